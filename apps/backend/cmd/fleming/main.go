@@ -17,10 +17,15 @@ import (
 
 	api "github.com/itspablomontes/fleming/apps/backend"
 	"github.com/itspablomontes/fleming/apps/backend/internal/config"
+	"github.com/itspablomontes/fleming/apps/backend/internal/anomaly"
+	"github.com/itspablomontes/fleming/apps/backend/internal/attestation"
 	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
 	"github.com/itspablomontes/fleming/apps/backend/internal/auth"
 	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/delegation"
+	"github.com/itspablomontes/fleming/apps/backend/internal/emergency"
 	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	"github.com/itspablomontes/fleming/apps/backend/internal/vc"
 )
 
 func main() {
@@ -67,6 +72,15 @@ func main() {
 	// Connection pool tuning (important for serverless Postgres like Neon).
 	applyConnPoolSettings(sqlDB, env)
 
+	if err := audit.PrepareMigration(db); err != nil {
+		slog.Error("refusing to migrate schema", "error", err)
+		os.Exit(1)
+	}
+	if err := timeline.PrepareMigration(db); err != nil {
+		slog.Error("failed to prepare timeline schema migration", "error", err)
+		os.Exit(1)
+	}
+
 	if err := db.AutoMigrate(
 		&auth.Challenge{},
 		&auth.User{},
@@ -76,7 +90,18 @@ func main() {
 		&timeline.EventFileAccess{},
 		&audit.AuditEntry{},
 		&audit.AuditBatch{},
+		&audit.AuditCheckpoint{},
+		&audit.AuditBatchLeaf{},
+		&audit.AuditLogNode{},
 		&consent.ConsentGrant{},
+		&consent.ConsentReceipt{},
+		&vc.Credential{},
+		&vc.RevocationList{},
+		&attestation.Attestation{},
+		&anomaly.Alert{},
+		&delegation.Delegation{},
+		&emergency.Profile{},
+		&emergency.Session{},
 	); err != nil {
 		slog.Error("failed to auto-migrate schema", "error", err)
 		os.Exit(1)
@@ -136,6 +136,11 @@ func main() {
 			log.Printf("Warning: failed to clean audit_batches: %v", err)
 		}
 	}
+	if err := db.Where("1 = 1").Delete(&audit.AuditLogNode{}).Error; err != nil {
+		if !strings.Contains(err.Error(), "does not exist") {
+			log.Printf("Warning: failed to clean audit_log_nodes: %v", err)
+		}
+	}
 
 	// Initialize services
 	auditRepo := audit.NewRepository(db)
@@ -144,8 +149,8 @@ func main() {
 	storageService := &noOpStorage{}
 
 	auditService := audit.NewService(auditRepo)
-	consentService := consent.NewService(consentRepo, auditService)
 	timelineService := timeline.NewService(timelineRepo, auditService, storageService, "fleming")
+	consentService := consent.NewService(consentRepo, auditService, timelineService)
 
 	// Mock Data Constants
 	patientId := "0x742d35Cc6634C0532925a3b844Bc9e7595f"
@@ -401,42 +406,62 @@ func main() {
 
 	now := time.Now()
 
+	// Approving, revoking and suspending take a wallet-signed receipt, and the seeded
+	// parties have no keys, so those transitions are written directly and audited here.
+	// Seeded grants carry no receipts.
+	setGrantState := func(grantID, actor string, from, to protoconsent.State, action protocol.Action) error {
+		changed, err := consentRepo.SetState(ctx, grantID, from, to, 0)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return fmt.Errorf("grant %s is no longer %s", grantID, from)
+		}
+		_ = auditService.Record(ctx, actor, action, protocol.ResourceConsent, grantID, common.JSONMap{"role": "grantor"})
+		return nil
+	}
+
 	// Approved grant (active)
-	grant1, err := consentService.RequestConsent(ctx, patientId, doctor1, "Primary care physician access", []string{"read", "write"}, now.Add(365*24*time.Hour))
+	grant1, err := consentService.RequestConsent(ctx, patientId, doctor1, "Primary care physician access", []string{"read", "write"}, nil, protoconsent.Terms{Purpose: protoconsent.PurposeTreatment}, now.Add(365*24*time.Hour))
 	if err != nil {
 		log.Fatalf("Failed to create consent grant 1: %v", err)
 	}
-	if err := consentService.ApproveConsent(ctx, grant1.ID); err != nil {
+	if err := setGrantState(grant1.ID, patientId, protoconsent.StateRequested, protoconsent.StateApproved, protocol.ActionConsentApprove); err != nil {
 		log.Fatalf("Failed to approve consent grant 1: %v", err)
 	}
 
 	// Denied grant
-	grant2, err := consentService.RequestConsent(ctx, patientId, doctor2, "Research study participation", []string{"read", "share"}, now.Add(180*24*time.Hour))
+	grant2, err := consentService.RequestConsent(ctx, patientId, doctor2, "Research study participation", []string{"read"}, nil, protoconsent.Terms{
+		Purpose:       protoconsent.PurposeResearch,
+		StudyID:       "FLEMING-LONGEVITY-01",
+		Categories:    []protoconsent.DataCategory{{EventType: prototline.EventLabResult}, {EventType: prototline.EventBiometric}},
+		SecondaryUses: []protoconsent.SecondaryUse{protoconsent.SecondaryUseAggregateAnalysis},
+	}, now.Add(180*24*time.Hour))
 	if err != nil {
 		log.Fatalf("Failed to create consent grant 2: %v", err)
 	}
-	if err := consentService.DenyConsent(ctx, grant2.ID); err != nil {
+	if err := consentService.DenyConsent(ctx, grant2.ID, patientId); err != nil {
 		log.Fatalf("Failed to deny consent grant 2: %v", err)
 	}
 
 	// Revoked grant (was approved, then revoked)
-	grant3, err := consentService.RequestConsent(ctx, patientId, doctor3, "Specialist consultation", []string{"read"}, now.Add(90*24*time.Hour))
+	grant3, err := consentService.RequestConsent(ctx, patientId, doctor3, "Specialist consultation", []string{"read"}, nil, protoconsent.Terms{Purpose: protoconsent.PurposeSecondOpinion}, now.Add(90*24*time.Hour))
 	if err != nil {
 		log.Fatalf("Failed to create consent grant 3: %v", err)
 	}
-	if err := consentService.ApproveConsent(ctx, grant3.ID); err != nil {
+	if err := setGrantState(grant3.ID, patientId, protoconsent.StateRequested, protoconsent.StateApproved, protocol.ActionConsentApprove); err != nil {
 		log.Fatalf("Failed to approve consent grant 3: %v", err)
 	}
-	if err := consentService.RevokeConsent(ctx, grant3.ID); err != nil {
+	if err := setGrantState(grant3.ID, patientId, protoconsent.StateApproved, protoconsent.StateRevoked, protocol.ActionConsentRevoke); err != nil {
 		log.Fatalf("Failed to revoke consent grant 3: %v", err)
 	}
 
 	// Expired grant (approved but expired)
-	grant4, err := consentService.RequestConsent(ctx, patientId, doctor2, "Temporary access for consultation", []string{"read"}, now.Add(-24*time.Hour)) // Expired yesterday
+	grant4, err := consentService.RequestConsent(ctx, patientId, doctor2, "Temporary access for consultation", []string{"read"}, nil, protoconsent.Terms{}, now.Add(-24*time.Hour)) // Expired yesterday
 	if err != nil {
 		log.Fatalf("Failed to create consent grant 4: %v", err)
 	}
-	if err := consentService.ApproveConsent(ctx, grant4.ID); err != nil {
+	if err := setGrantState(grant4.ID, patientId, protoconsent.StateRequested, protoconsent.StateApproved, protocol.ActionConsentApprove); err != nil {
 		log.Fatalf("Failed to approve consent grant 4: %v", err)
 	}
 	// Manually set state to expired (since CheckPermission would auto-expire it)
@@ -452,7 +477,7 @@ func main() {
 	_ = auditService.Record(ctx, grant4Get.Grantor, protocol.ActionConsentExpire, protocol.ResourceConsent, grant4Get.ID, nil)
 
 	// Pending grant (requested but not yet approved/denied)
-	_, err = consentService.RequestConsent(ctx, patientId, doctor1, "Extended access for ongoing treatment", []string{"read", "write", "share"}, now.Add(730*24*time.Hour))
+	_, err = consentService.RequestConsent(ctx, patientId, doctor1, "Extended access for ongoing treatment", []string{"read", "write", "share"}, nil, protoconsent.Terms{Purpose: protoconsent.PurposeTreatment}, now.Add(730*24*time.Hour))
 	if err != nil {
 		log.Fatalf("Failed to create consent grant 5: %v", err)
 	}
@@ -0,0 +1,28 @@
+package anchor
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrAlreadyAnchored is returned by Anchor when the root is already recorded on-chain.
+var ErrAlreadyAnchored = errors.New("anchor: root is already anchored")
+
+// Anchorer publishes audit Merkle roots to the FlemingAnchor contract.
+type Anchorer interface {
+	// Anchor submits root and returns the transaction hash without waiting for it to be mined.
+	Anchor(ctx context.Context, root [32]byte) (string, error)
+	// Receipt returns the status of a submitted transaction, or nil while it is not yet mined.
+	Receipt(ctx context.Context, txHash string) (*Receipt, error)
+	// Verify returns the block time at which root was anchored, or the zero time if it was not.
+	Verify(ctx context.Context, root [32]byte) (time.Time, error)
+}
+
+// Receipt describes a mined anchoring transaction.
+type Receipt struct {
+	TxHash        string
+	BlockNumber   uint64
+	Confirmations uint64 // Blocks on top of and including BlockNumber
+	Reverted      bool
+}
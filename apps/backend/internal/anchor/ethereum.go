@@ -0,0 +1,257 @@
+package anchor
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// FlemingAnchorABI is the ABI of FlemingAnchor.sol (see docs/ARCHITECTURE.md, section 7.3).
+const FlemingAnchorABI = `[
+	{"type":"function","name":"anchor","stateMutability":"nonpayable","inputs":[{"name":"merkleRoot","type":"bytes32"}],"outputs":[]},
+	{"type":"function","name":"verify","stateMutability":"view","inputs":[{"name":"merkleRoot","type":"bytes32"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"anchors","stateMutability":"view","inputs":[{"name":"","type":"bytes32"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"event","name":"AuditRootAnchored","anonymous":false,"inputs":[
+		{"name":"root","type":"bytes32","indexed":true},
+		{"name":"blockNumber","type":"uint256","indexed":true},
+		{"name":"timestamp","type":"uint256","indexed":false}
+	]}
+]`
+
+// gasHeadroom pads the node's gas estimate, in percent.
+const gasHeadroom = 20
+
+// EthereumAnchorer anchors roots through an Ethereum JSON-RPC endpoint, signing
+// EIP-155 transactions with a local key. It talks to the node through go-ethereum's
+// rpc, abi and rlp packages rather than ethclient, which would pull the whole
+// core/types blob and KZG stack into the backend for two contract calls.
+type EthereumAnchorer struct {
+	client   *rpc.Client
+	contract common.Address
+	key      *ecdsa.PrivateKey
+	from     common.Address
+	chainID  *big.Int
+	abi      abi.ABI
+
+	mu sync.Mutex // Serializes nonce assignment
+}
+
+// NewEthereumAnchorer creates an anchorer for the contract at the given address and
+// reads the chain ID from the node.
+func NewEthereumAnchorer(ctx context.Context, client *rpc.Client, contract common.Address, key *ecdsa.PrivateKey) (*EthereumAnchorer, error) {
+	parsed, err := abi.JSON(strings.NewReader(FlemingAnchorABI))
+	if err != nil {
+		return nil, fmt.Errorf("parse anchor abi: %w", err)
+	}
+
+	var chainID hexutil.Big
+	if err := client.CallContext(ctx, &chainID, "eth_chainId"); err != nil {
+		return nil, fmt.Errorf("get chain id: %w", err)
+	}
+
+	return &EthereumAnchorer{
+		client:   client,
+		contract: contract,
+		key:      key,
+		from:     crypto.PubkeyToAddress(key.PublicKey),
+		chainID:  chainID.ToInt(),
+		abi:      parsed,
+	}, nil
+}
+
+// DialEthereumAnchorer connects to rpcURL and creates an anchorer from a hex contract
+// address and hex private key.
+func DialEthereumAnchorer(ctx context.Context, rpcURL string, contractAddress string, privateKey string) (*EthereumAnchorer, error) {
+	if !common.IsHexAddress(contractAddress) {
+		return nil, fmt.Errorf("invalid anchor contract address %q", contractAddress)
+	}
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privateKey, "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid anchor private key: %w", err)
+	}
+
+	client, err := rpc.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, fmt.Errorf("dial anchor rpc: %w", err)
+	}
+
+	anchorer, err := NewEthereumAnchorer(ctx, client, common.HexToAddress(contractAddress), key)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return anchorer, nil
+}
+
+// From returns the address that signs anchoring transactions.
+func (a *EthereumAnchorer) From() common.Address {
+	return a.from
+}
+
+// Contract returns the FlemingAnchor contract address.
+func (a *EthereumAnchorer) Contract() common.Address {
+	return a.contract
+}
+
+// ChainID returns the chain ID reported by the node.
+func (a *EthereumAnchorer) ChainID() uint64 {
+	return a.chainID.Uint64()
+}
+
+// Anchor sends anchor(root) to the contract. It returns ErrAlreadyAnchored without
+// sending anything when the contract already holds the root, since the call would revert.
+func (a *EthereumAnchorer) Anchor(ctx context.Context, root [32]byte) (string, error) {
+	anchoredAt, err := a.Verify(ctx, root)
+	if err != nil {
+		return "", err
+	}
+	if !anchoredAt.IsZero() {
+		return "", ErrAlreadyAnchored
+	}
+
+	data, err := a.abi.Pack("anchor", root)
+	if err != nil {
+		return "", fmt.Errorf("pack anchor call: %w", err)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var nonce hexutil.Uint64
+	if err := a.client.CallContext(ctx, &nonce, "eth_getTransactionCount", a.from, "pending"); err != nil {
+		return "", fmt.Errorf("get nonce: %w", err)
+	}
+	var gasPrice hexutil.Big
+	if err := a.client.CallContext(ctx, &gasPrice, "eth_gasPrice"); err != nil {
+		return "", fmt.Errorf("get gas price: %w", err)
+	}
+	var gas hexutil.Uint64
+	if err := a.client.CallContext(ctx, &gas, "eth_estimateGas", a.callArgs(data, true)); err != nil {
+		return "", fmt.Errorf("estimate gas: %w", err)
+	}
+
+	raw, err := a.signTx(uint64(nonce), gasPrice.ToInt(), uint64(gas)*(100+gasHeadroom)/100, data)
+	if err != nil {
+		return "", err
+	}
+
+	var txHash common.Hash
+	if err := a.client.CallContext(ctx, &txHash, "eth_sendRawTransaction", hexutil.Bytes(raw)); err != nil {
+		return "", fmt.Errorf("send anchor transaction: %w", err)
+	}
+	return txHash.Hex(), nil
+}
+
+type rpcReceipt struct {
+	TransactionHash common.Hash    `json:"transactionHash"`
+	BlockNumber     hexutil.Uint64 `json:"blockNumber"`
+	Status          hexutil.Uint64 `json:"status"`
+}
+
+// txIndexingMessage is the error geth returns for a receipt it cannot find while its
+// transaction index is still catching up, including for transactions not yet mined.
+const txIndexingMessage = "transaction indexing is in progress"
+
+// Receipt looks up a transaction receipt and counts its confirmations.
+func (a *EthereumAnchorer) Receipt(ctx context.Context, txHash string) (*Receipt, error) {
+	var receipt *rpcReceipt
+	if err := a.client.CallContext(ctx, &receipt, "eth_getTransactionReceipt", common.HexToHash(txHash)); err != nil {
+		var dataErr rpc.DataError
+		if errors.As(err, &dataErr) && dataErr.ErrorData() == txIndexingMessage {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get anchor receipt: %w", err)
+	}
+	if receipt == nil {
+		return nil, nil
+	}
+
+	var head hexutil.Uint64
+	if err := a.client.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return nil, fmt.Errorf("get block number: %w", err)
+	}
+
+	block := uint64(receipt.BlockNumber)
+	var confirmations uint64
+	if uint64(head) >= block {
+		confirmations = uint64(head) - block + 1
+	}
+	return &Receipt{
+		TxHash:        receipt.TransactionHash.Hex(),
+		BlockNumber:   block,
+		Confirmations: confirmations,
+		Reverted:      receipt.Status == 0,
+	}, nil
+}
+
+// Verify calls verify(root) on the contract at the latest block.
+func (a *EthereumAnchorer) Verify(ctx context.Context, root [32]byte) (time.Time, error) {
+	data, err := a.abi.Pack("verify", root)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("pack verify call: %w", err)
+	}
+
+	var result hexutil.Bytes
+	if err := a.client.CallContext(ctx, &result, "eth_call", a.callArgs(data, false), "latest"); err != nil {
+		return time.Time{}, fmt.Errorf("call verify: %w", err)
+	}
+
+	values, err := a.abi.Unpack("verify", result)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unpack verify result: %w", err)
+	}
+	timestamp, ok := values[0].(*big.Int)
+	if !ok {
+		return time.Time{}, errors.New("unpack verify result: unexpected type")
+	}
+	if timestamp.Sign() == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(timestamp.Int64(), 0).UTC(), nil
+}
+
+func (a *EthereumAnchorer) callArgs(data []byte, withFrom bool) map[string]any {
+	args := map[string]any{
+		"to":    a.contract,
+		"input": hexutil.Bytes(data),
+	}
+	if withFrom {
+		args["from"] = a.from
+	}
+	return args
+}
+
+// signTx builds and signs a legacy EIP-155 contract call with zero value.
+func (a *EthereumAnchorer) signTx(nonce uint64, gasPrice *big.Int, gas uint64, data []byte) ([]byte, error) {
+	unsigned, err := rlp.EncodeToBytes([]any{nonce, gasPrice, gas, a.contract, new(big.Int), data, a.chainID, uint(0), uint(0)})
+	if err != nil {
+		return nil, fmt.Errorf("encode anchor transaction: %w", err)
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(unsigned), a.key)
+	if err != nil {
+		return nil, fmt.Errorf("sign anchor transaction: %w", err)
+	}
+
+	v := new(big.Int).Mul(a.chainID, big.NewInt(2))
+	v.Add(v, big.NewInt(35+int64(sig[64])))
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+
+	raw, err := rlp.EncodeToBytes([]any{nonce, gasPrice, gas, a.contract, new(big.Int), data, v, r, s})
+	if err != nil {
+		return nil, fmt.Errorf("encode anchor transaction: %w", err)
+	}
+	return raw, nil
+}
@@ -0,0 +1,286 @@
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const testChainID = 84532 // Base Sepolia
+
+var (
+	anchorSelector = crypto.Keccak256([]byte("anchor(bytes32)"))[:4]
+	verifySelector = crypto.Keccak256([]byte("verify(bytes32)"))[:4]
+)
+
+// fakeChain is an in-process JSON-RPC node that understands just enough of the eth
+// namespace to execute FlemingAnchor calls. It decodes and checks every signed
+// transaction, so signing bugs surface here without a real chain.
+type fakeChain struct {
+	mu       sync.Mutex
+	contract common.Address
+	block    uint64
+	now      time.Time
+	nonces   map[common.Address]uint64
+	anchors  map[common.Hash]uint64
+	pending  []minedTx
+	receipts map[common.Hash]map[string]any
+	failSend int // Number of upcoming eth_sendRawTransaction calls to reject
+}
+
+type minedTx struct {
+	hash common.Hash
+	root common.Hash
+}
+
+type legacyTx struct {
+	Nonce    uint64
+	GasPrice *big.Int
+	Gas      uint64
+	To       common.Address
+	Value    *big.Int
+	Data     []byte
+	V, R, S  *big.Int
+}
+
+type callArgs struct {
+	From  *common.Address `json:"from"`
+	To    *common.Address `json:"to"`
+	Input hexutil.Bytes   `json:"input"`
+}
+
+func newFakeChain(contract common.Address) *fakeChain {
+	return &fakeChain{
+		contract: contract,
+		block:    1,
+		now:      time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		nonces:   make(map[common.Address]uint64),
+		anchors:  make(map[common.Hash]uint64),
+		receipts: make(map[common.Hash]map[string]any),
+	}
+}
+
+func (c *fakeChain) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(testChainID))
+}
+
+func (c *fakeChain) BlockNumber() hexutil.Uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return hexutil.Uint64(c.block)
+}
+
+func (c *fakeChain) GasPrice() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1_000_000_000))
+}
+
+func (c *fakeChain) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return hexutil.Uint64(c.nonces[address])
+}
+
+func (c *fakeChain) EstimateGas(args callArgs) (hexutil.Uint64, error) {
+	if args.To == nil || *args.To != c.contract || !bytes.HasPrefix(args.Input, anchorSelector) {
+		return 0, errors.New("execution reverted")
+	}
+	return 45_000, nil
+}
+
+func (c *fakeChain) Call(args callArgs, block string) (hexutil.Bytes, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if args.To == nil || *args.To != c.contract || len(args.Input) != 36 || !bytes.Equal(args.Input[:4], verifySelector) {
+		return nil, errors.New("execution reverted")
+	}
+	timestamp := c.anchors[common.BytesToHash(args.Input[4:])]
+	return common.BigToHash(new(big.Int).SetUint64(timestamp)).Bytes(), nil
+}
+
+func (c *fakeChain) SendRawTransaction(raw hexutil.Bytes) (common.Hash, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failSend > 0 {
+		c.failSend--
+		return common.Hash{}, errors.New("connection reset by peer")
+	}
+
+	var tx legacyTx
+	if err := rlp.DecodeBytes(raw, &tx); err != nil {
+		return common.Hash{}, err
+	}
+
+	// EIP-155: v = recovery id + chainID*2 + 35.
+	recovery := new(big.Int).Sub(tx.V, big.NewInt(35+2*testChainID))
+	if !recovery.IsUint64() || recovery.Uint64() > 1 {
+		return common.Hash{}, errors.New("invalid chain id")
+	}
+	unsigned, _ := rlp.EncodeToBytes([]any{tx.Nonce, tx.GasPrice, tx.Gas, tx.To, tx.Value, tx.Data, big.NewInt(testChainID), uint(0), uint(0)})
+	sig := make([]byte, 65)
+	tx.R.FillBytes(sig[:32])
+	tx.S.FillBytes(sig[32:64])
+	sig[64] = byte(recovery.Uint64())
+	pub, err := crypto.SigToPub(crypto.Keccak256(unsigned), sig)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sender := crypto.PubkeyToAddress(*pub)
+
+	if tx.Nonce != c.nonces[sender] {
+		return common.Hash{}, errors.New("nonce too low")
+	}
+	if tx.To != c.contract || tx.Value.Sign() != 0 || len(tx.Data) != 36 || !bytes.Equal(tx.Data[:4], anchorSelector) {
+		return common.Hash{}, errors.New("unexpected transaction")
+	}
+	c.nonces[sender]++
+
+	hash := crypto.Keccak256Hash(raw)
+	c.pending = append(c.pending, minedTx{hash: hash, root: common.BytesToHash(tx.Data[4:])})
+	return hash, nil
+}
+
+func (c *fakeChain) GetTransactionReceipt(hash common.Hash) map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.receipts[hash]
+}
+
+// mine includes pending transactions in a new block, executing anchor(root).
+func (c *fakeChain) mine() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.block++
+	c.now = c.now.Add(12 * time.Second)
+	for _, tx := range c.pending {
+		status := hexutil.Uint64(1)
+		if c.anchors[tx.root] != 0 {
+			status = 0 // require(anchors[root] == 0)
+		} else {
+			c.anchors[tx.root] = uint64(c.now.Unix())
+		}
+		c.receipts[tx.hash] = map[string]any{
+			"transactionHash": tx.hash,
+			"blockNumber":     hexutil.Uint64(c.block),
+			"status":          status,
+		}
+	}
+	c.pending = nil
+}
+
+func newTestAnchorer(t *testing.T) (*EthereumAnchorer, *fakeChain, *ecdsa.PrivateKey) {
+	t.Helper()
+	contract := common.HexToAddress("0x00000000000000000000000000000000f1e3a1c0")
+	chain := newFakeChain(contract)
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", chain); err != nil {
+		t.Fatalf("register fake chain: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	t.Cleanup(func() {
+		client.Close()
+		server.Stop()
+	})
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	anchorer, err := NewEthereumAnchorer(context.Background(), client, contract, key)
+	if err != nil {
+		t.Fatalf("NewEthereumAnchorer() error = %v", err)
+	}
+	return anchorer, chain, key
+}
+
+func TestEthereumAnchorer_AnchorAndVerify(t *testing.T) {
+	ctx := context.Background()
+	anchorer, chain, key := newTestAnchorer(t)
+	root := common.HexToHash("0x5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328")
+
+	if at, err := anchorer.Verify(ctx, root); err != nil || !at.IsZero() {
+		t.Fatalf("Verify() before anchoring = %v, %v; want zero time", at, err)
+	}
+
+	txHash, err := anchorer.Anchor(ctx, root)
+	if err != nil {
+		t.Fatalf("Anchor() error = %v", err)
+	}
+	if chain.nonces[crypto.PubkeyToAddress(key.PublicKey)] != 1 {
+		t.Fatal("transaction was not accepted from the anchorer's address")
+	}
+
+	receipt, err := anchorer.Receipt(ctx, txHash)
+	if err != nil || receipt != nil {
+		t.Fatalf("Receipt() before mining = %+v, %v; want nil", receipt, err)
+	}
+
+	chain.mine()
+	chain.mine()
+	receipt, err = anchorer.Receipt(ctx, txHash)
+	if err != nil {
+		t.Fatalf("Receipt() error = %v", err)
+	}
+	if receipt == nil || receipt.Reverted || receipt.BlockNumber != 2 || receipt.Confirmations != 2 || receipt.TxHash != txHash {
+		t.Fatalf("Receipt() = %+v", receipt)
+	}
+
+	at, err := anchorer.Verify(ctx, root)
+	if err != nil || at.IsZero() {
+		t.Fatalf("Verify() after anchoring = %v, %v", at, err)
+	}
+
+	if _, err := anchorer.Anchor(ctx, root); !errors.Is(err, ErrAlreadyAnchored) {
+		t.Errorf("Anchor() twice error = %v, want %v", err, ErrAlreadyAnchored)
+	}
+}
+
+func TestEthereumAnchorer_SequentialNonces(t *testing.T) {
+	ctx := context.Background()
+	anchorer, chain, key := newTestAnchorer(t)
+
+	for i := byte(1); i <= 3; i++ {
+		if _, err := anchorer.Anchor(ctx, common.Hash{i}); err != nil {
+			t.Fatalf("Anchor(%d) error = %v", i, err)
+		}
+	}
+	if got := chain.nonces[crypto.PubkeyToAddress(key.PublicKey)]; got != 3 {
+		t.Errorf("nonce = %d, want 3", got)
+	}
+}
+
+func TestEthereumAnchorer_SendFailure(t *testing.T) {
+	ctx := context.Background()
+	anchorer, chain, _ := newTestAnchorer(t)
+	chain.failSend = 1
+
+	if _, err := anchorer.Anchor(ctx, common.Hash{1}); err == nil {
+		t.Fatal("Anchor() expected error when the node rejects the transaction")
+	}
+	if _, err := anchorer.Anchor(ctx, common.Hash{1}); err != nil {
+		t.Fatalf("Anchor() retry error = %v", err)
+	}
+}
+
+func TestDialEthereumAnchorer_InvalidConfig(t *testing.T) {
+	ctx := context.Background()
+	key := "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+	if _, err := DialEthereumAnchorer(ctx, "http://127.0.0.1:0", "not-an-address", key); err == nil {
+		t.Error("expected error for invalid contract address")
+	}
+	if _, err := DialEthereumAnchorer(ctx, "http://127.0.0.1:0", "0x00000000000000000000000000000000f1e3a1c0", "zz"); err == nil {
+		t.Error("expected error for invalid private key")
+	}
+}
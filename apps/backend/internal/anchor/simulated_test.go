@@ -0,0 +1,121 @@
+//go:build simulated
+
+// Run with: go test -tags simulated ./internal/anchor/...
+// The simulated backend pulls in go-ethereum's full node stack, so these tests are
+// kept out of the default build.
+
+package anchor
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// flemingAnchorRuntime is runtime bytecode with the same ABI and behaviour as
+// FlemingAnchor.sol (anchor, verify, anchors, AuditRootAnchored, "Already anchored"),
+// hand-assembled so the test needs no Solidity toolchain. It keys storage by the root
+// itself instead of Solidity's mapping slot, which callers cannot observe.
+const flemingAnchorRuntime = "0x60043610602b5760003560e01c8063eecdf92714603d57806375e36616146030578063b01b6d53146030575b600080fd5b6004355460005260206000f35b34602b576004358054607a574281554260005243817fd01a1b3f27e0d60728cd116d0318601e3cc01117b5fb2849ddaadba0697aafe160206000a3005b6308c379a060e01b600052602060045260106024526f416c726561647920616e63686f72656460801b60445260646000fd"
+
+func newSimulatedAnchorer(t *testing.T) (*EthereumAnchorer, *simulated.Backend) {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	contract := common.HexToAddress("0x00000000000000000000000000000000f1e3a1c0")
+	// Backend.Client hides the underlying RPC client, so expose the node over IPC and
+	// dial it the way DialEthereumAnchorer would.
+	ipcPath := filepath.Join(t.TempDir(), "geth.ipc")
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		crypto.PubkeyToAddress(key.PublicKey): {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))},
+		contract:                              {Code: common.FromHex(flemingAnchorRuntime)},
+	}, func(nodeConf *node.Config, _ *ethconfig.Config) {
+		nodeConf.IPCPath = ipcPath
+	})
+	t.Cleanup(func() { backend.Close() })
+	// Gas is estimated against the latest block. The genesis block has timestamp 0,
+	// so anchor() would store zero there and the estimate would miss the fresh SSTORE.
+	backend.Commit()
+
+	client, err := rpc.Dial(ipcPath)
+	if err != nil {
+		t.Fatalf("dial simulated backend: %v", err)
+	}
+	t.Cleanup(client.Close)
+	anchorer, err := NewEthereumAnchorer(context.Background(), client, contract, key)
+	if err != nil {
+		t.Fatalf("NewEthereumAnchorer() error = %v", err)
+	}
+	return anchorer, backend
+}
+
+func TestEthereumAnchorer_Simulated(t *testing.T) {
+	ctx := context.Background()
+	anchorer, backend := newSimulatedAnchorer(t)
+	root := common.HexToHash("0x5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328")
+
+	txHash, err := anchorer.Anchor(ctx, root)
+	if err != nil {
+		t.Fatalf("Anchor() error = %v", err)
+	}
+	if receipt, err := anchorer.Receipt(ctx, txHash); err != nil || receipt != nil {
+		t.Fatalf("Receipt() before commit = %+v, %v; want nil", receipt, err)
+	}
+
+	backend.Commit()
+	backend.Commit()
+
+	receipt, err := anchorer.Receipt(ctx, txHash)
+	if err != nil || receipt == nil {
+		t.Fatalf("Receipt() = %+v, %v", receipt, err)
+	}
+	if receipt.Reverted || receipt.Confirmations != 2 {
+		t.Errorf("Receipt() = %+v, want success with 2 confirmations", receipt)
+	}
+
+	header, err := backend.Client().HeaderByNumber(ctx, new(big.Int).SetUint64(receipt.BlockNumber))
+	if err != nil {
+		t.Fatalf("HeaderByNumber() error = %v", err)
+	}
+	anchoredAt, err := anchorer.Verify(ctx, root)
+	if err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if uint64(anchoredAt.Unix()) != header.Time {
+		t.Errorf("Verify() = %v, want block time %d", anchoredAt, header.Time)
+	}
+
+	mined, err := backend.Client().TransactionReceipt(ctx, common.HexToHash(txHash))
+	if err != nil {
+		t.Fatalf("TransactionReceipt() error = %v", err)
+	}
+	event := anchorer.abi.Events["AuditRootAnchored"]
+	if len(mined.Logs) != 1 || mined.Logs[0].Topics[0] != event.ID || mined.Logs[0].Topics[1] != root {
+		t.Errorf("anchor logs = %+v, want AuditRootAnchored(%s)", mined.Logs, root)
+	}
+
+	if _, err := anchorer.Anchor(ctx, root); !errors.Is(err, ErrAlreadyAnchored) {
+		t.Errorf("Anchor() twice error = %v, want %v", err, ErrAlreadyAnchored)
+	}
+}
+
+func TestEthereumAnchorer_SimulatedUnanchoredRoot(t *testing.T) {
+	anchorer, _ := newSimulatedAnchorer(t)
+
+	anchoredAt, err := anchorer.Verify(context.Background(), common.Hash{0x01})
+	if err != nil || !anchoredAt.IsZero() {
+		t.Errorf("Verify() = %v, %v; want zero time", anchoredAt, err)
+	}
+}
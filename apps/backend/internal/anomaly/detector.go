@@ -0,0 +1,341 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+const (
+	// scanPageSize is how many audit entries a scan reads per query.
+	scanPageSize = 500
+	// maxAlertEntries caps how many audit entry IDs an alert keeps as evidence.
+	maxAlertEntries = 50
+)
+
+// DetectorOptions configures the access rules.
+type DetectorOptions struct {
+	BulkDownloadCount  int           // Downloads by one party within BulkDownloadWindow that raise an alert
+	BulkDownloadWindow time.Duration // Window BulkDownloadCount is counted over
+	ExpiryWindow       time.Duration // Access this close to a grant's expiry raises an alert
+	SuspensionWindow   time.Duration // Attempts this soon after a grant was suspended raise an alert
+	RepeatWindow       time.Duration // Matches this soon after an open alert was last seen extend it
+	Lookback           time.Duration // How far back the first scan after start reads
+}
+
+// DefaultDetectorOptions returns the options used when none are configured.
+func DefaultDetectorOptions() DetectorOptions {
+	return DetectorOptions{
+		BulkDownloadCount:  20,
+		BulkDownloadWindow: 10 * time.Minute,
+		ExpiryWindow:       24 * time.Hour,
+		SuspensionWindow:   24 * time.Hour,
+		RepeatWindow:       24 * time.Hour,
+		Lookback:           24 * time.Hour,
+	}
+}
+
+// Detector runs the access rules over the audit stream and raises alerts.
+type Detector interface {
+	Scan(ctx context.Context) ([]Alert, error)
+	Start(ctx context.Context, interval time.Duration)
+}
+
+type detector struct {
+	repo         Repository
+	auditService audit.Service
+	grants       GrantManager
+	opts         DetectorOptions
+	now          func() time.Time
+	cursor       *audit.ChainCursor
+}
+
+// NewDetector creates a detector. Zero-valued options fall back to
+// DefaultDetectorOptions.
+func NewDetector(repo Repository, auditService audit.Service, grants GrantManager, opts DetectorOptions) Detector {
+	defaults := DefaultDetectorOptions()
+	if opts.BulkDownloadCount <= 0 {
+		opts.BulkDownloadCount = defaults.BulkDownloadCount
+	}
+	if opts.BulkDownloadWindow <= 0 {
+		opts.BulkDownloadWindow = defaults.BulkDownloadWindow
+	}
+	if opts.ExpiryWindow <= 0 {
+		opts.ExpiryWindow = defaults.ExpiryWindow
+	}
+	if opts.SuspensionWindow <= 0 {
+		opts.SuspensionWindow = defaults.SuspensionWindow
+	}
+	if opts.RepeatWindow <= 0 {
+		opts.RepeatWindow = defaults.RepeatWindow
+	}
+	if opts.Lookback <= 0 {
+		opts.Lookback = defaults.Lookback
+	}
+	return &detector{repo: repo, auditService: auditService, grants: grants, opts: opts, now: time.Now}
+}
+
+// Start runs Scan every interval until ctx is cancelled.
+func (d *detector) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := d.Scan(ctx); err != nil {
+					slog.Warn("access anomaly scan failed", "error", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Scan evaluates every access entry recorded since the previous scan and returns the
+// alerts it raised. The first scan reads back Lookback. Entries an open alert has
+// already counted are skipped, so rescanning after a restart does not double count.
+func (d *detector) Scan(ctx context.Context) ([]Alert, error) {
+	var start time.Time
+	if d.cursor == nil {
+		start = d.now().Add(-d.opts.Lookback)
+	}
+
+	var raised []Alert
+	grants := make(map[string]*consent.ConsentGrant)
+	for {
+		entries, err := d.auditService.ListAccessSince(ctx, d.cursor, start, scanPageSize)
+		if err != nil {
+			return raised, fmt.Errorf("scan audit access: %w", err)
+		}
+		for i := range entries {
+			entry := &entries[i]
+			alerts, err := d.evaluate(ctx, entry, grants)
+			if err != nil {
+				return raised, fmt.Errorf("evaluate audit entry %s: %w", entry.ID, err)
+			}
+			raised = append(raised, alerts...)
+			d.cursor = &audit.ChainCursor{Timestamp: entry.Timestamp, ID: entry.ID}
+		}
+		if len(entries) < scanPageSize {
+			break
+		}
+	}
+	return raised, nil
+}
+
+// finding is one rule matching an entry. Evidence lists the matching entries oldest
+// first and ends with the entry being evaluated.
+type finding struct {
+	rule     Rule
+	grantID  string
+	detail   string
+	evidence []audit.AuditEntry
+}
+
+// evaluate runs every rule against one entry. grants caches grant lookups for the scan.
+func (d *detector) evaluate(ctx context.Context, entry *audit.AuditEntry, grants map[string]*consent.ConsentGrant) ([]Alert, error) {
+	var findings []finding
+	grantID, _ := entry.Metadata[audit.MetadataGrantID].(string)
+
+	if entry.Action == protocol.ActionAccessDeny {
+		reason, _ := entry.Metadata[audit.MetadataDenyReason].(string)
+		switch audit.DenyReason(reason) {
+		case audit.DenyOutOfScope:
+			eventID, _ := entry.Metadata[audit.MetadataEventID].(string)
+			findings = append(findings, finding{
+				rule:     RuleOutOfScope,
+				grantID:  grantID,
+				detail:   fmt.Sprintf("requested event %s outside the grant's scope", eventID),
+				evidence: []audit.AuditEntry{*entry},
+			})
+		case audit.DenyPurposeMismatch:
+			purpose, _ := entry.Metadata["purpose"].(string)
+			studyID, _ := entry.Metadata["studyId"].(string)
+			findings = append(findings, finding{
+				rule:     RulePurposeMismatch,
+				grantID:  grantID,
+				detail:   fmt.Sprintf("declared purpose %q (study %q) not permitted by the grant", purpose, studyID),
+				evidence: []audit.AuditEntry{*entry},
+			})
+		case audit.DenyNoConsent:
+			grant, err := d.latestGrant(ctx, entry)
+			if err != nil {
+				return nil, err
+			}
+			if grant != nil && grant.State == protocolconsent.StateSuspended {
+				if since := entry.Timestamp.Sub(grant.UpdatedAt); since >= 0 && since <= d.opts.SuspensionWindow {
+					findings = append(findings, finding{
+						rule:     RuleAfterSuspension,
+						grantID:  grant.ID,
+						detail:   fmt.Sprintf("attempted access %s after the grant was suspended", since.Round(time.Second)),
+						evidence: []audit.AuditEntry{*entry},
+					})
+				}
+			}
+		}
+		return d.raise(ctx, entry, findings)
+	}
+
+	// A read several grants authorized is checked against each of them.
+	for _, readGrantID := range entry.GrantIDs() {
+		grant, ok := grants[readGrantID]
+		if !ok {
+			var err error
+			if grant, err = d.grants.GetGrantByID(ctx, readGrantID); err != nil {
+				return nil, err
+			}
+			grants[readGrantID] = grant
+		}
+		if grant.State == protocolconsent.StateSuspended && !entry.Timestamp.Before(grant.UpdatedAt) {
+			findings = append(findings, finding{
+				rule:     RuleAfterSuspension,
+				grantID:  readGrantID,
+				detail:   "accessed data after the grant was suspended",
+				evidence: []audit.AuditEntry{*entry},
+			})
+		}
+		if !grant.ExpiresAt.IsZero() {
+			if left := grant.ExpiresAt.Sub(entry.Timestamp); left > 0 && left <= d.opts.ExpiryWindow {
+				findings = append(findings, finding{
+					rule:     RuleNearExpiry,
+					grantID:  readGrantID,
+					detail:   fmt.Sprintf("accessed data %s before the grant expires", left.Round(time.Minute)),
+					evidence: []audit.AuditEntry{*entry},
+				})
+			}
+		}
+	}
+
+	if entry.Action == protocol.ActionDownload {
+		downloads, err := d.recentDownloads(ctx, entry)
+		if err != nil {
+			return nil, err
+		}
+		if len(downloads) >= d.opts.BulkDownloadCount {
+			findings = append(findings, finding{
+				rule:     RuleBulkDownload,
+				grantID:  grantID,
+				detail:   fmt.Sprintf("%d file downloads within %s", len(downloads), d.opts.BulkDownloadWindow),
+				evidence: downloads,
+			})
+		}
+	}
+	return d.raise(ctx, entry, findings)
+}
+
+// latestGrant returns the newest grant between the entry's subject and actor. After a
+// refusal for lack of consent, it is the grant most likely to have just stopped applying.
+func (d *detector) latestGrant(ctx context.Context, entry *audit.AuditEntry) (*consent.ConsentGrant, error) {
+	subject, _ := entry.Metadata[audit.MetadataSubject].(string)
+	grants, err := d.grants.GetGrantsByGrantor(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	var latest *consent.ConsentGrant
+	for i := range grants {
+		grant := &grants[i]
+		if strings.EqualFold(grant.Grantee, entry.Actor) && (latest == nil || grant.CreatedAt.After(latest.CreatedAt)) {
+			latest = grant
+		}
+	}
+	return latest, nil
+}
+
+// recentDownloads returns the actor's downloads of the subject's files within
+// BulkDownloadWindow up to and including entry, oldest first.
+func (d *detector) recentDownloads(ctx context.Context, entry *audit.AuditEntry) ([]audit.AuditEntry, error) {
+	start := types.NewTimestamp(entry.Timestamp.Add(-d.opts.BulkDownloadWindow))
+	end := types.NewTimestamp(entry.Timestamp)
+	entries, err := d.auditService.QueryEntries(ctx, protocol.QueryFilter{
+		Actor:     types.WalletAddress(entry.Actor),
+		Action:    protocol.ActionDownload,
+		StartTime: &start,
+		EndTime:   &end,
+	})
+	if err != nil {
+		return nil, err
+	}
+	downloads := entries[:0]
+	for _, download := range entries {
+		if download.Subject == entry.Subject {
+			downloads = append(downloads, download)
+		}
+	}
+	sort.Slice(downloads, func(i, j int) bool { return downloads[i].Timestamp.Before(downloads[j].Timestamp) })
+	return downloads, nil
+}
+
+// raise stores an alert for each finding, or extends the open alert for the same rule,
+// parties and grant when one was seen within RepeatWindow. Only new alerts are returned.
+func (d *detector) raise(ctx context.Context, entry *audit.AuditEntry, findings []finding) ([]Alert, error) {
+	var raised []Alert
+	grantee := strings.ToLower(entry.Actor)
+	for _, f := range findings {
+		open, err := d.repo.FindOpen(ctx, f.rule, entry.Subject, grantee, f.grantID, entry.Timestamp.Add(-d.opts.RepeatWindow))
+		if err != nil {
+			return raised, err
+		}
+		if open != nil {
+			if !entry.Timestamp.After(open.LastSeenAt) {
+				continue // Counted by an earlier scan
+			}
+			open.Count++
+			open.Detail = f.detail
+			open.LastSeenAt = entry.Timestamp
+			if len(open.EntryIDs) < maxAlertEntries {
+				open.EntryIDs = append(open.EntryIDs, entry.ID)
+			}
+			if err := d.repo.Update(ctx, open); err != nil {
+				return raised, err
+			}
+			continue
+		}
+
+		ids := make(common.JSONStrings, 0, min(len(f.evidence), maxAlertEntries))
+		for _, evidence := range f.evidence[:min(len(f.evidence), maxAlertEntries)] {
+			ids = append(ids, evidence.ID)
+		}
+		alert := &Alert{
+			Rule:        f.rule,
+			Subject:     entry.Subject,
+			Status:      StatusOpen,
+			Grantee:     grantee,
+			GrantID:     f.grantID,
+			Detail:      f.detail,
+			Count:       len(f.evidence),
+			EntryIDs:    ids,
+			FirstSeenAt: f.evidence[0].Timestamp,
+			LastSeenAt:  entry.Timestamp,
+		}
+		if err := d.repo.Create(ctx, alert); err != nil {
+			return raised, err
+		}
+
+		metadata := common.JSONMap{
+			audit.MetadataSubject: alert.Subject,
+			audit.MetadataGrantID: alert.GrantID,
+			"grantee":             alert.Grantee,
+			"rule":                alert.Rule,
+			"entryId":             entry.ID,
+		}
+		// Recorded under the patient, like other actions taken on their behalf.
+		if err := d.auditService.Record(ctx, alert.Subject, protocol.ActionAlertRaise, protocol.ResourceAlert, alert.ID, metadata); err != nil {
+			slog.Warn("failed to record access alert", "alert", alert.ID, "error", err)
+		}
+		slog.Info("access alert raised", "alert", alert.ID, "rule", alert.Rule, "subject", alert.Subject, "grantee", alert.Grantee)
+		raised = append(raised, *alert)
+	}
+	return raised, nil
+}
@@ -0,0 +1,66 @@
+package anomaly
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+)
+
+// Rule names the access pattern an alert was raised for.
+type Rule string
+
+const (
+	// RuleBulkDownload flags many file downloads by one party in a short window.
+	RuleBulkDownload Rule = "bulk_download"
+	// RuleNearExpiry flags access shortly before the authorizing grant expires.
+	RuleNearExpiry Rule = "near_expiry"
+	// RuleAfterSuspension flags access attempts by a party whose grant was just suspended.
+	RuleAfterSuspension Rule = "after_suspension"
+	// RuleOutOfScope flags attempts to reach events outside the party's grant.
+	RuleOutOfScope Rule = "out_of_scope"
+	// RulePurposeMismatch flags attempts to use data for a purpose, study or secondary
+	// use the party's grants do not permit.
+	RulePurposeMismatch Rule = "purpose_mismatch"
+)
+
+// Status is where an alert stands with the patient.
+type Status string
+
+const (
+	StatusOpen     Status = "open"
+	StatusResolved Status = "resolved"
+)
+
+// IsValid reports whether s is a known alert status.
+func (s Status) IsValid() bool {
+	return s == StatusOpen || s == StatusResolved
+}
+
+// ResolutionGrantSuspended records that the patient answered an alert by suspending
+// the grant it concerns.
+const ResolutionGrantSuspended = "grant_suspended"
+
+// Alert is a suspicious access pattern found in the audit stream. Matches found while
+// the alert is open extend it rather than raising another.
+type Alert struct {
+	ID          string             `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Rule        Rule               `json:"rule" gorm:"type:varchar(50);not null"`
+	Subject     string             `json:"subject" gorm:"index:idx_alert_subject_status,priority:1;type:varchar(255);not null"` // Patient, lowercased
+	Status      Status             `json:"status" gorm:"index:idx_alert_subject_status,priority:2;type:varchar(20);not null"`
+	Grantee     string             `json:"grantee" gorm:"index;type:varchar(255);not null"` // Flagged party, lowercased
+	GrantID     string             `json:"grantId,omitempty" gorm:"index;type:varchar(64)"`
+	Detail      string             `json:"detail" gorm:"type:text"`
+	Count       int                `json:"count" gorm:"not null"`      // Audit entries matched so far
+	EntryIDs    common.JSONStrings `json:"entryIds" gorm:"type:jsonb"` // The first maxAlertEntries matches
+	FirstSeenAt time.Time          `json:"firstSeenAt" gorm:"not null"`
+	LastSeenAt  time.Time          `json:"lastSeenAt" gorm:"not null"`
+	ResolvedAt  *time.Time         `json:"resolvedAt,omitempty"`
+	Resolution  string             `json:"resolution,omitempty" gorm:"type:varchar(50)"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for access alerts.
+func (Alert) TableName() string {
+	return "access_alerts"
+}
@@ -0,0 +1,97 @@
+package anomaly
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+)
+
+type Handler struct {
+	service Service
+}
+
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	alertGroup := rg.Group("/alerts")
+	{
+		alertGroup.GET("", h.HandleList)
+		alertGroup.POST("/:id/suspend", h.HandleSuspend)
+	}
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// HandleList lists the caller's alerts, optionally filtered by ?status=open|resolved.
+func (h *Handler) HandleList(c *gin.Context) {
+	subject, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	status := Status(c.Query("status"))
+	if status != "" && !status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status filter"})
+		return
+	}
+
+	alerts, err := h.service.ListAlerts(c.Request.Context(), subject, status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
+
+// HandleSuspend answers an alert by suspending the consent grant it concerns.
+func (h *Handler) HandleSuspend(c *gin.Context) {
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	// The signature may be left out when the grant is already suspended.
+	var req struct {
+		Signature string `json:"signature"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	alert, err := h.service.SuspendGrant(c.Request.Context(), c.Param("id"), actor, req.Signature)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNotSubject), errors.Is(err, consent.ErrForbiddenActor):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "alert not found"})
+		case errors.Is(err, ErrAlertResolved), errors.Is(err, ErrNoGrant), errors.Is(err, consent.ErrStaleReceipt):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"alert": alert})
+}
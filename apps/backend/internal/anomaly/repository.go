@@ -0,0 +1,83 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for alert persistence.
+type Repository interface {
+	Create(ctx context.Context, alert *Alert) error
+	Update(ctx context.Context, alert *Alert) error
+	GetByID(ctx context.Context, id string) (*Alert, error)
+	ListBySubject(ctx context.Context, subject string, status Status) ([]Alert, error)
+	FindOpen(ctx context.Context, rule Rule, subject, grantee, grantID string, since time.Time) (*Alert, error)
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new GORM repository for alerts.
+func NewRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) Create(ctx context.Context, alert *Alert) error {
+	if err := r.db.WithContext(ctx).Create(alert).Error; err != nil {
+		return fmt.Errorf("create access alert: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) Update(ctx context.Context, alert *Alert) error {
+	if err := r.db.WithContext(ctx).Save(alert).Error; err != nil {
+		return fmt.Errorf("update access alert: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetByID(ctx context.Context, id string) (*Alert, error) {
+	var alert Alert
+	if err := r.db.WithContext(ctx).First(&alert, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get access alert %s: %w", id, err)
+	}
+	return &alert, nil
+}
+
+// ListBySubject returns the patient's alerts, most recently seen first. An empty
+// status lists every alert.
+func (r *gormRepository) ListBySubject(ctx context.Context, subject string, status Status) ([]Alert, error) {
+	var alerts []Alert
+	query := r.db.WithContext(ctx).Where("subject = ?", strings.ToLower(subject))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("last_seen_at DESC").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("list access alerts for %s: %w", subject, err)
+	}
+	return alerts, nil
+}
+
+// FindOpen returns the open alert for the same rule, parties and grant that was last
+// seen at or after since, or nil when there is none.
+func (r *gormRepository) FindOpen(ctx context.Context, rule Rule, subject, grantee, grantID string, since time.Time) (*Alert, error) {
+	var alert Alert
+	err := r.db.WithContext(ctx).
+		Where("rule = ? AND subject = ? AND grantee = ? AND grant_id = ? AND status = ?",
+			rule, strings.ToLower(subject), strings.ToLower(grantee), grantID, StatusOpen).
+		Where("last_seen_at >= ?", since).
+		Order("last_seen_at DESC").
+		First(&alert).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("find open access alert: %w", err)
+	}
+	return &alert, nil
+}
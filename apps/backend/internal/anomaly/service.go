@@ -0,0 +1,108 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+)
+
+var (
+	// ErrNotSubject is returned when someone other than the patient acts on an alert.
+	ErrNotSubject = errors.New("only the patient an alert concerns may act on it")
+	// ErrNoGrant is returned when suspending from an alert that names no consent grant.
+	ErrNoGrant = errors.New("alert is not tied to a consent grant")
+	// ErrAlertResolved is returned when answering an alert that was already answered.
+	ErrAlertResolved = errors.New("alert is already resolved")
+)
+
+// GrantManager looks up and suspends the consent grants alerts refer to.
+type GrantManager interface {
+	GetGrantByID(ctx context.Context, grantID string) (*consent.ConsentGrant, error)
+	GetGrantsByGrantor(ctx context.Context, grantor string) ([]consent.ConsentGrant, error)
+	SuspendConsent(ctx context.Context, grantID, actor, signature string) (*consent.ConsentReceipt, error)
+}
+
+// Service defines the patient-facing side of access alerts.
+type Service interface {
+	ListAlerts(ctx context.Context, subject string, status Status) ([]Alert, error)
+	SuspendGrant(ctx context.Context, alertID, actor, signature string) (*Alert, error)
+}
+
+type service struct {
+	repo         Repository
+	auditService audit.Service
+	grants       GrantManager
+	now          func() time.Time
+}
+
+// NewService creates a new alert service.
+func NewService(repo Repository, auditService audit.Service, grants GrantManager) Service {
+	return &service{
+		repo:         repo,
+		auditService: auditService,
+		grants:       grants,
+		now:          time.Now,
+	}
+}
+
+func (s *service) ListAlerts(ctx context.Context, subject string, status Status) ([]Alert, error) {
+	alerts, err := s.repo.ListBySubject(ctx, subject, status)
+	if err != nil {
+		return nil, err
+	}
+	return alerts, nil
+}
+
+// SuspendGrant answers an alert by suspending the grant it concerns and resolving it.
+// The suspension needs the patient's signature over the grant's receipt, as any other
+// does. A grant that is already suspended is left as it is and needs none.
+func (s *service) SuspendGrant(ctx context.Context, alertID, actor, signature string) (*Alert, error) {
+	alert, err := s.repo.GetByID(ctx, alertID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(alert.Subject, actor) {
+		return nil, ErrNotSubject
+	}
+	if alert.Status == StatusResolved {
+		return nil, ErrAlertResolved
+	}
+	if alert.GrantID == "" {
+		return nil, ErrNoGrant
+	}
+
+	grant, err := s.grants.GetGrantByID(ctx, alert.GrantID)
+	if err != nil {
+		return nil, err
+	}
+	if grant.State != protocolconsent.StateSuspended {
+		if _, err := s.grants.SuspendConsent(ctx, grant.ID, actor, signature); err != nil {
+			return nil, fmt.Errorf("suspend grant %s: %w", grant.ID, err)
+		}
+	}
+
+	resolvedAt := s.now()
+	alert.Status = StatusResolved
+	alert.ResolvedAt = &resolvedAt
+	alert.Resolution = ResolutionGrantSuspended
+	if err := s.repo.Update(ctx, alert); err != nil {
+		return nil, err
+	}
+
+	metadata := common.JSONMap{
+		audit.MetadataSubject: alert.Subject,
+		audit.MetadataGrantID: alert.GrantID,
+		"rule":                alert.Rule,
+		"resolution":          alert.Resolution,
+	}
+	_ = s.auditService.Record(ctx, actor, protocol.ActionAlertResolve, protocol.ResourceAlert, alert.ID, metadata)
+	return alert, nil
+}
@@ -0,0 +1,335 @@
+package anomaly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"gorm.io/gorm"
+)
+
+const (
+	testPatient = "0x00000000000000000000000000000000000000aa"
+	testDoctor  = "0x00000000000000000000000000000000000000d1"
+)
+
+// mockAuditService serves entries as the access stream.
+type mockAuditService struct {
+	audittest.Service
+	entries []audit.AuditEntry
+}
+
+func (m *mockAuditService) add(actor string, action protocol.Action, at time.Time, metadata common.JSONMap) {
+	metadata[audit.MetadataSubject] = testPatient
+	m.entries = append(m.entries, audit.AuditEntry{
+		ID:        fmt.Sprintf("entry-%02d", len(m.entries)),
+		Actor:     actor,
+		Action:    action,
+		Timestamp: at,
+		Metadata:  metadata,
+		Subject:   strings.ToLower(testPatient),
+	})
+}
+
+func (m *mockAuditService) QueryEntries(ctx context.Context, filter protocol.QueryFilter) ([]audit.AuditEntry, error) {
+	var result []audit.AuditEntry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		entry := m.entries[i]
+		if entry.Actor != filter.Actor.String() || entry.Action != filter.Action {
+			continue
+		}
+		if entry.Timestamp.Before(filter.StartTime.Time) || entry.Timestamp.After(filter.EndTime.Time) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func (m *mockAuditService) ListAccessSince(ctx context.Context, after *audit.ChainCursor, start time.Time, limit int) ([]audit.AuditEntry, error) {
+	var result []audit.AuditEntry
+	for _, entry := range m.entries {
+		if after != nil && (entry.Timestamp.Before(after.Timestamp) || (entry.Timestamp.Equal(after.Timestamp) && entry.ID <= after.ID)) {
+			continue
+		}
+		if entry.Timestamp.Before(start) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+type mockRepo struct {
+	alerts []*Alert
+}
+
+func (m *mockRepo) Create(ctx context.Context, alert *Alert) error {
+	alert.ID = fmt.Sprintf("alert-%d", len(m.alerts)+1)
+	m.alerts = append(m.alerts, alert)
+	return nil
+}
+
+func (m *mockRepo) Update(ctx context.Context, alert *Alert) error {
+	for i, existing := range m.alerts {
+		if existing.ID == alert.ID {
+			m.alerts[i] = alert
+			return nil
+		}
+	}
+	return gorm.ErrRecordNotFound
+}
+
+func (m *mockRepo) GetByID(ctx context.Context, id string) (*Alert, error) {
+	for _, alert := range m.alerts {
+		if alert.ID == id {
+			copied := *alert
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("get access alert %s: %w", id, gorm.ErrRecordNotFound)
+}
+
+func (m *mockRepo) ListBySubject(ctx context.Context, subject string, status Status) ([]Alert, error) {
+	var alerts []Alert
+	for _, alert := range m.alerts {
+		if alert.Subject == strings.ToLower(subject) && (status == "" || alert.Status == status) {
+			alerts = append(alerts, *alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (m *mockRepo) FindOpen(ctx context.Context, rule Rule, subject, grantee, grantID string, since time.Time) (*Alert, error) {
+	for _, alert := range m.alerts {
+		if alert.Rule == rule && alert.Subject == strings.ToLower(subject) && alert.Grantee == strings.ToLower(grantee) &&
+			alert.GrantID == grantID && alert.Status == StatusOpen && !alert.LastSeenAt.Before(since) {
+			copied := *alert
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+type mockGrants struct {
+	grants    map[string]*consent.ConsentGrant
+	suspended []string
+}
+
+func (m *mockGrants) GetGrantByID(ctx context.Context, grantID string) (*consent.ConsentGrant, error) {
+	grant, ok := m.grants[grantID]
+	if !ok {
+		return nil, fmt.Errorf("get consent grant %s: %w", grantID, gorm.ErrRecordNotFound)
+	}
+	copied := *grant
+	return &copied, nil
+}
+
+func (m *mockGrants) GetGrantsByGrantor(ctx context.Context, grantor string) ([]consent.ConsentGrant, error) {
+	var grants []consent.ConsentGrant
+	for _, grant := range m.grants {
+		if grant.Grantor == grantor {
+			grants = append(grants, *grant)
+		}
+	}
+	return grants, nil
+}
+
+func (m *mockGrants) SuspendConsent(ctx context.Context, grantID, actor, signature string) (*consent.ConsentReceipt, error) {
+	grant, ok := m.grants[grantID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if grant.Grantor != actor {
+		return nil, consent.ErrNotGrantor
+	}
+	if signature == "" {
+		return nil, consent.ErrInvalidSignature
+	}
+	grant.State = protocolconsent.StateSuspended
+	m.suspended = append(m.suspended, grantID)
+	return &consent.ConsentReceipt{GrantID: grantID, Signer: actor, Signature: signature}, nil
+}
+
+func newTestDetector(repo Repository, stream *mockAuditService, grants *mockGrants, now time.Time, opts DetectorOptions) Detector {
+	d := NewDetector(repo, stream, grants, opts).(*detector)
+	d.now = func() time.Time { return now }
+	return d
+}
+
+func TestDetector_BulkDownload(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	stream := &mockAuditService{}
+	grants := &mockGrants{grants: map[string]*consent.ConsentGrant{
+		"grant-1": {ID: "grant-1", Grantor: testPatient, Grantee: testDoctor, State: protocolconsent.StateApproved},
+	}}
+	repo := &mockRepo{}
+	opts := DetectorOptions{BulkDownloadCount: 3, BulkDownloadWindow: 10 * time.Minute}
+	detector := newTestDetector(repo, stream, grants, now, opts)
+
+	// Two downloads an hour apart, then three within two minutes.
+	for _, offset := range []time.Duration{-90, -30, -5, -4, -3} {
+		stream.add(testDoctor, protocol.ActionDownload, now.Add(offset*time.Minute), common.JSONMap{audit.MetadataGrantID: "grant-1"})
+	}
+
+	raised, err := detector.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if len(raised) != 1 || raised[0].Rule != RuleBulkDownload || raised[0].Count != 3 || raised[0].GrantID != "grant-1" {
+		t.Fatalf("Scan() = %+v, want one bulk_download alert over 3 downloads", raised)
+	}
+	if !raised[0].FirstSeenAt.Equal(stream.entries[2].Timestamp) || len(raised[0].EntryIDs) != 3 {
+		t.Errorf("alert evidence = %+v", raised[0])
+	}
+	if records := stream.WithAction(protocol.ActionAlertRaise); len(records) != 1 || records[0].Actor != strings.ToLower(testPatient) || records[0].ResourceID != raised[0].ID {
+		t.Errorf("alert.raise records = %+v", records)
+	}
+
+	// A further download in the burst extends the open alert.
+	stream.add(testDoctor, protocol.ActionDownload, now.Add(-2*time.Minute), common.JSONMap{audit.MetadataGrantID: "grant-1"})
+	if raised, err := detector.Scan(ctx); err != nil || len(raised) != 0 {
+		t.Fatalf("Scan() = %+v, %v; want no new alerts", raised, err)
+	}
+	if repo.alerts[0].Count != 4 {
+		t.Errorf("alert count = %d, want 4", repo.alerts[0].Count)
+	}
+
+	// A restarted detector rescans the same entries without counting them twice.
+	if raised, err := newTestDetector(repo, stream, grants, now, opts).Scan(ctx); err != nil || len(raised) != 0 {
+		t.Fatalf("rescan Scan() = %+v, %v; want no new alerts", raised, err)
+	}
+	if len(repo.alerts) != 1 || repo.alerts[0].Count != 4 {
+		t.Errorf("after rescan alerts = %+v", repo.alerts)
+	}
+}
+
+func TestDetector_GrantRules(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	other := "0x00000000000000000000000000000000000000d2"
+	third := "0x00000000000000000000000000000000000000d3"
+	stream := &mockAuditService{}
+	grants := &mockGrants{grants: map[string]*consent.ConsentGrant{
+		"expiring":  {ID: "expiring", Grantor: testPatient, Grantee: testDoctor, State: protocolconsent.StateApproved, ExpiresAt: now.Add(2 * time.Hour)},
+		"scoped":    {ID: "scoped", Grantor: testPatient, Grantee: other, State: protocolconsent.StateApproved, Scope: common.JSONStrings{"lab_result"}},
+		"suspended": {ID: "suspended", Grantor: testPatient, Grantee: third, State: protocolconsent.StateSuspended, UpdatedAt: now.Add(-time.Hour)},
+	}}
+	repo := &mockRepo{}
+	detector := newTestDetector(repo, stream, grants, now, DetectorOptions{})
+
+	stream.add(testDoctor, protocol.ActionRead, now.Add(-30*time.Minute), common.JSONMap{audit.MetadataGrantID: "expiring"})
+	stream.add(other, protocol.ActionAccessDeny, now.Add(-20*time.Minute), common.JSONMap{
+		audit.MetadataGrantID:    "scoped",
+		audit.MetadataDenyReason: string(audit.DenyOutOfScope),
+		audit.MetadataEventID:    "evt-note",
+	})
+	stream.add(third, protocol.ActionAccessDeny, now.Add(-10*time.Minute), common.JSONMap{
+		audit.MetadataDenyReason: string(audit.DenyNoConsent),
+	})
+	stream.add(testDoctor, protocol.ActionAccessDeny, now.Add(-5*time.Minute), common.JSONMap{
+		audit.MetadataGrantID:    "expiring",
+		audit.MetadataDenyReason: string(audit.DenyPurposeMismatch),
+		"purpose":                "research",
+		"studyId":                "NCT01234567",
+	})
+
+	raised, err := detector.Scan(ctx)
+	if err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	want := []struct {
+		rule    Rule
+		grantee string
+		grantID string
+	}{
+		{RuleNearExpiry, testDoctor, "expiring"},
+		{RuleOutOfScope, other, "scoped"},
+		{RuleAfterSuspension, third, "suspended"},
+		{RulePurposeMismatch, testDoctor, "expiring"},
+	}
+	if len(raised) != len(want) {
+		t.Fatalf("Scan() raised %d alerts, want %d: %+v", len(raised), len(want), raised)
+	}
+	for i, w := range want {
+		if raised[i].Rule != w.rule || raised[i].Grantee != w.grantee || raised[i].GrantID != w.grantID || raised[i].Status != StatusOpen {
+			t.Errorf("alert %d = %+v, want %s for %s on %s", i, raised[i], w.rule, w.grantee, w.grantID)
+		}
+	}
+
+	// Attempts long after the suspension are ordinary refusals.
+	grants.grants["suspended"].UpdatedAt = now.Add(-72 * time.Hour)
+	repo.alerts = nil
+	stream.Reset()
+	if raised, err := newTestDetector(repo, stream, grants, now, DetectorOptions{}).Scan(ctx); err != nil || len(raised) != 3 {
+		t.Errorf("Scan() = %+v, %v; want only the expiry, scope and purpose alerts", raised, err)
+	}
+}
+
+// testSignature stands in for the patient's receipt signature, which the consent
+// service verifies.
+const testSignature = "0x5167"
+
+func TestService_SuspendGrant(t *testing.T) {
+	ctx := context.Background()
+	stream := &mockAuditService{}
+	grants := &mockGrants{grants: map[string]*consent.ConsentGrant{
+		"grant-1": {ID: "grant-1", Grantor: testPatient, Grantee: testDoctor, State: protocolconsent.StateApproved},
+	}}
+	repo := &mockRepo{}
+	repo.Create(ctx, &Alert{Rule: RuleBulkDownload, Subject: testPatient, Grantee: testDoctor, GrantID: "grant-1", Status: StatusOpen})
+	repo.Create(ctx, &Alert{Rule: RuleOutOfScope, Subject: testPatient, Grantee: testDoctor, Status: StatusOpen})
+	svc := NewService(repo, stream, grants)
+
+	if _, err := svc.SuspendGrant(ctx, "alert-1", testDoctor, testSignature); !errors.Is(err, ErrNotSubject) {
+		t.Errorf("SuspendGrant() by the grantee error = %v, want %v", err, ErrNotSubject)
+	}
+	if _, err := svc.SuspendGrant(ctx, "alert-2", testPatient, testSignature); !errors.Is(err, ErrNoGrant) {
+		t.Errorf("SuspendGrant() without a grant error = %v, want %v", err, ErrNoGrant)
+	}
+	if _, err := svc.SuspendGrant(ctx, "alert-9", testPatient, testSignature); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("SuspendGrant() unknown alert error = %v, want not found", err)
+	}
+	if _, err := svc.SuspendGrant(ctx, "alert-1", testPatient, ""); !errors.Is(err, consent.ErrInvalidSignature) {
+		t.Errorf("SuspendGrant() unsigned error = %v, want %v", err, consent.ErrInvalidSignature)
+	}
+
+	alert, err := svc.SuspendGrant(ctx, "alert-1", testPatient, testSignature)
+	if err != nil {
+		t.Fatalf("SuspendGrant() error = %v", err)
+	}
+	if alert.Status != StatusResolved || alert.ResolvedAt == nil || alert.Resolution != ResolutionGrantSuspended {
+		t.Errorf("SuspendGrant() = %+v, want resolved", alert)
+	}
+	if grants.grants["grant-1"].State != protocolconsent.StateSuspended || len(grants.suspended) != 1 {
+		t.Errorf("grant state = %s, want suspended", grants.grants["grant-1"].State)
+	}
+	if records := stream.WithAction(protocol.ActionAlertResolve); len(records) != 1 || records[0].ResourceID != "alert-1" {
+		t.Errorf("alert.resolve records = %+v", records)
+	}
+
+	if _, err := svc.SuspendGrant(ctx, "alert-1", testPatient, testSignature); !errors.Is(err, ErrAlertResolved) {
+		t.Errorf("second SuspendGrant() error = %v, want %v", err, ErrAlertResolved)
+	}
+
+	open, err := svc.ListAlerts(ctx, testPatient, StatusOpen)
+	if err != nil {
+		t.Fatalf("ListAlerts() error = %v", err)
+	}
+	if len(open) != 1 || open[0].ID != "alert-2" {
+		t.Errorf("ListAlerts(open) = %+v, want alert-2", open)
+	}
+}
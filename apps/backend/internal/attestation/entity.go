@@ -0,0 +1,61 @@
+package attestation
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/attestation"
+)
+
+// Attestation is the database model for a provider attestation of a timeline event.
+// A row starts as the patient's pending request; once the provider signs, it carries
+// the event hash and signature and is linked into the graph with an attested_by edge.
+type Attestation struct {
+	ID                 string                        `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	EventID            string                        `json:"eventId" gorm:"type:uuid;not null;index"`
+	PatientID          string                        `json:"patientId" gorm:"index;type:varchar(255);not null"` // Requester / event owner
+	Attester           string                        `json:"attester" gorm:"index;type:varchar(255);not null"`  // Provider asked to sign
+	Type               attestation.AttestationType   `json:"type" gorm:"type:varchar(50);not null"`
+	Status             attestation.AttestationStatus `json:"status" gorm:"type:varchar(50);not null"`
+	Message            string                        `json:"message,omitempty" gorm:"type:text"` // Patient's note to the provider
+	Notes              string                        `json:"notes,omitempty" gorm:"type:text"`   // Provider's notes
+	EventHash          string                        `json:"eventHash,omitempty" gorm:"type:varchar(128)"`
+	Signature          string                        `json:"signature,omitempty" gorm:"type:varchar(255)"`
+	SignatureAlgorithm string                        `json:"signatureAlgorithm,omitempty" gorm:"type:varchar(50)"`
+	RequestExpiresAt   time.Time                     `json:"requestExpiresAt" gorm:"not null"`
+	SignedAt           *time.Time                    `json:"signedAt,omitempty"`
+	ExpiresAt          *time.Time                    `json:"expiresAt,omitempty" gorm:"index"`
+	RevokedAt          *time.Time                    `json:"revokedAt,omitempty"`
+	AttestationEventID string                        `json:"attestationEventId,omitempty" gorm:"type:uuid"` // Provider note on the patient's timeline
+	EdgeID             string                        `json:"edgeId,omitempty" gorm:"type:uuid"`             // attested_by edge to that note
+	SchemaVersion      string                        `json:"schemaVersion" gorm:"type:varchar(50)"`
+	CreatedAt          time.Time                     `json:"createdAt"`
+	UpdatedAt          time.Time                     `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for attestations.
+func (Attestation) TableName() string {
+	return "attestations"
+}
+
+// IsExpired reports whether a signed attestation is past its expiry, or a pending
+// request went unanswered past its deadline.
+func (a *Attestation) IsExpired(now time.Time) bool {
+	switch a.Status {
+	case attestation.StatusPendingAttestation:
+		return now.After(a.RequestExpiresAt)
+	case attestation.StatusActiveAttestation:
+		return a.ExpiresAt != nil && now.After(*a.ExpiresAt)
+	}
+	return false
+}
+
+// VerificationResult reports whether an attestation still vouches for its event.
+type VerificationResult struct {
+	Valid          bool                          `json:"valid"`
+	AttestationID  string                        `json:"attestationId"`
+	Status         attestation.AttestationStatus `json:"status"`
+	SignatureValid bool                          `json:"signatureValid"`
+	EventUnchanged bool                          `json:"eventUnchanged"` // Event still hashes to the signed value
+	Errors         []string                      `json:"errors,omitempty"`
+	CheckedAt      time.Time                     `json:"checkedAt"`
+}
@@ -0,0 +1,247 @@
+package attestation
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/attestation"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Handler handles HTTP requests for provider attestations.
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new attestation handler.
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RegisterRoutes registers the attestation endpoints.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	attGroup := rg.Group("/attestations")
+	{
+		attGroup.POST("", h.HandleRequest)
+		attGroup.GET("", h.HandleList)
+		attGroup.GET("/:id", h.HandleGetByID)
+		attGroup.GET("/:id/signing-message", h.HandleSigningMessage)
+		attGroup.POST("/:id/sign", h.HandleSign)
+		attGroup.POST("/:id/revoke", h.HandleRevoke)
+		attGroup.GET("/:id/verify", h.HandleVerify)
+	}
+}
+
+// AttestationRequestDTO is the payload a patient sends to request an attestation.
+type AttestationRequestDTO struct {
+	EventID  string                      `json:"eventId" binding:"required"`
+	Attester string                      `json:"attester" binding:"required"`
+	Type     attestation.AttestationType `json:"type" binding:"required"`
+	Message  string                      `json:"message"`
+}
+
+// SignDTO is the payload a provider sends with their wallet signature.
+type SignDTO struct {
+	Signature string     `json:"signature" binding:"required"`
+	Notes     string     `json:"notes"`
+	ExpiresAt *time.Time `json:"expiresAt"` // Optional: must match the value used for the signing message
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(c *gin.Context, err error, fallback string) {
+	var validationErrs types.ValidationErrors
+	var validationErr types.ValidationError
+	switch {
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "attestation not found"})
+	case errors.As(err, &validationErrs),
+		errors.As(err, &validationErr),
+		errors.Is(err, ErrInvalidEvent),
+		errors.Is(err, ErrSelfAttestation),
+		errors.Is(err, ErrInvalidState),
+		errors.Is(err, ErrInvalidSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// HandleRequest asks a provider to attest one of the caller's events.
+func (h *Handler) HandleRequest(c *gin.Context) {
+	patient, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req AttestationRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	att, err := h.service.RequestAttestation(c.Request.Context(), patient, Request{
+		EventID:  req.EventID,
+		Attester: req.Attester,
+		Type:     req.Type,
+		Message:  req.Message,
+	})
+	if err != nil {
+		writeError(c, err, "failed to request attestation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, att)
+}
+
+// HandleList returns the caller's attestations: as patient by default, or as provider with ?role=attester.
+func (h *Handler) HandleList(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var (
+		atts []Attestation
+		err  error
+	)
+	switch c.DefaultQuery("role", "patient") {
+	case "patient":
+		atts, err = h.service.GetPatientAttestations(c.Request.Context(), address)
+	case "attester":
+		atts, err = h.service.GetAttesterAttestations(c.Request.Context(), address)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be patient or attester"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch attestations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"attestations": atts})
+}
+
+// HandleGetByID returns an attestation to its patient or attester.
+func (h *Handler) HandleGetByID(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	att, err := h.service.GetAttestation(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to fetch attestation")
+		return
+	}
+
+	c.JSON(http.StatusOK, att)
+}
+
+// HandleSigningMessage returns the message the provider signs with their wallet.
+// An optional ?expiresAt=<RFC3339> binds an expiry into the message.
+func (h *Handler) HandleSigningMessage(c *gin.Context) {
+	attester, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if raw := c.Query("expiresAt"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be RFC3339"})
+			return
+		}
+		expiresAt = &t
+	}
+
+	message, err := h.service.GetSigningMessage(c.Request.Context(), attester, c.Param("id"), expiresAt)
+	if err != nil {
+		writeError(c, err, "failed to build signing message")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// HandleSign records the provider's signature once it verifies against the current event.
+func (h *Handler) HandleSign(c *gin.Context) {
+	attester, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req SignDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	att, err := h.service.SignAttestation(c.Request.Context(), attester, c.Param("id"), SignRequest{
+		Signature: req.Signature,
+		Notes:     req.Notes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		writeError(c, err, "failed to sign attestation")
+		return
+	}
+
+	c.JSON(http.StatusOK, att)
+}
+
+// HandleRevoke revokes an attestation (attester) or withdraws a pending request (patient).
+func (h *Handler) HandleRevoke(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.RevokeAttestation(c.Request.Context(), address, c.Param("id")); err != nil {
+		writeError(c, err, "failed to revoke attestation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleVerify re-checks the signature and whether the event still matches what was signed.
+func (h *Handler) HandleVerify(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	result, err := h.service.VerifyAttestation(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to verify attestation")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,73 @@
+package attestation
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for attestation persistence.
+type Repository interface {
+	Create(ctx context.Context, att *Attestation) error
+	GetByID(ctx context.Context, id string) (*Attestation, error)
+	GetByEventID(ctx context.Context, eventID string) ([]Attestation, error)
+	GetByPatient(ctx context.Context, patientID string) ([]Attestation, error)
+	GetByAttester(ctx context.Context, attester string) ([]Attestation, error)
+	Update(ctx context.Context, att *Attestation) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new GORM repository for attestations.
+func NewRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) Create(ctx context.Context, att *Attestation) error {
+	if err := r.db.WithContext(ctx).Create(att).Error; err != nil {
+		return fmt.Errorf("create attestation: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetByID(ctx context.Context, id string) (*Attestation, error) {
+	var att Attestation
+	if err := r.db.WithContext(ctx).First(&att, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get attestation %s: %w", id, err)
+	}
+	return &att, nil
+}
+
+func (r *gormRepository) GetByEventID(ctx context.Context, eventID string) ([]Attestation, error) {
+	var atts []Attestation
+	if err := r.db.WithContext(ctx).Where("event_id = ?", eventID).Order("created_at DESC").Find(&atts).Error; err != nil {
+		return nil, fmt.Errorf("list attestations for event %s: %w", eventID, err)
+	}
+	return atts, nil
+}
+
+func (r *gormRepository) GetByPatient(ctx context.Context, patientID string) ([]Attestation, error) {
+	var atts []Attestation
+	if err := r.db.WithContext(ctx).Where("patient_id = ?", patientID).Order("created_at DESC").Find(&atts).Error; err != nil {
+		return nil, fmt.Errorf("list attestations for patient %s: %w", patientID, err)
+	}
+	return atts, nil
+}
+
+func (r *gormRepository) GetByAttester(ctx context.Context, attester string) ([]Attestation, error) {
+	var atts []Attestation
+	if err := r.db.WithContext(ctx).Where("attester = ?", attester).Order("created_at DESC").Find(&atts).Error; err != nil {
+		return nil, fmt.Errorf("list attestations by attester %s: %w", attester, err)
+	}
+	return atts, nil
+}
+
+func (r *gormRepository) Update(ctx context.Context, att *Attestation) error {
+	if err := r.db.WithContext(ctx).Save(att).Error; err != nil {
+		return fmt.Errorf("update attestation: %w", err)
+	}
+	return nil
+}
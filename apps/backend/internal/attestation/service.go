@@ -0,0 +1,452 @@
+package attestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/attestation"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
+)
+
+// ErrForbidden is wrapped by every error raised when the caller may not act on an attestation.
+var ErrForbidden = errors.New("not allowed to act on this attestation")
+
+var (
+	// ErrNotEventOwner is returned when someone requests an attestation for another patient's event.
+	ErrNotEventOwner = fmt.Errorf("%w: only the event owner may request it", ErrForbidden)
+	// ErrNotAttester is returned when someone other than the requested provider tries to sign.
+	ErrNotAttester = fmt.Errorf("%w: only the requested attester may sign it", ErrForbidden)
+	// ErrNotParty is returned when the caller is neither the patient nor the attester.
+	ErrNotParty = fmt.Errorf("%w: only the patient or attester may access it", ErrForbidden)
+)
+
+var (
+	// ErrInvalidEvent is returned when the event to attest does not exist.
+	ErrInvalidEvent = errors.New("invalid event")
+	// ErrSelfAttestation is returned when a patient names themselves as attester.
+	ErrSelfAttestation = errors.New("a patient cannot attest their own event")
+	// ErrInvalidState is returned when the attestation is not in a state that allows the action.
+	ErrInvalidState = errors.New("attestation is not in a valid state for this action")
+	// ErrInvalidSignature is returned when the signature does not match the current event and attester.
+	ErrInvalidSignature = errors.New("signature does not match the attestation")
+)
+
+// Timeline is the part of the timeline service attestations rely on.
+type Timeline interface {
+	GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error)
+	CreateEvent(ctx context.Context, event *protocoltimeline.Event) error
+	LinkEvents(ctx context.Context, fromID, toID string, relType protocoltimeline.RelationshipType) (*timeline.EventEdge, error)
+	UnlinkEvents(ctx context.Context, edgeID string) error
+}
+
+// Request describes an attestation a patient asks a provider for.
+type Request struct {
+	EventID  string
+	Attester string
+	Type     attestation.AttestationType
+	Message  string
+}
+
+// SignRequest carries the provider's signature over the attestation's signing message.
+type SignRequest struct {
+	Signature string
+	Notes     string
+	ExpiresAt *time.Time
+}
+
+// Service defines the business logic for provider attestations.
+type Service interface {
+	RequestAttestation(ctx context.Context, patient string, req Request) (*Attestation, error)
+	// GetSigningMessage returns the message the attester must sign, bound to the event's current hash.
+	GetSigningMessage(ctx context.Context, attester, id string, expiresAt *time.Time) (string, error)
+	SignAttestation(ctx context.Context, attester, id string, req SignRequest) (*Attestation, error)
+	RevokeAttestation(ctx context.Context, actor, id string) error
+	GetAttestation(ctx context.Context, actor, id string) (*Attestation, error)
+	GetPatientAttestations(ctx context.Context, patient string) ([]Attestation, error)
+	GetAttesterAttestations(ctx context.Context, attester string) ([]Attestation, error)
+	VerifyAttestation(ctx context.Context, actor, id string) (*VerificationResult, error)
+}
+
+type service struct {
+	repo         Repository
+	auditService audit.Service
+	timeline     Timeline
+}
+
+// NewService creates a new attestation service.
+func NewService(repo Repository, auditService audit.Service, timeline Timeline) Service {
+	return &service{
+		repo:         repo,
+		auditService: auditService,
+		timeline:     timeline,
+	}
+}
+
+func (s *service) RequestAttestation(ctx context.Context, patient string, req Request) (*Attestation, error) {
+	requester, err := types.NewWalletAddress(patient)
+	if err != nil {
+		return nil, err
+	}
+	eventID, err := types.NewID(req.EventID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
+	}
+	attester, err := types.NewWalletAddress(req.Attester)
+	if err != nil {
+		return nil, types.NewValidationError("attester", "attester address is required")
+	}
+	if attester.Equals(requester) {
+		return nil, ErrSelfAttestation
+	}
+
+	protocolReq, err := attestation.NewAttestationRequestBuilder().
+		WithEventID(eventID).
+		WithRequester(requester).
+		WithTargetAttester(attester).
+		WithRequestedType(req.Type).
+		WithMessage(req.Message).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	event, err := s.timeline.GetEvent(ctx, req.EventID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidEvent, req.EventID)
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(event.PatientID, patient) {
+		return nil, ErrNotEventOwner
+	}
+
+	att := &Attestation{
+		EventID:          req.EventID,
+		PatientID:        requester.String(),
+		Attester:         attester.String(),
+		Type:             protocolReq.RequestedType,
+		Status:           attestation.StatusPendingAttestation,
+		Message:          protocolReq.Message,
+		RequestExpiresAt: protocolReq.ExpiresAt,
+		SchemaVersion:    attestation.SchemaVersionAttestation,
+	}
+	if err := s.repo.Create(ctx, att); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, att.PatientID, protocol.ActionAttestationRequest, protocol.ResourceAttestation, att.ID, common.JSONMap{
+		"eventId":  att.EventID,
+		"attester": att.Attester,
+		"type":     att.Type,
+	})
+
+	return att, nil
+}
+
+func (s *service) GetSigningMessage(ctx context.Context, attester, id string, expiresAt *time.Time) (string, error) {
+	att, err := s.pendingForAttester(ctx, attester, id)
+	if err != nil {
+		return "", err
+	}
+	hash, err := s.currentEventHash(ctx, att.EventID)
+	if err != nil {
+		return "", err
+	}
+	return toProtocolAttestation(att, hash, expiresAt).SigningMessage(), nil
+}
+
+func (s *service) SignAttestation(ctx context.Context, attester, id string, req SignRequest) (*Attestation, error) {
+	att, err := s.pendingForAttester(ctx, attester, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, types.NewValidationError("expiresAt", "expiry must be in the future")
+	}
+
+	// The hash is recomputed rather than taken from the caller: if the event changed
+	// after the provider fetched the signing message, the signature will not verify.
+	hash, err := s.currentEventHash(ctx, att.EventID)
+	if err != nil {
+		return nil, err
+	}
+	signed := toProtocolAttestation(att, hash, req.ExpiresAt)
+	signed.Signature = req.Signature
+	signed.SignatureAlgorithm = attestation.SignatureAlgorithmEIP191
+	if !signed.VerifySignature() {
+		return nil, ErrInvalidSignature
+	}
+
+	noteID, edgeID, err := s.linkToEvent(ctx, att, req.Notes, now)
+	if err != nil {
+		return nil, err
+	}
+
+	att.Status = attestation.StatusActiveAttestation
+	att.EventHash = hash
+	att.Signature = req.Signature
+	att.SignatureAlgorithm = attestation.SignatureAlgorithmEIP191
+	att.Notes = req.Notes
+	att.SignedAt = &now
+	att.ExpiresAt = signed.ExpiresAt
+	att.AttestationEventID = noteID
+	att.EdgeID = edgeID
+	if err := s.repo.Update(ctx, att); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, att.Attester, protocol.ActionAttest, protocol.ResourceAttestation, att.ID, common.JSONMap{
+		"eventId":   att.EventID,
+		"eventHash": att.EventHash,
+		"patient":   att.PatientID,
+		"type":      att.Type,
+	})
+
+	return att, nil
+}
+
+// linkToEvent records the attestation on the patient's timeline as a provider note
+// and connects the attested event to it with an attested_by edge.
+func (s *service) linkToEvent(ctx context.Context, att *Attestation, notes string, signedAt time.Time) (noteID, edgeID string, err error) {
+	patient, _ := types.NewWalletAddress(att.PatientID)
+	note, err := protocoltimeline.NewEventBuilder().
+		WithPatientID(patient).
+		WithType(protocoltimeline.EventNote).
+		WithTitle(fmt.Sprintf("Provider attestation (%s)", att.Type)).
+		WithDescription(notes).
+		WithProvider(att.Attester).
+		WithTimestamp(signedAt).
+		SetMetadata("attestationId", att.ID).
+		SetMetadata("attestedEventId", att.EventID).
+		Build()
+	if err != nil {
+		return "", "", fmt.Errorf("build attestation note: %w", err)
+	}
+	if err := s.timeline.CreateEvent(ctx, note); err != nil {
+		return "", "", err
+	}
+
+	edge, err := s.timeline.LinkEvents(ctx, att.EventID, note.ID.String(), protocoltimeline.RelAttestedBy)
+	if err != nil {
+		return "", "", err
+	}
+	return note.ID.String(), edge.ID, nil
+}
+
+func (s *service) RevokeAttestation(ctx context.Context, actor, id string) error {
+	att, err := s.load(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var role string
+	switch {
+	case strings.EqualFold(actor, att.Attester):
+		role = "attester"
+	case strings.EqualFold(actor, att.PatientID):
+		// A patient can withdraw a request, but not a provider's signed statement.
+		if att.Status != attestation.StatusPendingAttestation {
+			return fmt.Errorf("%w: only the attester may revoke a signed attestation", ErrForbidden)
+		}
+		role = "patient"
+	default:
+		return ErrNotParty
+	}
+
+	if att.Status != attestation.StatusPendingAttestation && att.Status != attestation.StatusActiveAttestation {
+		return fmt.Errorf("%w: %s", ErrInvalidState, att.Status)
+	}
+
+	if err := s.unlink(ctx, att); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	att.Status = attestation.StatusRevokedAttestation
+	att.RevokedAt = &now
+	if err := s.repo.Update(ctx, att); err != nil {
+		return err
+	}
+
+	_ = s.auditService.Record(ctx, actor, protocol.ActionAttestationRevoke, protocol.ResourceAttestation, att.ID, common.JSONMap{
+		"role":    role,
+		"eventId": att.EventID,
+	})
+
+	return nil
+}
+
+func (s *service) GetAttestation(ctx context.Context, actor, id string) (*Attestation, error) {
+	att, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(actor, att.PatientID) && !strings.EqualFold(actor, att.Attester) {
+		return nil, ErrNotParty
+	}
+	return att, nil
+}
+
+func (s *service) GetPatientAttestations(ctx context.Context, patient string) ([]Attestation, error) {
+	atts, err := s.repo.GetByPatient(ctx, strings.ToLower(patient))
+	if err != nil {
+		return nil, err
+	}
+	return s.expireAll(ctx, atts), nil
+}
+
+func (s *service) GetAttesterAttestations(ctx context.Context, attester string) ([]Attestation, error) {
+	atts, err := s.repo.GetByAttester(ctx, strings.ToLower(attester))
+	if err != nil {
+		return nil, err
+	}
+	return s.expireAll(ctx, atts), nil
+}
+
+func (s *service) VerifyAttestation(ctx context.Context, actor, id string) (*VerificationResult, error) {
+	att, err := s.GetAttestation(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VerificationResult{
+		AttestationID: att.ID,
+		Status:        att.Status,
+		CheckedAt:     time.Now().UTC(),
+	}
+	if att.Status != attestation.StatusActiveAttestation {
+		result.Errors = append(result.Errors, "attestation is "+string(att.Status))
+	}
+
+	if att.Signature != "" {
+		signed := toProtocolAttestation(att, att.EventHash, att.ExpiresAt)
+		signed.Signature = att.Signature
+		signed.SignatureAlgorithm = att.SignatureAlgorithm
+		result.SignatureValid = signed.VerifySignature()
+		if !result.SignatureValid {
+			result.Errors = append(result.Errors, "signature does not match attester")
+		}
+
+		hash, err := s.currentEventHash(ctx, att.EventID)
+		switch {
+		case errors.Is(err, ErrInvalidEvent):
+			result.Errors = append(result.Errors, "attested event no longer exists")
+		case err != nil:
+			return nil, err
+		default:
+			result.EventUnchanged = hash == att.EventHash
+			if !result.EventUnchanged {
+				result.Errors = append(result.Errors, "event has changed since it was attested")
+			}
+		}
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// load fetches an attestation and applies any expiry that has come due.
+func (s *service) load(ctx context.Context, id string) (*Attestation, error) {
+	att, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expireIfDue(ctx, att); err != nil {
+		return nil, err
+	}
+	return att, nil
+}
+
+func (s *service) pendingForAttester(ctx context.Context, attester, id string) (*Attestation, error) {
+	att, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(attester, att.Attester) {
+		return nil, ErrNotAttester
+	}
+	if att.Status != attestation.StatusPendingAttestation {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidState, att.Status)
+	}
+	return att, nil
+}
+
+// expireIfDue marks a lapsed attestation or request as expired and removes its edge,
+// so the graph only shows attestations that still vouch for their event.
+func (s *service) expireIfDue(ctx context.Context, att *Attestation) error {
+	if !att.IsExpired(time.Now()) {
+		return nil
+	}
+	if err := s.unlink(ctx, att); err != nil {
+		return err
+	}
+	att.Status = attestation.StatusExpiredAttestation
+	if err := s.repo.Update(ctx, att); err != nil {
+		return err
+	}
+	_ = s.auditService.Record(ctx, att.Attester, protocol.ActionAttestationExpire, protocol.ResourceAttestation, att.ID, common.JSONMap{
+		"eventId": att.EventID,
+	})
+	return nil
+}
+
+func (s *service) expireAll(ctx context.Context, atts []Attestation) []Attestation {
+	for i := range atts {
+		_ = s.expireIfDue(ctx, &atts[i])
+	}
+	return atts
+}
+
+func (s *service) unlink(ctx context.Context, att *Attestation) error {
+	if att.EdgeID == "" {
+		return nil
+	}
+	if err := s.timeline.UnlinkEvents(ctx, att.EdgeID); err != nil {
+		return err
+	}
+	att.EdgeID = ""
+	return nil
+}
+
+func (s *service) currentEventHash(ctx context.Context, eventID string) (string, error) {
+	event, err := s.timeline.GetEvent(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", fmt.Errorf("%w: %s", ErrInvalidEvent, eventID)
+		}
+		return "", err
+	}
+	return eventHash(event)
+}
+
+// eventHash is the protocol's canonical event hash (timeline.Event.Hash), so patients
+// and third parties can recompute what the provider signed from the event alone.
+func eventHash(event *timeline.TimelineEvent) (string, error) {
+	protocolEvent, err := timeline.ToProtocolEvent(event)
+	if err != nil {
+		return "", fmt.Errorf("convert event %s: %w", event.ID, err)
+	}
+	return protocolEvent.Hash()
+}
+
+func toProtocolAttestation(att *Attestation, eventHash string, expiresAt *time.Time) *attestation.Attestation {
+	return &attestation.Attestation{
+		ID:        types.ID(att.ID),
+		EventID:   types.ID(att.EventID),
+		EventHash: eventHash,
+		Attester:  types.WalletAddress(att.Attester),
+		Type:      att.Type,
+		Status:    att.Status,
+		ExpiresAt: expiresAt,
+	}
+}
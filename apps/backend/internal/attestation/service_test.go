@@ -0,0 +1,367 @@
+package attestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/attestation"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolcrypto "github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
+)
+
+type mockRepo struct {
+	nextID int
+	atts   map[string]Attestation
+}
+
+func (m *mockRepo) Create(ctx context.Context, att *Attestation) error {
+	m.nextID++
+	att.ID = fmt.Sprintf("att-%d", m.nextID)
+	m.atts[att.ID] = *att
+	return nil
+}
+
+func (m *mockRepo) GetByID(ctx context.Context, id string) (*Attestation, error) {
+	att, ok := m.atts[id]
+	if !ok {
+		return nil, fmt.Errorf("get attestation %s: %w", id, gorm.ErrRecordNotFound)
+	}
+	return &att, nil
+}
+
+func (m *mockRepo) GetByEventID(ctx context.Context, eventID string) ([]Attestation, error) {
+	return m.filter(func(a Attestation) bool { return a.EventID == eventID }), nil
+}
+
+func (m *mockRepo) GetByPatient(ctx context.Context, patientID string) ([]Attestation, error) {
+	return m.filter(func(a Attestation) bool { return a.PatientID == patientID }), nil
+}
+
+func (m *mockRepo) GetByAttester(ctx context.Context, attester string) ([]Attestation, error) {
+	return m.filter(func(a Attestation) bool { return a.Attester == attester }), nil
+}
+
+func (m *mockRepo) Update(ctx context.Context, att *Attestation) error {
+	m.atts[att.ID] = *att
+	return nil
+}
+
+func (m *mockRepo) filter(keep func(Attestation) bool) []Attestation {
+	var out []Attestation
+	for _, a := range m.atts {
+		if keep(a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+type mockTimeline struct {
+	nextID int
+	events map[string]*timeline.TimelineEvent
+	notes  []*protocoltimeline.Event
+	edges  map[string]timeline.EventEdge
+}
+
+func (m *mockTimeline) GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error) {
+	event, ok := m.events[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *event
+	return &copied, nil
+}
+
+func (m *mockTimeline) CreateEvent(ctx context.Context, event *protocoltimeline.Event) error {
+	m.nextID++
+	event.ID = types.ID(fmt.Sprintf("note-%d", m.nextID))
+	m.notes = append(m.notes, event)
+	return nil
+}
+
+func (m *mockTimeline) LinkEvents(ctx context.Context, fromID, toID string, relType protocoltimeline.RelationshipType) (*timeline.EventEdge, error) {
+	m.nextID++
+	edge := timeline.EventEdge{ID: fmt.Sprintf("edge-%d", m.nextID), FromEventID: fromID, ToEventID: toID, RelationshipType: relType}
+	m.edges[edge.ID] = edge
+	return &edge, nil
+}
+
+func (m *mockTimeline) UnlinkEvents(ctx context.Context, edgeID string) error {
+	delete(m.edges, edgeID)
+	return nil
+}
+
+const patient = "0x1111111111111111111111111111111111111111"
+
+type fixture struct {
+	svc      Service
+	repo     *mockRepo
+	timeline *mockTimeline
+	audit    *audittest.Service
+	signer   func(message string) string
+	attester string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	repo := &mockRepo{atts: make(map[string]Attestation)}
+	tl := &mockTimeline{
+		events: map[string]*timeline.TimelineEvent{
+			"event-1": {
+				ID:        "event-1",
+				PatientID: patient,
+				Type:      protocoltimeline.EventLabResult,
+				Title:     "CBC",
+				Timestamp: time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC),
+				Metadata:  common.JSONMap{"hemoglobin": 14.2},
+			},
+		},
+		edges: make(map[string]timeline.EventEdge),
+	}
+	auditSvc := &audittest.Service{}
+
+	return &fixture{
+		svc:      NewService(repo, auditSvc, tl),
+		repo:     repo,
+		timeline: tl,
+		audit:    auditSvc,
+		attester: strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).Hex()),
+		signer: func(message string) string {
+			sig, err := protocolcrypto.SignMessage(message, key)
+			if err != nil {
+				t.Fatalf("SignMessage() error = %v", err)
+			}
+			return sig
+		},
+	}
+}
+
+func (f *fixture) request(t *testing.T) *Attestation {
+	t.Helper()
+	att, err := f.svc.RequestAttestation(context.Background(), patient, Request{
+		EventID:  "event-1",
+		Attester: f.attester,
+		Type:     attestation.AttestVerified,
+	})
+	if err != nil {
+		t.Fatalf("RequestAttestation() error = %v", err)
+	}
+	return att
+}
+
+func (f *fixture) sign(t *testing.T, id string, expiresAt *time.Time) *Attestation {
+	t.Helper()
+	ctx := context.Background()
+	message, err := f.svc.GetSigningMessage(ctx, f.attester, id, expiresAt)
+	if err != nil {
+		t.Fatalf("GetSigningMessage() error = %v", err)
+	}
+	att, err := f.svc.SignAttestation(ctx, f.attester, id, SignRequest{Signature: f.signer(message), ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("SignAttestation() error = %v", err)
+	}
+	return att
+}
+
+func TestService_RequestAttestation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.svc.RequestAttestation(ctx, "0x2222222222222222222222222222222222222222", Request{EventID: "event-1", Attester: f.attester, Type: attestation.AttestVerified}); !errors.Is(err, ErrNotEventOwner) {
+		t.Errorf("RequestAttestation() by non-owner error = %v, want %v", err, ErrNotEventOwner)
+	}
+	if _, err := f.svc.RequestAttestation(ctx, patient, Request{EventID: "event-1", Attester: patient, Type: attestation.AttestVerified}); !errors.Is(err, ErrSelfAttestation) {
+		t.Errorf("RequestAttestation() to self error = %v, want %v", err, ErrSelfAttestation)
+	}
+	if _, err := f.svc.RequestAttestation(ctx, patient, Request{EventID: "missing", Attester: f.attester, Type: attestation.AttestVerified}); !errors.Is(err, ErrInvalidEvent) {
+		t.Errorf("RequestAttestation() for missing event error = %v, want %v", err, ErrInvalidEvent)
+	}
+
+	att := f.request(t)
+	if att.Status != attestation.StatusPendingAttestation || !att.RequestExpiresAt.After(time.Now()) {
+		t.Errorf("RequestAttestation() = status %s, request expiry %v", att.Status, att.RequestExpiresAt)
+	}
+}
+
+func TestService_SignAttestation_LinksEvent(t *testing.T) {
+	f := newFixture(t)
+	att := f.sign(t, f.request(t).ID, nil)
+
+	if att.Status != attestation.StatusActiveAttestation || att.EventHash == "" || att.SignedAt == nil {
+		t.Fatalf("SignAttestation() = %+v", att)
+	}
+	protocolEvent, _ := timeline.ToProtocolEvent(f.timeline.events["event-1"])
+	if want, _ := protocolEvent.Hash(); att.EventHash != want {
+		t.Errorf("EventHash = %s, want canonical hash %s", att.EventHash, want)
+	}
+	edge, ok := f.timeline.edges[att.EdgeID]
+	if !ok {
+		t.Fatal("SignAttestation() did not create an edge")
+	}
+	if edge.FromEventID != "event-1" || edge.ToEventID != att.AttestationEventID || edge.RelationshipType != protocoltimeline.RelAttestedBy {
+		t.Errorf("edge = %+v, want event-1 -attested_by-> %s", edge, att.AttestationEventID)
+	}
+	if note := f.timeline.notes[0]; note.PatientID.String() != patient || note.Provider != f.attester {
+		t.Errorf("attestation note = %+v", note)
+	}
+
+	want := []protocol.Action{protocol.ActionAttestationRequest, protocol.ActionAttest}
+	if fmt.Sprint(f.audit.Actions()) != fmt.Sprint(want) {
+		t.Errorf("audit actions = %v, want %v", f.audit.Actions(), want)
+	}
+
+	result, err := f.svc.VerifyAttestation(context.Background(), patient, att.ID)
+	if err != nil {
+		t.Fatalf("VerifyAttestation() error = %v", err)
+	}
+	if !result.Valid || !result.SignatureValid || !result.EventUnchanged {
+		t.Errorf("VerifyAttestation() = %+v, want valid", result)
+	}
+}
+
+func TestService_SignAttestation_Rejects(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("other provider", func(t *testing.T) {
+		f := newFixture(t)
+		att := f.request(t)
+		if _, err := f.svc.SignAttestation(ctx, "0x3333333333333333333333333333333333333333", att.ID, SignRequest{Signature: "0x00"}); !errors.Is(err, ErrNotAttester) {
+			t.Errorf("SignAttestation() error = %v, want %v", err, ErrNotAttester)
+		}
+	})
+
+	t.Run("event edited after signing message", func(t *testing.T) {
+		f := newFixture(t)
+		att := f.request(t)
+		message, _ := f.svc.GetSigningMessage(ctx, f.attester, att.ID, nil)
+		f.timeline.events["event-1"].Metadata = common.JSONMap{"hemoglobin": 9.1}
+
+		if _, err := f.svc.SignAttestation(ctx, f.attester, att.ID, SignRequest{Signature: f.signer(message)}); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("SignAttestation() error = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("expiry not covered by signature", func(t *testing.T) {
+		f := newFixture(t)
+		att := f.request(t)
+		message, _ := f.svc.GetSigningMessage(ctx, f.attester, att.ID, nil)
+		later := time.Now().Add(time.Hour)
+
+		if _, err := f.svc.SignAttestation(ctx, f.attester, att.ID, SignRequest{Signature: f.signer(message), ExpiresAt: &later}); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("SignAttestation() error = %v, want %v", err, ErrInvalidSignature)
+		}
+	})
+
+	t.Run("already signed", func(t *testing.T) {
+		f := newFixture(t)
+		att := f.sign(t, f.request(t).ID, nil)
+		if _, err := f.svc.GetSigningMessage(ctx, f.attester, att.ID, nil); !errors.Is(err, ErrInvalidState) {
+			t.Errorf("GetSigningMessage() error = %v, want %v", err, ErrInvalidState)
+		}
+	})
+}
+
+func TestService_VerifyAttestation_DetectsEditedEvent(t *testing.T) {
+	f := newFixture(t)
+	att := f.sign(t, f.request(t).ID, nil)
+
+	f.timeline.events["event-1"].Title = "CBC (edited)"
+
+	result, err := f.svc.VerifyAttestation(context.Background(), f.attester, att.ID)
+	if err != nil {
+		t.Fatalf("VerifyAttestation() error = %v", err)
+	}
+	if result.Valid || !result.SignatureValid || result.EventUnchanged {
+		t.Errorf("VerifyAttestation() = %+v, want signature valid but event changed", result)
+	}
+}
+
+func TestService_RevokeAttestation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	att := f.sign(t, f.request(t).ID, nil)
+
+	if err := f.svc.RevokeAttestation(ctx, patient, att.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("RevokeAttestation() by patient error = %v, want %v", err, ErrForbidden)
+	}
+	if err := f.svc.RevokeAttestation(ctx, "0x3333333333333333333333333333333333333333", att.ID); !errors.Is(err, ErrNotParty) {
+		t.Errorf("RevokeAttestation() by outsider error = %v, want %v", err, ErrNotParty)
+	}
+	if err := f.svc.RevokeAttestation(ctx, f.attester, att.ID); err != nil {
+		t.Fatalf("RevokeAttestation() error = %v", err)
+	}
+
+	stored := f.repo.atts[att.ID]
+	if stored.Status != attestation.StatusRevokedAttestation || stored.RevokedAt == nil {
+		t.Errorf("revoked attestation = %+v", stored)
+	}
+	if len(f.timeline.edges) != 0 {
+		t.Errorf("RevokeAttestation() left %d attested_by edges", len(f.timeline.edges))
+	}
+	if err := f.svc.RevokeAttestation(ctx, f.attester, att.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second RevokeAttestation() error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestService_WithdrawPendingRequest(t *testing.T) {
+	f := newFixture(t)
+	att := f.request(t)
+
+	if err := f.svc.RevokeAttestation(context.Background(), patient, att.ID); err != nil {
+		t.Fatalf("RevokeAttestation() by patient error = %v", err)
+	}
+	if got := f.repo.atts[att.ID].Status; got != attestation.StatusRevokedAttestation {
+		t.Errorf("status = %s, want %s", got, attestation.StatusRevokedAttestation)
+	}
+}
+
+func TestService_ExpiresLazily(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	expiresAt := time.Now().Add(time.Hour)
+	att := f.sign(t, f.request(t).ID, &expiresAt)
+
+	lapsed := f.repo.atts[att.ID]
+	past := time.Now().Add(-time.Minute)
+	lapsed.ExpiresAt = &past
+	f.repo.atts[att.ID] = lapsed
+
+	got, err := f.svc.GetAttestation(ctx, patient, att.ID)
+	if err != nil {
+		t.Fatalf("GetAttestation() error = %v", err)
+	}
+	if got.Status != attestation.StatusExpiredAttestation {
+		t.Errorf("status = %s, want %s", got.Status, attestation.StatusExpiredAttestation)
+	}
+	if len(f.timeline.edges) != 0 {
+		t.Error("expired attestation should no longer be linked to its event")
+	}
+	if last := f.audit.Last().Action; last != protocol.ActionAttestationExpire {
+		t.Errorf("last audit action = %s, want %s", last, protocol.ActionAttestationExpire)
+	}
+
+	// An unanswered request lapses too.
+	pending := f.request(t)
+	stale := f.repo.atts[pending.ID]
+	stale.RequestExpiresAt = past
+	f.repo.atts[pending.ID] = stale
+	if _, err := f.svc.GetSigningMessage(ctx, f.attester, pending.ID, nil); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("GetSigningMessage() on lapsed request error = %v, want %v", err, ErrInvalidState)
+	}
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Metadata keys that tie an entry to the patient whose data it concerns.
+const (
+	MetadataSubject  = "subject"  // Patient address; copied to AuditEntry.Subject
+	MetadataGrantID  = "grantId"  // Consent grant that authorized a non-owner
+	MetadataGrantIDs = "grantIds" // Every grant behind a read that several grants authorized
+
+	// MetadataEmergencySessionID names the break-glass session a read was made under.
+	MetadataEmergencySessionID = "emergencySessionId"
+)
+
+// Metadata keys recorded with refused access attempts.
+const (
+	MetadataDenyReason = "reason"  // One of the DenyReason values
+	MetadataEventID    = "eventId" // Event that was requested, when there was one
+)
+
+// DenyReason explains why consent checks refused an access attempt.
+type DenyReason string
+
+const (
+	// DenyNoConsent means the party held no active grant with the needed permission.
+	DenyNoConsent DenyReason = "no_consent"
+	// DenyOutOfScope means the party's grant does not cover the requested event.
+	DenyOutOfScope DenyReason = "out_of_scope"
+	// DenyPurposeMismatch means the party's grants do not permit the purpose, study or
+	// secondary use it declared.
+	DenyPurposeMismatch DenyReason = "purpose_mismatch"
+	// DenyNoDelegation means the party asked to act as a patient without an active
+	// delegation carrying the needed permission.
+	DenyNoDelegation DenyReason = "no_delegation"
+)
+
+// defaultAccessReportLimit caps how many entries an access report reads.
+const defaultAccessReportLimit = 1000
+
+// AccessReportOptions narrows an access report.
+type AccessReportOptions struct {
+	From  time.Time // Inclusive; zero means no lower bound
+	To    time.Time // Inclusive; zero means no upper bound
+	Limit int       // Newest entries read; defaults to defaultAccessReportLimit
+}
+
+// GrantAccess is the access one party made under one consent grant. GrantID is empty
+// when no grant was recorded, for example for reads by parties the patient shared a
+// file key with directly.
+type GrantAccess struct {
+	GrantID     string       `json:"grantId"`
+	Count       int          `json:"count"`
+	FirstAccess time.Time    `json:"firstAccess"`
+	LastAccess  time.Time    `json:"lastAccess"`
+	Entries     []AuditEntry `json:"entries"` // Newest first
+}
+
+// PartyAccess is all access by one party to the subject's data.
+type PartyAccess struct {
+	Actor       string        `json:"actor"`
+	Count       int           `json:"count"`
+	FirstAccess time.Time     `json:"firstAccess"`
+	LastAccess  time.Time     `json:"lastAccess"`
+	Grants      []GrantAccess `json:"grants"` // Most recently used first
+}
+
+// AccessReport lists who accessed a patient's data, grouped by party and consent grant.
+type AccessReport struct {
+	Subject   string        `json:"subject"`
+	Total     int           `json:"total"`
+	Parties   []PartyAccess `json:"parties"`   // Most recent access first
+	Truncated bool          `json:"truncated"` // Older entries beyond the limit were not read
+}
+
+// GetAccessReport groups every recorded read of subject's data by someone other than
+// the subject, by party and then by consent grant. A read several grants authorized
+// counts once for the party and once under each of those grants.
+func (s *service) GetAccessReport(ctx context.Context, subject string, opts AccessReportOptions) (*AccessReport, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultAccessReportLimit
+	}
+	entries, err := s.repo.ListAccess(ctx, subject, opts.From, opts.To, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("get access report: %w", err)
+	}
+
+	report := &AccessReport{Subject: strings.ToLower(subject), Parties: []PartyAccess{}}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		report.Truncated = true
+	}
+	report.Total = len(entries)
+
+	// Entries arrive newest first, so the first entry seen for a party or grant is its
+	// latest access and groups are created in order of most recent access.
+	parties := make(map[string]int)
+	grants := make(map[string]map[string]int)
+	for _, entry := range entries {
+		actor := strings.ToLower(entry.Actor)
+
+		pi, ok := parties[actor]
+		if !ok {
+			pi = len(report.Parties)
+			parties[actor] = pi
+			grants[actor] = make(map[string]int)
+			report.Parties = append(report.Parties, PartyAccess{Actor: actor, LastAccess: entry.Timestamp, Grants: []GrantAccess{}})
+		}
+		party := &report.Parties[pi]
+		party.Count++
+		party.FirstAccess = entry.Timestamp
+
+		grantIDs := entry.GrantIDs()
+		if len(grantIDs) == 0 {
+			grantIDs = []string{""}
+		}
+		for _, grantID := range grantIDs {
+			gi, ok := grants[actor][grantID]
+			if !ok {
+				gi = len(party.Grants)
+				grants[actor][grantID] = gi
+				party.Grants = append(party.Grants, GrantAccess{GrantID: grantID, LastAccess: entry.Timestamp})
+			}
+			grant := &party.Grants[gi]
+			grant.Count++
+			grant.FirstAccess = entry.Timestamp
+			grant.Entries = append(grant.Entries, entry)
+		}
+	}
+	return report, nil
+}
+
+// GrantIDs returns the consent grants an access entry was recorded under: the single
+// grantId, or every item of grantIds. It is empty when no grant was recorded.
+func (e AuditEntry) GrantIDs() []string {
+	if grantID, _ := e.Metadata[MetadataGrantID].(string); grantID != "" {
+		return []string{grantID}
+	}
+	var grantIDs []string
+	switch list := e.Metadata[MetadataGrantIDs].(type) {
+	case []string:
+		grantIDs = list
+	case []any:
+		for _, item := range list {
+			if grantID, ok := item.(string); ok && grantID != "" {
+				grantIDs = append(grantIDs, grantID)
+			}
+		}
+	}
+	return grantIDs
+}
+
+// ListAccessSince returns entries about a patient's data recorded by another party,
+// refused attempts included, in chain order after the cursor and at or after start.
+// It is the feed access monitoring reads the audit stream from.
+func (s *service) ListAccessSince(ctx context.Context, after *ChainCursor, start time.Time, limit int) ([]AuditEntry, error) {
+	entries, err := s.repo.ListAccessAfter(ctx, after, start, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list access since cursor: %w", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+)
+
+func TestService_GetAccessReport(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	patient := "0x00000000000000000000000000000000000000AA"
+	doctor := "0x00000000000000000000000000000000000000d1"
+	researcher := "0x00000000000000000000000000000000000000d2"
+
+	record := func(actor string, subject string, grantID string) {
+		t.Helper()
+		metadata := common.JSONMap{MetadataSubject: subject}
+		if grantID != "" {
+			metadata[MetadataGrantID] = grantID
+		}
+		if err := service.Record(ctx, actor, protocol.ActionRead, protocol.ResourceEvent, "event-1", metadata); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	record(doctor, patient, "grant-1")
+	record(doctor, patient, "grant-2")
+	record(patient, patient, "")          // The patient's own read
+	record(doctor, researcher, "grant-3") // Someone else's data
+	record(researcher, patient, "grant-4")
+	record(doctor, patient, "grant-1")
+
+	if got := repo.entries[0].Subject; got != strings.ToLower(patient) {
+		t.Fatalf("Record() subject = %q, want %q", got, strings.ToLower(patient))
+	}
+
+	report, err := service.GetAccessReport(ctx, patient, AccessReportOptions{})
+	if err != nil {
+		t.Fatalf("GetAccessReport() error = %v", err)
+	}
+	if report.Total != 4 || report.Truncated || len(report.Parties) != 2 {
+		t.Fatalf("GetAccessReport() = %+v, want 4 reads by 2 parties", report)
+	}
+
+	// The doctor read most recently, so comes first, with grant-1 before grant-2.
+	first := report.Parties[0]
+	if first.Actor != strings.ToLower(doctor) || first.Count != 3 || len(first.Grants) != 2 {
+		t.Fatalf("first party = %+v", first)
+	}
+	if first.Grants[0].GrantID != "grant-1" || first.Grants[0].Count != 2 || first.Grants[1].GrantID != "grant-2" {
+		t.Errorf("doctor grants = %+v", first.Grants)
+	}
+	if !first.FirstAccess.Equal(repo.entries[0].Timestamp) || !first.LastAccess.Equal(repo.entries[5].Timestamp) {
+		t.Errorf("doctor access window = [%v, %v]", first.FirstAccess, first.LastAccess)
+	}
+	if second := report.Parties[1]; second.Actor != strings.ToLower(researcher) || second.Grants[0].GrantID != "grant-4" {
+		t.Errorf("second party = %+v", second)
+	}
+
+	limited, err := service.GetAccessReport(ctx, patient, AccessReportOptions{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetAccessReport() error = %v", err)
+	}
+	if limited.Total != 2 || !limited.Truncated {
+		t.Errorf("limited report = %+v, want 2 entries and truncated", limited)
+	}
+
+	windowed, err := service.GetAccessReport(ctx, patient, AccessReportOptions{From: repo.entries[4].Timestamp, To: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("GetAccessReport() error = %v", err)
+	}
+	if windowed.Total != 2 {
+		t.Errorf("windowed report total = %d, want 2", windowed.Total)
+	}
+}
+
+func TestService_ListAccessSince(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	patient := "0x00000000000000000000000000000000000000aa"
+	doctor := "0x00000000000000000000000000000000000000d1"
+
+	record := func(actor string, action protocol.Action) {
+		t.Helper()
+		if err := service.Record(ctx, actor, action, protocol.ResourceEvent, "event-1", common.JSONMap{MetadataSubject: patient}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	record(doctor, protocol.ActionRead)
+	record(patient, protocol.ActionRead)
+	record(doctor, protocol.ActionAccessDeny)
+	record(doctor, protocol.ActionDownload)
+
+	all, err := service.ListAccessSince(ctx, nil, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("ListAccessSince() error = %v", err)
+	}
+	if len(all) != 3 || all[1].Action != protocol.ActionAccessDeny {
+		t.Fatalf("ListAccessSince() = %d entries, want the doctor's 3 including the refused one", len(all))
+	}
+
+	after, err := service.ListAccessSince(ctx, &ChainCursor{Timestamp: all[1].Timestamp, ID: all[1].ID}, time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("ListAccessSince() error = %v", err)
+	}
+	if len(after) != 1 || after[0].ID != all[2].ID {
+		t.Errorf("ListAccessSince(cursor) = %+v, want only the download", after)
+	}
+
+	// Refused attempts are not part of the access report.
+	report, err := service.GetAccessReport(ctx, patient, AccessReportOptions{})
+	if err != nil {
+		t.Fatalf("GetAccessReport() error = %v", err)
+	}
+	if report.Total != 2 {
+		t.Errorf("GetAccessReport() total = %d, want 2", report.Total)
+	}
+}
+
+func TestService_GetAccessReport_CountsEachGrant(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	patient := "0x00000000000000000000000000000000000000aa"
+	doctor := "0x00000000000000000000000000000000000000d1"
+
+	// One timeline read disclosing events covered by two grants.
+	metadata := common.JSONMap{MetadataSubject: patient, MetadataGrantIDs: []string{"grant-1", "grant-2"}}
+	if err := service.Record(ctx, doctor, protocol.ActionRead, protocol.ResourceEvent, patient, metadata); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := service.Record(ctx, doctor, protocol.ActionRead, protocol.ResourceEvent, "event-1", common.JSONMap{MetadataSubject: patient, MetadataGrantID: "grant-2"}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	report, err := service.GetAccessReport(ctx, patient, AccessReportOptions{})
+	if err != nil {
+		t.Fatalf("GetAccessReport() error = %v", err)
+	}
+	if report.Total != 2 || len(report.Parties) != 1 || report.Parties[0].Count != 2 {
+		t.Fatalf("GetAccessReport() = %+v, want 2 reads by 1 party", report)
+	}
+	grants := report.Parties[0].Grants
+	// grant-2 was used most recently, so comes first.
+	if len(grants) != 2 || grants[0].GrantID != "grant-2" || grants[0].Count != 2 || grants[1].GrantID != "grant-1" || grants[1].Count != 1 {
+		t.Errorf("doctor grants = %+v, want grant-2 twice and grant-1 once", grants)
+	}
+}
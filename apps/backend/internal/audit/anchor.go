@@ -0,0 +1,290 @@
+package audit
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/anchor"
+)
+
+// AnchorStatus tracks a batch root through on-chain anchoring.
+type AnchorStatus string
+
+const (
+	// AnchorPending means the root has not been submitted yet, or is waiting to be retried.
+	AnchorPending AnchorStatus = "pending"
+	// AnchorSubmitted means the anchoring transaction was sent and awaits confirmations.
+	AnchorSubmitted AnchorStatus = "submitted"
+	// AnchorAnchored means the root is on-chain with the required confirmations.
+	AnchorAnchored AnchorStatus = "anchored"
+	// AnchorFailed means anchoring gave up after the maximum number of attempts.
+	AnchorFailed AnchorStatus = "failed"
+)
+
+// AnchorOptions configures the anchoring worker.
+type AnchorOptions struct {
+	Confirmations uint64        // Blocks required before a root counts as anchored
+	MaxAttempts   int           // Submissions before a batch is marked failed
+	RetryBackoff  time.Duration // Delay before the first retry, doubled on each attempt
+	SubmitTimeout time.Duration // How long a submitted transaction may stay unmined before resubmitting
+	BatchLimit    int           // Batches handled per pass
+}
+
+// DefaultAnchorOptions returns the options used when none are configured.
+func DefaultAnchorOptions() AnchorOptions {
+	return AnchorOptions{
+		Confirmations: 3,
+		MaxAttempts:   5,
+		RetryBackoff:  time.Minute,
+		SubmitTimeout: 30 * time.Minute,
+		BatchLimit:    20,
+	}
+}
+
+// AnchorVerification compares a batch's recorded anchoring state with the chain.
+type AnchorVerification struct {
+	BatchID     string       `json:"batchId"`
+	RootHash    string       `json:"rootHash"`
+	Status      AnchorStatus `json:"status"`
+	TxHash      string       `json:"txHash,omitempty"`
+	BlockNumber uint64       `json:"blockNumber,omitempty"`
+	OnChain     bool         `json:"onChain"`
+	AnchoredAt  *time.Time   `json:"anchoredAt,omitempty"` // Block time reported by the contract
+}
+
+// ErrAnchorNotConfigured is returned when no Anchorer is available.
+var ErrAnchorNotConfigured = errors.New("audit anchoring is not configured")
+
+// AnchorService submits batch roots on-chain and tracks them until confirmed.
+type AnchorService interface {
+	ProcessBatches(ctx context.Context) error
+	Start(ctx context.Context, interval time.Duration)
+	VerifyBatch(ctx context.Context, batchID string) (*AnchorVerification, error)
+}
+
+type anchorService struct {
+	repo     Repository
+	anchorer anchor.Anchorer
+	opts     AnchorOptions
+	now      func() time.Time
+}
+
+// NewAnchorService creates an anchoring service. Zero-valued options fall back to
+// DefaultAnchorOptions.
+func NewAnchorService(repo Repository, anchorer anchor.Anchorer, opts AnchorOptions) AnchorService {
+	defaults := DefaultAnchorOptions()
+	if opts.Confirmations == 0 {
+		opts.Confirmations = defaults.Confirmations
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = defaults.MaxAttempts
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaults.RetryBackoff
+	}
+	if opts.SubmitTimeout <= 0 {
+		opts.SubmitTimeout = defaults.SubmitTimeout
+	}
+	if opts.BatchLimit <= 0 {
+		opts.BatchLimit = defaults.BatchLimit
+	}
+	return &anchorService{repo: repo, anchorer: anchorer, opts: opts, now: time.Now}
+}
+
+// Start runs ProcessBatches every interval until ctx is cancelled.
+func (s *anchorService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.ProcessBatches(ctx); err != nil {
+					slog.Warn("audit anchoring pass failed", "error", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// ProcessBatches submits due pending batches and advances submitted ones. Chain errors
+// are recorded on the batch they affect; only storage errors stop the pass.
+func (s *anchorService) ProcessBatches(ctx context.Context) error {
+	batches, err := s.repo.ListBatchesToAnchor(ctx, s.now().UTC(), s.opts.BatchLimit)
+	if err != nil {
+		return fmt.Errorf("list batches to anchor: %w", err)
+	}
+
+	for i := range batches {
+		batch := &batches[i]
+		var err error
+		switch batch.AnchorStatus {
+		case AnchorSubmitted:
+			err = s.checkSubmitted(ctx, batch)
+		default:
+			err = s.submit(ctx, batch)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *anchorService) submit(ctx context.Context, batch *AuditBatch) error {
+	root, err := rootBytes(batch.RootHash)
+	if err != nil {
+		batch.AnchorStatus = AnchorFailed
+		batch.AnchorError = err.Error()
+		return s.repo.UpdateBatchAnchor(ctx, batch)
+	}
+
+	now := s.now().UTC()
+	batch.AnchorAttempts++
+	txHash, err := s.anchorer.Anchor(ctx, root)
+	switch {
+	case errors.Is(err, anchor.ErrAlreadyAnchored):
+		// An earlier attempt landed after we lost track of it.
+		return s.markAnchored(ctx, batch, root)
+	case err != nil:
+		s.scheduleRetry(batch, now, err)
+	default:
+		batch.AnchorStatus = AnchorSubmitted
+		batch.AnchorTxHash = txHash
+		batch.AnchorSubmittedAt = &now
+		batch.AnchorNextAttemptAt = nil
+		batch.AnchorError = ""
+		slog.Info("audit batch root submitted", "batchId", batch.ID, "txHash", txHash, "attempt", batch.AnchorAttempts)
+	}
+	return s.repo.UpdateBatchAnchor(ctx, batch)
+}
+
+func (s *anchorService) checkSubmitted(ctx context.Context, batch *AuditBatch) error {
+	now := s.now().UTC()
+	receipt, err := s.anchorer.Receipt(ctx, batch.AnchorTxHash)
+	if err != nil {
+		// Transient RPC failure; the next pass asks again.
+		slog.Warn("audit anchor receipt lookup failed", "batchId", batch.ID, "txHash", batch.AnchorTxHash, "error", err)
+		return nil
+	}
+
+	switch {
+	case receipt == nil:
+		if batch.AnchorSubmittedAt != nil && now.Sub(*batch.AnchorSubmittedAt) > s.opts.SubmitTimeout {
+			s.scheduleRetry(batch, now, fmt.Errorf("transaction %s not mined after %s", batch.AnchorTxHash, s.opts.SubmitTimeout))
+			return s.repo.UpdateBatchAnchor(ctx, batch)
+		}
+		return nil
+	case receipt.Reverted:
+		root, err := rootBytes(batch.RootHash)
+		if err != nil {
+			return err
+		}
+		// The contract reverts when the root is already stored, e.g. by a concurrent submission.
+		if anchoredAt, err := s.anchorer.Verify(ctx, root); err == nil && !anchoredAt.IsZero() {
+			return s.markAnchored(ctx, batch, root)
+		}
+		s.scheduleRetry(batch, now, fmt.Errorf("transaction %s reverted", batch.AnchorTxHash))
+		return s.repo.UpdateBatchAnchor(ctx, batch)
+	}
+
+	batch.AnchorBlockNumber = receipt.BlockNumber
+	batch.AnchorConfirmations = receipt.Confirmations
+	if receipt.Confirmations < s.opts.Confirmations {
+		return s.repo.UpdateBatchAnchor(ctx, batch)
+	}
+
+	root, err := rootBytes(batch.RootHash)
+	if err != nil {
+		return err
+	}
+	return s.markAnchored(ctx, batch, root)
+}
+
+// markAnchored confirms the root with the contract before recording it as anchored.
+func (s *anchorService) markAnchored(ctx context.Context, batch *AuditBatch, root [32]byte) error {
+	anchoredAt, err := s.anchorer.Verify(ctx, root)
+	if err != nil {
+		slog.Warn("audit anchor verification failed", "batchId", batch.ID, "error", err)
+		return nil
+	}
+	if anchoredAt.IsZero() {
+		s.scheduleRetry(batch, s.now().UTC(), errors.New("contract does not report the root as anchored"))
+		return s.repo.UpdateBatchAnchor(ctx, batch)
+	}
+
+	batch.AnchorStatus = AnchorAnchored
+	batch.AnchoredAt = &anchoredAt
+	batch.AnchorNextAttemptAt = nil
+	batch.AnchorError = ""
+	slog.Info("audit batch root anchored", "batchId", batch.ID, "txHash", batch.AnchorTxHash, "block", batch.AnchorBlockNumber)
+	return s.repo.UpdateBatchAnchor(ctx, batch)
+}
+
+// scheduleRetry puts the batch back to pending with exponential backoff, or marks it
+// failed once the attempts are used up.
+func (s *anchorService) scheduleRetry(batch *AuditBatch, now time.Time, cause error) {
+	batch.AnchorError = cause.Error()
+	batch.AnchorSubmittedAt = nil
+	if batch.AnchorAttempts >= s.opts.MaxAttempts {
+		batch.AnchorStatus = AnchorFailed
+		batch.AnchorNextAttemptAt = nil
+		slog.Error("audit batch anchoring failed", "batchId", batch.ID, "attempts", batch.AnchorAttempts, "error", cause)
+		return
+	}
+
+	backoff := s.opts.RetryBackoff << (batch.AnchorAttempts - 1)
+	next := now.Add(backoff)
+	batch.AnchorStatus = AnchorPending
+	batch.AnchorNextAttemptAt = &next
+	slog.Warn("audit batch anchoring will be retried", "batchId", batch.ID, "attempt", batch.AnchorAttempts, "retryAt", next, "error", cause)
+}
+
+// VerifyBatch asks the contract whether the batch root is anchored.
+func (s *anchorService) VerifyBatch(ctx context.Context, batchID string) (*AnchorVerification, error) {
+	batch, err := s.repo.GetBatchByID(ctx, batchID)
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrBatchNotFound
+	}
+
+	root, err := rootBytes(batch.RootHash)
+	if err != nil {
+		return nil, err
+	}
+	anchoredAt, err := s.anchorer.Verify(ctx, root)
+	if err != nil {
+		return nil, fmt.Errorf("verify anchored root: %w", err)
+	}
+
+	verification := &AnchorVerification{
+		BatchID:     batch.ID,
+		RootHash:    batch.RootHash,
+		Status:      batch.AnchorStatus,
+		TxHash:      batch.AnchorTxHash,
+		BlockNumber: batch.AnchorBlockNumber,
+		OnChain:     !anchoredAt.IsZero(),
+	}
+	if verification.OnChain {
+		verification.AnchoredAt = &anchoredAt
+	}
+	return verification, nil
+}
+
+func rootBytes(rootHash string) ([32]byte, error) {
+	var root [32]byte
+	decoded, err := hex.DecodeString(rootHash)
+	if err != nil || len(decoded) != len(root) {
+		return root, fmt.Errorf("batch root %q is not a 32-byte hex hash", rootHash)
+	}
+	copy(root[:], decoded)
+	return root, nil
+}
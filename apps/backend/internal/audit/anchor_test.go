@@ -0,0 +1,289 @@
+package audit
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/anchor"
+)
+
+// fakeAnchorer mimics the FlemingAnchor contract: a submitted root is stored once its
+// transaction is mined, and the chain head advances on demand.
+type fakeAnchorer struct {
+	head      uint64
+	anchors   map[[32]byte]time.Time
+	txs       map[string]*fakeTx
+	failures  int // Upcoming Anchor calls to fail
+	submitted int
+}
+
+type fakeTx struct {
+	root     [32]byte
+	block    uint64 // Zero while pending
+	reverted bool
+}
+
+func newFakeAnchorer() *fakeAnchorer {
+	return &fakeAnchorer{
+		head:    100,
+		anchors: make(map[[32]byte]time.Time),
+		txs:     make(map[string]*fakeTx),
+	}
+}
+
+func (f *fakeAnchorer) Anchor(ctx context.Context, root [32]byte) (string, error) {
+	if f.failures > 0 {
+		f.failures--
+		return "", errors.New("rpc unavailable")
+	}
+	if _, ok := f.anchors[root]; ok {
+		return "", anchor.ErrAlreadyAnchored
+	}
+	f.submitted++
+	txHash := fmt.Sprintf("0x%064x", f.submitted)
+	f.txs[txHash] = &fakeTx{root: root}
+	return txHash, nil
+}
+
+func (f *fakeAnchorer) Receipt(ctx context.Context, txHash string) (*anchor.Receipt, error) {
+	tx := f.txs[txHash]
+	if tx == nil || tx.block == 0 {
+		return nil, nil
+	}
+	return &anchor.Receipt{
+		TxHash:        txHash,
+		BlockNumber:   tx.block,
+		Confirmations: f.head - tx.block + 1,
+		Reverted:      tx.reverted,
+	}, nil
+}
+
+func (f *fakeAnchorer) Verify(ctx context.Context, root [32]byte) (time.Time, error) {
+	return f.anchors[root], nil
+}
+
+// mine includes every pending transaction in the next block.
+func (f *fakeAnchorer) mine() {
+	f.head++
+	for _, tx := range f.txs {
+		if tx.block != 0 {
+			continue
+		}
+		tx.block = f.head
+		if _, ok := f.anchors[tx.root]; ok {
+			tx.reverted = true
+			continue
+		}
+		f.anchors[tx.root] = time.Unix(int64(1_770_000_000+f.head*12), 0).UTC()
+	}
+}
+
+func newAnchorTestRepo(t *testing.T) (*mockRepo, *AuditBatch) {
+	t.Helper()
+	repo := &mockRepo{
+		entries: []AuditEntry{
+			{ID: "entry-1", Hash: "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", Timestamp: time.Date(2026, 1, 25, 10, 0, 0, 0, time.UTC)},
+			{ID: "entry-2", Hash: "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", Timestamp: time.Date(2026, 1, 25, 11, 0, 0, 0, time.UTC)},
+		},
+	}
+	batch, err := NewService(repo).BuildMerkleTree(context.Background(), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	if batch.AnchorStatus != AnchorPending {
+		t.Fatalf("new batch anchor status = %q, want %q", batch.AnchorStatus, AnchorPending)
+	}
+	return repo, batch
+}
+
+func newTestAnchorService(repo Repository, anchorer anchor.Anchorer, clock *time.Time) AnchorService {
+	service := NewAnchorService(repo, anchorer, AnchorOptions{
+		Confirmations: 3,
+		MaxAttempts:   3,
+		RetryBackoff:  time.Minute,
+		SubmitTimeout: 10 * time.Minute,
+	})
+	service.(*anchorService).now = func() time.Time { return *clock }
+	return service
+}
+
+func TestAnchorService_SubmitsAndConfirms(t *testing.T) {
+	ctx := context.Background()
+	repo, batch := newAnchorTestRepo(t)
+	chain := newFakeAnchorer()
+	clock := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+	service := newTestAnchorService(repo, chain, &clock)
+
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ := repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorSubmitted || got.AnchorTxHash == "" || got.AnchorAttempts != 1 {
+		t.Fatalf("after submit: status=%q tx=%q attempts=%d", got.AnchorStatus, got.AnchorTxHash, got.AnchorAttempts)
+	}
+
+	// Mined but short of the required confirmations.
+	chain.mine()
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ = repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorSubmitted || got.AnchorBlockNumber != 101 || got.AnchorConfirmations != 1 {
+		t.Fatalf("after 1 block: status=%q block=%d confirmations=%d", got.AnchorStatus, got.AnchorBlockNumber, got.AnchorConfirmations)
+	}
+
+	chain.mine()
+	chain.mine()
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ = repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorAnchored || got.AnchorConfirmations != 3 || got.AnchoredAt == nil {
+		t.Fatalf("after 3 blocks: status=%q confirmations=%d anchoredAt=%v", got.AnchorStatus, got.AnchorConfirmations, got.AnchoredAt)
+	}
+	if chain.submitted != 1 {
+		t.Errorf("submitted %d transactions, want 1", chain.submitted)
+	}
+
+	verification, err := service.VerifyBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatalf("VerifyBatch() error = %v", err)
+	}
+	if !verification.OnChain || verification.AnchoredAt == nil || !verification.AnchoredAt.Equal(*got.AnchoredAt) {
+		t.Errorf("VerifyBatch() = %+v", verification)
+	}
+}
+
+func TestAnchorService_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	repo, batch := newAnchorTestRepo(t)
+	chain := newFakeAnchorer()
+	chain.failures = 1
+	clock := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+	service := newTestAnchorService(repo, chain, &clock)
+
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ := repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorPending || got.AnchorError == "" || got.AnchorNextAttemptAt == nil {
+		t.Fatalf("after failure: status=%q error=%q next=%v", got.AnchorStatus, got.AnchorError, got.AnchorNextAttemptAt)
+	}
+	if want := clock.Add(time.Minute); !got.AnchorNextAttemptAt.Equal(want) {
+		t.Errorf("next attempt = %v, want %v", got.AnchorNextAttemptAt, want)
+	}
+
+	// Not due yet.
+	clock = clock.Add(30 * time.Second)
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	if got, _ = repo.GetBatchByID(ctx, batch.ID); got.AnchorAttempts != 1 {
+		t.Fatalf("retried before backoff elapsed: attempts=%d", got.AnchorAttempts)
+	}
+
+	clock = clock.Add(time.Minute)
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ = repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorSubmitted || got.AnchorAttempts != 2 || got.AnchorError != "" {
+		t.Fatalf("after retry: status=%q attempts=%d error=%q", got.AnchorStatus, got.AnchorAttempts, got.AnchorError)
+	}
+}
+
+func TestAnchorService_FailsAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	repo, batch := newAnchorTestRepo(t)
+	chain := newFakeAnchorer()
+	chain.failures = 10
+	clock := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+	service := newTestAnchorService(repo, chain, &clock)
+
+	for i := 0; i < 5; i++ {
+		if err := service.ProcessBatches(ctx); err != nil {
+			t.Fatalf("ProcessBatches() error = %v", err)
+		}
+		clock = clock.Add(time.Hour)
+	}
+
+	got, _ := repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorFailed || got.AnchorAttempts != 3 {
+		t.Fatalf("status=%q attempts=%d, want failed after 3", got.AnchorStatus, got.AnchorAttempts)
+	}
+}
+
+func TestAnchorService_ResubmitsStuckTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo, batch := newAnchorTestRepo(t)
+	chain := newFakeAnchorer()
+	clock := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+	service := newTestAnchorService(repo, chain, &clock)
+
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+
+	// The transaction is never mined; after the timeout the batch is rescheduled.
+	clock = clock.Add(11 * time.Minute)
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ := repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorPending || got.AnchorNextAttemptAt == nil {
+		t.Fatalf("after timeout: status=%q next=%v", got.AnchorStatus, got.AnchorNextAttemptAt)
+	}
+
+	// Meanwhile the first transaction lands; the resubmission sees the root on-chain.
+	chain.mine()
+	clock = clock.Add(time.Hour)
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ = repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorAnchored || got.AnchoredAt == nil {
+		t.Fatalf("after resubmit: status=%q anchoredAt=%v", got.AnchorStatus, got.AnchoredAt)
+	}
+	if chain.submitted != 1 {
+		t.Errorf("submitted %d transactions, want 1", chain.submitted)
+	}
+}
+
+func TestAnchorService_RevertedButAnchored(t *testing.T) {
+	ctx := context.Background()
+	repo, batch := newAnchorTestRepo(t)
+	chain := newFakeAnchorer()
+	clock := time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)
+	service := newTestAnchorService(repo, chain, &clock)
+
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+
+	// Another submitter anchors the same root first, so ours reverts.
+	var root [32]byte
+	decoded, _ := hex.DecodeString(batch.RootHash)
+	copy(root[:], decoded)
+	chain.anchors[root] = time.Unix(1_770_000_000, 0).UTC()
+	chain.mine()
+
+	if err := service.ProcessBatches(ctx); err != nil {
+		t.Fatalf("ProcessBatches() error = %v", err)
+	}
+	got, _ := repo.GetBatchByID(ctx, batch.ID)
+	if got.AnchorStatus != AnchorAnchored {
+		t.Fatalf("status=%q, want %q", got.AnchorStatus, AnchorAnchored)
+	}
+}
+
+func TestAnchorService_VerifyBatchNotFound(t *testing.T) {
+	clock := time.Now()
+	service := newTestAnchorService(&mockRepo{}, newFakeAnchorer(), &clock)
+	if _, err := service.VerifyBatch(context.Background(), "missing"); !errors.Is(err, ErrBatchNotFound) {
+		t.Errorf("VerifyBatch() error = %v, want %v", err, ErrBatchNotFound)
+	}
+}
@@ -0,0 +1,142 @@
+// Package audittest provides an in-memory audit.Service for tests of the packages that
+// record audit entries. Tests that also read entries embed Service and override the
+// read methods they exercise.
+package audittest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+)
+
+// Service keeps every Record call in Recorded. Its read methods return nothing, and its
+// verification methods report success.
+type Service struct {
+	mu       sync.Mutex
+	Recorded []audit.AuditEntry
+}
+
+var _ audit.Service = (*Service)(nil)
+
+func (s *Service) Record(ctx context.Context, actor string, action protocol.Action, resourceType protocol.ResourceType, resourceID string, metadata common.JSONMap) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Recorded = append(s.Recorded, audit.AuditEntry{
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Metadata:     metadata,
+	})
+	return nil
+}
+
+// Actions returns the recorded actions in order.
+func (s *Service) Actions() []protocol.Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	actions := make([]protocol.Action, len(s.Recorded))
+	for i, entry := range s.Recorded {
+		actions[i] = entry.Action
+	}
+	return actions
+}
+
+// WithAction returns the recorded entries with the given action.
+func (s *Service) WithAction(action protocol.Action) []audit.AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var found []audit.AuditEntry
+	for _, entry := range s.Recorded {
+		if entry.Action == action {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+// Last returns the most recently recorded entry, or the zero entry when nothing was
+// recorded.
+func (s *Service) Last() audit.AuditEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Recorded) == 0 {
+		return audit.AuditEntry{}
+	}
+	return s.Recorded[len(s.Recorded)-1]
+}
+
+// Reset forgets the recorded entries.
+func (s *Service) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Recorded = nil
+}
+
+func (s *Service) GetLatestEntries(ctx context.Context, actor string, limit int) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) VerifyIntegrity(ctx context.Context, opts audit.VerifyOptions) (*audit.IntegrityReport, error) {
+	return &audit.IntegrityReport{Valid: true}, nil
+}
+
+func (s *Service) BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*audit.AuditBatch, error) {
+	return nil, nil
+}
+
+func (s *Service) GetMerkleRoot(ctx context.Context, batchID string) (string, error) {
+	return "", nil
+}
+
+func (s *Service) VerifyMerkleProof(root string, entryHash string, proof *protocol.Proof) bool {
+	return true
+}
+
+func (s *Service) GetEntriesForMerkle(ctx context.Context, startTime time.Time, endTime time.Time) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) GetEntryByID(ctx context.Context, id string) (*audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) GetEntriesByResource(ctx context.Context, resourceID string) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) QueryEntries(ctx context.Context, filter protocol.QueryFilter) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) GetHashSchemes(ctx context.Context) ([]audit.HashSchemeSummary, error) {
+	return nil, nil
+}
+
+func (s *Service) GetEntryProof(ctx context.Context, entryID string, batchID string) (*audit.EntryProof, error) {
+	return nil, nil
+}
+
+func (s *Service) GetBatchConsistency(ctx context.Context, fromBatchID string, toBatchID string) (*audit.BatchConsistency, error) {
+	return nil, nil
+}
+
+func (s *Service) VerifyConsistencyProof(oldRoot string, newRoot string, proof *protocol.ConsistencyProof) bool {
+	return true
+}
+
+func (s *Service) GetAccessReport(ctx context.Context, subject string, opts audit.AccessReportOptions) (*audit.AccessReport, error) {
+	return nil, nil
+}
+
+func (s *Service) ListAccessSince(ctx context.Context, after *audit.ChainCursor, start time.Time, limit int) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
+
+func (s *Service) ListChainSince(ctx context.Context, after *audit.ChainCursor, limit int) ([]audit.AuditEntry, error) {
+	return nil, nil
+}
@@ -1,18 +1,103 @@
 package audit
 
-import "time"
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/audit"
+)
 
 // AuditBatch tracks a batch of audit entries summarized by a Merkle root.
+//
+// merkle.v2 batches form one append-only log: each batch appends its entries to the
+// leaves of the previous one, and RootHash commits to all TreeSize leaves so far.
+// Legacy merkle.v1 batches are isolated trees over their own entries.
 type AuditBatch struct {
-	ID         string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	RootHash   string    `json:"rootHash" gorm:"type:varchar(64);not null;uniqueIndex"`
-	StartTime  time.Time `json:"startTime" gorm:"index;not null"`
-	EndTime    time.Time `json:"endTime" gorm:"index;not null"`
-	EntryCount int       `json:"entryCount" gorm:"not null"`
-	CreatedAt  time.Time `json:"createdAt" gorm:"index;not null"`
+	ID           string             `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	RootHash     string             `json:"rootHash" gorm:"type:varchar(64);not null;uniqueIndex"`
+	MerkleScheme audit.MerkleScheme `json:"merkleScheme" gorm:"type:varchar(20);not null;default:'merkle.v1'"`
+	StartTime    time.Time          `json:"startTime" gorm:"index;not null"`
+	EndTime      time.Time          `json:"endTime" gorm:"index;not null"`
+	EntryCount   int                `json:"entryCount" gorm:"not null"`               // Entries added by this batch
+	TreeSize     int                `json:"treeSize" gorm:"not null;default:0;index"` // Leaves under RootHash
+	CreatedAt    time.Time          `json:"createdAt" gorm:"index;not null"`
+
+	// On-chain anchoring of RootHash, advanced by AnchorService.
+	AnchorStatus        AnchorStatus `json:"anchorStatus" gorm:"type:varchar(20);not null;default:'pending';index"`
+	AnchorTxHash        string       `json:"anchorTxHash,omitempty" gorm:"type:varchar(66)"`
+	AnchorBlockNumber   uint64       `json:"anchorBlockNumber,omitempty"`
+	AnchorConfirmations uint64       `json:"anchorConfirmations,omitempty"`
+	AnchorAttempts      int          `json:"anchorAttempts" gorm:"not null;default:0"`
+	AnchorError         string       `json:"anchorError,omitempty" gorm:"type:text"`
+	AnchorSubmittedAt   *time.Time   `json:"anchorSubmittedAt,omitempty"`
+	AnchorNextAttemptAt *time.Time   `json:"-" gorm:"index"`
+	AnchoredAt          *time.Time   `json:"anchoredAt,omitempty"` // Block time reported by the contract
+}
+
+// Scheme returns the batch's Merkle scheme, treating unversioned batches as merkle.v1.
+func (b *AuditBatch) Scheme() audit.MerkleScheme {
+	return b.MerkleScheme.Normalize()
+}
+
+// Size returns the number of leaves committed to by RootHash.
+func (b *AuditBatch) Size() int {
+	if b.TreeSize == 0 {
+		return b.EntryCount
+	}
+	return b.TreeSize
 }
 
 // TableName returns the custom table name for audit batches.
 func (AuditBatch) TableName() string {
 	return "audit_batches"
 }
+
+// AuditBatchLeaf is one leaf of a batch's Merkle tree, kept so inclusion proofs can be
+// rebuilt after the batch is created. A merkle.v2 batch stores only the leaves it
+// appended, and Position is the leaf's index in the whole log.
+type AuditBatchLeaf struct {
+	BatchID   string `json:"batchId" gorm:"primaryKey;type:uuid"`
+	Position  int    `json:"position" gorm:"primaryKey"`
+	EntryID   string `json:"entryId" gorm:"type:uuid;not null;index"`
+	EntryHash string `json:"entryHash" gorm:"type:varchar(64);not null"`
+}
+
+// TableName returns the custom table name for audit batch leaves.
+func (AuditBatchLeaf) TableName() string {
+	return "audit_batch_leaves"
+}
+
+// AuditLogNode is the hash of one perfect subtree of the merkle.v2 log (see
+// audit.LogNode). Nodes are written with the batch that completes them, so roots and
+// proofs read O(log n) nodes instead of rebuilding the tree from every leaf.
+type AuditLogNode struct {
+	Level int    `json:"level" gorm:"primaryKey;autoIncrement:false"`
+	Index int    `json:"index" gorm:"column:node_index;primaryKey;autoIncrement:false"`
+	Hash  string `json:"hash" gorm:"type:varchar(64);not null"`
+}
+
+// TableName returns the custom table name for audit log nodes.
+func (AuditLogNode) TableName() string {
+	return "audit_log_nodes"
+}
+
+// EntryProof proves an audit entry is included in a batch's Merkle root.
+type EntryProof struct {
+	EntryID   string       `json:"entryId"`
+	EntryHash string       `json:"entryHash"`
+	BatchID   string       `json:"batchId"`
+	Root      string       `json:"root"`
+	LeafIndex int          `json:"leafIndex"`
+	LeafCount int          `json:"leafCount"`
+	Proof     *audit.Proof `json:"proof"`
+	StartTime time.Time    `json:"startTime"` // Batch window
+	EndTime   time.Time    `json:"endTime"`
+}
+
+// BatchConsistency proves that a later merkle.v2 batch only appended to an earlier one.
+type BatchConsistency struct {
+	FromBatchID string                  `json:"fromBatchId"`
+	FromRoot    string                  `json:"fromRoot"`
+	ToBatchID   string                  `json:"toBatchId"`
+	ToRoot      string                  `json:"toRoot"`
+	Proof       *audit.ConsistencyProof `json:"proof"`
+}
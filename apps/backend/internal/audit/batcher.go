@@ -0,0 +1,258 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// coverageSampleLimit caps how many flagged entries each coverage section lists.
+const coverageSampleLimit = 100
+
+// BatchOptions configures the scheduled batcher.
+type BatchOptions struct {
+	Interval    time.Duration // Window cadence; window ends are aligned to multiples of Interval in UTC
+	SettleDelay time.Duration // How long after a window ends before it is closed
+	MaxWindows  int           // Windows closed per pass
+}
+
+// DefaultBatchOptions returns the options used when none are configured.
+func DefaultBatchOptions() BatchOptions {
+	return BatchOptions{
+		Interval:    time.Hour,
+		SettleDelay: time.Minute,
+		MaxWindows:  100,
+	}
+}
+
+// CoverageWindow is a stretch of time that two consecutive log batches leave uncovered
+// or cover twice.
+type CoverageWindow struct {
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	BeforeBatchID string    `json:"beforeBatchId"`
+	AfterBatchID  string    `json:"afterBatchId"`
+}
+
+// CoverageEntry is an audit entry flagged by the coverage report.
+type CoverageEntry struct {
+	EntryID   string    `json:"entryId"`
+	Timestamp time.Time `json:"timestamp"`
+	BatchID   string    `json:"batchId,omitempty"`
+}
+
+// BatchCoverage reports whether every audit entry belongs to exactly one merkle.v2 log
+// batch and whether the batch windows are consecutive. Legacy merkle.v1 batches are not
+// part of the log and are not counted. Flagged entries are sampled.
+type BatchCoverage struct {
+	Batches      int              `json:"batches"`
+	CoveredFrom  time.Time        `json:"coveredFrom"`
+	CoveredUntil time.Time        `json:"coveredUntil"`
+	Entries      int64            `json:"entries"`
+	Logged       int64            `json:"logged"`
+	Pending      int64            `json:"pending"` // Unlogged entries whose window has not closed yet
+	MissedCount  int64            `json:"missedCount"`
+	Missed       []CoverageEntry  `json:"missed"` // Unlogged entries before CoveredUntil
+	Duplicates   []CoverageEntry  `json:"duplicates"`
+	Misplaced    []CoverageEntry  `json:"misplaced"` // Logged outside their batch's window
+	Gaps         []CoverageWindow `json:"gaps"`
+	Overlaps     []CoverageWindow `json:"overlaps"`
+	Complete     bool             `json:"complete"`
+}
+
+// Batcher closes consecutive, non-overlapping batch windows on a fixed cadence.
+type Batcher interface {
+	CloseWindows(ctx context.Context) ([]AuditBatch, error)
+	Start(ctx context.Context, interval time.Duration)
+	Coverage(ctx context.Context) (*BatchCoverage, error)
+}
+
+type batcher struct {
+	service *service
+	opts    BatchOptions
+	now     func() time.Time
+}
+
+// NewBatcher creates a scheduled batcher. Zero-valued options fall back to
+// DefaultBatchOptions.
+func NewBatcher(repo Repository, opts BatchOptions) Batcher {
+	defaults := DefaultBatchOptions()
+	if opts.Interval <= 0 {
+		opts.Interval = defaults.Interval
+	}
+	if opts.SettleDelay < 0 {
+		opts.SettleDelay = defaults.SettleDelay
+	}
+	if opts.MaxWindows <= 0 {
+		opts.MaxWindows = defaults.MaxWindows
+	}
+	return &batcher{service: &service{repo: repo}, opts: opts, now: time.Now}
+}
+
+// Start runs CloseWindows every interval until ctx is cancelled.
+func (b *batcher) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := b.CloseWindows(ctx); err != nil {
+					slog.Warn("audit batching pass failed", "error", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// CloseWindows appends every closed window to the log, oldest first. Each window starts
+// where the latest log batch ended and ends on the first cadence boundary after its
+// first entry, so periods without entries are folded into the next window rather than
+// leaving gaps. A window closes once its end is SettleDelay in the past.
+func (b *batcher) CloseWindows(ctx context.Context) ([]AuditBatch, error) {
+	repo := b.service.repo
+	cutoff := b.now().UTC().Add(-b.opts.SettleDelay)
+
+	var closed []AuditBatch
+	for len(closed) < b.opts.MaxWindows {
+		head, err := repo.GetLatestLogBatch(ctx)
+		if err != nil {
+			return closed, fmt.Errorf("close audit windows: %w", err)
+		}
+		start := windowStart(head)
+
+		next, _, err := repo.ListUnloggedEntries(ctx, start, time.Time{}, 1)
+		if err != nil {
+			return closed, fmt.Errorf("close audit windows: %w", err)
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		end := next[0].Timestamp.UTC().Truncate(b.opts.Interval).Add(b.opts.Interval)
+		if end.After(cutoff) {
+			break
+		}
+		if start.IsZero() {
+			start = end.Add(-b.opts.Interval)
+		}
+
+		candidates, err := b.service.GetEntriesForMerkle(ctx, start, end)
+		if err != nil {
+			return closed, fmt.Errorf("close audit windows: %w", err)
+		}
+		entries := candidates[:0]
+		for _, entry := range candidates {
+			if entry.Timestamp.Before(end) {
+				entries = append(entries, entry)
+			}
+		}
+
+		batch, err := b.service.appendToLog(ctx, head, entries, start, end)
+		if err != nil {
+			if errors.Is(err, ErrLogHeadMoved) {
+				// Another replica is closing the same windows.
+				slog.Info("audit batch log head moved; leaving windows to the other batcher")
+				break
+			}
+			return closed, err
+		}
+		slog.Info("audit batch window closed", "batchId", batch.ID, "start", batch.StartTime, "end", batch.EndTime, "entries", batch.EntryCount)
+		closed = append(closed, *batch)
+	}
+	return closed, nil
+}
+
+// windowStart returns where the window after head begins. Batches built before windows
+// were scheduled may have no end time; their window closed when they were created.
+func windowStart(head *AuditBatch) time.Time {
+	switch {
+	case head == nil:
+		return time.Time{}
+	case head.EndTime.IsZero():
+		return head.CreatedAt.UTC()
+	default:
+		return head.EndTime.UTC()
+	}
+}
+
+// Coverage checks that log batch windows are consecutive and that every audit entry is
+// logged in exactly one batch whose window contains it.
+func (b *batcher) Coverage(ctx context.Context) (*BatchCoverage, error) {
+	repo := b.service.repo
+
+	batches, err := repo.ListLogBatches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &BatchCoverage{
+		Batches:    len(batches),
+		Missed:     []CoverageEntry{},
+		Duplicates: []CoverageEntry{},
+		Misplaced:  []CoverageEntry{},
+		Gaps:       []CoverageWindow{},
+		Overlaps:   []CoverageWindow{},
+	}
+
+	for i, batch := range batches {
+		report.Logged += int64(batch.EntryCount)
+		if i == 0 {
+			report.CoveredFrom = batch.StartTime
+			continue
+		}
+		prev := batches[i-1]
+		switch {
+		case batch.StartTime.After(prev.EndTime):
+			report.Gaps = append(report.Gaps, CoverageWindow{Start: prev.EndTime, End: batch.StartTime, BeforeBatchID: prev.ID, AfterBatchID: batch.ID})
+		case batch.StartTime.Before(prev.EndTime):
+			report.Overlaps = append(report.Overlaps, CoverageWindow{Start: batch.StartTime, End: prev.EndTime, BeforeBatchID: prev.ID, AfterBatchID: batch.ID})
+		}
+	}
+	if len(batches) > 0 {
+		report.CoveredUntil = windowStart(&batches[len(batches)-1])
+	}
+
+	summaries, err := repo.SummarizeHashSchemes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, summary := range summaries {
+		report.Entries += summary.EntryCount
+	}
+
+	_, unlogged, err := repo.ListUnloggedEntries(ctx, time.Time{}, time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !report.CoveredUntil.IsZero() {
+		missed, count, err := repo.ListUnloggedEntries(ctx, time.Time{}, report.CoveredUntil, coverageSampleLimit)
+		if err != nil {
+			return nil, err
+		}
+		report.MissedCount = count
+		for _, entry := range missed {
+			report.Missed = append(report.Missed, CoverageEntry{EntryID: entry.ID, Timestamp: entry.Timestamp})
+		}
+	}
+	report.Pending = unlogged - report.MissedCount
+
+	duplicates, err := repo.ListDuplicateLogLeaves(ctx, coverageSampleLimit)
+	if err != nil {
+		return nil, err
+	}
+	report.Duplicates = append(report.Duplicates, duplicates...)
+
+	misplaced, err := repo.ListMisplacedLogLeaves(ctx, coverageSampleLimit)
+	if err != nil {
+		return nil, err
+	}
+	report.Misplaced = append(report.Misplaced, misplaced...)
+
+	report.Complete = len(report.Gaps) == 0 && len(report.Overlaps) == 0 &&
+		report.MissedCount == 0 && len(report.Duplicates) == 0 && len(report.Misplaced) == 0
+	return report, nil
+}
@@ -0,0 +1,157 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newBatcherTestRepo(timestamps ...time.Time) *mockRepo {
+	repo := &mockRepo{}
+	for i, ts := range timestamps {
+		repo.entries = append(repo.entries, AuditEntry{
+			ID:        fmt.Sprintf("entry-%d", i+1),
+			Hash:      fmt.Sprintf("%064x", i+1),
+			Timestamp: ts,
+		})
+	}
+	return repo
+}
+
+func newTestBatcher(repo Repository, clock *time.Time) Batcher {
+	b := NewBatcher(repo, BatchOptions{Interval: time.Hour, SettleDelay: time.Minute})
+	b.(*batcher).now = func() time.Time { return *clock }
+	return b
+}
+
+func TestBatcher_ClosesConsecutiveWindows(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo := newBatcherTestRepo(
+		day.Add(10*time.Hour+5*time.Minute),
+		day.Add(10*time.Hour+30*time.Minute),
+		day.Add(11*time.Hour), // Exactly on a boundary: belongs to the later window
+		day.Add(13*time.Hour+20*time.Minute),
+		day.Add(14*time.Hour+10*time.Minute), // Window still open
+	)
+	clock := day.Add(14*time.Hour + 30*time.Minute)
+	batcher := newTestBatcher(repo, &clock)
+
+	closed, err := batcher.CloseWindows(ctx)
+	if err != nil {
+		t.Fatalf("CloseWindows() error = %v", err)
+	}
+
+	want := []struct {
+		start, end time.Time
+		entries    int
+	}{
+		{day.Add(10 * time.Hour), day.Add(11 * time.Hour), 2},
+		{day.Add(11 * time.Hour), day.Add(12 * time.Hour), 1},
+		{day.Add(12 * time.Hour), day.Add(14 * time.Hour), 1}, // Empty 12:00 hour folded in
+	}
+	if len(closed) != len(want) {
+		t.Fatalf("closed %d windows, want %d", len(closed), len(want))
+	}
+	for i, w := range want {
+		got := closed[i]
+		if !got.StartTime.Equal(w.start) || !got.EndTime.Equal(w.end) || got.EntryCount != w.entries {
+			t.Errorf("window %d = [%v, %v) with %d entries, want [%v, %v) with %d", i, got.StartTime, got.EndTime, got.EntryCount, w.start, w.end, w.entries)
+		}
+	}
+
+	if again, err := batcher.CloseWindows(ctx); err != nil || len(again) != 0 {
+		t.Fatalf("CloseWindows() again = %d batches, %v; want none", len(again), err)
+	}
+
+	// The open window closes once its end has settled.
+	clock = day.Add(15*time.Hour + 2*time.Minute)
+	closed, err = batcher.CloseWindows(ctx)
+	if err != nil || len(closed) != 1 {
+		t.Fatalf("CloseWindows() after 15:00 = %d batches, %v; want 1", len(closed), err)
+	}
+	if !closed[0].StartTime.Equal(day.Add(14*time.Hour)) || closed[0].TreeSize != 5 {
+		t.Errorf("last window = %+v", closed[0])
+	}
+}
+
+func TestBatcher_WaitsForSettleDelay(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo := newBatcherTestRepo(day.Add(10*time.Hour + 59*time.Minute))
+	clock := day.Add(11*time.Hour + 30*time.Second)
+	batcher := newTestBatcher(repo, &clock)
+
+	if closed, err := batcher.CloseWindows(ctx); err != nil || len(closed) != 0 {
+		t.Fatalf("CloseWindows() inside settle delay = %d batches, %v; want none", len(closed), err)
+	}
+	clock = clock.Add(time.Minute)
+	if closed, err := batcher.CloseWindows(ctx); err != nil || len(closed) != 1 {
+		t.Fatalf("CloseWindows() after settle delay = %d batches, %v; want 1", len(closed), err)
+	}
+}
+
+func TestBatcher_Coverage(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo := newBatcherTestRepo(
+		day.Add(10*time.Hour+5*time.Minute),
+		day.Add(10*time.Hour+30*time.Minute),
+		day.Add(11*time.Hour+15*time.Minute),
+		day.Add(12*time.Hour+10*time.Minute),
+	)
+	clock := day.Add(12*time.Hour + 30*time.Minute)
+	batcher := newTestBatcher(repo, &clock)
+
+	if _, err := batcher.CloseWindows(ctx); err != nil {
+		t.Fatalf("CloseWindows() error = %v", err)
+	}
+	report, err := batcher.Coverage(ctx)
+	if err != nil {
+		t.Fatalf("Coverage() error = %v", err)
+	}
+	if !report.Complete || report.Batches != 2 || report.Entries != 4 || report.Logged != 3 || report.Pending != 1 {
+		t.Fatalf("Coverage() = %+v; want complete with 2 batches, 3 logged, 1 pending", report)
+	}
+	if !report.CoveredFrom.Equal(day.Add(10*time.Hour)) || !report.CoveredUntil.Equal(day.Add(12*time.Hour)) {
+		t.Errorf("covered [%v, %v)", report.CoveredFrom, report.CoveredUntil)
+	}
+}
+
+func TestBatcher_CoverageFlagsManualWindows(t *testing.T) {
+	ctx := context.Background()
+	day := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	repo := newBatcherTestRepo(
+		day.Add(10*time.Hour+5*time.Minute),
+		day.Add(10*time.Hour+30*time.Minute), // Falls in the gap between the manual windows
+		day.Add(10*time.Hour+45*time.Minute),
+	)
+	service := NewService(repo)
+
+	// Windows built by hand before scheduling: [10:00, 10:10] and [10:40, 11:00].
+	if _, err := service.BuildMerkleTree(ctx, day.Add(10*time.Hour), day.Add(10*time.Hour+10*time.Minute)); err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	if _, err := service.BuildMerkleTree(ctx, day.Add(10*time.Hour+40*time.Minute), day.Add(11*time.Hour)); err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+
+	clock := day.Add(11*time.Hour + 30*time.Minute)
+	report, err := newTestBatcher(repo, &clock).Coverage(ctx)
+	if err != nil {
+		t.Fatalf("Coverage() error = %v", err)
+	}
+	if report.Complete {
+		t.Fatal("Coverage() reported complete coverage with a gap")
+	}
+	if len(report.Gaps) != 1 || !report.Gaps[0].Start.Equal(day.Add(10*time.Hour+10*time.Minute)) {
+		t.Errorf("gaps = %+v", report.Gaps)
+	}
+	if report.MissedCount != 1 || len(report.Missed) != 1 || report.Missed[0].EntryID != "entry-2" {
+		t.Errorf("missed = %d %+v, want entry-2", report.MissedCount, report.Missed)
+	}
+	if report.Pending != 0 {
+		t.Errorf("pending = %d, want 0", report.Pending)
+	}
+}
@@ -0,0 +1,83 @@
+package audit
+
+import "time"
+
+// AuditCheckpoint records the last entry of a successful chain verification, so the
+// next run can resume from its hash instead of re-reading the whole log.
+type AuditCheckpoint struct {
+	ID              string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	EntryID         string    `json:"entryId" gorm:"type:uuid;not null"`
+	EntryHash       string    `json:"entryHash" gorm:"type:varchar(64);not null"`
+	EntryTimestamp  time.Time `json:"entryTimestamp" gorm:"not null"`
+	EntriesVerified int64     `json:"entriesVerified" gorm:"not null"` // Total entries covered from genesis
+	CreatedAt       time.Time `json:"createdAt" gorm:"index;not null"`
+}
+
+// TableName returns the custom table name for audit checkpoints.
+func (AuditCheckpoint) TableName() string {
+	return "audit_checkpoints"
+}
+
+// ChainCursor is a position in the chain order (timestamp, then id).
+type ChainCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// VerifyOptions narrows a chain verification.
+type VerifyOptions struct {
+	From time.Time // Optional: verify entries at or after this time
+	To   time.Time // Optional: verify entries at or before this time
+	Full bool      // Ignore checkpoints and re-verify from genesis
+}
+
+// BreakKind classifies how the chain is broken.
+type BreakKind string
+
+const (
+	// BreakHashMismatch means an entry's content no longer matches its stored hash.
+	BreakHashMismatch BreakKind = "hash_mismatch"
+	// BreakChainGap means an entry links to a hash that no longer exists: entries were deleted.
+	BreakChainGap BreakKind = "chain_gap"
+	// BreakReordering means an entry links to an existing entry other than its predecessor,
+	// typically because timestamps were rewritten.
+	BreakReordering BreakKind = "reordering"
+	// BreakSchemeDowngrade means a legacy audit.v1 entry follows audit.v2 entries.
+	BreakSchemeDowngrade BreakKind = "scheme_downgrade"
+	// BreakCheckpointMismatch means the entry a checkpoint vouches for has changed since.
+	BreakCheckpointMismatch BreakKind = "checkpoint_mismatch"
+)
+
+// ChainBreak describes the first broken entry found by a verification.
+type ChainBreak struct {
+	Kind           BreakKind     `json:"kind"`
+	EntryID        string        `json:"entryId"`
+	EntryTimestamp time.Time     `json:"entryTimestamp"`
+	Scheme         string        `json:"scheme,omitempty"`
+	StoredHash     string        `json:"storedHash,omitempty"`
+	ComputedHash   string        `json:"computedHash,omitempty"`
+	PreviousHash   string        `json:"previousHash,omitempty"`         // What the entry links to
+	ExpectedLink   string        `json:"expectedPreviousHash,omitempty"` // Hash of the entry before it in chain order
+	AffectedRange  AffectedRange `json:"affectedRange"`
+}
+
+// AffectedRange bounds the entries implicated by a break, in chain order.
+type AffectedRange struct {
+	FromEntryID   string    `json:"fromEntryId"`
+	FromTimestamp time.Time `json:"fromTimestamp"`
+	ToEntryID     string    `json:"toEntryId"`
+	ToTimestamp   time.Time `json:"toTimestamp"`
+}
+
+// IntegrityReport is the result of a chain verification.
+type IntegrityReport struct {
+	Valid               bool        `json:"valid"`
+	EntriesChecked      int64       `json:"entriesChecked"`
+	From                *time.Time  `json:"from,omitempty"`
+	To                  *time.Time  `json:"to,omitempty"`
+	ResumedFrom         string      `json:"resumedFrom,omitempty"` // Checkpoint ID, when incremental
+	LastVerifiedEntryID string      `json:"lastVerifiedEntryId,omitempty"`
+	LastVerifiedHash    string      `json:"lastVerifiedHash,omitempty"`
+	Break               *ChainBreak `json:"break,omitempty"`
+	CheckedAt           time.Time   `json:"checkedAt"`
+}
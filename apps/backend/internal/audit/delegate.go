@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+)
+
+// Metadata keys recorded when a guardian or caregiver acts as a patient. The entry's
+// actor is the patient (the principal); these name who actually acted and under
+// which delegation.
+const (
+	MetadataDelegate     = "delegate"
+	MetadataDelegationID = "delegationId"
+)
+
+type delegateKey struct{}
+
+type actingDelegate struct {
+	address      string
+	delegationID string
+}
+
+// WithDelegate marks ctx as a request a delegate makes as a patient. Every entry
+// recorded with the returned context names the delegate and the delegation.
+func WithDelegate(ctx context.Context, delegate, delegationID string) context.Context {
+	return context.WithValue(ctx, delegateKey{}, actingDelegate{address: delegate, delegationID: delegationID})
+}
+
+// DelegateFrom returns the delegate acting in ctx and their delegation, if any.
+func DelegateFrom(ctx context.Context) (delegate, delegationID string, ok bool) {
+	acting, ok := ctx.Value(delegateKey{}).(actingDelegate)
+	if !ok {
+		return "", "", false
+	}
+	return acting.address, acting.delegationID, true
+}
+
+// withDelegate returns metadata with the acting delegate added, leaving the caller's
+// map untouched. Without a delegate in ctx it returns metadata as is.
+func withDelegate(ctx context.Context, metadata common.JSONMap) common.JSONMap {
+	delegate, delegationID, ok := DelegateFrom(ctx)
+	if !ok {
+		return metadata
+	}
+	out := make(common.JSONMap, len(metadata)+2)
+	for k, v := range metadata {
+		out[k] = v
+	}
+	out[MetadataDelegate] = delegate
+	out[MetadataDelegationID] = delegationID
+	return out
+}
+
+// Delegate returns the delegate who acted for the entry's actor, or "" when the actor
+// acted themselves.
+func (e AuditEntry) Delegate() string {
+	delegate, _ := e.Metadata[MetadataDelegate].(string)
+	return delegate
+}
@@ -16,12 +16,32 @@ type AuditEntry struct {
 	ResourceID     string             `json:"resourceId" gorm:"index;index:idx_audit_resource_timestamp,priority:1;type:varchar(255);not null"`
 	Timestamp      time.Time          `json:"timestamp" gorm:"index;index:idx_audit_actor_timestamp,priority:2;index:idx_audit_resource_timestamp,priority:2;index:idx_audit_resource_type_action_timestamp,priority:3;not null"`
 	Metadata       common.JSONMap     `json:"metadata,omitempty" gorm:"type:jsonb"`
-	Hash           string             `json:"hash" gorm:"type:varchar(64);not null"`
-	PreviousHash   string             `json:"previousHash" gorm:"type:varchar(64);not null"`
+	Hash           string             `json:"hash" gorm:"type:varchar(64);not null;index"`
+	PreviousHash   string             `json:"previousHash" gorm:"type:varchar(64);not null;uniqueIndex"`
 	SchemaVersion  string             `json:"schemaVersion,omitempty" gorm:"type:varchar(20)"`
+	// Subject is the patient whose data the entry concerns, lowercased. It is copied
+	// from metadata["subject"], which the entry hash covers, so it can be indexed.
+	Subject        string             `json:"subject,omitempty" gorm:"index:idx_audit_subject_timestamp,priority:1;type:varchar(255)"`
 }
 
 // TableName returns the custom table name for audit entries.
 func (AuditEntry) TableName() string {
 	return "audit_entries"
 }
+
+// HashScheme returns the hash scheme the entry was written under (audit.v1 when unset).
+func (e AuditEntry) HashScheme() string {
+	if e.SchemaVersion == "" {
+		return audit.SchemaVersionAuditV1
+	}
+	return e.SchemaVersion
+}
+
+// HashSchemeSummary reports which entries were written under a given hash scheme.
+type HashSchemeSummary struct {
+	Scheme         string    `json:"scheme"`
+	EntryCount     int64     `json:"entryCount"`
+	FirstEntryAt   time.Time `json:"firstEntryAt"`
+	LastEntryAt    time.Time `json:"lastEntryAt"`
+	CoversMetadata bool      `json:"coversMetadata" gorm:"-"` // false for legacy audit.v1 entries
+}
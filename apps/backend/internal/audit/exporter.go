@@ -0,0 +1,315 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// maxExportEntries caps how many entries one export bundle may hold.
+const maxExportEntries = 100_000
+
+// ErrExportTooLarge is returned when the export window holds more than maxExportEntries.
+var ErrExportTooLarge = fmt.Errorf("audit export exceeds %d entries; narrow the time window", maxExportEntries)
+
+// ExportOptions selects the entries in an export bundle.
+type ExportOptions struct {
+	From  time.Time // Inclusive; zero exports from genesis
+	To    time.Time // Inclusive; zero exports up to the latest entry
+	Actor string    // When set, only this actor's entries are exported
+}
+
+// Exporter writes self-verifying audit bundles that can be checked offline with
+// fleming-verify.
+type Exporter interface {
+	Export(ctx context.Context, w io.Writer, opts ExportOptions) (*audit.BundleManifest, error)
+}
+
+type exporter struct {
+	service *service
+	network *audit.AnchorNetwork
+	now     func() time.Time
+}
+
+// NewExporter creates an exporter. network identifies the anchoring contract and may be
+// nil when on-chain anchoring is not configured.
+func NewExporter(repo Repository, network *audit.AnchorNetwork) Exporter {
+	return &exporter{service: &service{repo: repo}, network: network, now: time.Now}
+}
+
+// Export writes the entries selected by opts in chain order, the batch roots covering
+// them and an inclusion proof for every batched entry. Entries whose stored hash no
+// longer matches their batch leaf are exported as they are, so the verifier reports them.
+func (e *exporter) Export(ctx context.Context, w io.Writer, opts ExportOptions) (*audit.BundleManifest, error) {
+	bundle := &audit.Bundle{
+		Manifest: audit.BundleManifest{
+			CreatedAt: e.now().UTC(),
+			Actor:     opts.Actor,
+			Anchoring: e.network,
+		},
+	}
+	if !opts.From.IsZero() {
+		from := opts.From.UTC()
+		bundle.Manifest.From = &from
+	}
+	if !opts.To.IsZero() {
+		to := opts.To.UTC()
+		bundle.Manifest.To = &to
+	}
+
+	entries, err := e.listEntries(ctx, opts, &bundle.Manifest)
+	if err != nil {
+		return nil, fmt.Errorf("export audit bundle: %w", err)
+	}
+	bundle.Entries = make([]audit.Entry, len(entries))
+	for i, entry := range entries {
+		bundle.Entries[i] = toProtocolEntry(entry)
+	}
+
+	if bundle.Batches, bundle.Proofs, err = e.prove(ctx, entries); err != nil {
+		return nil, fmt.Errorf("export audit bundle: %w", err)
+	}
+
+	if err := audit.WriteBundle(w, bundle); err != nil {
+		return nil, fmt.Errorf("export audit bundle: %w", err)
+	}
+	return &bundle.Manifest, nil
+}
+
+// listEntries returns the exported entries in chain order. A full export is a
+// contiguous slice of the chain, so it also records the hash its first entry links to.
+func (e *exporter) listEntries(ctx context.Context, opts ExportOptions, manifest *audit.BundleManifest) ([]AuditEntry, error) {
+	repo := e.service.repo
+
+	if opts.Actor != "" {
+		filter := audit.QueryFilter{Actor: types.WalletAddress(opts.Actor), Limit: maxExportEntries + 1}
+		if !opts.From.IsZero() {
+			from := types.NewTimestamp(opts.From)
+			filter.StartTime = &from
+		}
+		if !opts.To.IsZero() {
+			to := types.NewTimestamp(opts.To)
+			filter.EndTime = &to
+		}
+		entries, err := repo.Query(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > maxExportEntries {
+			return nil, ErrExportTooLarge
+		}
+		sort.Slice(entries, func(i, j int) bool { return chainBefore(&entries[i], &entries[j]) })
+		return entries, nil
+	}
+
+	manifest.PrecedingHash = genesisHash
+	if !opts.From.IsZero() {
+		prev, err := repo.GetLastBefore(ctx, opts.From)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			manifest.PrecedingHash = prev.Hash
+		}
+	}
+
+	var (
+		entries []AuditEntry
+		cursor  *ChainCursor
+	)
+	for {
+		page, err := repo.ListChain(ctx, cursor, opts.From, opts.To, verifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if len(entries) > maxExportEntries {
+			return nil, ErrExportTooLarge
+		}
+		if len(page) < verifyPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		cursor = &ChainCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	return entries, nil
+}
+
+// provenBatch is a legacy batch tree rebuilt from stored leaves, with each entry's leaf
+// index.
+type provenBatch struct {
+	batch *AuditBatch
+	tree  *audit.MerkleTree
+	index map[string]int
+}
+
+// prove builds an inclusion proof for every batched entry. Logged entries are proven
+// against the latest anchored log batch that contains them, so the root can be checked
+// on-chain, and otherwise against the log head; those proofs read only the log nodes
+// they need. Entries only in legacy merkle.v1 batches are proven against their latest
+// batch.
+func (e *exporter) prove(ctx context.Context, entries []AuditEntry) ([]audit.BundleBatch, []audit.BundleProof, error) {
+	repo := e.service.repo
+	batches := []audit.BundleBatch{}
+	proofs := []audit.BundleProof{}
+	bundled := make(map[string]bool)
+	addBatch := func(batch *AuditBatch) {
+		if !bundled[batch.ID] {
+			bundled[batch.ID] = true
+			batches = append(batches, toBundleBatch(batch))
+		}
+	}
+
+	logProofs, logBatches, err := e.proveLogged(ctx, entries)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	legacy := make(map[string]*provenBatch)
+	for _, entry := range entries {
+		if proof, ok := logProofs[entry.ID]; ok {
+			addBatch(logBatches[proof.BatchID])
+			proofs = append(proofs, proof)
+			continue
+		}
+
+		batch, err := repo.GetLatestBatchForEntry(ctx, entry.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if batch == nil || batch.Scheme() == audit.MerkleSchemeV2 {
+			// Not batched yet; the verifier reports it as unproven.
+			continue
+		}
+		target := legacy[batch.ID]
+		if target == nil {
+			leaves, err := repo.GetBatchLeaves(ctx, batch.ID)
+			if err != nil {
+				return nil, nil, err
+			}
+			hashes := make([]string, len(leaves))
+			index := make(map[string]int, len(leaves))
+			for i, leaf := range leaves {
+				hashes[i] = leaf.EntryHash
+				index[leaf.EntryID] = i
+			}
+			if target, err = rebuildBatch(batch, hashes, index); err != nil {
+				return nil, nil, err
+			}
+			legacy[batch.ID] = target
+		}
+
+		addBatch(target.batch)
+		index := target.index[entry.ID]
+		proof, err := audit.GenerateProofAt(target.tree, index)
+		if err != nil {
+			return nil, nil, fmt.Errorf("generate merkle proof: %w", err)
+		}
+		proofs = append(proofs, audit.BundleProof{
+			EntryID:   entry.ID,
+			BatchID:   target.batch.ID,
+			LeafIndex: index,
+			Proof:     proof,
+		})
+	}
+	return batches, proofs, nil
+}
+
+// proveLogged proves the entries that are in the merkle.v2 log, keyed by entry ID, and
+// returns the batches the proofs are against.
+func (e *exporter) proveLogged(ctx context.Context, entries []AuditEntry) (map[string]audit.BundleProof, map[string]*AuditBatch, error) {
+	repo := e.service.repo
+	logBatches, err := repo.ListLogBatches(ctx)
+	if err != nil || len(logBatches) == 0 {
+		return nil, nil, err
+	}
+	head := &logBatches[len(logBatches)-1]
+	var anchored *AuditBatch
+	for i := len(logBatches) - 1; i >= 0; i-- {
+		if logBatches[i].AnchorStatus == AnchorAnchored {
+			anchored = &logBatches[i]
+			break
+		}
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	leaves, err := repo.GetLogLeavesForEntries(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := map[string]*AuditBatch{head.ID: head}
+	grouped := make(map[string][]AuditBatchLeaf)
+	seen := make(map[string]bool, len(leaves))
+	for _, leaf := range leaves {
+		if seen[leaf.EntryID] || leaf.Position >= head.Size() {
+			continue
+		}
+		seen[leaf.EntryID] = true
+		batch := head
+		if anchored != nil && leaf.Position < anchored.Size() {
+			batch = anchored
+			targets[batch.ID] = batch
+		}
+		grouped[batch.ID] = append(grouped[batch.ID], leaf)
+	}
+
+	proofs := make(map[string]audit.BundleProof, len(seen))
+	for batchID, batchLeaves := range grouped {
+		batchProofs, err := e.service.proveLogLeaves(ctx, targets[batchID], batchLeaves)
+		if err != nil {
+			return nil, nil, err
+		}
+		for i, leaf := range batchLeaves {
+			proofs[leaf.EntryID] = audit.BundleProof{
+				EntryID:   leaf.EntryID,
+				BatchID:   batchID,
+				LeafIndex: leaf.Position,
+				Proof:     batchProofs[i],
+			}
+		}
+	}
+	return proofs, targets, nil
+}
+
+// rebuildBatch rebuilds a batch tree from its leaf hashes and checks it against the
+// stored root.
+func rebuildBatch(batch *AuditBatch, hashes []string, index map[string]int) (*provenBatch, error) {
+	tree, err := audit.BuildMerkleTreeWithScheme(hashesToEntries(hashes), batch.Scheme())
+	if err != nil {
+		return nil, fmt.Errorf("rebuild merkle tree: %w", err)
+	}
+	if tree.Root != batch.RootHash {
+		return nil, fmt.Errorf("%w: batch %s", ErrBatchRootMismatch, batch.ID)
+	}
+	return &provenBatch{batch: batch, tree: tree, index: index}, nil
+}
+
+// toBundleBatch converts a batch to its bundle form, with an anchor reference once the
+// root is confirmed on-chain.
+func toBundleBatch(batch *AuditBatch) audit.BundleBatch {
+	bundled := audit.BundleBatch{
+		ID:        batch.ID,
+		Root:      batch.RootHash,
+		Scheme:    batch.Scheme(),
+		StartTime: batch.StartTime,
+		EndTime:   batch.EndTime,
+		TreeSize:  batch.Size(),
+	}
+	if batch.AnchorStatus == AnchorAnchored {
+		bundled.Anchor = &audit.AnchorReference{
+			TxHash:      batch.AnchorTxHash,
+			BlockNumber: batch.AnchorBlockNumber,
+			AnchoredAt:  batch.AnchoredAt,
+		}
+	}
+	return bundled
+}
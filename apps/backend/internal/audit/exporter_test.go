@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+)
+
+func exportBundle(t *testing.T, exporter Exporter, opts ExportOptions) (*protocol.Bundle, *protocol.BundleReport) {
+	t.Helper()
+	var buf bytes.Buffer
+	if _, err := exporter.Export(context.Background(), &buf, opts); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	bundle, files, err := protocol.ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	return bundle, protocol.VerifyBundle(bundle, files)
+}
+
+func TestExporter_FullExportVerifies(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 5)
+
+	first, err := service.BuildMerkleTree(ctx, time.Time{}, repo.entries[2].Timestamp)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	second, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	repo.batches[0].AnchorStatus = AnchorAnchored
+	repo.batches[0].AnchorTxHash = "0xfeed"
+	repo.batches[0].AnchorBlockNumber = 42
+	recordEntries(t, service, 1) // Not batched yet
+
+	network := &protocol.AnchorNetwork{ChainID: 11155111, Contract: "0x00000000000000000000000000000000000000aa"}
+	exporter := NewExporter(repo, network)
+
+	bundle, report := exportBundle(t, exporter, ExportOptions{})
+	if !report.Valid {
+		t.Fatalf("VerifyBundle() failures = %+v", report.Failures)
+	}
+	if report.Entries != 6 || report.Proven != 5 || report.Unproven != 1 || !report.ChainChecked {
+		t.Errorf("VerifyBundle() = %+v", report)
+	}
+	if bundle.Manifest.PrecedingHash != genesisHash || report.Anchoring == nil || report.Anchoring.ChainID != network.ChainID {
+		t.Errorf("manifest = %+v", bundle.Manifest)
+	}
+	if len(report.Anchored) != 1 || report.Anchored[0].ID != first.ID || report.Anchored[0].Anchor.TxHash != "0xfeed" {
+		t.Errorf("anchored = %+v, want batch %s", report.Anchored, first.ID)
+	}
+
+	// Entries covered by the anchored batch are proven against it; later ones against the head.
+	for _, proof := range bundle.Proofs {
+		want := second.ID
+		if proof.LeafIndex < first.TreeSize {
+			want = first.ID
+		}
+		if proof.BatchID != want {
+			t.Errorf("proof for %s uses batch %s, want %s", proof.EntryID, proof.BatchID, want)
+		}
+	}
+
+	windowed, report := exportBundle(t, exporter, ExportOptions{From: repo.entries[3].Timestamp})
+	if !report.Valid || report.Entries != 3 {
+		t.Fatalf("windowed VerifyBundle() = %+v", report)
+	}
+	if windowed.Manifest.PrecedingHash != repo.entries[2].Hash {
+		t.Errorf("windowed preceding hash = %s, want %s", windowed.Manifest.PrecedingHash, repo.entries[2].Hash)
+	}
+}
+
+func TestExporter_ActorExport(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	other := "0x00000000000000000000000000000000000000bb"
+	for _, actor := range []string{testActor, other, testActor, other, testActor} {
+		if err := service.Record(ctx, actor, protocol.ActionRead, protocol.ResourceEvent, "event-1", nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+	if _, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+
+	bundle, report := exportBundle(t, NewExporter(repo, nil), ExportOptions{Actor: testActor})
+	if !report.Valid || report.ChainChecked || report.Entries != 3 || report.Proven != 3 {
+		t.Fatalf("VerifyBundle() = %+v", report)
+	}
+	if bundle.Manifest.Actor != testActor || bundle.Manifest.PrecedingHash != "" {
+		t.Errorf("manifest = %+v", bundle.Manifest)
+	}
+	for i := 1; i < len(bundle.Entries); i++ {
+		if !bundle.Entries[i-1].Timestamp.Before(bundle.Entries[i].Timestamp) {
+			t.Errorf("entries not in chain order at %d", i)
+		}
+	}
+}
+
+func TestExporter_ReportsTamperedEntry(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 3)
+	if _, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+
+	repo.entries[1].Metadata = common.JSONMap{"i": 99}
+	_, report := exportBundle(t, NewExporter(repo, nil), ExportOptions{})
+	if report.Valid {
+		t.Fatal("VerifyBundle() accepted an entry edited after batching")
+	}
+	checks := make(map[protocol.BundleCheck]bool)
+	for _, failure := range report.Failures {
+		if failure.EntryID == repo.entries[1].ID {
+			checks[failure.Check] = true
+		}
+	}
+	if !checks[protocol.CheckEntryHash] {
+		t.Errorf("failures = %+v, want entry_hash on %s", report.Failures, repo.entries[1].ID)
+	}
+}
+
+func TestExporter_LegacyBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	legacyEntry(repo, "file-1", nil)
+	legacyEntry(repo, "file-2", nil)
+
+	hashes := []string{repo.entries[0].Hash, repo.entries[1].Hash}
+	root, err := protocol.ComputeRootWithScheme(hashes, protocol.MerkleSchemeV1)
+	if err != nil {
+		t.Fatalf("ComputeRootWithScheme() error = %v", err)
+	}
+	legacy := &AuditBatch{RootHash: root, EntryCount: 2}
+	leaves := []AuditBatchLeaf{
+		{Position: 0, EntryID: repo.entries[0].ID, EntryHash: hashes[0]},
+		{Position: 1, EntryID: repo.entries[1].ID, EntryHash: hashes[1]},
+	}
+	if err := repo.CreateBatch(ctx, legacy, leaves, nil); err != nil {
+		t.Fatalf("CreateBatch() error = %v", err)
+	}
+
+	bundle, report := exportBundle(t, NewExporter(repo, nil), ExportOptions{})
+	if !report.Valid || report.Proven != 2 {
+		t.Fatalf("VerifyBundle() = %+v", report)
+	}
+	if len(bundle.Batches) != 1 || bundle.Batches[0].ID != legacy.ID || bundle.Batches[0].Scheme != protocol.MerkleSchemeV1 {
+		t.Errorf("batches = %+v", bundle.Batches)
+	}
+}
@@ -1,9 +1,12 @@
 package audit
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/itspablomontes/fleming/pkg/protocol/audit"
@@ -12,12 +15,22 @@ import (
 
 // Handler handles HTTP requests for audit logs.
 type Handler struct {
-	service Service
+	service  Service
+	anchors  AnchorService
+	batcher  Batcher
+	exporter Exporter
+	system   map[string]bool
 }
 
-// NewHandler creates a new audit handler.
-func NewHandler(service Service) *Handler {
-	return &Handler{service: service}
+// NewHandler creates a new audit handler. anchors may be nil when on-chain anchoring
+// is not configured. systemAddresses lists the wallets allowed to trigger batching and
+// read the coverage report, and whose exports cover every actor.
+func NewHandler(service Service, anchors AnchorService, batcher Batcher, exporter Exporter, systemAddresses []string) *Handler {
+	system := make(map[string]bool, len(systemAddresses))
+	for _, address := range systemAddresses {
+		system[strings.ToLower(address)] = true
+	}
+	return &Handler{service: service, anchors: anchors, batcher: batcher, exporter: exporter, system: system}
 }
 
 // RegisterRoutes registers audit endpoints.
@@ -25,12 +38,20 @@ func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 	audit := rg.Group("/audit")
 	{
 		audit.GET("", h.HandleGetLogs)
+		audit.GET("/access", h.HandleGetAccess)
 		audit.GET("/entries/:id", h.HandleGetEntry)
+		audit.GET("/entries/:id/proof", h.HandleGetEntryProof)
 		audit.GET("/resource/:resourceId", h.HandleGetByResource)
 		audit.GET("/query", h.HandleQuery)
 		audit.GET("/verify", h.HandleVerify)
+		audit.GET("/schemes", h.HandleGetSchemes)
+		audit.GET("/export", h.HandleExport)
 		audit.POST("/merkle/build", h.HandleBuildMerkle)
+		audit.GET("/merkle/coverage", h.HandleGetCoverage)
+		audit.GET("/merkle/consistency", h.HandleGetConsistency)
+		audit.POST("/merkle/consistency/verify", h.HandleVerifyConsistency)
 		audit.GET("/merkle/:batchId", h.HandleGetMerkleRoot)
+		audit.GET("/merkle/:batchId/anchor", h.HandleGetAnchor)
 		audit.POST("/merkle/verify", h.HandleVerifyMerkle)
 	}
 }
@@ -53,20 +74,163 @@ func (h *Handler) HandleGetLogs(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"entries": entries})
 }
 
-// HandleVerify performs a check of the entire chain integrity.
+// HandleGetAccess lists every recorded read of the current user's data by other
+// parties, grouped by party and consent grant. Optional ?startTime= and ?endTime=
+// restrict the report to a time window.
+func (h *Handler) HandleGetAccess(c *gin.Context) {
+	address, exists := c.Get("user_address")
+	subject, ok := address.(string)
+	if !exists || !ok || subject == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var opts AccessReportOptions
+	if start := c.Query("startTime"); start != "" {
+		ts, err := types.ParseTimestamp(start)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime"})
+			return
+		}
+		opts.From = ts.Time
+	}
+
+	if end := c.Query("endTime"); end != "" {
+		ts, err := types.ParseTimestamp(end)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime"})
+			return
+		}
+		opts.To = ts.Time
+	}
+
+	report, err := h.service.GetAccessReport(c.Request.Context(), subject, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch access report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+// HandleVerify checks the chain and returns a structured integrity report.
+// Optional ?startTime= and ?endTime= restrict the check to a time window;
+// ?full=true re-verifies from genesis instead of the latest checkpoint.
 func (h *Handler) HandleVerify(c *gin.Context) {
-	valid, err := h.service.VerifyIntegrity(c.Request.Context())
+	var opts VerifyOptions
+
+	if start := c.Query("startTime"); start != "" {
+		ts, err := types.ParseTimestamp(start)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime"})
+			return
+		}
+		opts.From = ts.Time
+	}
+
+	if end := c.Query("endTime"); end != "" {
+		ts, err := types.ParseTimestamp(end)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime"})
+			return
+		}
+		opts.To = ts.Time
+	}
+
+	if full := c.Query("full"); full != "" {
+		value, err := strconv.ParseBool(full)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid full"})
+			return
+		}
+		opts.Full = value
+	}
+
+	report, err := h.service.VerifyIntegrity(c.Request.Context(), opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "integrity check failed"})
 		return
 	}
 
+	message := "Audit chain integrity verified"
+	if !report.Valid {
+		message = fmt.Sprintf("Audit chain broken at entry %s (%s)", report.Break.EntryID, report.Break.Kind)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"valid":   report.Valid,
+		"message": message,
+		"report":  report,
+	})
+}
+
+// HandleGetSchemes reports which entries were written under which hash scheme.
+func (h *Handler) HandleGetSchemes(c *gin.Context) {
+	schemes, err := h.service.GetHashSchemes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to summarize hash schemes"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"valid":   valid,
-		"message": "Audit chain integrity verified",
+		"current": audit.SchemaVersionAudit,
+		"schemes": schemes,
 	})
 }
 
+// HandleExport returns a self-verifying audit bundle (tar) for offline checking with
+// fleming-verify. System principals export the whole chain; other callers export only
+// their own entries. Optional ?startTime= and ?endTime= restrict the export window.
+func (h *Handler) HandleExport(c *gin.Context) {
+	address, _ := c.Get("user_address")
+	actor, ok := address.(string)
+	if !ok || actor == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var opts ExportOptions
+	if !h.system[strings.ToLower(actor)] {
+		opts.Actor = actor
+	}
+
+	if start := c.Query("startTime"); start != "" {
+		ts, err := types.ParseTimestamp(start)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime"})
+			return
+		}
+		opts.From = ts.Time
+	}
+
+	if end := c.Query("endTime"); end != "" {
+		ts, err := types.ParseTimestamp(end)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime"})
+			return
+		}
+		opts.To = ts.Time
+	}
+
+	var buf bytes.Buffer
+	manifest, err := h.exporter.Export(c.Request.Context(), &buf, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrExportTooLarge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrBatchRootMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export audit bundle"})
+		}
+		return
+	}
+
+	filename := fmt.Sprintf("fleming-audit-%s.tar", manifest.CreatedAt.Format("20060102T150405Z"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/x-tar", buf.Bytes())
+}
+
 func (h *Handler) HandleGetEntry(c *gin.Context) {
 	entryID := c.Param("id")
 	if entryID == "" {
@@ -87,6 +251,31 @@ func (h *Handler) HandleGetEntry(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"entry": entry})
 }
 
+// HandleGetEntryProof returns a Merkle inclusion proof for an entry. By default it
+// proves against the latest batch containing the entry; ?batchId= selects another.
+func (h *Handler) HandleGetEntryProof(c *gin.Context) {
+	entryID := c.Param("id")
+	if entryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entry ID is required"})
+		return
+	}
+
+	proof, err := h.service.GetEntryProof(c.Request.Context(), entryID, c.Query("batchId"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrEntryNotFound), errors.Is(err, ErrEntryNotBatched):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrBatchRootMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build inclusion proof"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"proof": proof})
+}
+
 func (h *Handler) HandleGetByResource(c *gin.Context) {
 	resourceID := c.Param("resourceId")
 	if resourceID == "" {
@@ -177,47 +366,53 @@ func (h *Handler) HandleQuery(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"entries": entries})
 }
 
-type merkleBuildRequest struct {
-	StartTime string `json:"startTime"`
-	EndTime   string `json:"endTime"`
+// requireSystem aborts with 403 unless the caller is a configured system principal.
+func (h *Handler) requireSystem(c *gin.Context) bool {
+	address, _ := c.Get("user_address")
+	actor, ok := address.(string)
+	if !ok || actor == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return false
+	}
+	if !h.system[strings.ToLower(actor)] {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only system principals can manage audit batches"})
+		return false
+	}
+	return true
 }
 
+// HandleBuildMerkle closes any audit windows that are due now instead of waiting for
+// the scheduled batcher. Windows are always derived from the log, never from the request.
 func (h *Handler) HandleBuildMerkle(c *gin.Context) {
-	var req merkleBuildRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+	if !h.requireSystem(c) {
 		return
 	}
 
-	var startTime time.Time
-	var endTime time.Time
-	if req.StartTime != "" {
-		ts, err := types.ParseTimestamp(req.StartTime)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid startTime"})
-			return
-		}
-		startTime = ts.Time
+	batches, err := h.batcher.CloseWindows(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build merkle tree"})
+		return
 	}
-	if req.EndTime != "" {
-		ts, err := types.ParseTimestamp(req.EndTime)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid endTime"})
-			return
-		}
-		endTime = ts.Time
+	if batches == nil {
+		batches = []AuditBatch{}
 	}
 
-	batch, tree, err := h.service.BuildMerkleTree(c.Request.Context(), startTime, endTime)
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// HandleGetCoverage reports whether every audit entry belongs to exactly one batch.
+func (h *Handler) HandleGetCoverage(c *gin.Context) {
+	if !h.requireSystem(c) {
+		return
+	}
+
+	coverage, err := h.batcher.Coverage(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build merkle tree"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute batch coverage"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"batch": batch,
-		"root":  tree.Root,
-	})
+	c.JSON(http.StatusOK, gin.H{"coverage": coverage})
 }
 
 func (h *Handler) HandleGetMerkleRoot(c *gin.Context) {
@@ -240,6 +435,26 @@ func (h *Handler) HandleGetMerkleRoot(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"root": root})
 }
 
+// HandleGetAnchor checks a batch root against the anchoring contract.
+func (h *Handler) HandleGetAnchor(c *gin.Context) {
+	if h.anchors == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": ErrAnchorNotConfigured.Error()})
+		return
+	}
+
+	verification, err := h.anchors.VerifyBatch(c.Request.Context(), c.Param("batchId"))
+	if err != nil {
+		if errors.Is(err, ErrBatchNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "batch not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to verify anchor"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"anchor": verification})
+}
+
 type merkleVerifyRequest struct {
 	Root      string       `json:"root" binding:"required"`
 	EntryHash string       `json:"entryHash" binding:"required"`
@@ -256,3 +471,47 @@ func (h *Handler) HandleVerifyMerkle(c *gin.Context) {
 	valid := h.service.VerifyMerkleProof(req.Root, req.EntryHash, &req.Proof)
 	c.JSON(http.StatusOK, gin.H{"valid": valid})
 }
+
+// HandleGetConsistency proves the log at batch ?to= (default: latest) only appended to
+// the log at batch ?from=.
+func (h *Handler) HandleGetConsistency(c *gin.Context) {
+	fromBatchID := c.Query("from")
+	if fromBatchID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from batch ID is required"})
+		return
+	}
+
+	consistency, err := h.service.GetBatchConsistency(c.Request.Context(), fromBatchID, c.Query("to"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrBatchNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrBatchNotInLog), errors.Is(err, ErrBatchOrder):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrBatchRootMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build consistency proof"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"consistency": consistency})
+}
+
+type consistencyVerifyRequest struct {
+	OldRoot string                 `json:"oldRoot" binding:"required"`
+	NewRoot string                 `json:"newRoot" binding:"required"`
+	Proof   audit.ConsistencyProof `json:"proof" binding:"required"`
+}
+
+func (h *Handler) HandleVerifyConsistency(c *gin.Context) {
+	var req consistencyVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	valid := h.service.VerifyConsistencyProof(req.OldRoot, req.NewRoot, &req.Proof)
+	c.JSON(http.StatusOK, gin.H{"valid": valid})
+}
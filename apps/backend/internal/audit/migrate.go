@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"gorm.io/gorm"
+)
+
+// ErrChainForked is returned by PrepareMigration when entries share a previous hash,
+// so the unique index on previous_hash cannot be built.
+var ErrChainForked = errors.New("audit chain is forked")
+
+// ChainFork is a previous hash that more than one entry links to, with those entries
+// in timestamp order. Every entry after the first starts a branch off the chain.
+type ChainFork struct {
+	PreviousHash string   `json:"previousHash"`
+	EntryIDs     []string `json:"entryIds"`
+}
+
+// FindChainForks lists every previous hash claimed by more than one entry.
+func FindChainForks(db *gorm.DB) ([]ChainFork, error) {
+	var hashes []string
+	err := db.Model(&AuditEntry{}).
+		Select("previous_hash").
+		Group("previous_hash").
+		Having("COUNT(*) > 1").
+		Order("previous_hash").
+		Pluck("previous_hash", &hashes).Error
+	if err != nil {
+		return nil, fmt.Errorf("find audit chain forks: %w", err)
+	}
+
+	forks := make([]ChainFork, 0, len(hashes))
+	for _, hash := range hashes {
+		fork := ChainFork{PreviousHash: hash}
+		err := db.Model(&AuditEntry{}).
+			Where("previous_hash = ?", hash).
+			Order("timestamp ASC, id ASC").
+			Pluck("id", &fork.EntryIDs).Error
+		if err != nil {
+			return nil, fmt.Errorf("list entries forked at %s: %w", hash, err)
+		}
+		forks = append(forks, fork)
+	}
+	return forks, nil
+}
+
+// PrepareMigration must run before AutoMigrate on databases written before appends
+// were serialized. Concurrent replicas could then fork the chain, and a fork makes
+// AutoMigrate fail with an opaque duplicate-key error when it builds the unique index
+// on previous_hash. PrepareMigration logs each fork and refuses instead, so an
+// operator can repair the chain before upgrading. It does nothing once the index exists.
+func PrepareMigration(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&AuditEntry{}) || migrator.HasIndex(&AuditEntry{}, "PreviousHash") {
+		return nil
+	}
+
+	forks, err := FindChainForks(db)
+	if err != nil {
+		return err
+	}
+	if len(forks) == 0 {
+		return nil
+	}
+
+	for _, fork := range forks {
+		slog.Error("audit chain fork", "previousHash", fork.PreviousHash, "entryIds", fork.EntryIDs)
+	}
+	return fmt.Errorf("%w: %d previous hashes are shared by more than one entry (logged above); "+
+		"audit_entries.previous_hash cannot be made unique until every branch after the first is re-linked onto the chain",
+		ErrChainForked, len(forks))
+}
@@ -3,26 +3,65 @@ package audit
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // Repository defines the interface for audit log persistence.
 type Repository interface {
 	Create(ctx context.Context, entry *AuditEntry) error
+	Append(ctx context.Context, build func(latest *AuditEntry) (*AuditEntry, error)) (*AuditEntry, error)
 	GetLatest(ctx context.Context) (*AuditEntry, error)
 	List(ctx context.Context, actor string, limit int) ([]AuditEntry, error)
 	GetByResource(ctx context.Context, resourceID types.ID) ([]AuditEntry, error)
 	GetByActor(ctx context.Context, actor types.WalletAddress) ([]AuditEntry, error)
 	GetByID(ctx context.Context, id types.ID) (*AuditEntry, error)
 	Query(ctx context.Context, filter protocol.QueryFilter) ([]AuditEntry, error)
-	CreateBatch(ctx context.Context, batch *AuditBatch) error
+	CreateBatch(ctx context.Context, batch *AuditBatch, leaves []AuditBatchLeaf, nodes []AuditLogNode) error
+	GetBatchLeaves(ctx context.Context, batchID string) ([]AuditBatchLeaf, error)
+	GetLatestBatchForEntry(ctx context.Context, entryID string) (*AuditBatch, error)
 	GetBatchByID(ctx context.Context, id string) (*AuditBatch, error)
 	GetBatchByRoot(ctx context.Context, rootHash string) (*AuditBatch, error)
+	GetLatestLogBatch(ctx context.Context) (*AuditBatch, error)
+	GetLogLeaves(ctx context.Context, start int, end int) ([]AuditBatchLeaf, error)
+	GetLogLeavesForEntries(ctx context.Context, entryIDs []string) ([]AuditBatchLeaf, error)
+	GetLogNodes(ctx context.Context, nodes []protocol.LogNode) ([]AuditLogNode, error)
+	SaveLogNodes(ctx context.Context, nodes []AuditLogNode) error
+	ListLogBatches(ctx context.Context) ([]AuditBatch, error)
+	ListUnloggedEntries(ctx context.Context, start time.Time, end time.Time, limit int) ([]AuditEntry, int64, error)
+	ListDuplicateLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error)
+	ListMisplacedLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error)
+	ListBatchesToAnchor(ctx context.Context, now time.Time, limit int) ([]AuditBatch, error)
+	UpdateBatchAnchor(ctx context.Context, batch *AuditBatch) error
+	SummarizeHashSchemes(ctx context.Context) ([]HashSchemeSummary, error)
+	ListAccess(ctx context.Context, subject string, start time.Time, end time.Time, limit int) ([]AuditEntry, error)
+	ListAccessAfter(ctx context.Context, after *ChainCursor, start time.Time, limit int) ([]AuditEntry, error)
+	ListChain(ctx context.Context, after *ChainCursor, start time.Time, end time.Time, limit int) ([]AuditEntry, error)
+	GetLastBefore(ctx context.Context, before time.Time) (*AuditEntry, error)
+	GetByHash(ctx context.Context, hash string) (*AuditEntry, error)
+	GetLatestCheckpoint(ctx context.Context) (*AuditCheckpoint, error)
+	CreateCheckpoint(ctx context.Context, checkpoint *AuditCheckpoint) error
 }
 
+// chainLockKey is the Postgres advisory lock key that serializes chain appends.
+const chainLockKey int64 = 0x666c656d696e67 // "fleming"
+
+// logLockKey serializes appends to the merkle.v2 batch log.
+const logLockKey int64 = chainLockKey + 1
+
+// logQueryChunk caps the keys bound in one IN clause when reading log leaves and nodes.
+const logQueryChunk = 1000
+
+// NotifyChannel is the Postgres channel Append notifies with the new entry's ID. The
+// notification is sent on commit, so listeners see entries in chain order.
+const NotifyChannel = "fleming_audit_entries"
+
 type gormRepository struct {
 	db *gorm.DB
 }
@@ -39,6 +78,40 @@ func (r *gormRepository) Create(ctx context.Context, entry *AuditEntry) error {
 	return nil
 }
 
+// Append reads the chain head and inserts the entry built from it in one transaction
+// holding a transaction-scoped advisory lock, so writers on any number of replicas
+// append strictly one after another. The unique index on previous_hash rejects any
+// fork that bypasses the lock. Listeners on NotifyChannel are told once it commits.
+func (r *gormRepository) Append(ctx context.Context, build func(latest *AuditEntry) (*AuditEntry, error)) (*AuditEntry, error) {
+	var entry *AuditEntry
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockKey).Error; err != nil {
+			return fmt.Errorf("lock audit chain: %w", err)
+		}
+
+		latest, err := (&gormRepository{db: tx}).GetLatest(ctx)
+		if err != nil {
+			return err
+		}
+
+		entry, err = build(latest)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(entry).Error; err != nil {
+			return fmt.Errorf("create audit entry: %w", err)
+		}
+		if err := tx.Exec("SELECT pg_notify(?, ?)", NotifyChannel, entry.ID).Error; err != nil {
+			return fmt.Errorf("notify audit entry: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
 func (r *gormRepository) GetLatest(ctx context.Context) (*AuditEntry, error) {
 	var entry AuditEntry
 	err := r.db.WithContext(ctx).Order("timestamp DESC, id DESC").Limit(1).First(&entry).Error
@@ -134,13 +207,284 @@ func (r *gormRepository) Query(ctx context.Context, filter protocol.QueryFilter)
 	return entries, nil
 }
 
-func (r *gormRepository) CreateBatch(ctx context.Context, batch *AuditBatch) error {
-	if err := r.db.WithContext(ctx).Create(batch).Error; err != nil {
+// CreateBatch stores the batch, its ordered leaves and the log nodes it completes
+// together. A merkle.v2 batch must extend the current log head; if another batch was
+// appended since it was built, ErrLogHeadMoved is returned and nothing is written.
+func (r *gormRepository) CreateBatch(ctx context.Context, batch *AuditBatch, leaves []AuditBatchLeaf, nodes []AuditLogNode) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if batch.Scheme() == protocol.MerkleSchemeV2 {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", logLockKey).Error; err != nil {
+				return fmt.Errorf("lock audit batch log: %w", err)
+			}
+			head, err := (&gormRepository{db: tx}).GetLatestLogBatch(ctx)
+			if err != nil {
+				return err
+			}
+			headSize := 0
+			if head != nil {
+				headSize = head.TreeSize
+			}
+			if batch.TreeSize-batch.EntryCount != headSize {
+				return ErrLogHeadMoved
+			}
+		}
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range leaves {
+			leaves[i].BatchID = batch.ID
+		}
+		if len(leaves) > 0 {
+			if err := tx.CreateInBatches(leaves, 1000).Error; err != nil {
+				return err
+			}
+		}
+		if len(nodes) > 0 {
+			if err := tx.CreateInBatches(nodes, 1000).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("create audit batch: %w", err)
 	}
 	return nil
 }
 
+func (r *gormRepository) GetBatchLeaves(ctx context.Context, batchID string) ([]AuditBatchLeaf, error) {
+	var leaves []AuditBatchLeaf
+	if err := r.db.WithContext(ctx).Where("batch_id = ?", batchID).Order("position ASC").Find(&leaves).Error; err != nil {
+		return nil, fmt.Errorf("get audit batch leaves: %w", err)
+	}
+	return leaves, nil
+}
+
+// GetLatestLogBatch returns the merkle.v2 batch with the largest tree, or nil when the
+// log is empty.
+func (r *gormRepository) GetLatestLogBatch(ctx context.Context) (*AuditBatch, error) {
+	var batch AuditBatch
+	err := r.db.WithContext(ctx).
+		Where("merkle_scheme = ?", protocol.MerkleSchemeV2).
+		Order("tree_size DESC").
+		First(&batch).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get latest audit log batch: %w", err)
+	}
+	return &batch, nil
+}
+
+// ListLogBatches returns the merkle.v2 batches in log order.
+func (r *gormRepository) ListLogBatches(ctx context.Context) ([]AuditBatch, error) {
+	var batches []AuditBatch
+	err := r.db.WithContext(ctx).
+		Where("merkle_scheme = ?", protocol.MerkleSchemeV2).
+		Order("tree_size ASC").
+		Find(&batches).Error
+	if err != nil {
+		return nil, fmt.Errorf("list audit log batches: %w", err)
+	}
+	return batches, nil
+}
+
+// ListUnloggedEntries returns up to limit entries with start <= timestamp < end that are
+// in no merkle.v2 batch, in chain order, plus how many there are in total. Zero bounds
+// are open; limit <= 0 returns only the count.
+func (r *gormRepository) ListUnloggedEntries(ctx context.Context, start time.Time, end time.Time, limit int) ([]AuditEntry, int64, error) {
+	query := func() *gorm.DB {
+		q := r.db.WithContext(ctx).Model(&AuditEntry{}).
+			Where(`NOT EXISTS (
+				SELECT 1 FROM audit_batch_leaves
+				JOIN audit_batches ON audit_batches.id = audit_batch_leaves.batch_id
+				WHERE audit_batch_leaves.entry_id = audit_entries.id AND audit_batches.merkle_scheme = ?
+			)`, protocol.MerkleSchemeV2)
+		if !start.IsZero() {
+			q = q.Where("timestamp >= ?", start)
+		}
+		if !end.IsZero() {
+			q = q.Where("timestamp < ?", end)
+		}
+		return q
+	}
+
+	var count int64
+	if err := query().Count(&count).Error; err != nil {
+		return nil, 0, fmt.Errorf("count unlogged audit entries: %w", err)
+	}
+	if limit <= 0 || count == 0 {
+		return nil, count, nil
+	}
+
+	var entries []AuditEntry
+	if err := query().Order("timestamp ASC, id ASC").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("list unlogged audit entries: %w", err)
+	}
+	return entries, count, nil
+}
+
+// ListDuplicateLogLeaves returns leaves of entries that appear more than once in the
+// merkle.v2 log.
+func (r *gormRepository) ListDuplicateLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error) {
+	var entries []CoverageEntry
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT l.entry_id, e.timestamp, l.batch_id
+		FROM audit_batch_leaves l
+		JOIN audit_batches b ON b.id = l.batch_id
+		JOIN audit_entries e ON e.id = l.entry_id
+		WHERE b.merkle_scheme = ? AND l.entry_id IN (
+			SELECT l2.entry_id FROM audit_batch_leaves l2
+			JOIN audit_batches b2 ON b2.id = l2.batch_id
+			WHERE b2.merkle_scheme = ?
+			GROUP BY l2.entry_id HAVING COUNT(*) > 1
+		)
+		ORDER BY l.entry_id, l.position
+		LIMIT ?`, protocol.MerkleSchemeV2, protocol.MerkleSchemeV2, limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("list duplicate audit log leaves: %w", err)
+	}
+	return entries, nil
+}
+
+// ListMisplacedLogLeaves returns merkle.v2 leaves whose entry timestamp lies outside
+// the batch window.
+func (r *gormRepository) ListMisplacedLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error) {
+	var entries []CoverageEntry
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT l.entry_id, e.timestamp, l.batch_id
+		FROM audit_batch_leaves l
+		JOIN audit_batches b ON b.id = l.batch_id
+		JOIN audit_entries e ON e.id = l.entry_id
+		WHERE b.merkle_scheme = ? AND (e.timestamp < b.start_time OR e.timestamp > b.end_time)
+		ORDER BY l.position
+		LIMIT ?`, protocol.MerkleSchemeV2, limit).
+		Scan(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("list misplaced audit log leaves: %w", err)
+	}
+	return entries, nil
+}
+
+// ListBatchesToAnchor returns submitted batches and pending batches whose retry is due,
+// oldest first.
+func (r *gormRepository) ListBatchesToAnchor(ctx context.Context, now time.Time, limit int) ([]AuditBatch, error) {
+	var batches []AuditBatch
+	err := r.db.WithContext(ctx).
+		Where("anchor_status = ? OR (anchor_status = ? AND (anchor_next_attempt_at IS NULL OR anchor_next_attempt_at <= ?))",
+			AnchorSubmitted, AnchorPending, now).
+		Order("created_at ASC").
+		Limit(limit).
+		Find(&batches).Error
+	if err != nil {
+		return nil, fmt.Errorf("list audit batches to anchor: %w", err)
+	}
+	return batches, nil
+}
+
+// UpdateBatchAnchor persists the anchoring fields of a batch.
+func (r *gormRepository) UpdateBatchAnchor(ctx context.Context, batch *AuditBatch) error {
+	err := r.db.WithContext(ctx).Model(&AuditBatch{}).Where("id = ?", batch.ID).Updates(map[string]any{
+		"anchor_status":          batch.AnchorStatus,
+		"anchor_tx_hash":         batch.AnchorTxHash,
+		"anchor_block_number":    batch.AnchorBlockNumber,
+		"anchor_confirmations":   batch.AnchorConfirmations,
+		"anchor_attempts":        batch.AnchorAttempts,
+		"anchor_error":           batch.AnchorError,
+		"anchor_submitted_at":    batch.AnchorSubmittedAt,
+		"anchor_next_attempt_at": batch.AnchorNextAttemptAt,
+		"anchored_at":            batch.AnchoredAt,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("update audit batch anchor: %w", err)
+	}
+	return nil
+}
+
+// GetLogLeaves returns the merkle.v2 log leaves at positions [start, end) in log order.
+func (r *gormRepository) GetLogLeaves(ctx context.Context, start int, end int) ([]AuditBatchLeaf, error) {
+	var leaves []AuditBatchLeaf
+	err := r.db.WithContext(ctx).
+		Select("audit_batch_leaves.*").
+		Joins("JOIN audit_batches ON audit_batches.id = audit_batch_leaves.batch_id").
+		Where("audit_batches.merkle_scheme = ? AND audit_batch_leaves.position >= ? AND audit_batch_leaves.position < ?", protocol.MerkleSchemeV2, start, end).
+		Order("audit_batch_leaves.position ASC").
+		Find(&leaves).Error
+	if err != nil {
+		return nil, fmt.Errorf("get audit log leaves: %w", err)
+	}
+	return leaves, nil
+}
+
+// GetLogLeavesForEntries returns the merkle.v2 log leaves of the given entries in log
+// order.
+func (r *gormRepository) GetLogLeavesForEntries(ctx context.Context, entryIDs []string) ([]AuditBatchLeaf, error) {
+	var leaves []AuditBatchLeaf
+	for start := 0; start < len(entryIDs); start += logQueryChunk {
+		end := min(start+logQueryChunk, len(entryIDs))
+		var chunk []AuditBatchLeaf
+		err := r.db.WithContext(ctx).
+			Select("audit_batch_leaves.*").
+			Joins("JOIN audit_batches ON audit_batches.id = audit_batch_leaves.batch_id").
+			Where("audit_batches.merkle_scheme = ? AND audit_batch_leaves.entry_id IN ?", protocol.MerkleSchemeV2, entryIDs[start:end]).
+			Find(&chunk).Error
+		if err != nil {
+			return nil, fmt.Errorf("get audit log leaves for entries: %w", err)
+		}
+		leaves = append(leaves, chunk...)
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].Position < leaves[j].Position })
+	return leaves, nil
+}
+
+// GetLogNodes returns the stored log nodes among nodes; missing ones are left out.
+func (r *gormRepository) GetLogNodes(ctx context.Context, nodes []protocol.LogNode) ([]AuditLogNode, error) {
+	var found []AuditLogNode
+	for start := 0; start < len(nodes); start += logQueryChunk {
+		end := min(start+logQueryChunk, len(nodes))
+		keys := make([][]any, 0, end-start)
+		for _, node := range nodes[start:end] {
+			keys = append(keys, []any{node.Level, node.Index})
+		}
+		var chunk []AuditLogNode
+		if err := r.db.WithContext(ctx).Where("(level, node_index) IN ?", keys).Find(&chunk).Error; err != nil {
+			return nil, fmt.Errorf("get audit log nodes: %w", err)
+		}
+		found = append(found, chunk...)
+	}
+	return found, nil
+}
+
+// SaveLogNodes stores log nodes, keeping any that already exist. It is used to backfill
+// logs written before nodes were stored; CreateBatch stores the nodes of new batches.
+func (r *gormRepository) SaveLogNodes(ctx context.Context, nodes []AuditLogNode) error {
+	if len(nodes) == 0 {
+		return nil
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(nodes, 1000).Error; err != nil {
+		return fmt.Errorf("save audit log nodes: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetLatestBatchForEntry(ctx context.Context, entryID string) (*AuditBatch, error) {
+	var batch AuditBatch
+	err := r.db.WithContext(ctx).
+		Joins("JOIN audit_batch_leaves ON audit_batch_leaves.batch_id = audit_batches.id").
+		Where("audit_batch_leaves.entry_id = ?", entryID).
+		Order("audit_batches.created_at DESC").
+		First(&batch).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get audit batch for entry: %w", err)
+	}
+	return &batch, nil
+}
+
 func (r *gormRepository) GetBatchByID(ctx context.Context, id string) (*AuditBatch, error) {
 	var batch AuditBatch
 	if err := r.db.WithContext(ctx).First(&batch, "id = ?", id).Error; err != nil {
@@ -162,3 +506,124 @@ func (r *gormRepository) GetBatchByRoot(ctx context.Context, rootHash string) (*
 	}
 	return &batch, nil
 }
+
+func (r *gormRepository) SummarizeHashSchemes(ctx context.Context) ([]HashSchemeSummary, error) {
+	var summaries []HashSchemeSummary
+	if err := r.db.WithContext(ctx).
+		Model(&AuditEntry{}).
+		Select("COALESCE(NULLIF(schema_version, ''), ?) AS scheme, COUNT(*) AS entry_count, MIN(timestamp) AS first_entry_at, MAX(timestamp) AS last_entry_at", protocol.SchemaVersionAuditV1).
+		Group("scheme").
+		Order("first_entry_at ASC").
+		Scan(&summaries).Error; err != nil {
+		return nil, fmt.Errorf("summarize audit hash schemes: %w", err)
+	}
+	return summaries, nil
+}
+
+// ListAccess returns entries about subject's data recorded by anyone other than the
+// subject, newest first. Refused attempts are not access and are left out.
+func (r *gormRepository) ListAccess(ctx context.Context, subject string, start time.Time, end time.Time, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	subject = strings.ToLower(subject)
+	query := r.db.WithContext(ctx).
+		Where("subject = ? AND LOWER(actor) <> ? AND action <> ?", subject, subject, protocol.ActionAccessDeny).
+		Order("timestamp DESC, id DESC")
+	if !start.IsZero() {
+		query = query.Where("timestamp >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("timestamp <= ?", end)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("list access to %s: %w", subject, err)
+	}
+	return entries, nil
+}
+
+// ListAccessAfter returns entries recorded by someone other than their subject, refused
+// attempts included, in chain order after the cursor and at or after start.
+func (r *gormRepository) ListAccessAfter(ctx context.Context, after *ChainCursor, start time.Time, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	query := r.chainQuery(ctx, after, start, time.Time{}).
+		Where("subject <> '' AND LOWER(actor) <> subject").
+		Order("timestamp ASC, id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("list audit access: %w", err)
+	}
+	return entries, nil
+}
+
+// chainQuery selects entries in chain order (timestamp, then id), optionally after a
+// cursor and within a time window.
+func (r *gormRepository) chainQuery(ctx context.Context, after *ChainCursor, start time.Time, end time.Time) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&AuditEntry{})
+	if after != nil {
+		query = query.Where("timestamp > ? OR (timestamp = ? AND id > ?)", after.Timestamp, after.Timestamp, after.ID)
+	}
+	if !start.IsZero() {
+		query = query.Where("timestamp >= ?", start)
+	}
+	if !end.IsZero() {
+		query = query.Where("timestamp <= ?", end)
+	}
+	return query
+}
+
+func (r *gormRepository) ListChain(ctx context.Context, after *ChainCursor, start time.Time, end time.Time, limit int) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	query := r.chainQuery(ctx, after, start, end).Order("timestamp ASC, id ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("list audit chain: %w", err)
+	}
+	return entries, nil
+}
+
+func (r *gormRepository) GetLastBefore(ctx context.Context, before time.Time) (*AuditEntry, error) {
+	var entry AuditEntry
+	err := r.db.WithContext(ctx).Where("timestamp < ?", before).Order("timestamp DESC, id DESC").First(&entry).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get audit entry before %s: %w", before, err)
+	}
+	return &entry, nil
+}
+
+func (r *gormRepository) GetByHash(ctx context.Context, hash string) (*AuditEntry, error) {
+	var entry AuditEntry
+	if err := r.db.WithContext(ctx).First(&entry, "hash = ?", hash).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get audit entry by hash: %w", err)
+	}
+	return &entry, nil
+}
+
+func (r *gormRepository) GetLatestCheckpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	var checkpoint AuditCheckpoint
+	if err := r.db.WithContext(ctx).Order("created_at DESC").First(&checkpoint).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get latest audit checkpoint: %w", err)
+	}
+	return &checkpoint, nil
+}
+
+func (r *gormRepository) CreateCheckpoint(ctx context.Context, checkpoint *AuditCheckpoint) error {
+	if err := r.db.WithContext(ctx).Create(checkpoint).Error; err != nil {
+		return fmt.Errorf("create audit checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+)
+
+// openTestDatabases connects n independent pools to a throwaway schema in the database
+// named by TEST_DATABASE_URL, standing in for n backend replicas.
+func openTestDatabases(t *testing.T, n int) []*gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	schema := fmt.Sprintf("audit_test_%d", time.Now().UnixNano())
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	scoped := dsn + " search_path=" + schema
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		scoped = dsn + separator + "search_path=" + schema
+	}
+
+	dbs := make([]*gorm.DB, n)
+	for i := range dbs {
+		db, err := gorm.Open(postgres.Open(scoped), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatalf("open replica %d: %v", i, err)
+		}
+		dbs[i] = db
+	}
+	if err := dbs[0].AutoMigrate(&AuditEntry{}, &AuditCheckpoint{}, &AuditBatch{}, &AuditBatchLeaf{}, &AuditLogNode{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return dbs
+}
+
+func TestGormRepository_ConcurrentAppendsAcrossReplicas(t *testing.T) {
+	const (
+		replicas  = 4
+		writers   = 8 // goroutines per replica
+		perWriter = 25
+	)
+	dbs := openTestDatabases(t, replicas)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, replicas*writers)
+	for r, db := range dbs {
+		service := NewService(NewRepository(db))
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func(r, w int) {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					resource := fmt.Sprintf("event-%d-%d-%d", r, w, i)
+					if err := service.Record(ctx, testActor, protocol.ActionRead, protocol.ResourceEvent, resource, common.JSONMap{"replica": r}); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}(r, w)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	var count int64
+	if err := dbs[0].Model(&AuditEntry{}).Count(&count).Error; err != nil {
+		t.Fatalf("count entries: %v", err)
+	}
+	if want := int64(replicas * writers * perWriter); count != want {
+		t.Fatalf("entries = %d, want %d", count, want)
+	}
+
+	report, err := NewService(NewRepository(dbs[1])).VerifyIntegrity(ctx, VerifyOptions{Full: true})
+	if err != nil {
+		t.Fatalf("VerifyIntegrity() error = %v", err)
+	}
+	if !report.Valid || report.EntriesChecked != count {
+		t.Fatalf("VerifyIntegrity() = %+v (break %+v), want %d linked entries", report, report.Break, count)
+	}
+}
+
+func TestGormRepository_RejectsForks(t *testing.T) {
+	dbs := openTestDatabases(t, 1)
+	ctx := context.Background()
+	repo := NewRepository(dbs[0])
+
+	if err := NewService(repo).Record(ctx, testActor, protocol.ActionRead, protocol.ResourceEvent, "event-1", nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// A writer that skips the lock and appends on the same head must be rejected.
+	fork := &AuditEntry{
+		Actor:         testActor,
+		Action:        protocol.ActionRead,
+		ResourceType:  protocol.ResourceEvent,
+		ResourceID:    "event-2",
+		Timestamp:     time.Now().UTC(),
+		Hash:          strings.Repeat("f", 64),
+		PreviousHash:  genesisHash,
+		SchemaVersion: protocol.SchemaVersionAuditV2,
+	}
+	if err := repo.Create(ctx, fork); err == nil {
+		t.Fatal("Create() should reject a second entry on the same previous hash")
+	}
+}
+
+func TestPrepareMigration_RefusesForkedChain(t *testing.T) {
+	dbs := openTestDatabases(t, 1)
+	ctx := context.Background()
+	db := dbs[0]
+
+	if err := PrepareMigration(db); err != nil {
+		t.Fatalf("PrepareMigration() on a migrated database error = %v", err)
+	}
+
+	// A database from before the unique index, forked by two unserialized replicas.
+	if err := db.Migrator().DropIndex(&AuditEntry{}, "PreviousHash"); err != nil {
+		t.Fatalf("drop index: %v", err)
+	}
+	service := NewService(NewRepository(db))
+	if err := service.Record(ctx, testActor, protocol.ActionRead, protocol.ResourceEvent, "event-1", nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := PrepareMigration(db); err != nil {
+		t.Fatalf("PrepareMigration() on an unforked chain error = %v", err)
+	}
+	fork := &AuditEntry{
+		Actor:         testActor,
+		Action:        protocol.ActionRead,
+		ResourceType:  protocol.ResourceEvent,
+		ResourceID:    "event-2",
+		Timestamp:     time.Now().UTC(),
+		Hash:          strings.Repeat("f", 64),
+		PreviousHash:  genesisHash,
+		SchemaVersion: protocol.SchemaVersionAuditV2,
+	}
+	if err := db.Create(fork).Error; err != nil {
+		t.Fatalf("create fork: %v", err)
+	}
+
+	forks, err := FindChainForks(db)
+	if err != nil {
+		t.Fatalf("FindChainForks() error = %v", err)
+	}
+	if len(forks) != 1 || forks[0].PreviousHash != genesisHash || len(forks[0].EntryIDs) != 2 || forks[0].EntryIDs[1] != fork.ID {
+		t.Fatalf("FindChainForks() = %+v, want one fork at genesis ending in %s", forks, fork.ID)
+	}
+	if err := PrepareMigration(db); !errors.Is(err, ErrChainForked) {
+		t.Fatalf("PrepareMigration() error = %v, want %v", err, ErrChainForked)
+	}
+}
+
+func TestGormRepository_ConcurrentBatchersAcrossReplicas(t *testing.T) {
+	const replicas = 3
+	dbs := openTestDatabases(t, replicas)
+	ctx := context.Background()
+
+	service := NewService(NewRepository(dbs[0]))
+	for i := 0; i < 50; i++ {
+		if err := service.Record(ctx, testActor, protocol.ActionRead, protocol.ResourceEvent, fmt.Sprintf("event-%d", i), nil); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+
+	// Every replica closes windows at once; the log lock lets exactly one batch through
+	// per window.
+	clock := time.Now().Add(2 * time.Hour)
+	var wg sync.WaitGroup
+	errs := make(chan error, replicas)
+	for _, db := range dbs {
+		wg.Add(1)
+		go func(db *gorm.DB) {
+			defer wg.Done()
+			if _, err := newTestBatcher(NewRepository(db), &clock).CloseWindows(ctx); err != nil {
+				errs <- err
+			}
+		}(db)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("CloseWindows() error = %v", err)
+	}
+
+	// Whatever a losing replica left behind is picked up by the next pass.
+	if _, err := newTestBatcher(NewRepository(dbs[0]), &clock).CloseWindows(ctx); err != nil {
+		t.Fatalf("CloseWindows() error = %v", err)
+	}
+
+	report, err := newTestBatcher(NewRepository(dbs[1]), &clock).Coverage(ctx)
+	if err != nil {
+		t.Fatalf("Coverage() error = %v", err)
+	}
+	if !report.Complete || report.Entries != 50 || report.Logged != 50 || report.Pending != 0 {
+		t.Fatalf("Coverage() = %+v, want all 50 entries logged exactly once", report)
+	}
+}
@@ -2,10 +2,11 @@ package audit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sort"
-	"sync"
+	"strings"
 	"time"
 
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
@@ -13,23 +14,59 @@ import (
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
+var (
+	// ErrEntryNotFound is returned when an audit entry does not exist.
+	ErrEntryNotFound = errors.New("audit entry not found")
+	// ErrEntryNotBatched is returned when no Merkle batch includes the entry.
+	ErrEntryNotBatched = errors.New("audit entry is not included in a merkle batch")
+	// ErrBatchRootMismatch is returned when a batch's stored leaves no longer hash to its root.
+	ErrBatchRootMismatch = errors.New("audit batch leaves do not match the stored root")
+	// ErrNoNewEntries is returned when a batch window holds no entries missing from the log.
+	ErrNoNewEntries = errors.New("no unbatched audit entries in the window")
+	// ErrLogHeadMoved is returned when another batch was appended to the log concurrently.
+	ErrLogHeadMoved = errors.New("audit batch log head moved while building the batch")
+	// ErrBatchNotFound is returned when a referenced batch does not exist.
+	ErrBatchNotFound = errors.New("audit batch not found")
+	// ErrBatchNotInLog is returned for consistency proofs over legacy merkle.v1 batches,
+	// which are isolated trees and not part of the append-only log.
+	ErrBatchNotInLog = errors.New("audit batch is not part of the append-only merkle log")
+	// ErrBatchOrder is returned when a consistency proof is requested from a larger tree
+	// to a smaller one.
+	ErrBatchOrder = errors.New("audit batch must not be older than the batch it extends")
+)
+
+const (
+	// genesisHash is the previous hash of the first entry in the chain.
+	genesisHash = audit.GenesisHash
+	// verifyPageSize is how many entries VerifyIntegrity reads per query.
+	verifyPageSize = 500
+	// logBackfillPageSize is how many log leaves backfillLogNodes reads per query.
+	logBackfillPageSize = 10_000
+)
+
 // Service defines the business logic for the audit protocol.
 type Service interface {
 	Record(ctx context.Context, actor string, action audit.Action, resourceType audit.ResourceType, resourceID string, metadata common.JSONMap) error
 	GetLatestEntries(ctx context.Context, actor string, limit int) ([]AuditEntry, error)
-	VerifyIntegrity(ctx context.Context) (bool, error)
-	BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*AuditBatch, *audit.MerkleTree, error)
+	VerifyIntegrity(ctx context.Context, opts VerifyOptions) (*IntegrityReport, error)
+	BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*AuditBatch, error)
 	GetMerkleRoot(ctx context.Context, batchID string) (string, error)
 	VerifyMerkleProof(root string, entryHash string, proof *audit.Proof) bool
 	GetEntriesForMerkle(ctx context.Context, startTime time.Time, endTime time.Time) ([]AuditEntry, error)
 	GetEntryByID(ctx context.Context, id string) (*AuditEntry, error)
 	GetEntriesByResource(ctx context.Context, resourceID string) ([]AuditEntry, error)
 	QueryEntries(ctx context.Context, filter audit.QueryFilter) ([]AuditEntry, error)
+	GetHashSchemes(ctx context.Context) ([]HashSchemeSummary, error)
+	GetEntryProof(ctx context.Context, entryID string, batchID string) (*EntryProof, error)
+	GetBatchConsistency(ctx context.Context, fromBatchID string, toBatchID string) (*BatchConsistency, error)
+	VerifyConsistencyProof(oldRoot string, newRoot string, proof *audit.ConsistencyProof) bool
+	GetAccessReport(ctx context.Context, subject string, opts AccessReportOptions) (*AccessReport, error)
+	ListAccessSince(ctx context.Context, after *ChainCursor, start time.Time, limit int) ([]AuditEntry, error)
+	ListChainSince(ctx context.Context, after *ChainCursor, limit int) ([]AuditEntry, error)
 }
 
 type service struct {
 	repo Repository
-	mu   sync.Mutex // Ensure sequential hashing if multiple records happen at once
 }
 
 // NewService creates a new audit service.
@@ -37,49 +74,50 @@ func NewService(repo Repository) Service {
 	return &service{repo: repo}
 }
 
-// Record generates a new cryptographically chained audit entry.
+// Record generates a new cryptographically chained audit entry. The head is read and
+// the entry inserted inside Repository.Append, which serializes appends across replicas.
+// When a delegate acts for the actor (see WithDelegate), the entry also names the
+// delegate and delegation.
 func (s *service) Record(ctx context.Context, actor string, action audit.Action, resourceType audit.ResourceType, resourceID string, metadata common.JSONMap) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	latest, err := s.repo.GetLatest(ctx)
-	if err != nil {
-		return fmt.Errorf("audit: %w", err)
-	}
-
-	previousHash := "GENESIS"
-	if latest != nil {
-		previousHash = latest.Hash
-	}
-
-	protocolEntry := audit.NewEntry(
-		types.WalletAddress(actor),
-		action,
-		resourceType,
-		types.ID(resourceID),
-		previousHash,
-	)
+	metadata = withDelegate(ctx, metadata)
+	dbEntry, err := s.repo.Append(ctx, func(latest *AuditEntry) (*AuditEntry, error) {
+		previousHash := genesisHash
+		if latest != nil {
+			previousHash = latest.Hash
+		}
 
-	if metadata != nil {
+		protocolEntry := audit.NewEntry(
+			types.WalletAddress(actor),
+			action,
+			resourceType,
+			types.ID(resourceID),
+			previousHash,
+		)
+
+		// Keep timestamps strictly increasing so chain order matches (timestamp, id) order.
+		if latest != nil && !protocolEntry.Timestamp.After(latest.Timestamp) {
+			protocolEntry.Timestamp = latest.Timestamp.Add(time.Microsecond)
+		}
 		for k, v := range metadata {
 			protocolEntry.Metadata[k] = v
 		}
 		protocolEntry.SetHash()
-	}
-
-	dbEntry := &AuditEntry{
-		Actor:         actor,
-		Action:        action,
-		ResourceType:  resourceType,
-		ResourceID:    resourceID,
-		Timestamp:     protocolEntry.Timestamp,
-		Metadata:      metadata,
-		Hash:          protocolEntry.Hash,
-		PreviousHash:  protocolEntry.PreviousHash,
-		SchemaVersion: protocolEntry.SchemaVersion,
-	}
 
-	if err := s.repo.Create(ctx, dbEntry); err != nil {
+		subject, _ := metadata[MetadataSubject].(string)
+		return &AuditEntry{
+			Subject:       strings.ToLower(subject),
+			Actor:         actor,
+			Action:        action,
+			ResourceType:  resourceType,
+			ResourceID:    resourceID,
+			Timestamp:     protocolEntry.Timestamp,
+			Metadata:      metadata,
+			Hash:          protocolEntry.Hash,
+			PreviousHash:  protocolEntry.PreviousHash,
+			SchemaVersion: protocolEntry.SchemaVersion,
+		}, nil
+	})
+	if err != nil {
 		return fmt.Errorf("audit: %w", err)
 	}
 
@@ -95,41 +133,234 @@ func (s *service) GetLatestEntries(ctx context.Context, actor string, limit int)
 	return s.repo.List(ctx, actor, limit)
 }
 
-// VerifyIntegrity checks the entire hash chain for tampering.
-func (s *service) VerifyIntegrity(ctx context.Context) (bool, error) {
-	entries, err := s.repo.List(ctx, "", 0)
-	if err != nil {
-		return false, err
+// VerifyIntegrity walks the hash chain in pages, in chain order, and stops at the first
+// break. Unless a time window is given or opts.Full is set, it resumes from the latest
+// checkpoint, and records a new checkpoint when the run succeeds.
+func (s *service) VerifyIntegrity(ctx context.Context, opts VerifyOptions) (*IntegrityReport, error) {
+	report := &IntegrityReport{CheckedAt: time.Now().UTC()}
+	windowed := !opts.From.IsZero() || !opts.To.IsZero()
+	if !opts.From.IsZero() {
+		from := opts.From.UTC()
+		report.From = &from
+	}
+	if !opts.To.IsZero() {
+		to := opts.To.UTC()
+		report.To = &to
 	}
 
-	for i := len(entries) - 1; i >= 0; i-- {
-		e := entries[i]
-
-		protocolEntry := audit.Entry{
-			Actor:        types.WalletAddress(e.Actor),
-			Action:       e.Action,
-			ResourceType: e.ResourceType,
-			ResourceID:   types.ID(e.ResourceID),
-			Timestamp:    e.Timestamp,
-			PreviousHash: e.PreviousHash,
+	var (
+		prev       *AuditEntry // Predecessor in chain order; nil means genesis
+		cursor     *ChainCursor
+		checkpoint *AuditCheckpoint
+		err        error
+	)
+	switch {
+	case !opts.From.IsZero():
+		if prev, err = s.repo.GetLastBefore(ctx, opts.From); err != nil {
+			return nil, fmt.Errorf("verify integrity: %w", err)
 		}
+	case !windowed && !opts.Full:
+		if checkpoint, err = s.repo.GetLatestCheckpoint(ctx); err != nil {
+			return nil, fmt.Errorf("verify integrity: %w", err)
+		}
+		if checkpoint != nil {
+			entry, err := s.repo.GetByID(ctx, types.ID(checkpoint.EntryID))
+			if err != nil {
+				return nil, fmt.Errorf("verify integrity: %w", err)
+			}
+			if brk := checkCheckpoint(checkpoint, entry); brk != nil {
+				report.Break = brk
+				return report, nil
+			}
+			prev = entry
+			cursor = &ChainCursor{Timestamp: entry.Timestamp, ID: entry.ID}
+			report.ResumedFrom = checkpoint.ID
+		}
+	}
 
-		computed := protocolEntry.ComputeHash()
-		if computed != e.Hash {
-			slog.ErrorContext(ctx, "audit integrity failure: hash mismatch", "id", e.ID, "expected", e.Hash, "computed", computed)
-			return false, nil
+	upgraded := prev != nil && prev.HashScheme() == audit.SchemaVersionAuditV2
+	for {
+		page, err := s.repo.ListChain(ctx, cursor, opts.From, opts.To, verifyPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("verify integrity: %w", err)
 		}
 
-		if i < len(entries)-1 {
-			prev := entries[i+1]
-			if e.PreviousHash != prev.Hash {
-				slog.ErrorContext(ctx, "audit integrity failure: chain broken", "id", e.ID, "previous_hash", e.PreviousHash, "prev_entry_hash", prev.Hash)
-				return false, nil
+		for i := range page {
+			entry := &page[i]
+			brk, err := s.checkEntry(ctx, entry, prev, &upgraded)
+			if err != nil {
+				return nil, fmt.Errorf("verify integrity: %w", err)
 			}
+			if brk != nil {
+				slog.ErrorContext(ctx, "audit integrity failure", "kind", brk.Kind, "id", brk.EntryID)
+				report.Break = brk
+				return report, nil
+			}
+			report.EntriesChecked++
+			report.LastVerifiedEntryID = entry.ID
+			report.LastVerifiedHash = entry.Hash
+			prev = entry
 		}
+
+		if len(page) < verifyPageSize {
+			break
+		}
+		last := page[len(page)-1]
+		cursor = &ChainCursor{Timestamp: last.Timestamp, ID: last.ID}
 	}
+	report.Valid = true
 
-	return true, nil
+	// Only contiguous runs from genesis or a checkpoint may seed the next run.
+	if !windowed && report.EntriesChecked > 0 {
+		covered := report.EntriesChecked
+		if checkpoint != nil {
+			covered += checkpoint.EntriesVerified
+		}
+		next := &AuditCheckpoint{
+			EntryID:         prev.ID,
+			EntryHash:       prev.Hash,
+			EntryTimestamp:  prev.Timestamp,
+			EntriesVerified: covered,
+			CreatedAt:       time.Now().UTC(),
+		}
+		if err := s.repo.CreateCheckpoint(ctx, next); err != nil {
+			return nil, fmt.Errorf("verify integrity: %w", err)
+		}
+	}
+
+	return report, nil
+}
+
+// checkEntry verifies one entry against its own hash and its predecessor in chain order.
+func (s *service) checkEntry(ctx context.Context, entry *AuditEntry, prev *AuditEntry, upgraded *bool) (*ChainBreak, error) {
+	scheme := entry.HashScheme()
+	brk := &ChainBreak{
+		EntryID:        entry.ID,
+		EntryTimestamp: entry.Timestamp,
+		Scheme:         scheme,
+		StoredHash:     entry.Hash,
+		PreviousHash:   entry.PreviousHash,
+		AffectedRange:  rangeOf(entry, entry),
+	}
+
+	// Once audit.v2 entries appear, a later legacy entry can only be a rewrite
+	// that strips metadata from the hash.
+	if scheme == audit.SchemaVersionAuditV2 {
+		*upgraded = true
+	} else if *upgraded {
+		brk.Kind = BreakSchemeDowngrade
+		return brk, nil
+	}
+
+	protocolEntry := toProtocolEntry(*entry)
+	if computed := protocolEntry.ComputeHash(); computed != entry.Hash {
+		brk.Kind = BreakHashMismatch
+		brk.ComputedHash = computed
+		return brk, nil
+	}
+
+	expected := genesisHash
+	if prev != nil {
+		expected = prev.Hash
+	}
+	if entry.PreviousHash == expected {
+		return nil, nil
+	}
+	brk.ExpectedLink = expected
+
+	linked, err := s.repo.GetByHash(ctx, entry.PreviousHash)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		// The link target exists but is not this entry's predecessor.
+		brk.Kind = BreakReordering
+		if chainBefore(linked, entry) {
+			brk.AffectedRange = rangeOf(linked, entry)
+		} else {
+			brk.AffectedRange = rangeOf(entry, linked)
+		}
+		return brk, nil
+	}
+
+	// The link target is gone: entries between the predecessor and this one were removed.
+	brk.Kind = BreakChainGap
+	if prev != nil {
+		brk.AffectedRange = rangeOf(prev, entry)
+	}
+	return brk, nil
+}
+
+// checkCheckpoint confirms the entry a checkpoint vouches for is unchanged.
+func checkCheckpoint(checkpoint *AuditCheckpoint, entry *AuditEntry) *ChainBreak {
+	brk := &ChainBreak{
+		Kind:           BreakCheckpointMismatch,
+		EntryID:        checkpoint.EntryID,
+		EntryTimestamp: checkpoint.EntryTimestamp,
+		StoredHash:     checkpoint.EntryHash,
+		AffectedRange: AffectedRange{
+			FromEntryID:   checkpoint.EntryID,
+			FromTimestamp: checkpoint.EntryTimestamp,
+			ToEntryID:     checkpoint.EntryID,
+			ToTimestamp:   checkpoint.EntryTimestamp,
+		},
+	}
+	if entry == nil {
+		return brk
+	}
+	brk.Scheme = entry.HashScheme()
+	protocolEntry := toProtocolEntry(*entry)
+	if computed := protocolEntry.ComputeHash(); entry.Hash != checkpoint.EntryHash || computed != checkpoint.EntryHash {
+		brk.ComputedHash = computed
+		return brk
+	}
+	return nil
+}
+
+func chainBefore(a, b *AuditEntry) bool {
+	if a.Timestamp.Equal(b.Timestamp) {
+		return a.ID < b.ID
+	}
+	return a.Timestamp.Before(b.Timestamp)
+}
+
+func rangeOf(from, to *AuditEntry) AffectedRange {
+	return AffectedRange{
+		FromEntryID:   from.ID,
+		FromTimestamp: from.Timestamp,
+		ToEntryID:     to.ID,
+		ToTimestamp:   to.Timestamp,
+	}
+}
+
+// GetHashSchemes reports how many entries were written under each hash scheme, and
+// over which time range, so operators can see where the audit.v1 to audit.v2 cutover
+// happened and which entries' metadata is not covered by the chain.
+func (s *service) GetHashSchemes(ctx context.Context) ([]HashSchemeSummary, error) {
+	summaries, err := s.repo.SummarizeHashSchemes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range summaries {
+		summaries[i].CoversMetadata = summaries[i].Scheme != audit.SchemaVersionAuditV1
+	}
+	return summaries, nil
+}
+
+// toProtocolEntry rebuilds the protocol entry exactly as Record hashed it.
+func toProtocolEntry(e AuditEntry) audit.Entry {
+	return audit.Entry{
+		ID:            types.ID(e.ID),
+		Actor:         types.WalletAddress(e.Actor),
+		Action:        e.Action,
+		ResourceType:  e.ResourceType,
+		ResourceID:    types.ID(e.ResourceID),
+		Timestamp:     e.Timestamp,
+		Metadata:      types.Metadata(e.Metadata),
+		SchemaVersion: e.SchemaVersion,
+		Hash:          e.Hash,
+		PreviousHash:  e.PreviousHash,
+	}
 }
 
 func (s *service) GetEntriesForMerkle(ctx context.Context, startTime time.Time, endTime time.Time) ([]AuditEntry, error) {
@@ -159,12 +390,37 @@ func (s *service) QueryEntries(ctx context.Context, filter audit.QueryFilter) ([
 	return s.repo.Query(ctx, filter)
 }
 
-func (s *service) BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*AuditBatch, *audit.MerkleTree, error) {
+// ListChainSince returns entries in chain order after the cursor, or from genesis
+// when it is nil.
+func (s *service) ListChainSince(ctx context.Context, after *ChainCursor, limit int) ([]AuditEntry, error) {
+	entries, err := s.repo.ListChain(ctx, after, time.Time{}, time.Time{}, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list chain since cursor: %w", err)
+	}
+	return entries, nil
+}
+
+// BuildMerkleTree appends the window's entries that are not yet in the log to the
+// merkle.v2 batch log. The new batch's root commits to every leaf logged so far, so it
+// can be proven consistent with every earlier batch. Zero bounds leave the window open
+// on that side; the batch then records the first or last appended entry's timestamp.
+func (s *service) BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*AuditBatch, error) {
 	entries, err := s.GetEntriesForMerkle(ctx, startTime, endTime)
 	if err != nil {
-		return nil, nil, fmt.Errorf("build merkle tree: %w", err)
+		return nil, fmt.Errorf("build merkle tree: %w", err)
 	}
 
+	head, err := s.repo.GetLatestLogBatch(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("build merkle tree: %w", err)
+	}
+	return s.appendToLog(ctx, head, entries, startTime, endTime)
+}
+
+// appendToLog builds a merkle.v2 batch extending head with the entries not yet logged.
+// Only head's frontier nodes are read, so the cost does not grow with the log.
+// CreateBatch rejects the batch with ErrLogHeadMoved if head is no longer the latest.
+func (s *service) appendToLog(ctx context.Context, head *AuditBatch, entries []AuditEntry, startTime time.Time, endTime time.Time) (*AuditBatch, error) {
 	sort.Slice(entries, func(i, j int) bool {
 		if entries[i].Timestamp.Equal(entries[j].Timestamp) {
 			return entries[i].ID < entries[j].ID
@@ -172,30 +428,82 @@ func (s *service) BuildMerkleTree(ctx context.Context, startTime time.Time, endT
 		return entries[i].Timestamp.Before(entries[j].Timestamp)
 	})
 
-	protocolEntries := make([]audit.Entry, 0, len(entries))
+	size := 0
+	frontier := audit.LogNodeHashes{}
+	if head != nil {
+		size = head.Size()
+		var err error
+		frontier, err = s.logNodes(ctx, audit.FrontierNodes(size))
+		if err != nil {
+			return nil, err
+		}
+		root, err := audit.LogRoot(frontier, size)
+		if err != nil || root != head.RootHash {
+			return nil, fmt.Errorf("%w: batch %s", ErrBatchRootMismatch, head.ID)
+		}
+	}
+
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.ID
+	}
+	logged, err := s.repo.GetLogLeavesForEntries(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	inLog := make(map[string]bool, len(logged))
+	for _, leaf := range logged {
+		inLog[leaf.EntryID] = true
+	}
+
+	leaves := make([]AuditBatchLeaf, 0, len(entries))
+	hashes := make([]string, 0, len(entries))
+	var first, last time.Time
 	for _, entry := range entries {
-		protocolEntries = append(protocolEntries, audit.Entry{
-			Hash: entry.Hash,
+		if inLog[entry.ID] {
+			continue
+		}
+		if first.IsZero() {
+			first = entry.Timestamp
+		}
+		last = entry.Timestamp
+		hashes = append(hashes, entry.Hash)
+		leaves = append(leaves, AuditBatchLeaf{
+			Position:  size + len(leaves),
+			EntryID:   entry.ID,
+			EntryHash: entry.Hash,
 		})
 	}
+	if len(leaves) == 0 {
+		return nil, ErrNoNewEntries
+	}
+	if startTime.IsZero() {
+		startTime = first
+	}
+	if endTime.IsZero() {
+		endTime = last
+	}
 
-	tree, err := audit.BuildMerkleTree(protocolEntries)
+	added, root, err := audit.AppendLogNodes(frontier, size, hashes)
 	if err != nil {
-		return nil, nil, fmt.Errorf("build merkle tree: %w", err)
+		return nil, fmt.Errorf("build merkle tree: %w", err)
 	}
 
 	batch := &AuditBatch{
-		RootHash:   tree.Root,
-		StartTime:  startTime.UTC(),
-		EndTime:    endTime.UTC(),
-		EntryCount: len(entries),
-		CreatedAt:  time.Now().UTC(),
+		RootHash:     root,
+		MerkleScheme: audit.MerkleSchemeV2,
+		StartTime:    startTime.UTC(),
+		EndTime:      endTime.UTC(),
+		EntryCount:   len(leaves),
+		TreeSize:     size + len(leaves),
+		CreatedAt:    time.Now().UTC(),
+		AnchorStatus: AnchorPending,
 	}
-	if err := s.repo.CreateBatch(ctx, batch); err != nil {
-		return nil, nil, fmt.Errorf("create audit batch: %w", err)
+	if err := s.repo.CreateBatch(ctx, batch, leaves, toLogNodes(added)); err != nil {
+		return nil, fmt.Errorf("create audit batch: %w", err)
 	}
 
-	return batch, tree, nil
+	return batch, nil
 }
 
 func (s *service) GetMerkleRoot(ctx context.Context, batchID string) (string, error) {
@@ -212,3 +520,315 @@ func (s *service) GetMerkleRoot(ctx context.Context, batchID string) (string, er
 func (s *service) VerifyMerkleProof(root string, entryHash string, proof *audit.Proof) bool {
 	return audit.VerifyProof(root, entryHash, proof)
 }
+
+// GetEntryProof returns an inclusion proof for the entry against a batch root. With an
+// empty batchID it uses the most recent batch that includes the entry; for logged
+// entries that is the latest merkle.v2 batch.
+func (s *service) GetEntryProof(ctx context.Context, entryID string, batchID string) (*EntryProof, error) {
+	entry, err := s.repo.GetByID(ctx, types.ID(entryID))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, ErrEntryNotFound
+	}
+
+	var batch *AuditBatch
+	if batchID != "" {
+		batch, err = s.repo.GetBatchByID(ctx, batchID)
+	} else {
+		batch, err = s.repo.GetLatestBatchForEntry(ctx, entryID)
+		if err == nil && batch != nil && batch.Scheme() == audit.MerkleSchemeV2 {
+			batch, err = s.repo.GetLatestLogBatch(ctx)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if batch == nil {
+		return nil, ErrEntryNotBatched
+	}
+
+	if batch.Scheme() == audit.MerkleSchemeV2 {
+		return s.getLogEntryProof(ctx, entry, batch)
+	}
+
+	leaves, err := s.repo.GetBatchLeaves(ctx, batch.ID)
+	if err != nil {
+		return nil, err
+	}
+	index := -1
+	hashes := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		hashes[i] = leaf.EntryHash
+		if leaf.EntryID == entry.ID {
+			index = i
+		}
+	}
+	if index == -1 {
+		return nil, ErrEntryNotBatched
+	}
+	if hashes[index] != entry.Hash {
+		return nil, fmt.Errorf("%w: entry %s changed since batch %s", ErrBatchRootMismatch, entry.ID, batch.ID)
+	}
+
+	tree, err := audit.BuildMerkleTreeWithScheme(hashesToEntries(hashes), batch.Scheme())
+	if err != nil {
+		return nil, fmt.Errorf("rebuild merkle tree: %w", err)
+	}
+	if tree.Root != batch.RootHash {
+		return nil, fmt.Errorf("%w: batch %s", ErrBatchRootMismatch, batch.ID)
+	}
+	proof, err := audit.GenerateProof(tree, entry.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("generate merkle proof: %w", err)
+	}
+
+	return &EntryProof{
+		EntryID:   entry.ID,
+		EntryHash: entry.Hash,
+		BatchID:   batch.ID,
+		Root:      batch.RootHash,
+		LeafIndex: index,
+		LeafCount: len(leaves),
+		Proof:     proof,
+		StartTime: batch.StartTime,
+		EndTime:   batch.EndTime,
+	}, nil
+}
+
+// getLogEntryProof proves a logged entry against a merkle.v2 batch from stored log nodes.
+func (s *service) getLogEntryProof(ctx context.Context, entry *AuditEntry, batch *AuditBatch) (*EntryProof, error) {
+	leaves, err := s.repo.GetLogLeavesForEntries(ctx, []string{entry.ID})
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) == 0 || leaves[0].Position >= batch.Size() {
+		return nil, ErrEntryNotBatched
+	}
+	leaf := leaves[0]
+	if leaf.EntryHash != entry.Hash {
+		return nil, fmt.Errorf("%w: entry %s changed since batch %s", ErrBatchRootMismatch, entry.ID, batch.ID)
+	}
+
+	proofs, err := s.proveLogLeaves(ctx, batch, leaves[:1])
+	if err != nil {
+		return nil, err
+	}
+	return &EntryProof{
+		EntryID:   entry.ID,
+		EntryHash: entry.Hash,
+		BatchID:   batch.ID,
+		Root:      batch.RootHash,
+		LeafIndex: leaf.Position,
+		LeafCount: batch.Size(),
+		Proof:     proofs[0],
+		StartTime: batch.StartTime,
+		EndTime:   batch.EndTime,
+	}, nil
+}
+
+// GetBatchConsistency proves that the log at toBatchID is an append-only extension of
+// the log at fromBatchID. An empty toBatchID means the latest log batch.
+func (s *service) GetBatchConsistency(ctx context.Context, fromBatchID string, toBatchID string) (*BatchConsistency, error) {
+	from, err := s.repo.GetBatchByID(ctx, fromBatchID)
+	if err != nil {
+		return nil, err
+	}
+	var to *AuditBatch
+	if toBatchID != "" {
+		to, err = s.repo.GetBatchByID(ctx, toBatchID)
+	} else {
+		to, err = s.repo.GetLatestLogBatch(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if from == nil || to == nil {
+		return nil, ErrBatchNotFound
+	}
+	if from.Scheme() != audit.MerkleSchemeV2 || to.Scheme() != audit.MerkleSchemeV2 {
+		return nil, ErrBatchNotInLog
+	}
+	if from.Size() > to.Size() {
+		return nil, ErrBatchOrder
+	}
+
+	nodes, err := s.logNodes(ctx, audit.LogConsistencyProofNodes(from.Size(), to.Size()))
+	if err != nil {
+		return nil, err
+	}
+	proof, err := audit.LogConsistencyProof(nodes, from.Size(), to.Size())
+	if err != nil {
+		return nil, fmt.Errorf("generate consistency proof: %w", err)
+	}
+	if !audit.VerifyConsistencyProof(from.RootHash, to.RootHash, proof) {
+		return nil, fmt.Errorf("%w: batch %s does not extend batch %s", ErrBatchRootMismatch, to.ID, from.ID)
+	}
+
+	return &BatchConsistency{
+		FromBatchID: from.ID,
+		FromRoot:    from.RootHash,
+		ToBatchID:   to.ID,
+		ToRoot:      to.RootHash,
+		Proof:       proof,
+	}, nil
+}
+
+func (s *service) VerifyConsistencyProof(oldRoot string, newRoot string, proof *audit.ConsistencyProof) bool {
+	return audit.VerifyConsistencyProof(oldRoot, newRoot, proof)
+}
+
+// proveLogLeaves builds inclusion proofs for leaves of the merkle.v2 log against batch,
+// reading only the log nodes the proofs need. Each proof is checked against the batch
+// root, so a stored node that no longer matches is reported as ErrBatchRootMismatch.
+func (s *service) proveLogLeaves(ctx context.Context, batch *AuditBatch, leaves []AuditBatchLeaf) ([]*audit.Proof, error) {
+	var need []audit.LogNode
+	for _, leaf := range leaves {
+		need = append(need, audit.LogInclusionProofNodes(leaf.Position, batch.Size())...)
+	}
+	nodes, err := s.logNodes(ctx, need)
+	if err != nil {
+		return nil, err
+	}
+
+	proofs := make([]*audit.Proof, len(leaves))
+	for i, leaf := range leaves {
+		proof, err := audit.LogInclusionProof(nodes, leaf.EntryHash, leaf.Position, batch.Size())
+		if err != nil {
+			return nil, fmt.Errorf("generate merkle proof: %w", err)
+		}
+		if !audit.VerifyProof(batch.RootHash, leaf.EntryHash, proof) {
+			return nil, fmt.Errorf("%w: batch %s", ErrBatchRootMismatch, batch.ID)
+		}
+		proofs[i] = proof
+	}
+	return proofs, nil
+}
+
+// logNodes reads the listed log nodes. Logs written before nodes were stored have none,
+// so a miss backfills the node table from the leaves once and reads again.
+func (s *service) logNodes(ctx context.Context, list []audit.LogNode) (audit.LogNodeHashes, error) {
+	nodes, err := s.readLogNodes(ctx, list)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == len(uniqueLogNodes(list)) {
+		return nodes, nil
+	}
+
+	if err := s.backfillLogNodes(ctx); err != nil {
+		return nil, err
+	}
+	if nodes, err = s.readLogNodes(ctx, list); err != nil {
+		return nil, err
+	}
+	if len(nodes) != len(uniqueLogNodes(list)) {
+		return nil, fmt.Errorf("%w: %w", ErrBatchRootMismatch, audit.ErrLogNodeMissing)
+	}
+	return nodes, nil
+}
+
+func (s *service) readLogNodes(ctx context.Context, list []audit.LogNode) (audit.LogNodeHashes, error) {
+	stored, err := s.repo.GetLogNodes(ctx, uniqueLogNodes(list))
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(audit.LogNodeHashes, len(stored))
+	for _, node := range stored {
+		nodes[audit.LogNode{Level: node.Level, Index: node.Index}] = node.Hash
+	}
+	return nodes, nil
+}
+
+// backfillLogNodes computes the node table of the log up to its head from the stored
+// leaves, a page at a time and holding only the frontier in memory, and checks the
+// result against the head's root.
+func (s *service) backfillLogNodes(ctx context.Context) error {
+	head, err := s.repo.GetLatestLogBatch(ctx)
+	if err != nil || head == nil {
+		return err
+	}
+	slog.Info("backfilling audit log nodes", "treeSize", head.Size())
+
+	frontier := audit.LogNodeHashes{}
+	size := 0
+	root := ""
+	for size < head.Size() {
+		leaves, err := s.repo.GetLogLeaves(ctx, size, min(size+logBackfillPageSize, head.Size()))
+		if err != nil {
+			return err
+		}
+		if len(leaves) == 0 {
+			break
+		}
+		hashes := make([]string, len(leaves))
+		for i, leaf := range leaves {
+			if leaf.Position != size+i {
+				return fmt.Errorf("%w: log leaf %d missing", ErrBatchRootMismatch, size+i)
+			}
+			hashes[i] = leaf.EntryHash
+		}
+
+		added, pageRoot, err := audit.AppendLogNodes(frontier, size, hashes)
+		if err != nil {
+			return fmt.Errorf("backfill audit log nodes: %w", err)
+		}
+		if err := s.repo.SaveLogNodes(ctx, toLogNodes(added)); err != nil {
+			return err
+		}
+		size += len(leaves)
+		root = pageRoot
+
+		next := make(audit.LogNodeHashes)
+		for _, node := range audit.FrontierNodes(size) {
+			if hash, ok := added[node]; ok {
+				next[node] = hash
+			} else {
+				next[node] = frontier[node]
+			}
+		}
+		frontier = next
+	}
+	if size != head.Size() {
+		return fmt.Errorf("%w: batch %s has %d of %d leaves", ErrBatchRootMismatch, head.ID, size, head.Size())
+	}
+	if root != head.RootHash {
+		return fmt.Errorf("%w: batch %s", ErrBatchRootMismatch, head.ID)
+	}
+	return nil
+}
+
+func uniqueLogNodes(list []audit.LogNode) []audit.LogNode {
+	seen := make(map[audit.LogNode]bool, len(list))
+	unique := make([]audit.LogNode, 0, len(list))
+	for _, node := range list {
+		if !seen[node] {
+			seen[node] = true
+			unique = append(unique, node)
+		}
+	}
+	return unique
+}
+
+func toLogNodes(nodes audit.LogNodeHashes) []AuditLogNode {
+	result := make([]AuditLogNode, 0, len(nodes))
+	for node, hash := range nodes {
+		result = append(result, AuditLogNode{Level: node.Level, Index: node.Index, Hash: hash})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Level != result[j].Level {
+			return result[i].Level < result[j].Level
+		}
+		return result[i].Index < result[j].Index
+	})
+	return result
+}
+
+func hashesToEntries(hashes []string) []audit.Entry {
+	entries := make([]audit.Entry, len(hashes))
+	for i, hash := range hashes {
+		entries[i] = audit.Entry{Hash: hash}
+	}
+	return entries
+}
@@ -2,23 +2,51 @@ package audit
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
 type mockRepo struct {
-	entries []AuditEntry
-	batches []AuditBatch
+	mu          sync.Mutex
+	entries     []AuditEntry
+	batches     []AuditBatch
+	checkpoints []AuditCheckpoint
+	leaves      []AuditBatchLeaf
+	nodes       map[protocol.LogNode]string
 }
 
 func (m *mockRepo) Create(ctx context.Context, entry *AuditEntry) error {
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("entry-%06d", len(m.entries))
+	}
 	m.entries = append(m.entries, *entry)
 	return nil
 }
 
+func (m *mockRepo) Append(ctx context.Context, build func(latest *AuditEntry) (*AuditEntry, error)) (*AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest, _ := m.GetLatest(ctx)
+	entry, err := build(latest)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Create(ctx, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
 func (m *mockRepo) GetLatest(ctx context.Context) (*AuditEntry, error) {
 	if len(m.entries) == 0 {
 		return nil, nil
@@ -27,7 +55,12 @@ func (m *mockRepo) GetLatest(ctx context.Context) (*AuditEntry, error) {
 }
 
 func (m *mockRepo) List(ctx context.Context, actor string, limit int) ([]AuditEntry, error) {
-	return m.entries, nil
+	// Newest first, like the GORM repository.
+	result := make([]AuditEntry, 0, len(m.entries))
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		result = append(result, m.entries[i])
+	}
+	return result, nil
 }
 
 func (m *mockRepo) GetByResource(ctx context.Context, resourceID types.ID) ([]AuditEntry, error) {
@@ -63,6 +96,9 @@ func (m *mockRepo) GetByID(ctx context.Context, id types.ID) (*AuditEntry, error
 func (m *mockRepo) Query(ctx context.Context, filter protocol.QueryFilter) ([]AuditEntry, error) {
 	var result []AuditEntry
 	for _, entry := range m.entries {
+		if !filter.Actor.IsEmpty() && entry.Actor != filter.Actor.String() {
+			continue
+		}
 		if filter.StartTime != nil && entry.Timestamp.Before(filter.StartTime.Time) {
 			continue
 		}
@@ -74,12 +110,44 @@ func (m *mockRepo) Query(ctx context.Context, filter protocol.QueryFilter) ([]Au
 	return result, nil
 }
 
-func (m *mockRepo) CreateBatch(ctx context.Context, batch *AuditBatch) error {
+func (m *mockRepo) CreateBatch(ctx context.Context, batch *AuditBatch, leaves []AuditBatchLeaf, nodes []AuditLogNode) error {
+	if batch.Scheme() == protocol.MerkleSchemeV2 {
+		head, _ := m.GetLatestLogBatch(ctx)
+		if head != nil && batch.TreeSize-batch.EntryCount != head.TreeSize {
+			return ErrLogHeadMoved
+		}
+	}
 	if batch.ID == "" {
-		batch.ID = "batch-1"
+		batch.ID = fmt.Sprintf("batch-%d", len(m.batches)+1)
 	}
 	m.batches = append(m.batches, *batch)
-	return nil
+	for _, leaf := range leaves {
+		leaf.BatchID = batch.ID
+		m.leaves = append(m.leaves, leaf)
+	}
+	return m.SaveLogNodes(ctx, nodes)
+}
+
+func (m *mockRepo) GetBatchLeaves(ctx context.Context, batchID string) ([]AuditBatchLeaf, error) {
+	var result []AuditBatchLeaf
+	for _, leaf := range m.leaves {
+		if leaf.BatchID == batchID {
+			result = append(result, leaf)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) GetLatestBatchForEntry(ctx context.Context, entryID string) (*AuditBatch, error) {
+	for i := len(m.batches) - 1; i >= 0; i-- {
+		for _, leaf := range m.leaves {
+			if leaf.BatchID == m.batches[i].ID && leaf.EntryID == entryID {
+				found := m.batches[i]
+				return &found, nil
+			}
+		}
+	}
+	return nil, nil
 }
 
 func (m *mockRepo) GetBatchByID(ctx context.Context, id string) (*AuditBatch, error) {
@@ -102,6 +170,287 @@ func (m *mockRepo) GetBatchByRoot(ctx context.Context, rootHash string) (*AuditB
 	return nil, nil
 }
 
+func (m *mockRepo) GetLatestLogBatch(ctx context.Context) (*AuditBatch, error) {
+	var found *AuditBatch
+	for i := range m.batches {
+		if m.batches[i].Scheme() == protocol.MerkleSchemeV2 && (found == nil || m.batches[i].TreeSize > found.TreeSize) {
+			batch := m.batches[i]
+			found = &batch
+		}
+	}
+	return found, nil
+}
+
+func (m *mockRepo) GetLogLeaves(ctx context.Context, start int, end int) ([]AuditBatchLeaf, error) {
+	var result []AuditBatchLeaf
+	for leaf := range m.logLeaves() {
+		if leaf.Position >= start && leaf.Position < end {
+			result = append(result, leaf)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Position < result[j].Position })
+	return result, nil
+}
+
+func (m *mockRepo) GetLogLeavesForEntries(ctx context.Context, entryIDs []string) ([]AuditBatchLeaf, error) {
+	wanted := make(map[string]bool, len(entryIDs))
+	for _, id := range entryIDs {
+		wanted[id] = true
+	}
+	var result []AuditBatchLeaf
+	for leaf := range m.logLeaves() {
+		if wanted[leaf.EntryID] {
+			result = append(result, leaf)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Position < result[j].Position })
+	return result, nil
+}
+
+func (m *mockRepo) GetLogNodes(ctx context.Context, nodes []protocol.LogNode) ([]AuditLogNode, error) {
+	var result []AuditLogNode
+	for _, node := range nodes {
+		if hash, ok := m.nodes[node]; ok {
+			result = append(result, AuditLogNode{Level: node.Level, Index: node.Index, Hash: hash})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) SaveLogNodes(ctx context.Context, nodes []AuditLogNode) error {
+	if m.nodes == nil {
+		m.nodes = make(map[protocol.LogNode]string)
+	}
+	for _, node := range nodes {
+		key := protocol.LogNode{Level: node.Level, Index: node.Index}
+		if _, ok := m.nodes[key]; !ok {
+			m.nodes[key] = node.Hash
+		}
+	}
+	return nil
+}
+
+func (m *mockRepo) ListLogBatches(ctx context.Context) ([]AuditBatch, error) {
+	var result []AuditBatch
+	for _, batch := range m.batches {
+		if batch.Scheme() == protocol.MerkleSchemeV2 {
+			result = append(result, batch)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].TreeSize < result[j].TreeSize })
+	return result, nil
+}
+
+// logLeaves returns the merkle.v2 leaves with their batches.
+func (m *mockRepo) logLeaves() map[AuditBatchLeaf]AuditBatch {
+	batches := make(map[string]AuditBatch)
+	for _, batch := range m.batches {
+		if batch.Scheme() == protocol.MerkleSchemeV2 {
+			batches[batch.ID] = batch
+		}
+	}
+	result := make(map[AuditBatchLeaf]AuditBatch)
+	for _, leaf := range m.leaves {
+		if batch, ok := batches[leaf.BatchID]; ok {
+			result[leaf] = batch
+		}
+	}
+	return result
+}
+
+func (m *mockRepo) ListUnloggedEntries(ctx context.Context, start time.Time, end time.Time, limit int) ([]AuditEntry, int64, error) {
+	logged := make(map[string]bool)
+	for leaf := range m.logLeaves() {
+		logged[leaf.EntryID] = true
+	}
+	var result []AuditEntry
+	for _, entry := range m.chain() {
+		if logged[entry.ID] || (!start.IsZero() && entry.Timestamp.Before(start)) || (!end.IsZero() && !entry.Timestamp.Before(end)) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	count := int64(len(result))
+	if limit <= 0 {
+		return nil, count, nil
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, count, nil
+}
+
+func (m *mockRepo) ListDuplicateLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error) {
+	leaves := m.logLeaves()
+	counts := make(map[string]int)
+	for leaf := range leaves {
+		counts[leaf.EntryID]++
+	}
+	var result []CoverageEntry
+	for leaf := range leaves {
+		if counts[leaf.EntryID] > 1 && len(result) < limit {
+			entry, _ := m.GetByID(ctx, types.ID(leaf.EntryID))
+			result = append(result, CoverageEntry{EntryID: leaf.EntryID, Timestamp: entry.Timestamp, BatchID: leaf.BatchID})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) ListMisplacedLogLeaves(ctx context.Context, limit int) ([]CoverageEntry, error) {
+	var result []CoverageEntry
+	for leaf, batch := range m.logLeaves() {
+		entry, _ := m.GetByID(ctx, types.ID(leaf.EntryID))
+		if entry == nil || len(result) >= limit {
+			continue
+		}
+		if entry.Timestamp.Before(batch.StartTime) || entry.Timestamp.After(batch.EndTime) {
+			result = append(result, CoverageEntry{EntryID: leaf.EntryID, Timestamp: entry.Timestamp, BatchID: leaf.BatchID})
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) ListBatchesToAnchor(ctx context.Context, now time.Time, limit int) ([]AuditBatch, error) {
+	var result []AuditBatch
+	for _, batch := range m.batches {
+		due := batch.AnchorNextAttemptAt == nil || !batch.AnchorNextAttemptAt.After(now)
+		if batch.AnchorStatus == AnchorSubmitted || (batch.AnchorStatus == AnchorPending && due) {
+			result = append(result, batch)
+		}
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) UpdateBatchAnchor(ctx context.Context, batch *AuditBatch) error {
+	for i := range m.batches {
+		if m.batches[i].ID == batch.ID {
+			m.batches[i] = *batch
+			return nil
+		}
+	}
+	return errors.New("batch not found")
+}
+
+func (m *mockRepo) ListAccess(ctx context.Context, subject string, start time.Time, end time.Time, limit int) ([]AuditEntry, error) {
+	var result []AuditEntry
+	subject = strings.ToLower(subject)
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		entry := m.entries[i]
+		if entry.Subject != subject || strings.EqualFold(entry.Actor, subject) || entry.Action == protocol.ActionAccessDeny {
+			continue
+		}
+		if (!start.IsZero() && entry.Timestamp.Before(start)) || (!end.IsZero() && entry.Timestamp.After(end)) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) ListAccessAfter(ctx context.Context, after *ChainCursor, start time.Time, limit int) ([]AuditEntry, error) {
+	var result []AuditEntry
+	for _, entry := range m.chain() {
+		if after != nil && !chainBefore(&AuditEntry{ID: after.ID, Timestamp: after.Timestamp}, &entry) {
+			continue
+		}
+		if !start.IsZero() && entry.Timestamp.Before(start) {
+			continue
+		}
+		if entry.Subject == "" || strings.EqualFold(entry.Actor, entry.Subject) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) SummarizeHashSchemes(ctx context.Context) ([]HashSchemeSummary, error) {
+	var summaries []HashSchemeSummary
+	index := make(map[string]int)
+	for _, entry := range m.entries {
+		scheme := entry.HashScheme()
+		i, ok := index[scheme]
+		if !ok {
+			i = len(summaries)
+			index[scheme] = i
+			summaries = append(summaries, HashSchemeSummary{Scheme: scheme, FirstEntryAt: entry.Timestamp})
+		}
+		summaries[i].EntryCount++
+		summaries[i].LastEntryAt = entry.Timestamp
+	}
+	return summaries, nil
+}
+
+func (m *mockRepo) chain() []AuditEntry {
+	sorted := append([]AuditEntry(nil), m.entries...)
+	sort.Slice(sorted, func(i, j int) bool { return chainBefore(&sorted[i], &sorted[j]) })
+	return sorted
+}
+
+func (m *mockRepo) ListChain(ctx context.Context, after *ChainCursor, start time.Time, end time.Time, limit int) ([]AuditEntry, error) {
+	var result []AuditEntry
+	for _, entry := range m.chain() {
+		if after != nil && !chainBefore(&AuditEntry{ID: after.ID, Timestamp: after.Timestamp}, &entry) {
+			continue
+		}
+		if !start.IsZero() && entry.Timestamp.Before(start) {
+			continue
+		}
+		if !end.IsZero() && entry.Timestamp.After(end) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) GetLastBefore(ctx context.Context, before time.Time) (*AuditEntry, error) {
+	var found *AuditEntry
+	for _, entry := range m.chain() {
+		if entry.Timestamp.Before(before) {
+			e := entry
+			found = &e
+		}
+	}
+	return found, nil
+}
+
+func (m *mockRepo) GetByHash(ctx context.Context, hash string) (*AuditEntry, error) {
+	for _, entry := range m.entries {
+		if entry.Hash == hash {
+			found := entry
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockRepo) GetLatestCheckpoint(ctx context.Context) (*AuditCheckpoint, error) {
+	if len(m.checkpoints) == 0 {
+		return nil, nil
+	}
+	latest := m.checkpoints[len(m.checkpoints)-1]
+	return &latest, nil
+}
+
+func (m *mockRepo) CreateCheckpoint(ctx context.Context, checkpoint *AuditCheckpoint) error {
+	checkpoint.ID = fmt.Sprintf("checkpoint-%d", len(m.checkpoints)+1)
+	m.checkpoints = append(m.checkpoints, *checkpoint)
+	return nil
+}
+
 func TestService_BuildMerkleTreeAndVerifyProof(t *testing.T) {
 	repo := &mockRepo{
 		entries: []AuditEntry{
@@ -119,16 +468,20 @@ func TestService_BuildMerkleTreeAndVerifyProof(t *testing.T) {
 	}
 	service := NewService(repo)
 
-	batch, tree, err := service.BuildMerkleTree(context.Background(), time.Time{}, time.Time{})
+	batch, err := service.BuildMerkleTree(context.Background(), time.Time{}, time.Time{})
 	if err != nil {
 		t.Fatalf("BuildMerkleTree() error = %v", err)
 	}
-	if batch == nil || tree == nil {
-		t.Fatal("expected batch and tree to be returned")
+	if batch == nil {
+		t.Fatal("expected batch to be returned")
 	}
 	if batch.EntryCount != 2 {
 		t.Fatalf("expected entry count 2, got %d", batch.EntryCount)
 	}
+	tree, err := protocol.BuildMerkleTree([]protocol.Entry{{Hash: repo.entries[0].Hash}, {Hash: repo.entries[1].Hash}})
+	if err != nil {
+		t.Fatalf("protocol.BuildMerkleTree() error = %v", err)
+	}
 	if batch.RootHash != tree.Root {
 		t.Fatalf("batch root mismatch: got %s want %s", batch.RootHash, tree.Root)
 	}
@@ -149,3 +502,485 @@ func TestService_BuildMerkleTreeAndVerifyProof(t *testing.T) {
 		t.Fatalf("GetMerkleRoot() mismatch: got %s want %s", root, tree.Root)
 	}
 }
+
+const testActor = "0x1234567890abcdef1234567890abcdef12345678"
+
+// legacyEntry appends an entry hashed the way Record did before audit.v2.
+func legacyEntry(repo *mockRepo, resourceID string, metadata common.JSONMap) {
+	previousHash := "GENESIS"
+	timestamp := time.Date(2025, 11, 1, 9, 0, 0, 0, time.UTC)
+	if len(repo.entries) > 0 {
+		last := repo.entries[len(repo.entries)-1]
+		previousHash = last.Hash
+		timestamp = last.Timestamp.Add(time.Second)
+	}
+	entry := protocol.Entry{
+		Actor:         testActor,
+		Action:        protocol.ActionUpload,
+		ResourceType:  protocol.ResourceFile,
+		ResourceID:    types.ID(resourceID),
+		Timestamp:     timestamp,
+		PreviousHash:  previousHash,
+		SchemaVersion: protocol.SchemaVersionAuditV1,
+	}
+	entry.SetHash()
+	repo.entries = append(repo.entries, AuditEntry{
+		ID:            fmt.Sprintf("legacy-%d", len(repo.entries)),
+		Actor:         testActor,
+		Action:        entry.Action,
+		ResourceType:  entry.ResourceType,
+		ResourceID:    resourceID,
+		Timestamp:     entry.Timestamp,
+		Metadata:      metadata,
+		Hash:          entry.Hash,
+		PreviousHash:  previousHash,
+		SchemaVersion: protocol.SchemaVersionAuditV1,
+	})
+}
+
+func TestService_VerifyIntegrity_CoversMetadata(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+
+	if err := service.Record(ctx, testActor, protocol.ActionConsentApprove, protocol.ResourceConsent, "grant-1", common.JSONMap{"grantee": "0xabc", "permissions": []string{"read"}}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := service.Record(ctx, testActor, protocol.ActionUpload, protocol.ResourceFile, "file-1", common.JSONMap{"fileName": "scan.pdf", "size": int64(2048)}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if got := repo.entries[0].SchemaVersion; got != protocol.SchemaVersionAuditV2 {
+		t.Fatalf("Record() schema version = %q, want %q", got, protocol.SchemaVersionAuditV2)
+	}
+
+	report, err := service.VerifyIntegrity(ctx, VerifyOptions{})
+	if err != nil || !report.Valid {
+		t.Fatalf("VerifyIntegrity() = %+v, %v; want valid", report, err)
+	}
+
+	repo.entries[0].Metadata["grantee"] = "0xdef"
+	report, _ = service.VerifyIntegrity(ctx, VerifyOptions{Full: true})
+	if report.Valid || report.Break.Kind != BreakHashMismatch {
+		t.Errorf("VerifyIntegrity() = %+v, want hash mismatch for tampered metadata", report)
+	}
+}
+
+func TestService_Record_Delegate(t *testing.T) {
+	repo := &mockRepo{}
+	service := NewService(repo)
+	metadata := common.JSONMap{"grantee": "0xabc"}
+
+	ctx := WithDelegate(context.Background(), "0xcaregiver", "delegation-1")
+	if err := service.Record(ctx, testActor, protocol.ActionConsentApprove, protocol.ResourceConsent, "grant-1", metadata); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	entry := repo.entries[0]
+	if entry.Actor != testActor || entry.Delegate() != "0xcaregiver" || entry.Metadata[MetadataDelegationID] != "delegation-1" {
+		t.Errorf("Record() = actor %s, metadata %v; want the patient as actor and the delegate in metadata", entry.Actor, entry.Metadata)
+	}
+	if _, ok := metadata[MetadataDelegate]; ok {
+		t.Error("Record() modified the caller's metadata")
+	}
+	if report, err := service.VerifyIntegrity(context.Background(), VerifyOptions{}); err != nil || !report.Valid {
+		t.Errorf("VerifyIntegrity() = %+v, %v; want valid", report, err)
+	}
+}
+
+func TestService_VerifyIntegrity_LegacyEntries(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+
+	legacyEntry(repo, "file-1", common.JSONMap{"fileName": "a.pdf"})
+	legacyEntry(repo, "file-2", common.JSONMap{"fileName": "b.pdf"})
+	if err := service.Record(ctx, testActor, protocol.ActionRead, protocol.ResourceEvent, "event-1", nil); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	report, err := service.VerifyIntegrity(ctx, VerifyOptions{})
+	if err != nil || !report.Valid {
+		t.Fatalf("VerifyIntegrity() over mixed schemes = %+v, %v; want valid", report, err)
+	}
+
+	schemes, err := service.GetHashSchemes(ctx)
+	if err != nil {
+		t.Fatalf("GetHashSchemes() error = %v", err)
+	}
+	if len(schemes) != 2 {
+		t.Fatalf("GetHashSchemes() returned %d schemes, want 2", len(schemes))
+	}
+	if schemes[0].Scheme != protocol.SchemaVersionAuditV1 || schemes[0].EntryCount != 2 || schemes[0].CoversMetadata {
+		t.Errorf("legacy summary = %+v", schemes[0])
+	}
+	if schemes[1].Scheme != protocol.SchemaVersionAuditV2 || schemes[1].EntryCount != 1 || !schemes[1].CoversMetadata {
+		t.Errorf("current summary = %+v", schemes[1])
+	}
+
+	// A legacy entry after the cutover is a downgrade, even if its own hash checks out.
+	legacyEntry(repo, "file-3", nil)
+	report, _ = service.VerifyIntegrity(ctx, VerifyOptions{Full: true})
+	if report.Valid || report.Break.Kind != BreakSchemeDowngrade {
+		t.Errorf("VerifyIntegrity() = %+v, want audit.v1 after audit.v2 rejected as a downgrade", report)
+	}
+}
+
+func recordEntries(t *testing.T, service Service, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := service.Record(context.Background(), testActor, protocol.ActionRead, protocol.ResourceEvent, fmt.Sprintf("event-%d", i), common.JSONMap{"i": i}); err != nil {
+			t.Fatalf("Record() error = %v", err)
+		}
+	}
+}
+
+func TestService_VerifyIntegrity_ResumesFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+
+	// More than one page, so the cursor is exercised.
+	recordEntries(t, service, verifyPageSize+25)
+
+	report, err := service.VerifyIntegrity(ctx, VerifyOptions{})
+	if err != nil || !report.Valid {
+		t.Fatalf("VerifyIntegrity() = %+v, %v; want valid", report, err)
+	}
+	if report.EntriesChecked != verifyPageSize+25 || report.ResumedFrom != "" {
+		t.Errorf("first run checked %d entries (resumed from %q)", report.EntriesChecked, report.ResumedFrom)
+	}
+	if len(repo.checkpoints) != 1 || repo.checkpoints[0].EntryHash != report.LastVerifiedHash {
+		t.Fatalf("checkpoints = %+v, want one at %s", repo.checkpoints, report.LastVerifiedHash)
+	}
+
+	recordEntries(t, service, 3)
+	report, err = service.VerifyIntegrity(ctx, VerifyOptions{})
+	if err != nil || !report.Valid {
+		t.Fatalf("VerifyIntegrity() = %+v, %v; want valid", report, err)
+	}
+	if report.EntriesChecked != 3 || report.ResumedFrom != "checkpoint-1" {
+		t.Errorf("incremental run checked %d entries (resumed from %q), want 3 from checkpoint-1", report.EntriesChecked, report.ResumedFrom)
+	}
+	if got := repo.checkpoints[len(repo.checkpoints)-1].EntriesVerified; got != verifyPageSize+28 {
+		t.Errorf("checkpoint covers %d entries, want %d", got, verifyPageSize+28)
+	}
+
+	// Tampering behind the checkpoint is only caught by a full run...
+	repo.entries[10].Metadata["i"] = 999
+	if report, _ := service.VerifyIntegrity(ctx, VerifyOptions{}); !report.Valid {
+		t.Errorf("incremental run should not re-read checkpointed entries, got %+v", report.Break)
+	}
+	report, _ = service.VerifyIntegrity(ctx, VerifyOptions{Full: true})
+	if report.Valid || report.Break.EntryID != repo.entries[10].ID {
+		t.Errorf("full run = %+v, want break at %s", report.Break, repo.entries[10].ID)
+	}
+
+	// ...unless it touches the checkpointed entry itself.
+	last := len(repo.entries) - 1
+	repo.entries[last].Metadata["i"] = 999
+	report, _ = service.VerifyIntegrity(ctx, VerifyOptions{})
+	if report.Valid || report.Break.Kind != BreakCheckpointMismatch {
+		t.Errorf("VerifyIntegrity() = %+v, want checkpoint mismatch", report.Break)
+	}
+}
+
+func TestService_VerifyIntegrity_ClassifiesBreaks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("chain gap", func(t *testing.T) {
+		repo := &mockRepo{}
+		service := NewService(repo)
+		recordEntries(t, service, 5)
+		before, deleted, after := repo.entries[1], repo.entries[2], repo.entries[3]
+		repo.entries = append(repo.entries[:2], repo.entries[3:]...)
+
+		report, _ := service.VerifyIntegrity(ctx, VerifyOptions{})
+		brk := report.Break
+		if report.Valid || brk.Kind != BreakChainGap {
+			t.Fatalf("VerifyIntegrity() = %+v, want chain gap", brk)
+		}
+		if brk.EntryID != after.ID || brk.PreviousHash != deleted.Hash || brk.ExpectedLink != before.Hash {
+			t.Errorf("break = %+v", brk)
+		}
+		if brk.AffectedRange.FromEntryID != before.ID || brk.AffectedRange.ToEntryID != after.ID {
+			t.Errorf("affected range = %+v, want %s..%s", brk.AffectedRange, before.ID, after.ID)
+		}
+		if report.EntriesChecked != 2 || report.LastVerifiedEntryID != before.ID {
+			t.Errorf("checked %d entries up to %s", report.EntriesChecked, report.LastVerifiedEntryID)
+		}
+		if len(repo.checkpoints) != 0 {
+			t.Error("a failed run should not record a checkpoint")
+		}
+	})
+
+	t.Run("missing genesis", func(t *testing.T) {
+		repo := &mockRepo{}
+		service := NewService(repo)
+		recordEntries(t, service, 3)
+		repo.entries = repo.entries[1:]
+
+		report, _ := service.VerifyIntegrity(ctx, VerifyOptions{})
+		if report.Valid || report.Break.Kind != BreakChainGap || report.Break.ExpectedLink != genesisHash {
+			t.Errorf("VerifyIntegrity() = %+v, want gap at genesis", report.Break)
+		}
+	})
+
+	t.Run("reordering", func(t *testing.T) {
+		repo := &mockRepo{}
+		service := NewService(repo)
+		recordEntries(t, service, 4)
+
+		// A second writer appends on top of a stale head: entry 4 links to entry 2.
+		forked := protocol.NewEntry(testActor, protocol.ActionRead, protocol.ResourceEvent, "event-x", repo.entries[2].Hash)
+		forked.Timestamp = repo.entries[3].Timestamp.Add(time.Second)
+		forked.SetHash()
+		repo.entries = append(repo.entries, AuditEntry{
+			ID:            "forked",
+			Actor:         testActor,
+			Action:        forked.Action,
+			ResourceType:  forked.ResourceType,
+			ResourceID:    "event-x",
+			Timestamp:     forked.Timestamp,
+			Hash:          forked.Hash,
+			PreviousHash:  forked.PreviousHash,
+			SchemaVersion: forked.SchemaVersion,
+		})
+
+		report, _ := service.VerifyIntegrity(ctx, VerifyOptions{})
+		brk := report.Break
+		if report.Valid || brk.Kind != BreakReordering || brk.EntryID != "forked" {
+			t.Fatalf("VerifyIntegrity() = %+v, want reordering at forked", brk)
+		}
+		if brk.AffectedRange.FromEntryID != repo.entries[2].ID || brk.AffectedRange.ToEntryID != "forked" {
+			t.Errorf("affected range = %+v", brk.AffectedRange)
+		}
+	})
+}
+
+func TestService_VerifyIntegrity_TimeWindow(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 6)
+
+	// An unrelated break outside the window is not reported.
+	repo.entries[0].Metadata["i"] = 999
+
+	from, to := repo.entries[2].Timestamp, repo.entries[4].Timestamp
+	report, err := service.VerifyIntegrity(ctx, VerifyOptions{From: from, To: to})
+	if err != nil || !report.Valid {
+		t.Fatalf("VerifyIntegrity() = %+v, %v; want valid window", report, err)
+	}
+	if report.EntriesChecked != 3 || report.LastVerifiedEntryID != repo.entries[4].ID {
+		t.Errorf("window checked %d entries up to %s", report.EntriesChecked, report.LastVerifiedEntryID)
+	}
+	if len(repo.checkpoints) != 0 {
+		t.Error("windowed runs should not record checkpoints")
+	}
+
+	// The first entry in the window is still linked to the entry before it.
+	repo.entries = append(repo.entries[:1], repo.entries[2:]...)
+	report, _ = service.VerifyIntegrity(ctx, VerifyOptions{From: from, To: to})
+	if report.Valid || report.Break.Kind != BreakChainGap {
+		t.Errorf("VerifyIntegrity() = %+v, want gap at window start", report.Break)
+	}
+}
+
+func TestService_GetEntryProof(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 5)
+
+	first, err := service.BuildMerkleTree(ctx, time.Time{}, repo.entries[2].Timestamp)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	second, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+
+	target := repo.entries[1]
+	proof, err := service.GetEntryProof(ctx, target.ID, "")
+	if err != nil {
+		t.Fatalf("GetEntryProof() error = %v", err)
+	}
+	if proof.BatchID != second.ID || proof.Root != second.RootHash || proof.LeafIndex != 1 || proof.LeafCount != 5 {
+		t.Errorf("GetEntryProof() = %+v, want latest batch %s", proof, second.ID)
+	}
+	if !protocol.VerifyProof(proof.Root, target.Hash, proof.Proof) {
+		t.Error("returned proof does not verify against the batch root")
+	}
+
+	pinned, err := service.GetEntryProof(ctx, target.ID, first.ID)
+	if err != nil {
+		t.Fatalf("GetEntryProof() with batch error = %v", err)
+	}
+	if pinned.Root != first.RootHash || !protocol.VerifyProof(first.RootHash, target.Hash, pinned.Proof) {
+		t.Errorf("GetEntryProof() for batch %s = %+v", first.ID, pinned)
+	}
+
+	if _, err := service.GetEntryProof(ctx, repo.entries[4].ID, first.ID); !errors.Is(err, ErrEntryNotBatched) {
+		t.Errorf("GetEntryProof() outside batch error = %v, want %v", err, ErrEntryNotBatched)
+	}
+	if _, err := service.GetEntryProof(ctx, "missing", ""); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("GetEntryProof() for missing entry error = %v, want %v", err, ErrEntryNotFound)
+	}
+
+	recordEntries(t, service, 1)
+	if _, err := service.GetEntryProof(ctx, repo.entries[5].ID, ""); !errors.Is(err, ErrEntryNotBatched) {
+		t.Errorf("GetEntryProof() for unbatched entry error = %v, want %v", err, ErrEntryNotBatched)
+	}
+
+	repo.nodes[protocol.LogNode{Level: 0, Index: 0}] = strings.Repeat("0", 64)
+	if _, err := service.GetEntryProof(ctx, target.ID, first.ID); !errors.Is(err, ErrBatchRootMismatch) {
+		t.Errorf("GetEntryProof() over a tampered sibling error = %v, want %v", err, ErrBatchRootMismatch)
+	}
+	repo.leaves[1].EntryHash = strings.Repeat("0", 64)
+	if _, err := service.GetEntryProof(ctx, target.ID, first.ID); !errors.Is(err, ErrBatchRootMismatch) {
+		t.Errorf("GetEntryProof() over a tampered leaf error = %v, want %v", err, ErrBatchRootMismatch)
+	}
+}
+
+func TestService_BatchLog_Consistency(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 5)
+
+	first, err := service.BuildMerkleTree(ctx, time.Time{}, repo.entries[2].Timestamp)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	// Overlapping the first window only appends the entries not yet logged.
+	second, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	if first.Scheme() != protocol.MerkleSchemeV2 || first.TreeSize != 3 || second.EntryCount != 2 || second.TreeSize != 5 {
+		t.Fatalf("batches = %+v, %+v; want log of 3 then 5 leaves", first, second)
+	}
+	if _, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{}); !errors.Is(err, ErrNoNewEntries) {
+		t.Errorf("BuildMerkleTree() with nothing new error = %v, want %v", err, ErrNoNewEntries)
+	}
+
+	consistency, err := service.GetBatchConsistency(ctx, first.ID, "")
+	if err != nil {
+		t.Fatalf("GetBatchConsistency() error = %v", err)
+	}
+	if consistency.ToBatchID != second.ID || consistency.Proof.OldSize != 3 || consistency.Proof.NewSize != 5 {
+		t.Errorf("GetBatchConsistency() = %+v", consistency)
+	}
+	if !service.VerifyConsistencyProof(first.RootHash, second.RootHash, consistency.Proof) {
+		t.Error("consistency proof does not verify between the batch roots")
+	}
+
+	if _, err := service.GetBatchConsistency(ctx, second.ID, first.ID); !errors.Is(err, ErrBatchOrder) {
+		t.Errorf("GetBatchConsistency() backwards error = %v, want %v", err, ErrBatchOrder)
+	}
+	if _, err := service.GetBatchConsistency(ctx, "missing", ""); !errors.Is(err, ErrBatchNotFound) {
+		t.Errorf("GetBatchConsistency() for missing batch error = %v, want %v", err, ErrBatchNotFound)
+	}
+
+	stale := &AuditBatch{RootHash: strings.Repeat("1", 64), MerkleScheme: protocol.MerkleSchemeV2, EntryCount: 1, TreeSize: 4}
+	if err := repo.CreateBatch(ctx, stale, nil, nil); !errors.Is(err, ErrLogHeadMoved) {
+		t.Errorf("CreateBatch() on a stale head error = %v, want %v", err, ErrLogHeadMoved)
+	}
+
+	// Rewriting a stored log node breaks every proof that covers it.
+	node := protocol.LogNode{Level: 1, Index: 0}
+	stored := repo.nodes[node]
+	repo.nodes[node] = strings.Repeat("0", 64)
+	if _, err := service.GetBatchConsistency(ctx, first.ID, second.ID); !errors.Is(err, ErrBatchRootMismatch) {
+		t.Errorf("GetBatchConsistency() over a tampered node error = %v, want %v", err, ErrBatchRootMismatch)
+	}
+	repo.nodes[node] = stored
+}
+
+func TestService_BatchLog_BackfillsNodes(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 5)
+
+	first, err := service.BuildMerkleTree(ctx, time.Time{}, repo.entries[2].Timestamp)
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	second, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+
+	// A log written before nodes were stored has only its leaves.
+	repo.nodes = nil
+	proof, err := service.GetEntryProof(ctx, repo.entries[1].ID, "")
+	if err != nil {
+		t.Fatalf("GetEntryProof() without stored nodes error = %v", err)
+	}
+	if proof.BatchID != second.ID || !service.VerifyMerkleProof(proof.Root, proof.EntryHash, proof.Proof) {
+		t.Errorf("GetEntryProof() = %+v, want a verifying proof against %s", proof, second.ID)
+	}
+	if len(repo.nodes) == 0 {
+		t.Fatal("GetEntryProof() did not backfill the log nodes")
+	}
+	if _, err := service.GetBatchConsistency(ctx, first.ID, second.ID); err != nil {
+		t.Errorf("GetBatchConsistency() after backfill error = %v", err)
+	}
+
+	recordEntries(t, service, 2)
+	third, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil || third.TreeSize != 7 {
+		t.Fatalf("BuildMerkleTree() after backfill = %+v, %v", third, err)
+	}
+
+	// Backfilling from rewritten leaves does not reproduce the head's root.
+	repo.nodes = nil
+	repo.leaves[1].EntryHash = strings.Repeat("0", 64)
+	if _, err := service.GetBatchConsistency(ctx, first.ID, ""); !errors.Is(err, ErrBatchRootMismatch) {
+		t.Errorf("GetBatchConsistency() over tampered leaves error = %v, want %v", err, ErrBatchRootMismatch)
+	}
+}
+
+func TestService_LegacyBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	service := NewService(repo)
+	recordEntries(t, service, 3)
+
+	// A batch written before Merkle schemes were versioned.
+	hashes := []string{repo.entries[0].Hash, repo.entries[1].Hash, repo.entries[2].Hash}
+	root, err := protocol.ComputeRootWithScheme(hashes, protocol.MerkleSchemeV1)
+	if err != nil {
+		t.Fatalf("ComputeRootWithScheme() error = %v", err)
+	}
+	legacy := &AuditBatch{RootHash: root, EntryCount: 3}
+	leaves := make([]AuditBatchLeaf, len(hashes))
+	for i := range hashes {
+		leaves[i] = AuditBatchLeaf{Position: i, EntryID: repo.entries[i].ID, EntryHash: hashes[i]}
+	}
+	if err := repo.CreateBatch(ctx, legacy, leaves, nil); err != nil {
+		t.Fatalf("CreateBatch() error = %v", err)
+	}
+
+	proof, err := service.GetEntryProof(ctx, repo.entries[2].ID, "")
+	if err != nil {
+		t.Fatalf("GetEntryProof() error = %v", err)
+	}
+	if proof.BatchID != legacy.ID || proof.Proof.Scheme != "" || !service.VerifyMerkleProof(root, repo.entries[2].Hash, proof.Proof) {
+		t.Errorf("legacy GetEntryProof() = %+v", proof)
+	}
+
+	// The legacy batch does not seed the log; the first v2 batch starts it from scratch.
+	batch, err := service.BuildMerkleTree(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("BuildMerkleTree() error = %v", err)
+	}
+	if batch.TreeSize != 3 || batch.RootHash == root {
+		t.Errorf("first log batch = %+v", batch)
+	}
+	if _, err := service.GetBatchConsistency(ctx, legacy.ID, batch.ID); !errors.Is(err, ErrBatchNotInLog) {
+		t.Errorf("GetBatchConsistency() from legacy batch error = %v, want %v", err, ErrBatchNotInLog)
+	}
+}
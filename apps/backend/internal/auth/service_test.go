@@ -5,44 +5,9 @@ import (
 	"testing"
 	"time"
 
-	internalAudit "github.com/itspablomontes/fleming/apps/backend/internal/audit"
-	"github.com/itspablomontes/fleming/apps/backend/internal/common"
-	"github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
 )
 
-type MockAuditService struct{}
-
-func (m *MockAuditService) Record(ctx context.Context, actor string, action audit.Action, resourceType audit.ResourceType, resourceID string, metadata common.JSONMap) error {
-	return nil
-}
-func (m *MockAuditService) GetLatestEntries(ctx context.Context, actor string, limit int) ([]internalAudit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) VerifyIntegrity(ctx context.Context) (bool, error) {
-	return true, nil
-}
-func (m *MockAuditService) BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*internalAudit.AuditBatch, *audit.MerkleTree, error) {
-	return nil, nil, nil
-}
-func (m *MockAuditService) GetMerkleRoot(ctx context.Context, batchID string) (string, error) {
-	return "", nil
-}
-func (m *MockAuditService) VerifyMerkleProof(root string, entryHash string, proof *audit.Proof) bool {
-	return true
-}
-func (m *MockAuditService) GetEntriesForMerkle(ctx context.Context, startTime time.Time, endTime time.Time) ([]internalAudit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) GetEntryByID(ctx context.Context, id string) (*internalAudit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) GetEntriesByResource(ctx context.Context, resourceID string) ([]internalAudit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) QueryEntries(ctx context.Context, filter audit.QueryFilter) ([]internalAudit.AuditEntry, error) {
-	return nil, nil
-}
-
 type MockRepo struct {
 	challenges map[string]*Challenge
 	users      map[string]*User
@@ -96,7 +61,7 @@ func (m *MockRepo) FindUser(ctx context.Context, address string) (*User, error)
 
 func TestService_GenerateChallenge(t *testing.T) {
 	repo := &MockRepo{}
-	auditSvc := &MockAuditService{}
+	auditSvc := &audittest.Service{}
 	svc := NewService(repo, "secret", auditSvc)
 
 	tests := []struct {
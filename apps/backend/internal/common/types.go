@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
@@ -50,6 +52,27 @@ func (c *JSONCodes) Scan(value any) error {
 	return json.Unmarshal(bytes, &c)
 }
 
+type JSONDataCategories []consent.DataCategory
+
+func (c JSONDataCategories) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	return json.Marshal(c)
+}
+
+func (c *JSONDataCategories) Scan(value any) error {
+	if value == nil {
+		*c = nil
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, &c)
+}
+
 type JSONStrings []string
 
 func (s JSONStrings) Value() (driver.Value, error) {
@@ -70,3 +93,21 @@ func (s *JSONStrings) Scan(value any) error {
 	}
 	return json.Unmarshal(bytes, &s)
 }
+
+type JSONTypedData crypto.TypedData
+
+func (d JSONTypedData) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+func (d *JSONTypedData) Scan(value any) error {
+	if value == nil {
+		*d = JSONTypedData{}
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return json.Unmarshal(bytes, &d)
+}
@@ -5,6 +5,8 @@ import (
 
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
 // ConsentGrant is the database model for patient-controlled access.
@@ -19,9 +21,114 @@ type ConsentGrant struct {
 	ExpiresAt   time.Time          `json:"expiresAt,omitempty" gorm:"index"`
 	CreatedAt   time.Time          `json:"createdAt"`
 	UpdatedAt   time.Time          `json:"updatedAt"`
+	// ExpiryNoticeAt is when the parties were told the grant expires soon.
+	ExpiryNoticeAt *time.Time `json:"expiryNoticeAt,omitempty"`
+	// ReceiptNonce is signed into the next consent receipt and advances with every
+	// signed change, so an old signature cannot be replayed.
+	ReceiptNonce uint64 `json:"receiptNonce" gorm:"not null;default:0"`
+	// ShareSyncPending is set with a state change the grantee's file key shares must
+	// follow and cleared once they have, so the sweeper can retry a failed sync.
+	ShareSyncPending bool `json:"-" gorm:"not null;default:false;index"`
+
+	// Purpose-bound terms; see consent.Terms.
+	Purpose       consent.Purpose           `json:"purpose,omitempty" gorm:"type:varchar(50)"`
+	Categories    common.JSONDataCategories `json:"categories,omitempty" gorm:"type:jsonb"`
+	StudyID       string                    `json:"studyId,omitempty" gorm:"index;type:varchar(255)"`
+	SecondaryUses common.JSONStrings        `json:"secondaryUses,omitempty" gorm:"type:jsonb"`
 }
 
 // TableName returns the custom table name for consent grants.
 func (ConsentGrant) TableName() string {
 	return "consent_grants"
 }
+
+// Terms returns the grant's purpose-bound terms.
+func (g *ConsentGrant) Terms() consent.Terms {
+	terms := consent.Terms{
+		Purpose:    g.Purpose,
+		Categories: consent.DataCategories(g.Categories),
+		StudyID:    g.StudyID,
+	}
+	for _, use := range g.SecondaryUses {
+		terms.SecondaryUses = append(terms.SecondaryUses, consent.SecondaryUse(use))
+	}
+	return terms
+}
+
+// ToProtocol converts the row to the protocol's grant.
+func (g *ConsentGrant) ToProtocol() *consent.Grant {
+	scope := make([]types.ID, len(g.Scope))
+	for i, entry := range g.Scope {
+		scope[i] = types.ID(entry)
+	}
+	permissions := make(consent.Permissions, len(g.Permissions))
+	for i, p := range g.Permissions {
+		permissions[i] = consent.Permission(p)
+	}
+	return &consent.Grant{
+		ID:            types.ID(g.ID),
+		Grantor:       types.WalletAddress(g.Grantor),
+		Grantee:       types.WalletAddress(g.Grantee),
+		Scope:         scope,
+		Permissions:   permissions,
+		State:         g.State,
+		ExpiresAt:     g.ExpiresAt,
+		Reason:        g.Reason,
+		Terms:         g.Terms(),
+		SchemaVersion: consent.SchemaVersionConsent,
+		CreatedAt:     g.CreatedAt,
+		UpdatedAt:     g.UpdatedAt,
+	}
+}
+
+// AllowsEvent reports whether the grant's scope and data categories cover an event.
+// Scope entries are event IDs or event types; an empty scope covers the whole timeline,
+// as do empty categories.
+func (g *ConsentGrant) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	scope := make([]types.ID, len(g.Scope))
+	for i, entry := range g.Scope {
+		scope[i] = types.ID(entry)
+	}
+	return consent.ScopeCovers(scope, types.ID(eventID), string(eventType)) &&
+		consent.DataCategories(g.Categories).Cover(eventType, codes)
+}
+
+// AccessGrants are the active grants through which one grantee holds a permission on
+// one grantor's data, newest first. Access is their union: an event is covered when
+// any grant's scope and categories cover it.
+type AccessGrants []ConsentGrant
+
+// ForUse keeps the grants whose terms permit an access declared as use.
+func (a AccessGrants) ForUse(use consent.Use) AccessGrants {
+	var permitted AccessGrants
+	for i := range a {
+		if a[i].Terms().Permits(use) {
+			permitted = append(permitted, a[i])
+		}
+	}
+	return permitted
+}
+
+// AllowsEvent reports whether any of the grants covers an event.
+func (a AccessGrants) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	return a.GrantFor(eventID, eventType, codes) != ""
+}
+
+// GrantFor returns the ID of the newest grant covering an event, or "" when none does.
+func (a AccessGrants) GrantFor(eventID string, eventType timeline.EventType, codes types.Codes) string {
+	for i := range a {
+		if a[i].AllowsEvent(eventID, eventType, codes) {
+			return a[i].ID
+		}
+	}
+	return ""
+}
+
+// IDs lists the grants' IDs, newest first.
+func (a AccessGrants) IDs() []string {
+	ids := make([]string, len(a))
+	for i := range a {
+		ids[i] = a[i].ID
+	}
+	return ids
+}
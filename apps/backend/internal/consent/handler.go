@@ -9,6 +9,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
 type Handler struct {
@@ -31,14 +32,35 @@ func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 		consentGroup.GET("/active", h.HandleGetActive)
 		consentGroup.GET("/grants", h.HandleGetMyGrants)
 		consentGroup.GET("/:id", h.HandleGetByID)
+		consentGroup.GET("/:id/receipt-data", h.HandleGetReceiptData)
+		consentGroup.GET("/:id/receipts", h.HandleGetReceipts)
 	}
 }
 
+// RegisterPublicRoutes registers the endpoints third parties call without a Fleming
+// account. rg should already be scoped to the consent prefix.
+func (h *Handler) RegisterPublicRoutes(rg *gin.RouterGroup) {
+	rg.POST("/receipts/verify", h.HandleVerifyReceipt)
+}
+
 type ConsentRequestDTO struct {
 	Grantor     string   `json:"grantor" binding:"required"`
 	Permissions []string `json:"permissions" binding:"required"`
 	Reason      string   `json:"reason"`
+	Scope       []string `json:"scope"`        // Optional: event IDs or event types; empty means the whole timeline
 	Duration    int      `json:"durationDays"` // Optional: how long access should last
+
+	// Optional purpose-bound terms; research requests must name their study.
+	Purpose       consent.Purpose        `json:"purpose"`
+	Categories    []consent.DataCategory `json:"categories"`
+	StudyID       string                 `json:"studyId"`
+	SecondaryUses []consent.SecondaryUse `json:"secondaryUses"`
+}
+
+// SignedActionDTO carries the acting party's EIP-712 signature over the grant's
+// receipt typed data; see HandleGetReceiptData.
+type SignedActionDTO struct {
+	Signature string `json:"signature" binding:"required"`
 }
 
 func getUserAddress(c *gin.Context) (string, bool) {
@@ -69,6 +91,25 @@ func filterGrantsByState(
 	return filtered
 }
 
+// writeTransitionError maps consent transition failures onto HTTP status codes.
+// Anything unexpected is reported as fallback, so storage errors are not exposed.
+func writeTransitionError(c *gin.Context, err error, fallback string) {
+	var transitionErr consent.TransitionError
+	var validationErr types.ValidationError
+	switch {
+	case errors.Is(err, ErrForbiddenActor), errors.Is(err, ErrInvalidSignature):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "consent not found"})
+	case errors.Is(err, ErrStaleReceipt):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &transitionErr), errors.As(err, &validationErr):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
 func (h *Handler) HandleRequest(c *gin.Context) {
 	address, _ := c.Get("user_address")
 	grantee := address.(string)
@@ -84,9 +125,15 @@ func (h *Handler) HandleRequest(c *gin.Context) {
 		expiresAt = time.Now().AddDate(0, 0, req.Duration)
 	}
 
-	grant, err := h.service.RequestConsent(c.Request.Context(), req.Grantor, grantee, req.Reason, req.Permissions, expiresAt)
+	terms := consent.Terms{
+		Purpose:       req.Purpose,
+		Categories:    req.Categories,
+		StudyID:       req.StudyID,
+		SecondaryUses: req.SecondaryUses,
+	}
+	grant, err := h.service.RequestConsent(c.Request.Context(), req.Grantor, grantee, req.Reason, req.Permissions, req.Scope, terms, expiresAt)
 	if err != nil {
-		if errors.Is(err, ErrInvalidPermission) {
+		if errors.Is(err, ErrInvalidPermission) || errors.Is(err, ErrInvalidTerms) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -98,45 +145,83 @@ func (h *Handler) HandleRequest(c *gin.Context) {
 }
 
 func (h *Handler) HandleApprove(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.service.ApproveConsent(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	var req SignedActionDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signature is required"})
+		return
+	}
+	receipt, err := h.service.ApproveConsent(c.Request.Context(), c.Param("id"), actor, req.Signature)
+	if err != nil {
+		writeTransitionError(c, err, "failed to approve consent")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "receipt": receipt.ToProtocol()})
 }
 
 func (h *Handler) HandleDeny(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.service.DenyConsent(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if err := h.service.DenyConsent(c.Request.Context(), c.Param("id"), actor); err != nil {
+		writeTransitionError(c, err, "failed to deny consent")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 func (h *Handler) HandleRevoke(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.service.RevokeConsent(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	var req SignedActionDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signature is required"})
+		return
+	}
+	receipt, err := h.service.RevokeConsent(c.Request.Context(), c.Param("id"), actor, req.Signature)
+	if err != nil {
+		writeTransitionError(c, err, "failed to revoke consent")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "receipt": receipt.ToProtocol()})
 }
 
 func (h *Handler) HandleSuspend(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.service.SuspendConsent(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"success": true})
+	var req SignedActionDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "signature is required"})
+		return
+	}
+	receipt, err := h.service.SuspendConsent(c.Request.Context(), c.Param("id"), actor, req.Signature)
+	if err != nil {
+		writeTransitionError(c, err, "failed to suspend consent")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "receipt": receipt.ToProtocol()})
 }
 
 func (h *Handler) HandleResume(c *gin.Context) {
-	id := c.Param("id")
-	if err := h.service.ResumeConsent(c.Request.Context(), id); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if err := h.service.ResumeConsent(c.Request.Context(), c.Param("id"), actor); err != nil {
+		writeTransitionError(c, err, "failed to resume consent")
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"success": true})
@@ -218,3 +303,67 @@ func (h *Handler) HandleGetByID(c *gin.Context) {
 
 	c.JSON(http.StatusOK, grant)
 }
+
+// HandleGetReceiptData returns the EIP-712 typed data the caller signs with their
+// wallet (eth_signTypedData_v4) to approve, revoke or suspend the grant.
+func (h *Handler) HandleGetReceiptData(c *gin.Context) {
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	action := consent.ReceiptAction(c.Query("action"))
+	if !action.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be approve, revoke or suspend"})
+		return
+	}
+
+	typedData, err := h.service.ReceiptTypedData(c.Request.Context(), c.Param("id"), actor, action)
+	if err != nil {
+		writeTransitionError(c, err, "failed to build consent receipt")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"typedData": typedData})
+}
+
+// HandleGetReceipts lists the signed receipts for a grant, for either party to keep
+// or hand to a third party.
+func (h *Handler) HandleGetReceipts(c *gin.Context) {
+	actor, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	receipts, err := h.service.GetReceipts(c.Request.Context(), c.Param("id"), actor)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenActor) || errors.Is(err, gorm.ErrRecordNotFound) {
+			writeTransitionError(c, err, "failed to fetch consent receipts")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch consent receipts"})
+		return
+	}
+
+	portable := make([]*consent.Receipt, len(receipts))
+	for i := range receipts {
+		portable[i] = receipts[i].ToProtocol()
+	}
+	c.JSON(http.StatusOK, gin.H{"receipts": portable})
+}
+
+// HandleVerifyReceipt checks a receipt a third party holds.
+func (h *Handler) HandleVerifyReceipt(c *gin.Context) {
+	var receipt consent.Receipt
+	if err := c.ShouldBindJSON(&receipt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid receipt"})
+		return
+	}
+
+	result, err := h.service.VerifyReceipt(c.Request.Context(), &receipt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification failed"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,66 @@
+package consent
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// ConsentReceipt is the database model for a signed change to a grant. It keeps the
+// typed data exactly as signed, so it can be handed to third parties as a
+// consent.Receipt and checked without Fleming.
+type ConsentReceipt struct {
+	ID                 string                `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	GrantID            string                `json:"grantId" gorm:"index;type:uuid;not null"`
+	Action             consent.ReceiptAction `json:"action" gorm:"type:varchar(20);not null"`
+	Signer             string                `json:"signer" gorm:"index;type:varchar(255);not null"`
+	OnBehalfOf         string                `json:"onBehalfOf,omitempty" gorm:"type:varchar(255)"` // Patient a delegate signed for
+	DelegationID       string                `json:"delegationId,omitempty" gorm:"type:varchar(255)"`
+	Nonce              uint64                `json:"nonce" gorm:"not null"`
+	TypedData          common.JSONTypedData  `json:"typedData" gorm:"type:jsonb;not null"`
+	Signature          string                `json:"signature" gorm:"uniqueIndex;type:text;not null"`
+	SignatureAlgorithm string                `json:"signatureAlgorithm" gorm:"type:varchar(20);not null"`
+	SignedAt           time.Time             `json:"signedAt"`
+	SchemaVersion      string                `json:"schemaVersion" gorm:"type:varchar(50)"`
+	CreatedAt          time.Time             `json:"createdAt"`
+}
+
+// TableName returns the custom table name for consent receipts.
+func (ConsentReceipt) TableName() string {
+	return "consent_receipts"
+}
+
+// ToProtocol converts the row to the portable receipt.
+func (r *ConsentReceipt) ToProtocol() *consent.Receipt {
+	return &consent.Receipt{
+		ID:                 types.ID(r.ID),
+		GrantID:            types.ID(r.GrantID),
+		Action:             r.Action,
+		Signer:             types.WalletAddress(r.Signer),
+		OnBehalfOf:         types.WalletAddress(r.OnBehalfOf),
+		DelegationID:       types.ID(r.DelegationID),
+		TypedData:          crypto.TypedData(r.TypedData),
+		Signature:          r.Signature,
+		SignatureAlgorithm: r.SignatureAlgorithm,
+		SignedAt:           r.SignedAt,
+		SchemaVersion:      r.SchemaVersion,
+	}
+}
+
+// ReceiptVerification is the outcome of checking a receipt a third party holds.
+// Recorded is set when Fleming holds the same signed receipt; the grant's current
+// state is only reported then.
+type ReceiptVerification struct {
+	Valid      bool                  `json:"valid"`
+	ReceiptID  string                `json:"receiptId,omitempty"`
+	GrantID    string                `json:"grantId,omitempty"`
+	Action     consent.ReceiptAction `json:"action,omitempty"`
+	Signer     string                `json:"signer,omitempty"`
+	Recorded   bool                  `json:"recorded"`
+	GrantState consent.State         `json:"grantState,omitempty"`
+	Errors     []string              `json:"errors,omitempty"`
+	CheckedAt  time.Time             `json:"checkedAt"`
+}
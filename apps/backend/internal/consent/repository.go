@@ -3,7 +3,9 @@ package consent
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
 	"gorm.io/gorm"
 )
 
@@ -14,7 +16,29 @@ type Repository interface {
 	GetByGrantee(ctx context.Context, grantee string) ([]ConsentGrant, error)
 	GetByGrantor(ctx context.Context, grantor string) ([]ConsentGrant, error)
 	Update(ctx context.Context, grant *ConsentGrant) error
-	FindLatest(ctx context.Context, grantor, grantee string) (*ConsentGrant, error)
+	FindApproved(ctx context.Context, grantor, grantee string) ([]ConsentGrant, error)
+	ListOverdue(ctx context.Context, now time.Time, limit int) ([]ConsentGrant, error)
+	ListExpiringUnnoticed(ctx context.Context, now time.Time, until time.Time, limit int) ([]ConsentGrant, error)
+	// SetState moves a grant to the given state, only if it is still in from at that
+	// receipt nonce, and marks its file shares for sync when they must follow.
+	SetState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error)
+	MarkExpiryNotice(ctx context.Context, id string, at time.Time) (bool, error)
+	// SetSignedState moves a grant to the given state and advances its receipt nonce,
+	// only if it is still in from with that nonce, and marks its file shares for sync
+	// when they must follow.
+	SetSignedState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error)
+	// ListShareSyncPending returns grants whose file shares have not followed their
+	// last state change, least recently updated first.
+	ListShareSyncPending(ctx context.Context, limit int) ([]ConsentGrant, error)
+	// ClearShareSyncPending clears the pending sync once the shares followed state,
+	// unless the grant has moved on since.
+	ClearShareSyncPending(ctx context.Context, id string, state consent.State) error
+
+	CreateReceipt(ctx context.Context, receipt *ConsentReceipt) error
+	ListReceipts(ctx context.Context, grantID string) ([]ConsentReceipt, error)
+	GetReceiptBySignature(ctx context.Context, signature string) (*ConsentReceipt, error)
+
+	Transaction(ctx context.Context, fn func(repo Repository) error) error
 }
 
 type gormRepository struct {
@@ -64,18 +88,156 @@ func (r *gormRepository) Update(ctx context.Context, grant *ConsentGrant) error
 	return nil
 }
 
-func (r *gormRepository) FindLatest(ctx context.Context, grantor, grantee string) (*ConsentGrant, error) {
-	var grant ConsentGrant
+// FindApproved returns every approved grant between the pair, newest first. Pending,
+// suspended and ended grants do not take part in access checks.
+func (r *gormRepository) FindApproved(ctx context.Context, grantor, grantee string) ([]ConsentGrant, error) {
+	var grants []ConsentGrant
 	err := r.db.WithContext(ctx).
-		Where("grantor = ? AND grantee = ?", grantor, grantee).
+		Where("grantor = ? AND grantee = ? AND state = ?", grantor, grantee, consent.StateApproved).
 		Order("created_at DESC").
-		Limit(1).
-		First(&grant).Error
+		Find(&grants).Error
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, nil
-		}
-		return nil, err
+		return nil, fmt.Errorf("find approved grants from %s to %s: %w", grantor, grantee, err)
 	}
-	return &grant, nil
+	return grants, nil
+}
+
+// ListOverdue returns approved and suspended grants whose expiry has passed, soonest
+// expiry first. Grants without an expiry are never overdue.
+func (r *gormRepository) ListOverdue(ctx context.Context, now time.Time, limit int) ([]ConsentGrant, error) {
+	var grants []ConsentGrant
+	query := r.db.WithContext(ctx).
+		Where("state IN ?", []consent.State{consent.StateApproved, consent.StateSuspended}).
+		Where("expires_at > ? AND expires_at <= ?", time.Time{}, now).
+		Order("expires_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("list overdue consent grants: %w", err)
+	}
+	return grants, nil
+}
+
+// ListExpiringUnnoticed returns approved grants expiring after now and no later than
+// until that have not had an expiry notice yet.
+func (r *gormRepository) ListExpiringUnnoticed(ctx context.Context, now time.Time, until time.Time, limit int) ([]ConsentGrant, error) {
+	var grants []ConsentGrant
+	query := r.db.WithContext(ctx).
+		Where("state = ? AND expiry_notice_at IS NULL", consent.StateApproved).
+		Where("expires_at > ? AND expires_at <= ?", now, until).
+		Order("expires_at ASC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("list expiring consent grants: %w", err)
+	}
+	return grants, nil
+}
+
+// SetState moves a grant to the given state only if it is still in from at the nonce
+// it was read with, so an unsigned change cannot overwrite a signed one made meanwhile.
+// It reports false when another writer changed the grant first.
+func (r *gormRepository) SetState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error) {
+	updates := map[string]any{"state": to, "updated_at": time.Now()}
+	if sharesFollow(from) {
+		updates["share_sync_pending"] = true
+	}
+	result := r.db.WithContext(ctx).Model(&ConsentGrant{}).
+		Where("id = ? AND state = ? AND receipt_nonce = ?", id, from, nonce).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("set consent grant %s state: %w", id, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkExpiryNotice records when the expiry notice was sent, unless one already was.
+// It reports false when another replica sent it first.
+func (r *gormRepository) MarkExpiryNotice(ctx context.Context, id string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&ConsentGrant{}).
+		Where("id = ? AND expiry_notice_at IS NULL", id).
+		Update("expiry_notice_at", at)
+	if result.Error != nil {
+		return false, fmt.Errorf("mark consent grant %s expiry notice: %w", id, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// SetSignedState moves a grant to the given state and advances its receipt nonce,
+// only if it is still in from with the nonce the receipt was signed over. It reports
+// false when another writer changed the grant first.
+func (r *gormRepository) SetSignedState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error) {
+	updates := map[string]any{"state": to, "receipt_nonce": nonce + 1, "updated_at": time.Now()}
+	if sharesFollow(from) {
+		updates["share_sync_pending"] = true
+	}
+	result := r.db.WithContext(ctx).Model(&ConsentGrant{}).
+		Where("id = ? AND state = ? AND receipt_nonce = ?", id, from, nonce).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("set consent grant %s state: %w", id, result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// sharesFollow reports whether the grantee's file shares must follow a change out of
+// from. Shares only ever follow an approved grant, so answering a request leaves them
+// alone; in particular an approval must not revive shares a revocation disabled.
+func sharesFollow(from consent.State) bool {
+	return from != consent.StateRequested
+}
+
+func (r *gormRepository) ListShareSyncPending(ctx context.Context, limit int) ([]ConsentGrant, error) {
+	var grants []ConsentGrant
+	err := r.db.WithContext(ctx).
+		Where("share_sync_pending = ?", true).
+		Order("updated_at ASC").
+		Limit(limit).
+		Find(&grants).Error
+	if err != nil {
+		return nil, fmt.Errorf("list consent grants pending share sync: %w", err)
+	}
+	return grants, nil
+}
+
+func (r *gormRepository) ClearShareSyncPending(ctx context.Context, id string, state consent.State) error {
+	err := r.db.WithContext(ctx).Model(&ConsentGrant{}).
+		Where("id = ? AND state = ?", id, state).
+		Update("share_sync_pending", false).Error
+	if err != nil {
+		return fmt.Errorf("clear consent grant %s share sync: %w", id, err)
+	}
+	return nil
+}
+
+func (r *gormRepository) CreateReceipt(ctx context.Context, receipt *ConsentReceipt) error {
+	if err := r.db.WithContext(ctx).Create(receipt).Error; err != nil {
+		return fmt.Errorf("create consent receipt: %w", err)
+	}
+	return nil
+}
+
+// ListReceipts returns a grant's receipts, oldest first.
+func (r *gormRepository) ListReceipts(ctx context.Context, grantID string) ([]ConsentReceipt, error) {
+	var receipts []ConsentReceipt
+	if err := r.db.WithContext(ctx).Where("grant_id = ?", grantID).Order("signed_at ASC").Find(&receipts).Error; err != nil {
+		return nil, fmt.Errorf("list receipts for consent grant %s: %w", grantID, err)
+	}
+	return receipts, nil
+}
+
+func (r *gormRepository) GetReceiptBySignature(ctx context.Context, signature string) (*ConsentReceipt, error) {
+	var receipt ConsentReceipt
+	if err := r.db.WithContext(ctx).First(&receipt, "signature = ?", signature).Error; err != nil {
+		return nil, fmt.Errorf("get consent receipt: %w", err)
+	}
+	return &receipt, nil
+}
+
+func (r *gormRepository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormRepository{db: tx})
+	})
 }
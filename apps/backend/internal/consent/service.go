@@ -5,165 +5,401 @@ import (
 	"errors"
 	"fmt"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
 	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
 )
 
 // ErrInvalidPermission is returned when a permission string is not read, write, or share.
 var ErrInvalidPermission = errors.New("invalid permission")
 
+// ErrInvalidTerms is returned when a request's purpose, study, data categories or
+// secondary uses fail consent.Terms validation.
+var ErrInvalidTerms = errors.New("invalid consent terms")
+
+// ErrForbiddenActor is wrapped by every error raised when the caller is not allowed
+// to move a grant into the requested state.
+var ErrForbiddenActor = errors.New("actor not allowed to change this consent")
+
+// ErrInvalidSignature is returned when a change that must be signed carries no
+// signature, or one that is not the acting party's over the grant's receipt.
+var ErrInvalidSignature = errors.New("invalid consent signature")
+
+// ErrStaleReceipt is returned when the grant changed after the receipt was fetched,
+// so the signature is over content that no longer applies, or after an unsigned
+// change read it.
+var ErrStaleReceipt = errors.New("consent changed since the receipt was signed")
+
+var (
+	// ErrNotGrantor is returned when an action reserved for the patient is attempted by someone else.
+	ErrNotGrantor = fmt.Errorf("%w: only the grantor may perform this action", ErrForbiddenActor)
+	// ErrNotParty is returned when the caller is neither the grantor nor the grantee.
+	ErrNotParty = fmt.Errorf("%w: only the grantor or grantee may perform this action", ErrForbiddenActor)
+)
+
+const (
+	roleGrantor = "grantor"
+	roleGrantee = "grantee"
+)
+
+func requireGrantor(grant *ConsentGrant, actor string) (string, error) {
+	if !strings.EqualFold(grant.Grantor, actor) {
+		return "", ErrNotGrantor
+	}
+	return roleGrantor, nil
+}
+
+func requireParty(grant *ConsentGrant, actor string) (string, error) {
+	switch {
+	case strings.EqualFold(grant.Grantor, actor):
+		return roleGrantor, nil
+	case strings.EqualFold(grant.Grantee, actor):
+		return roleGrantee, nil
+	default:
+		return "", ErrNotParty
+	}
+}
+
 // Service defines the business logic for patient consent.
 type Service interface {
-	RequestConsent(ctx context.Context, grantor, grantee, reason string, permissions []string, expiresAt time.Time) (*ConsentGrant, error)
-	ApproveConsent(ctx context.Context, grantID string) error
-	DenyConsent(ctx context.Context, grantID string) error
-	RevokeConsent(ctx context.Context, grantID string) error
-	SuspendConsent(ctx context.Context, grantID string) error
-	ResumeConsent(ctx context.Context, grantID string) error
+	RequestConsent(ctx context.Context, grantor, grantee, reason string, permissions []string, scope []string, terms consent.Terms, expiresAt time.Time) (*ConsentGrant, error)
+	// ReceiptTypedData returns the EIP-712 typed data actor must sign to take action
+	// on the grant.
+	ReceiptTypedData(ctx context.Context, grantID, actor string, action consent.ReceiptAction) (*crypto.TypedData, error)
+	// ApproveConsent, RevokeConsent and SuspendConsent require the acting party's
+	// signature over ReceiptTypedData and return the receipt they store.
+	ApproveConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error)
+	DenyConsent(ctx context.Context, grantID, actor string) error
+	RevokeConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error)
+	SuspendConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error)
+	ResumeConsent(ctx context.Context, grantID, actor string) error
+	GetReceipts(ctx context.Context, grantID, actor string) ([]ConsentReceipt, error)
+	// VerifyReceipt checks a receipt presented by a third party. Verification failures
+	// are reported in the result; the error is reserved for infrastructure problems.
+	VerifyReceipt(ctx context.Context, receipt *consent.Receipt) (*ReceiptVerification, error)
 	GetGrantByID(ctx context.Context, grantID string) (*ConsentGrant, error)
 	GetActiveGrants(ctx context.Context, grantee string) ([]ConsentGrant, error)
 	GetGrantsByGrantor(ctx context.Context, grantor string) ([]ConsentGrant, error)
-	CheckPermission(ctx context.Context, grantor, grantee string, permission string) (bool, error)
+	CheckPermission(ctx context.Context, grantor, grantee string, permission string, use consent.Use) (bool, error)
+	CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use consent.Use, eventID string, eventType timeline.EventType, codes types.Codes) (bool, error)
+	GetAccessGrants(ctx context.Context, grantor, grantee string, permission string) (AccessGrants, error)
+}
+
+// FileShares is the part of the timeline service that keeps encrypted file key
+// shares in line with consent. It is told the grantee's remaining active grants with
+// the patient whenever one of their grants stops or resumes granting access.
+type FileShares interface {
+	SyncFileAccess(ctx context.Context, patientID, grantee string, active []*consent.Grant, state consent.State) error
 }
 
 type service struct {
 	repo         Repository
 	auditService audit.Service
+	fileShares   FileShares
 }
 
-// NewService creates a new consent service.
-func NewService(repo Repository, auditService audit.Service) Service {
+// NewService creates a new consent service. fileShares may be nil, in which case
+// consent changes do not touch file key shares.
+func NewService(repo Repository, auditService audit.Service, fileShares FileShares) Service {
 	return &service{
 		repo:         repo,
 		auditService: auditService,
+		fileShares:   fileShares,
 	}
 }
 
-func (s *service) RequestConsent(ctx context.Context, grantor, grantee, reason string, permissions []string, expiresAt time.Time) (*ConsentGrant, error) {
-	for _, p := range permissions {
+func (s *service) RequestConsent(ctx context.Context, grantor, grantee, reason string, permissions []string, scope []string, terms consent.Terms, expiresAt time.Time) (*ConsentGrant, error) {
+	perms := make(consent.Permissions, len(permissions))
+	for i, p := range permissions {
 		if !consent.Permission(p).IsValid() {
 			return nil, fmt.Errorf("%w: %q (must be read, write, or share)", ErrInvalidPermission, p)
 		}
+		perms[i] = consent.Permission(p)
 	}
+	terms.StudyID = strings.TrimSpace(terms.StudyID)
+	if err := terms.Validate(perms); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTerms, err)
+	}
+
 	grant := &ConsentGrant{
 		Grantor:     grantor,
 		Grantee:     grantee,
 		Reason:      reason,
 		Permissions: permissions,
+		Scope:       scope,
 		State:       consent.StateRequested,
 		ExpiresAt:   expiresAt,
+		Purpose:     terms.Purpose,
+		Categories:  common.JSONDataCategories(terms.Categories),
+		StudyID:     terms.StudyID,
+	}
+	for _, use := range terms.SecondaryUses {
+		grant.SecondaryUses = append(grant.SecondaryUses, string(use))
 	}
 
 	if err := s.repo.Create(ctx, grant); err != nil {
 		return nil, err
 	}
 
-	metadata := common.JSONMap{
+	metadata := termsMetadata(grant, common.JSONMap{
 		"grantee":     grant.Grantee,
 		"permissions": grant.Permissions,
+		"scope":       grant.Scope,
 		"expiresAt":   grant.ExpiresAt,
-	}
+	})
 	_ = s.auditService.Record(ctx, grantor, protocol.ActionConsentRequest, protocol.ResourceConsent, grant.ID, metadata)
 	return grant, nil
 }
 
-func (s *service) ApproveConsent(ctx context.Context, grantID string) error {
+// signedActions are the changes that need a receipt, with the state they move the
+// grant to and who may make them.
+var signedActions = map[consent.ReceiptAction]struct {
+	to        consent.State
+	action    protocol.Action
+	authorize func(grant *ConsentGrant, actor string) (string, error)
+}{
+	consent.ReceiptApprove: {consent.StateApproved, protocol.ActionConsentApprove, requireGrantor},
+	consent.ReceiptRevoke:  {consent.StateRevoked, protocol.ActionConsentRevoke, requireParty},
+	consent.ReceiptSuspend: {consent.StateSuspended, protocol.ActionConsentSuspend, requireGrantor},
+}
+
+func (s *service) ReceiptTypedData(ctx context.Context, grantID, actor string, action consent.ReceiptAction) (*crypto.TypedData, error) {
+	signed, ok := signedActions[action]
+	if !ok {
+		return nil, types.NewValidationError("action", "action does not take a receipt")
+	}
 	grant, err := s.repo.GetByID(ctx, grantID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	if err := consent.TryTransition(grant.State, consent.StateApproved); err != nil {
-		return fmt.Errorf("invalid transition: %w", err)
+	if _, err := signed.authorize(grant, actor); err != nil {
+		return nil, err
+	}
+	if err := consent.TryTransition(grant.State, signed.to); err != nil {
+		return nil, fmt.Errorf("invalid transition: %w", err)
 	}
 
-	grant.State = consent.StateApproved
-	if err := s.repo.Update(ctx, grant); err != nil {
-		return err
+	typedData := consent.ReceiptTypedData(grant.ToProtocol(), action, grant.ReceiptNonce)
+	if _, err := typedData.Hash(); err != nil {
+		return nil, fmt.Errorf("build consent receipt: %w", err)
 	}
+	return typedData, nil
+}
 
-	_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentApprove, protocol.ResourceConsent, grant.ID, nil)
-	return nil
+func (s *service) ApproveConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error) {
+	return s.signedTransition(ctx, grantID, actor, signature, consent.ReceiptApprove)
 }
 
-func (s *service) DenyConsent(ctx context.Context, grantID string) error {
-	grant, err := s.repo.GetByID(ctx, grantID)
-	if err != nil {
-		return err
-	}
+func (s *service) DenyConsent(ctx context.Context, grantID, actor string) error {
+	return s.transition(ctx, grantID, actor, consent.StateDenied, protocol.ActionConsentDeny, requireGrantor)
+}
 
-	if err := consent.TryTransition(grant.State, consent.StateDenied); err != nil {
-		return fmt.Errorf("invalid transition: %w", err)
-	}
+// RevokeConsent may be called by either party: a patient withdrawing access or a
+// grantee relinquishing it. The audit entry records which of them acted.
+func (s *service) RevokeConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error) {
+	return s.signedTransition(ctx, grantID, actor, signature, consent.ReceiptRevoke)
+}
 
-	grant.State = consent.StateDenied
-	if err := s.repo.Update(ctx, grant); err != nil {
-		return err
-	}
+func (s *service) SuspendConsent(ctx context.Context, grantID, actor, signature string) (*ConsentReceipt, error) {
+	return s.signedTransition(ctx, grantID, actor, signature, consent.ReceiptSuspend)
+}
 
-	_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentDeny, protocol.ResourceConsent, grant.ID, nil)
-	return nil
+func (s *service) ResumeConsent(ctx context.Context, grantID, actor string) error {
+	return s.transition(ctx, grantID, actor, consent.StateApproved, protocol.ActionConsentResume, requireGrantor)
 }
 
-func (s *service) RevokeConsent(ctx context.Context, grantID string) error {
+// signedTransition is transition for changes that need a receipt. The signature must
+// be the actor's over the grant's receipt typed data at its current nonce; when a
+// delegate acts for the patient it must be the delegate's. The state change, nonce
+// advance and receipt commit in one transaction; the audit entry and file share sync
+// follow only once it has.
+func (s *service) signedTransition(ctx context.Context, grantID, actor, signature string, receiptAction consent.ReceiptAction) (*ConsentReceipt, error) {
+	signed := signedActions[receiptAction]
+
 	grant, err := s.repo.GetByID(ctx, grantID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if err := consent.TryTransition(grant.State, consent.StateRevoked); err != nil {
-		return fmt.Errorf("invalid transition: %w", err)
+	role, err := signed.authorize(grant, actor)
+	if err != nil {
+		return nil, err
 	}
 
-	grant.State = consent.StateRevoked
-	if err := s.repo.Update(ctx, grant); err != nil {
-		return err
+	from := grant.State
+	if err := consent.TryTransition(from, signed.to); err != nil {
+		return nil, fmt.Errorf("invalid transition: %w", err)
 	}
 
-	_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentRevoke, protocol.ResourceConsent, grant.ID, nil)
-	return nil
+	signer, onBehalfOf := actor, ""
+	delegate, delegationID, delegated := audit.DelegateFrom(ctx)
+	if delegated {
+		signer, onBehalfOf = delegate, actor
+	}
+	typedData := consent.ReceiptTypedData(grant.ToProtocol(), receiptAction, grant.ReceiptNonce)
+	if signature == "" || !crypto.VerifyTypedDataSignature(typedData, signature, signer) {
+		return nil, ErrInvalidSignature
+	}
+
+	receipt := &ConsentReceipt{
+		GrantID:            grant.ID,
+		Action:             receiptAction,
+		Signer:             strings.ToLower(signer),
+		OnBehalfOf:         strings.ToLower(onBehalfOf),
+		DelegationID:       delegationID,
+		Nonce:              grant.ReceiptNonce,
+		TypedData:          common.JSONTypedData(*typedData),
+		Signature:          signature,
+		SignatureAlgorithm: consent.SignatureAlgorithmEIP712,
+		SignedAt:           time.Now().UTC(),
+		SchemaVersion:      consent.SchemaVersionReceipt,
+	}
+	err = s.repo.Transaction(ctx, func(repo Repository) error {
+		changed, err := repo.SetSignedState(ctx, grant.ID, from, signed.to, grant.ReceiptNonce)
+		if err != nil {
+			return err
+		}
+		if !changed {
+			return ErrStaleReceipt
+		}
+		return repo.CreateReceipt(ctx, receipt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	grant.State = signed.to
+	grant.ReceiptNonce++
+
+	metadata := termsMetadata(grant, common.JSONMap{
+		"role":      role,
+		"grantor":   grant.Grantor,
+		"grantee":   grant.Grantee,
+		"receiptId": receipt.ID,
+		"signer":    receipt.Signer,
+	})
+	_ = s.auditService.Record(ctx, actor, signed.action, protocol.ResourceConsent, grant.ID, metadata)
+	if receiptAction != consent.ReceiptApprove {
+		syncFileShares(ctx, s.repo, s.fileShares, grant)
+	}
+	return receipt, nil
 }
 
-func (s *service) SuspendConsent(ctx context.Context, grantID string) error {
+// transition authorizes actor against the grant, moves it to the target state,
+// and records the change under the acting party. The change only applies if the
+// grant is still as read, so it cannot undo a signed change made meanwhile.
+func (s *service) transition(
+	ctx context.Context,
+	grantID, actor string,
+	to consent.State,
+	action protocol.Action,
+	authorize func(grant *ConsentGrant, actor string) (string, error),
+) error {
 	grant, err := s.repo.GetByID(ctx, grantID)
 	if err != nil {
 		return err
 	}
 
-	if err := consent.TryTransition(grant.State, consent.StateSuspended); err != nil {
+	role, err := authorize(grant, actor)
+	if err != nil {
+		return err
+	}
+
+	from := grant.State
+	if err := consent.TryTransition(from, to); err != nil {
 		return fmt.Errorf("invalid transition: %w", err)
 	}
 
-	grant.State = consent.StateSuspended
-	if err := s.repo.Update(ctx, grant); err != nil {
+	changed, err := s.repo.SetState(ctx, grant.ID, from, to, grant.ReceiptNonce)
+	if err != nil {
 		return err
 	}
-
-	_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentSuspend, protocol.ResourceConsent, grant.ID, nil)
+	if !changed {
+		return ErrStaleReceipt
+	}
+	grant.State = to
+
+	metadata := termsMetadata(grant, common.JSONMap{
+		"role":    role,
+		"grantor": grant.Grantor,
+		"grantee": grant.Grantee,
+	})
+	_ = s.auditService.Record(ctx, actor, action, protocol.ResourceConsent, grant.ID, metadata)
+	if to == consent.StateApproved {
+		// Resuming restores the shares the suspension disabled.
+		syncFileShares(ctx, s.repo, s.fileShares, grant)
+	}
 	return nil
 }
 
-func (s *service) ResumeConsent(ctx context.Context, grantID string) error {
+func (s *service) GetReceipts(ctx context.Context, grantID, actor string) ([]ConsentReceipt, error) {
 	grant, err := s.repo.GetByID(ctx, grantID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if _, err := requireParty(grant, actor); err != nil {
+		return nil, err
 	}
+	return s.repo.ListReceipts(ctx, grant.ID)
+}
 
-	if err := consent.TryTransition(grant.State, consent.StateApproved); err != nil {
-		return fmt.Errorf("invalid transition: %w", err)
+// VerifyReceipt checks the signature over the receipt's typed data and that the
+// signer may take its action: the grantor for any of them, or the grantee revoking.
+// A receipt a delegate signed for the patient is only accepted when Fleming holds it,
+// since only Fleming saw the delegation in force.
+func (s *service) VerifyReceipt(ctx context.Context, receipt *consent.Receipt) (*ReceiptVerification, error) {
+	result := &ReceiptVerification{
+		ReceiptID: receipt.ID.String(),
+		GrantID:   receipt.GrantID.String(),
+		Action:    receipt.Action,
+		Signer:    receipt.Signer.String(),
+		CheckedAt: time.Now().UTC(),
 	}
 
-	grant.State = consent.StateApproved
-	if err := s.repo.Update(ctx, grant); err != nil {
-		return err
+	if err := receipt.Validate(); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	} else if !receipt.Verify() {
+		result.Errors = append(result.Errors, "signature does not match the signer")
 	}
 
-	_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentResume, protocol.ResourceConsent, grant.ID, nil)
-	return nil
+	grantor, _ := receipt.TypedData.Message["grantor"].(string)
+	grantee, _ := receipt.TypedData.Message["grantee"].(string)
+	switch {
+	case !receipt.OnBehalfOf.IsEmpty():
+		if !strings.EqualFold(receipt.OnBehalfOf.String(), grantor) {
+			result.Errors = append(result.Errors, "receipt was signed on behalf of someone other than the grantor")
+		}
+	case strings.EqualFold(receipt.Signer.String(), grantor):
+	case strings.EqualFold(receipt.Signer.String(), grantee) && receipt.Action == consent.ReceiptRevoke:
+	default:
+		result.Errors = append(result.Errors, "signer may not take this action on the grant")
+	}
+
+	if receipt.Signature != "" {
+		stored, err := s.repo.GetReceiptBySignature(ctx, receipt.Signature)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if stored != nil && stored.GrantID == receipt.GrantID.String() && strings.EqualFold(stored.Signer, receipt.Signer.String()) {
+			result.Recorded = true
+			if grant, err := s.repo.GetByID(ctx, stored.GrantID); err == nil {
+				result.GrantState = grant.State
+			}
+		}
+	}
+	if !receipt.OnBehalfOf.IsEmpty() && !result.Recorded {
+		result.Errors = append(result.Errors, "delegated receipt is not on record")
+	}
+
+	result.Valid = len(result.Errors) == 0
+	return result, nil
 }
 
 func (s *service) GetGrantByID(ctx context.Context, grantID string) (*ConsentGrant, error) {
@@ -200,30 +436,73 @@ func (s *service) GetGrantsByGrantor(ctx context.Context, grantor string) ([]Con
 	return grants, nil
 }
 
-func (s *service) CheckPermission(ctx context.Context, grantor, grantee string, permission string) (bool, error) {
+// CheckPermission reports whether grantee holds permission on grantor's timeline as a
+// whole, under a grant whose terms permit use. Use CheckEventPermission when a specific
+// event is being accessed.
+func (s *service) CheckPermission(ctx context.Context, grantor, grantee string, permission string, use consent.Use) (bool, error) {
 	if grantor == grantee {
 		return true, nil
 	}
 
-	latest, err := s.repo.FindLatest(ctx, grantor, grantee)
+	grants, err := s.GetAccessGrants(ctx, grantor, grantee, permission)
 	if err != nil {
 		return false, err
 	}
+	return len(grants.ForUse(use)) > 0, nil
+}
+
+// CheckEventPermission reports whether grantee holds permission on a single event,
+// which must also fall within the scope and data categories of one of the grants that
+// give it and permit use.
+func (s *service) CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use consent.Use, eventID string, eventType timeline.EventType, codes types.Codes) (bool, error) {
+	if grantor == grantee {
+		return true, nil
+	}
 
-	if latest == nil || latest.State != consent.StateApproved {
-		return false, nil
+	grants, err := s.GetAccessGrants(ctx, grantor, grantee, permission)
+	if err != nil {
+		return false, err
 	}
+	return grants.ForUse(use).AllowsEvent(eventID, eventType, codes), nil
+}
 
-	if !latest.ExpiresAt.IsZero() && latest.ExpiresAt.Before(time.Now()) {
-		latest.State = consent.StateExpired
-		_ = s.repo.Update(ctx, latest)
-		_ = s.auditService.Record(ctx, latest.Grantor, protocol.ActionConsentExpire, protocol.ResourceConsent, latest.ID, nil)
-		return false, nil
+// GetAccessGrants returns every active grant that gives grantee permission on
+// grantor's data, newest first; it is empty when there is none. A pending request or
+// a newer grant with other permissions does not hide an older grant that still
+// applies. Grants found past their expiry are marked expired.
+func (s *service) GetAccessGrants(ctx context.Context, grantor, grantee string, permission string) (AccessGrants, error) {
+	approved, err := s.repo.FindApproved(ctx, grantor, grantee)
+	if err != nil {
+		return nil, err
 	}
 
-	if slices.Contains(latest.Permissions, permission) {
-		return true, nil
+	now := time.Now()
+	var grants AccessGrants
+	for i := range approved {
+		grant := &approved[i]
+		if !grant.ExpiresAt.IsZero() && grant.ExpiresAt.Before(now) {
+			_, _ = expireGrant(ctx, s.repo, s.auditService, s.fileShares, grant)
+			continue
+		}
+		if slices.Contains(grant.Permissions, permission) {
+			grants = append(grants, *grant)
+		}
 	}
+	return grants, nil
+}
 
-	return false, nil
+// termsMetadata adds a grant's purpose-bound terms to consent audit metadata.
+func termsMetadata(grant *ConsentGrant, metadata common.JSONMap) common.JSONMap {
+	terms := grant.Terms()
+	metadata["purpose"] = terms.EffectivePurpose()
+	if terms.StudyID != "" {
+		metadata["studyId"] = terms.StudyID
+	}
+	if len(terms.Categories) > 0 {
+		metadata["categories"] = terms.Categories
+	}
+	if len(terms.SecondaryUses) > 0 {
+		metadata["secondaryUses"] = terms.SecondaryUses
+	}
+	return metadata
 }
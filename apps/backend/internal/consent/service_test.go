@@ -0,0 +1,867 @@
+package consent
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocolcrypto "github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
+)
+
+type mockRepo struct {
+	nextID   int
+	grants   []ConsentGrant
+	receipts []ConsentReceipt
+
+	receiptErr error // Returned by CreateReceipt when set
+}
+
+func (m *mockRepo) Create(ctx context.Context, grant *ConsentGrant) error {
+	m.nextID++
+	grant.ID = fmt.Sprintf("grant-%d", m.nextID)
+	grant.CreatedAt = time.Now().Add(time.Duration(m.nextID) * time.Millisecond)
+	m.grants = append(m.grants, *grant)
+	return nil
+}
+
+func (m *mockRepo) GetByID(ctx context.Context, id string) (*ConsentGrant, error) {
+	for i := range m.grants {
+		if m.grants[i].ID == id {
+			grant := m.grants[i]
+			return &grant, nil
+		}
+	}
+	return nil, fmt.Errorf("get consent grant %s: not found", id)
+}
+
+func (m *mockRepo) GetByGrantee(ctx context.Context, grantee string) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if grant.Grantee == grantee {
+			result = append(result, grant)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) GetByGrantor(ctx context.Context, grantor string) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if grant.Grantor == grantor {
+			result = append(result, grant)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) Update(ctx context.Context, grant *ConsentGrant) error {
+	for i := range m.grants {
+		if m.grants[i].ID == grant.ID {
+			m.grants[i] = *grant
+			return nil
+		}
+	}
+	return fmt.Errorf("update consent grant %s: not found", grant.ID)
+}
+
+func (m *mockRepo) FindApproved(ctx context.Context, grantor, grantee string) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if grant.Grantor == grantor && grant.Grantee == grantee && grant.State == consent.StateApproved {
+			result = append(result, grant)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.After(result[j].CreatedAt) })
+	return result, nil
+}
+
+func (m *mockRepo) ListOverdue(ctx context.Context, now time.Time, limit int) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if (grant.State == consent.StateApproved || grant.State == consent.StateSuspended) &&
+			!grant.ExpiresAt.IsZero() && !grant.ExpiresAt.After(now) {
+			result = append(result, grant)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) ListExpiringUnnoticed(ctx context.Context, now time.Time, until time.Time, limit int) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if grant.State == consent.StateApproved && grant.ExpiryNoticeAt == nil &&
+			grant.ExpiresAt.After(now) && !grant.ExpiresAt.After(until) {
+			result = append(result, grant)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) SetState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error) {
+	for i := range m.grants {
+		if m.grants[i].ID == id && m.grants[i].State == from && m.grants[i].ReceiptNonce == nonce {
+			m.grants[i].State = to
+			m.grants[i].ShareSyncPending = m.grants[i].ShareSyncPending || sharesFollow(from)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRepo) MarkExpiryNotice(ctx context.Context, id string, at time.Time) (bool, error) {
+	for i := range m.grants {
+		if m.grants[i].ID == id && m.grants[i].ExpiryNoticeAt == nil {
+			m.grants[i].ExpiryNoticeAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRepo) SetSignedState(ctx context.Context, id string, from, to consent.State, nonce uint64) (bool, error) {
+	for i := range m.grants {
+		if m.grants[i].ID == id && m.grants[i].State == from && m.grants[i].ReceiptNonce == nonce {
+			m.grants[i].State = to
+			m.grants[i].ReceiptNonce = nonce + 1
+			m.grants[i].ShareSyncPending = m.grants[i].ShareSyncPending || sharesFollow(from)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *mockRepo) ListShareSyncPending(ctx context.Context, limit int) ([]ConsentGrant, error) {
+	var result []ConsentGrant
+	for _, grant := range m.grants {
+		if grant.ShareSyncPending && len(result) < limit {
+			result = append(result, grant)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) ClearShareSyncPending(ctx context.Context, id string, state consent.State) error {
+	for i := range m.grants {
+		if m.grants[i].ID == id && m.grants[i].State == state {
+			m.grants[i].ShareSyncPending = false
+		}
+	}
+	return nil
+}
+
+func (m *mockRepo) CreateReceipt(ctx context.Context, receipt *ConsentReceipt) error {
+	if m.receiptErr != nil {
+		return m.receiptErr
+	}
+	receipt.ID = fmt.Sprintf("receipt-%d", len(m.receipts)+1)
+	m.receipts = append(m.receipts, *receipt)
+	return nil
+}
+
+func (m *mockRepo) ListReceipts(ctx context.Context, grantID string) ([]ConsentReceipt, error) {
+	var result []ConsentReceipt
+	for _, receipt := range m.receipts {
+		if receipt.GrantID == grantID {
+			result = append(result, receipt)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) GetReceiptBySignature(ctx context.Context, signature string) (*ConsentReceipt, error) {
+	for i := range m.receipts {
+		if m.receipts[i].Signature == signature {
+			receipt := m.receipts[i]
+			return &receipt, nil
+		}
+	}
+	return nil, fmt.Errorf("get consent receipt: %w", gorm.ErrRecordNotFound)
+}
+
+// Transaction restores the grants and receipts when fn fails, like a rollback.
+func (m *mockRepo) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	grants, receipts := slices.Clone(m.grants), slices.Clone(m.receipts)
+	if err := fn(m); err != nil {
+		m.grants, m.receipts = grants, receipts
+		return err
+	}
+	return nil
+}
+
+// The test parties' addresses are derived from these keys, so they can sign receipts.
+var testKeys = map[string]*ecdsa.PrivateKey{
+	testGrantor:  mustKey("a11ce"),
+	testGrantee:  mustKey("b0b"),
+	testOutsider: mustKey("c4a7"),
+}
+
+const (
+	testGrantor  = "0xe05fcc23807536bee418f142d19fa0d21bb0cff7"
+	testGrantee  = "0x0376aac07ad725e01357b1725b5cec61ae10473c"
+	testOutsider = "0x54fdd9d0ec8d87eb040d4bb8e34fd2a20362e16f"
+)
+
+type fileShareSync struct {
+	patientID, grantee string
+	active             []string
+	state              consent.State
+}
+
+type mockFileShares struct {
+	syncs []fileShareSync
+	err   error // Returned by SyncFileAccess, without recording the sync, when set
+}
+
+func (m *mockFileShares) SyncFileAccess(ctx context.Context, patientID, grantee string, active []*consent.Grant, state consent.State) error {
+	if m.err != nil {
+		return m.err
+	}
+	ids := make([]string, len(active))
+	for i, grant := range active {
+		ids[i] = grant.ID.String()
+	}
+	m.syncs = append(m.syncs, fileShareSync{patientID: patientID, grantee: grantee, active: ids, state: state})
+	return nil
+}
+
+func mustKey(hex string) *ecdsa.PrivateKey {
+	key, err := crypto.HexToECDSA(fmt.Sprintf("%064s", hex))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// sign returns signer's signature over the receipt typed data the service hands actor,
+// or "" when the service refuses to build it.
+func sign(svc Service, ctx context.Context, grantID, actor, signer string, action consent.ReceiptAction) string {
+	typedData, err := svc.ReceiptTypedData(ctx, grantID, actor, action)
+	if err != nil {
+		return ""
+	}
+	signature, err := protocolcrypto.SignTypedData(typedData, testKeys[signer])
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// seedGrant stores a grant already in the given state, bypassing the service.
+func seedGrant(repo *mockRepo, state consent.State) *ConsentGrant {
+	grant := &ConsentGrant{
+		Grantor:     testGrantor,
+		Grantee:     testGrantee,
+		Permissions: common.JSONStrings{"read"},
+		State:       state,
+	}
+	_ = repo.Create(context.Background(), grant)
+	return grant
+}
+
+func TestService_Transitions_EnforceActorRole(t *testing.T) {
+	actions := map[string]struct {
+		run         func(svc Service, grantID, actor string) error
+		auditAction protocol.Action
+		granteeMay  bool
+	}{
+		"approve": {func(svc Service, id, actor string) error {
+			_, err := svc.ApproveConsent(context.Background(), id, actor, sign(svc, context.Background(), id, actor, actor, consent.ReceiptApprove))
+			return err
+		}, protocol.ActionConsentApprove, false},
+		"deny": {func(svc Service, id, actor string) error { return svc.DenyConsent(context.Background(), id, actor) }, protocol.ActionConsentDeny, false},
+		"revoke": {func(svc Service, id, actor string) error {
+			_, err := svc.RevokeConsent(context.Background(), id, actor, sign(svc, context.Background(), id, actor, actor, consent.ReceiptRevoke))
+			return err
+		}, protocol.ActionConsentRevoke, true},
+		"suspend": {func(svc Service, id, actor string) error {
+			_, err := svc.SuspendConsent(context.Background(), id, actor, sign(svc, context.Background(), id, actor, actor, consent.ReceiptSuspend))
+			return err
+		}, protocol.ActionConsentSuspend, false},
+		"resume": {func(svc Service, id, actor string) error { return svc.ResumeConsent(context.Background(), id, actor) }, protocol.ActionConsentResume, false},
+	}
+
+	for _, tr := range consent.ValidTransitions() {
+		if tr.Action == "expire" {
+			// Expiry is driven by the clock, not by either party; see TestService_GetAccessGrants_ExpiresLazily.
+			continue
+		}
+		action, ok := actions[tr.Action]
+		if !ok {
+			t.Fatalf("transition %s -> %s (%s) has no actor-authorized service method", tr.From, tr.To, tr.Action)
+		}
+
+		parties := []struct {
+			name    string
+			actor   string
+			allowed bool
+			wantErr error
+		}{
+			{"grantor", testGrantor, true, nil},
+			{"grantee", testGrantee, action.granteeMay, ErrNotGrantor},
+			{"outsider", testOutsider, false, ErrNotGrantor},
+		}
+		if action.granteeMay {
+			parties[2].wantErr = ErrNotParty
+		}
+
+		for _, party := range parties {
+			t.Run(fmt.Sprintf("%s_%s_to_%s_by_%s", tr.Action, tr.From, tr.To, party.name), func(t *testing.T) {
+				repo := &mockRepo{}
+				auditSvc := &audittest.Service{}
+				svc := NewService(repo, auditSvc, nil)
+				grant := seedGrant(repo, tr.From)
+
+				err := action.run(svc, grant.ID, party.actor)
+				stored, _ := repo.GetByID(context.Background(), grant.ID)
+
+				if !party.allowed {
+					if !errors.Is(err, party.wantErr) || !errors.Is(err, ErrForbiddenActor) {
+						t.Fatalf("error = %v, want %v", err, party.wantErr)
+					}
+					if stored.State != tr.From {
+						t.Fatalf("state = %s, want unchanged %s", stored.State, tr.From)
+					}
+					if len(auditSvc.Recorded) != 0 {
+						t.Fatalf("audit entries = %d, want none for a rejected transition", len(auditSvc.Recorded))
+					}
+					return
+				}
+
+				if err != nil {
+					t.Fatalf("error = %v, want nil", err)
+				}
+				if stored.State != tr.To {
+					t.Fatalf("state = %s, want %s", stored.State, tr.To)
+				}
+				entry := auditSvc.Last()
+				if entry.Actor != party.actor || entry.Action != action.auditAction {
+					t.Fatalf("audit = %s by %s, want %s by %s", entry.Action, entry.Actor, action.auditAction, party.actor)
+				}
+				if entry.Metadata["role"] != party.name {
+					t.Fatalf("audit role = %v, want %s", entry.Metadata["role"], party.name)
+				}
+			})
+		}
+	}
+}
+
+func TestService_Transitions_RejectInvalidStateForGrantor(t *testing.T) {
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+	grant := seedGrant(repo, consent.StateRevoked)
+
+	err := svc.ResumeConsent(context.Background(), grant.ID, testGrantor)
+	if err == nil || errors.Is(err, ErrForbiddenActor) {
+		t.Fatalf("error = %v, want an invalid transition error", err)
+	}
+}
+
+func TestService_SignedTransitions_RequireReceiptSignature(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	auditSvc := &audittest.Service{}
+	svc := NewService(repo, auditSvc, nil)
+	grant := seedGrant(repo, consent.StateRequested)
+
+	forged := sign(svc, ctx, grant.ID, testGrantor, testOutsider, consent.ReceiptApprove)
+	wrongAction := sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptSuspend)
+	for name, signature := range map[string]string{"missing": "", "forged": forged, "wrong action": wrongAction, "garbage": "0x1234"} {
+		if _, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("ApproveConsent() with %s signature error = %v, want %v", name, err, ErrInvalidSignature)
+		}
+	}
+	if stored, _ := repo.GetByID(ctx, grant.ID); stored.State != consent.StateRequested || len(repo.receipts) != 0 || len(auditSvc.Recorded) != 0 {
+		t.Fatalf("rejected approvals changed the grant: state = %s, receipts = %d", stored.State, len(repo.receipts))
+	}
+
+	approval := sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptApprove)
+	receipt, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, approval)
+	if err != nil {
+		t.Fatalf("ApproveConsent() error = %v", err)
+	}
+	if receipt.Signer != testGrantor || receipt.Nonce != 0 || !receipt.ToProtocol().Verify() {
+		t.Errorf("ApproveConsent() receipt = %+v, want a verifiable receipt by the grantor at nonce 0", receipt)
+	}
+	if entry := auditSvc.Last(); entry.Metadata["receiptId"] != receipt.ID || entry.Metadata["signer"] != testGrantor {
+		t.Errorf("approve metadata = %v, want the receipt and signer", entry.Metadata)
+	}
+
+	suspension := sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptSuspend)
+	if _, err := svc.SuspendConsent(ctx, grant.ID, testGrantor, suspension); err != nil {
+		t.Fatalf("SuspendConsent() error = %v", err)
+	}
+	if err := svc.ResumeConsent(ctx, grant.ID, testGrantor); err != nil {
+		t.Fatalf("ResumeConsent() error = %v", err)
+	}
+	if _, err := svc.SuspendConsent(ctx, grant.ID, testGrantor, suspension); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("SuspendConsent() replaying an earlier signature error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	receipts, err := svc.GetReceipts(ctx, grant.ID, testGrantee)
+	if err != nil {
+		t.Fatalf("GetReceipts() error = %v", err)
+	}
+	if len(receipts) != 2 || receipts[0].Action != consent.ReceiptApprove || receipts[1].Action != consent.ReceiptSuspend {
+		t.Errorf("GetReceipts() = %+v, want the approval then the suspension", receipts)
+	}
+	if _, err := svc.GetReceipts(ctx, grant.ID, testOutsider); !errors.Is(err, ErrNotParty) {
+		t.Errorf("GetReceipts() by outsider error = %v, want %v", err, ErrNotParty)
+	}
+}
+
+func TestService_SignedTransitions_RollBackWithoutReceipt(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	auditSvc := &audittest.Service{}
+	shares := &mockFileShares{}
+	svc := NewService(repo, auditSvc, shares)
+	grant := seedGrant(repo, consent.StateApproved)
+
+	revocation := sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptRevoke)
+	repo.receiptErr = errors.New("duplicate signature")
+	if _, err := svc.RevokeConsent(ctx, grant.ID, testGrantor, revocation); !errors.Is(err, repo.receiptErr) {
+		t.Fatalf("RevokeConsent() error = %v, want the receipt insert error", err)
+	}
+	stored, _ := repo.GetByID(ctx, grant.ID)
+	if stored.State != consent.StateApproved || stored.ReceiptNonce != grant.ReceiptNonce {
+		t.Errorf("grant = %s at nonce %d, want it unchanged without a receipt", stored.State, stored.ReceiptNonce)
+	}
+	if len(auditSvc.Recorded) != 0 || len(shares.syncs) != 0 {
+		t.Errorf("failed revocation recorded %d audit entries and %d share syncs, want none", len(auditSvc.Recorded), len(shares.syncs))
+	}
+
+	repo.receiptErr = nil
+	if _, err := svc.RevokeConsent(ctx, grant.ID, testGrantor, revocation); err != nil {
+		t.Fatalf("RevokeConsent() retry error = %v", err)
+	}
+}
+
+// staleRepo serves a snapshot of a grant, as read before another writer changed it.
+type staleRepo struct {
+	*mockRepo
+	stale ConsentGrant
+}
+
+func (r *staleRepo) GetByID(ctx context.Context, id string) (*ConsentGrant, error) {
+	grant := r.stale
+	return &grant, nil
+}
+
+func TestService_Transitions_DoNotOverwriteSignedChanges(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+	grant := seedGrant(repo, consent.StateApproved)
+
+	if _, err := svc.SuspendConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptSuspend)); err != nil {
+		t.Fatalf("SuspendConsent() error = %v", err)
+	}
+	suspended, _ := repo.GetByID(ctx, grant.ID)
+	if _, err := svc.RevokeConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptRevoke)); err != nil {
+		t.Fatalf("RevokeConsent() error = %v", err)
+	}
+	revoked, _ := repo.GetByID(ctx, grant.ID)
+
+	racing := NewService(&staleRepo{mockRepo: repo, stale: *suspended}, &audittest.Service{}, nil)
+	if err := racing.ResumeConsent(ctx, grant.ID, testGrantor); !errors.Is(err, ErrStaleReceipt) {
+		t.Fatalf("ResumeConsent() on a stale read error = %v, want %v", err, ErrStaleReceipt)
+	}
+	if stored, _ := repo.GetByID(ctx, grant.ID); stored.State != consent.StateRevoked || stored.ReceiptNonce != revoked.ReceiptNonce {
+		t.Errorf("grant = %s at nonce %d, want the signed revocation at nonce %d", stored.State, stored.ReceiptNonce, revoked.ReceiptNonce)
+	}
+}
+
+func TestService_SignedTransitions_DelegateSigns(t *testing.T) {
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+	grant := seedGrant(repo, consent.StateRequested)
+	ctx := audit.WithDelegate(context.Background(), testOutsider, "delegation-1")
+
+	if _, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptApprove)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("ApproveConsent() signed by the patient under a delegation error = %v, want %v", err, ErrInvalidSignature)
+	}
+	receipt, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testOutsider, consent.ReceiptApprove))
+	if err != nil {
+		t.Fatalf("ApproveConsent() error = %v", err)
+	}
+	if receipt.Signer != testOutsider || receipt.OnBehalfOf != testGrantor || receipt.DelegationID != "delegation-1" {
+		t.Errorf("receipt = %+v, want the delegate signing for the grantor", receipt)
+	}
+
+	verification, err := svc.VerifyReceipt(context.Background(), receipt.ToProtocol())
+	if err != nil {
+		t.Fatalf("VerifyReceipt() error = %v", err)
+	}
+	if !verification.Valid || !verification.Recorded {
+		t.Errorf("VerifyReceipt() = %+v, want a valid recorded receipt", verification)
+	}
+
+	repo.receipts = nil
+	if verification, _ := svc.VerifyReceipt(context.Background(), receipt.ToProtocol()); verification.Valid {
+		t.Error("VerifyReceipt() accepted a delegated receipt that is not on record")
+	}
+}
+
+func TestService_VerifyReceipt(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+	grant := seedGrant(repo, consent.StateRequested)
+
+	stored, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptApprove))
+	if err != nil {
+		t.Fatalf("ApproveConsent() error = %v", err)
+	}
+	receipt := stored.ToProtocol()
+
+	verification, err := svc.VerifyReceipt(ctx, receipt)
+	if err != nil {
+		t.Fatalf("VerifyReceipt() error = %v", err)
+	}
+	if !verification.Valid || !verification.Recorded || verification.GrantState != consent.StateApproved {
+		t.Errorf("VerifyReceipt() = %+v, want a valid recorded receipt on an approved grant", verification)
+	}
+
+	forged := *receipt
+	forged.Signer = testOutsider
+	if verification, _ := svc.VerifyReceipt(ctx, &forged); verification.Valid || verification.Recorded {
+		t.Errorf("VerifyReceipt() of a receipt naming another signer = %+v, want invalid", verification)
+	}
+
+	// A grantee may sign a revocation, but not an approval.
+	typedData := consent.ReceiptTypedData(grant.ToProtocol(), consent.ReceiptApprove, 0)
+	signature, err := protocolcrypto.SignTypedData(typedData, testKeys[testGrantee])
+	if err != nil {
+		t.Fatalf("SignTypedData() error = %v", err)
+	}
+	byGrantee := *receipt
+	byGrantee.Signer, byGrantee.TypedData, byGrantee.Signature = testGrantee, *typedData, signature
+	if verification, _ := svc.VerifyReceipt(ctx, &byGrantee); verification.Valid || verification.Recorded {
+		t.Errorf("VerifyReceipt() of an approval signed by the grantee = %+v, want invalid", verification)
+	}
+}
+
+func TestService_GetAccessGrants_ExpiresLazily(t *testing.T) {
+	repo := &mockRepo{}
+	auditSvc := &audittest.Service{}
+	svc := NewService(repo, auditSvc, nil)
+	grant := seedGrant(repo, consent.StateApproved)
+	grant.ExpiresAt = time.Now().Add(-time.Hour)
+	_ = repo.Update(context.Background(), grant)
+
+	got, err := svc.GetAccessGrants(context.Background(), testGrantor, testGrantee, "read")
+	if err != nil {
+		t.Fatalf("GetAccessGrants() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("GetAccessGrants() = %+v, want none for an expired grant", got)
+	}
+
+	stored, _ := repo.GetByID(context.Background(), grant.ID)
+	if stored.State != consent.StateExpired {
+		t.Fatalf("state = %s, want %s", stored.State, consent.StateExpired)
+	}
+	if auditSvc.Last().Action != protocol.ActionConsentExpire {
+		t.Fatalf("audit action = %s, want %s", auditSvc.Last().Action, protocol.ActionConsentExpire)
+	}
+}
+
+func TestService_ConsentChanges_SyncFileShares(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	shares := &mockFileShares{}
+	svc := NewService(repo, &audittest.Service{}, shares)
+	kept := seedGrant(repo, consent.StateApproved)
+	changed := seedGrant(repo, consent.StateApproved)
+
+	if _, err := svc.SuspendConsent(ctx, changed.ID, testGrantor, sign(svc, ctx, changed.ID, testGrantor, testGrantor, consent.ReceiptSuspend)); err != nil {
+		t.Fatalf("SuspendConsent() error = %v", err)
+	}
+	if err := svc.ResumeConsent(ctx, changed.ID, testGrantor); err != nil {
+		t.Fatalf("ResumeConsent() error = %v", err)
+	}
+	if _, err := svc.RevokeConsent(ctx, changed.ID, testGrantee, sign(svc, ctx, changed.ID, testGrantee, testGrantee, consent.ReceiptRevoke)); err != nil {
+		t.Fatalf("RevokeConsent() error = %v", err)
+	}
+	kept.ExpiresAt = time.Now().Add(-time.Hour)
+	_ = repo.Update(ctx, kept)
+	if _, err := svc.GetAccessGrants(ctx, testGrantor, testGrantee, "read"); err != nil {
+		t.Fatalf("GetAccessGrants() error = %v", err)
+	}
+
+	want := []fileShareSync{
+		{testGrantor, testGrantee, []string{kept.ID}, consent.StateSuspended},
+		{testGrantor, testGrantee, []string{changed.ID, kept.ID}, consent.StateApproved},
+		{testGrantor, testGrantee, []string{kept.ID}, consent.StateRevoked},
+		{testGrantor, testGrantee, []string{}, consent.StateExpired},
+	}
+	if len(shares.syncs) != len(want) {
+		t.Fatalf("SyncFileAccess() called %d times, want %d: %+v", len(shares.syncs), len(want), shares.syncs)
+	}
+	for i := range want {
+		if got := shares.syncs[i]; got.patientID != want[i].patientID || got.grantee != want[i].grantee ||
+			got.state != want[i].state || !slices.Equal(got.active, want[i].active) {
+			t.Errorf("sync %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+
+	approvals := len(shares.syncs)
+	pending := seedGrant(repo, consent.StateRequested)
+	if _, err := svc.ApproveConsent(ctx, pending.ID, testGrantor, sign(svc, ctx, pending.ID, testGrantor, testGrantor, consent.ReceiptApprove)); err != nil {
+		t.Fatalf("ApproveConsent() error = %v", err)
+	}
+	if len(shares.syncs) != approvals {
+		t.Error("ApproveConsent() synced file shares; a new approval must not revive shares a revocation disabled")
+	}
+}
+
+func TestService_GetAccessGrants_UnionsActiveGrants(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+
+	seed := func(state consent.State, permissions []string, scope []string) *ConsentGrant {
+		grant := seedGrant(repo, state)
+		grant.Permissions = permissions
+		grant.Scope = scope
+		_ = repo.Update(ctx, grant)
+		return grant
+	}
+	labs := seed(consent.StateApproved, []string{"read"}, []string{string(timeline.EventLabResult)})
+	seed(consent.StateRequested, []string{"read", "write"}, nil) // A newer request must not hide labs
+	notes := seed(consent.StateApproved, []string{"read"}, []string{string(timeline.EventConsultation)})
+
+	grants, err := svc.GetAccessGrants(ctx, testGrantor, testGrantee, "read")
+	if err != nil {
+		t.Fatalf("GetAccessGrants() error = %v", err)
+	}
+	if len(grants) != 2 || grants[0].ID != notes.ID || grants[1].ID != labs.ID {
+		t.Fatalf("GetAccessGrants() = %v, want [%s %s]", grants.IDs(), notes.ID, labs.ID)
+	}
+	if got := grants.GrantFor("evt-1", timeline.EventLabResult, nil); got != labs.ID {
+		t.Errorf("GrantFor(lab result) = %q, want %q", got, labs.ID)
+	}
+	if got := grants.GrantFor("evt-2", timeline.EventDiagnosis, nil); got != "" {
+		t.Errorf("GrantFor(diagnosis) = %q, want none", got)
+	}
+
+	for _, tc := range []struct {
+		eventType timeline.EventType
+		want      bool
+	}{
+		{timeline.EventLabResult, true},
+		{timeline.EventConsultation, true},
+		{timeline.EventDiagnosis, false},
+	} {
+		allowed, err := svc.CheckEventPermission(ctx, testGrantor, testGrantee, "read", consent.Use{}, "evt-1", tc.eventType, nil)
+		if err != nil || allowed != tc.want {
+			t.Errorf("CheckEventPermission(%s) = %v, %v, want %v", tc.eventType, allowed, err, tc.want)
+		}
+	}
+	if allowed, _ := svc.CheckPermission(ctx, testGrantor, testGrantee, "write", consent.Use{}); allowed {
+		t.Error("CheckPermission(write) = true, want false while the write request is pending")
+	}
+}
+
+func TestService_RequestConsent_ValidatesTerms(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	auditSvc := &audittest.Service{}
+	svc := NewService(repo, auditSvc, nil)
+
+	research := consent.Terms{Purpose: consent.PurposeResearch}
+	if _, err := svc.RequestConsent(ctx, testGrantor, testGrantee, "", []string{"read"}, nil, research, time.Time{}); !errors.Is(err, ErrInvalidTerms) {
+		t.Fatalf("RequestConsent() without a study error = %v, want ErrInvalidTerms", err)
+	}
+	research.StudyID = " NCT01234567 "
+	if _, err := svc.RequestConsent(ctx, testGrantor, testGrantee, "", []string{"read", "write"}, nil, research, time.Time{}); !errors.Is(err, ErrInvalidTerms) {
+		t.Fatalf("RequestConsent() for research with write error = %v, want ErrInvalidTerms", err)
+	}
+
+	research.Categories = consent.DataCategories{{System: types.CodingICD10, CodePrefix: "E11"}}
+	research.SecondaryUses = []consent.SecondaryUse{consent.SecondaryUsePublication}
+	grant, err := svc.RequestConsent(ctx, testGrantor, testGrantee, "", []string{"read"}, nil, research, time.Time{})
+	if err != nil {
+		t.Fatalf("RequestConsent() error = %v", err)
+	}
+	if grant.Purpose != consent.PurposeResearch || grant.StudyID != "NCT01234567" || len(grant.Categories) != 1 || len(grant.SecondaryUses) != 1 {
+		t.Fatalf("RequestConsent() = %+v", grant)
+	}
+
+	metadata := auditSvc.Last().Metadata
+	if metadata["purpose"] != consent.PurposeResearch || metadata["studyId"] != "NCT01234567" || metadata["categories"] == nil || metadata["secondaryUses"] == nil {
+		t.Errorf("request metadata = %v, want the grant's terms", metadata)
+	}
+	if _, err := svc.ApproveConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptApprove)); err != nil {
+		t.Fatalf("ApproveConsent() error = %v", err)
+	}
+	if metadata := auditSvc.Last().Metadata; metadata["purpose"] != consent.PurposeResearch || metadata["studyId"] != "NCT01234567" {
+		t.Errorf("approve metadata = %v, want the grant's terms", metadata)
+	}
+}
+
+func TestService_CheckEventPermission_EnforcesTerms(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	svc := NewService(repo, &audittest.Service{}, nil)
+
+	grant := seedGrant(repo, consent.StateApproved)
+	grant.Purpose = consent.PurposeResearch
+	grant.StudyID = "NCT01234567"
+	grant.Categories = common.JSONDataCategories{{EventType: timeline.EventDiagnosis, System: types.CodingICD10, CodePrefix: "E11"}}
+	grant.SecondaryUses = common.JSONStrings{string(consent.SecondaryUseAggregateAnalysis)}
+	_ = repo.Update(ctx, grant)
+
+	study := consent.Use{Purpose: consent.PurposeResearch, StudyID: "NCT01234567"}
+	diabetes := types.Codes{{System: types.CodingICD10, Value: "E11.9"}}
+	asthma := types.Codes{{System: types.CodingICD10, Value: "J45.909"}}
+
+	tests := []struct {
+		name  string
+		use   consent.Use
+		codes types.Codes
+		want  bool
+	}{
+		{"study diagnosis in category", study, diabetes, true},
+		{"study diagnosis outside category", study, asthma, false},
+		{"undeclared use", consent.Use{}, diabetes, false},
+		{"another study", consent.Use{Purpose: consent.PurposeResearch, StudyID: "NCT07654321"}, diabetes, false},
+		{"treatment", consent.Use{Purpose: consent.PurposeTreatment}, diabetes, false},
+		{"permitted secondary use", consent.Use{StudyID: "NCT01234567", SecondaryUse: consent.SecondaryUseAggregateAnalysis}, diabetes, true},
+		{"unpermitted secondary use", consent.Use{StudyID: "NCT01234567", SecondaryUse: consent.SecondaryUseModelTraining}, diabetes, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed, err := svc.CheckEventPermission(ctx, testGrantor, testGrantee, "read", tt.use, "evt-1", timeline.EventDiagnosis, tt.codes)
+			if err != nil || allowed != tt.want {
+				t.Errorf("CheckEventPermission() = %v, %v, want %v", allowed, err, tt.want)
+			}
+		})
+	}
+
+	if allowed, _ := svc.CheckPermission(ctx, testGrantor, testGrantee, "read", study); !allowed {
+		t.Error("CheckPermission() for the study = false, want true")
+	}
+	if allowed, _ := svc.CheckPermission(ctx, testGrantor, testGrantee, "read", consent.Use{}); allowed {
+		t.Error("CheckPermission() without declaring the study = true, want false")
+	}
+}
+
+func TestSweeper_ExpiresAndNotifies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	repo := &mockRepo{}
+	auditSvc := &audittest.Service{}
+
+	seed := func(state consent.State, expiresAt time.Time) *ConsentGrant {
+		grant := seedGrant(repo, state)
+		grant.ExpiresAt = expiresAt
+		_ = repo.Update(ctx, grant)
+		return grant
+	}
+	overdueApproved := seed(consent.StateApproved, now.Add(-time.Hour))
+	overdueSuspended := seed(consent.StateSuspended, now.Add(-time.Minute))
+	overdueRevoked := seed(consent.StateRevoked, now.Add(-time.Hour))
+	soon := seed(consent.StateApproved, now.Add(48*time.Hour))
+	later := seed(consent.StateApproved, now.Add(30*24*time.Hour))
+	unbounded := seed(consent.StateApproved, time.Time{})
+
+	sw := NewSweeper(repo, auditSvc, nil, SweepOptions{NoticeWindow: 7 * 24 * time.Hour}).(*sweeper)
+	sw.now = func() time.Time { return now }
+
+	result, err := sw.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if len(result.Expired) != 2 || len(result.Notified) != 1 || result.Notified[0] != soon.ID {
+		t.Fatalf("Sweep() = %+v, want 2 expired and a notice for %s", result, soon.ID)
+	}
+
+	wantStates := map[string]consent.State{
+		overdueApproved.ID:  consent.StateExpired,
+		overdueSuspended.ID: consent.StateExpired,
+		overdueRevoked.ID:   consent.StateRevoked,
+		soon.ID:             consent.StateApproved,
+		later.ID:            consent.StateApproved,
+		unbounded.ID:        consent.StateApproved,
+	}
+	for id, want := range wantStates {
+		if stored, _ := repo.GetByID(ctx, id); stored.State != want {
+			t.Errorf("grant %s state = %s, want %s", id, stored.State, want)
+		}
+	}
+
+	var expires, notices int
+	for _, entry := range auditSvc.Recorded {
+		switch entry.Action {
+		case protocol.ActionConsentExpire:
+			expires++
+			if entry.Actor != testGrantor || entry.Metadata["grantee"] != testGrantee {
+				t.Errorf("consent.expire entry = %+v", entry)
+			}
+		case protocol.ActionConsentExpiring:
+			notices++
+		}
+	}
+	if expires != 2 || notices != 1 {
+		t.Errorf("recorded %d expire and %d expiring entries, want 2 and 1", expires, notices)
+	}
+
+	// A second sweep finds nothing left to do.
+	result, err = sw.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if len(result.Expired) != 0 || len(result.Notified) != 0 {
+		t.Errorf("second Sweep() = %+v, want no changes", result)
+	}
+}
+
+func TestSweeper_RetriesFailedShareSync(t *testing.T) {
+	ctx := context.Background()
+	repo := &mockRepo{}
+	shares := &mockFileShares{err: errors.New("storage unavailable")}
+	svc := NewService(repo, &audittest.Service{}, shares)
+	grant := seedGrant(repo, consent.StateApproved)
+
+	if _, err := svc.RevokeConsent(ctx, grant.ID, testGrantor, sign(svc, ctx, grant.ID, testGrantor, testGrantor, consent.ReceiptRevoke)); err != nil {
+		t.Fatalf("RevokeConsent() error = %v", err)
+	}
+	if stored, _ := repo.GetByID(ctx, grant.ID); stored.State != consent.StateRevoked || !stored.ShareSyncPending {
+		t.Fatalf("grant = %s, pending sync %t, want revoked with the failed sync pending", stored.State, stored.ShareSyncPending)
+	}
+
+	sw := NewSweeper(repo, &audittest.Service{}, shares, SweepOptions{})
+	if result, err := sw.Sweep(ctx); err != nil || len(result.Resynced) != 0 {
+		t.Fatalf("Sweep() = %+v, %v, want nothing resynced while the sync fails", result, err)
+	}
+
+	shares.err = nil
+	result, err := sw.Sweep(ctx)
+	if err != nil {
+		t.Fatalf("Sweep() error = %v", err)
+	}
+	if !slices.Equal(result.Resynced, []string{grant.ID}) {
+		t.Fatalf("Sweep() resynced %v, want [%s]", result.Resynced, grant.ID)
+	}
+	if len(shares.syncs) != 1 || shares.syncs[0].state != consent.StateRevoked {
+		t.Errorf("SyncFileAccess() calls = %+v, want one for the revocation", shares.syncs)
+	}
+	if result, _ := sw.Sweep(ctx); len(result.Resynced) != 0 {
+		t.Errorf("Sweep() resynced %v again after the sync succeeded", result.Resynced)
+	}
+}
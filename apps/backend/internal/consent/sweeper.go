@@ -0,0 +1,208 @@
+package consent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+)
+
+// sweepPageSize is how many grants are read per query.
+const sweepPageSize = 200
+
+// SweepOptions configures the expiry sweeper.
+type SweepOptions struct {
+	NoticeWindow time.Duration // How long before ExpiresAt the parties are told; zero disables notices
+}
+
+// DefaultSweepOptions returns the options used when none are configured.
+func DefaultSweepOptions() SweepOptions {
+	return SweepOptions{NoticeWindow: 7 * 24 * time.Hour}
+}
+
+// SweepResult lists the grants a sweep changed.
+type SweepResult struct {
+	Expired  []string `json:"expired"`
+	Notified []string `json:"notified"`
+	Resynced []string `json:"resynced"` // Grants whose earlier file share sync failed
+}
+
+// Sweeper expires overdue grants on a schedule instead of waiting for the next access
+// check, and warns both parties ahead of expiry.
+type Sweeper interface {
+	Sweep(ctx context.Context) (*SweepResult, error)
+	Start(ctx context.Context, interval time.Duration)
+}
+
+type sweeper struct {
+	repo         Repository
+	auditService audit.Service
+	fileShares   FileShares
+	opts         SweepOptions
+	now          func() time.Time
+}
+
+// NewSweeper creates an expiry sweeper. A negative notice window falls back to the
+// default; fileShares may be nil, as for NewService.
+func NewSweeper(repo Repository, auditService audit.Service, fileShares FileShares, opts SweepOptions) Sweeper {
+	if opts.NoticeWindow < 0 {
+		opts.NoticeWindow = DefaultSweepOptions().NoticeWindow
+	}
+	return &sweeper{repo: repo, auditService: auditService, fileShares: fileShares, opts: opts, now: time.Now}
+}
+
+// Start runs Sweep every interval until ctx is cancelled.
+func (s *sweeper) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.Sweep(ctx); err != nil {
+					slog.Warn("consent expiry sweep failed", "error", err)
+				}
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
+
+// Sweep expires every approved or suspended grant past its ExpiresAt, retries the file
+// share sync of grants whose earlier sync failed, then records a consent.expiring
+// notice for each approved grant entering the notice window. Expiry and notices are
+// conditional updates, so sweepers on several replicas never act twice.
+func (s *sweeper) Sweep(ctx context.Context) (*SweepResult, error) {
+	now := s.now()
+	result := &SweepResult{Expired: []string{}, Notified: []string{}, Resynced: []string{}}
+
+	for {
+		overdue, err := s.repo.ListOverdue(ctx, now, sweepPageSize)
+		if err != nil {
+			return result, fmt.Errorf("sweep consent expiry: %w", err)
+		}
+		for i := range overdue {
+			expired, err := expireGrant(ctx, s.repo, s.auditService, s.fileShares, &overdue[i])
+			if err != nil {
+				return result, fmt.Errorf("sweep consent expiry: %w", err)
+			}
+			if expired {
+				result.Expired = append(result.Expired, overdue[i].ID)
+			}
+		}
+		// Every grant read was expired or changed by another writer, so the next page
+		// starts after them.
+		if len(overdue) < sweepPageSize {
+			break
+		}
+	}
+
+	if s.fileShares != nil {
+		// One page per sweep, so grants whose sync keeps failing do not hold up the
+		// expiry notices.
+		pending, err := s.repo.ListShareSyncPending(ctx, sweepPageSize)
+		if err != nil {
+			return result, fmt.Errorf("sweep file share sync: %w", err)
+		}
+		for i := range pending {
+			if syncFileShares(ctx, s.repo, s.fileShares, &pending[i]) {
+				result.Resynced = append(result.Resynced, pending[i].ID)
+			}
+		}
+	}
+
+	if s.opts.NoticeWindow == 0 {
+		return result, nil
+	}
+	for {
+		expiring, err := s.repo.ListExpiringUnnoticed(ctx, now, now.Add(s.opts.NoticeWindow), sweepPageSize)
+		if err != nil {
+			return result, fmt.Errorf("sweep consent expiry notices: %w", err)
+		}
+		for i := range expiring {
+			grant := &expiring[i]
+			marked, err := s.repo.MarkExpiryNotice(ctx, grant.ID, now)
+			if err != nil {
+				return result, fmt.Errorf("sweep consent expiry notices: %w", err)
+			}
+			if !marked {
+				continue
+			}
+			metadata := termsMetadata(grant, common.JSONMap{
+				"grantor":   grant.Grantor,
+				"grantee":   grant.Grantee,
+				"expiresAt": grant.ExpiresAt,
+			})
+			_ = s.auditService.Record(ctx, grant.Grantor, protocol.ActionConsentExpiring, protocol.ResourceConsent, grant.ID, metadata)
+			result.Notified = append(result.Notified, grant.ID)
+		}
+		if len(expiring) < sweepPageSize {
+			break
+		}
+	}
+	return result, nil
+}
+
+// expireGrant moves an overdue grant to expired, records consent.expire under the
+// grantor and disables the file key shares it covered. It reports false when another
+// writer changed the grant first.
+func expireGrant(ctx context.Context, repo Repository, auditService audit.Service, fileShares FileShares, grant *ConsentGrant) (bool, error) {
+	from := grant.State
+	if err := consent.TryTransition(from, consent.StateExpired); err != nil {
+		return false, fmt.Errorf("expire grant %s: %w", grant.ID, err)
+	}
+
+	changed, err := repo.SetState(ctx, grant.ID, from, consent.StateExpired, grant.ReceiptNonce)
+	if err != nil || !changed {
+		return false, err
+	}
+	grant.State = consent.StateExpired
+
+	metadata := termsMetadata(grant, common.JSONMap{
+		"grantor":       grant.Grantor,
+		"grantee":       grant.Grantee,
+		"expiresAt":     grant.ExpiresAt,
+		"previousState": from,
+	})
+	_ = auditService.Record(ctx, grant.Grantor, protocol.ActionConsentExpire, protocol.ResourceConsent, grant.ID, metadata)
+	syncFileShares(ctx, repo, fileShares, grant)
+	return true, nil
+}
+
+// syncFileShares tells fileShares that grant changed state, passing the grantee's
+// grants with the patient that still give access, and clears the grant's pending sync
+// once that succeeds. The consent change was committed with the sync pending, so a
+// failure is logged rather than returned and the sweeper retries it. It reports
+// whether the shares were synced.
+func syncFileShares(ctx context.Context, repo Repository, fileShares FileShares, grant *ConsentGrant) bool {
+	if fileShares == nil {
+		return false
+	}
+	approved, err := repo.FindApproved(ctx, grant.Grantor, grant.Grantee)
+	if err != nil {
+		slog.Warn("failed to load grants for file share sync", "grant", grant.ID, "error", err)
+		return false
+	}
+	active := make([]*consent.Grant, 0, len(approved))
+	for i := range approved {
+		if g := approved[i].ToProtocol(); g.IsActive() {
+			active = append(active, g)
+		}
+	}
+	if err := fileShares.SyncFileAccess(ctx, grant.Grantor, grant.Grantee, active, grant.State); err != nil {
+		slog.Warn("failed to sync file shares with consent", "grant", grant.ID, "state", grant.State, "error", err)
+		return false
+	}
+	if err := repo.ClearShareSyncPending(ctx, grant.ID, grant.State); err != nil {
+		slog.Warn("failed to clear pending file share sync", "grant", grant.ID, "error", err)
+		return false
+	}
+	grant.ShareSyncPending = false
+	return true
+}
@@ -0,0 +1,64 @@
+package delegation
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Delegation is the database model for a patient's delegation to a guardian or
+// caregiver. A row starts pending when the patient names the delegate and becomes
+// active once the patient signs its signing message with their wallet.
+type Delegation struct {
+	ID                 string                  `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Principal          string                  `json:"principal" gorm:"index;type:varchar(255);not null"` // Patient the delegate acts as
+	Delegate           string                  `json:"delegate" gorm:"index;type:varchar(255);not null"`  // Guardian or caregiver
+	Relationship       delegation.Relationship `json:"relationship" gorm:"type:varchar(50);not null"`
+	Permissions        common.JSONStrings      `json:"permissions" gorm:"type:jsonb"`
+	Status             delegation.Status       `json:"status" gorm:"type:varchar(50);not null"`
+	ExpiresAt          time.Time               `json:"expiresAt" gorm:"not null;index"`
+	Signature          string                  `json:"signature,omitempty" gorm:"type:varchar(255)"`
+	SignatureAlgorithm string                  `json:"signatureAlgorithm,omitempty" gorm:"type:varchar(50)"`
+	SignedAt           *time.Time              `json:"signedAt,omitempty"`
+	RevokedAt          *time.Time              `json:"revokedAt,omitempty"`
+	RevokedBy          string                  `json:"revokedBy,omitempty" gorm:"type:varchar(255)"`
+	SchemaVersion      string                  `json:"schemaVersion" gorm:"type:varchar(50)"`
+	CreatedAt          time.Time               `json:"createdAt"`
+	UpdatedAt          time.Time               `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for delegations.
+func (Delegation) TableName() string {
+	return "delegations"
+}
+
+// IsExpired reports whether a pending or active delegation is past its expiry.
+func (d *Delegation) IsExpired(now time.Time) bool {
+	switch d.Status {
+	case delegation.StatusPending, delegation.StatusActive:
+		return !now.Before(d.ExpiresAt)
+	}
+	return false
+}
+
+// ToProtocol converts the row to the protocol delegation the patient signs.
+func (d *Delegation) ToProtocol() *delegation.Delegation {
+	perms := make([]delegation.Permission, len(d.Permissions))
+	for i, p := range d.Permissions {
+		perms[i] = delegation.Permission(p)
+	}
+	return &delegation.Delegation{
+		ID:                 types.ID(d.ID),
+		Principal:          types.WalletAddress(d.Principal),
+		Delegate:           types.WalletAddress(d.Delegate),
+		Relationship:       d.Relationship,
+		Permissions:        perms,
+		Status:             d.Status,
+		ExpiresAt:          d.ExpiresAt,
+		Signature:          d.Signature,
+		SignatureAlgorithm: d.SignatureAlgorithm,
+		SchemaVersion:      d.SchemaVersion,
+	}
+}
@@ -0,0 +1,212 @@
+package delegation
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Handler handles HTTP requests for guardian and caregiver delegations.
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new delegation handler.
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RegisterRoutes registers the delegation endpoints. They must not sit behind the
+// act-as middleware: only the patient themselves may delegate.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	delegationGroup := rg.Group("/delegations")
+	{
+		delegationGroup.POST("", h.HandleCreate)
+		delegationGroup.GET("", h.HandleList)
+		delegationGroup.GET("/:id", h.HandleGetByID)
+		delegationGroup.GET("/:id/signing-message", h.HandleSigningMessage)
+		delegationGroup.POST("/:id/sign", h.HandleSign)
+		delegationGroup.POST("/:id/revoke", h.HandleRevoke)
+	}
+}
+
+// DelegationRequestDTO is the payload a patient sends to name a guardian or caregiver.
+type DelegationRequestDTO struct {
+	Delegate     string                  `json:"delegate" binding:"required"`
+	Relationship delegation.Relationship `json:"relationship" binding:"required"`
+	Permissions  []delegation.Permission `json:"permissions" binding:"required"`
+	ExpiresAt    time.Time               `json:"expiresAt" binding:"required"`
+}
+
+// SignDTO is the payload a patient sends with their wallet signature.
+type SignDTO struct {
+	Signature string `json:"signature" binding:"required"`
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(c *gin.Context, err error, fallback string) {
+	var validationErrs types.ValidationErrors
+	var validationErr types.ValidationError
+	switch {
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "delegation not found"})
+	case errors.As(err, &validationErrs),
+		errors.As(err, &validationErr),
+		errors.Is(err, types.ErrInvalidAddress),
+		errors.Is(err, ErrInvalidState),
+		errors.Is(err, ErrInvalidSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// HandleCreate names a guardian or caregiver; the delegation stays pending until signed.
+func (h *Handler) HandleCreate(c *gin.Context) {
+	principal, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req DelegationRequestDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	d, err := h.service.CreateDelegation(c.Request.Context(), principal, Request{
+		Delegate:     req.Delegate,
+		Relationship: req.Relationship,
+		Permissions:  req.Permissions,
+		ExpiresAt:    req.ExpiresAt,
+	})
+	if err != nil {
+		writeError(c, err, "failed to create delegation")
+		return
+	}
+
+	c.JSON(http.StatusCreated, d)
+}
+
+// HandleList returns the caller's delegations: as patient by default, or as delegate with ?role=delegate.
+func (h *Handler) HandleList(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var (
+		ds  []Delegation
+		err error
+	)
+	switch c.DefaultQuery("role", "principal") {
+	case "principal":
+		ds, err = h.service.GetPrincipalDelegations(c.Request.Context(), address)
+	case "delegate":
+		ds, err = h.service.GetDelegateDelegations(c.Request.Context(), address)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be principal or delegate"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch delegations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"delegations": ds})
+}
+
+// HandleGetByID returns a delegation to its patient or delegate.
+func (h *Handler) HandleGetByID(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	d, err := h.service.GetDelegation(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to fetch delegation")
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+// HandleSigningMessage returns the message the patient signs with their wallet.
+func (h *Handler) HandleSigningMessage(c *gin.Context) {
+	principal, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	message, err := h.service.GetSigningMessage(c.Request.Context(), principal, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to build signing message")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// HandleSign activates a delegation once the patient's signature verifies.
+func (h *Handler) HandleSign(c *gin.Context) {
+	principal, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req SignDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	d, err := h.service.SignDelegation(c.Request.Context(), principal, c.Param("id"), req.Signature)
+	if err != nil {
+		writeError(c, err, "failed to sign delegation")
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+// HandleRevoke ends a delegation (patient) or gives it up (delegate).
+func (h *Handler) HandleRevoke(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.RevokeDelegation(c.Request.Context(), address, c.Param("id")); err != nil {
+		writeError(c, err, "failed to revoke delegation")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
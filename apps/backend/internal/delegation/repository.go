@@ -0,0 +1,79 @@
+package delegation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for delegation persistence.
+type Repository interface {
+	Create(ctx context.Context, d *Delegation) error
+	GetByID(ctx context.Context, id string) (*Delegation, error)
+	GetByPrincipal(ctx context.Context, principal string) ([]Delegation, error)
+	GetByDelegate(ctx context.Context, delegate string) ([]Delegation, error)
+	// FindActive returns the active delegations from principal to delegate, newest first.
+	FindActive(ctx context.Context, principal, delegate string) ([]Delegation, error)
+	Update(ctx context.Context, d *Delegation) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new GORM repository for delegations.
+func NewRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) Create(ctx context.Context, d *Delegation) error {
+	if err := r.db.WithContext(ctx).Create(d).Error; err != nil {
+		return fmt.Errorf("create delegation: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetByID(ctx context.Context, id string) (*Delegation, error) {
+	var d Delegation
+	if err := r.db.WithContext(ctx).First(&d, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get delegation %s: %w", id, err)
+	}
+	return &d, nil
+}
+
+func (r *gormRepository) GetByPrincipal(ctx context.Context, principal string) ([]Delegation, error) {
+	var ds []Delegation
+	if err := r.db.WithContext(ctx).Where("principal = ?", principal).Order("created_at DESC").Find(&ds).Error; err != nil {
+		return nil, fmt.Errorf("list delegations for principal %s: %w", principal, err)
+	}
+	return ds, nil
+}
+
+func (r *gormRepository) GetByDelegate(ctx context.Context, delegate string) ([]Delegation, error) {
+	var ds []Delegation
+	if err := r.db.WithContext(ctx).Where("delegate = ?", delegate).Order("created_at DESC").Find(&ds).Error; err != nil {
+		return nil, fmt.Errorf("list delegations for delegate %s: %w", delegate, err)
+	}
+	return ds, nil
+}
+
+func (r *gormRepository) FindActive(ctx context.Context, principal, delegate string) ([]Delegation, error) {
+	var ds []Delegation
+	err := r.db.WithContext(ctx).
+		Where("principal = ? AND delegate = ? AND status = ?", principal, delegate, delegation.StatusActive).
+		Order("created_at DESC").
+		Find(&ds).Error
+	if err != nil {
+		return nil, fmt.Errorf("find active delegations from %s to %s: %w", principal, delegate, err)
+	}
+	return ds, nil
+}
+
+func (r *gormRepository) Update(ctx context.Context, d *Delegation) error {
+	if err := r.db.WithContext(ctx).Save(d).Error; err != nil {
+		return fmt.Errorf("update delegation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package delegation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// ErrForbidden is wrapped by every error raised when the caller may not act on a delegation.
+var ErrForbidden = errors.New("not allowed to act on this delegation")
+
+var (
+	// ErrNotPrincipal is returned when someone other than the patient tries to sign.
+	ErrNotPrincipal = fmt.Errorf("%w: only the patient may sign it", ErrForbidden)
+	// ErrNotParty is returned when the caller is neither the patient nor the delegate.
+	ErrNotParty = fmt.Errorf("%w: only the patient or delegate may access it", ErrForbidden)
+	// ErrNoDelegation is returned when the caller holds no active delegation from the
+	// patient carrying the needed permission.
+	ErrNoDelegation = fmt.Errorf("%w: no active delegation with this permission", ErrForbidden)
+)
+
+var (
+	// ErrInvalidState is returned when the delegation is not in a state that allows the action.
+	ErrInvalidState = errors.New("delegation is not in a valid state for this action")
+	// ErrInvalidSignature is returned when the signature is not the patient's over the signing message.
+	ErrInvalidSignature = errors.New("signature does not match the delegation")
+)
+
+// Request describes a delegation a patient grants a guardian or caregiver.
+type Request struct {
+	Delegate     string
+	Relationship delegation.Relationship
+	Permissions  []delegation.Permission
+	ExpiresAt    time.Time
+}
+
+// Service defines the business logic for guardian and caregiver delegations.
+type Service interface {
+	CreateDelegation(ctx context.Context, principal string, req Request) (*Delegation, error)
+	// GetSigningMessage returns the message the patient signs to activate a pending delegation.
+	GetSigningMessage(ctx context.Context, principal, id string) (string, error)
+	SignDelegation(ctx context.Context, principal, id, signature string) (*Delegation, error)
+	// RevokeDelegation ends a delegation; the patient may revoke it and the delegate may give it up.
+	RevokeDelegation(ctx context.Context, actor, id string) error
+	GetDelegation(ctx context.Context, actor, id string) (*Delegation, error)
+	GetPrincipalDelegations(ctx context.Context, principal string) ([]Delegation, error)
+	GetDelegateDelegations(ctx context.Context, delegate string) ([]Delegation, error)
+	// Resolve returns the active delegation letting delegate act as principal with
+	// permission, or ErrNoDelegation.
+	Resolve(ctx context.Context, delegate, principal string, permission delegation.Permission) (*Delegation, error)
+}
+
+type service struct {
+	repo         Repository
+	auditService audit.Service
+}
+
+// NewService creates a new delegation service.
+func NewService(repo Repository, auditService audit.Service) Service {
+	return &service{
+		repo:         repo,
+		auditService: auditService,
+	}
+}
+
+func (s *service) CreateDelegation(ctx context.Context, principal string, req Request) (*Delegation, error) {
+	patient, err := types.NewWalletAddress(principal)
+	if err != nil {
+		return nil, err
+	}
+	delegate, err := types.NewWalletAddress(req.Delegate)
+	if err != nil {
+		return nil, types.NewValidationError("delegate", "delegate address is required")
+	}
+
+	protocolDelegation, err := delegation.NewDelegationBuilder().
+		WithPrincipal(patient).
+		WithDelegate(delegate).
+		WithRelationship(req.Relationship).
+		WithPermissions(req.Permissions...).
+		WithExpiresAt(req.ExpiresAt).
+		Build()
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make(common.JSONStrings, len(protocolDelegation.Permissions))
+	for i, p := range protocolDelegation.Permissions {
+		perms[i] = string(p)
+	}
+	d := &Delegation{
+		ID:            protocolDelegation.ID.String(),
+		Principal:     patient.String(),
+		Delegate:      delegate.String(),
+		Relationship:  protocolDelegation.Relationship,
+		Permissions:   perms,
+		Status:        delegation.StatusPending,
+		ExpiresAt:     protocolDelegation.ExpiresAt,
+		SchemaVersion: delegation.SchemaVersionDelegation,
+	}
+	if err := s.repo.Create(ctx, d); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, d.Principal, protocol.ActionDelegationCreate, protocol.ResourceDelegation, d.ID, common.JSONMap{
+		"delegate":     d.Delegate,
+		"relationship": d.Relationship,
+		"permissions":  d.Permissions,
+		"expiresAt":    d.ExpiresAt,
+	})
+
+	return d, nil
+}
+
+func (s *service) GetSigningMessage(ctx context.Context, principal, id string) (string, error) {
+	d, err := s.pendingForPrincipal(ctx, principal, id)
+	if err != nil {
+		return "", err
+	}
+	return d.ToProtocol().SigningMessage(), nil
+}
+
+func (s *service) SignDelegation(ctx context.Context, principal, id, signature string) (*Delegation, error) {
+	d, err := s.pendingForPrincipal(ctx, principal, id)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := d.ToProtocol()
+	signed.Signature = signature
+	signed.SignatureAlgorithm = delegation.SignatureAlgorithmEIP191
+	if !signed.VerifySignature() {
+		return nil, ErrInvalidSignature
+	}
+
+	now := time.Now().UTC()
+	d.Status = delegation.StatusActive
+	d.Signature = signature
+	d.SignatureAlgorithm = delegation.SignatureAlgorithmEIP191
+	d.SignedAt = &now
+	if err := s.repo.Update(ctx, d); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, d.Principal, protocol.ActionDelegationSign, protocol.ResourceDelegation, d.ID, common.JSONMap{
+		"delegate":    d.Delegate,
+		"permissions": d.Permissions,
+		"expiresAt":   d.ExpiresAt,
+	})
+
+	return d, nil
+}
+
+func (s *service) RevokeDelegation(ctx context.Context, actor, id string) error {
+	d, err := s.GetDelegation(ctx, actor, id)
+	if err != nil {
+		return err
+	}
+	if d.Status != delegation.StatusPending && d.Status != delegation.StatusActive {
+		return fmt.Errorf("%w: %s", ErrInvalidState, d.Status)
+	}
+
+	role := "principal"
+	if strings.EqualFold(actor, d.Delegate) {
+		role = "delegate"
+	}
+	now := time.Now().UTC()
+	d.Status = delegation.StatusRevoked
+	d.RevokedAt = &now
+	d.RevokedBy = strings.ToLower(actor)
+	if err := s.repo.Update(ctx, d); err != nil {
+		return err
+	}
+
+	// Recorded under the patient so the trail of who may act for them stays on their log.
+	_ = s.auditService.Record(ctx, d.Principal, protocol.ActionDelegationRevoke, protocol.ResourceDelegation, d.ID, common.JSONMap{
+		"delegate":  d.Delegate,
+		"revokedBy": d.RevokedBy,
+		"role":      role,
+	})
+
+	return nil
+}
+
+func (s *service) GetDelegation(ctx context.Context, actor, id string) (*Delegation, error) {
+	d, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(actor, d.Principal) && !strings.EqualFold(actor, d.Delegate) {
+		return nil, ErrNotParty
+	}
+	return d, nil
+}
+
+func (s *service) GetPrincipalDelegations(ctx context.Context, principal string) ([]Delegation, error) {
+	ds, err := s.repo.GetByPrincipal(ctx, strings.ToLower(principal))
+	if err != nil {
+		return nil, err
+	}
+	return s.expireAll(ctx, ds), nil
+}
+
+func (s *service) GetDelegateDelegations(ctx context.Context, delegate string) ([]Delegation, error) {
+	ds, err := s.repo.GetByDelegate(ctx, strings.ToLower(delegate))
+	if err != nil {
+		return nil, err
+	}
+	return s.expireAll(ctx, ds), nil
+}
+
+func (s *service) Resolve(ctx context.Context, delegate, principal string, permission delegation.Permission) (*Delegation, error) {
+	ds, err := s.repo.FindActive(ctx, strings.ToLower(principal), strings.ToLower(delegate))
+	if err != nil {
+		return nil, err
+	}
+	for i := range ds {
+		d := &ds[i]
+		if err := s.expireIfDue(ctx, d); err != nil {
+			return nil, err
+		}
+		if signed := d.ToProtocol(); signed.IsActive(time.Now()) && signed.Allows(permission) {
+			return d, nil
+		}
+	}
+	return nil, ErrNoDelegation
+}
+
+// load fetches a delegation and applies any expiry that has come due.
+func (s *service) load(ctx context.Context, id string) (*Delegation, error) {
+	d, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.expireIfDue(ctx, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (s *service) pendingForPrincipal(ctx context.Context, principal, id string) (*Delegation, error) {
+	d, err := s.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(principal, d.Principal) {
+		return nil, ErrNotPrincipal
+	}
+	if d.Status != delegation.StatusPending {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidState, d.Status)
+	}
+	return d, nil
+}
+
+// expireIfDue marks a lapsed delegation as expired.
+func (s *service) expireIfDue(ctx context.Context, d *Delegation) error {
+	if !d.IsExpired(time.Now()) {
+		return nil
+	}
+	d.Status = delegation.StatusExpired
+	if err := s.repo.Update(ctx, d); err != nil {
+		return err
+	}
+	_ = s.auditService.Record(ctx, d.Principal, protocol.ActionDelegationExpire, protocol.ResourceDelegation, d.ID, common.JSONMap{
+		"delegate":  d.Delegate,
+		"expiresAt": d.ExpiresAt,
+	})
+	return nil
+}
+
+func (s *service) expireAll(ctx context.Context, ds []Delegation) []Delegation {
+	for i := range ds {
+		_ = s.expireIfDue(ctx, &ds[i])
+	}
+	return ds
+}
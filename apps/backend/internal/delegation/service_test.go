@@ -0,0 +1,228 @@
+package delegation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolcrypto "github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"gorm.io/gorm"
+)
+
+type mockRepo struct {
+	ds map[string]Delegation
+}
+
+func (m *mockRepo) Create(ctx context.Context, d *Delegation) error {
+	m.ds[d.ID] = *d
+	return nil
+}
+
+func (m *mockRepo) GetByID(ctx context.Context, id string) (*Delegation, error) {
+	d, ok := m.ds[id]
+	if !ok {
+		return nil, fmt.Errorf("get delegation %s: %w", id, gorm.ErrRecordNotFound)
+	}
+	return &d, nil
+}
+
+func (m *mockRepo) GetByPrincipal(ctx context.Context, principal string) ([]Delegation, error) {
+	return m.filter(func(d Delegation) bool { return d.Principal == principal }), nil
+}
+
+func (m *mockRepo) GetByDelegate(ctx context.Context, delegate string) ([]Delegation, error) {
+	return m.filter(func(d Delegation) bool { return d.Delegate == delegate }), nil
+}
+
+func (m *mockRepo) FindActive(ctx context.Context, principal, delegate string) ([]Delegation, error) {
+	return m.filter(func(d Delegation) bool {
+		return d.Principal == principal && d.Delegate == delegate && d.Status == delegation.StatusActive
+	}), nil
+}
+
+func (m *mockRepo) Update(ctx context.Context, d *Delegation) error {
+	m.ds[d.ID] = *d
+	return nil
+}
+
+func (m *mockRepo) filter(keep func(Delegation) bool) []Delegation {
+	var out []Delegation
+	for _, d := range m.ds {
+		if keep(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+const caregiver = "0x2222222222222222222222222222222222222222"
+
+type fixture struct {
+	svc     Service
+	repo    *mockRepo
+	audit   *audittest.Service
+	signer  func(message string) string
+	patient string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	repo := &mockRepo{ds: make(map[string]Delegation)}
+	auditSvc := &audittest.Service{}
+
+	return &fixture{
+		svc:     NewService(repo, auditSvc),
+		repo:    repo,
+		audit:   auditSvc,
+		patient: strings.ToLower(crypto.PubkeyToAddress(key.PublicKey).Hex()),
+		signer: func(message string) string {
+			sig, err := protocolcrypto.SignMessage(message, key)
+			if err != nil {
+				t.Fatalf("SignMessage() error = %v", err)
+			}
+			return sig
+		},
+	}
+}
+
+func (f *fixture) create(t *testing.T, perms ...delegation.Permission) *Delegation {
+	t.Helper()
+	d, err := f.svc.CreateDelegation(context.Background(), f.patient, Request{
+		Delegate:     caregiver,
+		Relationship: delegation.RelationshipCaregiver,
+		Permissions:  perms,
+		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateDelegation() error = %v", err)
+	}
+	return d
+}
+
+func (f *fixture) sign(t *testing.T, id string) *Delegation {
+	t.Helper()
+	ctx := context.Background()
+	message, err := f.svc.GetSigningMessage(ctx, f.patient, id)
+	if err != nil {
+		t.Fatalf("GetSigningMessage() error = %v", err)
+	}
+	d, err := f.svc.SignDelegation(ctx, f.patient, id, f.signer(message))
+	if err != nil {
+		t.Fatalf("SignDelegation() error = %v", err)
+	}
+	return d
+}
+
+func TestService_CreateDelegation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	if _, err := f.svc.CreateDelegation(ctx, f.patient, Request{Delegate: f.patient, Relationship: delegation.RelationshipGuardian, Permissions: []delegation.Permission{delegation.PermTimelineRead}, ExpiresAt: time.Now().Add(time.Hour)}); err == nil {
+		t.Error("CreateDelegation() to self succeeded")
+	}
+	if _, err := f.svc.CreateDelegation(ctx, f.patient, Request{Delegate: caregiver, Relationship: delegation.RelationshipGuardian, Permissions: []delegation.Permission{delegation.PermTimelineRead}}); err == nil {
+		t.Error("CreateDelegation() without expiry succeeded")
+	}
+
+	d := f.create(t, delegation.PermTimelineRead)
+	if d.Status != delegation.StatusPending || d.Principal != f.patient || d.Delegate != caregiver {
+		t.Errorf("CreateDelegation() = %+v", d)
+	}
+	if _, err := f.svc.Resolve(ctx, caregiver, f.patient, delegation.PermTimelineRead); !errors.Is(err, ErrNoDelegation) {
+		t.Errorf("Resolve() of an unsigned delegation error = %v, want %v", err, ErrNoDelegation)
+	}
+}
+
+func TestService_SignDelegation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	d := f.create(t, delegation.PermTimelineRead, delegation.PermConsentManage)
+
+	message, _ := f.svc.GetSigningMessage(ctx, f.patient, d.ID)
+	if _, err := f.svc.SignDelegation(ctx, caregiver, d.ID, f.signer(message)); !errors.Is(err, ErrNotPrincipal) {
+		t.Errorf("SignDelegation() by delegate error = %v, want %v", err, ErrNotPrincipal)
+	}
+	if _, err := f.svc.SignDelegation(ctx, f.patient, d.ID, f.signer(message+"\n")); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("SignDelegation() with a signature over another message error = %v, want %v", err, ErrInvalidSignature)
+	}
+
+	signed := f.sign(t, d.ID)
+	if signed.Status != delegation.StatusActive || signed.SignedAt == nil {
+		t.Errorf("SignDelegation() = %+v, want active", signed)
+	}
+	if _, err := f.svc.SignDelegation(ctx, f.patient, d.ID, signed.Signature); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("SignDelegation() twice error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestService_Resolve(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	d := f.sign(t, f.create(t, delegation.PermTimelineRead, delegation.PermConsentManage).ID)
+
+	got, err := f.svc.Resolve(ctx, strings.ToUpper(caregiver[:2])+caregiver[2:], f.patient, delegation.PermConsentManage)
+	if err != nil || got.ID != d.ID {
+		t.Fatalf("Resolve() = %v, %v; want %s", got, err, d.ID)
+	}
+	if _, err := f.svc.Resolve(ctx, caregiver, f.patient, delegation.PermTimelineWrite); !errors.Is(err, ErrNoDelegation) {
+		t.Errorf("Resolve() without the permission error = %v, want %v", err, ErrNoDelegation)
+	}
+	if _, err := f.svc.Resolve(ctx, "0x3333333333333333333333333333333333333333", f.patient, delegation.PermTimelineRead); !errors.Is(err, ErrNoDelegation) {
+		t.Errorf("Resolve() for another delegate error = %v, want %v", err, ErrNoDelegation)
+	}
+}
+
+func TestService_RevokeDelegation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	d := f.sign(t, f.create(t, delegation.PermTimelineRead).ID)
+
+	if err := f.svc.RevokeDelegation(ctx, "0x3333333333333333333333333333333333333333", d.ID); !errors.Is(err, ErrNotParty) {
+		t.Errorf("RevokeDelegation() by a stranger error = %v, want %v", err, ErrNotParty)
+	}
+	// The delegate may step down.
+	if err := f.svc.RevokeDelegation(ctx, caregiver, d.ID); err != nil {
+		t.Fatalf("RevokeDelegation() by delegate error = %v", err)
+	}
+	if _, err := f.svc.Resolve(ctx, caregiver, f.patient, delegation.PermTimelineRead); !errors.Is(err, ErrNoDelegation) {
+		t.Errorf("Resolve() after revoke error = %v, want %v", err, ErrNoDelegation)
+	}
+	if got := f.audit.Last().Actor; got != f.patient {
+		t.Errorf("revoke recorded under %s, want the patient", got)
+	}
+	if err := f.svc.RevokeDelegation(ctx, f.patient, d.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("RevokeDelegation() twice error = %v, want %v", err, ErrInvalidState)
+	}
+}
+
+func TestService_ExpiresLazily(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	d := f.sign(t, f.create(t, delegation.PermTimelineRead).ID)
+
+	stored := f.repo.ds[d.ID]
+	stored.ExpiresAt = time.Now().Add(-time.Minute)
+	f.repo.ds[d.ID] = stored
+
+	if _, err := f.svc.Resolve(ctx, caregiver, f.patient, delegation.PermTimelineRead); !errors.Is(err, ErrNoDelegation) {
+		t.Errorf("Resolve() of an expired delegation error = %v, want %v", err, ErrNoDelegation)
+	}
+	if got := f.repo.ds[d.ID].Status; got != delegation.StatusExpired {
+		t.Errorf("status = %s, want %s", got, delegation.StatusExpired)
+	}
+	if last := f.audit.Last().Action; last != protocol.ActionDelegationExpire {
+		t.Errorf("last audit action = %s, want %s", last, protocol.ActionDelegationExpire)
+	}
+}
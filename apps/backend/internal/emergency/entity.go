@@ -0,0 +1,105 @@
+package emergency
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	backendconsent "github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Profile is a patient's emergency access configuration. Patients without a row get
+// consent.DefaultEmergencyCategories.
+type Profile struct {
+	PatientID  string                    `json:"patientId" gorm:"primaryKey;type:varchar(255)"`
+	Disabled   bool                      `json:"disabled" gorm:"not null;default:false"` // Patient opted out of break-glass access
+	Categories common.JSONDataCategories `json:"categories" gorm:"type:jsonb"`
+	CreatedAt  time.Time                 `json:"createdAt"`
+	UpdatedAt  time.Time                 `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for emergency profiles.
+func (Profile) TableName() string {
+	return "emergency_profiles"
+}
+
+// EmergencyCategories returns what emergency access to the patient covers.
+func (p *Profile) EmergencyCategories() consent.DataCategories {
+	if len(p.Categories) == 0 {
+		return consent.DefaultEmergencyCategories()
+	}
+	return consent.DataCategories(p.Categories)
+}
+
+// Session is the database model for a break-glass session. The categories are copied
+// from the patient's profile when the provider breaks glass, so later profile changes
+// do not widen a session after the fact.
+type Session struct {
+	ID            string                    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	PatientID     string                    `json:"patientId" gorm:"index:idx_emergency_patient_review,priority:1;type:varchar(255);not null"`
+	Provider      string                    `json:"provider" gorm:"index;type:varchar(255);not null"`
+	Justification string                    `json:"justification" gorm:"type:text;not null"`
+	Categories    common.JSONDataCategories `json:"categories" gorm:"type:jsonb"`
+	StartedAt     time.Time                 `json:"startedAt" gorm:"not null"`
+	ExpiresAt     time.Time                 `json:"expiresAt" gorm:"not null"`
+	EndedAt       *time.Time                `json:"endedAt,omitempty"`
+	EndedBy       string                    `json:"endedBy,omitempty" gorm:"type:varchar(255)"`
+	Review        consent.ReviewStatus      `json:"review" gorm:"index:idx_emergency_patient_review,priority:2;type:varchar(20);not null"`
+	ReviewedAt    *time.Time                `json:"reviewedAt,omitempty"`
+	ReviewNote    string                    `json:"reviewNote,omitempty" gorm:"type:text"`
+	SchemaVersion string                    `json:"schemaVersion" gorm:"type:varchar(50)"`
+	CreatedAt     time.Time                 `json:"createdAt"`
+	UpdatedAt     time.Time                 `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for break-glass sessions.
+func (Session) TableName() string {
+	return "emergency_sessions"
+}
+
+// ToProtocol converts the row to the protocol's emergency access.
+func (s *Session) ToProtocol() *consent.EmergencyAccess {
+	return &consent.EmergencyAccess{
+		ID:            types.ID(s.ID),
+		Patient:       types.WalletAddress(s.PatientID),
+		Provider:      types.WalletAddress(s.Provider),
+		Justification: s.Justification,
+		Categories:    consent.DataCategories(s.Categories),
+		StartedAt:     s.StartedAt,
+		ExpiresAt:     s.ExpiresAt,
+		EndedAt:       s.EndedAt,
+		Review:        s.Review,
+		SchemaVersion: s.SchemaVersion,
+	}
+}
+
+// IsActive reports whether the provider may still read under the session.
+func (s *Session) IsActive(now time.Time) bool {
+	return s.ToProtocol().IsActive(now)
+}
+
+// AllowsEvent reports whether the session, while active, covers an event.
+func (s *Session) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	access := s.ToProtocol()
+	return access.IsActive(time.Now()) && access.CoversEvent(eventType, codes)
+}
+
+// Scope is a non-owner's read access while they hold a break-glass session: the
+// union of their consent grants, if any, and the session's emergency categories.
+// Events are attributed to a grant when one covers them.
+type Scope struct {
+	Grants  backendconsent.AccessGrants
+	Session *Session
+}
+
+// AllowsEvent reports whether a grant or the session covers an event.
+func (s Scope) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	return s.Grants.AllowsEvent(eventID, eventType, codes) || (s.Session != nil && s.Session.AllowsEvent(eventID, eventType, codes))
+}
+
+// GrantFor returns the newest grant covering an event, or "" when only the session does.
+func (s Scope) GrantFor(eventID string, eventType timeline.EventType, codes types.Codes) string {
+	return s.Grants.GrantFor(eventID, eventType, codes)
+}
@@ -0,0 +1,257 @@
+package emergency
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Handler handles HTTP requests for break-glass emergency access.
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new emergency access handler.
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RegisterRoutes registers the emergency access endpoints. They must not sit behind
+// the act-as middleware: a delegate could otherwise break glass in a patient's name.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	emergencyGroup := rg.Group("/emergency")
+	{
+		emergencyGroup.POST("/break-glass", h.HandleBreakGlass)
+		emergencyGroup.GET("/sessions", h.HandleList)
+		emergencyGroup.GET("/sessions/:id", h.HandleGetByID)
+		emergencyGroup.GET("/sessions/:id/access", h.HandleGetAccess)
+		emergencyGroup.POST("/sessions/:id/end", h.HandleEnd)
+		emergencyGroup.POST("/sessions/:id/review", h.HandleReview)
+		emergencyGroup.GET("/profile", h.HandleGetProfile)
+		emergencyGroup.PUT("/profile", h.HandleUpdateProfile)
+	}
+}
+
+// BreakGlassDTO is the payload a provider sends to invoke emergency access.
+type BreakGlassDTO struct {
+	PatientID     string `json:"patientId" binding:"required"`
+	Justification string `json:"justification" binding:"required"`
+}
+
+// ReviewDTO is the patient's verdict on a session.
+type ReviewDTO struct {
+	Verdict consent.ReviewStatus `json:"verdict" binding:"required"`
+	Note    string               `json:"note"`
+}
+
+// ProfileDTO is the patient's emergency access configuration. No categories means
+// the defaults: allergies, medications and diagnoses.
+type ProfileDTO struct {
+	Disabled   bool                   `json:"disabled"`
+	Categories consent.DataCategories `json:"categories"`
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(c *gin.Context, err error, fallback string) {
+	var validationErrs types.ValidationErrors
+	var validationErr types.ValidationError
+	switch {
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrRateLimited):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "emergency session not found"})
+	case errors.As(err, &validationErrs),
+		errors.As(err, &validationErr),
+		errors.Is(err, types.ErrInvalidAddress),
+		errors.Is(err, ErrInvalidState):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// HandleBreakGlass opens a time-boxed emergency session on a patient's record.
+func (h *Handler) HandleBreakGlass(c *gin.Context) {
+	provider, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req BreakGlassDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	session, err := h.service.BreakGlass(c.Request.Context(), provider, req.PatientID, req.Justification)
+	if err != nil {
+		writeError(c, err, "failed to open emergency access")
+		return
+	}
+
+	c.JSON(http.StatusCreated, session)
+}
+
+// HandleList returns the caller's sessions: as patient by default (?review=pending for
+// the review queue), or as provider with ?role=provider.
+func (h *Handler) HandleList(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var (
+		sessions []Session
+		err      error
+	)
+	switch c.DefaultQuery("role", "patient") {
+	case "patient":
+		sessions, err = h.service.GetPatientSessions(c.Request.Context(), address, consent.ReviewStatus(c.Query("review")))
+	case "provider":
+		sessions, err = h.service.GetProviderSessions(c.Request.Context(), address)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be patient or provider"})
+		return
+	}
+	if err != nil {
+		writeError(c, err, "failed to fetch emergency sessions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// HandleGetByID returns a session to its patient or provider.
+func (h *Handler) HandleGetByID(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	session, err := h.service.GetSession(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to fetch emergency session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// HandleGetAccess returns the reads made under a session, so the patient can review them.
+func (h *Handler) HandleGetAccess(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	entries, err := h.service.GetSessionAccess(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to fetch emergency session access")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// HandleEnd lets the provider close their session before it expires.
+func (h *Handler) HandleEnd(c *gin.Context) {
+	provider, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	session, err := h.service.EndSession(c.Request.Context(), provider, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to end emergency session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// HandleReview records the patient's verdict on a session.
+func (h *Handler) HandleReview(c *gin.Context) {
+	patient, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ReviewDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	session, err := h.service.ReviewSession(c.Request.Context(), patient, c.Param("id"), req.Verdict, req.Note)
+	if err != nil {
+		writeError(c, err, "failed to review emergency session")
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// HandleGetProfile returns what emergency access to the caller's record covers.
+func (h *Handler) HandleGetProfile(c *gin.Context) {
+	patient, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	profile, err := h.service.GetProfile(c.Request.Context(), patient)
+	if err != nil {
+		writeError(c, err, "failed to fetch emergency profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// HandleUpdateProfile changes what emergency access covers, or opts out of it.
+func (h *Handler) HandleUpdateProfile(c *gin.Context) {
+	patient, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req ProfileDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	profile, err := h.service.UpdateProfile(c.Request.Context(), patient, req.Disabled, req.Categories)
+	if err != nil {
+		writeError(c, err, "failed to update emergency profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
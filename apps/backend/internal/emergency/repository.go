@@ -0,0 +1,123 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"gorm.io/gorm"
+)
+
+// Repository defines the interface for emergency profile and session persistence.
+type Repository interface {
+	// GetProfile returns the patient's profile, or nil when they have not configured one.
+	GetProfile(ctx context.Context, patientID string) (*Profile, error)
+	SaveProfile(ctx context.Context, profile *Profile) error
+
+	CreateSession(ctx context.Context, session *Session) error
+	GetSession(ctx context.Context, id string) (*Session, error)
+	// ListSessions returns the provider's sessions on the patient's record, newest first.
+	ListSessions(ctx context.Context, patientID, provider string) ([]Session, error)
+	// ListByPatient returns the patient's sessions, newest first; an empty review
+	// status returns all of them.
+	ListByPatient(ctx context.Context, patientID string, review consent.ReviewStatus) ([]Session, error)
+	ListByProvider(ctx context.Context, provider string) ([]Session, error)
+	// CountProviderSessionsSince counts the sessions the provider opened at or after since.
+	CountProviderSessionsSince(ctx context.Context, provider string, since time.Time) (int64, error)
+	UpdateSession(ctx context.Context, session *Session) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new GORM repository for emergency access.
+func NewRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) GetProfile(ctx context.Context, patientID string) (*Profile, error) {
+	var profile Profile
+	err := r.db.WithContext(ctx).First(&profile, "patient_id = ?", patientID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get emergency profile %s: %w", patientID, err)
+	}
+	return &profile, nil
+}
+
+func (r *gormRepository) SaveProfile(ctx context.Context, profile *Profile) error {
+	if err := r.db.WithContext(ctx).Save(profile).Error; err != nil {
+		return fmt.Errorf("save emergency profile: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) CreateSession(ctx context.Context, session *Session) error {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return fmt.Errorf("create emergency session: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetSession(ctx context.Context, id string) (*Session, error) {
+	var session Session
+	if err := r.db.WithContext(ctx).First(&session, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get emergency session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+func (r *gormRepository) ListSessions(ctx context.Context, patientID, provider string) ([]Session, error) {
+	var sessions []Session
+	err := r.db.WithContext(ctx).
+		Where("patient_id = ? AND provider = ?", patientID, provider).
+		Order("started_at DESC").
+		Find(&sessions).Error
+	if err != nil {
+		return nil, fmt.Errorf("list emergency sessions by %s on %s: %w", provider, patientID, err)
+	}
+	return sessions, nil
+}
+
+func (r *gormRepository) ListByPatient(ctx context.Context, patientID string, review consent.ReviewStatus) ([]Session, error) {
+	var sessions []Session
+	query := r.db.WithContext(ctx).Where("patient_id = ?", patientID)
+	if review != "" {
+		query = query.Where("review = ?", review)
+	}
+	if err := query.Order("started_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("list emergency sessions for patient %s: %w", patientID, err)
+	}
+	return sessions, nil
+}
+
+func (r *gormRepository) ListByProvider(ctx context.Context, provider string) ([]Session, error) {
+	var sessions []Session
+	if err := r.db.WithContext(ctx).Where("provider = ?", provider).Order("started_at DESC").Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("list emergency sessions by provider %s: %w", provider, err)
+	}
+	return sessions, nil
+}
+
+func (r *gormRepository) CountProviderSessionsSince(ctx context.Context, provider string, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Session{}).
+		Where("provider = ? AND started_at >= ?", provider, since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("count emergency sessions by provider %s: %w", provider, err)
+	}
+	return count, nil
+}
+
+func (r *gormRepository) UpdateSession(ctx context.Context, session *Session) error {
+	if err := r.db.WithContext(ctx).Save(session).Error; err != nil {
+		return fmt.Errorf("update emergency session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,369 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// ErrForbidden is wrapped by every error raised when the caller may not act on emergency access.
+var ErrForbidden = errors.New("not allowed to act on this emergency access")
+
+var (
+	// ErrNotPatient is returned when someone other than the patient reviews a session.
+	ErrNotPatient = fmt.Errorf("%w: only the patient may review it", ErrForbidden)
+	// ErrNotProvider is returned when someone other than the provider ends a session.
+	ErrNotProvider = fmt.Errorf("%w: only the provider may end it", ErrForbidden)
+	// ErrNotParty is returned when the caller is neither the patient nor the provider.
+	ErrNotParty = fmt.Errorf("%w: only the patient or provider may access it", ErrForbidden)
+	// ErrDisabled is returned when the patient opted out of emergency access.
+	ErrDisabled = fmt.Errorf("%w: the patient has disabled emergency access", ErrForbidden)
+	// ErrFlagged is returned when the patient flagged an earlier session by the provider as abuse.
+	ErrFlagged = fmt.Errorf("%w: the patient flagged an earlier emergency access by this provider", ErrForbidden)
+	// ErrUnverifiedProvider is returned when the caller is not on the provider allow-list.
+	ErrUnverifiedProvider = fmt.Errorf("%w: only a verified provider may break glass", ErrForbidden)
+	// ErrOwnRecord is returned when a provider breaks glass on their own record.
+	ErrOwnRecord = fmt.Errorf("%w: break-glass cannot target your own record", ErrForbidden)
+)
+
+var (
+	// ErrInvalidState is returned when the session is not in a state that allows the action.
+	ErrInvalidState = errors.New("emergency session is not in a valid state for this action")
+	// ErrRateLimited is returned when the provider opened too many sessions recently.
+	ErrRateLimited = errors.New("too many emergency sessions opened recently")
+)
+
+// Options configures emergency access.
+type Options struct {
+	Duration time.Duration // How long a session lasts; capped at consent.MaxEmergencyDuration
+	// Providers lists the verified provider wallets allowed to break glass. With none,
+	// nobody can.
+	Providers     []string
+	SessionLimit  int           // New sessions a provider may open per SessionWindow
+	SessionWindow time.Duration // Window SessionLimit is counted over
+}
+
+// DefaultOptions returns the options used when none are configured.
+func DefaultOptions() Options {
+	return Options{
+		Duration:      consent.DefaultEmergencyDuration,
+		SessionLimit:  5,
+		SessionWindow: 24 * time.Hour,
+	}
+}
+
+// Service defines the business logic for break-glass emergency access.
+type Service interface {
+	// BreakGlass opens a session giving provider read access to the patient's emergency
+	// categories, or returns the provider's session that is still open.
+	BreakGlass(ctx context.Context, provider, patient, justification string) (*Session, error)
+	EndSession(ctx context.Context, provider, id string) (*Session, error)
+	// ActiveSession returns the provider's open session on the patient's record, or nil.
+	ActiveSession(ctx context.Context, patient, provider string) (*Session, error)
+	GetSession(ctx context.Context, actor, id string) (*Session, error)
+	// GetSessionAccess returns what the provider read under a session, for the patient's review.
+	GetSessionAccess(ctx context.Context, actor, id string) ([]audit.AuditEntry, error)
+	// GetPatientSessions lists the patient's sessions; ReviewPending gives the review queue.
+	GetPatientSessions(ctx context.Context, patient string, review consent.ReviewStatus) ([]Session, error)
+	GetProviderSessions(ctx context.Context, provider string) ([]Session, error)
+	// ReviewSession records the patient's verdict. Flagging a session ends it and bars
+	// the provider from breaking glass on the patient's record again.
+	ReviewSession(ctx context.Context, patient, id string, verdict consent.ReviewStatus, note string) (*Session, error)
+	GetProfile(ctx context.Context, patient string) (*Profile, error)
+	UpdateProfile(ctx context.Context, patient string, disabled bool, categories consent.DataCategories) (*Profile, error)
+}
+
+type service struct {
+	repo         Repository
+	auditService audit.Service
+	opts         Options
+	providers    map[string]bool
+	now          func() time.Time
+}
+
+// NewService creates a new emergency access service. A duration outside
+// (0, consent.MaxEmergencyDuration] and a non-positive session limit or window fall
+// back to the defaults.
+func NewService(repo Repository, auditService audit.Service, opts Options) Service {
+	defaults := DefaultOptions()
+	if opts.Duration <= 0 || opts.Duration > consent.MaxEmergencyDuration {
+		opts.Duration = defaults.Duration
+	}
+	if opts.SessionLimit <= 0 {
+		opts.SessionLimit = defaults.SessionLimit
+	}
+	if opts.SessionWindow <= 0 {
+		opts.SessionWindow = defaults.SessionWindow
+	}
+	providers := make(map[string]bool, len(opts.Providers))
+	for _, address := range opts.Providers {
+		providers[strings.ToLower(address)] = true
+	}
+	return &service{repo: repo, auditService: auditService, opts: opts, providers: providers, now: time.Now}
+}
+
+func (s *service) BreakGlass(ctx context.Context, provider, patient, justification string) (*Session, error) {
+	providerAddr, err := types.NewWalletAddress(provider)
+	if err != nil {
+		return nil, err
+	}
+	patientAddr, err := types.NewWalletAddress(patient)
+	if err != nil {
+		return nil, types.NewValidationError("patientId", "patient address is required")
+	}
+	if !s.providers[providerAddr.String()] {
+		return nil, ErrUnverifiedProvider
+	}
+	if providerAddr == patientAddr {
+		return nil, ErrOwnRecord
+	}
+
+	profile, err := s.GetProfile(ctx, patientAddr.String())
+	if err != nil {
+		return nil, err
+	}
+	if profile.Disabled {
+		return nil, ErrDisabled
+	}
+
+	now := s.now().UTC()
+	previous, err := s.repo.ListSessions(ctx, patientAddr.String(), providerAddr.String())
+	if err != nil {
+		return nil, err
+	}
+	for i := range previous {
+		if previous[i].Review == consent.ReviewFlagged {
+			return nil, ErrFlagged
+		}
+	}
+	for i := range previous {
+		if previous[i].IsActive(now) {
+			return &previous[i], nil
+		}
+	}
+
+	recent, err := s.repo.CountProviderSessionsSince(ctx, providerAddr.String(), now.Add(-s.opts.SessionWindow))
+	if err != nil {
+		return nil, err
+	}
+	if recent >= int64(s.opts.SessionLimit) {
+		return nil, fmt.Errorf("%w: at most %d per %s", ErrRateLimited, s.opts.SessionLimit, s.opts.SessionWindow)
+	}
+
+	session := &Session{
+		PatientID:     patientAddr.String(),
+		Provider:      providerAddr.String(),
+		Justification: strings.TrimSpace(justification),
+		Categories:    common.JSONDataCategories(profile.EmergencyCategories()),
+		StartedAt:     now,
+		ExpiresAt:     now.Add(s.opts.Duration),
+		Review:        consent.ReviewPending,
+		SchemaVersion: consent.SchemaVersionConsent,
+	}
+	if err := session.ToProtocol().Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	// Recorded about the patient, so it reaches them on the audit stream and in their
+	// access report.
+	_ = s.auditService.Record(ctx, session.Provider, protocol.ActionEmergencyAccess, protocol.ResourceEmergencyAccess, session.ID, common.JSONMap{
+		audit.MetadataSubject: session.PatientID,
+		"justification":       session.Justification,
+		"categories":          session.Categories,
+		"expiresAt":           session.ExpiresAt,
+	})
+
+	return session, nil
+}
+
+func (s *service) EndSession(ctx context.Context, provider, id string) (*Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(provider, session.Provider) {
+		return nil, ErrNotProvider
+	}
+	if !session.IsActive(s.now()) {
+		return nil, fmt.Errorf("%w: session is no longer active", ErrInvalidState)
+	}
+	if err := s.end(ctx, session, provider, "provider"); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// end closes an active session early and records who closed it.
+func (s *service) end(ctx context.Context, session *Session, actor, role string) error {
+	now := s.now().UTC()
+	session.EndedAt = &now
+	session.EndedBy = strings.ToLower(actor)
+	if err := s.repo.UpdateSession(ctx, session); err != nil {
+		return err
+	}
+	_ = s.auditService.Record(ctx, actor, protocol.ActionEmergencyEnd, protocol.ResourceEmergencyAccess, session.ID, common.JSONMap{
+		audit.MetadataSubject: session.PatientID,
+		"provider":            session.Provider,
+		"role":                role,
+	})
+	return nil
+}
+
+func (s *service) ActiveSession(ctx context.Context, patient, provider string) (*Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, strings.ToLower(patient), strings.ToLower(provider))
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for i := range sessions {
+		if sessions[i].IsActive(now) {
+			return &sessions[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *service) GetSession(ctx context.Context, actor, id string) (*Session, error) {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(actor, session.PatientID) && !strings.EqualFold(actor, session.Provider) {
+		return nil, ErrNotParty
+	}
+	return session, nil
+}
+
+func (s *service) GetSessionAccess(ctx context.Context, actor, id string) ([]audit.AuditEntry, error) {
+	session, err := s.GetSession(ctx, actor, id)
+	if err != nil {
+		return nil, err
+	}
+
+	end := session.ExpiresAt
+	if session.EndedAt != nil && session.EndedAt.Before(end) {
+		end = *session.EndedAt
+	}
+	start, stop := types.NewTimestamp(session.StartedAt), types.NewTimestamp(end)
+	entries, err := s.auditService.QueryEntries(ctx, protocol.QueryFilter{
+		Actor:     types.WalletAddress(session.Provider),
+		StartTime: &start,
+		EndTime:   &stop,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	reads := make([]audit.AuditEntry, 0, len(entries))
+	for _, entry := range entries {
+		if sessionID, _ := entry.Metadata[audit.MetadataEmergencySessionID].(string); sessionID == session.ID {
+			reads = append(reads, entry)
+		}
+	}
+	return reads, nil
+}
+
+func (s *service) GetPatientSessions(ctx context.Context, patient string, review consent.ReviewStatus) ([]Session, error) {
+	if review != "" && !review.IsValid() {
+		return nil, types.NewValidationError("review", "invalid review status")
+	}
+	return s.repo.ListByPatient(ctx, strings.ToLower(patient), review)
+}
+
+func (s *service) GetProviderSessions(ctx context.Context, provider string) ([]Session, error) {
+	return s.repo.ListByProvider(ctx, strings.ToLower(provider))
+}
+
+func (s *service) ReviewSession(ctx context.Context, patient, id string, verdict consent.ReviewStatus, note string) (*Session, error) {
+	if verdict != consent.ReviewConfirmed && verdict != consent.ReviewFlagged {
+		return nil, types.NewValidationError("verdict", "verdict must be confirmed or flagged")
+	}
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(patient, session.PatientID) {
+		return nil, ErrNotPatient
+	}
+	if session.Review != consent.ReviewPending {
+		return nil, fmt.Errorf("%w: already %s", ErrInvalidState, session.Review)
+	}
+
+	if verdict == consent.ReviewFlagged && session.IsActive(s.now()) {
+		if err := s.end(ctx, session, patient, "patient"); err != nil {
+			return nil, err
+		}
+	}
+	now := s.now().UTC()
+	session.Review = verdict
+	session.ReviewedAt = &now
+	session.ReviewNote = strings.TrimSpace(note)
+	if err := s.repo.UpdateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, session.PatientID, protocol.ActionEmergencyReview, protocol.ResourceEmergencyAccess, session.ID, common.JSONMap{
+		"provider": session.Provider,
+		"verdict":  verdict,
+		"note":     session.ReviewNote,
+	})
+
+	return session, nil
+}
+
+func (s *service) GetProfile(ctx context.Context, patient string) (*Profile, error) {
+	profile, err := s.repo.GetProfile(ctx, strings.ToLower(patient))
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &Profile{PatientID: strings.ToLower(patient)}
+	}
+	return withDefaults(profile), nil
+}
+
+func (s *service) UpdateProfile(ctx context.Context, patient string, disabled bool, categories consent.DataCategories) (*Profile, error) {
+	for _, c := range categories {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	profile, err := s.repo.GetProfile(ctx, strings.ToLower(patient))
+	if err != nil {
+		return nil, err
+	}
+	if profile == nil {
+		profile = &Profile{PatientID: strings.ToLower(patient)}
+	}
+	profile.Disabled = disabled
+	profile.Categories = common.JSONDataCategories(categories)
+	if err := s.repo.SaveProfile(ctx, profile); err != nil {
+		return nil, err
+	}
+
+	_ = s.auditService.Record(ctx, profile.PatientID, protocol.ActionEmergencyProfile, protocol.ResourceEmergencyProfile, profile.PatientID, common.JSONMap{
+		"disabled":   profile.Disabled,
+		"categories": profile.EmergencyCategories(),
+	})
+
+	return withDefaults(profile), nil
+}
+
+// withDefaults fills in the default categories for a patient who has not chosen any,
+// so callers see what emergency access actually covers.
+func withDefaults(profile *Profile) *Profile {
+	profile.Categories = common.JSONDataCategories(profile.EmergencyCategories())
+	return profile
+}
@@ -0,0 +1,339 @@
+package emergency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/consent"
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
+)
+
+// mockAuditService serves entries as every query's result.
+type mockAuditService struct {
+	audittest.Service
+	entries []audit.AuditEntry
+}
+
+func (m *mockAuditService) QueryEntries(ctx context.Context, filter protocol.QueryFilter) ([]audit.AuditEntry, error) {
+	return m.entries, nil
+}
+
+type mockRepo struct {
+	profiles map[string]Profile
+	sessions map[string]Session
+	nextID   int
+}
+
+func newMockRepo() *mockRepo {
+	return &mockRepo{profiles: map[string]Profile{}, sessions: map[string]Session{}}
+}
+
+func (m *mockRepo) GetProfile(ctx context.Context, patientID string) (*Profile, error) {
+	p, ok := m.profiles[patientID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (m *mockRepo) SaveProfile(ctx context.Context, profile *Profile) error {
+	m.profiles[profile.PatientID] = *profile
+	return nil
+}
+
+func (m *mockRepo) CreateSession(ctx context.Context, session *Session) error {
+	m.nextID++
+	session.ID = fmt.Sprintf("session-%d", m.nextID)
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *mockRepo) GetSession(ctx context.Context, id string) (*Session, error) {
+	s, ok := m.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("get emergency session %s: %w", id, gorm.ErrRecordNotFound)
+	}
+	return &s, nil
+}
+
+func (m *mockRepo) ListSessions(ctx context.Context, patientID, provider string) ([]Session, error) {
+	return m.filter(func(s Session) bool { return s.PatientID == patientID && s.Provider == provider }), nil
+}
+
+func (m *mockRepo) ListByPatient(ctx context.Context, patientID string, review consent.ReviewStatus) ([]Session, error) {
+	return m.filter(func(s Session) bool { return s.PatientID == patientID && (review == "" || s.Review == review) }), nil
+}
+
+func (m *mockRepo) ListByProvider(ctx context.Context, provider string) ([]Session, error) {
+	return m.filter(func(s Session) bool { return s.Provider == provider }), nil
+}
+
+func (m *mockRepo) CountProviderSessionsSince(ctx context.Context, provider string, since time.Time) (int64, error) {
+	return int64(len(m.filter(func(s Session) bool { return s.Provider == provider && !s.StartedAt.Before(since) }))), nil
+}
+
+func (m *mockRepo) UpdateSession(ctx context.Context, session *Session) error {
+	m.sessions[session.ID] = *session
+	return nil
+}
+
+func (m *mockRepo) filter(keep func(Session) bool) []Session {
+	var out []Session
+	for _, s := range m.sessions {
+		if keep(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+const (
+	patient       = "0x1111111111111111111111111111111111111111"
+	provider      = "0x2222222222222222222222222222222222222222"
+	otherProvider = "0x3333333333333333333333333333333333333333"
+	justification = "Unconscious in the emergency department, need allergies"
+)
+
+func newTestService(repo *mockRepo, auditService *mockAuditService) *service {
+	opts := DefaultOptions()
+	opts.Providers = []string{provider, otherProvider}
+	return NewService(repo, auditService, opts).(*service)
+}
+
+func TestService_BreakGlass(t *testing.T) {
+	repo := newMockRepo()
+	auditService := &mockAuditService{}
+	svc := newTestService(repo, auditService)
+	ctx := context.Background()
+
+	session, err := svc.BreakGlass(ctx, provider, patient, justification)
+	if err != nil {
+		t.Fatalf("BreakGlass() error = %v", err)
+	}
+	if session.Review != consent.ReviewPending {
+		t.Errorf("Review = %s, want pending", session.Review)
+	}
+	if got := session.ExpiresAt.Sub(session.StartedAt); got != consent.DefaultEmergencyDuration {
+		t.Errorf("session lasts %v, want %v", got, consent.DefaultEmergencyDuration)
+	}
+	if actions := auditService.Actions(); len(actions) != 1 || actions[0] != protocol.ActionEmergencyAccess {
+		t.Fatalf("recorded %v, want [%s]", actions, protocol.ActionEmergencyAccess)
+	}
+	if subject := auditService.Recorded[0].Metadata[audit.MetadataSubject]; subject != patient {
+		t.Errorf("emergency access recorded about %q, want the patient", subject)
+	}
+
+	again, err := svc.BreakGlass(ctx, provider, patient, justification)
+	if err != nil {
+		t.Fatalf("BreakGlass() again error = %v", err)
+	}
+	if again.ID != session.ID || len(repo.sessions) != 1 {
+		t.Error("BreakGlass() opened a second session while one was active")
+	}
+
+	active, err := svc.ActiveSession(ctx, patient, provider)
+	if err != nil || active == nil || active.ID != session.ID {
+		t.Errorf("ActiveSession() = %v, %v, want %s", active, err, session.ID)
+	}
+
+	if _, err := svc.BreakGlass(ctx, otherProvider, patient, "help"); err == nil {
+		t.Error("BreakGlass() accepted a one-word justification")
+	}
+	if _, err := svc.BreakGlass(ctx, patient, patient, justification); err == nil {
+		t.Error("BreakGlass() let a patient break glass on their own record")
+	}
+}
+
+func TestService_BreakGlass_Providers(t *testing.T) {
+	repo := newMockRepo()
+	svc := newTestService(repo, &mockAuditService{})
+	ctx := context.Background()
+
+	stranger := "0x4444444444444444444444444444444444444444"
+	if _, err := svc.BreakGlass(ctx, stranger, patient, justification); !errors.Is(err, ErrUnverifiedProvider) {
+		t.Errorf("BreakGlass() by an unlisted wallet error = %v, want ErrUnverifiedProvider", err)
+	}
+	if _, err := svc.BreakGlass(ctx, provider, provider, justification); !errors.Is(err, ErrOwnRecord) {
+		t.Errorf("BreakGlass() on the provider's own record error = %v, want ErrOwnRecord", err)
+	}
+	if len(repo.sessions) != 0 {
+		t.Fatalf("sessions = %d, want none", len(repo.sessions))
+	}
+
+	unconfigured := NewService(repo, &mockAuditService{}, DefaultOptions())
+	if _, err := unconfigured.BreakGlass(ctx, provider, patient, justification); !errors.Is(err, ErrUnverifiedProvider) {
+		t.Errorf("BreakGlass() with no providers configured error = %v, want ErrUnverifiedProvider", err)
+	}
+}
+
+func TestService_BreakGlass_RateLimited(t *testing.T) {
+	repo := newMockRepo()
+	svc := newTestService(repo, &mockAuditService{})
+	svc.opts.SessionLimit = 2
+	ctx := context.Background()
+
+	for i := range 2 {
+		patientID := fmt.Sprintf("0x%040d", i+5)
+		if _, err := svc.BreakGlass(ctx, provider, patientID, justification); err != nil {
+			t.Fatalf("BreakGlass() #%d error = %v", i+1, err)
+		}
+	}
+	if _, err := svc.BreakGlass(ctx, provider, patient, justification); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("BreakGlass() over the limit error = %v, want ErrRateLimited", err)
+	}
+	if _, err := svc.BreakGlass(ctx, otherProvider, patient, justification); err != nil {
+		t.Errorf("BreakGlass() by another provider error = %v", err)
+	}
+
+	svc.now = func() time.Time { return time.Now().Add(svc.opts.SessionWindow + time.Minute) }
+	if _, err := svc.BreakGlass(ctx, provider, patient, justification); err != nil {
+		t.Errorf("BreakGlass() after the window error = %v", err)
+	}
+}
+
+func TestService_BreakGlass_Disabled(t *testing.T) {
+	repo := newMockRepo()
+	svc := newTestService(repo, &mockAuditService{})
+	ctx := context.Background()
+
+	if _, err := svc.UpdateProfile(ctx, patient, true, nil); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if _, err := svc.BreakGlass(ctx, provider, patient, justification); !errors.Is(err, ErrDisabled) {
+		t.Errorf("BreakGlass() error = %v, want ErrDisabled", err)
+	}
+}
+
+func TestService_BreakGlass_ProfileCategories(t *testing.T) {
+	repo := newMockRepo()
+	svc := newTestService(repo, &mockAuditService{})
+	ctx := context.Background()
+
+	categories := consent.DataCategories{{EventType: timeline.EventAllergy}}
+	if _, err := svc.UpdateProfile(ctx, patient, false, categories); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	session, err := svc.BreakGlass(ctx, provider, patient, justification)
+	if err != nil {
+		t.Fatalf("BreakGlass() error = %v", err)
+	}
+	if !session.AllowsEvent("evt-1", timeline.EventAllergy, nil) {
+		t.Error("session does not cover the patient's emergency category")
+	}
+	if session.AllowsEvent("evt-2", timeline.EventLabResult, nil) {
+		t.Error("session covers an event outside the emergency categories")
+	}
+}
+
+func TestService_ReviewSession(t *testing.T) {
+	repo := newMockRepo()
+	auditService := &mockAuditService{}
+	svc := newTestService(repo, auditService)
+	ctx := context.Background()
+
+	session, err := svc.BreakGlass(ctx, provider, patient, justification)
+	if err != nil {
+		t.Fatalf("BreakGlass() error = %v", err)
+	}
+
+	if _, err := svc.ReviewSession(ctx, provider, session.ID, consent.ReviewConfirmed, ""); !errors.Is(err, ErrNotPatient) {
+		t.Errorf("ReviewSession() by provider error = %v, want ErrNotPatient", err)
+	}
+
+	queue, err := svc.GetPatientSessions(ctx, patient, consent.ReviewPending)
+	if err != nil || len(queue) != 1 {
+		t.Fatalf("GetPatientSessions(pending) = %d sessions, %v; want 1", len(queue), err)
+	}
+
+	reviewed, err := svc.ReviewSession(ctx, patient, session.ID, consent.ReviewFlagged, "I never visited this hospital")
+	if err != nil {
+		t.Fatalf("ReviewSession() error = %v", err)
+	}
+	if reviewed.Review != consent.ReviewFlagged || reviewed.EndedAt == nil {
+		t.Errorf("flagged session: review = %s, ended = %v; want flagged and ended", reviewed.Review, reviewed.EndedAt)
+	}
+	if active, _ := svc.ActiveSession(ctx, patient, provider); active != nil {
+		t.Error("flagged session is still active")
+	}
+	if _, err := svc.BreakGlass(ctx, provider, patient, justification); !errors.Is(err, ErrFlagged) {
+		t.Errorf("BreakGlass() after flag error = %v, want ErrFlagged", err)
+	}
+	if _, err := svc.ReviewSession(ctx, patient, session.ID, consent.ReviewConfirmed, ""); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second ReviewSession() error = %v, want ErrInvalidState", err)
+	}
+
+	want := []protocol.Action{protocol.ActionEmergencyAccess, protocol.ActionEmergencyEnd, protocol.ActionEmergencyReview}
+	actions := auditService.Actions()
+	if len(actions) != len(want) {
+		t.Fatalf("recorded %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("action[%d] = %s, want %s", i, actions[i], want[i])
+		}
+	}
+}
+
+func TestService_GetSessionAccess(t *testing.T) {
+	repo := newMockRepo()
+	auditService := &mockAuditService{}
+	svc := newTestService(repo, auditService)
+	ctx := context.Background()
+
+	session, err := svc.BreakGlass(ctx, provider, patient, justification)
+	if err != nil {
+		t.Fatalf("BreakGlass() error = %v", err)
+	}
+	auditService.entries = []audit.AuditEntry{
+		{ID: "read-under-session", Metadata: common.JSONMap{audit.MetadataEmergencySessionID: session.ID}},
+		{ID: "read-under-grant", Metadata: common.JSONMap{audit.MetadataGrantID: "grant-1"}},
+	}
+
+	if _, err := svc.GetSessionAccess(ctx, "0x3333333333333333333333333333333333333333", session.ID); !errors.Is(err, ErrNotParty) {
+		t.Errorf("GetSessionAccess() by stranger error = %v, want ErrNotParty", err)
+	}
+	reads, err := svc.GetSessionAccess(ctx, patient, session.ID)
+	if err != nil {
+		t.Fatalf("GetSessionAccess() error = %v", err)
+	}
+	if len(reads) != 1 || reads[0].ID != "read-under-session" {
+		t.Errorf("GetSessionAccess() = %v, want only the read under the session", reads)
+	}
+}
+
+func TestScope(t *testing.T) {
+	now := time.Now()
+	session := &Session{
+		ID:         "session-1",
+		Categories: common.JSONDataCategories{{EventType: timeline.EventAllergy}},
+		StartedAt:  now.Add(-time.Minute),
+		ExpiresAt:  now.Add(time.Hour),
+		Review:     consent.ReviewPending,
+	}
+	scope := Scope{Session: session}
+
+	if !scope.AllowsEvent("evt-1", timeline.EventAllergy, types.Codes{}) {
+		t.Error("AllowsEvent(allergy) = false, want true")
+	}
+	if scope.AllowsEvent("evt-2", timeline.EventLabResult, nil) {
+		t.Error("AllowsEvent(lab result) = true, want false")
+	}
+	if got := scope.GrantFor("evt-1", timeline.EventAllergy, nil); got != "" {
+		t.Errorf("GrantFor() = %q, want \"\" for a session-only read", got)
+	}
+
+	ended := now
+	session.EndedAt = &ended
+	if scope.AllowsEvent("evt-1", timeline.EventAllergy, nil) {
+		t.Error("AllowsEvent() allowed a read after the session ended")
+	}
+}
@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
@@ -8,8 +9,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/itspablomontes/fleming/apps/backend/internal/config"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
 	"github.com/itspablomontes/fleming/apps/backend/internal/auth"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/delegation"
+	"github.com/itspablomontes/fleming/apps/backend/internal/emergency"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocoldelegation "github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
 func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
@@ -56,9 +66,148 @@ func AuthMiddleware(authService *auth.Service) gin.HandlerFunc {
 	}
 }
 
+// ActAsHeader names the patient a guardian or caregiver is acting as.
+const ActAsHeader = "X-Act-As"
+
+// isWrite reports whether a request method changes data.
+func isWrite(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodDelete
+}
+
+// ActAsMiddleware lets a delegate act as the patient named in ActAsHeader. It requires
+// AuthMiddleware to have run first, and must run before ConsentMiddleware.
+//
+// Reads need the read permission and writes the write permission; an empty permission
+// means that kind of request cannot be delegated. When the caller holds an active
+// delegation carrying it, the patient becomes "user_address" for the rest of the
+// chain, the caller is kept as "delegate_address" and the delegation as
+// "delegation_id", and every audit entry recorded for the request names both.
+// Refused attempts are recorded so access monitoring can see them.
+func ActAsMiddleware(delegationService delegation.Service, auditService audit.Service, read, write protocoldelegation.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := strings.TrimSpace(c.GetHeader(ActAsHeader))
+		if principal == "" {
+			c.Next()
+			return
+		}
+
+		userAddress, _ := c.Get("user_address")
+		actor, ok := userAddress.(string)
+		if !ok || actor == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			c.Abort()
+			return
+		}
+		if strings.EqualFold(actor, principal) {
+			c.Next()
+			return
+		}
+		if _, err := types.NewWalletAddress(principal); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + ActAsHeader + " address"})
+			c.Abort()
+			return
+		}
+
+		permission := read
+		if isWrite(c.Request.Method) {
+			permission = write
+		}
+		if permission == "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: this action cannot be delegated"})
+			c.Abort()
+			return
+		}
+
+		d, err := delegationService.Resolve(c.Request.Context(), actor, principal, permission)
+		if errors.Is(err, delegation.ErrNoDelegation) {
+			slog.Warn("access denied: no valid delegation", "actor", actor, "principal", principal, "permission", permission)
+			metadata := common.JSONMap{
+				audit.MetadataSubject:    strings.ToLower(principal),
+				audit.MetadataDenyReason: string(audit.DenyNoDelegation),
+				"permission":             permission,
+				"method":                 c.Request.Method,
+				"path":                   c.FullPath(),
+			}
+			if err := auditService.Record(c.Request.Context(), actor, protocol.ActionAccessDeny, protocol.ResourceDelegation, strings.ToLower(principal), metadata); err != nil {
+				slog.Warn("failed to record refused delegation", "actor", actor, "principal", principal, "error", err)
+			}
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: you may not act for this patient"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			slog.Error("delegation check error", "actor", actor, "principal", principal, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify delegation"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_address", d.Principal)
+		c.Set("delegate_address", actor)
+		c.Set("delegation_id", d.ID)
+		c.Request = c.Request.WithContext(audit.WithDelegate(c.Request.Context(), strings.ToLower(actor), d.ID))
+		c.Next()
+	}
+}
+
+// ConsentGrantsHeader names the consent grant(s) that authorized a non-owner's request,
+// comma-separated.
+const ConsentGrantsHeader = "X-Consent-Grants"
+
+// EmergencySessionHeader names the break-glass session that authorized a non-owner's
+// request where no grant did.
+const EmergencySessionHeader = "X-Emergency-Session"
+
+// ConsentMiddleware hands timeline the caller's grants, or the grants and an emergency
+// session, as its access scope.
+var (
+	_ timeline.GrantResolver = consent.AccessGrants(nil)
+	_ timeline.GrantResolver = emergency.Scope{}
+)
+
+// Headers a non-owner declares the use of an access with; see consent.Use. Research
+// grants only serve requests naming their study.
+const (
+	ConsentPurposeHeader      = "X-Consent-Purpose"
+	ConsentStudyHeader        = "X-Consent-Study"
+	ConsentSecondaryUseHeader = "X-Consent-Secondary-Use"
+)
+
+// declaredUse reads the use a request declares, or reports false when a header names
+// an unknown purpose or secondary use.
+func declaredUse(c *gin.Context) (protocolconsent.Use, bool) {
+	use := protocolconsent.Use{
+		Purpose:      protocolconsent.Purpose(strings.TrimSpace(c.GetHeader(ConsentPurposeHeader))),
+		StudyID:      strings.TrimSpace(c.GetHeader(ConsentStudyHeader)),
+		SecondaryUse: protocolconsent.SecondaryUse(strings.TrimSpace(c.GetHeader(ConsentSecondaryUseHeader))),
+	}
+	if use.Purpose != "" && !use.Purpose.IsValid() {
+		return use, false
+	}
+	if use.SecondaryUse != "" && !use.SecondaryUse.IsValid() {
+		return use, false
+	}
+	return use, true
+}
+
 // ConsentMiddleware enforces patient-controlled access to medical data.
 // It requires AuthMiddleware to have run first.
-func ConsentMiddleware(consentService consent.Service) gin.HandlerFunc {
+//
+// Routes addressing a single event (":id") are checked against the event itself:
+// the event must belong to the target patient and, for non-owners, fall within the
+// scope and data categories of one of their active grants. Non-owners may declare the
+// use of an access with ConsentPurposeHeader, ConsentStudyHeader and
+// ConsentSecondaryUseHeader; only grants whose terms permit it apply. For every non-owner request those grants are
+// attached as "access_scope" so collection handlers can filter what they return, and
+// the authorizing grant's ID as "access_grant_id" so reads can be recorded against it;
+// ConsentGrantsHeader tells the caller which grants were used. Non-owners refused for
+// lack of consent or scope are recorded so access monitoring can see the attempt.
+//
+// A provider's active break-glass session extends their reads to the patient's
+// emergency categories for undeclared or treatment use. It is attached as
+// "emergency_session_id" only when it allowed something no grant did, so reads are
+// attributed to the session for the patient's review.
+func ConsentMiddleware(consentService consent.Service, timelineService timeline.Service, emergencyService emergency.Service, auditService audit.Service) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userAddress, _ := c.Get("user_address")
 		actor, ok := userAddress.(string)
@@ -72,19 +221,44 @@ func ConsentMiddleware(consentService consent.Service) gin.HandlerFunc {
 		if patientID == "" {
 			patientID = actor
 		}
+		isOwner := strings.EqualFold(actor, patientID)
 
-		if actor == patientID {
+		var event *timeline.TimelineEvent
+		if eventID := c.Param("id"); eventID != "" {
+			resolved, err := timelineService.GetEvent(c.Request.Context(), eventID)
+			if err != nil || resolved == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+				c.Abort()
+				return
+			}
+			if !strings.EqualFold(resolved.PatientID, patientID) {
+				slog.Warn("access denied: event outside patient timeline", "actor", actor, "patient", patientID, "event", eventID)
+				c.JSON(http.StatusForbidden, gin.H{"error": "access denied: event does not belong to this patient"})
+				c.Abort()
+				return
+			}
+			event = resolved
+		}
+
+		if isOwner {
 			c.Set("target_patient", patientID)
 			c.Next()
 			return
 		}
 
 		permission := "read"
-		if c.Request.Method == http.MethodPost || c.Request.Method == http.MethodPut || c.Request.Method == http.MethodDelete {
+		if isWrite(c.Request.Method) {
 			permission = "write"
 		}
 
-		allowed, err := consentService.CheckPermission(c.Request.Context(), patientID, actor, permission)
+		use, ok := declaredUse(c)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid consent purpose or secondary use"})
+			c.Abort()
+			return
+		}
+
+		grants, err := consentService.GetAccessGrants(c.Request.Context(), patientID, actor, permission)
 		if err != nil {
 			slog.Error("consent check error", "actor", actor, "patient", patientID, "error", err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify access permissions"})
@@ -92,14 +266,109 @@ func ConsentMiddleware(consentService consent.Service) gin.HandlerFunc {
 			return
 		}
 
-		if !allowed {
+		var session *emergency.Session
+		if permission == "read" {
+			session, err = emergencyService.ActiveSession(c.Request.Context(), patientID, actor)
+			if err != nil {
+				slog.Error("emergency access check error", "actor", actor, "patient", patientID, "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify access permissions"})
+				c.Abort()
+				return
+			}
+			if session != nil && !session.ToProtocol().Permits(use) {
+				session = nil
+			}
+		}
+
+		if len(grants) == 0 && session == nil {
 			slog.Warn("access denied: no valid consent", "actor", actor, "patient", patientID)
+			recordDenial(c, auditService, actor, patientID, permission, use, audit.DenyNoConsent, nil, event)
 			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: you do not have permission to access this patient's data"})
 			c.Abort()
 			return
 		}
 
+		permitted := grants.ForUse(use)
+		if len(permitted) == 0 && session == nil {
+			slog.Warn("access denied: consent does not permit declared use", "actor", actor, "patient", patientID, "purpose", use.Purpose, "study", use.StudyID, "grants", grants.IDs())
+			recordDenial(c, auditService, actor, patientID, permission, use, audit.DenyPurposeMismatch, grants, event)
+			c.JSON(http.StatusForbidden, gin.H{"error": "access denied: your consent does not permit this purpose"})
+			c.Abort()
+			return
+		}
+		grants = permitted
+
+		// Access is the union of the actor's grants. An event is attributed to the newest
+		// grant covering it; a collection starts out attributed to the newest grant and
+		// timeline narrows it to the grants behind the events it returns.
+		// A session only answers for what the grants leave uncovered.
+		var grantID string
+		if len(grants) > 0 {
+			grantID = grants[0].ID
+		}
+		useSession := session != nil
+		if event != nil {
+			grantID = grants.GrantFor(event.ID, event.Type, types.Codes(event.Codes))
+			useSession = grantID == "" && session != nil && session.AllowsEvent(event.ID, event.Type, types.Codes(event.Codes))
+			if grantID == "" && !useSession {
+				slog.Warn("access denied: event outside consent scope", "actor", actor, "patient", patientID, "event", event.ID, "grants", grants.IDs())
+				recordDenial(c, auditService, actor, patientID, permission, use, audit.DenyOutOfScope, grants, event)
+				c.JSON(http.StatusForbidden, gin.H{"error": "access denied: this event is outside the scope of your consent"})
+				c.Abort()
+				return
+			}
+			if grantID != "" {
+				c.Header(ConsentGrantsHeader, grantID)
+			}
+		} else if len(grants) > 0 {
+			c.Header(ConsentGrantsHeader, strings.Join(grants.IDs(), ","))
+		}
+
 		c.Set("target_patient", patientID)
+		c.Set("access_grant_id", grantID)
+		if useSession {
+			c.Header(EmergencySessionHeader, session.ID)
+			c.Set("access_scope", emergency.Scope{Grants: grants, Session: session})
+			c.Set("emergency_session_id", session.ID)
+		} else {
+			c.Set("access_scope", grants)
+		}
 		c.Next()
 	}
 }
+
+// recordDenial audits an access attempt refused by consent checks. The attempt is
+// recorded against the event when one was requested and otherwise against the
+// patient's consent. Out-of-scope attempts name the newest grant the actor holds.
+func recordDenial(c *gin.Context, auditService audit.Service, actor, patientID, permission string, use protocolconsent.Use, reason audit.DenyReason, grants consent.AccessGrants, event *timeline.TimelineEvent) {
+	resourceType, resourceID := protocol.ResourceConsent, patientID
+	metadata := common.JSONMap{
+		audit.MetadataSubject:    patientID,
+		audit.MetadataDenyReason: string(reason),
+		"permission":             permission,
+		"method":                 c.Request.Method,
+		"path":                   c.FullPath(),
+	}
+	if use.Purpose != "" {
+		metadata["purpose"] = use.Purpose
+	}
+	if use.StudyID != "" {
+		metadata["studyId"] = use.StudyID
+	}
+	if use.SecondaryUse != "" {
+		metadata["secondaryUse"] = use.SecondaryUse
+	}
+	if len(grants) > 0 {
+		metadata[audit.MetadataGrantID] = grants[0].ID
+	}
+	if len(grants) > 1 {
+		metadata[audit.MetadataGrantIDs] = grants.IDs()
+	}
+	if event != nil {
+		resourceType, resourceID = protocol.ResourceEvent, event.ID
+		metadata[audit.MetadataEventID] = event.ID
+	}
+	if err := auditService.Record(c.Request.Context(), actor, protocol.ActionAccessDeny, resourceType, resourceID, metadata); err != nil {
+		slog.Warn("failed to record refused access", "actor", actor, "patient", patientID, "error", err)
+	}
+}
@@ -0,0 +1,397 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/delegation"
+	"github.com/itspablomontes/fleming/apps/backend/internal/emergency"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocoldelegation "github.com/itspablomontes/fleming/pkg/protocol/delegation"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+)
+
+const (
+	patient      = "0x1111111111111111111111111111111111111111"
+	grantee      = "0x2222222222222222222222222222222222222222"
+	caregiver    = "0x3333333333333333333333333333333333333333"
+	otherPatient = "0x4444444444444444444444444444444444444444"
+	provider     = "0x5555555555555555555555555555555555555555"
+)
+
+// Each fake embeds the service it stands in for and implements only what the
+// middleware calls; anything else panics.
+
+type fakeDelegations struct {
+	delegation.Service
+	delegations []delegation.Delegation
+}
+
+func (f *fakeDelegations) Resolve(ctx context.Context, delegate, principal string, permission protocoldelegation.Permission) (*delegation.Delegation, error) {
+	for i := range f.delegations {
+		d := f.delegations[i]
+		if strings.EqualFold(d.Delegate, delegate) && strings.EqualFold(d.Principal, principal) && slices.Contains(d.Permissions, string(permission)) {
+			return &d, nil
+		}
+	}
+	return nil, delegation.ErrNoDelegation
+}
+
+type fakeConsent struct {
+	consent.Service
+	grants []consent.ConsentGrant // Newest first
+}
+
+func (f *fakeConsent) GetAccessGrants(ctx context.Context, grantor, grantee, permission string) (consent.AccessGrants, error) {
+	var grants consent.AccessGrants
+	for _, grant := range f.grants {
+		if strings.EqualFold(grant.Grantor, grantor) && strings.EqualFold(grant.Grantee, grantee) && slices.Contains(grant.Permissions, permission) {
+			grants = append(grants, grant)
+		}
+	}
+	return grants, nil
+}
+
+type fakeTimeline struct {
+	timeline.Service
+	events map[string]*timeline.TimelineEvent
+}
+
+func (f *fakeTimeline) GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error) {
+	if event, ok := f.events[id]; ok {
+		return event, nil
+	}
+	return nil, nil
+}
+
+type fakeEmergency struct {
+	emergency.Service
+	sessions []emergency.Session
+}
+
+func (f *fakeEmergency) ActiveSession(ctx context.Context, patientID, provider string) (*emergency.Session, error) {
+	for i := range f.sessions {
+		s := f.sessions[i]
+		if strings.EqualFold(s.PatientID, patientID) && strings.EqualFold(s.Provider, provider) && s.IsActive(time.Now()) {
+			return &s, nil
+		}
+	}
+	return nil, nil
+}
+
+type fixture struct {
+	delegations *fakeDelegations
+	consent     *fakeConsent
+	emergency   *fakeEmergency
+	audit       *audittest.Service
+	router      *gin.Engine
+}
+
+// newFixture wires the middleware the way the router does. The caller is named by
+// the X-Test-User header in place of AuthMiddleware, and every handler echoes what
+// the middleware attached to the request.
+func newFixture() *fixture {
+	gin.SetMode(gin.TestMode)
+	f := &fixture{
+		delegations: &fakeDelegations{},
+		consent:     &fakeConsent{},
+		emergency:   &fakeEmergency{},
+		audit:       &audittest.Service{},
+		router:      gin.New(),
+	}
+	events := &fakeTimeline{events: map[string]*timeline.TimelineEvent{
+		"evt-allergy": {ID: "evt-allergy", PatientID: patient, Type: protocoltimeline.EventAllergy},
+		"evt-lab":     {ID: "evt-lab", PatientID: patient, Type: protocoltimeline.EventLabResult},
+		"evt-other":   {ID: "evt-other", PatientID: otherPatient, Type: protocoltimeline.EventAllergy},
+	}}
+
+	f.router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set("user_address", user)
+		}
+		c.Next()
+	})
+	echo := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"user":      c.GetString("user_address"),
+			"delegate":  c.GetString("delegate_address"),
+			"patient":   c.GetString("target_patient"),
+			"grant":     c.GetString("access_grant_id"),
+			"emergency": c.GetString("emergency_session_id"),
+		})
+	}
+
+	timelineGroup := f.router.Group("/timeline")
+	timelineGroup.Use(
+		ActAsMiddleware(f.delegations, f.audit, protocoldelegation.PermTimelineRead, protocoldelegation.PermTimelineWrite),
+		ConsentMiddleware(f.consent, events, f.emergency, f.audit),
+	)
+	timelineGroup.GET("", echo)
+	timelineGroup.GET("/events/:id", echo)
+	timelineGroup.POST("/events/:id", echo)
+
+	consentGroup := f.router.Group("/consent")
+	consentGroup.Use(ActAsMiddleware(f.delegations, f.audit, protocoldelegation.PermConsentManage, protocoldelegation.PermConsentManage))
+	consentGroup.POST("/:id/approve", echo)
+
+	auditGroup := f.router.Group("/audit")
+	auditGroup.Use(ActAsMiddleware(f.delegations, f.audit, protocoldelegation.PermAuditRead, ""))
+	auditGroup.GET("", echo)
+	auditGroup.POST("/batch", echo)
+	return f
+}
+
+type response struct {
+	status  int
+	header  http.Header
+	context map[string]string
+}
+
+func (f *fixture) do(t *testing.T, method, path, user string, headers map[string]string) response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if user != "" {
+		req.Header.Set("X-Test-User", user)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	f.router.ServeHTTP(rec, req)
+
+	res := response{status: rec.Code, header: rec.Header()}
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &res.context); err != nil {
+			t.Fatalf("decode %s %s response: %v", method, path, err)
+		}
+	}
+	return res
+}
+
+// lastDenial returns the reason of the most recent access.deny entry, or "".
+func (f *fixture) lastDenial() audit.DenyReason {
+	denials := f.audit.WithAction(protocol.ActionAccessDeny)
+	if len(denials) == 0 {
+		return ""
+	}
+	reason, _ := denials[len(denials)-1].Metadata[audit.MetadataDenyReason].(string)
+	return audit.DenyReason(reason)
+}
+
+func grant(id string, permissions []string, scope []string) consent.ConsentGrant {
+	return consent.ConsentGrant{
+		ID:          id,
+		Grantor:     patient,
+		Grantee:     grantee,
+		State:       protocolconsent.StateApproved,
+		Permissions: common.JSONStrings(permissions),
+		Scope:       common.JSONStrings(scope),
+	}
+}
+
+func TestActAsMiddleware(t *testing.T) {
+	f := newFixture()
+	f.delegations.delegations = []delegation.Delegation{{
+		ID:          "del-1",
+		Principal:   patient,
+		Delegate:    caregiver,
+		Permissions: common.JSONStrings{string(protocoldelegation.PermTimelineRead), string(protocoldelegation.PermAuditRead)},
+	}}
+	actAs := map[string]string{ActAsHeader: patient}
+
+	res := f.do(t, http.MethodGet, "/timeline", caregiver, actAs)
+	if res.status != http.StatusOK {
+		t.Fatalf("delegated read status = %d, want 200", res.status)
+	}
+	if res.context["user"] != patient || res.context["delegate"] != caregiver || res.context["patient"] != patient {
+		t.Errorf("delegated read context = %v, want the patient acted for by the caregiver", res.context)
+	}
+
+	if res := f.do(t, http.MethodGet, "/timeline", caregiver, nil); res.context["user"] != caregiver || res.context["delegate"] != "" {
+		t.Errorf("read without %s context = %v, want the caregiver acting as themselves", ActAsHeader, res.context)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		headers    map[string]string
+		wantStatus int
+		wantDenial bool
+	}{
+		{"write without timeline.write", http.MethodPost, "/timeline/events/evt-lab", actAs, http.StatusForbidden, true},
+		{"consent change without consent.manage", http.MethodPost, "/consent/grant-1/approve", actAs, http.StatusForbidden, true},
+		{"action that cannot be delegated", http.MethodPost, "/audit/batch", actAs, http.StatusForbidden, false},
+		{"invalid principal", http.MethodGet, "/timeline", map[string]string{ActAsHeader: "patient"}, http.StatusBadRequest, false},
+		{"principal without a delegation", http.MethodGet, "/timeline", map[string]string{ActAsHeader: otherPatient}, http.StatusForbidden, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.audit.Reset()
+			if res := f.do(t, tt.method, tt.path, caregiver, tt.headers); res.status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.status, tt.wantStatus)
+			}
+			if got := f.lastDenial(); (got == audit.DenyNoDelegation) != tt.wantDenial {
+				t.Errorf("recorded denial = %q, want recorded %t", got, tt.wantDenial)
+			}
+		})
+	}
+
+	f.delegations.delegations[0].Permissions = append(f.delegations.delegations[0].Permissions, string(protocoldelegation.PermConsentManage))
+	if res := f.do(t, http.MethodPost, "/consent/grant-1/approve", caregiver, actAs); res.status != http.StatusOK || res.context["user"] != patient {
+		t.Errorf("consent change with consent.manage = %d %v, want 200 as the patient", res.status, res.context)
+	}
+}
+
+func TestConsentMiddleware_Grants(t *testing.T) {
+	f := newFixture()
+	f.consent.grants = []consent.ConsentGrant{
+		grant("grant-lab", []string{"read"}, []string{"evt-lab"}),
+		grant("grant-allergy", []string{"read", "write"}, []string{"evt-allergy"}),
+	}
+	forPatient := "?patientId=" + patient
+
+	if res := f.do(t, http.MethodGet, "/timeline/events/evt-lab", patient, nil); res.status != http.StatusOK || res.context["grant"] != "" {
+		t.Errorf("owner read = %d %v, want 200 without a grant", res.status, res.context)
+	}
+
+	for eventID, wantGrant := range map[string]string{"evt-lab": "grant-lab", "evt-allergy": "grant-allergy"} {
+		res := f.do(t, http.MethodGet, "/timeline/events/"+eventID+forPatient, grantee, nil)
+		if res.status != http.StatusOK || res.context["grant"] != wantGrant || res.header.Get(ConsentGrantsHeader) != wantGrant {
+			t.Errorf("read %s = %d %v, want 200 under %s", eventID, res.status, res.context, wantGrant)
+		}
+	}
+
+	res := f.do(t, http.MethodGet, "/timeline"+forPatient, grantee, nil)
+	if res.status != http.StatusOK || res.header.Get(ConsentGrantsHeader) != "grant-lab,grant-allergy" {
+		t.Errorf("collection read = %d, %s %q, want 200 under both grants", res.status, ConsentGrantsHeader, res.header.Get(ConsentGrantsHeader))
+	}
+
+	if res := f.do(t, http.MethodPost, "/timeline/events/evt-allergy"+forPatient, grantee, nil); res.status != http.StatusOK || res.context["grant"] != "grant-allergy" {
+		t.Errorf("write under a write grant = %d %v, want 200", res.status, res.context)
+	}
+	if res := f.do(t, http.MethodPost, "/timeline/events/evt-lab"+forPatient, grantee, nil); res.status != http.StatusForbidden || f.lastDenial() != audit.DenyOutOfScope {
+		t.Errorf("write outside the write grant's scope = %d, denial %q, want 403 out_of_scope", res.status, f.lastDenial())
+	}
+
+	f.consent.grants = f.consent.grants[:1]
+	if res := f.do(t, http.MethodGet, "/timeline/events/evt-allergy"+forPatient, grantee, nil); res.status != http.StatusForbidden || f.lastDenial() != audit.DenyOutOfScope {
+		t.Errorf("read outside scope = %d, denial %q, want 403 out_of_scope", res.status, f.lastDenial())
+	}
+	if res := f.do(t, http.MethodGet, "/timeline/events/evt-other"+forPatient, grantee, nil); res.status != http.StatusForbidden {
+		t.Errorf("read of another patient's event = %d, want 403", res.status)
+	}
+	if res := f.do(t, http.MethodGet, "/timeline/events/evt-missing"+forPatient, grantee, nil); res.status != http.StatusNotFound {
+		t.Errorf("read of a missing event = %d, want 404", res.status)
+	}
+	if res := f.do(t, http.MethodGet, "/timeline"+forPatient, caregiver, nil); res.status != http.StatusForbidden || f.lastDenial() != audit.DenyNoConsent {
+		t.Errorf("read without a grant = %d, denial %q, want 403 no_consent", res.status, f.lastDenial())
+	}
+}
+
+func TestConsentMiddleware_Purpose(t *testing.T) {
+	f := newFixture()
+	research := grant("grant-research", []string{"read"}, nil)
+	research.Purpose = protocolconsent.PurposeResearch
+	research.StudyID = "NCT01234567"
+	research.SecondaryUses = common.JSONStrings{string(protocolconsent.SecondaryUsePublication)}
+	f.consent.grants = []consent.ConsentGrant{research}
+	path := "/timeline/events/evt-lab?patientId=" + patient
+
+	study := func(id string, extra ...string) map[string]string {
+		headers := map[string]string{ConsentPurposeHeader: string(protocolconsent.PurposeResearch), ConsentStudyHeader: id}
+		if len(extra) > 0 {
+			headers[ConsentSecondaryUseHeader] = extra[0]
+		}
+		return headers
+	}
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"undeclared", nil, http.StatusForbidden},
+		{"declared study", study("NCT01234567"), http.StatusOK},
+		{"other study", study("NCT99999999"), http.StatusForbidden},
+		{"permitted secondary use", study("NCT01234567", string(protocolconsent.SecondaryUsePublication)), http.StatusOK},
+		{"secondary use not permitted", study("NCT01234567", string(protocolconsent.SecondaryUseModelTraining)), http.StatusForbidden},
+		{"treatment", map[string]string{ConsentPurposeHeader: string(protocolconsent.PurposeTreatment)}, http.StatusForbidden},
+		{"unknown purpose", map[string]string{ConsentPurposeHeader: "marketing"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f.audit.Reset()
+			res := f.do(t, http.MethodGet, path, grantee, tt.headers)
+			if res.status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", res.status, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusForbidden && f.lastDenial() != audit.DenyPurposeMismatch {
+				t.Errorf("recorded denial = %q, want %q", f.lastDenial(), audit.DenyPurposeMismatch)
+			}
+		})
+	}
+}
+
+func TestConsentMiddleware_EmergencySession(t *testing.T) {
+	f := newFixture()
+	now := time.Now()
+	f.emergency.sessions = []emergency.Session{{
+		ID:         "session-1",
+		PatientID:  patient,
+		Provider:   provider,
+		Categories: common.JSONDataCategories{{EventType: protocoltimeline.EventAllergy}},
+		StartedAt:  now.Add(-time.Minute),
+		ExpiresAt:  now.Add(time.Hour),
+		Review:     protocolconsent.ReviewPending,
+	}}
+	forPatient := "?patientId=" + patient
+
+	res := f.do(t, http.MethodGet, "/timeline/events/evt-allergy"+forPatient, provider, nil)
+	if res.status != http.StatusOK || res.context["emergency"] != "session-1" || res.header.Get(EmergencySessionHeader) != "session-1" {
+		t.Fatalf("emergency read = %d %v, want 200 under the session", res.status, res.context)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+	}{
+		{"write", http.MethodPost, "/timeline/events/evt-allergy" + forPatient, nil},
+		{"outside the emergency categories", http.MethodGet, "/timeline/events/evt-lab" + forPatient, nil},
+		{"research use", http.MethodGet, "/timeline/events/evt-allergy" + forPatient, map[string]string{
+			ConsentPurposeHeader: string(protocolconsent.PurposeResearch),
+			ConsentStudyHeader:   "NCT01234567",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := f.do(t, tt.method, tt.path, provider, tt.headers); res.status != http.StatusForbidden {
+				t.Errorf("status = %d, want 403", res.status)
+			}
+		})
+	}
+
+	// A grant covering the event takes precedence, so the read is not attributed to
+	// the session.
+	f.consent.grants = []consent.ConsentGrant{grant("grant-allergy", []string{"read"}, []string{"evt-allergy"})}
+	f.consent.grants[0].Grantee = provider
+	res = f.do(t, http.MethodGet, "/timeline/events/evt-allergy"+forPatient, provider, nil)
+	if res.status != http.StatusOK || res.context["grant"] != "grant-allergy" || res.context["emergency"] != "" {
+		t.Errorf("read covered by a grant = %d %v, want 200 under the grant alone", res.status, res.context)
+	}
+}
@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// heartbeatInterval keeps idle streams open through proxies that drop silent connections.
+const heartbeatInterval = 25 * time.Second
+
+type Handler struct {
+	hub *Hub
+}
+
+func NewHandler(hub *Hub) *Handler {
+	return &Handler{hub: hub}
+}
+
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	rg.GET("/stream", h.HandleStream)
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// HandleStream streams the caller's audit, consent and timeline messages as
+// server-sent events. Browsers resume with the Last-Event-ID header; clients that
+// cannot set it may pass ?lastEventId= instead.
+func (h *Handler) HandleStream(c *gin.Context) {
+	user, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	lastEventID := strings.TrimSpace(c.GetHeader("Last-Event-ID"))
+	if lastEventID == "" {
+		lastEventID = strings.TrimSpace(c.Query("lastEventId"))
+	}
+
+	sub := h.hub.Subscribe(user)
+	defer sub.Close()
+
+	replay, reset, err := sub.Replay(c.Request.Context(), lastEventID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resume stream"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if reset != nil {
+		render(c, *reset)
+	}
+	for _, msg := range replay {
+		render(c, msg)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case msg, ok := <-sub.messages:
+			if !ok {
+				return false
+			}
+			if sub.advance(msg) {
+				render(c, msg)
+			}
+			return true
+		case <-heartbeat.C:
+			_, err := io.WriteString(w, ": keepalive\n\n")
+			return err == nil
+		}
+	})
+}
+
+func render(c *gin.Context, msg Message) {
+	c.Render(-1, sse.Event{Id: msg.ID, Event: string(msg.Kind), Data: msg})
+}
@@ -0,0 +1,384 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+const (
+	// pageSize is how many chain entries are read per query.
+	pageSize = 500
+	// maxReplayEntries bounds how far back Last-Event-ID can resume; longer gaps get
+	// a reset message instead.
+	maxReplayEntries = 5000
+	// subscriberBuffer is how many messages a slow client may fall behind before it is
+	// disconnected to resume with Last-Event-ID.
+	subscriberBuffer = 64
+)
+
+// ConsentReader looks up the grants messages are filtered by. Event visibility is
+// decided by CheckEventPermission, as for the timeline API, with no declared use: a
+// stream is not tied to a study, so research grants do not stream events.
+type ConsentReader interface {
+	GetGrantByID(ctx context.Context, grantID string) (*consent.ConsentGrant, error)
+	GetGrantsByGrantor(ctx context.Context, grantor string) ([]consent.ConsentGrant, error)
+	CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use protocolconsent.Use, eventID string, eventType protocoltimeline.EventType, codes types.Codes) (bool, error)
+}
+
+// EventReader loads the timeline events audit entries refer to.
+type EventReader interface {
+	GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error)
+}
+
+// Hub follows the audit chain and fans each new entry out to the subscribers allowed
+// to see it. The chain is the event log: appends are serialized across replicas, so
+// every replica's hub sees the same entries in the same order, and Last-Event-ID is
+// simply an entry ID.
+type Hub struct {
+	auditService audit.Service
+	grants       ConsentReader
+	events       EventReader
+	listener     Listener
+
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	wake        chan struct{}
+
+	// Owned by the dispatch goroutine.
+	started bool
+	cursor  *audit.ChainCursor
+}
+
+// NewHub creates a hub that is woken by listener when entries are appended.
+func NewHub(auditService audit.Service, grants ConsentReader, events EventReader, listener Listener) *Hub {
+	return &Hub{
+		auditService: auditService,
+		grants:       grants,
+		events:       events,
+		listener:     listener,
+		subscribers:  make(map[*Subscription]struct{}),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Start dispatches appended entries until ctx is done. Besides reacting to
+// notifications it reads the chain every pollInterval, in case one was lost.
+func (h *Hub) Start(ctx context.Context, pollInterval time.Duration) {
+	go h.listener.Listen(ctx, audit.NotifyChannel, h.notify)
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		defer h.closeAll()
+		for {
+			if err := h.dispatch(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("Audit stream dispatch failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-h.wake:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// notify wakes the dispatcher; wakes that arrive while it is busy are coalesced.
+func (h *Hub) notify() {
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch publishes every entry appended since the last call. The first call only
+// finds the chain head: streams start live, and history comes from Last-Event-ID.
+func (h *Hub) dispatch(ctx context.Context) error {
+	if !h.started {
+		head, err := h.head(ctx)
+		if err != nil {
+			return err
+		}
+		h.cursor = head
+		h.started = true
+		return nil
+	}
+
+	for {
+		page, err := h.auditService.ListChainSince(ctx, h.cursor, pageSize)
+		if err != nil {
+			return err
+		}
+		for i := range page {
+			h.publish(ctx, &page[i])
+			position := cursorOf(&page[i])
+			h.cursor = &position
+		}
+		if len(page) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (h *Hub) head(ctx context.Context) (*audit.ChainCursor, error) {
+	latest, err := h.auditService.GetLatestEntries(ctx, "", 1)
+	if err != nil {
+		return nil, fmt.Errorf("read chain head: %w", err)
+	}
+	if len(latest) == 0 {
+		return nil, nil
+	}
+	position := cursorOf(&latest[0])
+	return &position, nil
+}
+
+func (h *Hub) publish(ctx context.Context, entry *audit.AuditEntry) {
+	h.mu.Lock()
+	subscribers := make([]*Subscription, 0, len(h.subscribers))
+	for sub := range h.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	h.mu.Unlock()
+	if len(subscribers) == 0 {
+		return
+	}
+
+	r := h.resolve(ctx, entry)
+	for _, sub := range subscribers {
+		msg, ok := h.messageFor(ctx, r, sub.user)
+		if !ok {
+			continue
+		}
+		select {
+		case sub.messages <- msg:
+		default:
+			slog.Warn("Audit stream subscriber fell behind; disconnecting", "user", sub.user)
+			h.remove(sub)
+			sub.close()
+		}
+	}
+}
+
+// Subscribe registers user for live messages. Call Replay before reading them when
+// resuming, and Close when done.
+func (h *Hub) Subscribe(user string) *Subscription {
+	sub := &Subscription{
+		hub:      h,
+		user:     user,
+		messages: make(chan Message, subscriberBuffer),
+	}
+	h.mu.Lock()
+	h.subscribers[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	delete(h.subscribers, sub)
+	h.mu.Unlock()
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	subscribers := h.subscribers
+	h.subscribers = make(map[*Subscription]struct{})
+	h.mu.Unlock()
+	for sub := range subscribers {
+		sub.close()
+	}
+}
+
+// resolved is an entry together with the grant or event it changed, shared by every
+// subscriber it is checked against.
+type resolved struct {
+	entry audit.AuditEntry
+	grant *consent.ConsentGrant
+	event *timeline.TimelineEvent
+
+	grantees map[string]bool // Lowercased grantees of the event's patient, loaded on first use
+	readers  map[string]bool // Lowercased users already checked against the event
+}
+
+func (h *Hub) resolve(ctx context.Context, entry *audit.AuditEntry) *resolved {
+	r := &resolved{entry: *entry}
+	switch {
+	case entry.ResourceType == protocol.ResourceConsent && strings.HasPrefix(string(entry.Action), "consent."):
+		grant, err := h.grants.GetGrantByID(ctx, entry.ResourceID)
+		if err == nil {
+			r.grant = grant
+		}
+	case entry.ResourceType == protocol.ResourceEvent && isEventChange(entry.Action):
+		event, err := h.events.GetEvent(ctx, entry.ResourceID)
+		if err == nil {
+			r.event = event
+		}
+	}
+	return r
+}
+
+func isEventChange(action protocol.Action) bool {
+	return action == protocol.ActionCreate || action == protocol.ActionUpdate || action == protocol.ActionDelete
+}
+
+// messageFor builds user's view of a resolved entry. The entry itself is visible to
+// its actor and, like the access report, to the patient it concerns; a grant to both
+// of its parties; an event to its patient and to grantees whose read consent covers it.
+func (h *Hub) messageFor(ctx context.Context, r *resolved, user string) (Message, bool) {
+	msg := Message{ID: r.entry.ID, Kind: KindAudit, position: cursorOf(&r.entry)}
+
+	if strings.EqualFold(r.entry.Actor, user) || (r.entry.Subject != "" && r.entry.Subject == strings.ToLower(user)) {
+		msg.Entry = &r.entry
+	}
+	if r.grant != nil && (strings.EqualFold(r.grant.Grantor, user) || strings.EqualFold(r.grant.Grantee, user)) {
+		msg.Kind = KindConsent
+		msg.Grant = r.grant
+	}
+	if r.event != nil && h.canReadEvent(ctx, r, user) {
+		msg.Kind = KindTimeline
+		msg.Event = r.event
+	}
+	return msg, msg.Entry != nil || msg.Grant != nil || msg.Event != nil
+}
+
+func (h *Hub) canReadEvent(ctx context.Context, r *resolved, user string) bool {
+	if strings.EqualFold(r.event.PatientID, user) {
+		return true
+	}
+
+	if r.grantees == nil {
+		r.grantees = make(map[string]bool)
+		r.readers = make(map[string]bool)
+		grants, err := h.grants.GetGrantsByGrantor(ctx, r.event.PatientID)
+		if err != nil {
+			slog.Error("Audit stream failed to load grants", "patient", r.event.PatientID, "error", err)
+		}
+		for _, grant := range grants {
+			if grant.State == protocolconsent.StateApproved {
+				r.grantees[strings.ToLower(grant.Grantee)] = true
+			}
+		}
+	}
+
+	key := strings.ToLower(user)
+	if !r.grantees[key] {
+		return false
+	}
+	if allowed, seen := r.readers[key]; seen {
+		return allowed
+	}
+	allowed, err := h.grants.CheckEventPermission(ctx, r.event.PatientID, user, string(protocolconsent.PermRead), protocolconsent.Use{}, r.event.ID, r.event.Type, types.Codes(r.event.Codes))
+	r.readers[key] = err == nil && allowed
+	return r.readers[key]
+}
+
+// Subscription is one client's stream. Messages arrive in chain order; the channel is
+// closed when the client falls too far behind or the hub stops.
+type Subscription struct {
+	hub      *Hub
+	user     string
+	messages chan Message
+	once     sync.Once
+
+	// cursor is the last position delivered to the client; live messages at or before
+	// it were already replayed and are skipped.
+	cursor *audit.ChainCursor
+}
+
+// Close unregisters the subscription.
+func (s *Subscription) Close() {
+	s.hub.remove(s)
+}
+
+func (s *Subscription) close() {
+	s.once.Do(func() { close(s.messages) })
+}
+
+// Replay returns the user's messages after the entry lastEventID names, up to the
+// chain head. It reports reset, with a reset message to send in their place, when the
+// ID is unknown or the gap exceeds maxReplayEntries; the stream then continues from
+// the head.
+func (s *Subscription) Replay(ctx context.Context, lastEventID string) ([]Message, *Message, error) {
+	if lastEventID == "" {
+		return nil, nil, nil
+	}
+
+	var last *audit.AuditEntry
+	if uuid.Validate(lastEventID) == nil {
+		var err error
+		last, err = s.hub.auditService.GetEntryByID(ctx, lastEventID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("replay from %s: %w", lastEventID, err)
+		}
+	}
+	if last == nil {
+		reset, err := s.reset(ctx)
+		return nil, reset, err
+	}
+
+	var messages []Message
+	cursor := cursorOf(last)
+	for scanned := 0; ; {
+		page, err := s.hub.auditService.ListChainSince(ctx, &cursor, pageSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("replay from %s: %w", lastEventID, err)
+		}
+		for i := range page {
+			if msg, ok := s.hub.messageFor(ctx, s.hub.resolve(ctx, &page[i]), s.user); ok {
+				messages = append(messages, msg)
+			}
+			cursor = cursorOf(&page[i])
+		}
+		scanned += len(page)
+		if len(page) < pageSize {
+			break
+		}
+		if scanned >= maxReplayEntries {
+			reset, err := s.reset(ctx)
+			return nil, reset, err
+		}
+	}
+	s.cursor = &cursor
+	return messages, nil, nil
+}
+
+// reset moves the subscription to the chain head. The reset message carries the
+// head's ID so a client that reconnects afterwards resumes from there.
+func (s *Subscription) reset(ctx context.Context) (*Message, error) {
+	head, err := s.hub.head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.cursor = head
+	msg := &Message{Kind: KindReset}
+	if head != nil {
+		msg.ID = head.ID
+		msg.position = *head
+	}
+	return msg, nil
+}
+
+// advance reports whether a live message is new to the client and records it as
+// delivered.
+func (s *Subscription) advance(msg Message) bool {
+	if s.cursor != nil && !after(msg.position, *s.cursor) {
+		return false
+	}
+	position := msg.position
+	s.cursor = &position
+	return true
+}
@@ -0,0 +1,274 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"gorm.io/gorm"
+)
+
+const (
+	testPatient  = "0x00000000000000000000000000000000000000AA"
+	testDoctor   = "0x00000000000000000000000000000000000000d1"
+	testStranger = "0x00000000000000000000000000000000000000e5"
+)
+
+// mockAuditService serves an in-memory chain in append order.
+type mockAuditService struct {
+	audittest.Service
+	entries []audit.AuditEntry
+	base    time.Time
+}
+
+func (m *mockAuditService) add(actor string, action protocol.Action, resourceType protocol.ResourceType, resourceID string, subject string) audit.AuditEntry {
+	entry := audit.AuditEntry{
+		ID:           fmt.Sprintf("00000000-0000-0000-0000-%012d", len(m.entries)+1),
+		Actor:        actor,
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Timestamp:    m.base.Add(time.Duration(len(m.entries)) * time.Second),
+		Subject:      strings.ToLower(subject),
+	}
+	m.entries = append(m.entries, entry)
+	return entry
+}
+
+func (m *mockAuditService) GetLatestEntries(ctx context.Context, actor string, limit int) ([]audit.AuditEntry, error) {
+	if len(m.entries) == 0 {
+		return nil, nil
+	}
+	return []audit.AuditEntry{m.entries[len(m.entries)-1]}, nil
+}
+
+func (m *mockAuditService) GetEntryByID(ctx context.Context, id string) (*audit.AuditEntry, error) {
+	for _, entry := range m.entries {
+		if entry.ID == id {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockAuditService) ListChainSince(ctx context.Context, cursor *audit.ChainCursor, limit int) ([]audit.AuditEntry, error) {
+	var result []audit.AuditEntry
+	for _, entry := range m.entries {
+		if cursor != nil && !after(cursorOf(&entry), *cursor) {
+			continue
+		}
+		result = append(result, entry)
+		if limit > 0 && len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+type mockConsent struct {
+	grants map[string]*consent.ConsentGrant
+}
+
+func (m *mockConsent) GetGrantByID(ctx context.Context, grantID string) (*consent.ConsentGrant, error) {
+	grant, ok := m.grants[grantID]
+	if !ok {
+		return nil, fmt.Errorf("get consent grant %s: %w", grantID, gorm.ErrRecordNotFound)
+	}
+	return grant, nil
+}
+
+func (m *mockConsent) GetGrantsByGrantor(ctx context.Context, grantor string) ([]consent.ConsentGrant, error) {
+	var grants []consent.ConsentGrant
+	for _, grant := range m.grants {
+		if grant.Grantor == grantor {
+			grants = append(grants, *grant)
+		}
+	}
+	return grants, nil
+}
+
+func (m *mockConsent) CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use protocolconsent.Use, eventID string, eventType protocoltimeline.EventType, codes types.Codes) (bool, error) {
+	for _, grant := range m.grants {
+		if grant.Grantor == grantor && grant.Grantee == grantee && grant.State == protocolconsent.StateApproved {
+			return grant.Terms().Permits(use) && grant.AllowsEvent(eventID, eventType, codes), nil
+		}
+	}
+	return false, nil
+}
+
+type mockEvents struct {
+	events map[string]*timeline.TimelineEvent
+}
+
+func (m *mockEvents) GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error) {
+	event, ok := m.events[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return event, nil
+}
+
+type noopListener struct{}
+
+func (noopListener) Listen(ctx context.Context, channel string, notify func()) {}
+
+func newTestHub(chain *mockAuditService) *Hub {
+	grants := &mockConsent{grants: map[string]*consent.ConsentGrant{
+		"grant-1": {ID: "grant-1", Grantor: testPatient, Grantee: testDoctor, State: protocolconsent.StateApproved, Scope: common.JSONStrings{string(protocoltimeline.EventLabResult)}},
+	}}
+	events := &mockEvents{events: map[string]*timeline.TimelineEvent{
+		"event-lab":   {ID: "event-lab", PatientID: testPatient, Type: protocoltimeline.EventLabResult},
+		"event-visit": {ID: "event-visit", PatientID: testPatient, Type: protocoltimeline.EventVisitNote},
+	}}
+	return NewHub(chain, grants, events, noopListener{})
+}
+
+func drain(sub *Subscription) []Message {
+	var messages []Message
+	for {
+		select {
+		case msg := <-sub.messages:
+			if sub.advance(msg) {
+				messages = append(messages, msg)
+			}
+		default:
+			return messages
+		}
+	}
+}
+
+func kinds(messages []Message) string {
+	var parts []string
+	for _, msg := range messages {
+		part := string(msg.Kind)
+		if msg.Entry != nil {
+			part += "+entry"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestHub_FiltersByAudience(t *testing.T) {
+	ctx := context.Background()
+	chain := &mockAuditService{base: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	chain.add(testPatient, protocol.ActionLogin, protocol.ResourceSession, "session-0", "")
+	hub := newTestHub(chain)
+
+	patient := hub.Subscribe(strings.ToLower(testPatient))
+	doctor := hub.Subscribe(testDoctor)
+	stranger := hub.Subscribe(testStranger)
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	chain.add(testPatient, protocol.ActionConsentApprove, protocol.ResourceConsent, "grant-1", "")
+	chain.add(testPatient, protocol.ActionCreate, protocol.ResourceEvent, "event-lab", "")
+	chain.add(testPatient, protocol.ActionCreate, protocol.ResourceEvent, "event-visit", "")
+	chain.add(testDoctor, protocol.ActionRead, protocol.ResourceEvent, "event-lab", testPatient)
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	// The patient sees everything; the session entry before the hub started is not replayed.
+	if got, want := kinds(drain(patient)), "consent+entry,timeline+entry,timeline+entry,audit+entry"; got != want {
+		t.Errorf("patient messages = %s, want %s", got, want)
+	}
+	// The grantee sees the grant and the in-scope event, but not the patient's own entries.
+	if got, want := kinds(drain(doctor)), "consent,timeline,audit+entry"; got != want {
+		t.Errorf("doctor messages = %s, want %s", got, want)
+	}
+	if got := drain(stranger); len(got) != 0 {
+		t.Errorf("stranger messages = %s, want none", kinds(got))
+	}
+}
+
+func TestSubscription_Replay(t *testing.T) {
+	ctx := context.Background()
+	chain := &mockAuditService{base: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	seen := chain.add(testPatient, protocol.ActionCreate, protocol.ResourceEvent, "event-lab", "")
+	hub := newTestHub(chain)
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	// The client reconnects after two entries were appended; the hub dispatches a
+	// third while the replay is running and again afterwards.
+	sub := hub.Subscribe(testPatient)
+	chain.add(testPatient, protocol.ActionCreate, protocol.ResourceEvent, "event-visit", "")
+	chain.add(testStranger, protocol.ActionLogin, protocol.ResourceSession, "session-1", "")
+	chain.add(testDoctor, protocol.ActionRead, protocol.ResourceEvent, "event-lab", testPatient)
+
+	replay, reset, err := sub.Replay(ctx, seen.ID)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if reset != nil {
+		t.Fatalf("Replay() reset = %+v, want none", reset)
+	}
+	if got, want := kinds(replay), "timeline+entry,audit+entry"; got != want {
+		t.Errorf("replayed messages = %s, want %s", got, want)
+	}
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+	if got := drain(sub); len(got) != 0 {
+		t.Errorf("live messages after replay = %s, want replayed ones skipped", kinds(got))
+	}
+
+	latest := chain.add(testPatient, protocol.ActionConsentApprove, protocol.ResourceConsent, "grant-1", "")
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+	if got := drain(sub); len(got) != 1 || got[0].ID != latest.ID {
+		t.Errorf("live messages = %s, want only %s", kinds(got), latest.ID)
+	}
+
+	other := hub.Subscribe(testPatient)
+	replay, reset, err = other.Replay(ctx, "not-an-entry")
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if len(replay) != 0 || reset == nil || reset.Kind != KindReset || reset.ID != latest.ID {
+		t.Errorf("Replay(unknown) = %s, %+v; want a reset to the chain head", kinds(replay), reset)
+	}
+}
+
+func TestHub_DisconnectsSlowSubscriber(t *testing.T) {
+	ctx := context.Background()
+	chain := &mockAuditService{base: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	hub := newTestHub(chain)
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	sub := hub.Subscribe(testPatient)
+	for i := 0; i <= subscriberBuffer; i++ {
+		chain.add(testPatient, protocol.ActionLogin, protocol.ResourceSession, fmt.Sprintf("session-%d", i), "")
+	}
+	if err := hub.dispatch(ctx); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	received := 0
+	for range sub.messages {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d messages before disconnect, want %d", received, subscriberBuffer)
+	}
+	if _, ok := hub.subscribers[sub]; ok {
+		t.Error("slow subscriber is still registered")
+	}
+}
@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// maxListenBackoff caps the wait between reconnection attempts.
+const maxListenBackoff = 30 * time.Second
+
+// Listener calls notify whenever a notification arrives on channel, and after every
+// (re)connection so missed notifications are caught up on. It blocks until ctx is done.
+type Listener interface {
+	Listen(ctx context.Context, channel string, notify func())
+}
+
+type pgListener struct {
+	dsn string
+}
+
+// NewPostgresListener creates a Listener holding its own LISTEN connection to dsn.
+// Every replica runs one, so an append on any replica reaches streams on all of them.
+func NewPostgresListener(dsn string) Listener {
+	return &pgListener{dsn: dsn}
+}
+
+func (l *pgListener) Listen(ctx context.Context, channel string, notify func()) {
+	backoff := time.Second
+	for {
+		connected, err := l.listen(ctx, channel, notify)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			backoff = time.Second
+		}
+		slog.Warn("Audit stream listener disconnected", "error", err, "retryIn", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxListenBackoff)
+	}
+}
+
+func (l *pgListener) listen(ctx context.Context, channel string, notify func()) (bool, error) {
+	conn, err := pgx.Connect(ctx, l.dsn)
+	if err != nil {
+		return false, fmt.Errorf("connect: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return false, fmt.Errorf("listen on %s: %w", channel, err)
+	}
+	notify()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return true, fmt.Errorf("wait for notification: %w", err)
+		}
+		notify()
+	}
+}
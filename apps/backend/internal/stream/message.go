@@ -0,0 +1,49 @@
+package stream
+
+import (
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+)
+
+// Kind is the SSE event type of a message.
+type Kind string
+
+const (
+	// KindAudit carries an audit entry the user may read through the audit API.
+	KindAudit Kind = "audit"
+	// KindConsent carries the current state of a grant the user is a party to.
+	KindConsent Kind = "consent"
+	// KindTimeline carries a timeline event the user may read that was created,
+	// corrected or deleted.
+	KindTimeline Kind = "timeline"
+	// KindReset tells the client that the requested Last-Event-ID could not be
+	// replayed and that it should refetch over the REST API.
+	KindReset Kind = "reset"
+)
+
+// Message is one server-sent event, derived from one audit entry. Its ID is the entry
+// ID, which clients send back as Last-Event-ID to resume. Entry is set only when the
+// user may read the entry itself; Grant and Event are the current state of what it
+// changed.
+type Message struct {
+	ID    string                  `json:"-"`
+	Kind  Kind                    `json:"-"`
+	Entry *audit.AuditEntry       `json:"entry,omitempty"`
+	Grant *consent.ConsentGrant   `json:"grant,omitempty"`
+	Event *timeline.TimelineEvent `json:"event,omitempty"`
+
+	position audit.ChainCursor
+}
+
+func cursorOf(entry *audit.AuditEntry) audit.ChainCursor {
+	return audit.ChainCursor{Timestamp: entry.Timestamp, ID: entry.ID}
+}
+
+// after reports whether position a comes after b in chain order.
+func after(a, b audit.ChainCursor) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.After(b.Timestamp)
+	}
+	return a.ID > b.ID
+}
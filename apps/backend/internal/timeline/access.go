@@ -0,0 +1,112 @@
+package timeline
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Reader identifies who is reading a patient's data. GrantID is the consent grant
+// ConsentMiddleware authorized a non-owner under; it is empty for the patient. A read
+// disclosing events covered by different grants lists them all in GrantIDs instead.
+// EmergencySessionID is set when a break-glass session allowed what no grant covered.
+type Reader struct {
+	Actor              string
+	PatientID          string
+	GrantID            string
+	GrantIDs           []string
+	EmergencySessionID string
+	Scope              AccessScope
+}
+
+// IsOwner reports whether the reader is the patient.
+func (r Reader) IsOwner() bool {
+	return strings.EqualFold(r.Actor, r.PatientID)
+}
+
+// ForEvents attributes a read to the grants that cover the events it disclosed, when
+// the reader's scope can tell, and drops the emergency session when grants covered
+// every event. The reader is returned unchanged otherwise, or when no events were
+// disclosed.
+func (r Reader) ForEvents(events []TimelineEvent) Reader {
+	resolver, ok := r.Scope.(GrantResolver)
+	if !ok || len(events) == 0 {
+		return r
+	}
+
+	var grantIDs []string
+	uncovered := false
+	for i := range events {
+		grantID := resolver.GrantFor(events[i].ID, events[i].Type, types.Codes(events[i].Codes))
+		if grantID == "" {
+			uncovered = true
+		} else if !slices.Contains(grantIDs, grantID) {
+			grantIDs = append(grantIDs, grantID)
+		}
+	}
+	if !uncovered {
+		r.EmergencySessionID = ""
+	}
+	switch len(grantIDs) {
+	case 0:
+	case 1:
+		r.GrantID = grantIDs[0]
+	default:
+		r.GrantID = ""
+		r.GrantIDs = grantIDs
+	}
+	return r
+}
+
+// auditMetadata adds the patient, authorizing grants and any emergency session to
+// metadata, so the entry shows up in the patient's access report and session review.
+func (r Reader) auditMetadata(metadata common.JSONMap) common.JSONMap {
+	result := make(common.JSONMap, len(metadata)+2)
+	for k, v := range metadata {
+		result[k] = v
+	}
+	result[audit.MetadataSubject] = strings.ToLower(r.PatientID)
+	if r.GrantID != "" {
+		result[audit.MetadataGrantID] = r.GrantID
+	}
+	if len(r.GrantIDs) > 0 {
+		result[audit.MetadataGrantIDs] = r.GrantIDs
+	}
+	if r.EmergencySessionID != "" {
+		result[audit.MetadataEmergencySessionID] = r.EmergencySessionID
+	}
+	return result
+}
+
+// RecordAccess records a read of the patient's data by a non-owner, or by a delegate
+// acting as the patient. Reads by the patient themselves are not recorded.
+func (s *service) RecordAccess(ctx context.Context, reader Reader, resourceType protocol.ResourceType, resourceID string, metadata common.JSONMap) {
+	if reader.Actor == "" {
+		return
+	}
+	if _, _, delegated := audit.DelegateFrom(ctx); reader.IsOwner() && !delegated {
+		return
+	}
+	s.recordRead(ctx, reader, protocol.ActionRead, resourceType, resourceID, metadata)
+}
+
+func (s *service) recordRead(ctx context.Context, reader Reader, action protocol.Action, resourceType protocol.ResourceType, resourceID string, metadata common.JSONMap) {
+	if err := s.auditService.Record(ctx, reader.Actor, action, resourceType, resourceID, reader.auditMetadata(metadata)); err != nil {
+		slog.WarnContext(ctx, "failed to record data access", "actor", reader.Actor, "patient", reader.PatientID, "resource", resourceID, "error", err)
+	}
+}
+
+// eventIDs lists the IDs of the events a read disclosed.
+func eventIDs(events []TimelineEvent) []string {
+	ids := make([]string, len(events))
+	for i := range events {
+		ids[i] = events[i].ID
+	}
+	return ids
+}
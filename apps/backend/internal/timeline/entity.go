@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
 	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 )
 
@@ -51,6 +52,7 @@ type EventFile struct {
 	MimeType   string         `json:"mimeType" gorm:"type:varchar(100);not null"`
 	FileSize   int64          `json:"fileSize" gorm:"not null"`
 	WrappedDEK []byte         `json:"wrappedDek,omitempty" gorm:"type:bytea;not null"`
+	KeyVersion int            `json:"keyVersion" gorm:"not null;default:1"` // Bumped each time the patient re-keys the file
 	Metadata   common.JSONMap `json:"metadata,omitempty" gorm:"type:jsonb"`
 	CreatedAt  time.Time      `json:"createdAt"`
 
@@ -61,13 +63,26 @@ func (EventFile) TableName() string {
 	return "event_files"
 }
 
+// EventFileAccess is a file's data key wrapped for a grantee. DisabledAt is set when
+// the grantee's consent stops covering the file's event, with the grant state that
+// caused it as DisabledReason; a share disabled by a suspension comes back on resume.
+// KeyFetchedAt is when the grantee first read the key, so the patient knows which
+// files to re-key once the grantee's consent ends.
 type EventFileAccess struct {
-	ID        string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
-	FileID    string    `json:"fileId" gorm:"type:uuid;not null;index;uniqueIndex:idx_file_grantee"`
-	Grantee   string    `json:"grantee" gorm:"type:varchar(255);not null;index;uniqueIndex:idx_file_grantee"`
-	WrappedDEK []byte   `json:"wrappedDek,omitempty" gorm:"type:bytea;not null"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID             string                `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	FileID         string                `json:"fileId" gorm:"type:uuid;not null;index;uniqueIndex:idx_file_grantee"`
+	Grantee        string                `json:"grantee" gorm:"type:varchar(255);not null;index;uniqueIndex:idx_file_grantee"`
+	WrappedDEK     []byte                `json:"wrappedDek,omitempty" gorm:"type:bytea;not null"`
+	KeyFetchedAt   *time.Time            `json:"keyFetchedAt,omitempty"`
+	DisabledAt     *time.Time            `json:"disabledAt,omitempty"`
+	DisabledReason protocolconsent.State `json:"disabledReason,omitempty" gorm:"type:varchar(20)"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	UpdatedAt      time.Time             `json:"updatedAt"`
+}
+
+// IsDisabled reports whether consent no longer lets the grantee read the key.
+func (a *EventFileAccess) IsDisabled() bool {
+	return a.DisabledAt != nil
 }
 
 func (EventFileAccess) TableName() string {
@@ -2,10 +2,12 @@ package timeline
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"io"
@@ -13,6 +15,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	"github.com/itspablomontes/fleming/apps/backend/internal/storage"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
 	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
@@ -25,20 +28,67 @@ func NewHandler(service Service) *Handler {
 	return &Handler{service: service}
 }
 
+// targetPatient returns the patient ConsentMiddleware resolved for this request,
+// falling back to the caller's own address.
+func targetPatient(c *gin.Context) (string, bool) {
+	if targetVal, ok := c.Get("target_patient"); ok {
+		if target, ok := targetVal.(string); ok && target != "" {
+			return target, true
+		}
+	}
+	addressVal, exists := c.Get("user_address")
+	address, ok := addressVal.(string)
+	if !exists || !ok || address == "" {
+		return "", false
+	}
+	return address, true
+}
+
+// accessScope returns the consent scope ConsentMiddleware attached for non-owners,
+// or nil when the caller is the patient.
+func accessScope(c *gin.Context) AccessScope {
+	value, ok := c.Get("access_scope")
+	if !ok {
+		return nil
+	}
+	scope, _ := value.(AccessScope)
+	return scope
+}
+
+// requestReader returns who is making the request and, for non-owners, the consent
+// grants and emergency session ConsentMiddleware authorized them under.
+func requestReader(c *gin.Context) (Reader, bool) {
+	addressVal, exists := c.Get("user_address")
+	address, ok := addressVal.(string)
+	if !exists || !ok || address == "" {
+		return Reader{}, false
+	}
+	patientID, _ := targetPatient(c)
+	return Reader{
+		Actor:              address,
+		PatientID:          patientID,
+		GrantID:            c.GetString("access_grant_id"),
+		EmergencySessionID: c.GetString("emergency_session_id"),
+		Scope:              accessScope(c),
+	}, true
+}
+
 // HandleGetTimeline returns the patient's history, excluding superseded events.
 func (h *Handler) HandleGetTimeline(c *gin.Context) {
-	patientID, exists := c.Get("user_address")
-	address, ok := patientID.(string)
-	if !exists || !ok || address == "" {
+	address, ok := targetPatient(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: missing or invalid user address"})
 		return
 	}
 
-	events, err := h.service.GetTimeline(c.Request.Context(), address)
+	events, err := h.service.GetTimeline(c.Request.Context(), address, accessScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch timeline"})
 		return
 	}
+	if reader, ok := requestReader(c); ok {
+		h.service.RecordAccess(c.Request.Context(), reader.ForEvents(events), protocol.ResourceEvent, address, common.JSONMap{"view": "timeline", "eventIds": eventIDs(events)})
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"events": events,
@@ -58,6 +108,9 @@ func (h *Handler) HandleGetEvent(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
 		return
 	}
+	if reader, ok := requestReader(c); ok {
+		h.service.RecordAccess(c.Request.Context(), reader, protocol.ResourceEvent, event.ID, common.JSONMap{"view": "event"})
+	}
 
 	c.JSON(http.StatusOK, event)
 }
@@ -191,9 +244,8 @@ func (h *Handler) HandleAddEvent(c *gin.Context) {
 
 // HandleDownloadFile serves a file's ciphertext blob.
 func (h *Handler) HandleDownloadFile(c *gin.Context) {
-	addressVal, exists := c.Get("user_address")
-	address, ok := addressVal.(string)
-	if !exists || !ok || address == "" {
+	reader, ok := requestReader(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -204,22 +256,18 @@ func (h *Handler) HandleDownloadFile(c *gin.Context) {
 		return
 	}
 
-	file, reader, err := h.service.GetFile(c.Request.Context(), fileID, address)
+	file, blob, err := h.service.GetFile(c.Request.Context(), c.Param("id"), fileID, reader)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
 	}
-	if file.EventID != c.Param("id") {
-		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
-		return
-	}
-	defer reader.Close()
+	defer blob.Close()
 
 	c.Header("Content-Disposition", "attachment; filename="+file.FileName)
 	c.Header("Content-Type", file.MimeType)
 	c.Header("Content-Length", strconv.FormatInt(file.FileSize, 10))
 
-	if _, err := io.Copy(c.Writer, reader); err != nil {
+	if _, err := io.Copy(c.Writer, blob); err != nil {
 		slog.Error("failed to pipe file content", "error", err)
 	}
 }
@@ -463,9 +511,8 @@ func (h *Handler) HandleShareFile(c *gin.Context) {
 }
 
 func (h *Handler) HandleGetFileKey(c *gin.Context) {
-	addressVal, exists := c.Get("user_address")
-	address, ok := addressVal.(string)
-	if !exists || !ok || address == "" {
+	reader, ok := requestReader(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
@@ -477,23 +524,21 @@ func (h *Handler) HandleGetFileKey(c *gin.Context) {
 		return
 	}
 
-	targetVal, _ := c.Get("target_patient")
-	targetPatient, _ := targetVal.(string)
-	if targetPatient == "" {
-		targetPatient = address
-	}
-
 	event, err := h.service.GetEvent(c.Request.Context(), eventID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
 		return
 	}
-	if event.PatientID != targetPatient {
+	if !strings.EqualFold(event.PatientID, reader.PatientID) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "invalid patient context"})
 		return
 	}
 
-	key, err := h.service.GetFileKey(c.Request.Context(), fileID, address, targetPatient)
+	key, err := h.service.GetFileKey(c.Request.Context(), eventID, fileID, reader)
+	if errors.Is(err, ErrNoFileConsent) || errors.Is(err, ErrFileAccessDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
 		return
@@ -502,6 +547,120 @@ func (h *Handler) HandleGetFileKey(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"wrappedKey": common.BytesToHex(key)})
 }
 
+// HandleGetPendingRekeys lists the caller's files that grantees whose consent ended
+// still hold a data key for.
+func (h *Handler) HandleGetPendingRekeys(c *gin.Context) {
+	addressVal, exists := c.Get("user_address")
+	address, ok := addressVal.(string)
+	if !exists || !ok || address == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if patientID, _ := targetPatient(c); !strings.EqualFold(patientID, address) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the patient can re-key files"})
+		return
+	}
+
+	pending, err := h.service.GetPendingRekeys(c.Request.Context(), address)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list files pending re-key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"files": pending})
+}
+
+// HandleRekeyFile replaces a file's ciphertext with one the patient re-encrypted
+// under a new data key. The form carries the new "file", its "wrappedKey", optional
+// "metadata" (e.g. a new IV) and optional "shares": a JSON object of grantee to the
+// new key wrapped for them.
+func (h *Handler) HandleRekeyFile(c *gin.Context) {
+	addressVal, exists := c.Get("user_address")
+	address, ok := addressVal.(string)
+	if !exists || !ok || address == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	eventID := c.Param("id")
+	fileID := c.Param("fileId")
+	if eventID == "" || fileID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event ID and file ID are required"})
+		return
+	}
+
+	event, err := h.service.GetEvent(c.Request.Context(), eventID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "event not found"})
+		return
+	}
+	if !strings.EqualFold(event.PatientID, address) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the event owner can re-key files"})
+		return
+	}
+
+	const maxMultipartMemory = 32 << 20
+	if err := c.Request.ParseMultipartForm(maxMultipartMemory); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to parse form data"})
+		return
+	}
+	form := c.Request.PostForm
+
+	wrappedKey, err := common.HexToBytes(form.Get("wrappedKey"))
+	if err != nil || len(wrappedKey) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wrapped key"})
+		return
+	}
+
+	var metadata common.JSONMap
+	if metadataStr := form.Get("metadata"); metadataStr != "" {
+		if err := json.Unmarshal([]byte(metadataStr), &metadata); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid metadata"})
+			return
+		}
+	}
+
+	shares := make(map[string][]byte)
+	if sharesStr := form.Get("shares"); sharesStr != "" {
+		var hexShares map[string]string
+		if err := json.Unmarshal([]byte(sharesStr), &hexShares); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid shares"})
+			return
+		}
+		for grantee, hexKey := range hexShares {
+			key, err := common.HexToBytes(hexKey)
+			if err != nil || len(key) == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid wrapped key for " + grantee})
+				return
+			}
+			shares[grantee] = key
+		}
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	defer file.Close()
+
+	rekeyed, err := h.service.RekeyFile(c.Request.Context(), eventID, fileID, file, header.Size, wrappedKey, metadata, shares)
+	if err != nil {
+		var validationErr types.ValidationError
+		switch {
+		case errors.As(err, &validationErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, ErrFileNotInEvent):
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to re-key file"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"file": rekeyed})
+}
+
 // HandleCorrectEvent implements the "Edit" logic using the Append-Only flow.
 func (h *Handler) HandleCorrectEvent(c *gin.Context) {
 	patientID, exists := c.Get("user_address")
@@ -661,6 +820,24 @@ func (h *Handler) HandleLinkEvents(c *gin.Context) {
 		return
 	}
 
+	// ConsentMiddleware only vets the :id event, so the target must be checked here
+	// or a grantee could attach edges to events outside the patient's timeline or
+	// their grant.
+	patientID, ok := targetPatient(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized: missing or invalid user address"})
+		return
+	}
+	toEvent, err := h.service.GetEvent(c.Request.Context(), req.ToEventID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "to event not found"})
+		return
+	}
+	if !strings.EqualFold(toEvent.PatientID, patientID) || !scopeAllows(accessScope(c), toEvent) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access denied: to event is outside your consent scope"})
+		return
+	}
+
 	protocolEdge, err := h.service.LinkEventsProtocol(
 		c.Request.Context(),
 		fromID,
@@ -710,29 +887,35 @@ func (h *Handler) HandleGetRelatedEvents(c *gin.Context) {
 		depth = 2
 	}
 
-	events, err := h.service.GetRelatedEvents(c.Request.Context(), eventID, depth)
+	patientID, _ := targetPatient(c)
+	events, err := h.service.GetRelatedEvents(c.Request.Context(), eventID, depth, patientID, accessScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get related events"})
 		return
 	}
+	if reader, ok := requestReader(c); ok {
+		h.service.RecordAccess(c.Request.Context(), reader.ForEvents(events), protocol.ResourceEvent, eventID, common.JSONMap{"view": "related", "eventIds": eventIDs(events)})
+	}
 
 	c.JSON(http.StatusOK, gin.H{"events": events})
 }
 
 // HandleGetGraphData returns the raw node/edge list for visualizers.
 func (h *Handler) HandleGetGraphData(c *gin.Context) {
-	patientID, exists := c.Get("user_address")
-	address, ok := patientID.(string)
-	if !exists || !ok || address == "" {
+	address, ok := targetPatient(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 		return
 	}
 
-	graphData, err := h.service.GetGraphData(c.Request.Context(), address)
+	graphData, err := h.service.GetGraphData(c.Request.Context(), address, accessScope(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get graph data"})
 		return
 	}
+	if reader, ok := requestReader(c); ok {
+		h.service.RecordAccess(c.Request.Context(), reader.ForEvents(graphData.Events), protocol.ResourceEvent, address, common.JSONMap{"view": "graph", "eventIds": eventIDs(graphData.Events)})
+	}
 
 	c.JSON(http.StatusOK, graphData)
 }
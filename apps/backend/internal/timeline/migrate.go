@@ -0,0 +1,44 @@
+package timeline
+
+import (
+	"fmt"
+	"log/slog"
+
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	"gorm.io/gorm"
+)
+
+// PrepareMigration must run before AutoMigrate. Shares created before key reads were
+// tracked have no key_fetched_at, so ListExposedFileAccess would never queue them for
+// re-keying even though the grantee may hold the key. When it adds the column,
+// PrepareMigration backfills it from the earliest key read in the audit log, and from
+// when the key was shared where no read was recorded (reads were not audited at
+// first), so every such share counts as exposed. It does nothing once the column exists.
+func PrepareMigration(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&EventFileAccess{}) || migrator.HasColumn(&EventFileAccess{}, "KeyFetchedAt") {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Migrator().AddColumn(&EventFileAccess{}, "KeyFetchedAt"); err != nil {
+			return fmt.Errorf("add event_file_access.key_fetched_at: %w", err)
+		}
+		result := tx.Exec(`
+			UPDATE event_file_access SET key_fetched_at = COALESCE((
+				SELECT MIN(audit_entries.timestamp) FROM audit_entries
+				WHERE audit_entries.resource_type = ?
+					AND audit_entries.action = ?
+					AND audit_entries.resource_id = event_file_access.file_id::text
+					AND LOWER(audit_entries.actor) = LOWER(event_file_access.grantee)
+					AND audit_entries.metadata->>'access' = 'key'
+			), event_file_access.created_at)
+			WHERE event_file_access.key_fetched_at IS NULL`,
+			protocol.ResourceFile, protocol.ActionRead)
+		if result.Error != nil {
+			return fmt.Errorf("backfill event_file_access.key_fetched_at: %w", result.Error)
+		}
+		slog.Info("backfilled file key reads", "shares", result.RowsAffected)
+		return nil
+	})
+}
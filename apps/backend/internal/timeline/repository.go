@@ -3,10 +3,12 @@ package timeline
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
 	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
@@ -24,6 +26,15 @@ type Repository interface {
 	GetFilesByEventID(ctx context.Context, eventID string) ([]EventFile, error)
 	UpsertFileAccess(ctx context.Context, access *EventFileAccess) error
 	GetFileAccess(ctx context.Context, fileID string, grantee string) (*EventFileAccess, error)
+	UpdateFileAccess(ctx context.Context, access *EventFileAccess) error
+	ListFileAccessByFile(ctx context.Context, fileID string) ([]EventFileAccess, error)
+	// ListFileAccessByGrantee lists the key shares grantee holds on the patient's files.
+	ListFileAccessByGrantee(ctx context.Context, patientID string, grantee string) ([]EventFileAccess, error)
+	// ListExposedFileAccess lists the shares on the patient's files whose key the
+	// grantee fetched before their consent was revoked or expired.
+	ListExposedFileAccess(ctx context.Context, patientID string) ([]EventFileAccess, error)
+	// ReplaceFileKey stores a re-keyed file and replaces all of its key shares.
+	ReplaceFileKey(ctx context.Context, file *EventFile, shares []EventFileAccess) error
 
 	// Graph data for visualization (backend-specific)
 	GetGraphData(ctx context.Context, patientID string) ([]TimelineEvent, []EventEdge, error)
@@ -415,7 +426,7 @@ func (r *GormRepository) GetFilesByEventID(ctx context.Context, eventID string)
 func (r *GormRepository) UpsertFileAccess(ctx context.Context, access *EventFileAccess) error {
 	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
 		Columns:   []clause.Column{{Name: "file_id"}, {Name: "grantee"}},
-		DoUpdates: clause.AssignmentColumns([]string{"wrapped_dek", "updated_at"}),
+		DoUpdates: clause.AssignmentColumns([]string{"wrapped_dek", "key_fetched_at", "disabled_at", "disabled_reason", "updated_at"}),
 	}).Create(access).Error; err != nil {
 		return fmt.Errorf("upsert file access: %w", err)
 	}
@@ -425,9 +436,73 @@ func (r *GormRepository) UpsertFileAccess(ctx context.Context, access *EventFile
 func (r *GormRepository) GetFileAccess(ctx context.Context, fileID string, grantee string) (*EventFileAccess, error) {
 	var access EventFileAccess
 	if err := r.db.WithContext(ctx).
-		Where("file_id = ? AND grantee = ?", fileID, grantee).
+		Where("file_id = ? AND LOWER(grantee) = ?", fileID, strings.ToLower(grantee)).
 		First(&access).Error; err != nil {
 		return nil, fmt.Errorf("get file access for %s: %w", fileID, err)
 	}
 	return &access, nil
 }
+
+func (r *GormRepository) UpdateFileAccess(ctx context.Context, access *EventFileAccess) error {
+	if err := r.db.WithContext(ctx).Save(access).Error; err != nil {
+		return fmt.Errorf("update file access %s: %w", access.ID, err)
+	}
+	return nil
+}
+
+func (r *GormRepository) ListFileAccessByFile(ctx context.Context, fileID string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	if err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("list file access for %s: %w", fileID, err)
+	}
+	return shares, nil
+}
+
+// patientFileAccess scopes a query on event_file_access to the patient's files.
+func (r *GormRepository) patientFileAccess(ctx context.Context, patientID string) *gorm.DB {
+	return r.db.WithContext(ctx).
+		Select("event_file_access.*").
+		Joins("JOIN event_files ON event_files.id = event_file_access.file_id").
+		Joins("JOIN timeline_events ON timeline_events.id = event_files.event_id").
+		Where("timeline_events.patient_id = ?", patientID)
+}
+
+func (r *GormRepository) ListFileAccessByGrantee(ctx context.Context, patientID string, grantee string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	if err := r.patientFileAccess(ctx, patientID).
+		Where("LOWER(event_file_access.grantee) = ?", strings.ToLower(grantee)).
+		Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("list file access for %s: %w", grantee, err)
+	}
+	return shares, nil
+}
+
+func (r *GormRepository) ListExposedFileAccess(ctx context.Context, patientID string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	if err := r.patientFileAccess(ctx, patientID).
+		Where("event_file_access.key_fetched_at IS NOT NULL AND event_file_access.disabled_reason IN ?",
+			[]protocolconsent.State{protocolconsent.StateRevoked, protocolconsent.StateExpired}).
+		Order("event_file_access.disabled_at ASC").
+		Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("list exposed file access for %s: %w", patientID, err)
+	}
+	return shares, nil
+}
+
+func (r *GormRepository) ReplaceFileKey(ctx context.Context, file *EventFile, shares []EventFileAccess) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(file).Error; err != nil {
+			return fmt.Errorf("update event file %s: %w", file.ID, err)
+		}
+		if err := tx.Where("file_id = ?", file.ID).Delete(&EventFileAccess{}).Error; err != nil {
+			return fmt.Errorf("delete file access for %s: %w", file.ID, err)
+		}
+		if len(shares) == 0 {
+			return nil
+		}
+		if err := tx.Create(&shares).Error; err != nil {
+			return fmt.Errorf("create file access for %s: %w", file.ID, err)
+		}
+		return nil
+	})
+}
@@ -20,6 +20,8 @@ func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
 		timeline.GET("/events/:id/files/:fileId", h.HandleDownloadFile)
 		timeline.GET("/events/:id/files/:fileId/key", h.HandleGetFileKey)
 		timeline.POST("/events/:id/files/:fileId/share", h.HandleShareFile)
+		timeline.POST("/events/:id/files/:fileId/rekey", h.HandleRekeyFile)
+		timeline.GET("/files/pending-rekeys", h.HandleGetPendingRekeys)
 
 		timeline.POST("/events/:id/files/multipart/start", h.HandleStartMultipartUpload)
 		timeline.PUT("/events/:id/files/multipart/part", h.HandleUploadMultipartPart)
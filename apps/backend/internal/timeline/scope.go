@@ -0,0 +1,70 @@
+package timeline
+
+import (
+	"strings"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// AccessScope restricts which of a patient's events a caller may see.
+// ConsentMiddleware attaches the caller's consent grant as the scope for non-owners;
+// a nil scope means the caller is the patient and sees the whole timeline. Codes let
+// grants limited to data categories match events by their coded content.
+type AccessScope interface {
+	AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool
+}
+
+// GrantResolver is an AccessScope made of several consent grants that can name the
+// grant covering an event, so reads can be recorded against the grant that allowed them.
+type GrantResolver interface {
+	AccessScope
+	GrantFor(eventID string, eventType timeline.EventType, codes types.Codes) string
+}
+
+func scopeAllows(scope AccessScope, event *TimelineEvent) bool {
+	if scope == nil {
+		return true
+	}
+	return scope.AllowsEvent(event.ID, event.Type, types.Codes(event.Codes))
+}
+
+// filterEventsByScope keeps the events that belong to patientID and fall within scope.
+// An empty patientID skips the ownership check.
+func filterEventsByScope(events []TimelineEvent, patientID string, scope AccessScope) []TimelineEvent {
+	filtered := make([]TimelineEvent, 0, len(events))
+	for i := range events {
+		if patientID != "" && !strings.EqualFold(events[i].PatientID, patientID) {
+			continue
+		}
+		if scopeAllows(scope, &events[i]) {
+			filtered = append(filtered, events[i])
+		}
+	}
+	return filtered
+}
+
+// filterGraphByScope drops out-of-scope events and any edge that touches one,
+// so a scoped caller cannot learn about hidden events through the graph shape.
+func filterGraphByScope(graph *GraphData, scope AccessScope) *GraphData {
+	if scope == nil {
+		return graph
+	}
+
+	events := filterEventsByScope(graph.Events, "", scope)
+	visible := make(map[string]struct{}, len(events))
+	for _, event := range events {
+		visible[event.ID] = struct{}{}
+	}
+
+	edges := make([]EventEdge, 0, len(graph.Edges))
+	for _, edge := range graph.Edges {
+		_, fromVisible := visible[edge.FromEventID]
+		_, toVisible := visible[edge.ToEventID]
+		if fromVisible && toVisible {
+			edges = append(edges, edge)
+		}
+	}
+
+	return &GraphData{Events: events, Edges: edges}
+}
@@ -2,9 +2,11 @@ package timeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,6 +14,7 @@ import (
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	"github.com/itspablomontes/fleming/apps/backend/internal/storage"
 	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
 	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
@@ -27,25 +30,55 @@ type Service interface {
 	UnlinkEventsByID(ctx context.Context, edgeID types.ID) error
 
 	// Legacy methods returning backend types (for backward compatibility with handlers)
-	GetTimeline(ctx context.Context, patientID string) ([]TimelineEvent, error)
+	GetTimeline(ctx context.Context, patientID string, scope AccessScope) ([]TimelineEvent, error)
 	GetEvent(ctx context.Context, id string) (*TimelineEvent, error)
 	AddEvent(ctx context.Context, event *TimelineEvent) error
 	UpdateEvent(ctx context.Context, event *TimelineEvent) error
 	DeleteEvent(ctx context.Context, id string) error
 	LinkEvents(ctx context.Context, fromID, toID string, relType timeline.RelationshipType) (*EventEdge, error)
 	UnlinkEvents(ctx context.Context, edgeID string) error
-	GetRelatedEvents(ctx context.Context, eventID string, maxDepth int) ([]TimelineEvent, error)
-	GetGraphData(ctx context.Context, patientID string) (*GraphData, error)
+	GetRelatedEvents(ctx context.Context, eventID string, maxDepth int, patientID string, scope AccessScope) ([]TimelineEvent, error)
+	GetGraphData(ctx context.Context, patientID string, scope AccessScope) (*GraphData, error)
 
 	UploadFile(ctx context.Context, eventID string, fileName string, contentType string, reader io.Reader, size int64, wrappedDEK []byte, metadata common.JSONMap) (*EventFile, error)
-	GetFile(ctx context.Context, fileID string, actor string) (*EventFile, io.ReadCloser, error)
+	GetFile(ctx context.Context, eventID string, fileID string, reader Reader) (*EventFile, io.ReadCloser, error)
 
 	StartMultipartUpload(ctx context.Context, eventID string, fileName string, contentType string) (string, string, error)
 	UploadMultipartPart(ctx context.Context, objectName string, uploadID string, partNumber int, reader io.Reader, size int64) (string, error)
 	CompleteMultipartUpload(ctx context.Context, eventID string, objectName string, uploadID string, parts []storage.Part, fileName string, contentType string, size int64, wrappedDEK []byte, metadata common.JSONMap) (*EventFile, error)
 
-	GetFileKey(ctx context.Context, fileID string, actor string, patientID string) ([]byte, error)
+	GetFileKey(ctx context.Context, eventID string, fileID string, reader Reader) ([]byte, error)
 	SaveFileAccess(ctx context.Context, fileID string, grantee string, wrappedDEK []byte) error
+	// SyncFileAccess brings the key shares grantee holds on the patient's files in line
+	// with their active grants after one of them moved to state: shares no active grant
+	// covers are disabled, and shares a suspension disabled come back once covered.
+	SyncFileAccess(ctx context.Context, patientID string, grantee string, active []*protocolconsent.Grant, state protocolconsent.State) error
+	// GetPendingRekeys lists the patient's files whose key a grantee read before their
+	// consent was revoked or expired.
+	GetPendingRekeys(ctx context.Context, patientID string) ([]PendingRekey, error)
+	// RekeyFile replaces a file's ciphertext and wrapped key with ones the patient
+	// re-encrypted under a new data key. shares holds the new key wrapped for grantees
+	// who keep access; every other share is dropped, since it wraps the retired key.
+	RekeyFile(ctx context.Context, eventID string, fileID string, reader io.Reader, size int64, wrappedDEK []byte, metadata common.JSONMap, shares map[string][]byte) (*EventFile, error)
+
+	RecordAccess(ctx context.Context, reader Reader, resourceType protocol.ResourceType, resourceID string, metadata common.JSONMap)
+}
+
+var (
+	// ErrFileNotInEvent is returned when a file is requested through an event it does not belong to.
+	ErrFileNotInEvent = errors.New("file does not belong to event")
+	// ErrNoFileConsent is returned when a non-owner asks for a file key without an
+	// active grant covering the file's event.
+	ErrNoFileConsent = errors.New("no active consent grant covers the file's event")
+	// ErrFileAccessDisabled is returned when the grantee's key share was disabled by a consent change.
+	ErrFileAccessDisabled = errors.New("file key share was disabled when consent ended")
+)
+
+// PendingRekey is a file whose data key grantees read before their consent ended.
+// Until the patient re-keys it, they can still decrypt its ciphertext.
+type PendingRekey struct {
+	File     EventFile `json:"file"`
+	Grantees []string  `json:"grantees"`
 }
 
 type service struct {
@@ -125,7 +158,8 @@ func (s *service) GetTimelineForPatient(ctx context.Context, patientID types.Wal
 // Legacy methods for backward compatibility
 
 // GetTimeline returns active events for a patient, filtering superseded ones.
-func (s *service) GetTimeline(ctx context.Context, patientID string) ([]TimelineEvent, error) {
+// A non-nil scope further limits the result to the events a consent grant covers.
+func (s *service) GetTimeline(ctx context.Context, patientID string, scope AccessScope) ([]TimelineEvent, error) {
 	addr, err := types.NewWalletAddress(patientID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid patient ID: %w", err)
@@ -140,7 +174,7 @@ func (s *service) GetTimeline(ctx context.Context, patientID string) ([]Timeline
 	for i, e := range events {
 		entities[i] = *ToTimelineEvent(&e)
 	}
-	return entities, nil
+	return filterEventsByScope(entities, "", scope), nil
 }
 
 // GetEvent retrieves a specific event.
@@ -362,7 +396,8 @@ func (s *service) UnlinkEvents(ctx context.Context, edgeID string) error {
 }
 
 // GetRelatedEvents finds connected events by traversing the graph (legacy).
-func (s *service) GetRelatedEvents(ctx context.Context, eventID string, maxDepth int) ([]TimelineEvent, error) {
+// Results are limited to patientID's events within scope, since edges may point anywhere.
+func (s *service) GetRelatedEvents(ctx context.Context, eventID string, maxDepth int, patientID string, scope AccessScope) ([]TimelineEvent, error) {
 	if maxDepth < 1 {
 		maxDepth = 2
 	}
@@ -385,20 +420,21 @@ func (s *service) GetRelatedEvents(ctx context.Context, eventID string, maxDepth
 		entities[i] = *ToTimelineEvent(&e)
 	}
 
-	return entities, nil
+	return filterEventsByScope(entities, patientID, scope), nil
 }
 
 // GetGraphData returns the adjacency list of nodes and edges.
-func (s *service) GetGraphData(ctx context.Context, patientID string) (*GraphData, error) {
+// A non-nil scope drops out-of-scope events together with their edges.
+func (s *service) GetGraphData(ctx context.Context, patientID string, scope AccessScope) (*GraphData, error) {
 	events, edges, err := s.repo.GetGraphData(ctx, patientID)
 	if err != nil {
 		return nil, fmt.Errorf("get graph data for %s: %w", patientID, err)
 	}
 
-	return &GraphData{
+	return filterGraphByScope(&GraphData{
 		Events: events,
 		Edges:  edges,
-	}, nil
+	}, scope), nil
 }
 
 func (s *service) UploadFile(ctx context.Context, eventID string, fileName string, contentType string, reader io.Reader, size int64, wrappedDEK []byte, metadata common.JSONMap) (*EventFile, error) {
@@ -436,28 +472,33 @@ func (s *service) UploadFile(ctx context.Context, eventID string, fileName strin
 	return file, nil
 }
 
-func (s *service) GetFile(ctx context.Context, fileID string, actor string) (*EventFile, io.ReadCloser, error) {
+// GetFile opens a file's ciphertext blob and records the download under the reader,
+// with the patient as the entry's subject.
+func (s *service) GetFile(ctx context.Context, eventID string, fileID string, reader Reader) (*EventFile, io.ReadCloser, error) {
 	file, err := s.repo.GetFileByID(ctx, fileID)
 	if err != nil {
 		return nil, nil, fmt.Errorf("repo get file %s: %w", fileID, err)
 	}
+	if file.EventID != eventID {
+		return nil, nil, fmt.Errorf("get file %s: %w", fileID, ErrFileNotInEvent)
+	}
 
-	reader, err := s.storage.Get(ctx, s.bucketName, file.BlobRef)
+	blob, err := s.storage.Get(ctx, s.bucketName, file.BlobRef)
 	if err != nil {
 		return nil, nil, fmt.Errorf("storage get %s: %w", file.BlobRef, err)
 	}
 
-	if actor != "" {
+	if reader.Actor != "" {
 		auditMetadata := common.JSONMap{
 			"eventId":  file.EventID,
 			"fileName": file.FileName,
 			"fileSize": file.FileSize,
 			"mimeType": file.MimeType,
 		}
-		_ = s.auditService.Record(ctx, actor, protocol.ActionDownload, protocol.ResourceFile, file.ID, auditMetadata)
+		s.recordRead(ctx, reader, protocol.ActionDownload, protocol.ResourceFile, file.ID, auditMetadata)
 	}
 
-	return file, reader, nil
+	return file, blob, nil
 }
 
 func (s *service) StartMultipartUpload(ctx context.Context, eventID string, fileName string, contentType string) (string, string, error) {
@@ -519,27 +560,48 @@ func (s *service) CompleteMultipartUpload(
 	return file, nil
 }
 
-func (s *service) GetFileKey(ctx context.Context, fileID string, actor string, patientID string) ([]byte, error) {
+// GetFileKey returns the file key wrapped for the reader. Non-owners receive the key
+// the patient shared with them, and the read is recorded.
+func (s *service) GetFileKey(ctx context.Context, eventID string, fileID string, reader Reader) ([]byte, error) {
 	file, err := s.repo.GetFileByID(ctx, fileID)
 	if err != nil {
 		return nil, err
 	}
+	if file.EventID != eventID {
+		return nil, fmt.Errorf("get file key %s: %w", fileID, ErrFileNotInEvent)
+	}
 
-	if actor == patientID {
+	if reader.Actor == reader.PatientID {
 		return file.WrappedDEK, nil
 	}
+	// ConsentMiddleware only names a grant when an active one covers the event; an
+	// emergency session alone does not unlock keys the patient shared.
+	if reader.GrantID == "" {
+		return nil, fmt.Errorf("get file key %s: %w", fileID, ErrNoFileConsent)
+	}
 
-	access, err := s.repo.GetFileAccess(ctx, fileID, actor)
+	access, err := s.repo.GetFileAccess(ctx, fileID, reader.Actor)
 	if err != nil {
 		return nil, err
 	}
+	if access.IsDisabled() {
+		return nil, fmt.Errorf("get file key %s: %w", fileID, ErrFileAccessDisabled)
+	}
+	if access.KeyFetchedAt == nil {
+		now := time.Now().UTC()
+		access.KeyFetchedAt = &now
+		if err := s.repo.UpdateFileAccess(ctx, access); err != nil {
+			return nil, err
+		}
+	}
+	s.RecordAccess(ctx, reader, protocol.ResourceFile, fileID, common.JSONMap{"eventId": eventID, "access": "key"})
 	return access.WrappedDEK, nil
 }
 
 func (s *service) SaveFileAccess(ctx context.Context, fileID string, grantee string, wrappedDEK []byte) error {
 	access := &EventFileAccess{
 		FileID:     fileID,
-		Grantee:    grantee,
+		Grantee:    strings.ToLower(grantee),
 		WrappedDEK: wrappedDEK,
 	}
 	if err := s.repo.UpsertFileAccess(ctx, access); err != nil {
@@ -562,3 +624,164 @@ func (s *service) SaveFileAccess(ctx context.Context, fileID string, grantee str
 
 	return nil
 }
+
+func (s *service) SyncFileAccess(ctx context.Context, patientID string, grantee string, active []*protocolconsent.Grant, state protocolconsent.State) error {
+	shares, err := s.repo.ListFileAccessByGrantee(ctx, patientID, grantee)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for i := range shares {
+		share := &shares[i]
+		file, err := s.repo.GetFileByID(ctx, share.FileID)
+		if err != nil {
+			return err
+		}
+		event, err := s.repo.GetEvent(ctx, types.ID(file.EventID))
+		if err != nil {
+			return fmt.Errorf("sync file access %s: %w", share.ID, err)
+		}
+
+		action := protocol.ActionUnshare
+		switch covered := grantsCoverEvent(active, event); {
+		case covered && share.DisabledReason == protocolconsent.StateSuspended:
+			share.DisabledAt, share.DisabledReason = nil, ""
+			action = protocol.ActionShare
+		case covered, state.IsActive():
+			continue
+		case !share.IsDisabled():
+			share.DisabledAt, share.DisabledReason = &now, state
+		case share.DisabledReason == protocolconsent.StateSuspended && state != protocolconsent.StateSuspended:
+			// Consent that ends while suspended must not bring the share back on resume.
+			share.DisabledReason = state
+		default:
+			continue
+		}
+
+		if err := s.repo.UpdateFileAccess(ctx, share); err != nil {
+			return err
+		}
+		_ = s.auditService.Record(ctx, patientID, action, protocol.ResourceFile, file.ID, common.JSONMap{
+			"eventId":      file.EventID,
+			"fileName":     file.FileName,
+			"grantee":      share.Grantee,
+			"consentState": state,
+		})
+	}
+	return nil
+}
+
+// grantsCoverEvent reports whether any of the grants lets its grantee read the event.
+func grantsCoverEvent(grants []*protocolconsent.Grant, event *timeline.Event) bool {
+	for _, grant := range grants {
+		if grant.HasPermission(protocolconsent.PermRead) && grant.CoversEvent(event.ID, event.Type, event.Codes) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *service) GetPendingRekeys(ctx context.Context, patientID string) ([]PendingRekey, error) {
+	shares, err := s.repo.ListExposedFileAccess(ctx, patientID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]PendingRekey, 0)
+	byFile := make(map[string]int)
+	for _, share := range shares {
+		i, ok := byFile[share.FileID]
+		if !ok {
+			file, err := s.repo.GetFileByID(ctx, share.FileID)
+			if err != nil {
+				return nil, err
+			}
+			i = len(pending)
+			byFile[share.FileID] = i
+			pending = append(pending, PendingRekey{File: *file})
+		}
+		pending[i].Grantees = append(pending[i].Grantees, share.Grantee)
+	}
+	return pending, nil
+}
+
+func (s *service) RekeyFile(ctx context.Context, eventID string, fileID string, reader io.Reader, size int64, wrappedDEK []byte, metadata common.JSONMap, shares map[string][]byte) (*EventFile, error) {
+	if len(wrappedDEK) == 0 {
+		return nil, types.NewValidationError("wrappedKey", "wrapped key is required")
+	}
+	file, err := s.repo.GetFileByID(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	if file.EventID != eventID {
+		return nil, fmt.Errorf("rekey file %s: %w", fileID, ErrFileNotInEvent)
+	}
+
+	existing, err := s.repo.ListFileAccessByFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[string]bool, len(existing))
+	for _, share := range existing {
+		enabled[strings.ToLower(share.Grantee)] = !share.IsDisabled()
+	}
+	kept := make([]EventFileAccess, 0, len(shares))
+	for grantee, key := range shares {
+		grantee = strings.ToLower(grantee)
+		if !enabled[grantee] {
+			// Re-keying never hands the new key to someone whose access ended; share it anew instead.
+			return nil, types.NewValidationError("shares", fmt.Sprintf("%s holds no active share of this file", grantee))
+		}
+		kept = append(kept, EventFileAccess{FileID: file.ID, Grantee: grantee, WrappedDEK: key})
+	}
+	slices.SortFunc(kept, func(a, b EventFileAccess) int { return strings.Compare(a.Grantee, b.Grantee) })
+
+	version := file.KeyVersion + 1
+	blobRef, err := s.storage.Put(ctx, s.bucketName, fmt.Sprintf("%s/%s.v%d", file.EventID, file.ID, version), reader, size, file.MimeType)
+	if err != nil {
+		return nil, fmt.Errorf("storage put: %w", err)
+	}
+
+	retiredBlob := file.BlobRef
+	file.BlobRef = blobRef
+	file.FileSize = size
+	file.WrappedDEK = wrappedDEK
+	file.KeyVersion = version
+	if metadata != nil {
+		file.Metadata = metadata
+	}
+	if err := s.repo.ReplaceFileKey(ctx, file, kept); err != nil {
+		if delErr := s.storage.Delete(ctx, s.bucketName, blobRef); delErr != nil {
+			slog.Warn("failed to remove re-keyed blob", "file", file.ID, "blob", blobRef, "error", delErr)
+		}
+		return nil, fmt.Errorf("rekey file %s: %w", fileID, err)
+	}
+	if retiredBlob != blobRef {
+		if err := s.storage.Delete(ctx, s.bucketName, retiredBlob); err != nil {
+			slog.Warn("failed to remove retired blob", "file", file.ID, "blob", retiredBlob, "error", err)
+		}
+	}
+
+	keptGrantees := make([]string, len(kept))
+	for i := range kept {
+		keptGrantees[i] = kept[i].Grantee
+	}
+	var dropped []string
+	for _, share := range existing {
+		if !slices.Contains(keptGrantees, strings.ToLower(share.Grantee)) {
+			dropped = append(dropped, share.Grantee)
+		}
+	}
+	if event, err := s.repo.GetEvent(ctx, types.ID(file.EventID)); err == nil && event != nil {
+		_ = s.auditService.Record(ctx, event.PatientID.String(), protocol.ActionRekey, protocol.ResourceFile, file.ID, common.JSONMap{
+			"eventId":    file.EventID,
+			"fileName":   file.FileName,
+			"keyVersion": version,
+			"kept":       keptGrantees,
+			"dropped":    dropped,
+		})
+	}
+
+	return file, nil
+}
@@ -2,53 +2,24 @@ package timeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"io"
 
 	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
 	"github.com/itspablomontes/fleming/apps/backend/internal/common"
 	"github.com/itspablomontes/fleming/apps/backend/internal/storage"
 	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
 	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
-type MockAuditService struct{}
-
-func (m *MockAuditService) Record(ctx context.Context, actor string, action protocol.Action, resourceType protocol.ResourceType, resourceID string, metadata common.JSONMap) error {
-	return nil
-}
-func (m *MockAuditService) GetLatestEntries(ctx context.Context, actor string, limit int) ([]audit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) VerifyIntegrity(ctx context.Context) (bool, error) {
-	return true, nil
-}
-func (m *MockAuditService) BuildMerkleTree(ctx context.Context, startTime time.Time, endTime time.Time) (*audit.AuditBatch, *protocol.MerkleTree, error) {
-	return nil, nil, nil
-}
-func (m *MockAuditService) GetMerkleRoot(ctx context.Context, batchID string) (string, error) {
-	return "", nil
-}
-func (m *MockAuditService) VerifyMerkleProof(root string, entryHash string, proof *protocol.Proof) bool {
-	return true
-}
-func (m *MockAuditService) GetEntriesForMerkle(ctx context.Context, startTime time.Time, endTime time.Time) ([]audit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) GetEntryByID(ctx context.Context, id string) (*audit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) GetEntriesByResource(ctx context.Context, resourceID string) ([]audit.AuditEntry, error) {
-	return nil, nil
-}
-func (m *MockAuditService) QueryEntries(ctx context.Context, filter protocol.QueryFilter) ([]audit.AuditEntry, error) {
-	return nil, nil
-}
-
 type MockStorage struct{}
 
 func (m *MockStorage) Put(ctx context.Context, bucketName, objectName string, reader io.Reader, objectSize int64, contentType string) (string, error) {
@@ -80,6 +51,8 @@ type MockRepo struct {
 	nextID int
 	events []timeline.Event
 	edges  []timeline.Edge
+	files  []EventFile
+	access []EventFileAccess
 }
 
 func (m *MockRepo) GetEvent(ctx context.Context, id types.ID) (*timeline.Event, error) {
@@ -167,23 +140,110 @@ func (m *MockRepo) DeleteEdge(ctx context.Context, id types.ID) error {
 
 func (m *MockRepo) CreateFile(ctx context.Context, file *EventFile) error { return nil }
 func (m *MockRepo) GetFileByID(ctx context.Context, id string) (*EventFile, error) {
-	return nil, nil
+	for i := range m.files {
+		if m.files[i].ID == id {
+			file := m.files[i]
+			return &file, nil
+		}
+	}
+	return nil, fmt.Errorf("file %s not found", id)
 }
 func (m *MockRepo) GetFilesByEventID(ctx context.Context, eventID string) ([]EventFile, error) {
 	return nil, nil
 }
 func (m *MockRepo) UpsertFileAccess(ctx context.Context, confirmations *EventFileAccess) error { return nil }
 func (m *MockRepo) GetFileAccess(ctx context.Context, fileID string, grantee string) (*EventFileAccess, error) {
-	return nil, nil
+	for i := range m.access {
+		if m.access[i].FileID == fileID && m.access[i].Grantee == grantee {
+			access := m.access[i]
+			return &access, nil
+		}
+	}
+	return nil, fmt.Errorf("no access to file %s for %s", fileID, grantee)
 }
 func (m *MockRepo) GetGraphData(ctx context.Context, patientID string) ([]TimelineEvent, []EventEdge, error) {
-	return []TimelineEvent{}, []EventEdge{}, nil
+	events := make([]TimelineEvent, 0, len(m.events))
+	for i := range m.events {
+		if m.events[i].PatientID.String() == patientID {
+			events = append(events, *ToTimelineEvent(&m.events[i]))
+		}
+	}
+	edges := make([]EventEdge, 0, len(m.edges))
+	for i := range m.edges {
+		edges = append(edges, *ToEventEdge(&m.edges[i]))
+	}
+	return events, edges, nil
 }
 func (m *MockRepo) Transaction(ctx context.Context, fn func(repo Repository) error) error { return fn(m) }
+func (m *MockRepo) UpdateFileAccess(ctx context.Context, access *EventFileAccess) error {
+	for i := range m.access {
+		if m.access[i].FileID == access.FileID && m.access[i].Grantee == access.Grantee {
+			m.access[i] = *access
+			return nil
+		}
+	}
+	return fmt.Errorf("no access to file %s for %s", access.FileID, access.Grantee)
+}
+func (m *MockRepo) ListFileAccessByFile(ctx context.Context, fileID string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	for _, access := range m.access {
+		if access.FileID == fileID {
+			shares = append(shares, access)
+		}
+	}
+	return shares, nil
+}
+func (m *MockRepo) ListFileAccessByGrantee(ctx context.Context, patientID string, grantee string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	for _, access := range m.access {
+		if access.Grantee == grantee && m.filePatient(access.FileID) == patientID {
+			shares = append(shares, access)
+		}
+	}
+	return shares, nil
+}
+func (m *MockRepo) ListExposedFileAccess(ctx context.Context, patientID string) ([]EventFileAccess, error) {
+	var shares []EventFileAccess
+	for _, access := range m.access {
+		exposed := access.KeyFetchedAt != nil && (access.DisabledReason == protocolconsent.StateRevoked || access.DisabledReason == protocolconsent.StateExpired)
+		if exposed && m.filePatient(access.FileID) == patientID {
+			shares = append(shares, access)
+		}
+	}
+	return shares, nil
+}
+func (m *MockRepo) ReplaceFileKey(ctx context.Context, file *EventFile, shares []EventFileAccess) error {
+	for i := range m.files {
+		if m.files[i].ID == file.ID {
+			m.files[i] = *file
+		}
+	}
+	kept := m.access[:0]
+	for _, access := range m.access {
+		if access.FileID != file.ID {
+			kept = append(kept, access)
+		}
+	}
+	m.access = append(kept, shares...)
+	return nil
+}
+func (m *MockRepo) filePatient(fileID string) string {
+	for _, file := range m.files {
+		if file.ID != fileID {
+			continue
+		}
+		for _, event := range m.events {
+			if event.ID.String() == file.EventID {
+				return event.PatientID.String()
+			}
+		}
+	}
+	return ""
+}
 
 func TestService_CreateEvent(t *testing.T) {
 	repo := &MockRepo{}
-	auditSvc := &MockAuditService{}
+	auditSvc := &audittest.Service{}
 	storageSvc := &MockStorage{}
 	svc := NewService(repo, auditSvc, storageSvc, "test-bucket")
 
@@ -217,7 +277,7 @@ func TestService_CreateEvent(t *testing.T) {
 
 func TestService_GetTimelineForPatient_FiltersByPatient(t *testing.T) {
 	repo := &MockRepo{}
-	auditSvc := &MockAuditService{}
+	auditSvc := &audittest.Service{}
 	storageSvc := &MockStorage{}
 	svc := NewService(repo, auditSvc, storageSvc, "test-bucket")
 
@@ -237,3 +297,381 @@ func TestService_GetTimelineForPatient_FiltersByPatient(t *testing.T) {
 		t.Fatalf("GetTimelineForPatient() count = %d, want %d", len(got), 1)
 	}
 }
+
+// typeScope is an AccessScope covering a fixed set of event types.
+type typeScope []timeline.EventType
+
+func (s typeScope) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	for _, t := range s {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func seedScopedTimeline(t *testing.T, svc Service) (types.WalletAddress, *timeline.Event, *timeline.Event) {
+	t.Helper()
+
+	patientID, _ := types.NewWalletAddress("0x0000000000000000000000000000000000000123")
+	lab, _ := timeline.NewEventBuilder().WithPatientID(patientID).WithType(timeline.EventLabResult).WithTitle("Blood Test").WithTimestamp(time.Now()).Build()
+	diagnosis, _ := timeline.NewEventBuilder().WithPatientID(patientID).WithType(timeline.EventDiagnosis).WithTitle("Diagnosis").WithTimestamp(time.Now()).Build()
+	if err := svc.CreateEvent(context.Background(), lab); err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if err := svc.CreateEvent(context.Background(), diagnosis); err != nil {
+		t.Fatalf("CreateEvent() error = %v", err)
+	}
+	if _, err := svc.LinkEventsProtocol(context.Background(), lab.ID, diagnosis.ID, timeline.RelSupports); err != nil {
+		t.Fatalf("LinkEventsProtocol() error = %v", err)
+	}
+	return patientID, lab, diagnosis
+}
+
+func TestService_GetTimeline_FiltersByScope(t *testing.T) {
+	svc := NewService(&MockRepo{}, &audittest.Service{}, &MockStorage{}, "test-bucket")
+	patientID, lab, _ := seedScopedTimeline(t, svc)
+
+	all, err := svc.GetTimeline(context.Background(), patientID.String(), nil)
+	if err != nil {
+		t.Fatalf("GetTimeline() error = %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("GetTimeline() without scope count = %d, want %d", len(all), 2)
+	}
+
+	scoped, err := svc.GetTimeline(context.Background(), patientID.String(), typeScope{timeline.EventLabResult})
+	if err != nil {
+		t.Fatalf("GetTimeline() error = %v", err)
+	}
+	if len(scoped) != 1 || scoped[0].ID != lab.ID.String() {
+		t.Fatalf("GetTimeline() with scope = %+v, want only %s", scoped, lab.ID)
+	}
+}
+
+func TestService_GetGraphData_DropsEdgesToOutOfScopeEvents(t *testing.T) {
+	svc := NewService(&MockRepo{}, &audittest.Service{}, &MockStorage{}, "test-bucket")
+	patientID, _, _ := seedScopedTimeline(t, svc)
+
+	full, err := svc.GetGraphData(context.Background(), patientID.String(), nil)
+	if err != nil {
+		t.Fatalf("GetGraphData() error = %v", err)
+	}
+	if len(full.Events) != 2 || len(full.Edges) != 1 {
+		t.Fatalf("GetGraphData() without scope = %d events, %d edges, want 2 and 1", len(full.Events), len(full.Edges))
+	}
+
+	scoped, err := svc.GetGraphData(context.Background(), patientID.String(), typeScope{timeline.EventLabResult})
+	if err != nil {
+		t.Fatalf("GetGraphData() error = %v", err)
+	}
+	if len(scoped.Events) != 1 || len(scoped.Edges) != 0 {
+		t.Fatalf("GetGraphData() with scope = %d events, %d edges, want 1 and 0", len(scoped.Events), len(scoped.Edges))
+	}
+}
+
+func TestService_GetFileKey_RejectsFileFromAnotherEvent(t *testing.T) {
+	repo := &MockRepo{files: []EventFile{{ID: "file-1", EventID: "evt-1", WrappedDEK: []byte{0x01}}}}
+	svc := NewService(repo, &audittest.Service{}, &MockStorage{}, "test-bucket")
+	patient := "0x0000000000000000000000000000000000000123"
+
+	if _, err := svc.GetFileKey(context.Background(), "evt-2", "file-1", Reader{Actor: patient, PatientID: patient}); !errors.Is(err, ErrFileNotInEvent) {
+		t.Fatalf("GetFileKey() error = %v, want %v", err, ErrFileNotInEvent)
+	}
+
+	key, err := svc.GetFileKey(context.Background(), "evt-1", "file-1", Reader{Actor: patient, PatientID: patient})
+	if err != nil {
+		t.Fatalf("GetFileKey() error = %v", err)
+	}
+	if len(key) != 1 || key[0] != 0x01 {
+		t.Fatalf("GetFileKey() = %x, want 01", key)
+	}
+}
+
+func TestService_RecordAccess_OnlyNonOwners(t *testing.T) {
+	auditService := &audittest.Service{}
+	svc := NewService(&MockRepo{}, auditService, &MockStorage{}, "test-bucket")
+	patient := "0x0000000000000000000000000000000000000ABC"
+	doctor := "0x0000000000000000000000000000000000000def"
+
+	svc.RecordAccess(context.Background(), Reader{Actor: patient, PatientID: patient}, protocol.ResourceEvent, "evt-1", nil)
+	if len(auditService.Recorded) != 0 {
+		t.Fatalf("RecordAccess() by the patient recorded %d entries, want 0", len(auditService.Recorded))
+	}
+
+	svc.RecordAccess(context.Background(), Reader{Actor: doctor, PatientID: patient, GrantID: "grant-1"}, protocol.ResourceEvent, "evt-1", common.JSONMap{"view": "event"})
+	if len(auditService.Recorded) != 1 {
+		t.Fatalf("RecordAccess() by a grantee recorded %d entries, want 1", len(auditService.Recorded))
+	}
+	entry := auditService.Recorded[0]
+	if entry.Actor != doctor || entry.Action != protocol.ActionRead || entry.ResourceID != "evt-1" {
+		t.Errorf("recorded %+v", entry)
+	}
+	if entry.Metadata[audit.MetadataSubject] != strings.ToLower(patient) || entry.Metadata[audit.MetadataGrantID] != "grant-1" || entry.Metadata["view"] != "event" {
+		t.Errorf("recorded metadata = %v", entry.Metadata)
+	}
+
+	// A caregiver reading as the patient is recorded under the patient.
+	ctx := audit.WithDelegate(context.Background(), doctor, "delegation-1")
+	svc.RecordAccess(ctx, Reader{Actor: patient, PatientID: patient}, protocol.ResourceEvent, "evt-1", nil)
+	if len(auditService.Recorded) != 2 || auditService.Recorded[1].Actor != patient {
+		t.Fatalf("RecordAccess() by a delegate recorded %+v, want one entry under the patient", auditService.Recorded)
+	}
+}
+
+// grantScope resolves each event type to the grant covering it.
+type grantScope map[timeline.EventType]string
+
+func (s grantScope) AllowsEvent(eventID string, eventType timeline.EventType, codes types.Codes) bool {
+	return s[eventType] != ""
+}
+
+func (s grantScope) GrantFor(eventID string, eventType timeline.EventType, codes types.Codes) string {
+	return s[eventType]
+}
+
+func TestReader_ForEvents_AttributesCoveringGrants(t *testing.T) {
+	scope := grantScope{timeline.EventLabResult: "grant-1", timeline.EventDiagnosis: "grant-2"}
+	reader := Reader{Actor: "0x0000000000000000000000000000000000000456", PatientID: "0x0000000000000000000000000000000000000123", GrantID: "grant-2", Scope: scope}
+	lab := TimelineEvent{ID: "evt-1", Type: timeline.EventLabResult}
+	diagnosis := TimelineEvent{ID: "evt-2", Type: timeline.EventDiagnosis}
+
+	if got := reader.ForEvents([]TimelineEvent{lab, lab}); got.GrantID != "grant-1" || len(got.GrantIDs) != 0 {
+		t.Errorf("ForEvents(labs) = %q %v, want grant-1 alone", got.GrantID, got.GrantIDs)
+	}
+	both := reader.ForEvents([]TimelineEvent{lab, diagnosis})
+	if both.GrantID != "" || len(both.GrantIDs) != 2 || both.GrantIDs[0] != "grant-1" || both.GrantIDs[1] != "grant-2" {
+		t.Errorf("ForEvents(both) = %q %v, want [grant-1 grant-2]", both.GrantID, both.GrantIDs)
+	}
+	if got := reader.ForEvents(nil); got.GrantID != "grant-2" {
+		t.Errorf("ForEvents(nil) GrantID = %q, want the reader's own", got.GrantID)
+	}
+
+	metadata := both.auditMetadata(nil)
+	if _, ok := metadata[audit.MetadataGrantID]; ok || len(metadata[audit.MetadataGrantIDs].([]string)) != 2 {
+		t.Errorf("auditMetadata() = %v, want grantIds only", metadata)
+	}
+}
+
+func TestReader_ForEvents_KeepsEmergencySessionForUncoveredEvents(t *testing.T) {
+	scope := grantScope{timeline.EventLabResult: "grant-1"}
+	reader := Reader{Actor: "0x0000000000000000000000000000000000000456", PatientID: "0x0000000000000000000000000000000000000123", EmergencySessionID: "session-1", Scope: scope}
+	lab := TimelineEvent{ID: "evt-1", Type: timeline.EventLabResult}
+	allergy := TimelineEvent{ID: "evt-2", Type: timeline.EventAllergy}
+
+	if got := reader.ForEvents([]TimelineEvent{lab}); got.EmergencySessionID != "" {
+		t.Errorf("ForEvents(granted lab) EmergencySessionID = %q, want none", got.EmergencySessionID)
+	}
+	mixed := reader.ForEvents([]TimelineEvent{lab, allergy})
+	if mixed.EmergencySessionID != "session-1" || mixed.GrantID != "grant-1" {
+		t.Errorf("ForEvents(lab, allergy) = %q %q, want grant-1 and session-1", mixed.GrantID, mixed.EmergencySessionID)
+	}
+	if got := mixed.auditMetadata(nil)[audit.MetadataEmergencySessionID]; got != "session-1" {
+		t.Errorf("auditMetadata() emergency session = %v, want session-1", got)
+	}
+}
+
+func TestService_GetFileKey_RecordsGranteeReads(t *testing.T) {
+	patient := "0x0000000000000000000000000000000000000123"
+	doctor := "0x0000000000000000000000000000000000000456"
+	repo := &MockRepo{
+		files:  []EventFile{{ID: "file-1", EventID: "evt-1", WrappedDEK: []byte{0x01}}},
+		access: []EventFileAccess{{FileID: "file-1", Grantee: doctor, WrappedDEK: []byte{0x02}}},
+	}
+	auditService := &audittest.Service{}
+	svc := NewService(repo, auditService, &MockStorage{}, "test-bucket")
+
+	if _, err := svc.GetFileKey(context.Background(), "evt-1", "file-1", Reader{Actor: patient, PatientID: patient}); err != nil {
+		t.Fatalf("GetFileKey() by patient error = %v", err)
+	}
+	key, err := svc.GetFileKey(context.Background(), "evt-1", "file-1", Reader{Actor: doctor, PatientID: patient, GrantID: "grant-1"})
+	if err != nil {
+		t.Fatalf("GetFileKey() by grantee error = %v", err)
+	}
+	if len(key) != 1 || key[0] != 0x02 {
+		t.Fatalf("GetFileKey() = %x, want 02", key)
+	}
+	if len(auditService.Recorded) != 1 || auditService.Recorded[0].Actor != doctor || auditService.Recorded[0].ResourceType != protocol.ResourceFile {
+		t.Fatalf("recorded %+v, want one key read by the grantee", auditService.Recorded)
+	}
+}
+
+func TestService_GetFile_RejectsFileFromAnotherEvent(t *testing.T) {
+	repo := &MockRepo{files: []EventFile{{ID: "file-1", EventID: "evt-1"}}}
+	auditService := &audittest.Service{}
+	svc := NewService(repo, auditService, &MockStorage{}, "test-bucket")
+	reader := Reader{Actor: "0x0000000000000000000000000000000000000456", PatientID: "0x0000000000000000000000000000000000000123"}
+
+	if _, _, err := svc.GetFile(context.Background(), "evt-2", "file-1", reader); !errors.Is(err, ErrFileNotInEvent) {
+		t.Fatalf("GetFile() error = %v, want %v", err, ErrFileNotInEvent)
+	}
+	if len(auditService.Recorded) != 0 {
+		t.Fatalf("GetFile() recorded a download of a file outside the event")
+	}
+
+	if _, _, err := svc.GetFile(context.Background(), "evt-1", "file-1", reader); err != nil {
+		t.Fatalf("GetFile() error = %v", err)
+	}
+	if len(auditService.Recorded) != 1 || auditService.Recorded[0].Action != protocol.ActionDownload || auditService.Recorded[0].Metadata[audit.MetadataSubject] != reader.PatientID {
+		t.Fatalf("recorded %+v, want a download with the patient as subject", auditService.Recorded)
+	}
+}
+
+func TestService_GetFileKey_RequiresActiveShare(t *testing.T) {
+	patient := "0x0000000000000000000000000000000000000123"
+	doctor := "0x0000000000000000000000000000000000000456"
+	repo := &MockRepo{
+		files:  []EventFile{{ID: "file-1", EventID: "evt-1"}},
+		access: []EventFileAccess{{FileID: "file-1", Grantee: doctor, WrappedDEK: []byte{0x02}}},
+	}
+	svc := NewService(repo, &audittest.Service{}, &MockStorage{}, "test-bucket")
+	ctx := context.Background()
+
+	emergency := Reader{Actor: doctor, PatientID: patient, EmergencySessionID: "session-1"}
+	if _, err := svc.GetFileKey(ctx, "evt-1", "file-1", emergency); !errors.Is(err, ErrNoFileConsent) {
+		t.Fatalf("GetFileKey() under an emergency session error = %v, want %v", err, ErrNoFileConsent)
+	}
+	if repo.access[0].KeyFetchedAt != nil {
+		t.Fatal("a refused key request marked the key as fetched")
+	}
+
+	if _, err := svc.GetFileKey(ctx, "evt-1", "file-1", Reader{Actor: doctor, PatientID: patient, GrantID: "grant-1"}); err != nil {
+		t.Fatalf("GetFileKey() error = %v", err)
+	}
+	if repo.access[0].KeyFetchedAt == nil {
+		t.Fatal("GetFileKey() did not record that the grantee fetched the key")
+	}
+
+	now := time.Now()
+	repo.access[0].DisabledAt, repo.access[0].DisabledReason = &now, protocolconsent.StateRevoked
+	if _, err := svc.GetFileKey(ctx, "evt-1", "file-1", Reader{Actor: doctor, PatientID: patient, GrantID: "grant-1"}); !errors.Is(err, ErrFileAccessDisabled) {
+		t.Fatalf("GetFileKey() of a disabled share error = %v, want %v", err, ErrFileAccessDisabled)
+	}
+}
+
+func TestService_SyncFileAccess_FollowsConsent(t *testing.T) {
+	patient := types.WalletAddress("0x0000000000000000000000000000000000000123")
+	doctor := "0x0000000000000000000000000000000000000456"
+	repo := &MockRepo{
+		events: []timeline.Event{
+			{ID: "evt-1", PatientID: patient, Type: timeline.EventLabResult},
+			{ID: "evt-2", PatientID: patient, Type: timeline.EventDiagnosis},
+		},
+		files: []EventFile{{ID: "file-1", EventID: "evt-1"}, {ID: "file-2", EventID: "evt-2"}},
+		access: []EventFileAccess{
+			{FileID: "file-1", Grantee: doctor, WrappedDEK: []byte{0x01}},
+			{FileID: "file-2", Grantee: doctor, WrappedDEK: []byte{0x02}},
+		},
+	}
+	auditService := &audittest.Service{}
+	svc := NewService(repo, auditService, &MockStorage{}, "test-bucket")
+	ctx := context.Background()
+
+	labs := &protocolconsent.Grant{ID: "grant-1", Grantor: patient, Grantee: types.WalletAddress(doctor), Permissions: protocolconsent.Permissions{protocolconsent.PermRead}, Scope: []types.ID{types.ID(timeline.EventLabResult)}, State: protocolconsent.StateApproved}
+	everything := &protocolconsent.Grant{ID: "grant-2", Grantor: patient, Grantee: types.WalletAddress(doctor), Permissions: protocolconsent.Permissions{protocolconsent.PermRead}, State: protocolconsent.StateApproved}
+	disabled := func() map[string]protocolconsent.State {
+		states := make(map[string]protocolconsent.State)
+		for _, access := range repo.access {
+			if access.IsDisabled() {
+				states[access.FileID] = access.DisabledReason
+			}
+		}
+		return states
+	}
+
+	// Suspending the broad grant leaves only the lab grant in force.
+	if err := svc.SyncFileAccess(ctx, patient.String(), doctor, []*protocolconsent.Grant{labs}, protocolconsent.StateSuspended); err != nil {
+		t.Fatalf("SyncFileAccess(suspended) error = %v", err)
+	}
+	if got := disabled(); len(got) != 1 || got["file-2"] != protocolconsent.StateSuspended {
+		t.Fatalf("after suspension disabled = %v, want file-2 suspended", got)
+	}
+
+	if err := svc.SyncFileAccess(ctx, patient.String(), doctor, []*protocolconsent.Grant{everything, labs}, protocolconsent.StateApproved); err != nil {
+		t.Fatalf("SyncFileAccess(approved) error = %v", err)
+	}
+	if got := disabled(); len(got) != 0 {
+		t.Fatalf("after resume disabled = %v, want none", got)
+	}
+
+	if _, err := svc.GetFileKey(ctx, "evt-1", "file-1", Reader{Actor: doctor, PatientID: patient.String(), GrantID: "grant-1"}); err != nil {
+		t.Fatalf("GetFileKey() error = %v", err)
+	}
+	if err := svc.SyncFileAccess(ctx, patient.String(), doctor, nil, protocolconsent.StateRevoked); err != nil {
+		t.Fatalf("SyncFileAccess(revoked) error = %v", err)
+	}
+	if got := disabled(); len(got) != 2 || got["file-1"] != protocolconsent.StateRevoked || got["file-2"] != protocolconsent.StateRevoked {
+		t.Fatalf("after revocation disabled = %v, want both revoked", got)
+	}
+	// Another grant's resumption must not revive shares a revocation disabled.
+	if err := svc.SyncFileAccess(ctx, patient.String(), doctor, []*protocolconsent.Grant{everything}, protocolconsent.StateApproved); err != nil {
+		t.Fatalf("SyncFileAccess(approved) error = %v", err)
+	}
+	if got := disabled(); len(got) != 2 {
+		t.Fatalf("resuming another grant re-enabled revoked shares: disabled = %v", got)
+	}
+
+	var unshares, reshares int
+	for _, entry := range auditService.Recorded {
+		switch entry.Action {
+		case protocol.ActionUnshare:
+			unshares++
+		case protocol.ActionShare:
+			reshares++
+		}
+	}
+	if unshares != 3 || reshares != 1 {
+		t.Errorf("recorded %d unshare and %d share entries, want 3 and 1", unshares, reshares)
+	}
+
+	pending, err := svc.GetPendingRekeys(ctx, patient.String())
+	if err != nil {
+		t.Fatalf("GetPendingRekeys() error = %v", err)
+	}
+	if len(pending) != 1 || pending[0].File.ID != "file-1" || len(pending[0].Grantees) != 1 || pending[0].Grantees[0] != doctor {
+		t.Fatalf("GetPendingRekeys() = %+v, want file-1 exposed to the doctor", pending)
+	}
+}
+
+func TestService_RekeyFile_KeepsOnlyActiveShares(t *testing.T) {
+	patient := types.WalletAddress("0x0000000000000000000000000000000000000123")
+	doctor := "0x0000000000000000000000000000000000000456"
+	revoked := "0x0000000000000000000000000000000000000789"
+	fetched := time.Now()
+	repo := &MockRepo{
+		events: []timeline.Event{{ID: "evt-1", PatientID: patient, Type: timeline.EventLabResult}},
+		files:  []EventFile{{ID: "file-1", EventID: "evt-1", BlobRef: "evt-1/file-1", WrappedDEK: []byte{0x01}, KeyVersion: 1}},
+		access: []EventFileAccess{
+			{FileID: "file-1", Grantee: doctor, WrappedDEK: []byte{0x02}},
+			{FileID: "file-1", Grantee: revoked, WrappedDEK: []byte{0x03}, KeyFetchedAt: &fetched, DisabledAt: &fetched, DisabledReason: protocolconsent.StateRevoked},
+		},
+	}
+	auditService := &audittest.Service{}
+	svc := NewService(repo, auditService, &MockStorage{}, "test-bucket")
+	ctx := context.Background()
+
+	_, err := svc.RekeyFile(ctx, "evt-1", "file-1", strings.NewReader("ciphertext"), 10, []byte{0x11}, nil, map[string][]byte{revoked: {0x13}})
+	var validationErr types.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("RekeyFile() sharing with a revoked grantee error = %v, want a validation error", err)
+	}
+
+	file, err := svc.RekeyFile(ctx, "evt-1", "file-1", strings.NewReader("ciphertext"), 10, []byte{0x11}, nil, map[string][]byte{strings.ToUpper(doctor[:2]) + doctor[2:]: {0x12}})
+	if err != nil {
+		t.Fatalf("RekeyFile() error = %v", err)
+	}
+	if file.KeyVersion != 2 || file.BlobRef != "evt-1/file-1.v2" || file.WrappedDEK[0] != 0x11 {
+		t.Fatalf("RekeyFile() = %+v, want version 2 under a new blob and key", file)
+	}
+	if len(repo.access) != 1 || repo.access[0].Grantee != doctor || repo.access[0].WrappedDEK[0] != 0x12 {
+		t.Fatalf("shares after re-key = %+v, want only the doctor's re-wrapped key", repo.access)
+	}
+	if pending, _ := svc.GetPendingRekeys(ctx, patient.String()); len(pending) != 0 {
+		t.Errorf("GetPendingRekeys() after re-key = %+v, want none", pending)
+	}
+
+	last := auditService.Recorded[len(auditService.Recorded)-1]
+	if last.Action != protocol.ActionRekey || last.Actor != patient.String() || last.Metadata["keyVersion"] != 2 {
+		t.Errorf("recorded %+v, want file.rekey at version 2 under the patient", last)
+	}
+}
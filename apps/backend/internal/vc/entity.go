@@ -0,0 +1,105 @@
+package vc
+
+import (
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"github.com/itspablomontes/fleming/pkg/protocol/vc"
+)
+
+// Credential is the database model for an issued verifiable credential.
+// Every claim is stored as a salted SD-JWT disclosure so the holder can later
+// reveal any subset of them; Digests are the commitments verifiers check against.
+// A credential stays pending until the issuer signs those commitments, and only
+// then can it be presented.
+type Credential struct {
+	ID               string              `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Issuer           string              `json:"issuer" gorm:"index;type:varchar(255);not null"`
+	Subject          string              `json:"subject" gorm:"index;type:varchar(255);not null"` // Patient / holder
+	ClaimType        vc.ClaimType        `json:"claimType" gorm:"type:varchar(100);not null"`
+	Claims           common.JSONMap      `json:"claims" gorm:"type:jsonb"`
+	Disclosures      common.JSONStrings  `json:"disclosures" gorm:"type:jsonb"` // Encoded [salt, key, value] arrays
+	Digests          common.JSONStrings  `json:"digests" gorm:"type:jsonb"`     // base64url SHA-256 of each disclosure
+	SourceEventIDs   common.JSONStrings  `json:"sourceEventIds" gorm:"type:jsonb"`
+	Status           vc.CredentialStatus `json:"status" gorm:"type:varchar(50);not null"`
+	RevocationListID string              `json:"revocationListId" gorm:"type:uuid;index"`
+	RevocationIndex  uint64              `json:"revocationIndex"`
+	IssuedAt         time.Time           `json:"issuedAt" gorm:"not null"`
+	ExpiresAt        *time.Time          `json:"expiresAt,omitempty" gorm:"index"`
+	SchemaVersion    string              `json:"schemaVersion" gorm:"type:varchar(50)"`
+	ProofType        string              `json:"proofType,omitempty" gorm:"type:varchar(50)"`
+	ProofValue       string              `json:"proofValue,omitempty" gorm:"type:varchar(132)"` // Issuer's EIP-191 signature
+	ProofCreated     *time.Time          `json:"proofCreated,omitempty"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for credentials.
+func (Credential) TableName() string {
+	return "credentials"
+}
+
+// IsExpired reports whether the credential is past its expiry.
+func (c *Credential) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && now.After(*c.ExpiresAt)
+}
+
+// Proof returns the issuer's proof, or nil while the credential awaits signing.
+func (c *Credential) Proof() *vc.Proof {
+	if c.ProofValue == "" || c.ProofCreated == nil {
+		return nil
+	}
+	return &vc.Proof{
+		Type:               c.ProofType,
+		Created:            *c.ProofCreated,
+		VerificationMethod: types.WalletAddress(c.Issuer),
+		ProofValue:         c.ProofValue,
+	}
+}
+
+// RevocationList is the persisted status list an issuer uses to revoke credentials.
+// Each issuer owns one list; credentials are allocated consecutive indices in it.
+type RevocationList struct {
+	ID            string    `json:"id" gorm:"primaryKey;type:uuid;default:gen_random_uuid()"`
+	Issuer        string    `json:"issuer" gorm:"uniqueIndex;type:varchar(255);not null"`
+	Purpose       string    `json:"purpose" gorm:"type:varchar(50);not null"`
+	Bitmap        []byte    `json:"bitmap" gorm:"type:bytea;not null"`
+	Size          uint64    `json:"size" gorm:"not null"`
+	NextIndex     uint64    `json:"-" gorm:"not null;default:0"` // Next unallocated index
+	SchemaVersion string    `json:"schemaVersion" gorm:"type:varchar(50)"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// TableName returns the custom table name for revocation lists.
+func (RevocationList) TableName() string {
+	return "credential_revocation_lists"
+}
+
+// Presentation is what a holder hands to a verifier: the credential envelope
+// plus the encoded disclosures for the claims they chose to reveal.
+type Presentation struct {
+	CredentialID     string       `json:"credentialId"`
+	Issuer           string       `json:"issuer"`
+	Subject          string       `json:"subject"`
+	ClaimType        vc.ClaimType `json:"claimType"`
+	Digests          []string     `json:"digests"`
+	Disclosures      []string     `json:"disclosures"`
+	RevocationListID string       `json:"revocationListId"`
+	RevocationIndex  uint64       `json:"revocationIndex"`
+	IssuedAt         time.Time    `json:"issuedAt"`
+	ExpiresAt        *time.Time   `json:"expiresAt,omitempty"`
+	SchemaVersion    string       `json:"schemaVersion"`
+	Proof            *vc.Proof    `json:"proof,omitempty"` // Issuer's signature over the envelope and digests
+}
+
+// VerificationResult reports the outcome of verifying a presentation.
+type VerificationResult struct {
+	Valid        bool                `json:"valid"`
+	CredentialID string              `json:"credentialId"`
+	Status       vc.CredentialStatus `json:"status,omitempty"`
+	Claims       map[string]any      `json:"claims,omitempty"` // Only the disclosed claims
+	Errors       []string            `json:"errors,omitempty"`
+	CheckedAt    time.Time           `json:"checkedAt"`
+}
@@ -0,0 +1,293 @@
+package vc
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"github.com/itspablomontes/fleming/pkg/protocol/vc"
+)
+
+// Handler handles HTTP requests for verifiable credentials.
+type Handler struct {
+	service Service
+}
+
+// NewHandler creates a new credential handler.
+func NewHandler(service Service) *Handler {
+	return &Handler{service: service}
+}
+
+// RegisterRoutes registers the authenticated credential endpoints.
+func (h *Handler) RegisterRoutes(rg *gin.RouterGroup) {
+	vcGroup := rg.Group("/vc")
+	{
+		vcGroup.POST("/credentials", h.HandleIssue)
+		vcGroup.GET("/credentials", h.HandleList)
+		vcGroup.GET("/credentials/:id", h.HandleGetByID)
+		vcGroup.GET("/credentials/:id/signing-message", h.HandleSigningMessage)
+		vcGroup.POST("/credentials/:id/sign", h.HandleSign)
+		vcGroup.POST("/credentials/:id/present", h.HandlePresent)
+		vcGroup.POST("/credentials/:id/revoke", h.HandleRevoke)
+	}
+}
+
+// RegisterPublicRoutes registers the endpoints verifiers call without a Fleming account.
+// rg should already be scoped to the vc prefix.
+func (h *Handler) RegisterPublicRoutes(rg *gin.RouterGroup) {
+	rg.POST("/verify", h.HandleVerify)
+	rg.GET("/revocation-lists/:id", h.HandleGetRevocationList)
+}
+
+// IssueCredentialDTO is the payload for issuing a credential.
+type IssueCredentialDTO struct {
+	Subject        string         `json:"subject"` // Optional: defaults to the caller (self-issued)
+	ClaimType      vc.ClaimType   `json:"claimType" binding:"required"`
+	Claims         map[string]any `json:"claims" binding:"required"`
+	SourceEventIDs []string       `json:"sourceEventIds" binding:"required"`
+	TTLDays        int            `json:"ttlDays"` // Optional: how long the credential stays valid
+}
+
+// SignDTO carries the issuer's signature over the credential's signing message.
+type SignDTO struct {
+	Signature string `json:"signature" binding:"required"`
+}
+
+// PresentDTO is the payload for creating a presentation.
+type PresentDTO struct {
+	DisclosedKeys []string `json:"disclosedKeys" binding:"required"`
+}
+
+func getUserAddress(c *gin.Context) (string, bool) {
+	address, ok := c.Get("user_address")
+	if !ok {
+		return "", false
+	}
+	value, ok := address.(string)
+	if !ok || value == "" {
+		return "", false
+	}
+	return value, true
+}
+
+// writeError maps service errors onto HTTP status codes.
+func writeError(c *gin.Context, err error, fallback string) {
+	var validationErrs types.ValidationErrors
+	switch {
+	case errors.Is(err, ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "credential not found"})
+	case errors.Is(err, ErrNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.As(err, &validationErrs),
+		errors.Is(err, ErrInvalidSourceEvent),
+		errors.Is(err, ErrCredentialNotUsable),
+		errors.Is(err, ErrUnknownClaim),
+		errors.Is(err, ErrInvalidSignature):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// HandleIssue drafts a credential over the subject's timeline events. It stays
+// pending until the issuer signs it through HandleSign.
+func (h *Handler) HandleIssue(c *gin.Context) {
+	issuer, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req IssueCredentialDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if req.Subject == "" {
+		req.Subject = issuer
+	}
+
+	issue := IssueRequest{
+		Subject:        req.Subject,
+		ClaimType:      req.ClaimType,
+		Claims:         req.Claims,
+		SourceEventIDs: req.SourceEventIDs,
+	}
+	if req.TTLDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.TTLDays)
+		issue.ExpiresAt = &expiresAt
+	}
+
+	credential, err := h.service.IssueCredential(c.Request.Context(), issuer, issue)
+	if err != nil {
+		writeError(c, err, "failed to issue credential")
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// HandleList returns the caller's credentials: held by default, or issued with ?role=issuer.
+func (h *Handler) HandleList(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var (
+		credentials []Credential
+		err         error
+	)
+	switch c.DefaultQuery("role", "holder") {
+	case "holder":
+		credentials, err = h.service.GetHeldCredentials(c.Request.Context(), address)
+	case "issuer":
+		credentials, err = h.service.GetIssuedCredentials(c.Request.Context(), address)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be holder or issuer"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch credentials"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"credentials": credentials})
+}
+
+// HandleGetByID returns a credential to its issuer or subject.
+func (h *Handler) HandleGetByID(c *gin.Context) {
+	address, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	credential, err := h.service.GetCredential(c.Request.Context(), address, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to fetch credential")
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// HandleSigningMessage returns the message the issuer signs with their wallet.
+func (h *Handler) HandleSigningMessage(c *gin.Context) {
+	issuer, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	message, err := h.service.GetSigningMessage(c.Request.Context(), issuer, c.Param("id"))
+	if err != nil {
+		writeError(c, err, "failed to build signing message")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// HandleSign records the issuer's proof and activates the credential.
+func (h *Handler) HandleSign(c *gin.Context) {
+	issuer, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req SignDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	credential, err := h.service.SignCredential(c.Request.Context(), issuer, c.Param("id"), req.Signature)
+	if err != nil {
+		writeError(c, err, "failed to sign credential")
+		return
+	}
+
+	c.JSON(http.StatusOK, credential)
+}
+
+// HandlePresent creates a selective-disclosure presentation for the holder.
+func (h *Handler) HandlePresent(c *gin.Context) {
+	holder, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var req PresentDTO
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	presentation, err := h.service.CreatePresentation(c.Request.Context(), holder, c.Param("id"), req.DisclosedKeys)
+	if err != nil {
+		writeError(c, err, "failed to create presentation")
+		return
+	}
+
+	c.JSON(http.StatusOK, presentation)
+}
+
+// HandleRevoke revokes a credential on behalf of its issuer.
+func (h *Handler) HandleRevoke(c *gin.Context) {
+	issuer, ok := getUserAddress(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	if err := h.service.RevokeCredential(c.Request.Context(), issuer, c.Param("id")); err != nil {
+		writeError(c, err, "failed to revoke credential")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleVerify checks a presentation's issuer proof, disclosures, expiry and revocation status.
+// It is public: a verifier does not need a Fleming account.
+func (h *Handler) HandleVerify(c *gin.Context) {
+	var presentation Presentation
+	if err := c.ShouldBindJSON(&presentation); err != nil || presentation.CredentialID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid presentation"})
+		return
+	}
+
+	verifier, _ := getUserAddress(c)
+	result, err := h.service.VerifyPresentation(c.Request.Context(), verifier, &presentation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification failed"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// HandleGetRevocationList publishes an issuer's revocation bitmap so verifiers can check status themselves.
+func (h *Handler) HandleGetRevocationList(c *gin.Context) {
+	list, err := h.service.GetRevocationList(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "revocation list not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch revocation list"})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
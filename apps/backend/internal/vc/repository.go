@@ -0,0 +1,124 @@
+package vc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Repository defines the interface for credential persistence.
+type Repository interface {
+	CreateCredential(ctx context.Context, credential *Credential) error
+	GetCredentialByID(ctx context.Context, id string) (*Credential, error)
+	GetCredentialsBySubject(ctx context.Context, subject string) ([]Credential, error)
+	GetCredentialsByIssuer(ctx context.Context, issuer string) ([]Credential, error)
+	UpdateCredential(ctx context.Context, credential *Credential) error
+
+	GetRevocationListByID(ctx context.Context, id string) (*RevocationList, error)
+	// GetRevocationListByIssuer returns the issuer's list, or nil when the issuer has
+	// no list yet.
+	GetRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error)
+	// LockRevocationListByIssuer returns the issuer's list with a row lock held for the
+	// enclosing transaction, or nil when the issuer has no list yet.
+	LockRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error)
+	SaveRevocationList(ctx context.Context, list *RevocationList) error
+
+	Transaction(ctx context.Context, fn func(repo Repository) error) error
+}
+
+type gormRepository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new GORM repository for credentials.
+func NewRepository(db *gorm.DB) Repository {
+	return &gormRepository{db: db}
+}
+
+func (r *gormRepository) CreateCredential(ctx context.Context, credential *Credential) error {
+	if err := r.db.WithContext(ctx).Create(credential).Error; err != nil {
+		return fmt.Errorf("create credential: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetCredentialByID(ctx context.Context, id string) (*Credential, error) {
+	var credential Credential
+	if err := r.db.WithContext(ctx).First(&credential, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get credential %s: %w", id, err)
+	}
+	return &credential, nil
+}
+
+func (r *gormRepository) GetCredentialsBySubject(ctx context.Context, subject string) ([]Credential, error) {
+	var credentials []Credential
+	if err := r.db.WithContext(ctx).Where("subject = ?", subject).Order("issued_at DESC").Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("list credentials for subject %s: %w", subject, err)
+	}
+	return credentials, nil
+}
+
+func (r *gormRepository) GetCredentialsByIssuer(ctx context.Context, issuer string) ([]Credential, error) {
+	var credentials []Credential
+	if err := r.db.WithContext(ctx).Where("issuer = ?", issuer).Order("issued_at DESC").Find(&credentials).Error; err != nil {
+		return nil, fmt.Errorf("list credentials from issuer %s: %w", issuer, err)
+	}
+	return credentials, nil
+}
+
+func (r *gormRepository) UpdateCredential(ctx context.Context, credential *Credential) error {
+	if err := r.db.WithContext(ctx).Save(credential).Error; err != nil {
+		return fmt.Errorf("update credential: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) GetRevocationListByID(ctx context.Context, id string) (*RevocationList, error) {
+	var list RevocationList
+	if err := r.db.WithContext(ctx).First(&list, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("get revocation list %s: %w", id, err)
+	}
+	return &list, nil
+}
+
+func (r *gormRepository) GetRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error) {
+	var list RevocationList
+	if err := r.db.WithContext(ctx).Where("issuer = ?", issuer).First(&list).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("get revocation list for %s: %w", issuer, err)
+	}
+	return &list, nil
+}
+
+func (r *gormRepository) LockRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error) {
+	var list RevocationList
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("issuer = ?", issuer).
+		First(&list).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("lock revocation list for %s: %w", issuer, err)
+	}
+	return &list, nil
+}
+
+func (r *gormRepository) SaveRevocationList(ctx context.Context, list *RevocationList) error {
+	if err := r.db.WithContext(ctx).Save(list).Error; err != nil {
+		return fmt.Errorf("save revocation list: %w", err)
+	}
+	return nil
+}
+
+func (r *gormRepository) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&gormRepository{db: tx})
+	})
+}
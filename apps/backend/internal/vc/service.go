@@ -0,0 +1,613 @@
+package vc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
+	"github.com/itspablomontes/fleming/apps/backend/internal/common"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"github.com/itspablomontes/fleming/pkg/protocol/vc"
+	"gorm.io/gorm"
+)
+
+// ErrForbidden is wrapped by every error raised when the caller may not act on a credential.
+var ErrForbidden = errors.New("not allowed to act on this credential")
+
+var (
+	// ErrNotHolder is returned when someone other than the subject tries to present a credential.
+	ErrNotHolder = fmt.Errorf("%w: only the credential subject may present it", ErrForbidden)
+	// ErrNotIssuer is returned when someone other than the issuer tries to revoke a credential.
+	ErrNotIssuer = fmt.Errorf("%w: only the issuer may revoke it", ErrForbidden)
+	// ErrNotParty is returned when the caller is neither issuer nor subject.
+	ErrNotParty = fmt.Errorf("%w: only the issuer or subject may view it", ErrForbidden)
+	// ErrNoConsent is returned when the issuer lacks read consent over a source event.
+	ErrNoConsent = fmt.Errorf("%w: issuer has no consent covering the source events", ErrForbidden)
+)
+
+var (
+	// ErrInvalidSourceEvent is returned when a source event is missing or belongs to another patient.
+	ErrInvalidSourceEvent = errors.New("invalid source event")
+	// ErrCredentialNotUsable is returned when presenting a revoked or expired credential.
+	ErrCredentialNotUsable = errors.New("credential is not usable")
+	// ErrUnknownClaim is returned when a presentation asks to disclose a claim the credential lacks.
+	ErrUnknownClaim = errors.New("unknown claim")
+	// ErrRevocationListFull is returned when the issuer's list has no free index left.
+	ErrRevocationListFull = errors.New("revocation list is full")
+	// ErrNotPending is returned when signing a credential the issuer already signed or revoked.
+	ErrNotPending = errors.New("credential is not awaiting the issuer's signature")
+	// ErrInvalidSignature is returned when the signature is not the issuer's over the credential's signing message.
+	ErrInvalidSignature = errors.New("signature does not match the credential")
+)
+
+// publicVerifier is the audit actor for verifications made through the unauthenticated endpoint.
+const publicVerifier = "public"
+
+// EventReader resolves the timeline events a credential is issued over.
+type EventReader interface {
+	GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error)
+}
+
+// ConsentChecker decides whether an issuer may read a patient's event. Issuing declares
+// no use, so study-bound research grants never support a credential.
+type ConsentChecker interface {
+	CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use protocolconsent.Use, eventID string, eventType protocoltimeline.EventType, codes types.Codes) (bool, error)
+}
+
+// IssueRequest describes a credential to issue.
+type IssueRequest struct {
+	Subject        string
+	ClaimType      vc.ClaimType
+	Claims         map[string]any
+	SourceEventIDs []string
+	ExpiresAt      *time.Time
+}
+
+// Service defines the business logic for verifiable credentials.
+type Service interface {
+	// IssueCredential creates a pending credential. The issuer then signs the message
+	// from GetSigningMessage and submits it to SignCredential to activate it.
+	IssueCredential(ctx context.Context, issuer string, req IssueRequest) (*Credential, error)
+	GetSigningMessage(ctx context.Context, issuer, credentialID string) (string, error)
+	SignCredential(ctx context.Context, issuer, credentialID, signature string) (*Credential, error)
+	GetCredential(ctx context.Context, actor, credentialID string) (*Credential, error)
+	GetHeldCredentials(ctx context.Context, subject string) ([]Credential, error)
+	GetIssuedCredentials(ctx context.Context, issuer string) ([]Credential, error)
+	RevokeCredential(ctx context.Context, issuer, credentialID string) error
+	CreatePresentation(ctx context.Context, holder, credentialID string, disclosedKeys []string) (*Presentation, error)
+	VerifyPresentation(ctx context.Context, verifier string, presentation *Presentation) (*VerificationResult, error)
+	GetRevocationList(ctx context.Context, id string) (*RevocationList, error)
+}
+
+type service struct {
+	repo         Repository
+	auditService audit.Service
+	events       EventReader
+	consent      ConsentChecker
+}
+
+// NewService creates a new credential service.
+func NewService(repo Repository, auditService audit.Service, events EventReader, consent ConsentChecker) Service {
+	return &service{
+		repo:         repo,
+		auditService: auditService,
+		events:       events,
+		consent:      consent,
+	}
+}
+
+// IssueCredential drafts a credential to req.Subject backed by their timeline events.
+// A patient may issue over their own events; anyone else needs read consent covering each one.
+// The credential is pending until the issuer signs it.
+func (s *service) IssueCredential(ctx context.Context, issuer string, req IssueRequest) (*Credential, error) {
+	issuerAddr, err := types.NewWalletAddress(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	subjectAddr, err := types.NewWalletAddress(req.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject: %w", err)
+	}
+
+	sourceIDs := make([]types.ID, len(req.SourceEventIDs))
+	for i, id := range req.SourceEventIDs {
+		sourceIDs[i] = types.ID(id)
+	}
+
+	builder := vc.NewCredentialBuilder().
+		WithIssuer(issuerAddr).
+		WithSubject(subjectAddr).
+		WithClaimType(req.ClaimType).
+		WithSourceEvents(sourceIDs...).
+		WithIssuedAt(time.Now().UTC())
+	for key, value := range req.Claims {
+		builder = builder.AddClaim(key, value, true)
+	}
+	if req.ExpiresAt != nil {
+		builder = builder.WithExpiresAt(req.ExpiresAt.UTC())
+	}
+	cred, err := builder.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorizeSources(ctx, issuerAddr.String(), subjectAddr.String(), req.SourceEventIDs); err != nil {
+		return nil, err
+	}
+
+	disclosures, digests, err := encodeDisclosures(cred.Claims)
+	if err != nil {
+		return nil, fmt.Errorf("encode disclosures: %w", err)
+	}
+
+	credential := &Credential{
+		ID:             cred.ID.String(),
+		Issuer:         cred.Issuer.String(),
+		Subject:        cred.Subject.String(),
+		ClaimType:      cred.ClaimType,
+		Claims:         common.JSONMap(cred.Claims),
+		Disclosures:    disclosures,
+		Digests:        digests,
+		SourceEventIDs: req.SourceEventIDs,
+		Status:         vc.StatusPending,
+		IssuedAt:       cred.IssuedAt,
+		ExpiresAt:      cred.ExpiresAt,
+		SchemaVersion:  cred.SchemaVersion,
+	}
+
+	if err := s.repo.CreateCredential(ctx, credential); err != nil {
+		return nil, fmt.Errorf("issue credential: %w", err)
+	}
+	return credential, nil
+}
+
+// GetSigningMessage returns the message the issuer signs to activate a pending
+// credential. It binds the revocation index the credential will take if signed
+// next; should another of the issuer's credentials be signed first, the signature
+// no longer matches and the message must be fetched again.
+func (s *service) GetSigningMessage(ctx context.Context, issuer, credentialID string) (string, error) {
+	credential, err := pendingForIssuer(ctx, s.repo, issuer, credentialID)
+	if err != nil {
+		return "", err
+	}
+	list, err := s.repo.GetRevocationListByIssuer(ctx, credential.Issuer)
+	if err != nil {
+		return "", err
+	}
+	if list != nil {
+		credential.RevocationIndex = list.NextIndex
+	}
+	return toProtocolCredential(credential).SigningMessage()
+}
+
+// SignCredential attaches the issuer's EIP-191 signature over the credential's
+// envelope and digests and activates it. The revocation index is reserved only
+// here, so drafts that are never signed do not use up the issuer's list. Issuance
+// is recorded here, once the credential can be presented.
+func (s *service) SignCredential(ctx context.Context, issuer, credentialID, signature string) (*Credential, error) {
+	var credential *Credential
+	err := s.repo.Transaction(ctx, func(repo Repository) error {
+		// The list lock is taken before the credential is read, so a concurrent
+		// signature of the same credential finds it already active.
+		list, err := allocateRevocationIndex(ctx, repo, strings.ToLower(issuer))
+		if err != nil {
+			return err
+		}
+		credential, err = pendingForIssuer(ctx, repo, issuer, credentialID)
+		if err != nil {
+			return err
+		}
+		credential.RevocationListID = list.ID
+		credential.RevocationIndex = list.NextIndex - 1
+
+		signed := toProtocolCredential(credential)
+		if err := signed.AttachProof(signature, time.Now()); err != nil {
+			if errors.Is(err, vc.ErrInvalidProof) {
+				return ErrInvalidSignature
+			}
+			return err
+		}
+
+		credential.Status = vc.StatusActive
+		credential.ProofType = signed.Proof.Type
+		credential.ProofValue = signed.Proof.ProofValue
+		credential.ProofCreated = &signed.Proof.Created
+		return repo.UpdateCredential(ctx, credential)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sign credential %s: %w", credentialID, err)
+	}
+
+	metadata := common.JSONMap{
+		"subject":        credential.Subject,
+		"claimType":      credential.ClaimType,
+		"sourceEventIds": credential.SourceEventIDs,
+	}
+	_ = s.auditService.Record(ctx, credential.Issuer, protocol.ActionVCIssue, protocol.ResourceVC, credential.ID, metadata)
+	return credential, nil
+}
+
+// pendingForIssuer loads a credential that issuer still has to sign.
+func pendingForIssuer(ctx context.Context, repo Repository, issuer, credentialID string) (*Credential, error) {
+	credential, err := repo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(issuer, credential.Issuer) {
+		return nil, ErrNotIssuer
+	}
+	if credential.Status != vc.StatusPending {
+		return nil, ErrNotPending
+	}
+	return credential, nil
+}
+
+// authorizeSources checks that every source event exists on the subject's timeline
+// and, for third-party issuers, that consent covers it.
+func (s *service) authorizeSources(ctx context.Context, issuer, subject string, eventIDs []string) error {
+	for _, eventID := range eventIDs {
+		event, err := s.events.GetEvent(ctx, eventID)
+		if err != nil || event == nil {
+			return fmt.Errorf("%w: %s not found", ErrInvalidSourceEvent, eventID)
+		}
+		if !strings.EqualFold(event.PatientID, subject) {
+			return fmt.Errorf("%w: %s is not on the subject's timeline", ErrInvalidSourceEvent, eventID)
+		}
+		if strings.EqualFold(issuer, subject) {
+			continue
+		}
+		allowed, err := s.consent.CheckEventPermission(ctx, event.PatientID, issuer, "read", protocolconsent.Use{}, event.ID, event.Type, types.Codes(event.Codes))
+		if err != nil {
+			return fmt.Errorf("check consent for %s: %w", eventID, err)
+		}
+		if !allowed {
+			return ErrNoConsent
+		}
+	}
+	return nil
+}
+
+// encodeDisclosures salts and encodes every claim, in key order, and returns the
+// encoded disclosures together with their digests.
+func encodeDisclosures(claims map[string]any) (common.JSONStrings, common.JSONStrings, error) {
+	keys := make([]string, 0, len(claims))
+	for key := range claims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	disclosures := make(common.JSONStrings, 0, len(keys))
+	digests := make(common.JSONStrings, 0, len(keys))
+	for _, key := range keys {
+		d := &vc.Disclosure{Key: key, Value: claims[key]}
+		encoded, err := vc.EncodeDisclosure(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		disclosures = append(disclosures, encoded)
+		digests = append(digests, vc.ComputeDisclosureDigest(encoded))
+	}
+	return disclosures, digests, nil
+}
+
+// allocateRevocationIndex reserves the next index on the issuer's revocation list,
+// creating the list on the issuer's first signed credential. The returned list's NextIndex is already advanced.
+func allocateRevocationIndex(ctx context.Context, repo Repository, issuer string) (*RevocationList, error) {
+	list, err := repo.LockRevocationListByIssuer(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if list == nil {
+		list = &RevocationList{
+			Issuer:        issuer,
+			Purpose:       "revocation",
+			Bitmap:        make([]byte, vc.DefaultRevocationListSize/8),
+			Size:          vc.DefaultRevocationListSize,
+			SchemaVersion: vc.SchemaVersionVC,
+		}
+	}
+	if list.NextIndex >= list.Size {
+		return nil, ErrRevocationListFull
+	}
+	list.NextIndex++
+	if err := repo.SaveRevocationList(ctx, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *service) GetCredential(ctx context.Context, actor, credentialID string) (*Credential, error) {
+	credential, err := s.repo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(actor, credential.Issuer) && !strings.EqualFold(actor, credential.Subject) {
+		return nil, ErrNotParty
+	}
+	return credential, nil
+}
+
+func (s *service) GetHeldCredentials(ctx context.Context, subject string) ([]Credential, error) {
+	return s.repo.GetCredentialsBySubject(ctx, strings.ToLower(subject))
+}
+
+func (s *service) GetIssuedCredentials(ctx context.Context, issuer string) ([]Credential, error) {
+	return s.repo.GetCredentialsByIssuer(ctx, strings.ToLower(issuer))
+}
+
+// RevokeCredential flips the credential's bit on the issuer's revocation list.
+func (s *service) RevokeCredential(ctx context.Context, issuer, credentialID string) error {
+	alreadyRevoked := false
+	err := s.repo.Transaction(ctx, func(repo Repository) error {
+		credential, err := repo.GetCredentialByID(ctx, credentialID)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(issuer, credential.Issuer) {
+			return ErrNotIssuer
+		}
+		if credential.Status == vc.StatusRevoked {
+			alreadyRevoked = true
+			return nil
+		}
+		if credential.RevocationListID == "" {
+			// Never signed, so it holds no slot on the list.
+			credential.Status = vc.StatusRevoked
+			return repo.UpdateCredential(ctx, credential)
+		}
+
+		list, err := repo.LockRevocationListByIssuer(ctx, credential.Issuer)
+		if err != nil {
+			return err
+		}
+		if list == nil || list.ID != credential.RevocationListID {
+			return fmt.Errorf("revocation list %s not found", credential.RevocationListID)
+		}
+
+		bitmap := toProtocolRevocationList(list)
+		if err := bitmap.Revoke(credential.RevocationIndex); err != nil {
+			return err
+		}
+		list.Bitmap = bitmap.Bitmap
+		if err := repo.SaveRevocationList(ctx, list); err != nil {
+			return err
+		}
+
+		credential.Status = vc.StatusRevoked
+		return repo.UpdateCredential(ctx, credential)
+	})
+	if err != nil {
+		return fmt.Errorf("revoke credential %s: %w", credentialID, err)
+	}
+	if alreadyRevoked {
+		return nil
+	}
+
+	_ = s.auditService.Record(ctx, strings.ToLower(issuer), protocol.ActionVCRevoke, protocol.ResourceVC, credentialID, nil)
+	return nil
+}
+
+// CreatePresentation builds a selective-disclosure presentation revealing only disclosedKeys.
+func (s *service) CreatePresentation(ctx context.Context, holder, credentialID string, disclosedKeys []string) (*Presentation, error) {
+	credential, err := s.repo.GetCredentialByID(ctx, credentialID)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(holder, credential.Subject) {
+		return nil, ErrNotHolder
+	}
+
+	revoked, err := s.isRevoked(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+	if revoked || credential.Status != vc.StatusActive || credential.IsExpired(time.Now()) {
+		return nil, ErrCredentialNotUsable
+	}
+
+	set := vc.NewDisclosureSet()
+	for _, encoded := range credential.Disclosures {
+		d, err := vc.DecodeDisclosure(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("decode stored disclosure: %w", err)
+		}
+		if err := set.Add(d); err != nil {
+			return nil, err
+		}
+	}
+	selected, err := set.SelectDisclosures(disclosedKeys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownClaim, err)
+	}
+
+	presentation := &Presentation{
+		CredentialID:     credential.ID,
+		Issuer:           credential.Issuer,
+		Subject:          credential.Subject,
+		ClaimType:        credential.ClaimType,
+		Digests:          credential.Digests,
+		Disclosures:      selected,
+		RevocationListID: credential.RevocationListID,
+		RevocationIndex:  credential.RevocationIndex,
+		IssuedAt:         credential.IssuedAt,
+		ExpiresAt:        credential.ExpiresAt,
+		SchemaVersion:    credential.SchemaVersion,
+		Proof:            credential.Proof(),
+	}
+
+	metadata := common.JSONMap{"disclosedKeys": disclosedKeys}
+	_ = s.auditService.Record(ctx, credential.Subject, protocol.ActionVCPresent, protocol.ResourceVC, credential.ID, metadata)
+	return presentation, nil
+}
+
+// VerifyPresentation checks a presentation against the issued credential: the issuer's
+// proof must verify over the presented envelope and digests, the envelope must match,
+// every disclosure must hash to one of the issued digests, and the credential must be
+// neither expired nor revoked. Verification failures are reported in the result; the
+// error is reserved for infrastructure problems.
+func (s *service) VerifyPresentation(ctx context.Context, verifier string, presentation *Presentation) (*VerificationResult, error) {
+	result := &VerificationResult{
+		CredentialID: presentation.CredentialID,
+		Claims:       make(map[string]any),
+		CheckedAt:    time.Now().UTC(),
+	}
+
+	credential, err := s.repo.GetCredentialByID(ctx, presentation.CredentialID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			result.Errors = append(result.Errors, "unknown credential")
+			return result, nil
+		}
+		return nil, err
+	}
+	result.Status = credential.Status
+
+	if !strings.EqualFold(presentation.Issuer, credential.Issuer) ||
+		!strings.EqualFold(presentation.Subject, credential.Subject) ||
+		presentation.ClaimType != credential.ClaimType {
+		result.Errors = append(result.Errors, "presentation does not match the issued credential")
+	}
+	if err := verifyProof(presentation, credential); err != nil {
+		result.Errors = append(result.Errors, "issuer proof: "+err.Error())
+	}
+
+	for _, encoded := range presentation.Disclosures {
+		if !slices.Contains(credential.Digests, vc.ComputeDisclosureDigest(encoded)) {
+			result.Errors = append(result.Errors, "disclosure does not match any issued digest")
+			continue
+		}
+		d, err := vc.DecodeDisclosure(encoded)
+		if err != nil {
+			result.Errors = append(result.Errors, "malformed disclosure")
+			continue
+		}
+		if _, dup := result.Claims[d.Key]; dup {
+			result.Errors = append(result.Errors, "claim disclosed more than once: "+d.Key)
+			continue
+		}
+		result.Claims[d.Key] = d.Value
+	}
+
+	if credential.IsExpired(result.CheckedAt) {
+		result.Status = vc.StatusExpired
+		result.Errors = append(result.Errors, "credential has expired")
+	}
+
+	revoked, err := s.isRevoked(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+	if revoked || credential.Status == vc.StatusRevoked {
+		result.Status = vc.StatusRevoked
+		result.Errors = append(result.Errors, "credential has been revoked")
+	}
+
+	result.Valid = len(result.Errors) == 0
+	if !result.Valid {
+		result.Claims = nil
+	}
+
+	actor := strings.ToLower(verifier)
+	if actor == "" {
+		actor = publicVerifier
+	}
+	metadata := common.JSONMap{
+		"valid":         result.Valid,
+		"disclosedKeys": len(presentation.Disclosures),
+	}
+	_ = s.auditService.Record(ctx, actor, protocol.ActionVCVerify, protocol.ResourceVC, credential.ID, metadata)
+	return result, nil
+}
+
+func (s *service) isRevoked(ctx context.Context, credential *Credential) (bool, error) {
+	if credential.RevocationListID == "" {
+		return false, nil
+	}
+	list, err := s.repo.GetRevocationListByID(ctx, credential.RevocationListID)
+	if err != nil {
+		return false, fmt.Errorf("load revocation list: %w", err)
+	}
+	return toProtocolRevocationList(list).IsRevoked(credential.RevocationIndex), nil
+}
+
+func (s *service) GetRevocationList(ctx context.Context, id string) (*RevocationList, error) {
+	return s.repo.GetRevocationListByID(ctx, id)
+}
+
+// toProtocolCredential returns the credential's envelope, digests and proof in the
+// protocol form the issuer signs.
+func toProtocolCredential(credential *Credential) *vc.Credential {
+	index := credential.RevocationIndex
+	return &vc.Credential{
+		ID:              types.ID(credential.ID),
+		Issuer:          types.WalletAddress(credential.Issuer),
+		Subject:         types.WalletAddress(credential.Subject),
+		ClaimType:       credential.ClaimType,
+		Digests:         credential.Digests,
+		IssuedAt:        credential.IssuedAt,
+		ExpiresAt:       credential.ExpiresAt,
+		Status:          credential.Status,
+		RevocationIndex: &index,
+		SchemaVersion:   credential.SchemaVersion,
+		Proof:           credential.Proof(),
+	}
+}
+
+// verifyProof checks the presentation as a verifier holding only the issuer's address
+// would: the proof must be the stored issuer's signature over what was presented, and
+// each disclosure must match a signed digest.
+func verifyProof(presentation *Presentation, credential *Credential) error {
+	index := presentation.RevocationIndex
+	signed := &vc.Credential{
+		ID:              types.ID(presentation.CredentialID),
+		Issuer:          types.WalletAddress(strings.ToLower(presentation.Issuer)),
+		Subject:         types.WalletAddress(strings.ToLower(presentation.Subject)),
+		ClaimType:       presentation.ClaimType,
+		Claims:          make(map[string]any, len(presentation.Disclosures)),
+		Disclosures:     make([]vc.Disclosure, 0, len(presentation.Disclosures)),
+		Digests:         presentation.Digests,
+		IssuedAt:        presentation.IssuedAt,
+		ExpiresAt:       presentation.ExpiresAt,
+		RevocationIndex: &index,
+		SchemaVersion:   presentation.SchemaVersion,
+		Proof:           presentation.Proof,
+	}
+	for _, encoded := range presentation.Disclosures {
+		d, err := vc.DecodeDisclosure(encoded)
+		if err != nil {
+			return err
+		}
+		signed.Claims[d.Key] = d.Value
+		signed.Disclosures = append(signed.Disclosures, *d)
+	}
+	err := vc.VerifyPresentation(signed, types.WalletAddress(credential.Issuer))
+	if errors.Is(err, vc.ErrCredentialExpired) {
+		// Expiry is judged against the stored credential below.
+		return nil
+	}
+	return err
+}
+
+// toProtocolRevocationList wraps a persisted list in the protocol bitmap type.
+func toProtocolRevocationList(list *RevocationList) *vc.RevocationList {
+	bitmap := make([]byte, len(list.Bitmap))
+	copy(bitmap, list.Bitmap)
+	return &vc.RevocationList{
+		ID:            types.ID(list.ID),
+		IssuerID:      types.WalletAddress(list.Issuer),
+		Purpose:       list.Purpose,
+		Bitmap:        bitmap,
+		Size:          list.Size,
+		LastUpdated:   list.UpdatedAt,
+		SchemaVersion: list.SchemaVersion,
+	}
+}
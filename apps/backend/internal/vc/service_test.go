@@ -0,0 +1,452 @@
+package vc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/apps/backend/internal/audit/audittest"
+	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocolconsent "github.com/itspablomontes/fleming/pkg/protocol/consent"
+	protocolcrypto "github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	protocoltimeline "github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+	"github.com/itspablomontes/fleming/pkg/protocol/vc"
+	"gorm.io/gorm"
+)
+
+type mockRepo struct {
+	nextID      int
+	credentials map[string]Credential
+	lists       map[string]RevocationList
+}
+
+func newMockRepo() *mockRepo {
+	return &mockRepo{
+		credentials: make(map[string]Credential),
+		lists:       make(map[string]RevocationList),
+	}
+}
+
+func (m *mockRepo) CreateCredential(ctx context.Context, credential *Credential) error {
+	m.credentials[credential.ID] = *credential
+	return nil
+}
+
+func (m *mockRepo) GetCredentialByID(ctx context.Context, id string) (*Credential, error) {
+	credential, ok := m.credentials[id]
+	if !ok {
+		return nil, fmt.Errorf("get credential %s: %w", id, gorm.ErrRecordNotFound)
+	}
+	return &credential, nil
+}
+
+func (m *mockRepo) GetCredentialsBySubject(ctx context.Context, subject string) ([]Credential, error) {
+	var result []Credential
+	for _, credential := range m.credentials {
+		if credential.Subject == subject {
+			result = append(result, credential)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) GetCredentialsByIssuer(ctx context.Context, issuer string) ([]Credential, error) {
+	var result []Credential
+	for _, credential := range m.credentials {
+		if credential.Issuer == issuer {
+			result = append(result, credential)
+		}
+	}
+	return result, nil
+}
+
+func (m *mockRepo) UpdateCredential(ctx context.Context, credential *Credential) error {
+	m.credentials[credential.ID] = *credential
+	return nil
+}
+
+func (m *mockRepo) GetRevocationListByID(ctx context.Context, id string) (*RevocationList, error) {
+	list, ok := m.lists[id]
+	if !ok {
+		return nil, fmt.Errorf("get revocation list %s: %w", id, gorm.ErrRecordNotFound)
+	}
+	list.Bitmap = append([]byte(nil), list.Bitmap...)
+	return &list, nil
+}
+
+func (m *mockRepo) GetRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error) {
+	return m.LockRevocationListByIssuer(ctx, issuer)
+}
+
+func (m *mockRepo) LockRevocationListByIssuer(ctx context.Context, issuer string) (*RevocationList, error) {
+	for _, list := range m.lists {
+		if list.Issuer == issuer {
+			list.Bitmap = append([]byte(nil), list.Bitmap...)
+			return &list, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockRepo) SaveRevocationList(ctx context.Context, list *RevocationList) error {
+	if list.ID == "" {
+		m.nextID++
+		list.ID = fmt.Sprintf("list-%d", m.nextID)
+	}
+	m.lists[list.ID] = *list
+	return nil
+}
+
+// Transaction restores the lists and credentials when fn fails, as a rollback would.
+func (m *mockRepo) Transaction(ctx context.Context, fn func(repo Repository) error) error {
+	credentials, lists := maps.Clone(m.credentials), maps.Clone(m.lists)
+	if err := fn(m); err != nil {
+		m.credentials, m.lists = credentials, lists
+		return err
+	}
+	return nil
+}
+
+type mockEvents map[string]timeline.TimelineEvent
+
+func (m mockEvents) GetEvent(ctx context.Context, id string) (*timeline.TimelineEvent, error) {
+	event, ok := m[id]
+	if !ok {
+		return nil, fmt.Errorf("event %s not found", id)
+	}
+	return &event, nil
+}
+
+// mockConsent grants read access to the listed event IDs only.
+type mockConsent map[string]bool
+
+func (m mockConsent) CheckEventPermission(ctx context.Context, grantor, grantee string, permission string, use protocolconsent.Use, eventID string, eventType protocoltimeline.EventType, codes types.Codes) (bool, error) {
+	return m[eventID], nil
+}
+
+const (
+	testPatient  = "0xe05fcc23807536bee418f142d19fa0d21bb0cff7"
+	testProvider = "0x0376aac07ad725e01357b1725b5cec61ae10473c"
+)
+
+var testKeys = map[string]*ecdsa.PrivateKey{
+	testPatient:  mustKey("a11ce"),
+	testProvider: mustKey("b0b"),
+}
+
+func mustKey(hex string) *ecdsa.PrivateKey {
+	key, err := crypto.HexToECDSA(fmt.Sprintf("%064s", hex))
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// signAs returns signer's signature over the credential's signing message as issuer
+// fetches it, or "" when the service refuses to build it.
+func signAs(svc Service, issuer, signer, credentialID string) string {
+	message, err := svc.GetSigningMessage(context.Background(), issuer, credentialID)
+	if err != nil {
+		return ""
+	}
+	signature, err := protocolcrypto.SignMessage(message, testKeys[signer])
+	if err != nil {
+		panic(err)
+	}
+	return signature
+}
+
+// issueSigned issues a credential and signs it with the issuer's key.
+func issueSigned(t *testing.T, svc Service, issuer string, req IssueRequest) *Credential {
+	t.Helper()
+	credential, err := svc.IssueCredential(context.Background(), issuer, req)
+	if err != nil {
+		t.Fatalf("IssueCredential() error = %v", err)
+	}
+	signed, err := svc.SignCredential(context.Background(), issuer, credential.ID, signAs(svc, issuer, issuer, credential.ID))
+	if err != nil {
+		t.Fatalf("SignCredential() error = %v", err)
+	}
+	return signed
+}
+
+func newTestService(consent mockConsent) (Service, *mockRepo, *audittest.Service) {
+	repo := newMockRepo()
+	auditSvc := &audittest.Service{}
+	events := mockEvents{
+		"evt-lab":  {ID: "evt-lab", PatientID: testPatient, Type: protocoltimeline.EventLabResult},
+		"evt-note": {ID: "evt-note", PatientID: testPatient, Type: protocoltimeline.EventConsultation},
+		"evt-else": {ID: "evt-else", PatientID: testProvider, Type: protocoltimeline.EventLabResult},
+	}
+	return NewService(repo, auditSvc, events, consent), repo, auditSvc
+}
+
+func bloodworkRequest(sourceEventIDs ...string) IssueRequest {
+	return IssueRequest{
+		Subject:   testPatient,
+		ClaimType: vc.ClaimBloodworkRange,
+		Claims: map[string]any{
+			"marker":     "LOINC:2093-3",
+			"allInRange": true,
+		},
+		SourceEventIDs: sourceEventIDs,
+	}
+}
+
+func TestService_IssueCredential_AuthorizesSourceEvents(t *testing.T) {
+	svc, _, _ := newTestService(mockConsent{"evt-lab": true})
+	ctx := context.Background()
+
+	if _, err := svc.IssueCredential(ctx, testPatient, bloodworkRequest("evt-lab", "evt-note")); err != nil {
+		t.Fatalf("self-issued IssueCredential() error = %v", err)
+	}
+	if _, err := svc.IssueCredential(ctx, testProvider, bloodworkRequest("evt-lab")); err != nil {
+		t.Fatalf("consented IssueCredential() error = %v", err)
+	}
+	if _, err := svc.IssueCredential(ctx, testProvider, bloodworkRequest("evt-lab", "evt-note")); !errors.Is(err, ErrNoConsent) {
+		t.Fatalf("IssueCredential() without consent error = %v, want %v", err, ErrNoConsent)
+	}
+	if _, err := svc.IssueCredential(ctx, testPatient, bloodworkRequest("evt-else")); !errors.Is(err, ErrInvalidSourceEvent) {
+		t.Fatalf("IssueCredential() over foreign event error = %v, want %v", err, ErrInvalidSourceEvent)
+	}
+}
+
+func TestService_SignCredential_AllocatesRevocationIndices(t *testing.T) {
+	svc, repo, _ := newTestService(nil)
+	ctx := context.Background()
+
+	for range 3 {
+		draft, err := svc.IssueCredential(ctx, testPatient, bloodworkRequest("evt-lab"))
+		if err != nil {
+			t.Fatalf("IssueCredential() error = %v", err)
+		}
+		if draft.RevocationListID != "" {
+			t.Fatalf("pending credential holds revocation list %s, want none until signed", draft.RevocationListID)
+		}
+	}
+	if len(repo.lists) != 0 {
+		t.Fatalf("unsigned drafts created %d revocation lists, want none", len(repo.lists))
+	}
+
+	first := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+	second := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+
+	if first.RevocationListID != second.RevocationListID || len(repo.lists) != 1 {
+		t.Fatalf("credentials from one issuer should share a revocation list")
+	}
+	if first.RevocationIndex == second.RevocationIndex {
+		t.Fatalf("revocation indices should be unique, both = %d", first.RevocationIndex)
+	}
+	if list := repo.lists[first.RevocationListID]; list.NextIndex != 2 {
+		t.Fatalf("revocation list NextIndex = %d, want 2 for the two signed credentials", list.NextIndex)
+	}
+	if len(first.Digests) != 2 || len(first.Disclosures) != 2 {
+		t.Fatalf("expected one disclosure and digest per claim, got %d/%d", len(first.Disclosures), len(first.Digests))
+	}
+}
+
+func TestService_SignCredential_RejectsStaleSigningMessage(t *testing.T) {
+	svc, repo, _ := newTestService(nil)
+	ctx := context.Background()
+
+	stale, err := svc.IssueCredential(ctx, testPatient, bloodworkRequest("evt-lab"))
+	if err != nil {
+		t.Fatalf("IssueCredential() error = %v", err)
+	}
+	staleSignature := signAs(svc, testPatient, testPatient, stale.ID)
+	issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+
+	if _, err := svc.SignCredential(ctx, testPatient, stale.ID, staleSignature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("SignCredential() over a taken index error = %v, want %v", err, ErrInvalidSignature)
+	}
+	for _, list := range repo.lists {
+		if list.NextIndex != 1 {
+			t.Fatalf("revocation list NextIndex = %d after a rejected signature, want 1", list.NextIndex)
+		}
+	}
+
+	signed, err := svc.SignCredential(ctx, testPatient, stale.ID, signAs(svc, testPatient, testPatient, stale.ID))
+	if err != nil {
+		t.Fatalf("SignCredential() with a fresh message error = %v", err)
+	}
+	if signed.RevocationIndex != 1 {
+		t.Fatalf("RevocationIndex = %d, want 1", signed.RevocationIndex)
+	}
+}
+
+func TestService_PresentAndVerify_SelectiveDisclosure(t *testing.T) {
+	svc, _, auditSvc := newTestService(nil)
+	ctx := context.Background()
+
+	credential := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+
+	if _, err := svc.CreatePresentation(ctx, testProvider, credential.ID, []string{"allInRange"}); !errors.Is(err, ErrNotHolder) {
+		t.Fatalf("CreatePresentation() by non-holder error = %v, want %v", err, ErrNotHolder)
+	}
+	if _, err := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"age"}); !errors.Is(err, ErrUnknownClaim) {
+		t.Fatalf("CreatePresentation() with unknown claim error = %v, want %v", err, ErrUnknownClaim)
+	}
+
+	presentation, err := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"allInRange"})
+	if err != nil {
+		t.Fatalf("CreatePresentation() error = %v", err)
+	}
+
+	result, err := svc.VerifyPresentation(ctx, "", presentation)
+	if err != nil {
+		t.Fatalf("VerifyPresentation() error = %v", err)
+	}
+	if !result.Valid {
+		t.Fatalf("VerifyPresentation() errors = %v, want valid", result.Errors)
+	}
+	if len(result.Claims) != 1 || result.Claims["allInRange"] != true {
+		t.Fatalf("VerifyPresentation() claims = %v, want only allInRange", result.Claims)
+	}
+	if _, leaked := result.Claims["marker"]; leaked {
+		t.Fatal("undisclosed claim leaked into verification result")
+	}
+
+	want := []protocol.Action{protocol.ActionVCIssue, protocol.ActionVCPresent, protocol.ActionVCVerify}
+	if fmt.Sprint(auditSvc.Actions()) != fmt.Sprint(want) {
+		t.Fatalf("audit actions = %v, want %v", auditSvc.Actions(), want)
+	}
+}
+
+func TestService_VerifyPresentation_RejectsTamperedDisclosure(t *testing.T) {
+	svc, _, _ := newTestService(nil)
+	ctx := context.Background()
+
+	credential := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+	presentation, _ := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"allInRange"})
+
+	forged := &vc.Disclosure{Salt: "forged-salt", Key: "allInRange", Value: false}
+	encoded, err := vc.EncodeDisclosure(forged)
+	if err != nil {
+		t.Fatalf("EncodeDisclosure() error = %v", err)
+	}
+	presentation.Disclosures = []string{encoded}
+
+	result, err := svc.VerifyPresentation(ctx, "", presentation)
+	if err != nil {
+		t.Fatalf("VerifyPresentation() error = %v", err)
+	}
+	if result.Valid {
+		t.Fatal("VerifyPresentation() accepted a disclosure that was never issued")
+	}
+}
+
+func TestService_VerifyPresentation_RejectsRevokedAndExpired(t *testing.T) {
+	svc, repo, _ := newTestService(nil)
+	ctx := context.Background()
+
+	credential := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+	presentation, _ := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"marker"})
+
+	if err := svc.RevokeCredential(ctx, testPatient, credential.ID); err != nil {
+		t.Fatalf("RevokeCredential() error = %v", err)
+	}
+	result, _ := svc.VerifyPresentation(ctx, "", presentation)
+	if result.Valid || result.Status != vc.StatusRevoked {
+		t.Fatalf("revoked credential verified as valid=%v status=%s", result.Valid, result.Status)
+	}
+	if _, err := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"marker"}); !errors.Is(err, ErrCredentialNotUsable) {
+		t.Fatalf("CreatePresentation() on revoked credential error = %v, want %v", err, ErrCredentialNotUsable)
+	}
+
+	expiring := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+	expiredPresentation, _ := svc.CreatePresentation(ctx, testPatient, expiring.ID, []string{"marker"})
+	stored := repo.credentials[expiring.ID]
+	past := time.Now().Add(-time.Hour)
+	stored.ExpiresAt = &past
+	repo.credentials[expiring.ID] = stored
+
+	result, _ = svc.VerifyPresentation(ctx, "", expiredPresentation)
+	if result.Valid || result.Status != vc.StatusExpired {
+		t.Fatalf("expired credential verified as valid=%v status=%s", result.Valid, result.Status)
+	}
+}
+
+func TestService_RevokeCredential_RequiresIssuer(t *testing.T) {
+	svc, _, _ := newTestService(mockConsent{"evt-lab": true})
+	ctx := context.Background()
+
+	credential, _ := svc.IssueCredential(ctx, testProvider, bloodworkRequest("evt-lab"))
+	if err := svc.RevokeCredential(ctx, testPatient, credential.ID); !errors.Is(err, ErrNotIssuer) {
+		t.Fatalf("RevokeCredential() by subject error = %v, want %v", err, ErrNotIssuer)
+	}
+}
+
+func TestService_SignCredential_RequiresIssuerSignature(t *testing.T) {
+	svc, _, auditSvc := newTestService(mockConsent{"evt-lab": true})
+	ctx := context.Background()
+
+	credential, err := svc.IssueCredential(ctx, testProvider, bloodworkRequest("evt-lab"))
+	if err != nil {
+		t.Fatalf("IssueCredential() error = %v", err)
+	}
+	if credential.Status != vc.StatusPending || credential.Proof() != nil {
+		t.Fatalf("IssueCredential() status = %s, want an unsigned pending credential", credential.Status)
+	}
+	if _, err := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"marker"}); !errors.Is(err, ErrCredentialNotUsable) {
+		t.Fatalf("CreatePresentation() of an unsigned credential error = %v, want %v", err, ErrCredentialNotUsable)
+	}
+	if _, err := svc.GetSigningMessage(ctx, testPatient, credential.ID); !errors.Is(err, ErrNotIssuer) {
+		t.Fatalf("GetSigningMessage() by subject error = %v, want %v", err, ErrNotIssuer)
+	}
+	if _, err := svc.SignCredential(ctx, testProvider, credential.ID, signAs(svc, testProvider, testPatient, credential.ID)); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("SignCredential() with the subject's signature error = %v, want %v", err, ErrInvalidSignature)
+	}
+	if len(auditSvc.Actions()) != 0 {
+		t.Fatalf("audit actions before signing = %v, want none", auditSvc.Actions())
+	}
+
+	signature := signAs(svc, testProvider, testProvider, credential.ID)
+	signed, err := svc.SignCredential(ctx, testProvider, credential.ID, signature)
+	if err != nil {
+		t.Fatalf("SignCredential() error = %v", err)
+	}
+	if signed.Status != vc.StatusActive || signed.Proof() == nil || signed.Proof().ProofValue != signature {
+		t.Fatalf("SignCredential() = %+v, want an active credential carrying the proof", signed)
+	}
+	if _, err := svc.SignCredential(ctx, testProvider, credential.ID, signature); !errors.Is(err, ErrNotPending) {
+		t.Fatalf("SignCredential() twice error = %v, want %v", err, ErrNotPending)
+	}
+}
+
+func TestService_VerifyPresentation_ChecksIssuerProof(t *testing.T) {
+	svc, _, _ := newTestService(nil)
+	ctx := context.Background()
+
+	credential := issueSigned(t, svc, testPatient, bloodworkRequest("evt-lab"))
+	presentation, err := svc.CreatePresentation(ctx, testPatient, credential.ID, []string{"marker"})
+	if err != nil {
+		t.Fatalf("CreatePresentation() error = %v", err)
+	}
+	if presentation.Proof == nil {
+		t.Fatal("CreatePresentation() left out the issuer's proof")
+	}
+
+	// The offline check a verifier makes without Fleming.
+	if err := verifyProof(presentation, credential); err != nil {
+		t.Fatalf("verifyProof() error = %v", err)
+	}
+
+	unsigned := *presentation
+	unsigned.Proof = nil
+	if result, _ := svc.VerifyPresentation(ctx, "", &unsigned); result.Valid {
+		t.Error("VerifyPresentation() accepted a presentation without the issuer's proof")
+	}
+
+	backdated := *presentation
+	backdated.IssuedAt = presentation.IssuedAt.Add(-24 * time.Hour)
+	if result, _ := svc.VerifyPresentation(ctx, "", &backdated); result.Valid {
+		t.Error("VerifyPresentation() accepted an envelope the issuer did not sign")
+	}
+}
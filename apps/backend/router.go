@@ -7,16 +7,27 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/itspablomontes/fleming/apps/backend/internal/anchor"
+	"github.com/itspablomontes/fleming/apps/backend/internal/anomaly"
+	"github.com/itspablomontes/fleming/apps/backend/internal/attestation"
 	"github.com/itspablomontes/fleming/apps/backend/internal/audit"
 	"github.com/itspablomontes/fleming/apps/backend/internal/auth"
 	"github.com/itspablomontes/fleming/apps/backend/internal/config"
 	"github.com/itspablomontes/fleming/apps/backend/internal/consent"
+	"github.com/itspablomontes/fleming/apps/backend/internal/delegation"
+	"github.com/itspablomontes/fleming/apps/backend/internal/emergency"
 	"github.com/itspablomontes/fleming/apps/backend/internal/middleware"
 	"github.com/itspablomontes/fleming/apps/backend/internal/storage"
+	"github.com/itspablomontes/fleming/apps/backend/internal/stream"
 	"github.com/itspablomontes/fleming/apps/backend/internal/timeline"
+	"github.com/itspablomontes/fleming/apps/backend/internal/vc"
+	protocol "github.com/itspablomontes/fleming/pkg/protocol/audit"
+	protocoldelegation "github.com/itspablomontes/fleming/pkg/protocol/delegation"
 	"gorm.io/gorm"
 )
 
@@ -29,8 +40,12 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 		if origin != "" {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{
+				"Content-Type", "Authorization", "Last-Event-ID", middleware.ActAsHeader,
+				middleware.ConsentPurposeHeader, middleware.ConsentStudyHeader, middleware.ConsentSecondaryUseHeader,
+			}, ", "))
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			c.Writer.Header().Set("Access-Control-Expose-Headers", middleware.ConsentGrantsHeader+", "+middleware.EmergencySessionHeader)
 		}
 
 		if c.Request.Method == "OPTIONS" {
@@ -58,6 +73,11 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 	auditRepo := audit.NewRepository(db)
 	consentRepo := consent.NewRepository(db)
 	timelineRepo := timeline.NewRepository(db)
+	vcRepo := vc.NewRepository(db)
+	attestationRepo := attestation.NewRepository(db)
+	alertRepo := anomaly.NewRepository(db)
+	delegationRepo := delegation.NewRepository(db)
+	emergencyRepo := emergency.NewRepository(db)
 
 	storageEndpointRaw := firstNonEmpty(os.Getenv("STORAGE_ENDPOINT"), os.Getenv("S3_ENDPOINT"))
 	storageAccessKey := firstNonEmpty(os.Getenv("STORAGE_ACCESS_KEY"), os.Getenv("S3_ACCESS_KEY"))
@@ -122,16 +142,127 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 	}
 
 	auditService := audit.NewService(auditRepo)
-	consentService := consent.NewService(consentRepo, auditService)
-	authService := auth.NewService(authRepo, jwtSecret, auditService)
 	timelineService := timeline.NewService(timelineRepo, auditService, storageService, storageBucket)
+	consentService := consent.NewService(consentRepo, auditService, timelineService)
+	authService := auth.NewService(authRepo, jwtSecret, auditService)
+	vcService := vc.NewService(vcRepo, auditService, timelineService, consentService)
+	attestationService := attestation.NewService(attestationRepo, auditService, timelineService)
+	alertService := anomaly.NewService(alertRepo, auditService, consentService)
+	delegationService := delegation.NewService(delegationRepo, auditService)
+
+	emergencyOpts := emergency.DefaultOptions()
+	if emergencyOpts.Duration, err = parseOptionalDuration(os.Getenv("EMERGENCY_ACCESS_DURATION"), emergencyOpts.Duration); err != nil {
+		slog.Error("Invalid EMERGENCY_ACCESS_DURATION value", "error", err)
+		os.Exit(1)
+	}
+	emergencyOpts.Providers = splitList(os.Getenv("EMERGENCY_PROVIDER_ADDRESSES"))
+	if len(emergencyOpts.Providers) == 0 {
+		slog.Warn("EMERGENCY_PROVIDER_ADDRESSES not set; break-glass emergency access is disabled")
+	}
+	if raw := strings.TrimSpace(os.Getenv("EMERGENCY_SESSION_LIMIT")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			slog.Error("Invalid EMERGENCY_SESSION_LIMIT value", "value", raw)
+			os.Exit(1)
+		}
+		emergencyOpts.SessionLimit = limit
+	}
+	emergencyService := emergency.NewService(emergencyRepo, auditService, emergencyOpts)
 
 	authService.StartCleanup(context.Background())
 
+	sweepOpts := consent.DefaultSweepOptions()
+	if raw := strings.TrimSpace(os.Getenv("CONSENT_EXPIRY_NOTICE_DAYS")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			slog.Error("Invalid CONSENT_EXPIRY_NOTICE_DAYS value", "value", raw)
+			os.Exit(1)
+		}
+		sweepOpts.NoticeWindow = time.Duration(days) * 24 * time.Hour
+	}
+	sweepInterval, err := parseOptionalDuration(os.Getenv("CONSENT_SWEEP_INTERVAL"), 5*time.Minute)
+	if err != nil {
+		slog.Error("Invalid CONSENT_SWEEP_INTERVAL value", "error", err)
+		os.Exit(1)
+	}
+	consent.NewSweeper(consentRepo, auditService, timelineService, sweepOpts).Start(context.Background(), sweepInterval)
+	slog.Info("Consent expiry sweep scheduled", "interval", sweepInterval, "noticeWindow", sweepOpts.NoticeWindow)
+
+	batchInterval, err := parseOptionalDuration(os.Getenv("AUDIT_BATCH_INTERVAL"), time.Hour)
+	if err != nil {
+		slog.Error("Invalid AUDIT_BATCH_INTERVAL value", "error", err)
+		os.Exit(1)
+	}
+	batchSettleDelay, err := parseOptionalDuration(os.Getenv("AUDIT_BATCH_SETTLE_DELAY"), time.Minute)
+	if err != nil {
+		slog.Error("Invalid AUDIT_BATCH_SETTLE_DELAY value", "error", err)
+		os.Exit(1)
+	}
+	batcher := audit.NewBatcher(auditRepo, audit.BatchOptions{Interval: batchInterval, SettleDelay: batchSettleDelay})
+	batcher.Start(context.Background(), min(batchInterval, time.Minute))
+	slog.Info("Audit batching scheduled", "interval", batchInterval, "settleDelay", batchSettleDelay)
+
+	systemAddresses := splitList(os.Getenv("AUDIT_SYSTEM_ADDRESSES"))
+	if len(systemAddresses) == 0 {
+		slog.Warn("AUDIT_SYSTEM_ADDRESSES not set; manual audit batching and coverage reports are disabled")
+	}
+
+	anchorService, anchorNetwork := newAnchorService(env, auditRepo)
+	if anchorService != nil {
+		anchorInterval, err := parseOptionalDuration(os.Getenv("ANCHOR_INTERVAL"), time.Minute)
+		if err != nil {
+			slog.Error("Invalid ANCHOR_INTERVAL value", "error", err)
+			os.Exit(1)
+		}
+		anchorService.Start(context.Background(), anchorInterval)
+	}
+
+	exporter := audit.NewExporter(auditRepo, anchorNetwork)
+
+	detectorOpts := anomaly.DefaultDetectorOptions()
+	if raw := strings.TrimSpace(os.Getenv("ANOMALY_BULK_DOWNLOAD_COUNT")); raw != "" {
+		count, err := strconv.Atoi(raw)
+		if err != nil || count <= 0 {
+			slog.Error("Invalid ANOMALY_BULK_DOWNLOAD_COUNT value", "value", raw)
+			os.Exit(1)
+		}
+		detectorOpts.BulkDownloadCount = count
+	}
+	if detectorOpts.BulkDownloadWindow, err = parseOptionalDuration(os.Getenv("ANOMALY_BULK_DOWNLOAD_WINDOW"), detectorOpts.BulkDownloadWindow); err != nil {
+		slog.Error("Invalid ANOMALY_BULK_DOWNLOAD_WINDOW value", "error", err)
+		os.Exit(1)
+	}
+	if detectorOpts.ExpiryWindow, err = parseOptionalDuration(os.Getenv("ANOMALY_EXPIRY_WINDOW"), detectorOpts.ExpiryWindow); err != nil {
+		slog.Error("Invalid ANOMALY_EXPIRY_WINDOW value", "error", err)
+		os.Exit(1)
+	}
+	scanInterval, err := parseOptionalDuration(os.Getenv("ANOMALY_SCAN_INTERVAL"), time.Minute)
+	if err != nil {
+		slog.Error("Invalid ANOMALY_SCAN_INTERVAL value", "error", err)
+		os.Exit(1)
+	}
+	detector := anomaly.NewDetector(alertRepo, auditService, consentService, detectorOpts)
+	detector.Start(context.Background(), scanInterval)
+	slog.Info("Access anomaly detection scheduled", "interval", scanInterval, "bulkDownloads", detectorOpts.BulkDownloadCount, "bulkWindow", detectorOpts.BulkDownloadWindow)
+
+	streamPollInterval, err := parseOptionalDuration(os.Getenv("STREAM_POLL_INTERVAL"), 30*time.Second)
+	if err != nil {
+		slog.Error("Invalid STREAM_POLL_INTERVAL value", "error", err)
+		os.Exit(1)
+	}
+	streamHub := stream.NewHub(auditService, consentService, timelineService, stream.NewPostgresListener(os.Getenv("DATABASE_URL")))
+	streamHub.Start(context.Background(), streamPollInterval)
+
 	authHandler := auth.NewHandler(authService)
-	auditHandler := audit.NewHandler(auditService)
+	auditHandler := audit.NewHandler(auditService, anchorService, batcher, exporter, systemAddresses)
 	consentHandler := consent.NewHandler(consentService)
 	timelineHandler := timeline.NewHandler(timelineService)
+	vcHandler := vc.NewHandler(vcService)
+	attestationHandler := attestation.NewHandler(attestationService)
+	alertHandler := anomaly.NewHandler(alertService)
+	delegationHandler := delegation.NewHandler(delegationService)
+	emergencyHandler := emergency.NewHandler(emergencyService)
+	streamHandler := stream.NewHandler(streamHub)
 
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -145,20 +276,107 @@ func NewRouter(db *gorm.DB) *gin.Engine {
 
 	r.GET("/api/auth/me", middleware.AuthMiddleware(authService), authHandler.HandleMe)
 
+	// Verification is public so third parties can check credential presentations
+	vcHandler.RegisterPublicRoutes(r.Group("/api/vc"))
+	// and consent receipts without a Fleming account
+	consentHandler.RegisterPublicRoutes(r.Group("/api/consent"))
+
 	apiGroup := r.Group("/api")
 	apiGroup.Use(middleware.AuthMiddleware(authService))
 
-	auditHandler.RegisterRoutes(apiGroup)
-	consentHandler.RegisterRoutes(apiGroup)
+	vcHandler.RegisterRoutes(apiGroup)
+	attestationHandler.RegisterRoutes(apiGroup)
+	alertHandler.RegisterRoutes(apiGroup)
+	streamHandler.RegisterRoutes(apiGroup)
+	delegationHandler.RegisterRoutes(apiGroup)
+	emergencyHandler.RegisterRoutes(apiGroup)
+
+	// Guardians and caregivers may act as a patient (X-Act-As) on audit, consent and
+	// timeline routes, within the permissions the patient delegated
+	auditGroup := apiGroup.Group("")
+	auditGroup.Use(middleware.ActAsMiddleware(delegationService, auditService, protocoldelegation.PermAuditRead, ""))
+	auditHandler.RegisterRoutes(auditGroup)
+
+	consentGroup := apiGroup.Group("")
+	consentGroup.Use(middleware.ActAsMiddleware(delegationService, auditService, protocoldelegation.PermConsentManage, protocoldelegation.PermConsentManage))
+	consentHandler.RegisterRoutes(consentGroup)
 
 	// Timeline routes are protected by both Auth and Consent middleware
 	timelineGroup := apiGroup.Group("")
-	timelineGroup.Use(middleware.ConsentMiddleware(consentService))
+	timelineGroup.Use(
+		middleware.ActAsMiddleware(delegationService, auditService, protocoldelegation.PermTimelineRead, protocoldelegation.PermTimelineWrite),
+		middleware.ConsentMiddleware(consentService, timelineService, emergencyService, auditService),
+	)
 	timelineHandler.RegisterRoutes(timelineGroup)
 
 	return r
 }
 
+// newAnchorService connects to the FlemingAnchor contract from ANCHOR_* variables. It
+// also returns the contract's network so export bundles can name where roots live.
+// Anchoring is required in production/staging; in development it is skipped when unset.
+func newAnchorService(env string, repo audit.Repository) (audit.AnchorService, *protocol.AnchorNetwork) {
+	rpcURL := strings.TrimSpace(os.Getenv("ANCHOR_RPC_URL"))
+	contractAddress := strings.TrimSpace(os.Getenv("ANCHOR_CONTRACT_ADDRESS"))
+	privateKey := strings.TrimSpace(os.Getenv("ANCHOR_PRIVATE_KEY"))
+
+	if rpcURL == "" || contractAddress == "" || privateKey == "" {
+		if config.IsProductionLike(env) {
+			slog.Error("ANCHOR_RPC_URL, ANCHOR_CONTRACT_ADDRESS and ANCHOR_PRIVATE_KEY are required in production/staging")
+			os.Exit(1)
+		}
+		slog.Warn("ANCHOR_* not set; audit roots will not be anchored on-chain", "env", env)
+		return nil, nil
+	}
+
+	opts := audit.DefaultAnchorOptions()
+	if raw := strings.TrimSpace(os.Getenv("ANCHOR_CONFIRMATIONS")); raw != "" {
+		confirmations, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil || confirmations == 0 {
+			slog.Error("Invalid ANCHOR_CONFIRMATIONS value", "value", raw)
+			os.Exit(1)
+		}
+		opts.Confirmations = confirmations
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	anchorer, err := anchor.DialEthereumAnchorer(ctx, rpcURL, contractAddress, privateKey)
+	if err != nil {
+		slog.Error("Failed to initialize audit anchorer", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("Audit anchoring enabled", "contract", contractAddress, "from", anchorer.From().Hex(), "confirmations", opts.Confirmations)
+
+	network := &protocol.AnchorNetwork{ChainID: anchorer.ChainID(), Contract: anchorer.Contract().Hex()}
+	return audit.NewAnchorService(repo, anchorer, opts), network
+}
+
+func parseOptionalDuration(v string, fallback time.Duration) (time.Duration, error) {
+	if strings.TrimSpace(v) == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(v))
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be positive, got %q", v)
+	}
+	return d, nil
+}
+
+// splitList parses a comma-separated list, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, v := range values {
 		if strings.TrimSpace(v) != "" {
@@ -0,0 +1,66 @@
+import { apiClient } from "@/lib/api-client";
+import type { EthAddress } from "@/types/ethereum";
+
+import type { AccessAlert, AccessAlertRule, AccessAlertStatus } from "../types";
+
+interface AccessAlertResponse {
+	id: string;
+	rule: AccessAlertRule;
+	subject: string;
+	status: AccessAlertStatus;
+	grantee: string;
+	grantId?: string;
+	detail: string;
+	count: number;
+	entryIds: string[] | null;
+	firstSeenAt: string;
+	lastSeenAt: string;
+	resolvedAt?: string;
+	resolution?: string;
+}
+
+const mapAccessAlert = (alert: AccessAlertResponse): AccessAlert => ({
+	id: alert.id,
+	rule: alert.rule,
+	subject: alert.subject as EthAddress,
+	status: alert.status,
+	grantee: alert.grantee as EthAddress,
+	grantId: alert.grantId || undefined,
+	detail: alert.detail,
+	count: alert.count,
+	entryIds: alert.entryIds ?? [],
+	firstSeenAt: new Date(alert.firstSeenAt),
+	lastSeenAt: new Date(alert.lastSeenAt),
+	resolvedAt: alert.resolvedAt ? new Date(alert.resolvedAt) : undefined,
+	resolution: alert.resolution,
+});
+
+/** Lists suspicious access to the current user's data, most recently seen first. */
+export const getAccessAlerts = async (
+	status?: AccessAlertStatus,
+): Promise<AccessAlert[]> => {
+	const query = status ? `?status=${status}` : "";
+	const { alerts } = (await apiClient(`/api/alerts${query}`)) as {
+		alerts: AccessAlertResponse[] | null;
+	};
+	return (alerts ?? []).map(mapAccessAlert);
+};
+
+/**
+ * Answers an alert by suspending the consent grant it concerns. The signature is
+ * the patient's suspension receipt for the grant (see useSuspendConsent); it may
+ * be left out when the grant is already suspended.
+ */
+export const suspendAlertGrant = async (
+	alertId: string,
+	signature?: string,
+): Promise<AccessAlert> => {
+	if (!alertId) {
+		throw new Error("Alert id is required");
+	}
+	const { alert } = (await apiClient(`/api/alerts/${alertId}/suspend`, {
+		method: "POST",
+		body: signature ? { signature } : undefined,
+	})) as { alert: AccessAlertResponse };
+	return mapAccessAlert(alert);
+};
@@ -1,38 +1,31 @@
 import { apiClient } from "@/lib/api-client";
 
+import type { AuditAnchorStatus, MerkleScheme } from "../types";
+
 export interface MerkleBatch {
 	id: string;
 	rootHash: string;
+	merkleScheme: MerkleScheme;
 	startTime: string;
 	endTime: string;
 	entryCount: number;
+	treeSize: number;
 	createdAt: string;
+	anchorStatus: AuditAnchorStatus;
+	anchorTxHash?: string;
+	anchorBlockNumber?: number;
+	anchorConfirmations?: number;
+	anchorAttempts: number;
+	anchorError?: string;
+	anchorSubmittedAt?: string;
+	anchoredAt?: string;
 }
 
 interface BuildMerkleResponse {
-	batch: MerkleBatch;
-	root: string;
-}
-
-export interface BuildMerkleParams {
-	startTime?: Date | string;
-	endTime?: Date | string;
+	batches: MerkleBatch[];
 }
 
-const toTimestamp = (value?: Date | string) => {
-	if (!value) {
-		return undefined;
-	}
-	return value instanceof Date ? value.toISOString() : value;
-};
-
-export const buildMerkleTree = async (
-	params: BuildMerkleParams,
-): Promise<BuildMerkleResponse> => {
-	return apiClient("/api/audit/merkle/build", {
-		body: {
-			startTime: toTimestamp(params.startTime),
-			endTime: toTimestamp(params.endTime),
-		},
-	});
+/** Closes any due batch windows now. Only system principals may call this. */
+export const buildMerkleTree = async (): Promise<BuildMerkleResponse> => {
+	return apiClient("/api/audit/merkle/build", { method: "POST" });
 };
@@ -0,0 +1,32 @@
+import { API_URL } from "@/lib/api-client";
+
+export interface ExportAuditBundleParams {
+	startTime?: string;
+	endTime?: string;
+}
+
+/**
+ * Downloads a self-verifying audit bundle (tar) that can be checked offline with
+ * `fleming-verify`. System principals receive the whole chain; other users receive
+ * only their own entries.
+ */
+export const exportAuditBundle = async (
+	params: ExportAuditBundleParams = {},
+): Promise<Blob> => {
+	const query = new URLSearchParams();
+	if (params.startTime) query.set("startTime", params.startTime);
+	if (params.endTime) query.set("endTime", params.endTime);
+	const suffix = query.toString() ? `?${query.toString()}` : "";
+
+	const response = await fetch(`${API_URL}/api/audit/export${suffix}`, {
+		method: "GET",
+		credentials: "include",
+	});
+
+	if (!response.ok) {
+		const errorMessage = await response.text();
+		throw new Error(errorMessage || response.statusText);
+	}
+
+	return response.blob();
+};
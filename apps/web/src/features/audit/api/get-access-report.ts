@@ -0,0 +1,72 @@
+import { apiClient } from "@/lib/api-client";
+import type { EthAddress } from "@/types/ethereum";
+
+import type { AccessReport } from "../types";
+import { type AuditEntryResponse, mapAuditEntry } from "./mappers";
+
+interface GrantAccessResponse {
+	grantId: string;
+	count: number;
+	firstAccess: string;
+	lastAccess: string;
+	entries: AuditEntryResponse[];
+}
+
+interface PartyAccessResponse {
+	actor: string;
+	count: number;
+	firstAccess: string;
+	lastAccess: string;
+	grants: GrantAccessResponse[];
+}
+
+interface AccessReportResponse {
+	report: {
+		subject: string;
+		total: number;
+		parties: PartyAccessResponse[];
+		truncated: boolean;
+	};
+}
+
+export interface AccessReportParams {
+	startTime?: string;
+	endTime?: string;
+}
+
+/** Lists every read of the current user's data by other parties, grouped by party and consent grant. */
+export const getAccessReport = async (
+	params: AccessReportParams = {},
+): Promise<AccessReport> => {
+	const searchParams = new URLSearchParams();
+	if (params.startTime) {
+		searchParams.set("startTime", params.startTime);
+	}
+	if (params.endTime) {
+		searchParams.set("endTime", params.endTime);
+	}
+	const query = searchParams.toString();
+
+	const { report } = (await apiClient(
+		`/api/audit/access${query ? `?${query}` : ""}`,
+	)) as AccessReportResponse;
+
+	return {
+		subject: report.subject as EthAddress,
+		total: report.total,
+		truncated: report.truncated,
+		parties: report.parties.map((party) => ({
+			actor: party.actor as EthAddress,
+			count: party.count,
+			firstAccess: new Date(party.firstAccess),
+			lastAccess: new Date(party.lastAccess),
+			grants: party.grants.map((grant) => ({
+				grantId: grant.grantId || undefined,
+				count: grant.count,
+				firstAccess: new Date(grant.firstAccess),
+				lastAccess: new Date(grant.lastAccess),
+				entries: grant.entries.map(mapAuditEntry),
+			})),
+		})),
+	};
+};
@@ -1,4 +1,7 @@
+export * from "./access-alerts";
 export * from "./build-merkle-tree";
+export * from "./export-audit-bundle";
+export * from "./get-access-report";
 export * from "./get-audit-by-resource";
 export * from "./get-audit-entry";
 export * from "./get-audit-logs";
@@ -1,8 +1,45 @@
 import { apiClient } from "@/lib/api-client";
 
+type BreakKind =
+	| "hash_mismatch"
+	| "chain_gap"
+	| "reordering"
+	| "scheme_downgrade"
+	| "checkpoint_mismatch";
+
+interface ChainBreak {
+	kind: BreakKind;
+	entryId: string;
+	entryTimestamp: string;
+	scheme?: string;
+	storedHash?: string;
+	computedHash?: string;
+	previousHash?: string;
+	expectedPreviousHash?: string;
+	affectedRange: {
+		fromEntryId: string;
+		fromTimestamp: string;
+		toEntryId: string;
+		toTimestamp: string;
+	};
+}
+
+interface IntegrityReport {
+	valid: boolean;
+	entriesChecked: number;
+	from?: string;
+	to?: string;
+	resumedFrom?: string;
+	lastVerifiedEntryId?: string;
+	lastVerifiedHash?: string;
+	break?: ChainBreak;
+	checkedAt: string;
+}
+
 interface VerifyIntegrityResponse {
 	valid: boolean;
 	message: string;
+	report: IntegrityReport;
 }
 
 export const verifyIntegrity = async (): Promise<VerifyIntegrityResponse> => {
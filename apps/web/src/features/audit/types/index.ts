@@ -12,6 +12,7 @@ export const AuditAction = {
 	ConsentExpired: "consent.expire",
 	ConsentSuspend: "consent.suspend",
 	ConsentResume: "consent.resume",
+	ConsentExpiring: "consent.expiring",
 	RecordCreated: "create",
 	RecordRead: "read",
 	RecordUpdated: "update",
@@ -19,8 +20,17 @@ export const AuditAction = {
 	FileUploaded: "file.upload",
 	FileDownloaded: "file.download",
 	FileShared: "file.share",
+	FileUnshared: "file.unshare",
+	FileRekeyed: "file.rekey",
 	UserAuthenticated: "auth.login",
 	UserLoggedOut: "auth.logout",
+	AccessDenied: "access.deny",
+	AlertRaised: "alert.raise",
+	AlertResolved: "alert.resolve",
+	EmergencyAccess: "emergency.access",
+	EmergencyEnded: "emergency.end",
+	EmergencyReviewed: "emergency.review",
+	EmergencyProfileUpdated: "emergency.profile",
 } as const;
 
 export type AuditAction = (typeof AuditAction)[keyof typeof AuditAction];
@@ -33,6 +43,9 @@ export const AuditTargetType = {
 	VC: "vc",
 	ZKProof: "zk_proof",
 	Attestation: "attestation",
+	Alert: "alert",
+	EmergencyAccess: "emergency_access",
+	EmergencyProfile: "emergency_profile",
 } as const;
 
 export type AuditTargetType =
@@ -50,6 +63,7 @@ export function formatAuditAction(action: string): string {
 		[AuditAction.ConsentExpired]: "Consent expired",
 		[AuditAction.ConsentSuspend]: "Consent suspended",
 		[AuditAction.ConsentResume]: "Consent resumed",
+		[AuditAction.ConsentExpiring]: "Consent expiring soon",
 		[AuditAction.RecordCreated]: "Create",
 		[AuditAction.RecordRead]: "Read",
 		[AuditAction.RecordUpdated]: "Update",
@@ -57,8 +71,17 @@ export function formatAuditAction(action: string): string {
 		[AuditAction.FileUploaded]: "File upload",
 		[AuditAction.FileDownloaded]: "File download",
 		[AuditAction.FileShared]: "File share",
+		[AuditAction.FileUnshared]: "File share disabled",
+		[AuditAction.FileRekeyed]: "File re-keyed",
 		[AuditAction.UserAuthenticated]: "Login",
 		[AuditAction.UserLoggedOut]: "Logout",
+		[AuditAction.AccessDenied]: "Access denied",
+		[AuditAction.AlertRaised]: "Alert raised",
+		[AuditAction.AlertResolved]: "Alert resolved",
+		[AuditAction.EmergencyAccess]: "Emergency access",
+		[AuditAction.EmergencyEnded]: "Emergency access ended",
+		[AuditAction.EmergencyReviewed]: "Emergency access reviewed",
+		[AuditAction.EmergencyProfileUpdated]: "Emergency profile updated",
 	};
 	if (known[action]) return known[action];
 	return action.replace(/\./g, " ").replace(/\b\w/g, (c) => c.toUpperCase());
@@ -76,6 +99,9 @@ export function formatAuditResourceType(resourceType: string): string {
 		[AuditTargetType.VC]: "Verifiable credential",
 		[AuditTargetType.ZKProof]: "ZK proof",
 		[AuditTargetType.Attestation]: "Attestation",
+		[AuditTargetType.Alert]: "Alert",
+		[AuditTargetType.EmergencyAccess]: "Emergency access",
+		[AuditTargetType.EmergencyProfile]: "Emergency profile",
 	};
 	if (known[resourceType]) return known[resourceType];
 	return resourceType.replace(/_/g, " ").replace(/\b\w/g, (c) => c.toUpperCase());
@@ -83,6 +109,7 @@ export function formatAuditResourceType(resourceType: string): string {
 
 export const AuditAnchorStatus = {
 	Pending: "pending",
+	Submitted: "submitted",
 	Anchored: "anchored",
 	Failed: "failed",
 } as const;
@@ -104,12 +131,71 @@ export interface AuditLogEntry {
 	anchorStatus?: AuditAnchorStatus;
 }
 
+/** Reads of a patient's data made by one party under one consent grant. */
+export interface GrantAccess {
+	grantId?: string;
+	count: number;
+	firstAccess: Date;
+	lastAccess: Date;
+	entries: AuditLogEntry[];
+}
+
+/** Reads of a patient's data made by one party. */
+export interface PartyAccess {
+	actor: EthAddress;
+	count: number;
+	firstAccess: Date;
+	lastAccess: Date;
+	grants: GrantAccess[];
+}
+
+/** Who accessed a patient's data, most recent first. */
+export interface AccessReport {
+	subject: EthAddress;
+	total: number;
+	parties: PartyAccess[];
+	truncated: boolean;
+}
+
+export const AccessAlertRule = {
+	BulkDownload: "bulk_download",
+	NearExpiry: "near_expiry",
+	AfterSuspension: "after_suspension",
+	OutOfScope: "out_of_scope",
+	PurposeMismatch: "purpose_mismatch",
+} as const;
+
+export type AccessAlertRule =
+	(typeof AccessAlertRule)[keyof typeof AccessAlertRule];
+
+export type AccessAlertStatus = "open" | "resolved";
+
+/** Suspicious access to a patient's data flagged from the audit stream. */
+export interface AccessAlert {
+	id: string;
+	rule: AccessAlertRule;
+	subject: EthAddress;
+	status: AccessAlertStatus;
+	grantee: EthAddress;
+	grantId?: string;
+	detail: string;
+	count: number;
+	entryIds: string[];
+	firstSeenAt: Date;
+	lastSeenAt: Date;
+	resolvedAt?: Date;
+	resolution?: string;
+}
+
 export interface MerkleProofStep {
 	hash: string;
 	isLeft: boolean;
 }
 
+export type MerkleScheme = "merkle.v1" | "merkle.v2";
+
 export interface MerkleProof {
+	scheme?: MerkleScheme;
 	entryHash: string;
 	steps: MerkleProofStep[];
 }
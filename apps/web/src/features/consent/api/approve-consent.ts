@@ -1,10 +1,15 @@
 import { apiClient } from "@/lib/api-client";
+import type { ConsentReceipt, SignedConsentAction } from "../types";
 
-export const approveConsent = async (grantId: string): Promise<void> => {
+export const approveConsent = async ({
+	grantId,
+	signature,
+}: SignedConsentAction): Promise<ConsentReceipt> => {
 	if (!grantId) {
 		throw new Error("Consent grant id is required");
 	}
-	await apiClient(`/api/consent/${grantId}/approve`, {
-		method: "POST",
-	});
+	const { receipt } = (await apiClient(`/api/consent/${grantId}/approve`, {
+		body: { signature },
+	})) as { receipt: ConsentReceipt };
+	return receipt;
 };
@@ -2,5 +2,7 @@ export * from "./approve-consent";
 export * from "./deny-consent";
 export * from "./get-consent-grants";
 export * from "./get-consent-request";
+export * from "./receipts";
 export * from "./request-consent";
 export * from "./revoke-consent";
+export * from "./suspend-consent";
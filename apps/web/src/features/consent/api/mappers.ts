@@ -1,6 +1,13 @@
 import type { EthAddress } from "@/types/ethereum";
 
-import type { ConsentGrant, ConsentPermission, ConsentState } from "../types";
+import type {
+	ConsentGrant,
+	ConsentPermission,
+	ConsentPurpose,
+	ConsentState,
+	DataCategory,
+	SecondaryUse,
+} from "../types";
 
 export interface ConsentGrantResponse {
 	id: string;
@@ -11,6 +18,11 @@ export interface ConsentGrantResponse {
 	state: string;
 	reason?: string | null;
 	expiresAt?: string | null;
+	expiryNoticeAt?: string | null;
+	purpose?: string | null;
+	categories?: DataCategory[] | null;
+	studyId?: string | null;
+	secondaryUses?: string[] | null;
 	createdAt: string;
 	updatedAt: string;
 }
@@ -48,6 +60,11 @@ export const mapConsentGrant = (grant: ConsentGrantResponse): ConsentGrant => ({
 	state: grant.state as ConsentState,
 	reason: grant.reason ?? undefined,
 	expiresAt: parseOptionalDate(grant.expiresAt),
+	expiryNoticeAt: parseOptionalDate(grant.expiryNoticeAt),
+	purpose: (grant.purpose as ConsentPurpose | null) ?? undefined,
+	categories: grant.categories ?? undefined,
+	studyId: grant.studyId ?? undefined,
+	secondaryUses: (grant.secondaryUses as SecondaryUse[] | null) ?? undefined,
 	createdAt: parseRequiredDate(grant.createdAt),
 	updatedAt: parseRequiredDate(grant.updatedAt),
 });
@@ -0,0 +1,42 @@
+import { apiClient } from "@/lib/api-client";
+import type {
+	ConsentReceipt,
+	ConsentReceiptAction,
+	ConsentReceiptVerification,
+	ConsentTypedData,
+} from "../types";
+
+/** Fetches the typed data the caller signs to take action on a grant. */
+export const getReceiptData = async (
+	grantId: string,
+	action: ConsentReceiptAction,
+): Promise<ConsentTypedData> => {
+	if (!grantId) {
+		throw new Error("Consent grant id is required");
+	}
+	const { typedData } = (await apiClient(
+		`/api/consent/${grantId}/receipt-data?action=${action}`,
+	)) as { typedData: ConsentTypedData };
+	return typedData;
+};
+
+/** Lists the signed receipts of a grant, oldest first. */
+export const getConsentReceipts = async (
+	grantId: string,
+): Promise<ConsentReceipt[]> => {
+	if (!grantId) {
+		throw new Error("Consent grant id is required");
+	}
+	const { receipts } = (await apiClient(
+		`/api/consent/${grantId}/receipts`,
+	)) as { receipts: ConsentReceipt[] | null };
+	return receipts ?? [];
+};
+
+/** Checks a receipt's signature and signer; no account is needed. */
+export const verifyConsentReceipt = async (
+	receipt: ConsentReceipt,
+): Promise<ConsentReceiptVerification> =>
+	(await apiClient("/api/consent/receipts/verify", {
+		body: receipt,
+	})) as ConsentReceiptVerification;
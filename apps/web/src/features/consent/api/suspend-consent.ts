@@ -0,0 +1,15 @@
+import { apiClient } from "@/lib/api-client";
+import type { ConsentReceipt, SignedConsentAction } from "../types";
+
+export const suspendConsent = async ({
+	grantId,
+	signature,
+}: SignedConsentAction): Promise<ConsentReceipt> => {
+	if (!grantId) {
+		throw new Error("Consent grant id is required");
+	}
+	const { receipt } = (await apiClient(`/api/consent/${grantId}/suspend`, {
+		body: { signature },
+	})) as { receipt: ConsentReceipt };
+	return receipt;
+};
@@ -8,7 +8,12 @@ import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { ConsentBadge } from "@/features/consent/components/consent-badge";
 import { cn } from "@/lib/utils";
 
-import type { ConsentGrant, ConsentPermission, ConsentState } from "../types";
+import {
+	CONSENT_PURPOSE_LABELS,
+	type ConsentGrant,
+	type ConsentPermission,
+	type ConsentState,
+} from "../types";
 import { RevokeConsentDialog } from "./revoke-consent-dialog";
 
 const permissionLabels: Record<ConsentPermission, string> = {
@@ -111,6 +116,21 @@ export function ConsentGrantCard({
 
 				{detailsOpen && (
 					<div className="space-y-2">
+						<div className="space-y-1">
+							<p className="text-xs uppercase tracking-wide text-muted-foreground">
+								Purpose
+							</p>
+							<p className="text-sm text-foreground">
+								{CONSENT_PURPOSE_LABELS[grant.purpose ?? "treatment"]}
+								{grant.studyId && ` (study ${grant.studyId})`}
+							</p>
+							{grant.secondaryUses && grant.secondaryUses.length > 0 && (
+								<p className="text-xs text-muted-foreground">
+									Also permits:{" "}
+									{grant.secondaryUses.join(", ").replaceAll("_", " ")}
+								</p>
+							)}
+						</div>
 						{grant.reason && (
 							<div className="space-y-1">
 								<p className="text-xs uppercase tracking-wide text-muted-foreground">
@@ -1,8 +1,10 @@
-import type { ConsentPermission } from "../types";
+import type { ConsentPermission, ConsentPurpose } from "../types";
 
 export interface ConsentRequestFormValues {
 	grantor: string;
 	permissions: ConsentPermission[];
 	durationDays?: number;
 	reason: string;
+	purpose: ConsentPurpose;
+	studyId: string;
 }
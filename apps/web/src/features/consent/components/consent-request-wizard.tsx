@@ -13,6 +13,7 @@ import { requestConsent } from "../api";
 import type {
 	ConsentGrant,
 	ConsentPermission,
+	ConsentPurpose,
 	ConsentRequestPayload,
 } from "../types";
 import type { ConsentForm } from "./consent-form-types";
@@ -24,6 +25,17 @@ import { PermissionsStep } from "./wizard-steps/permissions-step";
 import { ReasonStep } from "./wizard-steps/reason-step";
 import { ReviewStep } from "./wizard-steps/review-step";
 
+const consentPurposeSchema = z.enum([
+	"treatment",
+	"research",
+	"second_opinion",
+	"insurance",
+]);
+
+/** Research consent must name its study; other purposes must not. */
+const studyMatchesPurpose = (purpose: string, studyId?: string): boolean =>
+	(purpose === "research") === Boolean(studyId?.trim());
+
 const consentRequestSchema = z.object({
 	grantor: z
 		.string()
@@ -33,6 +45,8 @@ const consentRequestSchema = z.object({
 		.array(z.enum(["read", "write", "share"]))
 		.min(1, "Select at least one permission"),
 	reason: z.string().trim().max(500, "Reason is too long").optional(),
+	purpose: consentPurposeSchema,
+	studyId: z.string().trim().max(255, "Study ID is too long").optional(),
 	durationDays: z
 		.number()
 		.int("Use a whole number of days")
@@ -41,6 +55,21 @@ const consentRequestSchema = z.object({
 		.optional(),
 });
 
+const consentRequestFormSchema = consentRequestSchema
+	.refine((value) => studyMatchesPurpose(value.purpose, value.studyId), {
+		message: "Research requests must name a study",
+		path: ["studyId"],
+	})
+	.refine(
+		(value) =>
+			!["research", "insurance"].includes(value.purpose) ||
+			value.permissions.every((permission) => permission === "read"),
+		{
+			message: "Research and insurance requests are read-only",
+			path: ["permissions"],
+		},
+	);
+
 const stepLabels = [
 	"Patient",
 	"Permissions",
@@ -81,9 +110,11 @@ export function ConsentRequestWizard({
 			permissions: [] as ConsentPermission[],
 			durationDays: 30 as number | undefined,
 			reason: "",
+			purpose: "treatment" as ConsentPurpose,
+			studyId: "",
 		} satisfies Partial<ConsentRequestFormValues>,
 		onSubmit: async ({ value }) => {
-			const parsed = consentRequestSchema.safeParse(value);
+			const parsed = consentRequestFormSchema.safeParse(value);
 			if (!parsed.success) {
 				toast.error("Please fix the form errors before submitting.");
 				return;
@@ -93,6 +124,8 @@ export function ConsentRequestWizard({
 				permissions: parsed.data.permissions as ConsentPermission[],
 				reason: parsed.data.reason?.trim() || undefined,
 				durationDays: parsed.data.durationDays,
+				purpose: parsed.data.purpose,
+				studyId: parsed.data.studyId?.trim() || undefined,
 			};
 			await mutation.mutateAsync(payload);
 		},
@@ -113,7 +146,7 @@ export function ConsentRequestWizard({
 					values.durationDays,
 				).success;
 			case 3:
-				return true;
+				return studyMatchesPurpose(values.purpose, values.studyId);
 			default:
 				return form.state.canSubmit;
 		}
@@ -2,6 +2,7 @@ import type { JSX } from "react";
 
 import { Label } from "@/components/ui/label";
 
+import { CONSENT_PURPOSE_LABELS, type ConsentPurpose } from "../../types";
 import type { ConsentForm } from "../consent-form-types";
 
 interface ReasonStepProps {
@@ -10,28 +11,79 @@ interface ReasonStepProps {
 
 export function ReasonStep({ form }: ReasonStepProps): JSX.Element {
 	return (
-		<form.Field name="reason">
-			{(field) => (
-				<div className="space-y-3">
+		<div className="space-y-6">
+			<form.Field name="purpose">
+				{(field) => (
 					<div className="space-y-2">
-						<Label htmlFor="reason">Reason (optional)</Label>
-						<p className="text-xs text-muted-foreground">
-							You can skip this step.
-						</p>
-						<textarea
-							id="reason"
+						<Label htmlFor="purpose">Purpose</Label>
+						<select
+							id="purpose"
 							value={field.state.value}
-							onChange={(event) => field.handleChange(event.target.value)}
+							onChange={(event) =>
+								field.handleChange(event.target.value as ConsentPurpose)
+							}
 							onBlur={field.handleBlur}
-							placeholder="Explain why you need access"
-							className="min-h-[110px] w-full rounded-md border border-input bg-transparent px-3 py-2 text-sm shadow-xs focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-cyan-500 focus-visible:ring-offset-2 dark:focus-visible:ring-cyan-400"
-						/>
+							className="h-9 w-full rounded-md border border-input bg-transparent px-3 text-sm shadow-xs focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-cyan-500 focus-visible:ring-offset-2 dark:focus-visible:ring-cyan-400"
+						>
+							{Object.entries(CONSENT_PURPOSE_LABELS).map(([value, label]) => (
+								<option key={value} value={value}>
+									{label}
+								</option>
+							))}
+						</select>
+						<p className="text-xs text-muted-foreground">
+							Research and insurance access is read-only.
+						</p>
+					</div>
+				)}
+			</form.Field>
+			<form.Subscribe selector={(state) => state.values.purpose}>
+				{(purpose) =>
+					purpose === "research" && (
+						<form.Field name="studyId">
+							{(field) => (
+								<div className="space-y-2">
+									<Label htmlFor="studyId">Study ID</Label>
+									<input
+										id="studyId"
+										value={field.state.value}
+										onChange={(event) => field.handleChange(event.target.value)}
+										onBlur={field.handleBlur}
+										placeholder="e.g. NCT01234567"
+										className="h-9 w-full rounded-md border border-input bg-transparent px-3 text-sm shadow-xs focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-cyan-500 focus-visible:ring-offset-2 dark:focus-visible:ring-cyan-400"
+									/>
+									<p className="text-xs text-muted-foreground">
+										Research access only serves requests for this study.
+									</p>
+								</div>
+							)}
+						</form.Field>
+					)
+				}
+			</form.Subscribe>
+			<form.Field name="reason">
+				{(field) => (
+					<div className="space-y-3">
+						<div className="space-y-2">
+							<Label htmlFor="reason">Reason (optional)</Label>
+							<p className="text-xs text-muted-foreground">
+								You can skip this step.
+							</p>
+							<textarea
+								id="reason"
+								value={field.state.value}
+								onChange={(event) => field.handleChange(event.target.value)}
+								onBlur={field.handleBlur}
+								placeholder="Explain why you need access"
+								className="min-h-[110px] w-full rounded-md border border-input bg-transparent px-3 py-2 text-sm shadow-xs focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-cyan-500 focus-visible:ring-offset-2 dark:focus-visible:ring-cyan-400"
+							/>
+						</div>
+						<p className="text-xs text-muted-foreground">
+							A short reason helps patients approve requests faster.
+						</p>
 					</div>
-					<p className="text-xs text-muted-foreground">
-						A short reason helps patients approve requests faster.
-					</p>
-				</div>
-			)}
-		</form.Field>
+				)}
+			</form.Field>
+		</div>
 	);
 }
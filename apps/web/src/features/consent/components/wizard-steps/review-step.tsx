@@ -3,7 +3,7 @@ import type { JSX } from "react";
 import { AddressDisplay } from "@/components/common/address-display";
 import { Badge } from "@/components/ui/badge";
 
-import type { ConsentPermission } from "../../types";
+import { CONSENT_PURPOSE_LABELS, type ConsentPermission } from "../../types";
 import type { ConsentRequestFormValues } from "../consent-request-wizard-types";
 
 interface ReviewStepProps {
@@ -58,6 +58,18 @@ export function ReviewStep({ values }: ReviewStepProps): JSX.Element {
 				</p>
 			</div>
 
+			<div className="space-y-2">
+				<p className="text-xs uppercase tracking-wide text-muted-foreground">
+					Purpose
+				</p>
+				<p className="text-sm text-foreground">
+					{CONSENT_PURPOSE_LABELS[values.purpose]}
+					{values.purpose === "research" &&
+						values.studyId?.trim() &&
+						` (study ${values.studyId.trim()})`}
+				</p>
+			</div>
+
 			<div className="space-y-2">
 				<p className="text-xs uppercase tracking-wide text-muted-foreground">
 					Reason
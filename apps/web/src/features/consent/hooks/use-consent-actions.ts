@@ -1,14 +1,56 @@
 import { type UseMutationOptions, useMutation } from "@tanstack/react-query";
+import type { TypedData } from "viem";
+import { useSignTypedData } from "wagmi";
 
-import { approveConsent, denyConsent, revokeConsent } from "../api";
+import {
+	approveConsent,
+	denyConsent,
+	getReceiptData,
+	revokeConsent,
+	suspendConsent,
+} from "../api";
+import {
+	type ConsentReceipt,
+	ConsentReceiptAction,
+	type SignedConsentAction,
+} from "../types";
 
-export const useApproveConsent = (
-	options?: UseMutationOptions<void, Error, string>,
-) =>
-	useMutation<void, Error, string>({
-		mutationFn: approveConsent,
+/**
+ * Signs the grant's receipt typed data with the connected wallet, then submits the
+ * signature to take the action. The backend rejects the change without it.
+ */
+const useSignedConsentAction = (
+	action: ConsentReceiptAction,
+	submit: (payload: SignedConsentAction) => Promise<ConsentReceipt>,
+	options?: UseMutationOptions<ConsentReceipt, Error, string>,
+) => {
+	const { mutateAsync: signTypedDataAsync } = useSignTypedData();
+
+	return useMutation<ConsentReceipt, Error, string>({
+		mutationFn: async (grantId) => {
+			const typedData = await getReceiptData(grantId, action);
+			// The wallet derives EIP712Domain from the domain itself.
+			const { EIP712Domain: _domain, ...types } = typedData.types;
+			const signature = await signTypedDataAsync({
+				domain: typedData.domain,
+				types: types as TypedData,
+				primaryType: typedData.primaryType,
+				message: typedData.message,
+			});
+			return submit({ grantId, signature });
+		},
 		...options,
 	});
+};
+
+export const useApproveConsent = (
+	options?: UseMutationOptions<ConsentReceipt, Error, string>,
+) =>
+	useSignedConsentAction(
+		ConsentReceiptAction.Approve,
+		approveConsent,
+		options,
+	);
 
 export const useDenyConsent = (
 	options?: UseMutationOptions<void, Error, string>,
@@ -19,9 +61,19 @@ export const useDenyConsent = (
 	});
 
 export const useRevokeConsent = (
-	options?: UseMutationOptions<void, Error, string>,
+	options?: UseMutationOptions<ConsentReceipt, Error, string>,
 ) =>
-	useMutation<void, Error, string>({
-		mutationFn: revokeConsent,
-		...options,
-	});
+	useSignedConsentAction(
+		ConsentReceiptAction.Revoke,
+		revokeConsent,
+		options,
+	);
+
+export const useSuspendConsent = (
+	options?: UseMutationOptions<ConsentReceipt, Error, string>,
+) =>
+	useSignedConsentAction(
+		ConsentReceiptAction.Suspend,
+		suspendConsent,
+		options,
+	);
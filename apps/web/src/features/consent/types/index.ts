@@ -57,6 +57,48 @@ export const ConsentPermission = {
 export type ConsentPermission =
 	(typeof ConsentPermission)[keyof typeof ConsentPermission];
 
+/**
+ * What a grant allows the grantee to use the data for.
+ * Research grants are read-only and bound to a single study.
+ */
+export const ConsentPurpose = {
+	Treatment: "treatment",
+	Research: "research",
+	SecondOpinion: "second_opinion",
+	Insurance: "insurance",
+} as const;
+
+export type ConsentPurpose =
+	(typeof ConsentPurpose)[keyof typeof ConsentPurpose];
+
+export const CONSENT_PURPOSE_LABELS: Record<ConsentPurpose, string> = {
+	treatment: "Treatment",
+	research: "Research",
+	second_opinion: "Second opinion",
+	insurance: "Insurance",
+};
+
+/**
+ * Uses of research data beyond the study itself that a patient may permit.
+ */
+export const SecondaryUse = {
+	AggregateAnalysis: "aggregate_analysis",
+	Publication: "publication",
+	DataSharing: "data_sharing",
+	ModelTraining: "model_training",
+} as const;
+
+export type SecondaryUse = (typeof SecondaryUse)[keyof typeof SecondaryUse];
+
+/**
+ * Selects events by type, by a code prefix within a coding system, or both.
+ */
+export interface DataCategory {
+	readonly eventType?: string;
+	readonly system?: string;
+	readonly codePrefix?: string;
+}
+
 /**
  * Represents a consent grant stored in the backend.
  */
@@ -69,6 +111,13 @@ export interface ConsentGrant {
 	readonly state: ConsentState;
 	readonly reason?: string;
 	readonly expiresAt?: Date;
+	/** When both parties were told the grant expires soon. */
+	readonly expiryNoticeAt?: Date;
+	/** Absent on grants made before purposes existed; they count as treatment. */
+	readonly purpose?: ConsentPurpose;
+	readonly categories?: readonly DataCategory[];
+	readonly studyId?: string;
+	readonly secondaryUses?: readonly SecondaryUse[];
 	readonly createdAt: Date;
 	readonly updatedAt: Date;
 }
@@ -78,4 +127,76 @@ export interface ConsentRequestPayload {
 	readonly permissions: readonly ConsentPermission[];
 	readonly reason?: string;
 	readonly durationDays?: number;
+	readonly purpose?: ConsentPurpose;
+	readonly categories?: readonly DataCategory[];
+	readonly studyId?: string;
+	readonly secondaryUses?: readonly SecondaryUse[];
+}
+
+/**
+ * Grant changes the acting party signs with their wallet (EIP-712).
+ */
+export const ConsentReceiptAction = {
+	Approve: "approve",
+	Revoke: "revoke",
+	Suspend: "suspend",
+} as const;
+
+export type ConsentReceiptAction =
+	(typeof ConsentReceiptAction)[keyof typeof ConsentReceiptAction];
+
+/**
+ * EIP-712 typed data over a grant's canonical content, in the shape
+ * eth_signTypedData_v4 accepts.
+ */
+export interface ConsentTypedData {
+	readonly types: Record<string, readonly { name: string; type: string }[]>;
+	readonly primaryType: string;
+	readonly domain: {
+		readonly name?: string;
+		readonly version?: string;
+		readonly chainId?: number;
+		readonly verifyingContract?: EthAddress;
+	};
+	readonly message: Record<string, unknown>;
+}
+
+/**
+ * Portable evidence that a party signed a change to a grant. Anyone can check it
+ * against the typed data it carries.
+ */
+export interface ConsentReceipt {
+	readonly id: string;
+	readonly grantId: string;
+	readonly action: ConsentReceiptAction;
+	readonly signer: EthAddress;
+	/** The patient, when a delegate signed for them. */
+	readonly onBehalfOf?: EthAddress;
+	readonly delegationId?: string;
+	readonly typedData: ConsentTypedData;
+	readonly signature: string;
+	readonly signatureAlgorithm: string;
+	readonly signedAt: string;
+	readonly schemaVersion?: string;
+}
+
+export interface ConsentReceiptVerification {
+	readonly valid: boolean;
+	readonly receiptId?: string;
+	readonly grantId: string;
+	readonly action: ConsentReceiptAction;
+	readonly signer: EthAddress;
+	/** Whether Fleming holds the receipt. */
+	readonly recorded: boolean;
+	readonly grantState?: ConsentState;
+	readonly errors?: readonly string[];
+	readonly checkedAt: string;
+}
+
+/**
+ * A receipt signature for a grant change.
+ */
+export interface SignedConsentAction {
+	readonly grantId: string;
+	readonly signature: string;
 }
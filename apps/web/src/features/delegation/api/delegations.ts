@@ -0,0 +1,84 @@
+import { apiClient } from "@/lib/api-client";
+import type { EthAddress } from "@/types/ethereum";
+import type {
+	Delegation,
+	DelegationPermission,
+	DelegationRelationship,
+	DelegationRequestPayload,
+	DelegationStatus,
+} from "../types";
+
+interface DelegationResponse {
+	id: string;
+	principal: string;
+	delegate: string;
+	relationship: DelegationRelationship;
+	permissions: DelegationPermission[] | null;
+	status: DelegationStatus;
+	expiresAt: string;
+	signedAt?: string;
+	revokedAt?: string;
+	revokedBy?: string;
+	createdAt: string;
+}
+
+const mapDelegation = (response: DelegationResponse): Delegation => ({
+	id: response.id,
+	principal: response.principal as EthAddress,
+	delegate: response.delegate as EthAddress,
+	relationship: response.relationship,
+	permissions: response.permissions ?? [],
+	status: response.status,
+	expiresAt: new Date(response.expiresAt),
+	signedAt: response.signedAt ? new Date(response.signedAt) : undefined,
+	revokedAt: response.revokedAt ? new Date(response.revokedAt) : undefined,
+	revokedBy: response.revokedBy as EthAddress | undefined,
+	createdAt: new Date(response.createdAt),
+});
+
+export const createDelegation = async (
+	payload: DelegationRequestPayload,
+): Promise<Delegation> => {
+	const response = await apiClient("/api/delegations", { body: payload });
+	return mapDelegation(response as DelegationResponse);
+};
+
+/**
+ * Lists the caller's delegations: those they granted as patient, or those they
+ * hold as delegate.
+ */
+export const getDelegations = async (
+	role: "principal" | "delegate" = "principal",
+): Promise<Delegation[]> => {
+	const response = await apiClient(`/api/delegations?role=${role}`);
+	const payload = response as { delegations: DelegationResponse[] };
+	return payload.delegations.map(mapDelegation);
+};
+
+/**
+ * Fetches the message the patient signs with their wallet to activate a delegation.
+ */
+export const getDelegationSigningMessage = async (
+	delegationId: string,
+): Promise<string> => {
+	const response = await apiClient(
+		`/api/delegations/${delegationId}/signing-message`,
+	);
+	return (response as { message: string }).message;
+};
+
+export const signDelegation = async (
+	delegationId: string,
+	signature: string,
+): Promise<Delegation> => {
+	const response = await apiClient(`/api/delegations/${delegationId}/sign`, {
+		body: { signature },
+	});
+	return mapDelegation(response as DelegationResponse);
+};
+
+export const revokeDelegation = async (delegationId: string): Promise<void> => {
+	await apiClient(`/api/delegations/${delegationId}/revoke`, {
+		method: "POST",
+	});
+};
@@ -0,0 +1,80 @@
+import type { EthAddress } from "@/types/ethereum";
+
+/**
+ * Delegation Types
+ */
+
+/**
+ * What a guardian or caregiver may do as the patient.
+ */
+export const DelegationPermission = {
+	TimelineRead: "timeline.read",
+	TimelineWrite: "timeline.write",
+	ConsentManage: "consent.manage",
+	AuditRead: "audit.read",
+} as const;
+
+export type DelegationPermission =
+	(typeof DelegationPermission)[keyof typeof DelegationPermission];
+
+/**
+ * User-facing labels for delegation permissions.
+ */
+export const DELEGATION_PERMISSION_LABELS: Record<DelegationPermission, string> =
+	{
+		"timeline.read": "View timeline",
+		"timeline.write": "Edit timeline",
+		"consent.manage": "Manage consent",
+		"audit.read": "View access history",
+	};
+
+/**
+ * How the delegate stands to the patient.
+ */
+export const DelegationRelationship = {
+	Guardian: "guardian",
+	Caregiver: "caregiver",
+} as const;
+
+export type DelegationRelationship =
+	(typeof DelegationRelationship)[keyof typeof DelegationRelationship];
+
+/**
+ * Lifecycle status of a delegation. It stays pending until the patient signs it.
+ */
+export const DelegationStatus = {
+	Pending: "pending",
+	Active: "active",
+	Revoked: "revoked",
+	Expired: "expired",
+} as const;
+
+export type DelegationStatus =
+	(typeof DelegationStatus)[keyof typeof DelegationStatus];
+
+/**
+ * A patient's delegation to a guardian or caregiver.
+ */
+export interface Delegation {
+	id: string;
+	principal: EthAddress;
+	delegate: EthAddress;
+	relationship: DelegationRelationship;
+	permissions: DelegationPermission[];
+	status: DelegationStatus;
+	expiresAt: Date;
+	signedAt?: Date;
+	revokedAt?: Date;
+	revokedBy?: EthAddress;
+	createdAt: Date;
+}
+
+/**
+ * Payload for naming a guardian or caregiver.
+ */
+export interface DelegationRequestPayload {
+	delegate: EthAddress;
+	relationship: DelegationRelationship;
+	permissions: DelegationPermission[];
+	expiresAt: string;
+}
@@ -0,0 +1,137 @@
+import {
+	type AuditEntryResponse,
+	mapAuditEntry,
+} from "@/features/audit/api/mappers";
+import type { AuditLogEntry } from "@/features/audit/types";
+import type { DataCategory } from "@/features/consent/types";
+import { apiClient } from "@/lib/api-client";
+import type { EthAddress } from "@/types/ethereum";
+import type {
+	BreakGlassPayload,
+	EmergencyProfile,
+	EmergencyReview,
+	EmergencySession,
+} from "../types";
+
+interface EmergencySessionResponse {
+	id: string;
+	patientId: string;
+	provider: string;
+	justification: string;
+	categories: DataCategory[] | null;
+	startedAt: string;
+	expiresAt: string;
+	endedAt?: string;
+	endedBy?: string;
+	review: EmergencyReview;
+	reviewedAt?: string;
+	reviewNote?: string;
+}
+
+interface EmergencyProfileResponse {
+	patientId: string;
+	disabled: boolean;
+	categories: DataCategory[] | null;
+}
+
+const mapSession = (response: EmergencySessionResponse): EmergencySession => ({
+	id: response.id,
+	patientId: response.patientId as EthAddress,
+	provider: response.provider as EthAddress,
+	justification: response.justification,
+	categories: response.categories ?? [],
+	startedAt: new Date(response.startedAt),
+	expiresAt: new Date(response.expiresAt),
+	endedAt: response.endedAt ? new Date(response.endedAt) : undefined,
+	endedBy: response.endedBy as EthAddress | undefined,
+	review: response.review,
+	reviewedAt: response.reviewedAt ? new Date(response.reviewedAt) : undefined,
+	reviewNote: response.reviewNote || undefined,
+});
+
+const mapProfile = (response: EmergencyProfileResponse): EmergencyProfile => ({
+	patientId: response.patientId as EthAddress,
+	disabled: response.disabled,
+	categories: response.categories ?? [],
+});
+
+/**
+ * Opens a break-glass session on a patient's record, or returns the caller's
+ * session that is still open. The patient is notified and must review it.
+ */
+export const breakGlass = async (
+	payload: BreakGlassPayload,
+): Promise<EmergencySession> => {
+	const response = await apiClient("/api/emergency/break-glass", {
+		body: payload,
+	});
+	return mapSession(response as EmergencySessionResponse);
+};
+
+/**
+ * Lists the caller's sessions: on their record as patient (optionally only those
+ * awaiting review), or those they opened as provider.
+ */
+export const getEmergencySessions = async (
+	role: "patient" | "provider" = "patient",
+	review?: EmergencyReview,
+): Promise<EmergencySession[]> => {
+	const params = new URLSearchParams({ role });
+	if (review) params.set("review", review);
+	const response = await apiClient(`/api/emergency/sessions?${params}`);
+	const payload = response as { sessions: EmergencySessionResponse[] | null };
+	return (payload.sessions ?? []).map(mapSession);
+};
+
+/**
+ * Lists what the provider read under a session, for the patient's review.
+ */
+export const getEmergencySessionAccess = async (
+	sessionId: string,
+): Promise<AuditLogEntry[]> => {
+	const response = await apiClient(
+		`/api/emergency/sessions/${sessionId}/access`,
+	);
+	const payload = response as { entries: AuditEntryResponse[] | null };
+	return (payload.entries ?? []).map(mapAuditEntry);
+};
+
+export const endEmergencySession = async (
+	sessionId: string,
+): Promise<EmergencySession> => {
+	const response = await apiClient(`/api/emergency/sessions/${sessionId}/end`, {
+		method: "POST",
+	});
+	return mapSession(response as EmergencySessionResponse);
+};
+
+/**
+ * Records the patient's verdict. Flagging ends the session and bars the provider
+ * from breaking glass on the patient's record again.
+ */
+export const reviewEmergencySession = async (
+	sessionId: string,
+	verdict: Exclude<EmergencyReview, "pending">,
+	note?: string,
+): Promise<EmergencySession> => {
+	const response = await apiClient(
+		`/api/emergency/sessions/${sessionId}/review`,
+		{ body: { verdict, note } },
+	);
+	return mapSession(response as EmergencySessionResponse);
+};
+
+export const getEmergencyProfile = async (): Promise<EmergencyProfile> => {
+	const response = await apiClient("/api/emergency/profile");
+	return mapProfile(response as EmergencyProfileResponse);
+};
+
+export const updateEmergencyProfile = async (
+	profile: Pick<EmergencyProfile, "disabled" | "categories">,
+): Promise<EmergencyProfile> => {
+	const response = await apiClient("/api/emergency/profile", {
+		method: "PUT",
+		body: profile,
+	});
+	return mapProfile(response as EmergencyProfileResponse);
+};
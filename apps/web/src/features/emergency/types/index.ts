@@ -0,0 +1,56 @@
+import type { DataCategory } from "@/features/consent/types";
+import type { EthAddress } from "@/types/ethereum";
+
+/**
+ * Emergency Access Types
+ */
+
+/**
+ * The patient's retrospective verdict on a break-glass session.
+ */
+export const EmergencyReview = {
+	Pending: "pending",
+	Confirmed: "confirmed",
+	Flagged: "flagged",
+} as const;
+
+export type EmergencyReview =
+	(typeof EmergencyReview)[keyof typeof EmergencyReview];
+
+/**
+ * A provider's time-boxed, read-only break-glass access to a patient's emergency
+ * categories. Every session waits in the patient's review queue until confirmed or
+ * flagged as abuse.
+ */
+export interface EmergencySession {
+	id: string;
+	patientId: EthAddress;
+	provider: EthAddress;
+	justification: string;
+	categories: DataCategory[];
+	startedAt: Date;
+	expiresAt: Date;
+	endedAt?: Date;
+	endedBy?: EthAddress;
+	review: EmergencyReview;
+	reviewedAt?: Date;
+	reviewNote?: string;
+}
+
+/**
+ * What a patient lets providers read in an emergency. Disabled profiles refuse
+ * break-glass entirely.
+ */
+export interface EmergencyProfile {
+	patientId: EthAddress;
+	disabled: boolean;
+	categories: DataCategory[];
+}
+
+/**
+ * Payload for breaking glass on a patient's record.
+ */
+export interface BreakGlassPayload {
+	patientId: EthAddress;
+	justification: string;
+}
@@ -6,7 +6,9 @@ export type NotificationType =
 	| "consent_request_received"
 	| "consent_granted"
 	| "consent_revoked"
+	| "consent_expiring"
 	| "consent_expired"
+	| "emergency_access"
 	| "record_shared";
 
 export interface Notification {
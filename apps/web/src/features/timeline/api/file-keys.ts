@@ -1,4 +1,5 @@
 import { apiClient } from "@/lib/api-client";
+import type { EventFile, PendingRekey } from "../types";
 
 export interface FileKeyResponse {
   wrappedKey: string;
@@ -33,3 +34,35 @@ export const shareFileKey = (payload: ShareFilePayload) => {
     },
   });
 };
+
+export const getPendingRekeys = (): Promise<{ files: PendingRekey[] }> => {
+  return apiClient("/api/timeline/files/pending-rekeys");
+};
+
+export interface RekeyFilePayload {
+  eventId: string;
+  fileId: string;
+  /** The file re-encrypted under a new data key. */
+  file: File | Blob;
+  wrappedKey: string;
+  metadata?: Record<string, unknown>;
+  /** The new data key wrapped for each grantee who keeps access; everyone else is dropped. */
+  shares?: Record<string, string>;
+}
+
+export const rekeyFile = (payload: RekeyFilePayload): Promise<{ file: EventFile }> => {
+  const formData = new FormData();
+  formData.append("file", payload.file);
+  formData.append("wrappedKey", payload.wrappedKey);
+  if (payload.metadata) {
+    formData.append("metadata", JSON.stringify(payload.metadata));
+  }
+  if (payload.shares) {
+    formData.append("shares", JSON.stringify(payload.shares));
+  }
+
+  return apiClient(`/api/timeline/events/${payload.eventId}/files/${payload.fileId}/rekey`, {
+    method: "POST",
+    body: formData,
+  });
+};
@@ -262,10 +262,18 @@ export interface EventFile {
 	readonly mimeType: string;
 	readonly fileSize: number;
 	readonly wrappedDek?: string;
+	/** Bumped each time the patient re-keys the file. */
+	readonly keyVersion: number;
 	readonly metadata?: Record<string, unknown>;
 	readonly createdAt: string;
 }
 
+/** A file whose data key grantees fetched before their consent was revoked or expired. */
+export interface PendingRekey {
+	readonly file: EventFile;
+	readonly grantees: readonly string[];
+}
+
 // =============================================================================
 // API RESPONSES
 // =============================================================================
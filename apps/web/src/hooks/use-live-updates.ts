@@ -0,0 +1,29 @@
+import { useQueryClient } from "@tanstack/react-query";
+import { useEffect } from "react";
+import { useAuth } from "@/features/auth/hooks/use-auth";
+import { openEventStream, StreamEventKind } from "@/lib/event-stream";
+
+/**
+ * Keeps audit, consent and timeline queries fresh from the server's event stream
+ * while the user is signed in, instead of polling.
+ */
+export function useLiveUpdates() {
+	const queryClient = useQueryClient();
+	const { isAuthenticated } = useAuth();
+
+	useEffect(() => {
+		if (!isAuthenticated) return;
+
+		return openEventStream((kind, message) => {
+			if (message.entry || kind === StreamEventKind.Reset) {
+				void queryClient.invalidateQueries({ queryKey: ["audit-logs"] });
+			}
+			if (kind === StreamEventKind.Consent || kind === StreamEventKind.Reset) {
+				void queryClient.invalidateQueries({ queryKey: ["consent", "grants"] });
+			}
+			if (kind === StreamEventKind.Timeline || kind === StreamEventKind.Reset) {
+				void queryClient.invalidateQueries({ queryKey: ["timeline"] });
+			}
+		});
+	}, [isAuthenticated, queryClient]);
+}
@@ -1,16 +1,26 @@
 export const API_URL = import.meta.env.VITE_API_URL || "";
 
+/**
+ * Header a guardian or caregiver sends to act as the patient they hold a
+ * delegation from.
+ */
+export const ACT_AS_HEADER = "X-Act-As";
+
 export const apiClient = async (
 	endpoint: string,
 	{
 		body,
+		actAs,
 		...customConfig
-	}: Omit<RequestInit, "body"> & { body?: unknown } = {},
+	}: Omit<RequestInit, "body"> & { body?: unknown; actAs?: string } = {},
 ) => {
 	const isFormData = body instanceof FormData;
-	const headers: HeadersInit = isFormData
+	const headers: Record<string, string> = isFormData
 		? {}
 		: { "Content-Type": "application/json" };
+	if (actAs) {
+		headers[ACT_AS_HEADER] = actAs;
+	}
 
 	const config: RequestInit = {
 		method: body ? "POST" : "GET",
@@ -0,0 +1,44 @@
+import { API_URL } from "./api-client";
+
+/** Event types sent by `GET /api/stream`. */
+export const StreamEventKind = {
+	Audit: "audit",
+	Consent: "consent",
+	Timeline: "timeline",
+	Reset: "reset",
+} as const;
+
+export type StreamEventKind =
+	(typeof StreamEventKind)[keyof typeof StreamEventKind];
+
+/**
+ * Payload of a stream event. `entry` is set only when the user may read the audit
+ * entry itself; `grant` and `event` carry the current state of what it changed.
+ */
+export interface StreamMessage {
+	entry?: Record<string, unknown>;
+	grant?: Record<string, unknown>;
+	event?: Record<string, unknown>;
+}
+
+/**
+ * Opens the live update stream. The browser reconnects on its own and resumes with
+ * Last-Event-ID; a `reset` event means updates were missed and data should be refetched.
+ * Returns a function that closes the stream.
+ */
+export const openEventStream = (
+	onMessage: (kind: StreamEventKind, message: StreamMessage) => void,
+): (() => void) => {
+	const source = new EventSource(`${API_URL}/api/stream`, {
+		withCredentials: true,
+	});
+
+	for (const kind of Object.values(StreamEventKind)) {
+		source.addEventListener(kind, (event) => {
+			const data = (event as MessageEvent<string>).data;
+			onMessage(kind, data ? (JSON.parse(data) as StreamMessage) : {});
+		});
+	}
+
+	return () => source.close();
+};
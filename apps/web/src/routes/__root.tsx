@@ -3,6 +3,7 @@ import { useEffect } from "react";
 import { Toaster } from "sonner";
 import { AuthProvider } from "@/features/auth/context/auth-context";
 import { VaultProvider } from "@/features/auth/contexts/vault-context";
+import { useLiveUpdates } from "@/hooks/use-live-updates";
 
 export const Route = createRootRoute({
 	component: RootComponent,
@@ -19,9 +20,15 @@ function RootComponent() {
 	return (
 		<AuthProvider>
 			<VaultProvider>
+				<LiveUpdates />
 				<Outlet />
 				<Toaster richColors position="top-right" />
 			</VaultProvider>
 		</AuthProvider>
 	);
 }
+
+function LiveUpdates() {
+	useLiveUpdates();
+	return null;
+}
@@ -0,0 +1,40 @@
+package attestation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+)
+
+// SignatureAlgorithmEIP191 identifies a wallet personal_sign signature over SigningMessage.
+const SignatureAlgorithmEIP191 = "EIP191"
+
+// SigningMessage returns the text the attester signs with their wallet. It binds the
+// attestation ID, the attested event and its hash, the attestation type, the attester
+// and the expiry, so none of them can be changed without invalidating the signature.
+func (a *Attestation) SigningMessage() string {
+	expires := "never"
+	if a.ExpiresAt != nil {
+		expires = a.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	var b strings.Builder
+	b.WriteString("Fleming Provider Attestation\n")
+	fmt.Fprintf(&b, "Attestation: %s\n", a.ID)
+	fmt.Fprintf(&b, "Event: %s\n", a.EventID)
+	fmt.Fprintf(&b, "Event Hash: %s\n", a.EventHash)
+	fmt.Fprintf(&b, "Type: %s\n", a.Type)
+	fmt.Fprintf(&b, "Attester: %s\n", a.Attester)
+	fmt.Fprintf(&b, "Expires: %s", expires)
+	return b.String()
+}
+
+// VerifySignature reports whether Signature is the attester's signature over SigningMessage.
+func (a *Attestation) VerifySignature() bool {
+	if a.SignatureAlgorithm != SignatureAlgorithmEIP191 {
+		return false
+	}
+	return crypto.VerifySignature(a.SigningMessage(), a.Signature, a.Attester.String())
+}
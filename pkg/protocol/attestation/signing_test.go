@@ -0,0 +1,80 @@
+package attestation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+func signedAttestation(t *testing.T) *Attestation {
+	t.Helper()
+
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	attester, _ := types.NewWalletAddress(ethcrypto.PubkeyToAddress(key.PublicKey).Hex())
+	eventID, _ := types.NewID("event-1")
+	expiresAt := time.Now().Add(24 * time.Hour)
+
+	att := NewAttestationBuilder().
+		WithEventID(eventID).
+		WithEventHash("9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08").
+		WithAttester(attester).
+		WithType(AttestVerified).
+		WithExpiresAt(expiresAt).
+		MustBuild()
+
+	sig, err := crypto.SignMessage(att.SigningMessage(), key)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	att.Signature = sig
+	att.SignatureAlgorithm = SignatureAlgorithmEIP191
+	return att
+}
+
+func TestAttestation_SigningMessage(t *testing.T) {
+	att := signedAttestation(t)
+	msg := att.SigningMessage()
+
+	for _, want := range []string{att.ID.String(), att.EventID.String(), att.EventHash, string(att.Type), att.Attester.String()} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("SigningMessage() missing %q", want)
+		}
+	}
+
+	att.ExpiresAt = nil
+	if !strings.HasSuffix(att.SigningMessage(), "Expires: never") {
+		t.Error("SigningMessage() should state that an attestation without expiry never expires")
+	}
+}
+
+func TestAttestation_VerifySignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(a *Attestation)
+		want   bool
+	}{
+		{"valid", func(a *Attestation) {}, true},
+		{"event changed", func(a *Attestation) { a.EventHash = "00" }, false},
+		{"type changed", func(a *Attestation) { a.Type = AttestGenerated }, false},
+		{"expiry extended", func(a *Attestation) { later := a.ExpiresAt.Add(time.Hour); a.ExpiresAt = &later }, false},
+		{"other attester", func(a *Attestation) { a.Attester = "0x1111111111111111111111111111111111111111" }, false},
+		{"unknown algorithm", func(a *Attestation) { a.SignatureAlgorithm = "ES256K" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			att := signedAttestation(t)
+			tt.mutate(att)
+			if got := att.VerifySignature(); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -134,7 +134,7 @@ type Attestation struct {
 	// EventID is the timeline event being attested
 	EventID types.ID `json:"eventId"`
 
-	// EventHash is the hash of the event at the time of attestation
+	// EventHash is the hash of the event at the time of attestation (timeline.Event.Hash)
 	// This ensures the attestation is bound to a specific version
 	EventHash string `json:"eventHash"`
 
@@ -125,6 +125,11 @@ func RegisterDefaultActions() {
 			Description: "Resume suspended consent grant",
 			Since:       "0.1.0",
 		},
+		ActionConsentExpiring: {
+			Name:        "Consent Expiring",
+			Description: "Consent grant expires soon",
+			Since:       "0.1.0",
+		},
 
 		// Authentication
 		ActionLogin: {
@@ -154,6 +159,16 @@ func RegisterDefaultActions() {
 			Description: "Share file access",
 			Since:       "0.1.0",
 		},
+		ActionUnshare: {
+			Name:        "File Unshare",
+			Description: "Disable file keys shared with a grantee whose consent ended",
+			Since:       "0.1.0",
+		},
+		ActionRekey: {
+			Name:        "File Rekey",
+			Description: "Re-encrypt a file under a new data key",
+			Since:       "0.1.0",
+		},
 
 		// Verifiable Credentials
 		ActionVCIssue: {
@@ -200,6 +215,82 @@ func RegisterDefaultActions() {
 			Description: "Provider attests to accuracy of an event",
 			Since:       "0.1.0",
 		},
+		ActionAttestationRequest: {
+			Name:        "Attestation Request",
+			Description: "Patient asks a provider to attest an event",
+			Since:       "0.1.0",
+		},
+		ActionAttestationRevoke: {
+			Name:        "Attestation Revoke",
+			Description: "Revoke an attestation or withdraw a pending request",
+			Since:       "0.1.0",
+		},
+		ActionAttestationExpire: {
+			Name:        "Attestation Expire",
+			Description: "Attestation reached its expiry",
+			Since:       "0.1.0",
+		},
+
+		// Access monitoring
+		ActionAccessDeny: {
+			Name:        "Access Deny",
+			Description: "Access to patient data refused by consent checks",
+			Since:       "0.1.0",
+		},
+		ActionAlertRaise: {
+			Name:        "Alert Raise",
+			Description: "Suspicious access pattern flagged",
+			Since:       "0.1.0",
+		},
+		ActionAlertResolve: {
+			Name:        "Alert Resolve",
+			Description: "Patient answered a suspicious access alert",
+			Since:       "0.1.0",
+		},
+
+		// Delegation
+		ActionDelegationCreate: {
+			Name:        "Delegation Create",
+			Description: "Patient named a guardian or caregiver to act for them",
+			Since:       "0.1.0",
+		},
+		ActionDelegationSign: {
+			Name:        "Delegation Sign",
+			Description: "Patient signed a delegation, activating it",
+			Since:       "0.1.0",
+		},
+		ActionDelegationRevoke: {
+			Name:        "Delegation Revoke",
+			Description: "Delegation ended by the patient or the delegate",
+			Since:       "0.1.0",
+		},
+		ActionDelegationExpire: {
+			Name:        "Delegation Expire",
+			Description: "Delegation reached its expiry",
+			Since:       "0.1.0",
+		},
+
+		// Emergency (break-glass) access
+		ActionEmergencyAccess: {
+			Name:        "Emergency Access",
+			Description: "Provider broke glass to read a patient's emergency data without consent",
+			Since:       "0.1.0",
+		},
+		ActionEmergencyEnd: {
+			Name:        "Emergency End",
+			Description: "Break-glass session ended before its expiry",
+			Since:       "0.1.0",
+		},
+		ActionEmergencyReview: {
+			Name:        "Emergency Review",
+			Description: "Patient confirmed a break-glass session or flagged it as abuse",
+			Since:       "0.1.0",
+		},
+		ActionEmergencyProfile: {
+			Name:        "Emergency Profile",
+			Description: "Patient changed what emergency access may read",
+			Since:       "0.1.0",
+		},
 	})
 }
 
@@ -249,5 +340,31 @@ func RegisterDefaultResourceTypes() {
 			Description: "Provider attestation",
 			Since:       "0.1.0",
 		},
+
+		// Access monitoring
+		ResourceAlert: {
+			Name:        "Alert",
+			Description: "Suspicious access alert",
+			Since:       "0.1.0",
+		},
+
+		// Delegation
+		ResourceDelegation: {
+			Name:        "Delegation",
+			Description: "Guardian or caregiver delegation",
+			Since:       "0.1.0",
+		},
+
+		// Emergency access
+		ResourceEmergencyAccess: {
+			Name:        "Emergency Access",
+			Description: "Break-glass session",
+			Since:       "0.1.0",
+		},
+		ResourceEmergencyProfile: {
+			Name:        "Emergency Profile",
+			Description: "Patient's emergency access categories",
+			Since:       "0.1.0",
+		},
 	})
 }
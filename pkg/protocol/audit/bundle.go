@@ -0,0 +1,394 @@
+package audit
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// GenesisHash is the previous hash of the first entry in the chain.
+const GenesisHash = "GENESIS"
+
+// BundleFormatV1 identifies the first audit export bundle layout.
+const BundleFormatV1 = "fleming.audit-bundle.v1"
+
+// Files inside an export bundle. The manifest records the SHA-256 of each JSONL file.
+const (
+	BundleManifestFile = "manifest.json"
+	BundleEntriesFile  = "entries.jsonl"
+	BundleBatchesFile  = "batches.jsonl"
+	BundleProofsFile   = "proofs.jsonl"
+)
+
+var (
+	ErrBundleFormat          = errors.New("audit: unsupported bundle format")
+	ErrBundleMissingManifest = errors.New("audit: bundle has no manifest")
+)
+
+// BundleManifest describes an export bundle.
+type BundleManifest struct {
+	Format    string     `json:"format"`
+	CreatedAt time.Time  `json:"createdAt"`
+	From      *time.Time `json:"from,omitempty"` // Export window; nil bounds are open
+	To        *time.Time `json:"to,omitempty"`
+
+	// Actor is set when the bundle holds only one actor's entries. Such bundles are not a
+	// contiguous slice of the chain, so only hashes and inclusion are checkable.
+	Actor string `json:"actor,omitempty"`
+
+	// PrecedingHash is the hash the first entry links to: GenesisHash when the bundle
+	// starts the chain, otherwise the hash of the entry just before From.
+	PrecedingHash string `json:"precedingHash,omitempty"`
+
+	EntryCount int `json:"entryCount"`
+	BatchCount int `json:"batchCount"`
+	ProofCount int `json:"proofCount"`
+
+	Anchoring *AnchorNetwork `json:"anchoring,omitempty"`
+
+	Files map[string]string `json:"files"` // File name to hex SHA-256
+}
+
+// AnchorNetwork identifies the contract batch roots are anchored to.
+type AnchorNetwork struct {
+	ChainID  uint64 `json:"chainId"`
+	Contract string `json:"contract"`
+}
+
+// AnchorReference locates a batch root on-chain.
+type AnchorReference struct {
+	TxHash      string     `json:"txHash"`
+	BlockNumber uint64     `json:"blockNumber"`
+	AnchoredAt  *time.Time `json:"anchoredAt,omitempty"`
+}
+
+// BundleBatch is a Merkle batch root that bundle proofs refer to.
+type BundleBatch struct {
+	ID        string           `json:"id"`
+	Root      string           `json:"root"`
+	Scheme    MerkleScheme     `json:"scheme"`
+	StartTime time.Time        `json:"startTime"`
+	EndTime   time.Time        `json:"endTime"`
+	TreeSize  int              `json:"treeSize"`
+	Anchor    *AnchorReference `json:"anchor,omitempty"` // Nil until the root is anchored
+}
+
+// BundleProof proves a bundle entry is included in a bundle batch.
+type BundleProof struct {
+	EntryID   string `json:"entryId"`
+	BatchID   string `json:"batchId"`
+	LeafIndex int    `json:"leafIndex"`
+	Proof     *Proof `json:"proof"`
+}
+
+// Bundle is a self-verifying audit export: entries in chain order, the batch roots
+// covering them, and an inclusion proof per batched entry.
+type Bundle struct {
+	Manifest BundleManifest
+	Entries  []Entry
+	Batches  []BundleBatch
+	Proofs   []BundleProof
+}
+
+// WriteBundle writes the bundle as a tar archive of a JSON manifest and JSONL files,
+// filling in the manifest's counts and file digests.
+func WriteBundle(w io.Writer, bundle *Bundle) error {
+	files := make(map[string][]byte, 3)
+	var err error
+	if files[BundleEntriesFile], err = encodeJSONL(bundle.Entries); err != nil {
+		return fmt.Errorf("encode bundle entries: %w", err)
+	}
+	if files[BundleBatchesFile], err = encodeJSONL(bundle.Batches); err != nil {
+		return fmt.Errorf("encode bundle batches: %w", err)
+	}
+	if files[BundleProofsFile], err = encodeJSONL(bundle.Proofs); err != nil {
+		return fmt.Errorf("encode bundle proofs: %w", err)
+	}
+
+	manifest := bundle.Manifest
+	manifest.Format = BundleFormatV1
+	manifest.EntryCount = len(bundle.Entries)
+	manifest.BatchCount = len(bundle.Batches)
+	manifest.ProofCount = len(bundle.Proofs)
+	manifest.Files = make(map[string]string, len(files))
+	for name, data := range files {
+		manifest.Files[name] = sha256Hex(data)
+	}
+	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode bundle manifest: %w", err)
+	}
+	bundle.Manifest = manifest
+
+	tw := tar.NewWriter(w)
+	modTime := manifest.CreatedAt
+	for _, name := range []string{BundleManifestFile, BundleEntriesFile, BundleBatchesFile, BundleProofsFile} {
+		data := manifestJSON
+		if name != BundleManifestFile {
+			data = files[name]
+		}
+		header := &tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), ModTime: modTime}
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("write bundle %s: %w", name, err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("write bundle %s: %w", name, err)
+		}
+	}
+	return tw.Close()
+}
+
+// ReadBundle reads a bundle written by WriteBundle, optionally gzip-compressed. It
+// returns the raw file contents alongside the decoded bundle so digests can be checked.
+func ReadBundle(r io.Reader) (*Bundle, map[string][]byte, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	} else {
+		r = br
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read bundle %s: %w", header.Name, err)
+		}
+		files[header.Name] = data
+	}
+
+	manifestJSON, ok := files[BundleManifestFile]
+	if !ok {
+		return nil, nil, ErrBundleMissingManifest
+	}
+	bundle := &Bundle{}
+	if err := json.Unmarshal(manifestJSON, &bundle.Manifest); err != nil {
+		return nil, nil, fmt.Errorf("decode bundle manifest: %w", err)
+	}
+	if bundle.Manifest.Format != BundleFormatV1 {
+		return nil, nil, fmt.Errorf("%w: %q", ErrBundleFormat, bundle.Manifest.Format)
+	}
+	if err := decodeJSONL(files[BundleEntriesFile], &bundle.Entries); err != nil {
+		return nil, nil, fmt.Errorf("decode bundle entries: %w", err)
+	}
+	if err := decodeJSONL(files[BundleBatchesFile], &bundle.Batches); err != nil {
+		return nil, nil, fmt.Errorf("decode bundle batches: %w", err)
+	}
+	if err := decodeJSONL(files[BundleProofsFile], &bundle.Proofs); err != nil {
+		return nil, nil, fmt.Errorf("decode bundle proofs: %w", err)
+	}
+	return bundle, files, nil
+}
+
+// BundleCheck names one of the checks VerifyBundle performs.
+type BundleCheck string
+
+const (
+	CheckFileDigest    BundleCheck = "file_digest"
+	CheckCounts        BundleCheck = "counts"
+	CheckEntryHash     BundleCheck = "entry_hash"
+	CheckChainLink     BundleCheck = "chain_link"
+	CheckSchemeVersion BundleCheck = "scheme_downgrade"
+	CheckEntryActor    BundleCheck = "entry_actor"
+	CheckInclusion     BundleCheck = "inclusion"
+)
+
+// BundleFailure is one failed check.
+type BundleFailure struct {
+	Check   BundleCheck `json:"check"`
+	EntryID string      `json:"entryId,omitempty"`
+	BatchID string      `json:"batchId,omitempty"`
+	Detail  string      `json:"detail"`
+}
+
+// BundleReport is the outcome of VerifyBundle.
+type BundleReport struct {
+	Valid         bool            `json:"valid"`
+	Entries       int             `json:"entries"`
+	ChainChecked  bool            `json:"chainChecked"` // False for single-actor bundles
+	Batches       int             `json:"batches"`
+	Proven        int             `json:"proven"`   // Entries with a valid inclusion proof
+	Unproven      int             `json:"unproven"` // Entries not yet in any batch
+	Anchored      []BundleBatch   `json:"anchored"` // Batches whose roots can be checked on-chain
+	Anchoring     *AnchorNetwork  `json:"anchoring,omitempty"`
+	PrecedingHash string          `json:"precedingHash,omitempty"`
+	Failures      []BundleFailure `json:"failures"`
+}
+
+func (r *BundleReport) fail(check BundleCheck, entryID, batchID, format string, args ...any) {
+	r.Failures = append(r.Failures, BundleFailure{
+		Check:   check,
+		EntryID: entryID,
+		BatchID: batchID,
+		Detail:  fmt.Sprintf(format, args...),
+	})
+}
+
+// VerifyBundle checks a bundle without any outside information: file digests against
+// the manifest, every entry hash, chain continuity from PrecedingHash, and every
+// inclusion proof against its batch root. Anchored roots are listed for the caller to
+// compare with the chain.
+func VerifyBundle(bundle *Bundle, files map[string][]byte) *BundleReport {
+	manifest := bundle.Manifest
+	report := &BundleReport{
+		Entries:       len(bundle.Entries),
+		ChainChecked:  manifest.Actor == "",
+		Batches:       len(bundle.Batches),
+		Anchored:      []BundleBatch{},
+		Anchoring:     manifest.Anchoring,
+		PrecedingHash: manifest.PrecedingHash,
+		Failures:      []BundleFailure{},
+	}
+
+	if files != nil {
+		names := make([]string, 0, len(manifest.Files))
+		for name := range manifest.Files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			data, ok := files[name]
+			if !ok {
+				report.fail(CheckFileDigest, "", "", "%s is listed in the manifest but missing", name)
+				continue
+			}
+			if got := sha256Hex(data); got != manifest.Files[name] {
+				report.fail(CheckFileDigest, "", "", "%s digest %s does not match manifest %s", name, got, manifest.Files[name])
+			}
+		}
+	}
+	if manifest.EntryCount != len(bundle.Entries) || manifest.BatchCount != len(bundle.Batches) || manifest.ProofCount != len(bundle.Proofs) {
+		report.fail(CheckCounts, "", "", "manifest lists %d entries, %d batches, %d proofs; bundle has %d, %d, %d",
+			manifest.EntryCount, manifest.BatchCount, manifest.ProofCount, len(bundle.Entries), len(bundle.Batches), len(bundle.Proofs))
+	}
+
+	entries := make(map[string]*Entry, len(bundle.Entries))
+	upgraded := false
+	for i := range bundle.Entries {
+		entry := &bundle.Entries[i]
+		id := entry.ID.String()
+		entries[id] = entry
+
+		if computed := entry.ComputeHash(); computed != entry.Hash {
+			report.fail(CheckEntryHash, id, "", "stored hash %s, recomputed %s under %s", entry.Hash, computed, entry.HashScheme())
+		}
+
+		if !report.ChainChecked {
+			if entry.Actor.String() != manifest.Actor {
+				report.fail(CheckEntryActor, id, "", "entry actor %s is not the bundle actor %s", entry.Actor, manifest.Actor)
+			}
+			continue
+		}
+
+		// Once audit.v2 entries appear, a later legacy entry can only be a rewrite.
+		if entry.HashScheme() == SchemaVersionAuditV2 {
+			upgraded = true
+		} else if upgraded {
+			report.fail(CheckSchemeVersion, id, "", "%s entry follows %s entries", entry.HashScheme(), SchemaVersionAuditV2)
+		}
+
+		expected := manifest.PrecedingHash
+		if i > 0 {
+			expected = bundle.Entries[i-1].Hash
+		}
+		if entry.PreviousHash != expected {
+			report.fail(CheckChainLink, id, "", "links to %s, expected %s", entry.PreviousHash, expected)
+		}
+	}
+
+	batches := make(map[string]*BundleBatch, len(bundle.Batches))
+	for i := range bundle.Batches {
+		batch := &bundle.Batches[i]
+		batches[batch.ID] = batch
+		if batch.Anchor != nil {
+			report.Anchored = append(report.Anchored, *batch)
+		}
+	}
+
+	proven := make(map[string]bool, len(bundle.Proofs))
+	for _, proof := range bundle.Proofs {
+		entry, ok := entries[proof.EntryID]
+		if !ok {
+			report.fail(CheckInclusion, proof.EntryID, proof.BatchID, "proof for an entry that is not in the bundle")
+			continue
+		}
+		batch, ok := batches[proof.BatchID]
+		if !ok {
+			report.fail(CheckInclusion, proof.EntryID, proof.BatchID, "proof refers to a batch that is not in the bundle")
+			continue
+		}
+		if proof.Proof == nil || proof.Proof.EntryHash != entry.Hash {
+			report.fail(CheckInclusion, proof.EntryID, proof.BatchID, "proof is not for the entry's hash")
+			continue
+		}
+		if proof.Proof.Scheme.Normalize() != batch.Scheme.Normalize() {
+			report.fail(CheckInclusion, proof.EntryID, proof.BatchID, "proof scheme %s does not match batch scheme %s", proof.Proof.Scheme.Normalize(), batch.Scheme.Normalize())
+			continue
+		}
+		if !VerifyProof(batch.Root, entry.Hash, proof.Proof) {
+			report.fail(CheckInclusion, proof.EntryID, proof.BatchID, "proof does not lead to root %s", batch.Root)
+			continue
+		}
+		proven[proof.EntryID] = true
+	}
+	report.Proven = len(proven)
+	report.Unproven = len(bundle.Entries) - len(proven)
+
+	report.Valid = len(report.Failures) == 0
+	return report
+}
+
+func encodeJSONL[T any](items []T) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	for i := range items {
+		if err := enc.Encode(&items[i]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeJSONL[T any](data []byte, items *[]T) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for line := 1; ; line++ {
+		var item T
+		if err := dec.Decode(&item); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		*items = append(*items, item)
+	}
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,235 @@
+package audit
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// newTestBundle builds a chain of n entries, a merkle.v2 batch over the first batched
+// of them, and a proof for each batched entry.
+func newTestBundle(t *testing.T, n, batched int) *Bundle {
+	t.Helper()
+	actor := types.WalletAddress("0x1234567890abcdef1234567890abcdef12345678")
+	start := time.Date(2026, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	bundle := &Bundle{Manifest: BundleManifest{CreatedAt: start.Add(time.Hour), PrecedingHash: GenesisHash}}
+	previous := GenesisHash
+	for i := 0; i < n; i++ {
+		entry := Entry{
+			ID:            types.ID(fmt.Sprintf("entry-%d", i)),
+			Actor:         actor,
+			Action:        ActionRead,
+			ResourceType:  ResourceEvent,
+			ResourceID:    types.ID(fmt.Sprintf("event-%d", i)),
+			Timestamp:     start.Add(time.Duration(i) * time.Minute),
+			Metadata:      types.Metadata{"index": i, "tags": []string{"lab"}},
+			SchemaVersion: SchemaVersionAuditV2,
+			PreviousHash:  previous,
+		}
+		entry.SetHash()
+		previous = entry.Hash
+		bundle.Entries = append(bundle.Entries, entry)
+	}
+
+	if batched == 0 {
+		return bundle
+	}
+	tree, err := BuildMerkleTreeWithScheme(bundle.Entries[:batched], MerkleSchemeV2)
+	if err != nil {
+		t.Fatalf("BuildMerkleTreeWithScheme() error = %v", err)
+	}
+	anchoredAt := start.Add(2 * time.Hour)
+	bundle.Batches = []BundleBatch{{
+		ID:        "batch-1",
+		Root:      tree.Root,
+		Scheme:    MerkleSchemeV2,
+		StartTime: start,
+		EndTime:   start.Add(time.Hour),
+		TreeSize:  batched,
+		Anchor:    &AnchorReference{TxHash: "0xabc", BlockNumber: 42, AnchoredAt: &anchoredAt},
+	}}
+	for i := 0; i < batched; i++ {
+		proof, err := GenerateProofAt(tree, i)
+		if err != nil {
+			t.Fatalf("GenerateProofAt(%d) error = %v", i, err)
+		}
+		bundle.Proofs = append(bundle.Proofs, BundleProof{
+			EntryID:   bundle.Entries[i].ID.String(),
+			BatchID:   "batch-1",
+			LeafIndex: i,
+			Proof:     proof,
+		})
+	}
+	return bundle
+}
+
+func roundTrip(t *testing.T, bundle *Bundle) (*Bundle, map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteBundle(&buf, bundle); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	read, files, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	return read, files
+}
+
+func TestBundle_RoundTripVerifies(t *testing.T) {
+	read, files := roundTrip(t, newTestBundle(t, 7, 5))
+
+	report := VerifyBundle(read, files)
+	if !report.Valid {
+		t.Fatalf("VerifyBundle() failures = %+v", report.Failures)
+	}
+	if report.Entries != 7 || report.Proven != 5 || report.Unproven != 2 || !report.ChainChecked {
+		t.Errorf("VerifyBundle() = %+v", report)
+	}
+	if len(report.Anchored) != 1 || report.Anchored[0].Anchor.TxHash != "0xabc" {
+		t.Errorf("anchored = %+v", report.Anchored)
+	}
+}
+
+func TestBundle_ReadsGzip(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := WriteBundle(gz, newTestBundle(t, 3, 3)); err != nil {
+		t.Fatalf("WriteBundle() error = %v", err)
+	}
+	gz.Close()
+
+	read, files, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("ReadBundle() error = %v", err)
+	}
+	if report := VerifyBundle(read, files); !report.Valid {
+		t.Fatalf("VerifyBundle() failures = %+v", report.Failures)
+	}
+}
+
+func TestBundle_DetectsTampering(t *testing.T) {
+	hasFailure := func(report *BundleReport, check BundleCheck, entryID string) bool {
+		for _, failure := range report.Failures {
+			if failure.Check == check && (entryID == "" || failure.EntryID == entryID) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("edited metadata", func(t *testing.T) {
+		bundle, _ := roundTrip(t, newTestBundle(t, 4, 4))
+		bundle.Entries[2].Metadata["index"] = 99
+		report := VerifyBundle(bundle, nil)
+		if report.Valid || !hasFailure(report, CheckEntryHash, "entry-2") {
+			t.Errorf("failures = %+v, want entry_hash on entry-2", report.Failures)
+		}
+	})
+
+	t.Run("removed entry", func(t *testing.T) {
+		bundle, _ := roundTrip(t, newTestBundle(t, 4, 0))
+		bundle.Entries = append(bundle.Entries[:1], bundle.Entries[2:]...)
+		bundle.Manifest.EntryCount--
+		report := VerifyBundle(bundle, nil)
+		if report.Valid || !hasFailure(report, CheckChainLink, "entry-2") {
+			t.Errorf("failures = %+v, want chain_link on entry-2", report.Failures)
+		}
+	})
+
+	t.Run("rehashed entry", func(t *testing.T) {
+		// Recomputing the edited entry's hash still breaks the next link.
+		bundle, _ := roundTrip(t, newTestBundle(t, 4, 0))
+		bundle.Entries[1].ResourceID = "event-forged"
+		bundle.Entries[1].SetHash()
+		report := VerifyBundle(bundle, nil)
+		if report.Valid || !hasFailure(report, CheckChainLink, "entry-2") {
+			t.Errorf("failures = %+v, want chain_link on entry-2", report.Failures)
+		}
+	})
+
+	t.Run("wrong preceding hash", func(t *testing.T) {
+		bundle, _ := roundTrip(t, newTestBundle(t, 2, 0))
+		bundle.Manifest.PrecedingHash = "deadbeef"
+		if report := VerifyBundle(bundle, nil); !hasFailure(report, CheckChainLink, "entry-0") {
+			t.Errorf("failures = %+v, want chain_link on entry-0", report.Failures)
+		}
+	})
+
+	t.Run("swapped root", func(t *testing.T) {
+		bundle, _ := roundTrip(t, newTestBundle(t, 4, 4))
+		bundle.Batches[0].Root = bundle.Entries[0].Hash
+		report := VerifyBundle(bundle, nil)
+		if report.Valid || !hasFailure(report, CheckInclusion, "") || report.Proven != 0 {
+			t.Errorf("report = %+v, want inclusion failures", report)
+		}
+	})
+
+	t.Run("edited file", func(t *testing.T) {
+		bundle := newTestBundle(t, 3, 3)
+		var buf bytes.Buffer
+		if err := WriteBundle(&buf, bundle); err != nil {
+			t.Fatalf("WriteBundle() error = %v", err)
+		}
+		_, files, err := ReadBundle(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("ReadBundle() error = %v", err)
+		}
+		files[BundleBatchesFile] = append(files[BundleBatchesFile], '\n')
+		if report := VerifyBundle(bundle, files); !hasFailure(report, CheckFileDigest, "") {
+			t.Errorf("failures = %+v, want file_digest", report.Failures)
+		}
+	})
+}
+
+func TestBundle_SingleActor(t *testing.T) {
+	bundle := newTestBundle(t, 5, 5)
+	// Keep every other entry: the chain is no longer contiguous, but hashes and
+	// inclusion still verify.
+	bundle.Manifest.Actor = bundle.Entries[0].Actor.String()
+	bundle.Manifest.PrecedingHash = ""
+	bundle.Entries = []Entry{bundle.Entries[0], bundle.Entries[2], bundle.Entries[4]}
+	bundle.Proofs = []BundleProof{bundle.Proofs[0], bundle.Proofs[2], bundle.Proofs[4]}
+
+	read, files := roundTrip(t, bundle)
+	report := VerifyBundle(read, files)
+	if !report.Valid || report.ChainChecked || report.Proven != 3 {
+		t.Fatalf("VerifyBundle() = %+v", report)
+	}
+
+	read.Entries[1].Actor = "0x0000000000000000000000000000000000000001"
+	read.Entries[1].SetHash()
+	if report := VerifyBundle(read, nil); report.Valid {
+		t.Error("VerifyBundle() accepted another actor's entry")
+	}
+}
+
+func TestReadBundle_Errors(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data := []byte(`{"format":"something.else"}`)
+	tw.WriteHeader(&tar.Header{Name: BundleManifestFile, Mode: 0o644, Size: int64(len(data))})
+	tw.Write(data)
+	tw.Close()
+
+	if _, _, err := ReadBundle(bytes.NewReader(buf.Bytes())); err == nil {
+		t.Error("ReadBundle() accepted an unknown format")
+	}
+
+	buf.Reset()
+	tar.NewWriter(&buf).Close()
+	if _, _, err := ReadBundle(&buf); err != ErrBundleMissingManifest {
+		t.Errorf("ReadBundle() error = %v, want %v", err, ErrBundleMissingManifest)
+	}
+
+	if _, _, err := ReadBundle(io.LimitReader(bytes.NewReader([]byte("not a tar archive at all")), 10)); err == nil {
+		t.Error("ReadBundle() accepted garbage")
+	}
+}
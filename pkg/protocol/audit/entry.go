@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -12,6 +13,13 @@ import (
 
 const SchemaVersionAudit = protocol.SchemaVersionAudit
 
+// SchemaVersionAuditV1 is the legacy hash scheme. Its hash does not commit to metadata;
+// entries written under it remain verifiable but their metadata is not tamper-evident.
+const SchemaVersionAuditV1 = "audit.v1"
+
+// SchemaVersionAuditV2 commits to the schema version and canonicalized metadata.
+const SchemaVersionAuditV2 = "audit.v2"
+
 type Action string
 
 const (
@@ -29,6 +37,8 @@ const (
 	ActionConsentExpire  Action = "consent.expire"
 	ActionConsentSuspend Action = "consent.suspend"
 	ActionConsentResume  Action = "consent.resume"
+	// ActionConsentExpiring notifies both parties that a grant expires soon.
+	ActionConsentExpiring Action = "consent.expiring"
 
 	// Authentication
 	ActionLogin  Action = "auth.login"
@@ -38,6 +48,8 @@ const (
 	ActionUpload   Action = "file.upload"
 	ActionDownload Action = "file.download"
 	ActionShare    Action = "file.share"
+	ActionUnshare  Action = "file.unshare"
+	ActionRekey    Action = "file.rekey"
 
 	// Verifiable Credentials
 	ActionVCIssue   Action = "vc.issue"
@@ -50,8 +62,28 @@ const (
 	ActionZKVerify   Action = "zk.verify"
 
 	// Attestation (Post-MVP)
-	ActionCosign Action = "attestation.cosign"
-	ActionAttest Action = "attestation.attest"
+	ActionCosign             Action = "attestation.cosign"
+	ActionAttest             Action = "attestation.attest"
+	ActionAttestationRequest Action = "attestation.request"
+	ActionAttestationRevoke  Action = "attestation.revoke"
+	ActionAttestationExpire  Action = "attestation.expire"
+
+	// Access monitoring
+	ActionAccessDeny   Action = "access.deny"
+	ActionAlertRaise   Action = "alert.raise"
+	ActionAlertResolve Action = "alert.resolve"
+
+	// Delegation
+	ActionDelegationCreate Action = "delegation.create"
+	ActionDelegationSign   Action = "delegation.sign"
+	ActionDelegationRevoke Action = "delegation.revoke"
+	ActionDelegationExpire Action = "delegation.expire"
+
+	// Emergency (break-glass) access
+	ActionEmergencyAccess  Action = "emergency.access"
+	ActionEmergencyEnd     Action = "emergency.end"
+	ActionEmergencyReview  Action = "emergency.review"
+	ActionEmergencyProfile Action = "emergency.profile"
 )
 
 func (a Action) IsValid() bool {
@@ -75,6 +107,16 @@ const (
 
 	// Attestation
 	ResourceAttestation ResourceType = "attestation" // Provider attestation
+
+	// Access monitoring
+	ResourceAlert ResourceType = "alert" // Suspicious access alert
+
+	// Delegation
+	ResourceDelegation ResourceType = "delegation" // Guardian or caregiver delegation
+
+	// Emergency access
+	ResourceEmergencyAccess  ResourceType = "emergency_access"  // Break-glass session
+	ResourceEmergencyProfile ResourceType = "emergency_profile" // Patient's emergency categories
 )
 
 func (rt ResourceType) IsValid() bool {
@@ -132,7 +174,28 @@ func (e *Entry) Validate() error {
 	return nil
 }
 
+// HashScheme returns the scheme the entry's hash was computed under. Entries without
+// a schema version predate versioning and are treated as audit.v1.
+func (e *Entry) HashScheme() string {
+	if e.SchemaVersion == "" {
+		return SchemaVersionAuditV1
+	}
+	return e.SchemaVersion
+}
+
+// ComputeHash returns the entry hash under its HashScheme. It returns "" for unknown
+// schemes, or when metadata cannot be encoded, so VerifyHash fails closed.
 func (e *Entry) ComputeHash() string {
+	switch e.HashScheme() {
+	case SchemaVersionAuditV1:
+		return e.computeHashV1()
+	case SchemaVersionAuditV2:
+		return e.computeHashV2()
+	}
+	return ""
+}
+
+func (e *Entry) computeHashV1() string {
 	data := struct {
 		Actor        string       `json:"actor"`
 		Action       Action       `json:"action"`
@@ -149,11 +212,76 @@ func (e *Entry) ComputeHash() string {
 		PreviousHash: e.PreviousHash,
 	}
 
-	bytes, _ := json.Marshal(data)
-	hash := sha256.Sum256(bytes)
+	encoded, _ := json.Marshal(data)
+	hash := sha256.Sum256(encoded)
+	return hex.EncodeToString(hash[:])
+}
+
+// computeHashV2 extends v1 with the schema version and metadata. Metadata is
+// canonicalized so the hash survives a round trip through JSON storage (jsonb).
+func (e *Entry) computeHashV2() string {
+	metadata, err := canonicalMetadata(e.Metadata)
+	if err != nil {
+		return ""
+	}
+
+	data := struct {
+		SchemaVersion string          `json:"schemaVersion"`
+		Actor         string          `json:"actor"`
+		Action        Action          `json:"action"`
+		ResourceType  ResourceType    `json:"resourceType"`
+		ResourceID    string          `json:"resourceId"`
+		Timestamp     string          `json:"timestamp"`
+		PreviousHash  string          `json:"previousHash"`
+		Metadata      json.RawMessage `json:"metadata"`
+	}{
+		SchemaVersion: SchemaVersionAuditV2,
+		Actor:         e.Actor.String(),
+		Action:        e.Action,
+		ResourceType:  e.ResourceType,
+		ResourceID:    e.ResourceID.String(),
+		Timestamp:     e.Timestamp.UTC().Format(time.RFC3339Nano),
+		PreviousHash:  e.PreviousHash,
+		Metadata:      metadata,
+	}
+
+	encoded, err := encodeCanonical(data)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(encoded)
 	return hex.EncodeToString(hash[:])
 }
 
+// canonicalMetadata renders metadata as compact JSON with object keys sorted at every
+// level. Values are first normalized through a JSON round trip, so structs, typed slices
+// and integers hash the same as the generic maps and float64s read back from storage.
+func canonicalMetadata(metadata types.Metadata) (json.RawMessage, error) {
+	if len(metadata) == 0 {
+		return json.RawMessage("{}"), nil
+	}
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, err
+	}
+	return encodeCanonical(normalized)
+}
+
+func encodeCanonical(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
 func (e *Entry) SetHash() {
 	e.Hash = e.ComputeHash()
 }
@@ -177,7 +305,7 @@ func NewEntry(
 		Action:        action,
 		ResourceType:  resourceType,
 		ResourceID:    resourceID,
-		Timestamp:     time.Now().UTC(),
+		Timestamp:     time.Now().UTC().Truncate(time.Microsecond), // Postgres precision
 		PreviousHash:  previousHash,
 		Metadata:      types.NewMetadata(),
 		SchemaVersion: SchemaVersionAudit,
@@ -84,7 +84,7 @@ func (b *EntryBuilder) Build() (*Entry, error) {
 	}
 
 	if b.entry.Timestamp.IsZero() {
-		b.entry.Timestamp = time.Now().UTC()
+		b.entry.Timestamp = time.Now().UTC().Truncate(time.Microsecond)
 	}
 
 	if err := b.entry.Validate(); err != nil {
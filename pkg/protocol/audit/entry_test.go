@@ -1,6 +1,7 @@
 package audit
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -25,6 +26,7 @@ func TestAction_IsValid(t *testing.T) {
 		{ActionConsentExpire, true},
 		{ActionConsentSuspend, true},
 		{ActionConsentResume, true},
+		{ActionConsentExpiring, true},
 		// Auth
 		{ActionLogin, true},
 		{ActionLogout, true},
@@ -32,6 +34,8 @@ func TestAction_IsValid(t *testing.T) {
 		{ActionUpload, true},
 		{ActionDownload, true},
 		{ActionShare, true},
+		{ActionUnshare, true},
+		{ActionRekey, true},
 		// VC
 		{ActionVCIssue, true},
 		{ActionVCRevoke, true},
@@ -43,6 +47,23 @@ func TestAction_IsValid(t *testing.T) {
 		// Attestation
 		{ActionCosign, true},
 		{ActionAttest, true},
+		{ActionAttestationRequest, true},
+		{ActionAttestationRevoke, true},
+		{ActionAttestationExpire, true},
+		// Access monitoring
+		{ActionAccessDeny, true},
+		{ActionAlertRaise, true},
+		{ActionAlertResolve, true},
+		// Delegation
+		{ActionDelegationCreate, true},
+		{ActionDelegationSign, true},
+		{ActionDelegationRevoke, true},
+		{ActionDelegationExpire, true},
+		// Emergency access
+		{ActionEmergencyAccess, true},
+		{ActionEmergencyEnd, true},
+		{ActionEmergencyReview, true},
+		{ActionEmergencyProfile, true},
 		// Invalid
 		{"unknown", false},
 		{"", false},
@@ -69,6 +90,10 @@ func TestResourceType_IsValid(t *testing.T) {
 		{ResourceVC, true},
 		{ResourceZKProof, true},
 		{ResourceAttestation, true},
+		{ResourceAlert, true},
+		{ResourceDelegation, true},
+		{ResourceEmergencyAccess, true},
+		{ResourceEmergencyProfile, true},
 		{"unknown", false},
 		{"", false},
 	}
@@ -252,3 +277,102 @@ func TestQueryFilter(t *testing.T) {
 		t.Error("Limit not set")
 	}
 }
+
+func TestEntry_HashSchemes(t *testing.T) {
+	actor, _ := types.NewWalletAddress("0x1234567890abcdef1234567890abcdef12345678")
+	newEntry := func(schemaVersion string) *Entry {
+		return &Entry{
+			Actor:         actor,
+			Action:        ActionConsentApprove,
+			ResourceType:  ResourceConsent,
+			ResourceID:    "grant-1",
+			Timestamp:     time.Date(2026, 1, 23, 12, 0, 0, 123456000, time.UTC),
+			PreviousHash:  "GENESIS",
+			SchemaVersion: schemaVersion,
+			Metadata: types.Metadata{
+				"grantee":     "0xabcdefabcdefabcdefabcdefabcdefabcdefabcd",
+				"permissions": []string{"read", "share"},
+			},
+		}
+	}
+
+	t.Run("v2 vector", func(t *testing.T) {
+		entry := newEntry(SchemaVersionAuditV2)
+		const want = "6b2aaafc476063f77f8c28bbe3ed7a85b7c56fd4cd4b3753d5f64cbb7ed3a9f0"
+		if got := entry.ComputeHash(); got != want {
+			t.Errorf("ComputeHash() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("v1 vector is unchanged", func(t *testing.T) {
+		entry := newEntry(SchemaVersionAuditV1)
+		const want = "6bb475eff9ae97602ff6ec373008caebb20535d73d6f577d2973d6d966362526"
+		if got := entry.ComputeHash(); got != want {
+			t.Errorf("ComputeHash() = %s, want %s", got, want)
+		}
+		if unversioned := newEntry(""); unversioned.ComputeHash() != want {
+			t.Error("entries without a schema version should hash as audit.v1")
+		}
+	})
+
+	t.Run("v2 commits to metadata", func(t *testing.T) {
+		entry := newEntry(SchemaVersionAuditV2)
+		entry.SetHash()
+		entry.Metadata["grantee"] = "0x0000000000000000000000000000000000000000"
+		if entry.VerifyHash() {
+			t.Error("VerifyHash() should fail after metadata tampering")
+		}
+	})
+
+	t.Run("v1 does not commit to metadata", func(t *testing.T) {
+		entry := newEntry(SchemaVersionAuditV1)
+		entry.SetHash()
+		entry.Metadata["grantee"] = "0x0000000000000000000000000000000000000000"
+		if !entry.VerifyHash() {
+			t.Error("legacy audit.v1 hashes should still verify")
+		}
+	})
+
+	t.Run("scheme downgrade changes the hash", func(t *testing.T) {
+		entry := newEntry(SchemaVersionAuditV2)
+		entry.SetHash()
+		entry.SchemaVersion = SchemaVersionAuditV1
+		if entry.VerifyHash() {
+			t.Error("VerifyHash() should fail when the schema version is rewritten")
+		}
+	})
+
+	t.Run("unknown scheme fails closed", func(t *testing.T) {
+		entry := newEntry("audit.v9")
+		if entry.ComputeHash() != "" {
+			t.Error("ComputeHash() should return empty for unknown schemes")
+		}
+		entry.Hash = "anything"
+		if entry.VerifyHash() {
+			t.Error("VerifyHash() should fail for unknown schemes")
+		}
+	})
+
+	t.Run("metadata survives a JSON round trip", func(t *testing.T) {
+		type file struct {
+			Size int64  `json:"size"`
+			Name string `json:"name"`
+		}
+		written := newEntry(SchemaVersionAuditV2)
+		written.Metadata["file"] = file{Size: 2048, Name: "scan.pdf"}
+		written.Metadata["size"] = int64(2048)
+		written.SetHash()
+
+		raw, _ := json.Marshal(written.Metadata)
+		var stored types.Metadata
+		if err := json.Unmarshal(raw, &stored); err != nil {
+			t.Fatalf("Unmarshal() error = %v", err)
+		}
+		read := newEntry(SchemaVersionAuditV2)
+		read.Metadata = stored
+		read.Hash = written.Hash
+		if !read.VerifyHash() {
+			t.Error("hash should match after metadata is stored and read back as generic JSON")
+		}
+	})
+}
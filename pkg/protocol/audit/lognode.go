@@ -0,0 +1,226 @@
+package audit
+
+import "fmt"
+
+// LogNode identifies a perfect subtree of a merkle.v2 log: the 1<<Level leaves starting
+// at leaf Index<<Level. Once those leaves are logged its hash never changes, so the
+// hashes can be stored and roots and proofs computed from O(log n) of them instead of
+// rebuilding the tree from every leaf. Level 0 nodes are the prefixed leaf hashes.
+type LogNode struct {
+	Level int
+	Index int
+}
+
+// LogNodeHashes maps log nodes to their hex hashes.
+type LogNodeHashes map[LogNode]string
+
+// logRange is the subtree over leaves [start, end) of the RFC 6962 split of a log.
+// left marks a proof sibling that hashes on the left.
+type logRange struct {
+	start, end int
+	left       bool
+}
+
+// FrontierNodes returns the largest perfect subtrees covering the first size leaves,
+// left to right. They are all that is needed to compute the root of the log at size or
+// to append to it.
+func FrontierNodes(size int) []LogNode {
+	return rangeNodes(0, size)
+}
+
+// LogRoot computes the root of the log of size leaves from FrontierNodes(size).
+func LogRoot(nodes LogNodeHashes, size int) (string, error) {
+	if size <= 0 {
+		return "", ErrEmptyLeaves
+	}
+	return nodes.rangeHash(0, size)
+}
+
+// AppendLogNodes returns every node completed by appending entryHashes to a log of
+// oldSize leaves, including the new leaves, and the new root. nodes must hold
+// FrontierNodes(oldSize).
+func AppendLogNodes(nodes LogNodeHashes, oldSize int, entryHashes []string) (LogNodeHashes, string, error) {
+	if len(entryHashes) == 0 {
+		return nil, "", ErrEmptyLeaves
+	}
+
+	known := make(LogNodeHashes, len(entryHashes)*2)
+	for _, node := range FrontierNodes(oldSize) {
+		hash, ok := nodes[node]
+		if !ok {
+			return nil, "", fmt.Errorf("%w: level %d index %d", ErrLogNodeMissing, node.Level, node.Index)
+		}
+		known[node] = hash
+	}
+
+	added := make(LogNodeHashes, len(entryHashes)*2)
+	for i, entryHash := range entryHashes {
+		hash, err := hashLeafHex(entryHash)
+		if err != nil {
+			return nil, "", err
+		}
+		node := LogNode{Level: 0, Index: oldSize + i}
+		known[node], added[node] = hash, hash
+		for node.Index%2 == 1 {
+			sibling := LogNode{Level: node.Level, Index: node.Index - 1}
+			left, ok := known[sibling]
+			if !ok {
+				return nil, "", fmt.Errorf("%w: level %d index %d", ErrLogNodeMissing, sibling.Level, sibling.Index)
+			}
+			parent := LogNode{Level: node.Level + 1, Index: node.Index / 2}
+			if hash, err = hashNodeHex(left, hash); err != nil {
+				return nil, "", err
+			}
+			known[parent], added[parent] = hash, hash
+			node = parent
+		}
+	}
+
+	root, err := known.rangeHash(0, oldSize+len(entryHashes))
+	if err != nil {
+		return nil, "", err
+	}
+	return added, root, nil
+}
+
+// LogInclusionProofNodes lists the nodes LogInclusionProof reads.
+func LogInclusionProofNodes(index, size int) []LogNode {
+	return rangesNodes(inclusionRanges(index, 0, size))
+}
+
+// LogInclusionProof returns the inclusion proof for the leaf at index in the log of size
+// leaves. It matches GenerateProofAt over the full merkle.v2 tree.
+func LogInclusionProof(nodes LogNodeHashes, entryHash string, index, size int) (*Proof, error) {
+	if !isValidHexHash(entryHash) {
+		return nil, ErrInvalidHash
+	}
+	if index < 0 || index >= size {
+		return nil, ErrLeafNotFound
+	}
+
+	ranges := inclusionRanges(index, 0, size)
+	steps := make([]ProofStep, len(ranges))
+	for i, r := range ranges {
+		hash, err := nodes.rangeHash(r.start, r.end)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = ProofStep{Hash: hash, IsLeft: r.left}
+	}
+	return &Proof{Scheme: MerkleSchemeV2, EntryHash: entryHash, Steps: steps}, nil
+}
+
+// LogConsistencyProofNodes lists the nodes LogConsistencyProof reads.
+func LogConsistencyProofNodes(oldSize, newSize int) []LogNode {
+	if oldSize <= 0 || oldSize > newSize {
+		return nil
+	}
+	return rangesNodes(consistencyRanges(oldSize, 0, newSize, true))
+}
+
+// LogConsistencyProof proves that the log of oldSize leaves is a prefix of the log of
+// newSize leaves. It matches GenerateConsistencyProof over the full tree.
+func LogConsistencyProof(nodes LogNodeHashes, oldSize, newSize int) (*ConsistencyProof, error) {
+	if oldSize <= 0 || oldSize > newSize {
+		return nil, ErrInvalidTreeSize
+	}
+
+	ranges := consistencyRanges(oldSize, 0, newSize, true)
+	hashes := make([]string, len(ranges))
+	for i, r := range ranges {
+		hash, err := nodes.rangeHash(r.start, r.end)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return &ConsistencyProof{
+		Scheme:  MerkleSchemeV2,
+		OldSize: oldSize,
+		NewSize: newSize,
+		Hashes:  hashes,
+	}, nil
+}
+
+// rangeHash is MTH over leaves [start, end), folded from stored perfect subtrees.
+func (nodes LogNodeHashes) rangeHash(start, end int) (string, error) {
+	parts := rangeNodes(start, end)
+	if len(parts) == 0 {
+		return "", ErrEmptyLeaves
+	}
+	hashes := make([]string, len(parts))
+	for i, node := range parts {
+		hash, ok := nodes[node]
+		if !ok {
+			return "", fmt.Errorf("%w: level %d index %d", ErrLogNodeMissing, node.Level, node.Index)
+		}
+		hashes[i] = hash
+	}
+
+	// Under the RFC 6962 split every left part is perfect, so the range hashes as
+	// H(p0, H(p1, ... H(pn-1, pn))).
+	hash := hashes[len(hashes)-1]
+	for i := len(hashes) - 2; i >= 0; i-- {
+		var err error
+		if hash, err = hashNodeHex(hashes[i], hash); err != nil {
+			return "", err
+		}
+	}
+	return hash, nil
+}
+
+// rangeNodes splits leaves [start, end) the way RFC 6962 does, into perfect subtrees.
+func rangeNodes(start, end int) []LogNode {
+	var nodes []LogNode
+	for n := end - start; n > 0; n = end - start {
+		size := 1
+		for size<<1 <= n {
+			size <<= 1
+		}
+		level := 0
+		for 1<<level < size {
+			level++
+		}
+		nodes = append(nodes, LogNode{Level: level, Index: start >> level})
+		start += size
+	}
+	return nodes
+}
+
+func rangesNodes(ranges []logRange) []LogNode {
+	var nodes []LogNode
+	for _, r := range ranges {
+		nodes = append(nodes, rangeNodes(r.start, r.end)...)
+	}
+	return nodes
+}
+
+// inclusionRanges is PATH from RFC 9162 section 2.1.3.1 over leaves [start, end),
+// with the siblings bottom-up.
+func inclusionRanges(index, start, end int) []logRange {
+	n := end - start
+	if n <= 1 {
+		return nil
+	}
+	k := splitPoint(n)
+	if index < start+k {
+		return append(inclusionRanges(index, start, start+k), logRange{start: start + k, end: end})
+	}
+	return append(inclusionRanges(index, start+k, end), logRange{start: start, end: start + k, left: true})
+}
+
+// consistencyRanges is SUBPROOF from RFC 9162 section 2.1.4.1 over leaves [start, end).
+func consistencyRanges(m, start, end int, complete bool) []logRange {
+	n := end - start
+	if m == n {
+		if complete {
+			return nil
+		}
+		return []logRange{{start: start, end: end}}
+	}
+	k := splitPoint(n)
+	if m <= k {
+		return append(consistencyRanges(m, start, start+k, complete), logRange{start: start + k, end: end})
+	}
+	return append(consistencyRanges(m-k, start+k, end, false), logRange{start: start, end: start + k})
+}
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// appendAll logs the entries in chunks of the given sizes, as successive batches would.
+func appendAll(t *testing.T, entries []Entry, chunks ...int) LogNodeHashes {
+	t.Helper()
+	nodes := make(LogNodeHashes)
+	size := 0
+	for _, chunk := range chunks {
+		hashes := make([]string, chunk)
+		for i := range hashes {
+			hashes[i] = entries[size+i].Hash
+		}
+		added, root, err := AppendLogNodes(nodes, size, hashes)
+		if err != nil {
+			t.Fatalf("AppendLogNodes(%d, %d) error = %v", size, chunk, err)
+		}
+		for node, hash := range added {
+			nodes[node] = hash
+		}
+		size += chunk
+
+		tree, _ := BuildMerkleTree(entries[:size])
+		if root != tree.Root {
+			t.Fatalf("AppendLogNodes() root at %d = %s, want %s", size, root, tree.Root)
+		}
+	}
+	return nodes
+}
+
+func TestLogNodes_MatchFullTree(t *testing.T) {
+	entries := hashEntries(17)
+	nodes := appendAll(t, entries, 3, 1, 4, 6, 3)
+
+	for size := 1; size <= len(entries); size++ {
+		tree, _ := BuildMerkleTree(entries[:size])
+		root, err := LogRoot(nodes, size)
+		if err != nil || root != tree.Root {
+			t.Fatalf("LogRoot(%d) = %s, %v, want %s", size, root, err, tree.Root)
+		}
+
+		for index := 0; index < size; index++ {
+			want, _ := GenerateProofAt(tree, index)
+			got, err := LogInclusionProof(nodes, entries[index].Hash, index, size)
+			if err != nil {
+				t.Fatalf("LogInclusionProof(%d, %d) error = %v", index, size, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("LogInclusionProof(%d, %d) = %+v, want %+v", index, size, got, want)
+			}
+		}
+
+		for oldSize := 1; oldSize <= size; oldSize++ {
+			want, _ := GenerateConsistencyProof(tree, oldSize)
+			got, err := LogConsistencyProof(nodes, oldSize, size)
+			if err != nil {
+				t.Fatalf("LogConsistencyProof(%d, %d) error = %v", oldSize, size, err)
+			}
+			if len(got.Hashes) != len(want.Hashes) || (len(want.Hashes) > 0 && !reflect.DeepEqual(got, want)) {
+				t.Fatalf("LogConsistencyProof(%d, %d) = %+v, want %+v", oldSize, size, got, want)
+			}
+		}
+	}
+}
+
+func TestLogNodes_ProofsReadOnlyListedNodes(t *testing.T) {
+	entries := hashEntries(17)
+	all := appendAll(t, entries, 17)
+
+	pick := func(list []LogNode) LogNodeHashes {
+		nodes := make(LogNodeHashes, len(list))
+		for _, node := range list {
+			nodes[node] = all[node]
+		}
+		return nodes
+	}
+
+	if _, err := LogRoot(pick(FrontierNodes(13)), 13); err != nil {
+		t.Errorf("LogRoot() from FrontierNodes error = %v", err)
+	}
+	if _, err := LogInclusionProof(pick(LogInclusionProofNodes(5, 13)), entries[5].Hash, 5, 13); err != nil {
+		t.Errorf("LogInclusionProof() from LogInclusionProofNodes error = %v", err)
+	}
+	if _, err := LogConsistencyProof(pick(LogConsistencyProofNodes(6, 13)), 6, 13); err != nil {
+		t.Errorf("LogConsistencyProof() from LogConsistencyProofNodes error = %v", err)
+	}
+	if len(LogInclusionProofNodes(5, 13)) > 8 {
+		t.Errorf("LogInclusionProofNodes(5, 13) = %v, want O(log n) nodes", LogInclusionProofNodes(5, 13))
+	}
+}
+
+func TestLogNodes_AppendNeedsFrontier(t *testing.T) {
+	entries := hashEntries(6)
+	nodes := appendAll(t, entries, 5)
+	delete(nodes, LogNode{Level: 2, Index: 0})
+
+	if _, _, err := AppendLogNodes(nodes, 5, []string{entries[5].Hash}); !errors.Is(err, ErrLogNodeMissing) {
+		t.Errorf("AppendLogNodes() without the frontier error = %v, want %v", err, ErrLogNodeMissing)
+	}
+}
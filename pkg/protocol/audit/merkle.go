@@ -1,35 +1,101 @@
 package audit
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 )
 
 var (
-	ErrEmptyLeaves     = errors.New("audit: merkle tree requires at least one leaf")
-	ErrLeafNotFound    = errors.New("audit: entry hash not found in merkle tree")
-	ErrInvalidHash     = errors.New("audit: invalid hex hash")
-	ErrInvalidTreeRoot = errors.New("audit: merkle tree root is empty")
+	ErrEmptyLeaves         = errors.New("audit: merkle tree requires at least one leaf")
+	ErrLeafNotFound        = errors.New("audit: entry hash not found in merkle tree")
+	ErrInvalidHash         = errors.New("audit: invalid hex hash")
+	ErrInvalidTreeRoot     = errors.New("audit: merkle tree root is empty")
+	ErrUnknownScheme       = errors.New("audit: unknown merkle scheme")
+	ErrInvalidTreeSize     = errors.New("audit: invalid merkle tree size")
+	ErrSchemeNotAppendOnly = errors.New("audit: merkle scheme does not support consistency proofs")
+	ErrLogNodeMissing      = errors.New("audit: merkle log node missing")
 )
 
+// MerkleScheme identifies how a tree hashes its leaves and interior nodes.
+type MerkleScheme string
+
+const (
+	// MerkleSchemeV1 is the legacy tree: leaves are used as-is, nodes hash as
+	// sha256(left||right) and odd levels duplicate their last node. It is ambiguous
+	// (a node can pose as a leaf, and [a b c] has the same root as [a b c c]) and is
+	// kept only so existing batches remain verifiable.
+	MerkleSchemeV1 MerkleScheme = "merkle.v1"
+
+	// MerkleSchemeV2 follows RFC 6962/9162: leaves hash as sha256(0x00||entryHash),
+	// nodes as sha256(0x01||left||right), and an unbalanced tree splits at the largest
+	// power of two below its size. Trees over a growing log support consistency proofs.
+	MerkleSchemeV2 MerkleScheme = "merkle.v2"
+
+	// MerkleSchemeCurrent is the scheme used for new trees.
+	MerkleSchemeCurrent = MerkleSchemeV2
+)
+
+const (
+	leafPrefix byte = 0x00
+	nodePrefix byte = 0x01
+)
+
+// Normalize maps the empty scheme, used by proofs and batches that predate
+// versioning, to MerkleSchemeV1.
+func (s MerkleScheme) Normalize() MerkleScheme {
+	if s == "" {
+		return MerkleSchemeV1
+	}
+	return s
+}
+
+// IsValid reports whether the scheme is known.
+func (s MerkleScheme) IsValid() bool {
+	switch s.Normalize() {
+	case MerkleSchemeV1, MerkleSchemeV2:
+		return true
+	}
+	return false
+}
+
 type ProofStep struct {
-	Hash   string
-	IsLeft bool
+	Hash   string `json:"hash"`
+	IsLeft bool   `json:"isLeft"`
 }
 
 type Proof struct {
-	EntryHash string
-	Steps     []ProofStep
+	Scheme    MerkleScheme `json:"scheme,omitempty"` // Empty means merkle.v1
+	EntryHash string       `json:"entryHash"`
+	Steps     []ProofStep  `json:"steps"`
+}
+
+// ConsistencyProof shows that the tree of the first OldSize leaves is a prefix of the
+// tree of NewSize leaves, i.e. the log only grew by appending (RFC 9162 section 2.1.4).
+type ConsistencyProof struct {
+	Scheme  MerkleScheme `json:"scheme"`
+	OldSize int          `json:"oldSize"`
+	NewSize int          `json:"newSize"`
+	Hashes  []string     `json:"hashes"`
 }
 
+// MerkleTree holds the entry hashes it was built from and every level of the tree.
+// Under merkle.v2 Levels[0] holds the prefixed leaf hashes, not the entry hashes.
 type MerkleTree struct {
+	Scheme MerkleScheme
 	Leaves []string
 	Levels [][]string
 	Root   string
 }
 
+// BuildMerkleTree builds a tree over the entries under MerkleSchemeCurrent.
 func BuildMerkleTree(entries []Entry) (*MerkleTree, error) {
+	return BuildMerkleTreeWithScheme(entries, MerkleSchemeCurrent)
+}
+
+// BuildMerkleTreeWithScheme builds a tree over the entries under the given scheme.
+func BuildMerkleTreeWithScheme(entries []Entry, scheme MerkleScheme) (*MerkleTree, error) {
 	if len(entries) == 0 {
 		return nil, ErrEmptyLeaves
 	}
@@ -46,25 +112,33 @@ func BuildMerkleTree(entries []Entry) (*MerkleTree, error) {
 		leaves = append(leaves, hash)
 	}
 
-	levels, err := buildLevels(leaves)
+	scheme = scheme.Normalize()
+	levels, err := buildLevels(leaves, scheme)
 	if err != nil {
 		return nil, err
 	}
 
 	root := levels[len(levels)-1][0]
 	return &MerkleTree{
+		Scheme: scheme,
 		Leaves: leaves,
 		Levels: levels,
 		Root:   root,
 	}, nil
 }
 
+// ComputeRoot returns the root of the leaves under MerkleSchemeCurrent.
 func ComputeRoot(leaves []string) (string, error) {
+	return ComputeRootWithScheme(leaves, MerkleSchemeCurrent)
+}
+
+// ComputeRootWithScheme returns the root of the leaves under the given scheme.
+func ComputeRootWithScheme(leaves []string, scheme MerkleScheme) (string, error) {
 	if len(leaves) == 0 {
 		return "", ErrEmptyLeaves
 	}
 
-	levels, err := buildLevels(leaves)
+	levels, err := buildLevels(leaves, scheme.Normalize())
 	if err != nil {
 		return "", err
 	}
@@ -91,6 +165,21 @@ func GenerateProof(tree *MerkleTree, entryHash string) (*Proof, error) {
 		return nil, ErrLeafNotFound
 	}
 
+	return GenerateProofAt(tree, index)
+}
+
+// GenerateProofAt returns the inclusion proof for the leaf at index, avoiding the leaf
+// scan in GenerateProof when the caller already knows the position.
+func GenerateProofAt(tree *MerkleTree, index int) (*Proof, error) {
+	if tree == nil || len(tree.Levels) == 0 {
+		return nil, ErrInvalidTreeRoot
+	}
+	if index < 0 || index >= len(tree.Leaves) {
+		return nil, ErrLeafNotFound
+	}
+	entryHash := tree.Leaves[index]
+
+	scheme := tree.Scheme.Normalize()
 	steps := make([]ProofStep, 0, len(tree.Levels)-1)
 	for levelIndex := 0; levelIndex < len(tree.Levels)-1; levelIndex++ {
 		level := tree.Levels[levelIndex]
@@ -103,6 +192,11 @@ func GenerateProof(tree *MerkleTree, entryHash string) (*Proof, error) {
 		}
 
 		if siblingIndex >= len(level) {
+			if scheme == MerkleSchemeV2 {
+				// Unpaired nodes are promoted unchanged, so there is nothing to hash with.
+				index = index / 2
+				continue
+			}
 			siblingIndex = index
 		}
 
@@ -114,18 +208,39 @@ func GenerateProof(tree *MerkleTree, entryHash string) (*Proof, error) {
 		index = index / 2
 	}
 
-	return &Proof{
+	proof := &Proof{
 		EntryHash: entryHash,
 		Steps:     steps,
-	}, nil
+	}
+	if scheme != MerkleSchemeV1 {
+		proof.Scheme = scheme
+	}
+	return proof, nil
 }
 
+// VerifyProof checks an inclusion proof under the scheme recorded in the proof.
 func VerifyProof(root string, entryHash string, proof *Proof) bool {
 	if proof == nil || root == "" || !isValidHexHash(entryHash) {
 		return false
 	}
 
-	hash := entryHash
+	var hash string
+	var combine func(left, right string) (string, error)
+	switch proof.Scheme.Normalize() {
+	case MerkleSchemeV1:
+		hash = entryHash
+		combine = hashPair
+	case MerkleSchemeV2:
+		var err error
+		hash, err = hashLeafHex(entryHash)
+		if err != nil {
+			return false
+		}
+		combine = hashNodeHex
+	default:
+		return false
+	}
+
 	for _, step := range proof.Steps {
 		if !isValidHexHash(step.Hash) {
 			return false
@@ -133,9 +248,9 @@ func VerifyProof(root string, entryHash string, proof *Proof) bool {
 
 		var err error
 		if step.IsLeft {
-			hash, err = hashPair(step.Hash, hash)
+			hash, err = combine(step.Hash, hash)
 		} else {
-			hash, err = hashPair(hash, step.Hash)
+			hash, err = combine(hash, step.Hash)
 		}
 		if err != nil {
 			return false
@@ -145,19 +260,85 @@ func VerifyProof(root string, entryHash string, proof *Proof) bool {
 	return hash == root
 }
 
-func buildLevels(leaves []string) ([][]string, error) {
+// GenerateConsistencyProof proves that the tree over the first oldSize leaves of tree
+// is a prefix of tree. Only merkle.v2 trees support consistency proofs.
+func GenerateConsistencyProof(tree *MerkleTree, oldSize int) (*ConsistencyProof, error) {
+	if tree == nil || len(tree.Levels) == 0 {
+		return nil, ErrInvalidTreeRoot
+	}
+	if tree.Scheme.Normalize() != MerkleSchemeV2 {
+		return nil, ErrSchemeNotAppendOnly
+	}
+	newSize := len(tree.Leaves)
+	if oldSize <= 0 || oldSize > newSize {
+		return nil, ErrInvalidTreeSize
+	}
+
+	leafHashes, err := decodeHashes(tree.Levels[0])
+	if err != nil {
+		return nil, err
+	}
+
+	path := consistencySubproof(oldSize, leafHashes, true)
+	hashes := make([]string, len(path))
+	for i, hash := range path {
+		hashes[i] = hex.EncodeToString(hash)
+	}
+
+	return &ConsistencyProof{
+		Scheme:  MerkleSchemeV2,
+		OldSize: oldSize,
+		NewSize: newSize,
+		Hashes:  hashes,
+	}, nil
+}
+
+// VerifyConsistencyProof checks that newRoot commits to a tree whose first
+// proof.OldSize leaves hash to oldRoot.
+func VerifyConsistencyProof(oldRoot string, newRoot string, proof *ConsistencyProof) bool {
+	if proof == nil || proof.Scheme != MerkleSchemeV2 {
+		return false
+	}
+
+	oldHash, err := hex.DecodeString(oldRoot)
+	if err != nil || len(oldHash) == 0 {
+		return false
+	}
+	newHash, err := hex.DecodeString(newRoot)
+	if err != nil || len(newHash) == 0 {
+		return false
+	}
+	path, err := decodeHashes(proof.Hashes)
+	if err != nil {
+		return false
+	}
+
+	return verifyConsistency(proof.OldSize, proof.NewSize, oldHash, newHash, path)
+}
+
+func buildLevels(leaves []string, scheme MerkleScheme) ([][]string, error) {
 	if len(leaves) == 0 {
 		return nil, ErrEmptyLeaves
 	}
-
-	level := make([]string, len(leaves))
-	copy(level, leaves)
-	for _, leaf := range level {
+	for _, leaf := range leaves {
 		if !isValidHexHash(leaf) {
 			return nil, ErrInvalidHash
 		}
 	}
 
+	switch scheme {
+	case MerkleSchemeV1:
+		return buildLevelsV1(leaves)
+	case MerkleSchemeV2:
+		return buildLevelsV2(leaves)
+	}
+	return nil, ErrUnknownScheme
+}
+
+func buildLevelsV1(leaves []string) ([][]string, error) {
+	level := make([]string, len(leaves))
+	copy(level, leaves)
+
 	levels := [][]string{level}
 	for len(level) > 1 {
 		if len(level)%2 == 1 {
@@ -179,6 +360,40 @@ func buildLevels(leaves []string) ([][]string, error) {
 	return levels, nil
 }
 
+// buildLevelsV2 pairs nodes left to right and promotes an unpaired last node to the
+// next level. That yields the same root as the recursive RFC 6962 definition, which
+// splits n leaves at the largest power of two below n.
+func buildLevelsV2(leaves []string) ([][]string, error) {
+	level := make([]string, len(leaves))
+	for i, leaf := range leaves {
+		hash, err := hashLeafHex(leaf)
+		if err != nil {
+			return nil, err
+		}
+		level[i] = hash
+	}
+
+	levels := [][]string{level}
+	for len(level) > 1 {
+		next := make([]string, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			parent, err := hashNodeHex(level[i], level[i+1])
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, parent)
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	return levels, nil
+}
+
 func hashPair(left, right string) (string, error) {
 	leftBytes, err := hex.DecodeString(left)
 	if err != nil {
@@ -192,6 +407,132 @@ func hashPair(left, right string) (string, error) {
 	return hex.EncodeToString(sum[:]), nil
 }
 
+func hashLeafHex(entryHash string) (string, error) {
+	data, err := hex.DecodeString(entryHash)
+	if err != nil {
+		return "", ErrInvalidHash
+	}
+	return hex.EncodeToString(hashLeaf(data)), nil
+}
+
+func hashNodeHex(left, right string) (string, error) {
+	leftBytes, err := hex.DecodeString(left)
+	if err != nil {
+		return "", ErrInvalidHash
+	}
+	rightBytes, err := hex.DecodeString(right)
+	if err != nil {
+		return "", ErrInvalidHash
+	}
+	return hex.EncodeToString(hashChildren(leftBytes, rightBytes)), nil
+}
+
+func hashLeaf(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{leafPrefix})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hashChildren(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{nodePrefix})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// subtreeRoot is MTH over already-hashed leaves.
+func subtreeRoot(leafHashes [][]byte) []byte {
+	if len(leafHashes) == 1 {
+		return leafHashes[0]
+	}
+	k := splitPoint(len(leafHashes))
+	return hashChildren(subtreeRoot(leafHashes[:k]), subtreeRoot(leafHashes[k:]))
+}
+
+// consistencySubproof is SUBPROOF from RFC 9162 section 2.1.4.1.
+func consistencySubproof(m int, leafHashes [][]byte, complete bool) [][]byte {
+	n := len(leafHashes)
+	if m == n {
+		if complete {
+			return nil
+		}
+		return [][]byte{subtreeRoot(leafHashes)}
+	}
+
+	k := splitPoint(n)
+	if m <= k {
+		return append(consistencySubproof(m, leafHashes[:k], complete), subtreeRoot(leafHashes[k:]))
+	}
+	return append(consistencySubproof(m-k, leafHashes[k:], false), subtreeRoot(leafHashes[:k]))
+}
+
+// verifyConsistency follows RFC 9162 section 2.1.4.2.
+func verifyConsistency(oldSize, newSize int, oldRoot, newRoot []byte, path [][]byte) bool {
+	if oldSize <= 0 || oldSize > newSize {
+		return false
+	}
+	if oldSize == newSize {
+		return len(path) == 0 && bytes.Equal(oldRoot, newRoot)
+	}
+	if len(path) == 0 {
+		return false
+	}
+
+	if oldSize&(oldSize-1) == 0 {
+		path = append([][]byte{oldRoot}, path...)
+	}
+
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := path[0], path[0]
+	for _, c := range path[1:] {
+		if sn == 0 {
+			return false
+		}
+		if fn&1 == 1 || fn == sn {
+			fr = hashChildren(c, fr)
+			sr = hashChildren(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = hashChildren(sr, c)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+
+	return sn == 0 && bytes.Equal(fr, oldRoot) && bytes.Equal(sr, newRoot)
+}
+
+// splitPoint returns the largest power of two strictly less than n (n > 1).
+func splitPoint(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+func decodeHashes(values []string) ([][]byte, error) {
+	hashes := make([][]byte, len(values))
+	for i, value := range values {
+		hash, err := hex.DecodeString(value)
+		if err != nil || len(hash) == 0 {
+			return nil, ErrInvalidHash
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
 func isValidHexHash(value string) bool {
 	if len(value) == 0 {
 		return false
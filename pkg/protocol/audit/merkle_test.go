@@ -1,6 +1,8 @@
 package audit
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 	"testing"
 	"time"
@@ -120,3 +122,225 @@ func TestComputeRoot_Empty(t *testing.T) {
 		t.Fatal("expected error for empty leaves")
 	}
 }
+
+// RFC 6962 reference inputs and roots, as used by the certificate-transparency test suites.
+var rfc6962Leaves = []string{
+	"",
+	"00",
+	"10",
+	"2021",
+	"3031",
+	"40414243",
+	"5051525354555657",
+	"606162636465666768696a6b6c6d6e6f",
+}
+
+var rfc6962Roots = []string{
+	"6e340b9cffb37a989ca544e6bb780a2c78901d3fb33738768511a30617afa01d",
+	"fac54203e7cc696cf0dfcb42c92a1d9dbaf70ad9e621f4bd8d98662f00e3c125",
+	"aeb6bcfe274b70a14fb067a5e5578264db0fa9b51af5e0ba159158f329e06e77",
+	"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+	"4e3bbb1f7b478dcfe71fb631631519a3bca12c9aefca1612bfce4c13a86264d4",
+	"76e67dadbcdf1e10e1b74ddc608abd2f98dfb16fbce75277b5232a127f2087ef",
+	"ddb89be403809e325750d3d263cd78929c2942b7942a34b77e122c9594a74c8c",
+	"5dc9da79a70659a9ad559cb701ded9a2ab9d823aad2f4960cfe370eff4604328",
+}
+
+func rfc6962LeafHashes(t *testing.T, n int) [][]byte {
+	t.Helper()
+	hashes := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		data, err := hex.DecodeString(rfc6962Leaves[i])
+		if err != nil {
+			t.Fatalf("decode leaf %d: %v", i, err)
+		}
+		hashes[i] = hashLeaf(data)
+	}
+	return hashes
+}
+
+func TestMerkleV2_RFC6962Roots(t *testing.T) {
+	for n := 1; n <= len(rfc6962Leaves); n++ {
+		if got := hex.EncodeToString(subtreeRoot(rfc6962LeafHashes(t, n))); got != rfc6962Roots[n-1] {
+			t.Errorf("root of %d leaves = %s, want %s", n, got, rfc6962Roots[n-1])
+		}
+	}
+}
+
+func TestMerkleV2_RFC6962ConsistencyProofs(t *testing.T) {
+	tests := []struct {
+		oldSize, newSize int
+		proof            []string
+	}{
+		{1, 1, nil},
+		{1, 8, []string{
+			"96a296d224f285c67bee93c30f8a309157f0daa35dc5b87e410b78630a09cfc7",
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"6b47aaf29ee3c2af9af889bc1fb9254dabd31177f16232dd6aab035ca39bf6e4",
+		}},
+		{6, 8, []string{
+			"0ebc5d3437fbe2db158b9f126a1d118e308181031d0a949f8dededebc558ef6a",
+			"ca854ea128ed050b41b35ffc1b87b8eb2bde461e9e3b5596ece6b9d5975a0ae0",
+			"d37ee418976dd95753c1c73862b9398fa2a2cf9b4ff0fdfe8b30cd95209614b7",
+		}},
+		{2, 5, []string{
+			"5f083f0a1a33ca076a95279832580db3e0ef4584bdff1f54c8a360f50de3031e",
+			"bc1a0643b12e4d2d7c77918f44e0f4f79a838b6cf9ec5b5c283e1f4d88599e6b",
+		}},
+	}
+
+	for _, tt := range tests {
+		path := consistencySubproof(tt.oldSize, rfc6962LeafHashes(t, tt.newSize), true)
+		if len(path) != len(tt.proof) {
+			t.Fatalf("proof(%d, %d) has %d hashes, want %d", tt.oldSize, tt.newSize, len(path), len(tt.proof))
+		}
+		for i := range path {
+			if got := hex.EncodeToString(path[i]); got != tt.proof[i] {
+				t.Errorf("proof(%d, %d)[%d] = %s, want %s", tt.oldSize, tt.newSize, i, got, tt.proof[i])
+			}
+		}
+
+		oldRoot, _ := hex.DecodeString(rfc6962Roots[tt.oldSize-1])
+		newRoot, _ := hex.DecodeString(rfc6962Roots[tt.newSize-1])
+		if !verifyConsistency(tt.oldSize, tt.newSize, oldRoot, newRoot, path) {
+			t.Errorf("verifyConsistency(%d, %d) = false, want true", tt.oldSize, tt.newSize)
+		}
+	}
+}
+
+func hashEntries(n int) []Entry {
+	entries := make([]Entry, n)
+	for i := range entries {
+		sum := sha256.Sum256([]byte{byte(i)})
+		entries[i] = Entry{Hash: hex.EncodeToString(sum[:])}
+	}
+	return entries
+}
+
+func TestMerkleV2_InclusionProofsAllSizes(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		entries := hashEntries(n)
+		tree, err := BuildMerkleTree(entries)
+		if err != nil {
+			t.Fatalf("BuildMerkleTree(%d) error = %v", n, err)
+		}
+		if tree.Scheme != MerkleSchemeV2 {
+			t.Fatalf("tree scheme = %s, want %s", tree.Scheme, MerkleSchemeV2)
+		}
+
+		leafHashes, _ := decodeHashes(tree.Levels[0])
+		if want := hex.EncodeToString(subtreeRoot(leafHashes)); tree.Root != want {
+			t.Fatalf("level root for %d leaves = %s, want recursive root %s", n, tree.Root, want)
+		}
+
+		for _, entry := range entries {
+			proof, err := GenerateProof(tree, entry.Hash)
+			if err != nil {
+				t.Fatalf("GenerateProof() error = %v", err)
+			}
+			if !VerifyProof(tree.Root, entry.Hash, proof) {
+				t.Fatalf("VerifyProof() failed for %d leaves", n)
+			}
+		}
+	}
+}
+
+func TestMerkleV2_ConsistencyAllSizes(t *testing.T) {
+	entries := hashEntries(17)
+	for newSize := 1; newSize <= len(entries); newSize++ {
+		newTree, _ := BuildMerkleTree(entries[:newSize])
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			oldTree, _ := BuildMerkleTree(entries[:oldSize])
+
+			proof, err := GenerateConsistencyProof(newTree, oldSize)
+			if err != nil {
+				t.Fatalf("GenerateConsistencyProof(%d, %d) error = %v", oldSize, newSize, err)
+			}
+			if !VerifyConsistencyProof(oldTree.Root, newTree.Root, proof) {
+				t.Fatalf("VerifyConsistencyProof(%d, %d) = false", oldSize, newSize)
+			}
+			if VerifyConsistencyProof(strings.Repeat("f", 64), newTree.Root, proof) {
+				t.Fatalf("VerifyConsistencyProof(%d, %d) accepted a foreign old root", oldSize, newSize)
+			}
+		}
+	}
+}
+
+func TestMerkleV2_ConsistencyRejectsRewrite(t *testing.T) {
+	entries := hashEntries(6)
+	oldTree, _ := BuildMerkleTree(entries[:4])
+
+	rewritten := append([]Entry{}, entries...)
+	rewritten[2] = Entry{Hash: strings.Repeat("e", 64)}
+	newTree, _ := BuildMerkleTree(rewritten)
+
+	proof, err := GenerateConsistencyProof(newTree, 4)
+	if err != nil {
+		t.Fatalf("GenerateConsistencyProof() error = %v", err)
+	}
+	if VerifyConsistencyProof(oldTree.Root, newTree.Root, proof) {
+		t.Fatal("VerifyConsistencyProof() should fail when an old leaf was rewritten")
+	}
+
+	proof.NewSize = 7
+	if VerifyConsistencyProof(oldTree.Root, newTree.Root, proof) {
+		t.Fatal("VerifyConsistencyProof() should fail with a wrong tree size")
+	}
+}
+
+func TestMerkleV2_NoDuplicateLeafAmbiguity(t *testing.T) {
+	three := hashEntries(3)
+	four := append(append([]Entry{}, three...), three[2])
+
+	for _, scheme := range []MerkleScheme{MerkleSchemeV1, MerkleSchemeV2} {
+		a, _ := BuildMerkleTreeWithScheme(three, scheme)
+		b, _ := BuildMerkleTreeWithScheme(four, scheme)
+		collides := a.Root == b.Root
+		if scheme == MerkleSchemeV1 && !collides {
+			t.Error("merkle.v1 is expected to collide on a duplicated last leaf")
+		}
+		if scheme == MerkleSchemeV2 && collides {
+			t.Error("merkle.v2 root must differ when the last leaf is duplicated")
+		}
+	}
+}
+
+func TestMerkleV2_InteriorNodeIsNotALeaf(t *testing.T) {
+	entries := hashEntries(4)
+	tree, _ := BuildMerkleTree(entries)
+
+	// Present the parent of the first two leaves as if it were an entry hash.
+	node := tree.Levels[1][0]
+	proof := &Proof{Scheme: MerkleSchemeV2, EntryHash: node, Steps: []ProofStep{{Hash: tree.Levels[1][1]}}}
+	if VerifyProof(tree.Root, node, proof) {
+		t.Fatal("an interior node must not verify as a leaf")
+	}
+}
+
+func TestMerkleV1_LegacyProofsStillVerify(t *testing.T) {
+	entries := hashEntries(5)
+	tree, err := BuildMerkleTreeWithScheme(entries, MerkleSchemeV1)
+	if err != nil {
+		t.Fatalf("BuildMerkleTreeWithScheme() error = %v", err)
+	}
+
+	proof, err := GenerateProof(tree, entries[4].Hash)
+	if err != nil {
+		t.Fatalf("GenerateProof() error = %v", err)
+	}
+	if proof.Scheme != "" {
+		t.Fatalf("legacy proof scheme = %q, want empty", proof.Scheme)
+	}
+	if !VerifyProof(tree.Root, entries[4].Hash, proof) {
+		t.Fatal("legacy proof should verify")
+	}
+
+	proof.Scheme = MerkleSchemeV2
+	if VerifyProof(tree.Root, entries[4].Hash, proof) {
+		t.Fatal("legacy proof must not verify under merkle.v2")
+	}
+
+	if _, err := GenerateConsistencyProof(tree, 2); err != ErrSchemeNotAppendOnly {
+		t.Fatalf("GenerateConsistencyProof() error = %v, want %v", err, ErrSchemeNotAppendOnly)
+	}
+}
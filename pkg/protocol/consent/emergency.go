@@ -0,0 +1,115 @@
+package consent
+
+import (
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Emergency (break-glass) access lets a provider read part of a patient's timeline
+// without their consent when care cannot wait. It is read-only, time-boxed, limited
+// to the patient's emergency categories, and always reviewed by the patient afterwards.
+const (
+	// DefaultEmergencyDuration is how long emergency access lasts unless configured.
+	DefaultEmergencyDuration = time.Hour
+	// MaxEmergencyDuration bounds any configured emergency access duration.
+	MaxEmergencyDuration = 24 * time.Hour
+	// MinJustificationLength is the shortest justification a provider may give.
+	MinJustificationLength = 20
+)
+
+// DefaultEmergencyCategories is what emergency access covers for a patient who has
+// not configured their own: allergies, medications and diagnoses.
+func DefaultEmergencyCategories() DataCategories {
+	return DataCategories{
+		{EventType: timeline.EventAllergy},
+		{EventType: timeline.EventMedication},
+		{EventType: timeline.EventPrescription},
+		{EventType: timeline.EventDiagnosis},
+	}
+}
+
+// ReviewStatus is the patient's verdict on an emergency access.
+type ReviewStatus string
+
+const (
+	ReviewPending   ReviewStatus = "pending"   // Awaiting the patient
+	ReviewConfirmed ReviewStatus = "confirmed" // The patient accepts the access was justified
+	ReviewFlagged   ReviewStatus = "flagged"   // The patient reports the access as abuse
+)
+
+func (s ReviewStatus) IsValid() bool {
+	switch s {
+	case ReviewPending, ReviewConfirmed, ReviewFlagged:
+		return true
+	}
+	return false
+}
+
+// EmergencyAccess is one break-glass session: a provider's time-boxed read access to
+// a patient's emergency categories.
+type EmergencyAccess struct {
+	ID            types.ID            `json:"id"`
+	Patient       types.WalletAddress `json:"patient"`
+	Provider      types.WalletAddress `json:"provider"`
+	Justification string              `json:"justification"`
+	Categories    DataCategories      `json:"categories"`
+	StartedAt     time.Time           `json:"startedAt"`
+	ExpiresAt     time.Time           `json:"expiresAt"`
+	EndedAt       *time.Time          `json:"endedAt,omitempty"` // Ended early by the provider or a flag
+	Review        ReviewStatus        `json:"review"`
+	SchemaVersion string              `json:"schemaVersion,omitempty"`
+}
+
+func (a *EmergencyAccess) Validate() error {
+	var errs types.ValidationErrors
+
+	if a.Patient.IsEmpty() {
+		errs.Add("patient", "patient address is required")
+	}
+	if a.Provider.IsEmpty() {
+		errs.Add("provider", "provider address is required")
+	} else if a.Provider.Equals(a.Patient) {
+		errs.Add("provider", "a patient cannot break glass on their own record")
+	}
+	if len(strings.TrimSpace(a.Justification)) < MinJustificationLength {
+		errs.Add("justification", "justification must explain the emergency")
+	}
+	if len(a.Categories) == 0 {
+		errs.Add("categories", "emergency access needs at least one category")
+	}
+	for _, c := range a.Categories {
+		c.validate(&errs)
+	}
+	if !a.ExpiresAt.After(a.StartedAt) {
+		errs.Add("expiresAt", "expiry must be after the start")
+	} else if a.ExpiresAt.Sub(a.StartedAt) > MaxEmergencyDuration {
+		errs.Add("expiresAt", "emergency access may last at most a day")
+	}
+	if !a.Review.IsValid() {
+		errs.Add("review", "invalid review status")
+	}
+
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// IsActive reports whether the provider may still read under the session at now.
+func (a *EmergencyAccess) IsActive(now time.Time) bool {
+	return a.EndedAt == nil && now.Before(a.ExpiresAt)
+}
+
+// CoversEvent reports whether an event falls in the session's categories.
+func (a *EmergencyAccess) CoversEvent(eventType timeline.EventType, codes types.Codes) bool {
+	return len(a.Categories) > 0 && a.Categories.Cover(eventType, codes)
+}
+
+// Permits reports whether emergency access serves an access declared as use: only
+// treatment, never a study or a secondary use.
+func (a *EmergencyAccess) Permits(use Use) bool {
+	return (use.Purpose == "" || use.Purpose == PurposeTreatment) && strings.TrimSpace(use.StudyID) == "" && use.SecondaryUse == ""
+}
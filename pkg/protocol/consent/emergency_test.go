@@ -0,0 +1,71 @@
+package consent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+func newEmergencyAccess() *EmergencyAccess {
+	now := time.Now()
+	return &EmergencyAccess{
+		ID:            "session-1",
+		Patient:       "0x1111111111111111111111111111111111111111",
+		Provider:      "0x2222222222222222222222222222222222222222",
+		Justification: "Unconscious patient admitted to the ER after a car accident",
+		Categories:    DefaultEmergencyCategories(),
+		StartedAt:     now,
+		ExpiresAt:     now.Add(DefaultEmergencyDuration),
+		Review:        ReviewPending,
+	}
+}
+
+func TestEmergencyAccess_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(a *EmergencyAccess)
+		wantErr bool
+	}{
+		{"valid", func(a *EmergencyAccess) {}, false},
+		{"own record", func(a *EmergencyAccess) { a.Provider = a.Patient }, true},
+		{"short justification", func(a *EmergencyAccess) { a.Justification = "emergency" }, true},
+		{"no categories", func(a *EmergencyAccess) { a.Categories = nil }, true},
+		{"too long", func(a *EmergencyAccess) { a.ExpiresAt = a.StartedAt.Add(MaxEmergencyDuration + time.Minute) }, true},
+		{"invalid review", func(a *EmergencyAccess) { a.Review = "ignored" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newEmergencyAccess()
+			tt.mutate(a)
+			if err := a.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEmergencyAccess_Coverage(t *testing.T) {
+	a := newEmergencyAccess()
+
+	if !a.CoversEvent(timeline.EventAllergy, nil) || !a.CoversEvent(timeline.EventDiagnosis, types.Codes{}) {
+		t.Error("CoversEvent() should cover the default emergency categories")
+	}
+	if a.CoversEvent(timeline.EventLabResult, nil) {
+		t.Error("CoversEvent(lab result) = true, want false")
+	}
+	if !a.Permits(Use{}) || a.Permits(Use{Purpose: PurposeResearch, StudyID: "NCT01234567"}) {
+		t.Error("Permits() should serve undeclared treatment access only")
+	}
+
+	if !a.IsActive(time.Now()) || a.IsActive(a.ExpiresAt) {
+		t.Error("IsActive() should hold until expiry")
+	}
+	ended := time.Now()
+	a.EndedAt = &ended
+	if a.IsActive(time.Now()) {
+		t.Error("IsActive() = true after the session ended")
+	}
+}
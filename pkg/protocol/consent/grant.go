@@ -4,6 +4,7 @@ import (
 	"slices"
 	"time"
 
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
 	"github.com/itspablomontes/fleming/pkg/protocol/types"
 )
 
@@ -38,6 +39,7 @@ type Grant struct {
 	State       State               `json:"state"`
 	ExpiresAt   time.Time           `json:"expiresAt,omitempty"`
 	Reason      string              `json:"reason,omitempty"`
+	Terms
 	SchemaVersion string            `json:"schemaVersion,omitempty"` // Protocol schema version (e.g., "consent.v1")
 	CreatedAt   time.Time           `json:"createdAt"`
 	UpdatedAt   time.Time           `json:"updatedAt"`
@@ -72,6 +74,8 @@ func (g *Grant) Validate() error {
 		errs.Add("state", "invalid state")
 	}
 
+	g.Terms.validate(&errs, g.Permissions)
+
 	if errs.HasErrors() {
 		return errs
 	}
@@ -108,6 +112,42 @@ func (g *Grant) CanAccess(eventID types.ID) bool {
 	return slices.Contains(g.Scope, eventID)
 }
 
+// CanAccessEvent is like CanAccess but also honours category entries in the scope,
+// so a grant scoped to "lab_result" covers every lab result on the timeline.
+func (g *Grant) CanAccessEvent(eventID types.ID, category string) bool {
+	if !g.IsActive() {
+		return false
+	}
+	return ScopeCovers(g.Scope, eventID, category)
+}
+
+// CoversEvent reports whether the grant is active and both its scope and its data
+// categories cover an event.
+func (g *Grant) CoversEvent(eventID types.ID, eventType timeline.EventType, codes types.Codes) bool {
+	if !g.IsActive() {
+		return false
+	}
+	return ScopeCovers(g.Scope, eventID, string(eventType)) && g.Categories.Cover(eventType, codes)
+}
+
+// ScopeCovers reports whether a consent scope covers an event.
+// An empty scope covers the whole timeline; otherwise an entry must equal
+// either the event ID or the event's category (its timeline event type).
+func ScopeCovers(scope []types.ID, eventID types.ID, category string) bool {
+	if len(scope) == 0 {
+		return true
+	}
+	for _, entry := range scope {
+		if entry == eventID {
+			return true
+		}
+		if category != "" && entry.String() == category {
+			return true
+		}
+	}
+	return false
+}
+
 func (g *Grant) AddToScope(eventID types.ID) {
 	if slices.Contains(g.Scope, eventID) {
 		return
@@ -90,6 +90,36 @@ func (b *GrantBuilder) WithReason(reason string) *GrantBuilder {
 	return b
 }
 
+// WithPurpose sets what the grantee may use the data for.
+func (b *GrantBuilder) WithPurpose(purpose Purpose) *GrantBuilder {
+	b.grant.Purpose = purpose
+	return b
+}
+
+// WithStudyID binds a research grant to a study.
+func (b *GrantBuilder) WithStudyID(studyID string) *GrantBuilder {
+	b.grant.StudyID = studyID
+	return b
+}
+
+// WithCategories sets the data categories the grant covers.
+func (b *GrantBuilder) WithCategories(categories DataCategories) *GrantBuilder {
+	b.grant.Categories = categories
+	return b
+}
+
+// AddCategory adds a data category.
+func (b *GrantBuilder) AddCategory(category DataCategory) *GrantBuilder {
+	b.grant.Categories = append(b.grant.Categories, category)
+	return b
+}
+
+// WithSecondaryUses sets the secondary uses of research data the patient permits.
+func (b *GrantBuilder) WithSecondaryUses(uses []SecondaryUse) *GrantBuilder {
+	b.grant.SecondaryUses = uses
+	return b
+}
+
 // WithCreatedAt sets the creation timestamp.
 func (b *GrantBuilder) WithCreatedAt(createdAt time.Time) *GrantBuilder {
 	b.grant.CreatedAt = createdAt
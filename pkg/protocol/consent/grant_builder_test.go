@@ -143,3 +143,28 @@ func TestGrantBuilder_WithExpiresAt(t *testing.T) {
 		t.Error("WithExpiresAt() did not set expiration")
 	}
 }
+
+func TestGrantBuilder_Terms(t *testing.T) {
+	grantor, _ := types.NewWalletAddress("0x1111111111111111111111111111111111111111")
+	grantee, _ := types.NewWalletAddress("0x2222222222222222222222222222222222222222")
+	build := func() *GrantBuilder {
+		return NewGrantBuilder().
+			WithGrantor(grantor).
+			WithGrantee(grantee).
+			AddPermission(PermRead).
+			WithPurpose(PurposeResearch).
+			AddCategory(DataCategory{System: types.CodingLOINC})
+	}
+
+	if _, err := build().Build(); err == nil {
+		t.Error("Build() accepted research consent without a study")
+	}
+
+	grant, err := build().WithStudyID("NCT01234567").WithSecondaryUses([]SecondaryUse{SecondaryUsePublication}).Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if grant.Purpose != PurposeResearch || grant.StudyID != "NCT01234567" || len(grant.Categories) != 1 || len(grant.SecondaryUses) != 1 {
+		t.Errorf("Build() terms = %+v", grant.Terms)
+	}
+}
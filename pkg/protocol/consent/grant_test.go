@@ -171,3 +171,40 @@ func TestGrant_ScopeManagement(t *testing.T) {
 		t.Errorf("Expected 1 event in scope after removal, got %d", len(g.Scope))
 	}
 }
+
+func TestGrant_CanAccessEvent(t *testing.T) {
+	g := newValidGrant()
+	g.State = StateApproved
+	g.Scope = []types.ID{"event-1", "lab_result"}
+
+	tests := []struct {
+		name     string
+		eventID  types.ID
+		category string
+		want     bool
+	}{
+		{"scoped event id", "event-1", "imaging", true},
+		{"scoped category", "event-9", "lab_result", true},
+		{"out of scope", "event-9", "imaging", false},
+		{"empty category", "event-9", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.CanAccessEvent(tt.eventID, tt.category); got != tt.want {
+				t.Errorf("CanAccessEvent(%q, %q) = %v, want %v", tt.eventID, tt.category, got, tt.want)
+			}
+		})
+	}
+
+	g.State = StateSuspended
+	if g.CanAccessEvent("event-1", "lab_result") {
+		t.Error("Suspended grant should not allow access")
+	}
+}
+
+func TestScopeCovers_EmptyScope(t *testing.T) {
+	if !ScopeCovers(nil, "event-1", "imaging") {
+		t.Error("Empty scope should cover every event")
+	}
+}
@@ -0,0 +1,217 @@
+package consent
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Purpose is what a grant allows the grantee to use the data for.
+type Purpose string
+
+const (
+	PurposeTreatment     Purpose = "treatment"      // Ongoing care by the grantee
+	PurposeResearch      Purpose = "research"       // A single study, named by the grant's StudyID
+	PurposeSecondOpinion Purpose = "second_opinion" // A one-off review by another clinician
+	PurposeInsurance     Purpose = "insurance"      // Claims and underwriting
+)
+
+func ValidPurposes() []Purpose {
+	return []Purpose{PurposeTreatment, PurposeResearch, PurposeSecondOpinion, PurposeInsurance}
+}
+
+func (p Purpose) IsValid() bool {
+	return slices.Contains(ValidPurposes(), p)
+}
+
+// AllowsPermission reports whether a grant for this purpose may carry a permission.
+// Research and insurance grants are read-only.
+func (p Purpose) AllowsPermission(perm Permission) bool {
+	switch p {
+	case PurposeResearch, PurposeInsurance:
+		return perm == PermRead
+	}
+	return true
+}
+
+// SecondaryUse is a use of research data beyond the study itself that the patient
+// agreed to.
+type SecondaryUse string
+
+const (
+	SecondaryUseAggregateAnalysis SecondaryUse = "aggregate_analysis" // Pooled statistics across participants
+	SecondaryUsePublication       SecondaryUse = "publication"        // De-identified results in publications
+	SecondaryUseDataSharing       SecondaryUse = "data_sharing"       // Sharing with the study's collaborators
+	SecondaryUseModelTraining     SecondaryUse = "model_training"     // Training statistical or ML models
+)
+
+func ValidSecondaryUses() []SecondaryUse {
+	return []SecondaryUse{SecondaryUseAggregateAnalysis, SecondaryUsePublication, SecondaryUseDataSharing, SecondaryUseModelTraining}
+}
+
+func (u SecondaryUse) IsValid() bool {
+	return slices.Contains(ValidSecondaryUses(), u)
+}
+
+// DataCategory selects events by type, by code, or both. CodePrefix matches the
+// start of a code in System, so {System: ICD-10, CodePrefix: "E11"} covers every
+// type 2 diabetes diagnosis code.
+type DataCategory struct {
+	EventType  timeline.EventType `json:"eventType,omitempty"`
+	System     types.CodingSystem `json:"system,omitempty"`
+	CodePrefix string             `json:"codePrefix,omitempty"`
+}
+
+func (c DataCategory) Validate() error {
+	var errs types.ValidationErrors
+	c.validate(&errs)
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+func (c DataCategory) validate(errs *types.ValidationErrors) {
+	if c.EventType == "" && c.System == "" {
+		errs.Add("categories", "category needs an event type or a coding system")
+	}
+	if c.EventType != "" && !c.EventType.IsValid() {
+		errs.Add("categories", "invalid event type: "+string(c.EventType))
+	}
+	if c.System != "" && !c.System.IsValid() {
+		errs.Add("categories", "invalid coding system: "+string(c.System))
+	}
+	if c.CodePrefix != "" && c.System == "" {
+		errs.Add("categories", "code prefix requires a coding system")
+	}
+}
+
+// Matches reports whether an event of eventType carrying codes falls in the category.
+func (c DataCategory) Matches(eventType timeline.EventType, codes types.Codes) bool {
+	if c.EventType != "" && c.EventType != eventType {
+		return false
+	}
+	if c.System == "" {
+		return true
+	}
+	prefix := strings.ToUpper(strings.TrimSpace(c.CodePrefix))
+	for _, code := range codes {
+		if code.System == c.System && strings.HasPrefix(strings.ToUpper(strings.TrimSpace(code.Value)), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type DataCategories []DataCategory
+
+// Cover reports whether any category matches an event. No categories cover everything.
+func (cc DataCategories) Cover(eventType timeline.EventType, codes types.Codes) bool {
+	if len(cc) == 0 {
+		return true
+	}
+	for _, c := range cc {
+		if c.Matches(eventType, codes) {
+			return true
+		}
+	}
+	return false
+}
+
+// Terms are the purpose-bound conditions a grant is given under. A grant without a
+// purpose predates them and is treated as a treatment grant.
+type Terms struct {
+	Purpose       Purpose        `json:"purpose,omitempty"`
+	Categories    DataCategories `json:"categories,omitempty"`
+	StudyID       string         `json:"studyId,omitempty"`
+	SecondaryUses []SecondaryUse `json:"secondaryUses,omitempty"`
+}
+
+// EffectivePurpose returns the purpose the terms are enforced under.
+func (t Terms) EffectivePurpose() Purpose {
+	if t.Purpose == "" {
+		return PurposeTreatment
+	}
+	return t.Purpose
+}
+
+// Validate checks the terms against the permissions they are granted with. Research
+// terms must name their study; only research terms may name a study or list
+// secondary uses.
+func (t Terms) Validate(permissions Permissions) error {
+	var errs types.ValidationErrors
+	t.validate(&errs, permissions)
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+func (t Terms) validate(errs *types.ValidationErrors, permissions Permissions) {
+	if t.Purpose != "" && !t.Purpose.IsValid() {
+		errs.Add("purpose", "invalid purpose: "+string(t.Purpose))
+		return
+	}
+
+	purpose := t.EffectivePurpose()
+	for _, p := range permissions {
+		if !purpose.AllowsPermission(p) {
+			errs.Add("permissions", "permission "+string(p)+" not allowed for purpose "+string(purpose))
+		}
+	}
+
+	studyID := strings.TrimSpace(t.StudyID)
+	switch {
+	case purpose == PurposeResearch && studyID == "":
+		errs.Add("studyId", "research consent must name a study")
+	case purpose != PurposeResearch && studyID != "":
+		errs.Add("studyId", "only research consent may name a study")
+	}
+
+	if len(t.SecondaryUses) > 0 && purpose != PurposeResearch {
+		errs.Add("secondaryUses", "only research consent may permit secondary uses")
+	}
+	for _, u := range t.SecondaryUses {
+		if !u.IsValid() {
+			errs.Add("secondaryUses", "invalid secondary use: "+string(u))
+		}
+	}
+
+	for _, c := range t.Categories {
+		c.validate(errs)
+	}
+}
+
+// Use is what a grantee declares an access is for. The zero Use declares nothing.
+type Use struct {
+	Purpose      Purpose
+	StudyID      string
+	SecondaryUse SecondaryUse
+}
+
+// Permits reports whether the terms allow an access declared as use. A declared
+// purpose must match the grant's. Research grants are bound to their study: they
+// serve only accesses naming it, and no other grant serves an access naming a study.
+// A declared secondary use must be one the patient permitted.
+func (t Terms) Permits(use Use) bool {
+	purpose := t.EffectivePurpose()
+	if use.Purpose != "" && use.Purpose != purpose {
+		return false
+	}
+
+	studyID := strings.TrimSpace(use.StudyID)
+	if purpose == PurposeResearch {
+		if studyID == "" || studyID != strings.TrimSpace(t.StudyID) {
+			return false
+		}
+	} else if studyID != "" {
+		return false
+	}
+
+	if use.SecondaryUse != "" && !slices.Contains(t.SecondaryUses, use.SecondaryUse) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package consent
+
+import (
+	"testing"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/timeline"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+func TestTerms_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		terms       Terms
+		permissions Permissions
+		wantErr     bool
+	}{
+		{
+			name:        "no terms",
+			terms:       Terms{},
+			permissions: Permissions{PermRead, PermWrite},
+		},
+		{
+			name:        "research bound to a study",
+			terms:       Terms{Purpose: PurposeResearch, StudyID: "NCT01234567", SecondaryUses: []SecondaryUse{SecondaryUsePublication}},
+			permissions: Permissions{PermRead},
+		},
+		{
+			name:        "invalid purpose",
+			terms:       Terms{Purpose: "marketing"},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "research without a study",
+			terms:       Terms{Purpose: PurposeResearch},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "research with write",
+			terms:       Terms{Purpose: PurposeResearch, StudyID: "NCT01234567"},
+			permissions: Permissions{PermRead, PermWrite},
+			wantErr:     true,
+		},
+		{
+			name:        "insurance with share",
+			terms:       Terms{Purpose: PurposeInsurance},
+			permissions: Permissions{PermShare},
+			wantErr:     true,
+		},
+		{
+			name:        "study outside research",
+			terms:       Terms{Purpose: PurposeTreatment, StudyID: "NCT01234567"},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "secondary use outside research",
+			terms:       Terms{Purpose: PurposeSecondOpinion, SecondaryUses: []SecondaryUse{SecondaryUsePublication}},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid secondary use",
+			terms:       Terms{Purpose: PurposeResearch, StudyID: "NCT01234567", SecondaryUses: []SecondaryUse{"resale"}},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "code prefix without system",
+			terms:       Terms{Categories: DataCategories{{EventType: timeline.EventDiagnosis, CodePrefix: "E11"}}},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid coding system",
+			terms:       Terms{Categories: DataCategories{{System: "ICD-9"}}},
+			permissions: Permissions{PermRead},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.terms.Validate(tt.permissions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGrant_Validate_Terms(t *testing.T) {
+	g := newValidGrant()
+	g.Purpose = PurposeResearch
+	if err := g.Validate(); err == nil {
+		t.Error("Validate() accepted research consent without a study")
+	}
+	g.StudyID = "NCT01234567"
+	if err := g.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestTerms_Permits(t *testing.T) {
+	research := Terms{Purpose: PurposeResearch, StudyID: "NCT01234567", SecondaryUses: []SecondaryUse{SecondaryUseAggregateAnalysis}}
+	treatment := Terms{}
+
+	tests := []struct {
+		name  string
+		terms Terms
+		use   Use
+		want  bool
+	}{
+		{"undeclared treatment access", treatment, Use{}, true},
+		{"declared treatment", treatment, Use{Purpose: PurposeTreatment}, true},
+		{"wrong purpose", treatment, Use{Purpose: PurposeInsurance}, false},
+		{"study on a treatment grant", treatment, Use{StudyID: "NCT01234567"}, false},
+		{"research needs its study", research, Use{Purpose: PurposeResearch}, false},
+		{"research for its study", research, Use{Purpose: PurposeResearch, StudyID: "NCT01234567"}, true},
+		{"research for another study", research, Use{Purpose: PurposeResearch, StudyID: "NCT07654321"}, false},
+		{"permitted secondary use", research, Use{StudyID: "NCT01234567", SecondaryUse: SecondaryUseAggregateAnalysis}, true},
+		{"unpermitted secondary use", research, Use{StudyID: "NCT01234567", SecondaryUse: SecondaryUseModelTraining}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.terms.Permits(tt.use); got != tt.want {
+				t.Errorf("Permits(%+v) = %v, want %v", tt.use, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGrant_CoversEvent_Categories(t *testing.T) {
+	g := newValidGrant()
+	g.State = StateApproved
+	g.Categories = DataCategories{
+		{EventType: timeline.EventLabResult},
+		{EventType: timeline.EventDiagnosis, System: types.CodingICD10, CodePrefix: "e11"},
+	}
+
+	diabetes := types.Codes{{System: types.CodingICD10, Value: "E11.9"}}
+	asthma := types.Codes{{System: types.CodingICD10, Value: "J45.909"}}
+
+	if !g.CoversEvent("evt-1", timeline.EventLabResult, nil) {
+		t.Error("CoversEvent(lab result) = false, want true")
+	}
+	if !g.CoversEvent("evt-2", timeline.EventDiagnosis, diabetes) {
+		t.Error("CoversEvent(E11.9 diagnosis) = false, want true")
+	}
+	if g.CoversEvent("evt-3", timeline.EventDiagnosis, asthma) {
+		t.Error("CoversEvent(J45 diagnosis) = true, want false")
+	}
+	if g.CoversEvent("evt-4", timeline.EventPrescription, diabetes) {
+		t.Error("CoversEvent(prescription) = true, want false")
+	}
+
+	g.Scope = []types.ID{"evt-2"}
+	if g.CoversEvent("evt-1", timeline.EventLabResult, nil) {
+		t.Error("CoversEvent() ignored the scope")
+	}
+}
@@ -0,0 +1,196 @@
+package consent
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+const SchemaVersionReceipt = protocol.SchemaVersionReceipt
+
+// SignatureAlgorithmEIP712 identifies a wallet eth_signTypedData_v4 signature over a
+// receipt's typed data.
+const SignatureAlgorithmEIP712 = "EIP712"
+
+// ReceiptAction is a change to a grant that the acting party must sign.
+type ReceiptAction string
+
+const (
+	ReceiptApprove ReceiptAction = "approve"
+	ReceiptRevoke  ReceiptAction = "revoke"
+	ReceiptSuspend ReceiptAction = "suspend"
+)
+
+func ValidReceiptActions() []ReceiptAction {
+	return []ReceiptAction{ReceiptApprove, ReceiptRevoke, ReceiptSuspend}
+}
+
+func (a ReceiptAction) IsValid() bool {
+	return slices.Contains(ValidReceiptActions(), a)
+}
+
+// ReceiptPrimaryType is the EIP-712 struct a consent receipt signs.
+const ReceiptPrimaryType = "ConsentReceipt"
+
+// ReceiptDomain returns the EIP-712 domain of consent receipts. It names no chain,
+// since consent lives off-chain and a patient may sign from any network.
+func ReceiptDomain() crypto.TypedDataDomain {
+	return crypto.TypedDataDomain{Name: "Fleming Consent", Version: "1"}
+}
+
+// ReceiptTypes returns the EIP-712 types of consent receipts.
+func ReceiptTypes() crypto.TypedDataTypes {
+	return crypto.TypedDataTypes{
+		"EIP712Domain": ReceiptDomain().Fields(),
+		"DataCategory": {
+			{Name: "eventType", Type: "string"},
+			{Name: "system", Type: "string"},
+			{Name: "codePrefix", Type: "string"},
+		},
+		ReceiptPrimaryType: {
+			{Name: "grantId", Type: "string"},
+			{Name: "action", Type: "string"},
+			{Name: "grantor", Type: "address"},
+			{Name: "grantee", Type: "address"},
+			{Name: "scope", Type: "string[]"},
+			{Name: "permissions", Type: "string[]"},
+			{Name: "purpose", Type: "string"},
+			{Name: "studyId", Type: "string"},
+			{Name: "categories", Type: "DataCategory[]"},
+			{Name: "secondaryUses", Type: "string[]"},
+			{Name: "expiresAt", Type: "uint256"},
+			{Name: "nonce", Type: "uint256"},
+		},
+	}
+}
+
+// ReceiptTypedData returns the typed data a party signs to take action on a grant.
+// It binds the grant's canonical content: both parties, the scope, permissions and
+// purpose-bound terms including the permitted secondary uses, the expiry (Unix
+// seconds, zero for none) and the grant's receipt nonce, so a signature cannot be
+// replayed once the grant has moved on. Scope, permissions and secondary uses are
+// sorted, so stored order does not matter.
+func ReceiptTypedData(g *Grant, action ReceiptAction, nonce uint64) *crypto.TypedData {
+	scope := make([]string, len(g.Scope))
+	for i, id := range g.Scope {
+		scope[i] = id.String()
+	}
+	slices.Sort(scope)
+
+	perms := make([]string, len(g.Permissions))
+	for i, p := range g.Permissions {
+		perms[i] = string(p)
+	}
+	slices.Sort(perms)
+
+	uses := make([]string, len(g.SecondaryUses))
+	for i, u := range g.SecondaryUses {
+		uses[i] = string(u)
+	}
+	slices.Sort(uses)
+
+	categories := make([]map[string]any, len(g.Categories))
+	for i, c := range g.Categories {
+		categories[i] = map[string]any{
+			"eventType":  string(c.EventType),
+			"system":     string(c.System),
+			"codePrefix": c.CodePrefix,
+		}
+	}
+
+	var expiresAt int64
+	if !g.ExpiresAt.IsZero() {
+		expiresAt = g.ExpiresAt.Unix()
+	}
+
+	return &crypto.TypedData{
+		Types:       ReceiptTypes(),
+		PrimaryType: ReceiptPrimaryType,
+		Domain:      ReceiptDomain(),
+		Message: map[string]any{
+			"grantId":       g.ID.String(),
+			"action":        string(action),
+			"grantor":       g.Grantor.String(),
+			"grantee":       g.Grantee.String(),
+			"scope":         scope,
+			"permissions":   perms,
+			"purpose":       string(g.EffectivePurpose()),
+			"studyId":       strings.TrimSpace(g.StudyID),
+			"categories":    categories,
+			"secondaryUses": uses,
+			"expiresAt":     expiresAt,
+			"nonce":         nonce,
+		},
+	}
+}
+
+// Receipt is portable evidence that a party signed a change to a grant. It carries
+// the typed data as signed, so anyone can check it without access to Fleming.
+// OnBehalfOf names the patient when a delegate signed for them.
+type Receipt struct {
+	ID                 types.ID            `json:"id"`
+	GrantID            types.ID            `json:"grantId"`
+	Action             ReceiptAction       `json:"action"`
+	Signer             types.WalletAddress `json:"signer"`
+	OnBehalfOf         types.WalletAddress `json:"onBehalfOf,omitempty"`
+	DelegationID       types.ID            `json:"delegationId,omitempty"`
+	TypedData          crypto.TypedData    `json:"typedData"`
+	Signature          string              `json:"signature"`
+	SignatureAlgorithm string              `json:"signatureAlgorithm"`
+	SignedAt           time.Time           `json:"signedAt"`
+	SchemaVersion      string              `json:"schemaVersion,omitempty"`
+}
+
+func (r *Receipt) Validate() error {
+	var errs types.ValidationErrors
+
+	if r.GrantID.IsEmpty() {
+		errs.Add("grantId", "grant ID is required")
+	}
+	if !r.Action.IsValid() {
+		errs.Add("action", "invalid receipt action")
+	}
+	if r.Signer.IsEmpty() {
+		errs.Add("signer", "signer address is required")
+	}
+	if r.Signature == "" {
+		errs.Add("signature", "signature is required")
+	}
+	if r.SignatureAlgorithm != SignatureAlgorithmEIP712 {
+		errs.Add("signatureAlgorithm", "unsupported signature algorithm")
+	}
+	if r.TypedData.PrimaryType != ReceiptPrimaryType {
+		errs.Add("typedData", "typed data is not a consent receipt")
+	}
+	if !reflect.DeepEqual(r.TypedData.Types, ReceiptTypes()) {
+		errs.Add("typedData", "typed data does not use the consent receipt types")
+	}
+	if r.TypedData.Domain != ReceiptDomain() {
+		errs.Add("typedData", "typed data is not in the consent receipt domain")
+	}
+	if grantID, _ := r.TypedData.Message["grantId"].(string); grantID != r.GrantID.String() {
+		errs.Add("typedData", "typed data names another grant")
+	}
+	if action, _ := r.TypedData.Message["action"].(string); action != string(r.Action) {
+		errs.Add("typedData", "typed data names another action")
+	}
+
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// Verify reports whether the receipt is well-formed and Signature is the signer's
+// EIP-712 signature over its typed data.
+func (r *Receipt) Verify() bool {
+	if r.Validate() != nil {
+		return false
+	}
+	return crypto.VerifyTypedDataSignature(&r.TypedData, r.Signature, r.Signer.String())
+}
@@ -59,6 +59,7 @@ var validTransitions = []Transition{
 	// From Suspended (can resume or permanently revoke)
 	{StateSuspended, StateApproved, "resume"}, // NEW: Resume suspended consent
 	{StateSuspended, StateRevoked, "revoke"},  // NEW: Permanently revoke from suspended
+	{StateSuspended, StateExpired, "expire"},  // A suspended grant still runs out at its TTL
 }
 
 func ValidTransitions() []Transition {
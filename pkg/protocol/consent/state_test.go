@@ -89,7 +89,7 @@ func TestCanTransition(t *testing.T) {
 		{"denied to approved", StateDenied, StateApproved, false},
 		{"revoked to approved", StateRevoked, StateApproved, false},
 		{"suspended to denied", StateSuspended, StateDenied, false},
-		{"suspended to expired", StateSuspended, StateExpired, false},
+		{"suspended to expired", StateSuspended, StateExpired, true},
 	}
 
 	for _, tt := range tests {
@@ -116,6 +116,7 @@ func TestGetAction(t *testing.T) {
 		{"approved to suspended", StateApproved, StateSuspended, "suspend", true},
 		{"suspended to approved", StateSuspended, StateApproved, "resume", true},
 		{"suspended to revoked", StateSuspended, StateRevoked, "revoke", true},
+		{"suspended to expired", StateSuspended, StateExpired, "expire", true},
 		{"denied to approved", StateDenied, StateApproved, "", false},
 		{"invalid transition", StateRequested, StateRevoked, "", false},
 	}
@@ -145,6 +146,7 @@ func TestTryTransition(t *testing.T) {
 		{"approved to suspended", StateApproved, StateSuspended, false},
 		{"suspended to approved", StateSuspended, StateApproved, false},
 		{"suspended to revoked", StateSuspended, StateRevoked, false},
+		{"suspended to expired", StateSuspended, StateExpired, false},
 		{"denied to approved", StateDenied, StateApproved, true},
 		{"requested to revoked", StateRequested, StateRevoked, true},
 		{"suspended to denied", StateSuspended, StateDenied, true},
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"crypto/ecdsa"
 	"fmt"
 	"strings"
 
@@ -8,7 +9,14 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 )
 
+// VerifySignature reports whether signatureHex is an EIP-191 personal_sign
+// signature of message produced by addressHex.
 func VerifySignature(message string, signatureHex string, addressHex string) bool {
+	return verifyHash(personalMessageHash(message), signatureHex, addressHex)
+}
+
+// verifyHash reports whether signatureHex signs hash and was produced by addressHex.
+func verifyHash(hash []byte, signatureHex string, addressHex string) bool {
 	sig, err := hexutil.Decode(signatureHex)
 	if err != nil {
 		return false
@@ -22,10 +30,6 @@ func VerifySignature(message string, signatureHex string, addressHex string) boo
 		sig[64] -= 27
 	}
 
-	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
-
-	hash := crypto.Keccak256([]byte(prefix))
-
 	pubKeyBytes, err := crypto.Ecrecover(hash, sig)
 	if err != nil {
 		return false
@@ -40,3 +44,19 @@ func VerifySignature(message string, signatureHex string, addressHex string) boo
 
 	return strings.EqualFold(recoveredAddr.Hex(), addressHex)
 }
+
+// SignMessage produces an EIP-191 personal_sign signature of message, in the
+// same 0x-prefixed, v=27/28 form wallets return and VerifySignature accepts.
+func SignMessage(message string, key *ecdsa.PrivateKey) (string, error) {
+	sig, err := crypto.Sign(personalMessageHash(message), key)
+	if err != nil {
+		return "", fmt.Errorf("sign message: %w", err)
+	}
+	sig[64] += 27
+	return hexutil.Encode(sig), nil
+}
+
+func personalMessageHash(message string) []byte {
+	prefix := fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)
+	return crypto.Keccak256([]byte(prefix))
+}
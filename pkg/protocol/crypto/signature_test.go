@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestSignMessage_RoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	sig, err := SignMessage("hello fleming", key)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+
+	if !VerifySignature("hello fleming", sig, address) {
+		t.Error("VerifySignature() rejected a signature produced by SignMessage")
+	}
+	if VerifySignature("hello fleming!", sig, address) {
+		t.Error("VerifySignature() accepted a signature over a different message")
+	}
+	if VerifySignature("hello fleming", sig, "0x0000000000000000000000000000000000000001") {
+		t.Error("VerifySignature() accepted a signature for a different address")
+	}
+}
+
+func TestVerifySignature_RejectsMalformed(t *testing.T) {
+	if VerifySignature("msg", "0x1234", "0x0000000000000000000000000000000000000001") {
+		t.Error("VerifySignature() accepted a short signature")
+	}
+	if VerifySignature("msg", "not-hex", "0x0000000000000000000000000000000000000001") {
+		t.Error("VerifySignature() accepted a non-hex signature")
+	}
+}
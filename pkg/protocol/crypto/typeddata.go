@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// TypedDataField is one member of an EIP-712 struct type.
+type TypedDataField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// TypedDataTypes maps struct type names to their members, in declaration order.
+type TypedDataTypes map[string][]TypedDataField
+
+// TypedDataDomain separates one application's signatures from another's. Empty
+// fields are left out of the domain type.
+type TypedDataDomain struct {
+	Name              string `json:"name,omitempty"`
+	Version           string `json:"version,omitempty"`
+	ChainID           int64  `json:"chainId,omitempty"`
+	VerifyingContract string `json:"verifyingContract,omitempty"`
+}
+
+// Fields returns the EIP712Domain members the domain sets.
+func (d TypedDataDomain) Fields() []TypedDataField {
+	var fields []TypedDataField
+	if d.Name != "" {
+		fields = append(fields, TypedDataField{Name: "name", Type: "string"})
+	}
+	if d.Version != "" {
+		fields = append(fields, TypedDataField{Name: "version", Type: "string"})
+	}
+	if d.ChainID != 0 {
+		fields = append(fields, TypedDataField{Name: "chainId", Type: "uint256"})
+	}
+	if d.VerifyingContract != "" {
+		fields = append(fields, TypedDataField{Name: "verifyingContract", Type: "address"})
+	}
+	return fields
+}
+
+// TypedData is an EIP-712 payload in the shape eth_signTypedData_v4 accepts.
+// Message values may be Go values or what encoding/json decodes them to, so a
+// payload survives a JSON round trip with the same hash.
+type TypedData struct {
+	Types       TypedDataTypes  `json:"types"`
+	PrimaryType string          `json:"primaryType"`
+	Domain      TypedDataDomain `json:"domain"`
+	Message     map[string]any  `json:"message"`
+}
+
+const domainType = "EIP712Domain"
+
+// Hash returns the EIP-712 digest a wallet signs:
+// keccak256("\x19\x01" || domainSeparator || hashStruct(message)). The encoding is
+// go-ethereum's, the one its eth_signTypedData_v4 implementation signs.
+func (td *TypedData) Hash() ([]byte, error) {
+	hash, _, err := apitypes.TypedDataAndHash(td.apitypes())
+	if err != nil {
+		return nil, fmt.Errorf("hash typed data: %w", err)
+	}
+	return hash, nil
+}
+
+// apitypes converts the payload for go-ethereum, adding the domain type when Types
+// leaves it out.
+func (td *TypedData) apitypes() apitypes.TypedData {
+	types := make(apitypes.Types, len(td.Types)+1)
+	types[domainType] = toTypes(td.Domain.Fields())
+	for name, fields := range td.Types {
+		types[name] = toTypes(fields)
+	}
+
+	domain := apitypes.TypedDataDomain{
+		Name:              td.Domain.Name,
+		Version:           td.Domain.Version,
+		VerifyingContract: td.Domain.VerifyingContract,
+	}
+	if td.Domain.ChainID != 0 {
+		domain.ChainId = (*math.HexOrDecimal256)(big.NewInt(td.Domain.ChainID))
+	}
+
+	message, _ := normalizeValue(td.Message).(map[string]any)
+	return apitypes.TypedData{
+		Types:       types,
+		PrimaryType: td.PrimaryType,
+		Domain:      domain,
+		Message:     message,
+	}
+}
+
+func toTypes(fields []TypedDataField) []apitypes.Type {
+	types := make([]apitypes.Type, len(fields))
+	for i, field := range fields {
+		types[i] = apitypes.Type{Name: field.Name, Type: field.Type}
+	}
+	return types
+}
+
+// normalizeValue rewrites the Go integers go-ethereum does not accept as *big.Int
+// and json.Number as its string form, recursing into maps and slices.
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case int:
+		return big.NewInt(int64(v))
+	case int64:
+		return big.NewInt(v)
+	case uint64:
+		return new(big.Int).SetUint64(v)
+	case json.Number:
+		return v.String()
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, item := range v {
+			out[key] = normalizeValue(item)
+		}
+		return out
+	case []map[string]any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalizeValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+// VerifyTypedDataSignature reports whether signatureHex is an EIP-712
+// (eth_signTypedData_v4) signature of data produced by addressHex.
+func VerifyTypedDataSignature(data *TypedData, signatureHex string, addressHex string) bool {
+	hash, err := data.Hash()
+	if err != nil {
+		return false
+	}
+	return verifyHash(hash, signatureHex, addressHex)
+}
+
+// SignTypedData produces an EIP-712 signature of data, in the same 0x-prefixed,
+// v=27/28 form wallets return and VerifyTypedDataSignature accepts.
+func SignTypedData(data *TypedData, key *ecdsa.PrivateKey) (string, error) {
+	hash, err := data.Hash()
+	if err != nil {
+		return "", err
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return "", fmt.Errorf("sign typed data: %w", err)
+	}
+	sig[64] += 27
+	return hexutil.Encode(sig), nil
+}
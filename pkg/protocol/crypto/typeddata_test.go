@@ -0,0 +1,124 @@
+package crypto
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// mailTypedData is the example from the EIP-712 specification.
+func mailTypedData() *TypedData {
+	return &TypedData{
+		Types: TypedDataTypes{
+			"Person": {{Name: "name", Type: "string"}, {Name: "wallet", Type: "address"}},
+			"Mail":   {{Name: "from", Type: "Person"}, {Name: "to", Type: "Person"}, {Name: "contents", Type: "string"}},
+		},
+		PrimaryType: "Mail",
+		Domain: TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainID:           1,
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: map[string]any{
+			"from":     map[string]any{"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+			"to":       map[string]any{"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func TestTypedData_Hash_SpecVector(t *testing.T) {
+	td := mailTypedData()
+
+	hash, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	if got, want := hexutil.Encode(hash), "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"; got != want {
+		t.Errorf("Hash() = %s, want %s", got, want)
+	}
+
+	sig := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c"
+	if !VerifyTypedDataSignature(td, sig, "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826") {
+		t.Error("VerifyTypedDataSignature() rejected the specification's signature")
+	}
+}
+
+func TestTypedData_Hash_SurvivesJSON(t *testing.T) {
+	td := &TypedData{
+		Types: TypedDataTypes{
+			"Item":  {{Name: "label", Type: "string"}},
+			"Order": {{Name: "items", Type: "Item[]"}, {Name: "tags", Type: "string[]"}, {Name: "nonce", Type: "uint256"}, {Name: "paid", Type: "bool"}},
+		},
+		PrimaryType: "Order",
+		Domain:      TypedDataDomain{Name: "Test", Version: "1"},
+		Message: map[string]any{
+			"items": []map[string]any{{"label": "a"}, {"label": "b"}},
+			"tags":  []string{"x", "y"},
+			"nonce": uint64(7),
+			"paid":  true,
+		},
+	}
+	want, err := td.Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	raw, err := json.Marshal(td)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var decoded TypedData
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	got, err := decoded.Hash()
+	if err != nil {
+		t.Fatalf("Hash() after round trip error = %v", err)
+	}
+	if hexutil.Encode(got) != hexutil.Encode(want) {
+		t.Error("Hash() changed after a JSON round trip")
+	}
+}
+
+func TestSignTypedData_RoundTrip(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+
+	td := mailTypedData()
+	sig, err := SignTypedData(td, key)
+	if err != nil {
+		t.Fatalf("SignTypedData() error = %v", err)
+	}
+	if !VerifyTypedDataSignature(td, sig, address) {
+		t.Error("VerifyTypedDataSignature() rejected a signature produced by SignTypedData")
+	}
+
+	td.Message["contents"] = "Hello, Eve!"
+	if VerifyTypedDataSignature(td, sig, address) {
+		t.Error("VerifyTypedDataSignature() accepted a signature over a different message")
+	}
+	if VerifySignature("Hello, Bob!", sig, address) {
+		t.Error("VerifySignature() accepted a typed-data signature as a personal_sign one")
+	}
+}
+
+func TestTypedData_Hash_RejectsMalformed(t *testing.T) {
+	td := mailTypedData()
+	td.Message["from"] = map[string]any{"name": "Cow", "wallet": "not-an-address"}
+	if _, err := td.Hash(); err == nil {
+		t.Error("Hash() accepted an invalid address")
+	}
+
+	td = mailTypedData()
+	td.PrimaryType = "Letter"
+	if _, err := td.Hash(); err == nil {
+		t.Error("Hash() accepted an unknown primary type")
+	}
+}
@@ -0,0 +1,91 @@
+package delegation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// DelegationBuilder provides a fluent interface for building Delegations.
+// Follows the Builder pattern used throughout the protocol layer.
+type DelegationBuilder struct {
+	d    *Delegation
+	errs types.ValidationErrors
+}
+
+// NewDelegationBuilder creates a new DelegationBuilder with default values.
+func NewDelegationBuilder() *DelegationBuilder {
+	return &DelegationBuilder{
+		d: &Delegation{
+			ID:            types.ID(uuid.New().String()),
+			Status:        StatusPending,
+			SchemaVersion: SchemaVersionDelegation,
+		},
+		errs: types.ValidationErrors{},
+	}
+}
+
+// WithPrincipal sets the patient the delegate acts as.
+func (b *DelegationBuilder) WithPrincipal(addr types.WalletAddress) *DelegationBuilder {
+	if addr.IsEmpty() {
+		b.errs.Add("principal", "principal address is required")
+	}
+	b.d.Principal = addr
+	return b
+}
+
+// WithDelegate sets the guardian or caregiver.
+func (b *DelegationBuilder) WithDelegate(addr types.WalletAddress) *DelegationBuilder {
+	if addr.IsEmpty() {
+		b.errs.Add("delegate", "delegate address is required")
+	}
+	b.d.Delegate = addr
+	return b
+}
+
+// WithRelationship sets how the delegate stands to the patient.
+func (b *DelegationBuilder) WithRelationship(r Relationship) *DelegationBuilder {
+	if !r.IsValid() {
+		b.errs.Add("relationship", "invalid relationship")
+	}
+	b.d.Relationship = r
+	return b
+}
+
+// WithPermissions sets what the delegate may do, dropping duplicates.
+func (b *DelegationBuilder) WithPermissions(perms ...Permission) *DelegationBuilder {
+	b.d.Permissions = nil
+	for _, p := range perms {
+		if !b.d.Allows(p) {
+			b.d.Permissions = append(b.d.Permissions, p)
+		}
+	}
+	return b
+}
+
+// WithExpiresAt sets when the delegation ends. It must be in the future and at most
+// MaxDuration away.
+func (b *DelegationBuilder) WithExpiresAt(t time.Time) *DelegationBuilder {
+	now := time.Now()
+	switch {
+	case !t.After(now):
+		b.errs.Add("expiresAt", "expiry must be in the future")
+	case t.After(now.Add(MaxDuration)):
+		b.errs.Add("expiresAt", "delegations may run for at most a year")
+	}
+	b.d.ExpiresAt = t.UTC().Truncate(time.Second)
+	return b
+}
+
+// Build validates and returns the delegation.
+// The delegation is built in Pending status until the patient signs it.
+func (b *DelegationBuilder) Build() (*Delegation, error) {
+	if b.errs.HasErrors() {
+		return nil, b.errs
+	}
+	if err := b.d.Validate(); err != nil {
+		return nil, err
+	}
+	return b.d, nil
+}
@@ -0,0 +1,52 @@
+package delegation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelegationBuilder_Build(t *testing.T) {
+	d, err := NewDelegationBuilder().
+		WithPrincipal("0x1111111111111111111111111111111111111111").
+		WithDelegate("0x2222222222222222222222222222222222222222").
+		WithRelationship(RelationshipCaregiver).
+		WithPermissions(PermTimelineRead, PermTimelineRead, PermAuditRead).
+		WithExpiresAt(time.Now().Add(90 * 24 * time.Hour)).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if d.ID.IsEmpty() || d.Status != StatusPending || d.SchemaVersion != SchemaVersionDelegation {
+		t.Errorf("Build() = %+v, want a pending delegation with an ID", d)
+	}
+	if len(d.Permissions) != 2 {
+		t.Errorf("Build() permissions = %v, want duplicates dropped", d.Permissions)
+	}
+}
+
+func TestDelegationBuilder_Expiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		wantErr   bool
+	}{
+		{"a month", time.Now().Add(30 * 24 * time.Hour), false},
+		{"in the past", time.Now().Add(-time.Hour), true},
+		{"over a year", time.Now().Add(MaxDuration + time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewDelegationBuilder().
+				WithPrincipal("0x1111111111111111111111111111111111111111").
+				WithDelegate("0x2222222222222222222222222222222222222222").
+				WithRelationship(RelationshipGuardian).
+				WithPermissions(PermConsentManage).
+				WithExpiresAt(tt.expiresAt).
+				Build()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package delegation
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+)
+
+// SignatureAlgorithmEIP191 identifies a wallet personal_sign signature over SigningMessage.
+const SignatureAlgorithmEIP191 = "EIP191"
+
+// SigningMessage returns the text the patient signs with their wallet. It binds the
+// delegation ID, both parties, the relationship, the permissions and the expiry, so
+// none of them can be changed without invalidating the signature. Permissions are
+// listed sorted, so their order in the request does not matter.
+func (d *Delegation) SigningMessage() string {
+	perms := make([]string, len(d.Permissions))
+	for i, p := range d.Permissions {
+		perms[i] = string(p)
+	}
+	slices.Sort(perms)
+
+	var b strings.Builder
+	b.WriteString("Fleming Delegation\n")
+	fmt.Fprintf(&b, "Delegation: %s\n", d.ID)
+	fmt.Fprintf(&b, "Patient: %s\n", d.Principal)
+	fmt.Fprintf(&b, "Delegate: %s\n", d.Delegate)
+	fmt.Fprintf(&b, "Relationship: %s\n", d.Relationship)
+	fmt.Fprintf(&b, "Permissions: %s\n", strings.Join(perms, ", "))
+	fmt.Fprintf(&b, "Expires: %s", d.ExpiresAt.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// VerifySignature reports whether Signature is the principal's signature over SigningMessage.
+func (d *Delegation) VerifySignature() bool {
+	if d.SignatureAlgorithm != SignatureAlgorithmEIP191 {
+		return false
+	}
+	return crypto.VerifySignature(d.SigningMessage(), d.Signature, d.Principal.String())
+}
@@ -0,0 +1,81 @@
+package delegation
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+func signedDelegation(t *testing.T) *Delegation {
+	t.Helper()
+
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	principal, _ := types.NewWalletAddress(ethcrypto.PubkeyToAddress(key.PublicKey).Hex())
+
+	d := &Delegation{
+		ID:            "delegation-1",
+		Principal:     principal,
+		Delegate:      "0x2222222222222222222222222222222222222222",
+		Relationship:  RelationshipGuardian,
+		Permissions:   []Permission{PermTimelineRead, PermConsentManage},
+		Status:        StatusActive,
+		ExpiresAt:     time.Now().Add(30 * 24 * time.Hour),
+		SchemaVersion: SchemaVersionDelegation,
+	}
+	sig, err := crypto.SignMessage(d.SigningMessage(), key)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	d.Signature = sig
+	d.SignatureAlgorithm = SignatureAlgorithmEIP191
+	return d
+}
+
+func TestDelegation_SigningMessage(t *testing.T) {
+	d := signedDelegation(t)
+	msg := d.SigningMessage()
+
+	for _, want := range []string{d.ID.String(), d.Principal.String(), d.Delegate.String(), string(d.Relationship), "consent.manage, timeline.read"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("SigningMessage() missing %q", want)
+		}
+	}
+
+	reordered := *d
+	reordered.Permissions = []Permission{PermConsentManage, PermTimelineRead}
+	if reordered.SigningMessage() != msg {
+		t.Error("SigningMessage() should not depend on permission order")
+	}
+}
+
+func TestDelegation_VerifySignature(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(d *Delegation)
+		want   bool
+	}{
+		{"valid", func(d *Delegation) {}, true},
+		{"permission added", func(d *Delegation) { d.Permissions = append(d.Permissions, PermTimelineWrite) }, false},
+		{"expiry extended", func(d *Delegation) { d.ExpiresAt = d.ExpiresAt.Add(time.Hour) }, false},
+		{"other delegate", func(d *Delegation) { d.Delegate = "0x3333333333333333333333333333333333333333" }, false},
+		{"signed by delegate", func(d *Delegation) { d.Principal = d.Delegate }, false},
+		{"unknown algorithm", func(d *Delegation) { d.SignatureAlgorithm = "ES256K" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := signedDelegation(t)
+			tt.mutate(d)
+			if got := d.VerifySignature(); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
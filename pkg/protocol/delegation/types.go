@@ -0,0 +1,164 @@
+// Package delegation provides patient-signed delegations for the Protocol layer.
+// A delegation lets a guardian or caregiver act as a patient, within a permission
+// set and until an expiry the patient chose, without holding the patient's wallet.
+package delegation
+
+import (
+	"slices"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// SchemaVersionDelegation is the schema version for delegations.
+const SchemaVersionDelegation = protocol.SchemaVersionDelegation
+
+// MaxDuration is the longest a single delegation may run before the patient signs a new one.
+const MaxDuration = 366 * 24 * time.Hour
+
+// Permission is something a delegate may do as the patient.
+type Permission string
+
+const (
+	PermTimelineRead  Permission = "timeline.read"  // Read the patient's timeline and files
+	PermTimelineWrite Permission = "timeline.write" // Add, edit and delete timeline events
+	PermConsentManage Permission = "consent.manage" // Approve, deny, suspend and revoke consent
+	PermAuditRead     Permission = "audit.read"     // Read the patient's audit trail and access reports
+)
+
+func ValidPermissions() []Permission {
+	return []Permission{PermTimelineRead, PermTimelineWrite, PermConsentManage, PermAuditRead}
+}
+
+func (p Permission) IsValid() bool {
+	return slices.Contains(ValidPermissions(), p)
+}
+
+// Relationship is how the delegate stands to the patient.
+type Relationship string
+
+const (
+	RelationshipGuardian  Relationship = "guardian"  // Parent or legal guardian of a minor or dependent adult
+	RelationshipCaregiver Relationship = "caregiver" // Family member or professional caring for the patient
+)
+
+func (r Relationship) IsValid() bool {
+	return r == RelationshipGuardian || r == RelationshipCaregiver
+}
+
+// Status is where a delegation is in its lifecycle.
+type Status string
+
+const (
+	StatusPending Status = "pending" // Created, awaiting the patient's signature
+	StatusActive  Status = "active"  // Signed; the delegate may act as the patient
+	StatusRevoked Status = "revoked" // Ended by the patient or given up by the delegate
+	StatusExpired Status = "expired" // Past ExpiresAt
+)
+
+func (s Status) IsValid() bool {
+	switch s {
+	case StatusPending, StatusActive, StatusRevoked, StatusExpired:
+		return true
+	}
+	return false
+}
+
+// Delegation is a patient's signed statement that Delegate may act as them with
+// Permissions until ExpiresAt.
+type Delegation struct {
+	// ID is the unique identifier for this delegation
+	ID types.ID `json:"id"`
+
+	// Principal is the patient the delegate acts for; they sign the delegation
+	Principal types.WalletAddress `json:"principal"`
+
+	// Delegate is the guardian or caregiver acting as the patient
+	Delegate types.WalletAddress `json:"delegate"`
+
+	// Relationship is how the delegate stands to the patient
+	Relationship Relationship `json:"relationship"`
+
+	// Permissions are what the delegate may do as the patient
+	Permissions []Permission `json:"permissions"`
+
+	// Status is the current status of the delegation
+	Status Status `json:"status"`
+
+	// ExpiresAt is when the delegation ends; every delegation expires
+	ExpiresAt time.Time `json:"expiresAt"`
+
+	// Signature is the principal's signature over SigningMessage
+	Signature string `json:"signature,omitempty"`
+
+	// SignatureAlgorithm is the algorithm used (e.g., "EIP191")
+	SignatureAlgorithm string `json:"signatureAlgorithm,omitempty"`
+
+	// SchemaVersion is the protocol schema version
+	SchemaVersion string `json:"schemaVersion"`
+}
+
+// Validate validates the delegation structure.
+func (d *Delegation) Validate() error {
+	var errs types.ValidationErrors
+
+	if d.ID.IsEmpty() {
+		errs.Add("id", "delegation ID is required")
+	}
+
+	if d.Principal.IsEmpty() {
+		errs.Add("principal", "principal address is required")
+	}
+
+	if d.Delegate.IsEmpty() {
+		errs.Add("delegate", "delegate address is required")
+	} else if d.Delegate.Equals(d.Principal) {
+		errs.Add("delegate", "a patient cannot delegate to themselves")
+	}
+
+	if !d.Relationship.IsValid() {
+		errs.Add("relationship", "invalid relationship")
+	}
+
+	if len(d.Permissions) == 0 {
+		errs.Add("permissions", "at least one permission is required")
+	}
+	for _, p := range d.Permissions {
+		if !p.IsValid() {
+			errs.Add("permissions", "invalid permission: "+string(p))
+		}
+	}
+
+	if !d.Status.IsValid() {
+		errs.Add("status", "invalid delegation status")
+	}
+
+	if d.ExpiresAt.IsZero() {
+		errs.Add("expiresAt", "expiry is required")
+	}
+
+	if d.Signature == "" && d.Status == StatusActive {
+		errs.Add("signature", "signature is required for active delegations")
+	}
+
+	if errs.HasErrors() {
+		return errs
+	}
+	return nil
+}
+
+// Allows reports whether the delegation carries a permission.
+func (d *Delegation) Allows(perm Permission) bool {
+	return slices.Contains(d.Permissions, perm)
+}
+
+// IsExpired reports whether the delegation is past its expiry at now.
+func (d *Delegation) IsExpired(now time.Time) bool {
+	return !now.Before(d.ExpiresAt)
+}
+
+// IsActive reports whether the delegate may act as the principal at now.
+func (d *Delegation) IsActive(now time.Time) bool {
+	return d.Status == StatusActive && !d.IsExpired(now)
+}
@@ -0,0 +1,52 @@
+package delegation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelegation_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(d *Delegation)
+		wantErr bool
+	}{
+		{"valid", func(d *Delegation) {}, false},
+		{"pending without signature", func(d *Delegation) { d.Status = StatusPending; d.Signature = "" }, false},
+		{"active without signature", func(d *Delegation) { d.Signature = "" }, true},
+		{"self delegation", func(d *Delegation) { d.Delegate = d.Principal }, true},
+		{"no permissions", func(d *Delegation) { d.Permissions = nil }, true},
+		{"invalid permission", func(d *Delegation) { d.Permissions = []Permission{"billing.pay"} }, true},
+		{"invalid relationship", func(d *Delegation) { d.Relationship = "friend" }, true},
+		{"no expiry", func(d *Delegation) { d.ExpiresAt = time.Time{} }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := signedDelegation(t)
+			tt.mutate(d)
+			if err := d.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDelegation_IsActive(t *testing.T) {
+	d := signedDelegation(t)
+	now := time.Now()
+
+	if !d.IsActive(now) {
+		t.Error("IsActive() = false for a signed, unexpired delegation")
+	}
+	if !d.Allows(PermConsentManage) || d.Allows(PermTimelineWrite) {
+		t.Errorf("Allows() does not match permissions %v", d.Permissions)
+	}
+	if d.IsActive(d.ExpiresAt) {
+		t.Error("IsActive() = true at expiry")
+	}
+	d.Status = StatusRevoked
+	if d.IsActive(now) {
+		t.Error("IsActive() = true for a revoked delegation")
+	}
+}
@@ -0,0 +1,149 @@
+package timeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// EventHashVersion identifies the canonical serialization produced by CanonicalBytes.
+// It is embedded in the serialized document, so any future change to the algorithm
+// yields different bytes instead of silently colliding with v1 hashes.
+const EventHashVersion = "event-hash.v1"
+
+// canonicalTimestampLayout always renders nine fractional digits in UTC, so the same
+// instant has exactly one textual form regardless of the precision it was stored with.
+const canonicalTimestampLayout = "2006-01-02T15:04:05.000000000Z"
+
+// canonicalEvent is the hashed view of an Event. Fields are declared in lexicographic
+// key order, which is the order encoding/json emits them in.
+type canonicalEvent struct {
+	BlobDigests   []string        `json:"blobDigests,omitempty"`
+	Codes         []canonicalCode `json:"codes"`
+	Description   string          `json:"description"`
+	ID            string          `json:"id"`
+	Metadata      types.Metadata  `json:"metadata"`
+	PatientID     string          `json:"patientId"`
+	Provider      string          `json:"provider"`
+	SchemaVersion string          `json:"schemaVersion"`
+	Timestamp     string          `json:"timestamp"`
+	Title         string          `json:"title"`
+	Type          EventType       `json:"type"`
+	Version       string          `json:"version"`
+}
+
+type canonicalCode struct {
+	Code    string             `json:"code"`
+	Display string             `json:"display"`
+	System  types.CodingSystem `json:"system"`
+}
+
+// CanonicalBytes returns the deterministic serialization of the event that Hash digests.
+//
+// The algorithm (event-hash.v1) builds a JSON object with these members:
+//
+//	blobDigests    sorted, de-duplicated, lowercase digests of attached files; omitted when none are given
+//	codes          array of {code, display, system}, sorted by system, then code, then display; [] when empty
+//	description    string, "" when unset
+//	id             string, "" when unset
+//	metadata       object, {} when unset
+//	patientId      lowercase wallet address
+//	provider       string, "" when unset
+//	schemaVersion  the event's schema version, or "timeline.v1" when unset
+//	timestamp      UTC, RFC 3339 with exactly nine fractional digits (2006-01-02T15:04:05.000000000Z)
+//	title          string
+//	type           event type
+//	version        the constant "event-hash.v1"
+//
+// Serialization has no insignificant whitespace, and object members are ordered by key
+// (byte-wise) at every nesting level, including inside metadata. Strings are UTF-8 with
+// only ", \ and control characters escaped, except that U+2028 and U+2029 are written as
+// \u2028 and \u2029. Numbers use the shortest round-tripping form, matching ECMAScript's
+// Number.prototype.toString, so JSON.stringify over a key-sorted object reproduces the bytes.
+// CreatedAt and UpdatedAt are storage bookkeeping and are not part of the hash.
+func (e *Event) CanonicalBytes(blobDigests ...string) ([]byte, error) {
+	codes := make([]canonicalCode, 0, len(e.Codes))
+	for _, code := range e.Codes {
+		codes = append(codes, canonicalCode{Code: code.Value, Display: code.Display, System: code.System})
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if codes[i].System != codes[j].System {
+			return codes[i].System < codes[j].System
+		}
+		if codes[i].Code != codes[j].Code {
+			return codes[i].Code < codes[j].Code
+		}
+		return codes[i].Display < codes[j].Display
+	})
+
+	metadata := e.Metadata
+	if metadata == nil {
+		metadata = types.NewMetadata()
+	}
+
+	schemaVersion := e.SchemaVersion
+	if schemaVersion == "" {
+		schemaVersion = SchemaVersionTimeline
+	}
+
+	doc := canonicalEvent{
+		BlobDigests:   canonicalDigests(blobDigests),
+		Codes:         codes,
+		Description:   e.Description,
+		ID:            e.ID.String(),
+		Metadata:      metadata,
+		PatientID:     strings.ToLower(e.PatientID.String()),
+		Provider:      e.Provider,
+		SchemaVersion: schemaVersion,
+		Timestamp:     e.Timestamp.UTC().Format(canonicalTimestampLayout),
+		Title:         e.Title,
+		Type:          e.Type,
+		Version:       EventHashVersion,
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("canonicalize event %s: %w", e.ID, err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Hash returns the lowercase hex SHA-256 of CanonicalBytes. This is the value an
+// attestation's EventHash binds to: any change to the hashed fields yields a new hash.
+func (e *Event) Hash(blobDigests ...string) (string, error) {
+	canonical, err := e.CanonicalBytes(blobDigests...)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func canonicalDigests(digests []string) []string {
+	if len(digests) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(digests))
+	out := make([]string, 0, len(digests))
+	for _, d := range digests {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		out = append(out, d)
+	}
+	sort.Strings(out)
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
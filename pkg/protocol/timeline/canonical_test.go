@@ -0,0 +1,197 @@
+package timeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// Test vectors for event-hash.v1. External implementations (browser, verifier CLIs)
+// should reproduce these bytes and hashes exactly; changing them is a breaking change.
+var canonicalVectors = []struct {
+	name      string
+	event     func() *Event
+	digests   []string
+	canonical string
+	hash      string
+}{
+	{
+		name: "minimal",
+		event: func() *Event {
+			return &Event{
+				ID:        "3f1c2b9e-4c1d-4a8e-9a51-2d8f0e7b6c11",
+				PatientID: "0xAbCdEf0123456789aBcDeF0123456789AbCdEf01",
+				Type:      EventNote,
+				Title:     "Follow-up",
+				Timestamp: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+			}
+		},
+		canonical: `{"codes":[],"description":"","id":"3f1c2b9e-4c1d-4a8e-9a51-2d8f0e7b6c11","metadata":{},"patientId":"0xabcdef0123456789abcdef0123456789abcdef01","provider":"","schemaVersion":"timeline.v1","timestamp":"2025-01-02T03:04:05.000000000Z","title":"Follow-up","type":"note","version":"event-hash.v1"}`,
+		hash:      "122c5c56eac3d5a2f37e6a17c7fa43fdad26bf38405dcfd0eb5607799c6b7d4e",
+	},
+	{
+		name: "full",
+		event: func() *Event {
+			return &Event{
+				ID:          "9b2e4f8a-1d3c-4e5f-8a7b-6c5d4e3f2a10",
+				PatientID:   "0x1111111111111111111111111111111111111111",
+				Type:        EventLabResult,
+				Title:       "Lipid panel <fasting> & follow-up",
+				Description: "Résultats: LDL ↓ — \"good\"",
+				Provider:    "Dr. Müller",
+				Codes: types.Codes{
+					{System: types.CodingSNOMED, Value: "16254007", Display: "Lipid panel"},
+					{System: types.CodingLOINC, Value: "2093-3", Display: "Cholesterol"},
+					{System: types.CodingLOINC, Value: "13457-7"},
+				},
+				Timestamp: time.Date(2025, 6, 30, 23, 15, 0, 123456000, time.FixedZone("BRT", -3*60*60)),
+				Metadata: types.Metadata{
+					"units":  "mg/dL",
+					"ldl":    98.5,
+					"hdl":    61,
+					"flags":  []any{"fasting", true, nil},
+					"ranges": map[string]any{"ldl": map[string]any{"max": 100, "min": 0}, "hdl": map[string]any{"min": 40}},
+					"tiny":   0.000001,
+					"huge":   1e21,
+				},
+				SchemaVersion: "timeline.v1",
+				CreatedAt:     time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC),
+			}
+		},
+		canonical: `{"codes":[{"code":"13457-7","display":"","system":"LOINC"},{"code":"2093-3","display":"Cholesterol","system":"LOINC"},{"code":"16254007","display":"Lipid panel","system":"SNOMED"}],"description":"Résultats: LDL ↓ — \"good\"","id":"9b2e4f8a-1d3c-4e5f-8a7b-6c5d4e3f2a10","metadata":{"flags":["fasting",true,null],"hdl":61,"huge":1e+21,"ldl":98.5,"ranges":{"hdl":{"min":40},"ldl":{"max":100,"min":0}},"tiny":0.000001,"units":"mg/dL"},"patientId":"0x1111111111111111111111111111111111111111","provider":"Dr. Müller","schemaVersion":"timeline.v1","timestamp":"2025-07-01T02:15:00.123456000Z","title":"Lipid panel <fasting> & follow-up","type":"lab_result","version":"event-hash.v1"}`,
+		hash:      "5d77a1999a7e5ab03de5b817e730a47ab90dcd0ff2c92946eb9162f2fec14b43",
+	},
+	{
+		name: "with blob digests",
+		event: func() *Event {
+			return &Event{
+				ID:        "c0ffee00-0000-4000-8000-000000000001",
+				PatientID: "0x2222222222222222222222222222222222222222",
+				Type:      EventImaging,
+				Title:     "Chest X-ray",
+				Timestamp: time.Date(2024, 12, 31, 12, 0, 0, 1, time.UTC),
+			}
+		},
+		digests: []string{
+			"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855",
+			"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+			"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		},
+		canonical: `{"blobDigests":["2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824","e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"],"codes":[],"description":"","id":"c0ffee00-0000-4000-8000-000000000001","metadata":{},"patientId":"0x2222222222222222222222222222222222222222","provider":"","schemaVersion":"timeline.v1","timestamp":"2024-12-31T12:00:00.000000001Z","title":"Chest X-ray","type":"imaging","version":"event-hash.v1"}`,
+		hash:      "ca01c5ee0a7d432ee9b160f7a3dd8c5ee48068bafc6ce91783346abe07b2cdd7",
+	},
+}
+
+func TestEvent_CanonicalBytes_Vectors(t *testing.T) {
+	for _, tt := range canonicalVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event()
+
+			canonical, err := event.CanonicalBytes(tt.digests...)
+			if err != nil {
+				t.Fatalf("CanonicalBytes() error = %v", err)
+			}
+			if string(canonical) != tt.canonical {
+				t.Errorf("CanonicalBytes() =\n%s\nwant\n%s", canonical, tt.canonical)
+			}
+
+			hash, err := event.Hash(tt.digests...)
+			if err != nil {
+				t.Fatalf("Hash() error = %v", err)
+			}
+			if hash != tt.hash {
+				t.Errorf("Hash() = %s, want %s", hash, tt.hash)
+			}
+		})
+	}
+}
+
+func TestEvent_Hash_Normalization(t *testing.T) {
+	base := canonicalVectors[1].event
+	want, err := base().Hash()
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+
+	same := map[string]func(e *Event){
+		"codes reordered": func(e *Event) {
+			e.Codes[0], e.Codes[2] = e.Codes[2], e.Codes[0]
+		},
+		"timestamp in another zone": func(e *Event) {
+			e.Timestamp = e.Timestamp.UTC()
+		},
+		"bookkeeping timestamps": func(e *Event) {
+			e.CreatedAt = time.Now()
+			e.UpdatedAt = time.Now()
+		},
+	}
+	for name, mutate := range same {
+		t.Run(name, func(t *testing.T) {
+			e := base()
+			mutate(e)
+			if got, _ := e.Hash(); got != want {
+				t.Errorf("Hash() = %s, want unchanged %s", got, want)
+			}
+		})
+	}
+
+	changed := map[string]func(e *Event){
+		"title":          func(e *Event) { e.Title = "Lipid panel" },
+		"metadata value": func(e *Event) { e.Metadata["ldl"] = 98.6 },
+		"nested value":   func(e *Event) { e.Metadata["ranges"].(map[string]any)["hdl"] = map[string]any{"min": 41} },
+		"code display":   func(e *Event) { e.Codes[2].Display = "Cholesterol in LDL" },
+		"timestamp":      func(e *Event) { e.Timestamp = e.Timestamp.Add(time.Microsecond) },
+		"schema version": func(e *Event) { e.SchemaVersion = "timeline.v2" },
+	}
+	for name, mutate := range changed {
+		t.Run(name, func(t *testing.T) {
+			e := base()
+			mutate(e)
+			if got, _ := e.Hash(); got == want {
+				t.Errorf("Hash() unchanged after modifying %s", name)
+			}
+		})
+	}
+}
+
+func TestEvent_Hash_SchemaVersionDefault(t *testing.T) {
+	unset := canonicalVectors[0].event()
+	explicit := canonicalVectors[0].event()
+	explicit.SchemaVersion = SchemaVersionTimeline
+
+	a, _ := unset.Hash()
+	b, _ := explicit.Hash()
+	if a != b {
+		t.Errorf("unset schema version should hash as %s", SchemaVersionTimeline)
+	}
+}
+
+func TestEvent_Hash_BlobDigests(t *testing.T) {
+	e := canonicalVectors[2].event()
+
+	without, _ := e.Hash()
+	empty, _ := e.Hash("", " ")
+	if without != empty {
+		t.Error("blank digests should be ignored")
+	}
+
+	one, _ := e.Hash("AA")
+	if one == without {
+		t.Error("adding a blob digest should change the hash")
+	}
+	reordered, _ := e.Hash("bb", "aa")
+	ordered, _ := e.Hash("aa", "BB")
+	if reordered != ordered {
+		t.Error("blob digest order and case should not affect the hash")
+	}
+}
+
+func TestEvent_CanonicalBytes_Unencodable(t *testing.T) {
+	e := canonicalVectors[0].event()
+	e.Metadata = types.Metadata{"bad": make(chan int)}
+
+	if _, err := e.CanonicalBytes(); err == nil {
+		t.Error("CanonicalBytes() expected error for unencodable metadata")
+	}
+}
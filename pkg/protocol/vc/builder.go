@@ -240,28 +240,41 @@ func (b *PresentationBuilder) Build() (*Credential, error) {
 		return nil, types.NewValidationError("credential", "credential is not usable (status: "+string(b.credential.Status)+")")
 	}
 
-	// Create a copy with only disclosed claims
+	// Create a copy with only disclosed claims. Digests, proof and revocation index
+	// are carried over unchanged so the verifier can check the issuer's signature.
 	presentation := &Credential{
-		ID:            b.credential.ID,
-		Issuer:        b.credential.Issuer,
-		Subject:       b.credential.Subject,
-		ClaimType:     b.credential.ClaimType,
-		Claims:        make(map[string]any),
-		Disclosures:   make([]Disclosure, 0),
-		IssuedAt:      b.credential.IssuedAt,
-		ExpiresAt:     b.credential.ExpiresAt,
-		Status:        b.credential.Status,
-		SchemaVersion: b.credential.SchemaVersion,
+		ID:              b.credential.ID,
+		Issuer:          b.credential.Issuer,
+		Subject:         b.credential.Subject,
+		ClaimType:       b.credential.ClaimType,
+		Claims:          make(map[string]any),
+		Disclosures:     make([]Disclosure, 0),
+		Digests:         b.credential.Digests,
+		IssuedAt:        b.credential.IssuedAt,
+		ExpiresAt:       b.credential.ExpiresAt,
+		Status:          b.credential.Status,
+		RevocationIndex: b.credential.RevocationIndex,
+		SchemaVersion:   b.credential.SchemaVersion,
+		Proof:           b.credential.Proof,
+	}
+
+	// Sealed disclosures keep their salts; unsealed claims are disclosed as plain values.
+	sealed := make(map[string]Disclosure, len(b.credential.Disclosures))
+	for _, d := range b.credential.Disclosures {
+		if d.Encoded != "" {
+			sealed[d.Key] = d
+		}
 	}
 
 	// Only include disclosed claims
 	for key, value := range b.credential.Claims {
 		if b.disclosedKeys[key] {
 			presentation.Claims[key] = value
-			presentation.Disclosures = append(presentation.Disclosures, Disclosure{
-				Key:   key,
-				Value: value,
-			})
+			d, ok := sealed[key]
+			if !ok {
+				d = Disclosure{Key: key, Value: value}
+			}
+			presentation.Disclosures = append(presentation.Disclosures, d)
 		}
 	}
 
@@ -0,0 +1,288 @@
+package vc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// ProofTypeEIP191 identifies an issuer proof made with an EIP-191 personal_sign signature.
+const ProofTypeEIP191 = "EIP191Signature"
+
+// proofMessageHeader prefixes every signing message so a wallet prompt shows what is being signed
+// and the signature cannot be replayed as some other Fleming message.
+const proofMessageHeader = "Fleming Verifiable Credential Proof\n"
+
+var (
+	ErrMissingProof       = errors.New("credential has no issuer proof")
+	ErrUnsupportedProof   = errors.New("unsupported proof type")
+	ErrIssuerMismatch     = errors.New("proof issuer does not match credential issuer")
+	ErrInvalidProof       = errors.New("issuer signature does not match credential")
+	ErrDisclosureMismatch = errors.New("disclosure does not match a committed digest")
+	ErrUnsealed           = errors.New("credential disclosures are not sealed")
+	ErrCredentialExpired  = errors.New("credential has expired")
+)
+
+// Proof is an issuer's signature over a credential's commitments.
+type Proof struct {
+	// Type is the proof scheme (ProofTypeEIP191)
+	Type string `json:"type"`
+
+	// Created is when the issuer signed
+	Created time.Time `json:"created"`
+
+	// VerificationMethod is the address whose signature this is (the issuer)
+	VerificationMethod types.WalletAddress `json:"verificationMethod"`
+
+	// ProofValue is the 0x-prefixed 65-byte signature
+	ProofValue string `json:"proofValue"`
+}
+
+// proofPayload is the exact structure the issuer signs. Field order is fixed by the
+// struct, digests are sorted, and timestamps are UTC with second precision so the
+// message survives storage round-trips. Claim values are committed only via digests.
+type proofPayload struct {
+	ID              types.ID  `json:"id"`
+	Issuer          string    `json:"issuer"`
+	Subject         string    `json:"subject"`
+	ClaimType       ClaimType `json:"claimType"`
+	Digests         []string  `json:"digests"`
+	IssuedAt        string    `json:"issuedAt"`
+	ExpiresAt       string    `json:"expiresAt,omitempty"`
+	RevocationIndex *uint64   `json:"revocationIndex,omitempty"`
+	SchemaVersion   string    `json:"schemaVersion"`
+}
+
+// SealDisclosures turns every claim into a salted SD-JWT disclosure and records the
+// sorted digests. Existing salts are kept, so sealing twice is stable. After sealing,
+// any subset of claims can be presented and checked against the issuer's proof.
+func (c *Credential) SealDisclosures() error {
+	existing := make(map[string]Disclosure, len(c.Disclosures))
+	for _, d := range c.Disclosures {
+		existing[d.Key] = d
+	}
+
+	keys := make([]string, 0, len(c.Claims))
+	for key := range c.Claims {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	disclosures := make([]Disclosure, 0, len(keys))
+	digests := make([]string, 0, len(keys))
+	for _, key := range keys {
+		d := existing[key]
+		d.Key = key
+		if d.Encoded == "" || !sameValue(d.Value, c.Claims[key]) {
+			d.Value = c.Claims[key]
+			d.Encoded = ""
+			if _, err := EncodeDisclosure(&d); err != nil {
+				return err
+			}
+		}
+		disclosures = append(disclosures, d)
+		digests = append(digests, ComputeDisclosureDigest(d.Encoded))
+	}
+	sort.Strings(digests)
+
+	c.Disclosures = disclosures
+	c.Digests = digests
+	return nil
+}
+
+// SigningMessage returns the message the issuer signs with personal_sign.
+// The credential must be sealed first.
+func (c *Credential) SigningMessage() (string, error) {
+	if len(c.Digests) == 0 {
+		return "", ErrUnsealed
+	}
+
+	digests := slices.Clone(c.Digests)
+	sort.Strings(digests)
+
+	payload := proofPayload{
+		ID:              c.ID,
+		Issuer:          c.Issuer.String(),
+		Subject:         c.Subject.String(),
+		ClaimType:       c.ClaimType,
+		Digests:         digests,
+		IssuedAt:        c.IssuedAt.UTC().Format(time.RFC3339),
+		RevocationIndex: c.RevocationIndex,
+		SchemaVersion:   c.SchemaVersion,
+	}
+	if c.ExpiresAt != nil {
+		payload.ExpiresAt = c.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encode proof payload: %w", err)
+	}
+	return proofMessageHeader + string(encoded), nil
+}
+
+// AttachProof verifies a signature produced by the issuer's wallet over SigningMessage
+// and stores it as the credential's proof.
+func (c *Credential) AttachProof(signatureHex string, created time.Time) error {
+	message, err := c.SigningMessage()
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifySignature(message, signatureHex, c.Issuer.String()) {
+		return ErrInvalidProof
+	}
+	c.Proof = &Proof{
+		Type:               ProofTypeEIP191,
+		Created:            created.UTC(),
+		VerificationMethod: c.Issuer,
+		ProofValue:         signatureHex,
+	}
+	return nil
+}
+
+// Signer produces EIP-191 personal_sign signatures on behalf of an address.
+type Signer interface {
+	Address() types.WalletAddress
+	SignMessage(message string) (string, error)
+}
+
+// KeySigner is a Signer backed by an in-memory secp256k1 key.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address types.WalletAddress
+}
+
+// NewKeySigner creates a Signer for the given private key.
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	address, _ := types.NewWalletAddress(ethcrypto.PubkeyToAddress(key.PublicKey).Hex())
+	return &KeySigner{key: key, address: address}
+}
+
+// Address returns the signer's wallet address.
+func (s *KeySigner) Address() types.WalletAddress {
+	return s.address
+}
+
+// SignMessage signs message with personal_sign.
+func (s *KeySigner) SignMessage(message string) (string, error) {
+	return crypto.SignMessage(message, s.key)
+}
+
+// SignCredential seals the credential if needed and attaches the signer's proof.
+// The signer must be the credential's issuer.
+func SignCredential(c *Credential, signer Signer) error {
+	if signer.Address() != c.Issuer {
+		return ErrIssuerMismatch
+	}
+	if len(c.Digests) == 0 {
+		if err := c.SealDisclosures(); err != nil {
+			return err
+		}
+	}
+	message, err := c.SigningMessage()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.SignMessage(message)
+	if err != nil {
+		return err
+	}
+	return c.AttachProof(signature, time.Now())
+}
+
+// VerifyCredential checks a complete credential offline, given only the issuer's
+// address: the proof must be the issuer's signature over the credential's commitments,
+// every claim must be backed by a disclosure matching a committed digest, every digest
+// must be disclosed, and the credential must not have expired. Revocation needs the
+// issuer's published list; check it separately with CheckRevocationStatus.
+func VerifyCredential(c *Credential, issuer types.WalletAddress) error {
+	if err := verify(c, issuer); err != nil {
+		return err
+	}
+	if len(c.Disclosures) != len(c.Digests) {
+		return fmt.Errorf("%w: %d of %d digests disclosed", ErrDisclosureMismatch, len(c.Disclosures), len(c.Digests))
+	}
+	return nil
+}
+
+// VerifyPresentation is like VerifyCredential but accepts a selective-disclosure
+// presentation, where only some of the committed digests are disclosed.
+func VerifyPresentation(presentation *Credential, issuer types.WalletAddress) error {
+	return verify(presentation, issuer)
+}
+
+func verify(c *Credential, issuer types.WalletAddress) error {
+	if c.Proof == nil {
+		return ErrMissingProof
+	}
+	if c.Proof.Type != ProofTypeEIP191 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedProof, c.Proof.Type)
+	}
+	if c.Issuer != issuer || c.Proof.VerificationMethod != issuer {
+		return ErrIssuerMismatch
+	}
+
+	message, err := c.SigningMessage()
+	if err != nil {
+		return err
+	}
+	if !crypto.VerifySignature(message, c.Proof.ProofValue, issuer.String()) {
+		return ErrInvalidProof
+	}
+
+	if err := verifyDisclosures(c); err != nil {
+		return err
+	}
+
+	if c.IsExpired() {
+		return ErrCredentialExpired
+	}
+	return nil
+}
+
+// verifyDisclosures checks that each disclosure hashes to a committed digest and
+// decodes to the claim it stands for, and that no claim lacks a disclosure.
+func verifyDisclosures(c *Credential) error {
+	seen := make(map[string]bool, len(c.Disclosures))
+	for _, d := range c.Disclosures {
+		if !slices.Contains(c.Digests, ComputeDisclosureDigest(d.Encoded)) {
+			return fmt.Errorf("%w: %s", ErrDisclosureMismatch, d.Key)
+		}
+		decoded, err := DecodeDisclosure(d.Encoded)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrDisclosureMismatch, err)
+		}
+		if seen[decoded.Key] {
+			return fmt.Errorf("%w: %s disclosed twice", ErrDisclosureMismatch, decoded.Key)
+		}
+		seen[decoded.Key] = true
+
+		value, ok := c.Claims[decoded.Key]
+		if !ok || !sameValue(value, decoded.Value) {
+			return fmt.Errorf("%w: claim %s differs from its disclosure", ErrDisclosureMismatch, decoded.Key)
+		}
+	}
+	for key := range c.Claims {
+		if !seen[key] {
+			return fmt.Errorf("%w: claim %s has no disclosure", ErrDisclosureMismatch, key)
+		}
+	}
+	return nil
+}
+
+// sameValue compares claim values by their JSON encoding, since decoded disclosures
+// carry JSON types (float64, map[string]any) rather than the original Go types.
+func sameValue(a, b any) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(aj, bj)
+}
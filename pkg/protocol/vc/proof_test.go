@@ -0,0 +1,174 @@
+package vc
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+func newSignedCredential(t *testing.T) (*Credential, *KeySigner) {
+	t.Helper()
+
+	key, err := ethcrypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	signer := NewKeySigner(key)
+	subject, _ := types.NewWalletAddress("0x2222222222222222222222222222222222222222")
+	eventID, _ := types.NewID("event-1")
+
+	cred, err := NewCredentialBuilder().
+		WithIssuer(signer.Address()).
+		WithSubject(subject).
+		WithClaimType(ClaimBloodworkRange).
+		AddClaim("marker", "718-7", true).
+		AddClaim("value", 15.0, false).
+		AddClaim("allInRange", true, false).
+		WithSourceEvents(eventID).
+		WithRevocationIndex(7).
+		WithTTL(24 * time.Hour).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := SignCredential(cred, signer); err != nil {
+		t.Fatalf("SignCredential() error = %v", err)
+	}
+	return cred, signer
+}
+
+func TestSealDisclosures_IsStable(t *testing.T) {
+	cred, _ := newSignedCredential(t)
+
+	if len(cred.Digests) != 3 || len(cred.Disclosures) != 3 {
+		t.Fatalf("SealDisclosures() produced %d digests and %d disclosures, want 3 each", len(cred.Digests), len(cred.Disclosures))
+	}
+
+	before := strings.Join(cred.Digests, ",")
+	if err := cred.SealDisclosures(); err != nil {
+		t.Fatalf("SealDisclosures() error = %v", err)
+	}
+	if after := strings.Join(cred.Digests, ","); after != before {
+		t.Error("SealDisclosures() should keep existing salts when resealing")
+	}
+}
+
+func TestVerifyCredential(t *testing.T) {
+	cred, signer := newSignedCredential(t)
+
+	if err := VerifyCredential(cred, signer.Address()); err != nil {
+		t.Fatalf("VerifyCredential() error = %v", err)
+	}
+
+	other, _ := types.NewWalletAddress("0x3333333333333333333333333333333333333333")
+	if err := VerifyCredential(cred, other); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("VerifyCredential() with wrong issuer error = %v, want %v", err, ErrIssuerMismatch)
+	}
+}
+
+func TestVerifyCredential_DetectsTampering(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(c *Credential)
+		wantErr error
+	}{
+		{"subject", func(c *Credential) { c.Subject = "0x4444444444444444444444444444444444444444" }, ErrInvalidProof},
+		{"claim type", func(c *Credential) { c.ClaimType = ClaimAgeOver }, ErrInvalidProof},
+		{"expiry", func(c *Credential) { later := c.ExpiresAt.Add(365 * 24 * time.Hour); c.ExpiresAt = &later }, ErrInvalidProof},
+		{"revocation index", func(c *Credential) { idx := uint64(8); c.RevocationIndex = &idx }, ErrInvalidProof},
+		{"claim value", func(c *Credential) { c.Claims["value"] = 99.0 }, ErrDisclosureMismatch},
+		{"extra claim", func(c *Credential) { c.Claims["injected"] = "x" }, ErrDisclosureMismatch},
+		{"missing proof", func(c *Credential) { c.Proof = nil }, ErrMissingProof},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cred, signer := newSignedCredential(t)
+			tt.mutate(cred)
+			if err := VerifyCredential(cred, signer.Address()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("VerifyCredential() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPresentation(t *testing.T) {
+	cred, signer := newSignedCredential(t)
+
+	presentation, err := NewPresentationBuilder(cred).DiscloseKey("allInRange").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := VerifyPresentation(presentation, signer.Address()); err != nil {
+		t.Fatalf("VerifyPresentation() error = %v", err)
+	}
+	if err := VerifyCredential(presentation, signer.Address()); !errors.Is(err, ErrDisclosureMismatch) {
+		t.Errorf("VerifyCredential() on a partial presentation error = %v, want %v", err, ErrDisclosureMismatch)
+	}
+
+	// A holder cannot swap in a value the issuer never committed to.
+	forged := Disclosure{Salt: presentation.Disclosures[0].Salt, Key: "allInRange", Value: false}
+	if _, err := EncodeDisclosure(&forged); err != nil {
+		t.Fatalf("EncodeDisclosure() error = %v", err)
+	}
+	presentation.Disclosures[0] = forged
+	presentation.Claims["allInRange"] = false
+	if err := VerifyPresentation(presentation, signer.Address()); !errors.Is(err, ErrDisclosureMismatch) {
+		t.Errorf("VerifyPresentation() with forged disclosure error = %v, want %v", err, ErrDisclosureMismatch)
+	}
+}
+
+func TestVerifyPresentation_RejectsUnsignedBuilderOutput(t *testing.T) {
+	issuer, _ := types.NewWalletAddress("0x1111111111111111111111111111111111111111")
+	subject, _ := types.NewWalletAddress("0x2222222222222222222222222222222222222222")
+	eventID, _ := types.NewID("event-1")
+
+	cred := NewCredentialBuilder().
+		WithIssuer(issuer).
+		WithSubject(subject).
+		WithClaimType(ClaimAgeOver).
+		AddClaim("over", 18, false).
+		WithSourceEvents(eventID).
+		MustBuild()
+
+	presentation, err := NewPresentationBuilder(cred).DiscloseAll().Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if err := VerifyPresentation(presentation, issuer); !errors.Is(err, ErrMissingProof) {
+		t.Errorf("VerifyPresentation() error = %v, want %v", err, ErrMissingProof)
+	}
+}
+
+func TestAttachProof_RejectsForeignSignature(t *testing.T) {
+	cred, _ := newSignedCredential(t)
+
+	key, _ := ethcrypto.GenerateKey()
+	impostor := NewKeySigner(key)
+	message, err := cred.SigningMessage()
+	if err != nil {
+		t.Fatalf("SigningMessage() error = %v", err)
+	}
+	sig, _ := impostor.SignMessage(message)
+
+	if err := cred.AttachProof(sig, time.Now()); !errors.Is(err, ErrInvalidProof) {
+		t.Errorf("AttachProof() error = %v, want %v", err, ErrInvalidProof)
+	}
+	if err := SignCredential(cred, impostor); !errors.Is(err, ErrIssuerMismatch) {
+		t.Errorf("SignCredential() error = %v, want %v", err, ErrIssuerMismatch)
+	}
+}
+
+func TestSigningMessage_SurvivesSubsecondTruncation(t *testing.T) {
+	cred, signer := newSignedCredential(t)
+
+	// Databases commonly truncate timestamps; the proof must still verify.
+	cred.IssuedAt = cred.IssuedAt.Truncate(time.Microsecond)
+	if err := VerifyCredential(cred, signer.Address()); err != nil {
+		t.Errorf("VerifyCredential() after truncation error = %v", err)
+	}
+}
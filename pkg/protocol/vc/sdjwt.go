@@ -0,0 +1,239 @@
+package vc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// SD-JWT VC header values. The signature segment of a Fleming SD-JWT carries the
+// issuer's EIP-191 proof over SigningMessage, not a JWS signature over the
+// header and payload, so alg names that scheme rather than a JOSE algorithm.
+const (
+	TypeSDJWTVC  = "dc+sd-jwt"
+	AlgEIP191    = "EIP191"
+	algUnsecured = "none"
+
+	// vctPrefix turns a ClaimType into an SD-JWT VC type identifier.
+	vctPrefix = "urn:fleming:vc:"
+
+	sdJWTSeparator = "~"
+)
+
+var ErrMalformedSDJWT = errors.New("malformed SD-JWT")
+
+type sdJWTHeader struct {
+	Alg     string `json:"alg"`
+	Typ     string `json:"typ"`
+	Kid     string `json:"kid,omitempty"`
+	Created string `json:"created,omitempty"` // When the issuer signed the proof
+}
+
+type sdJWTPayload struct {
+	Iss            string       `json:"iss"`
+	Sub            string       `json:"sub"`
+	Jti            string       `json:"jti"`
+	Iat            int64        `json:"iat"`
+	Exp            int64        `json:"exp,omitempty"`
+	Vct            string       `json:"vct"`
+	SD             []string     `json:"_sd"`
+	SDAlg          string       `json:"_sd_alg"`
+	Status         *sdJWTStatus `json:"status,omitempty"`
+	SchemaVersion  string       `json:"schema_version,omitempty"`
+	SourceEventIDs []types.ID   `json:"source_event_ids,omitempty"`
+}
+
+// sdJWTStatus is a Token Status List reference. The list is published by the
+// issuer's Fleming node, so only the index is carried.
+type sdJWTStatus struct {
+	StatusList struct {
+		Idx uint64 `json:"idx"`
+	} `json:"status_list"`
+}
+
+// EncodeSDJWT renders a sealed credential or presentation as an SD-JWT VC compact
+// string: <jwt>~<disclosure>~...~. Only the disclosures present on c are appended,
+// so a presentation from PresentationBuilder yields a selectively disclosed token.
+func EncodeSDJWT(c *Credential) (string, error) {
+	if len(c.Digests) == 0 {
+		return "", ErrUnsealed
+	}
+	disclosures, err := encodedDisclosures(c)
+	if err != nil {
+		return "", err
+	}
+
+	header := sdJWTHeader{Alg: algUnsecured, Typ: TypeSDJWTVC}
+	var signature []byte
+	if c.Proof != nil {
+		if c.Proof.Type != ProofTypeEIP191 {
+			return "", fmt.Errorf("%w: %s", ErrUnsupportedProof, c.Proof.Type)
+		}
+		if signature, err = hexutil.Decode(c.Proof.ProofValue); err != nil {
+			return "", fmt.Errorf("%w: proof value: %v", ErrInvalidProof, err)
+		}
+		header.Alg = AlgEIP191
+		header.Kid = AddressToDID(c.Proof.VerificationMethod) + verificationMethodFragment
+		header.Created = formatTime(c.Proof.Created)
+	}
+
+	payload := sdJWTPayload{
+		Iss:            AddressToDID(c.Issuer),
+		Sub:            AddressToDID(c.Subject),
+		Jti:            credentialURI(c.ID),
+		Iat:            c.IssuedAt.Unix(),
+		Vct:            vctPrefix + string(c.ClaimType),
+		SD:             c.Digests,
+		SDAlg:          sdAlgSHA256,
+		SchemaVersion:  c.SchemaVersion,
+		SourceEventIDs: c.SourceEventIDs,
+	}
+	if c.ExpiresAt != nil {
+		payload.Exp = c.ExpiresAt.Unix()
+	}
+	if c.RevocationIndex != nil {
+		payload.Status = &sdJWTStatus{}
+		payload.Status.StatusList.Idx = *c.RevocationIndex
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("encode SD-JWT header: %w", err)
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("encode SD-JWT payload: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString(base64.RawURLEncoding.EncodeToString(headerJSON))
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(payloadJSON))
+	b.WriteByte('.')
+	b.WriteString(base64.RawURLEncoding.EncodeToString(signature))
+	for _, d := range disclosures {
+		b.WriteString(sdJWTSeparator)
+		b.WriteString(d)
+	}
+	b.WriteString(sdJWTSeparator)
+	return b.String(), nil
+}
+
+// DecodeSDJWT parses an SD-JWT VC compact string back into a Credential whose
+// claims are those revealed by the attached disclosures. Disclosures keep their
+// original encoding so digests can be recomputed. The result is not verified;
+// pass it to VerifyPresentation for that. Key binding JWTs are not supported.
+func DecodeSDJWT(compact string) (*Credential, error) {
+	parts := strings.Split(compact, sdJWTSeparator)
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%w: missing disclosure separator", ErrMalformedSDJWT)
+	}
+	if parts[len(parts)-1] != "" {
+		return nil, fmt.Errorf("%w: key binding JWTs are not supported", ErrMalformedSDJWT)
+	}
+
+	segments := strings.Split(parts[0], ".")
+	if len(segments) != 3 {
+		return nil, fmt.Errorf("%w: issuer JWT must have 3 segments", ErrMalformedSDJWT)
+	}
+	var header sdJWTHeader
+	if err := decodeSegment(segments[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrMalformedSDJWT, err)
+	}
+	var payload sdJWTPayload
+	if err := decodeSegment(segments[1], &payload); err != nil {
+		return nil, fmt.Errorf("%w: payload: %v", ErrMalformedSDJWT, err)
+	}
+	if payload.SDAlg != sdAlgSHA256 {
+		return nil, fmt.Errorf("%w: unsupported _sd_alg %q", ErrMalformedSDJWT, payload.SDAlg)
+	}
+
+	issuer, err := DIDToAddress(payload.Iss)
+	if err != nil {
+		return nil, err
+	}
+	subject, err := DIDToAddress(payload.Sub)
+	if err != nil {
+		return nil, err
+	}
+
+	disclosures, err := decodeDisclosures(parts[1 : len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Credential{
+		ID:             credentialIDFromURI(payload.Jti),
+		Issuer:         issuer,
+		Subject:        subject,
+		ClaimType:      ClaimType(strings.TrimPrefix(payload.Vct, vctPrefix)),
+		Claims:         make(map[string]any, len(disclosures)),
+		Disclosures:    disclosures,
+		Digests:        payload.SD,
+		SourceEventIDs: payload.SourceEventIDs,
+		IssuedAt:       time.Unix(payload.Iat, 0).UTC(),
+		Status:         StatusActive,
+		SchemaVersion:  payload.SchemaVersion,
+	}
+	for _, d := range disclosures {
+		if _, dup := c.Claims[d.Key]; dup {
+			return nil, fmt.Errorf("%w: claim %s disclosed twice", ErrMalformedSDJWT, d.Key)
+		}
+		c.Claims[d.Key] = d.Value
+	}
+	if payload.Exp != 0 {
+		expiresAt := time.Unix(payload.Exp, 0).UTC()
+		c.ExpiresAt = &expiresAt
+	}
+	if payload.Status != nil {
+		index := payload.Status.StatusList.Idx
+		c.RevocationIndex = &index
+	}
+
+	switch header.Alg {
+	case algUnsecured:
+	case AlgEIP191:
+		if c.Proof, err = decodeSDJWTProof(header, segments[2]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedProof, header.Alg)
+	}
+
+	return c, nil
+}
+
+func decodeSDJWTProof(header sdJWTHeader, segment string) (*Proof, error) {
+	signature, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrMalformedSDJWT, err)
+	}
+	method, err := DIDToAddress(strings.TrimSuffix(header.Kid, verificationMethodFragment))
+	if err != nil {
+		return nil, err
+	}
+	created, err := time.Parse(time.RFC3339, header.Created)
+	if err != nil {
+		return nil, fmt.Errorf("%w: created: %v", ErrMalformedSDJWT, err)
+	}
+	return &Proof{
+		Type:               ProofTypeEIP191,
+		Created:            created.UTC(),
+		VerificationMethod: method,
+		ProofValue:         hexutil.Encode(signature),
+	}, nil
+}
+
+func decodeSegment(segment string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,265 @@
+package vc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// vectorKey is a fixed test key; secp256k1 signing is deterministic (RFC 6979),
+// so credentials built from it encode to byte-identical vectors.
+const vectorKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+// vectorCredential returns a signed credential with fixed salts, times and ID.
+func vectorCredential(t *testing.T) (*Credential, types.WalletAddress) {
+	t.Helper()
+
+	key, err := ethcrypto.HexToECDSA(vectorKey)
+	if err != nil {
+		t.Fatalf("HexToECDSA() error = %v", err)
+	}
+	signer := NewKeySigner(key)
+	subject, _ := types.NewWalletAddress("0x2222222222222222222222222222222222222222")
+	issuedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := time.Date(2099, 1, 2, 3, 4, 5, 0, time.UTC)
+	index := uint64(42)
+
+	cred := &Credential{
+		ID:        "6f1c2d3e-4b5a-4c6d-8e7f-9a0b1c2d3e4f",
+		Issuer:    signer.Address(),
+		Subject:   subject,
+		ClaimType: ClaimBloodworkRange,
+		Claims: map[string]any{
+			"marker":     "718-7",
+			"allInRange": true,
+			"value":      15.5,
+		},
+		Disclosures: []Disclosure{
+			{Salt: "c2FsdC1tYXJrZXI", Key: "marker"},
+			{Salt: "c2FsdC1hbGxJblJhbmdl", Key: "allInRange"},
+			{Salt: "c2FsdC12YWx1ZQ", Key: "value"},
+		},
+		SourceEventIDs:  []types.ID{"event-1"},
+		IssuedAt:        issuedAt,
+		ExpiresAt:       &expiresAt,
+		Status:          StatusActive,
+		RevocationIndex: &index,
+		SchemaVersion:   SchemaVersionVC,
+	}
+	if err := cred.SealDisclosures(); err != nil {
+		t.Fatalf("SealDisclosures() error = %v", err)
+	}
+	message, err := cred.SigningMessage()
+	if err != nil {
+		t.Fatalf("SigningMessage() error = %v", err)
+	}
+	sig, err := signer.SignMessage(message)
+	if err != nil {
+		t.Fatalf("SignMessage() error = %v", err)
+	}
+	if err := cred.AttachProof(sig, issuedAt); err != nil {
+		t.Fatalf("AttachProof() error = %v", err)
+	}
+	return cred, signer.Address()
+}
+
+func TestSDJWT_RoundTrip(t *testing.T) {
+	cred, issuer := vectorCredential(t)
+
+	compact, err := EncodeSDJWT(cred)
+	if err != nil {
+		t.Fatalf("EncodeSDJWT() error = %v", err)
+	}
+	if got := strings.Count(compact, "~"); got != 4 {
+		t.Errorf("EncodeSDJWT() has %d separators, want 4 (3 disclosures, no key binding)", got)
+	}
+
+	decoded, err := DecodeSDJWT(compact)
+	if err != nil {
+		t.Fatalf("DecodeSDJWT() error = %v", err)
+	}
+	if err := VerifyCredential(decoded, issuer); err != nil {
+		t.Fatalf("VerifyCredential() on decoded SD-JWT error = %v", err)
+	}
+
+	if decoded.ID != cred.ID || decoded.Issuer != cred.Issuer || decoded.Subject != cred.Subject || decoded.ClaimType != cred.ClaimType {
+		t.Errorf("DecodeSDJWT() envelope = %+v, want %+v", decoded, cred)
+	}
+	if !decoded.IssuedAt.Equal(cred.IssuedAt) || !decoded.ExpiresAt.Equal(*cred.ExpiresAt) {
+		t.Errorf("DecodeSDJWT() times = %v/%v, want %v/%v", decoded.IssuedAt, decoded.ExpiresAt, cred.IssuedAt, cred.ExpiresAt)
+	}
+	if *decoded.RevocationIndex != *cred.RevocationIndex {
+		t.Errorf("DecodeSDJWT() revocation index = %d, want %d", *decoded.RevocationIndex, *cred.RevocationIndex)
+	}
+	if !reflect.DeepEqual(decoded.SourceEventIDs, cred.SourceEventIDs) {
+		t.Errorf("DecodeSDJWT() source events = %v, want %v", decoded.SourceEventIDs, cred.SourceEventIDs)
+	}
+	if !reflect.DeepEqual(decoded.Claims, cred.Claims) {
+		t.Errorf("DecodeSDJWT() claims = %v, want %v", decoded.Claims, cred.Claims)
+	}
+	if *decoded.Proof != *cred.Proof {
+		t.Errorf("DecodeSDJWT() proof = %+v, want %+v", decoded.Proof, cred.Proof)
+	}
+
+	again, err := EncodeSDJWT(decoded)
+	if err != nil {
+		t.Fatalf("EncodeSDJWT() of decoded credential error = %v", err)
+	}
+	if again != compact {
+		t.Error("EncodeSDJWT() should be stable across a decode round trip")
+	}
+}
+
+func TestSDJWT_SelectiveDisclosure(t *testing.T) {
+	cred, issuer := vectorCredential(t)
+
+	presentation, err := NewPresentationBuilder(cred).DiscloseKey("allInRange").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	compact, err := EncodeSDJWT(presentation)
+	if err != nil {
+		t.Fatalf("EncodeSDJWT() error = %v", err)
+	}
+	if strings.Contains(compact, "718-7") {
+		t.Error("EncodeSDJWT() leaked an undisclosed claim value")
+	}
+
+	decoded, err := DecodeSDJWT(compact)
+	if err != nil {
+		t.Fatalf("DecodeSDJWT() error = %v", err)
+	}
+	if len(decoded.Claims) != 1 || decoded.Claims["allInRange"] != true {
+		t.Errorf("DecodeSDJWT() claims = %v, want only allInRange", decoded.Claims)
+	}
+	if err := VerifyPresentation(decoded, issuer); err != nil {
+		t.Errorf("VerifyPresentation() error = %v", err)
+	}
+}
+
+func TestSDJWT_Vector(t *testing.T) {
+	cred, _ := vectorCredential(t)
+
+	compact, err := EncodeSDJWT(cred)
+	if err != nil {
+		t.Fatalf("EncodeSDJWT() error = %v", err)
+	}
+	if compact != sdJWTVector {
+		t.Errorf("EncodeSDJWT() =\n%s\nwant\n%s", compact, sdJWTVector)
+	}
+}
+
+// TestSDJWT_SpecDisclosures checks disclosures taken verbatim from the SD-JWT
+// specification examples. Other implementations serialize the disclosure array
+// with whitespace, so digests must be computed over the received encoding.
+func TestSDJWT_SpecDisclosures(t *testing.T) {
+	vectors := []struct {
+		encoded string
+		digest  string
+		key     string
+		value   any
+	}{
+		{
+			encoded: "WyJfMjZiYzRMVC1hYzZxMktJNmNCVzVlcyIsICJmYW1pbHlfbmFtZSIsICJNw7ZiaXVzIl0",
+			digest:  "X9yH0Ajrdm1Oij4tWso9UzzKJvPoDxwmuEcO3XAdRC0",
+			key:     "family_name",
+			value:   "Möbius",
+		},
+		{
+			encoded: "WyIyR0xDNDJzS1F2ZUNmR2ZyeU5STjl3IiwgImdpdmVuX25hbWUiLCAiSm9obiJd",
+			digest:  "jsu9yVulwQQlhFlM_3JlzMaSFzglhQG0DpfayQwLUK4",
+			key:     "given_name",
+			value:   "John",
+		},
+	}
+
+	// An unsecured issuer JWT from another implementation carrying the spec digests.
+	header := "eyJhbGciOiJub25lIiwidHlwIjoiZGMrc2Qtand0In0"
+	payload := encodeTestSegment(t, map[string]any{
+		"iss":     "did:pkh:eip155:137:0x1111111111111111111111111111111111111111",
+		"sub":     "did:pkh:eip155:137:0x2222222222222222222222222222222222222222",
+		"jti":     "urn:uuid:0b5c8f3a-1d2e-4f60-8a7b-9c0d1e2f3a4b",
+		"iat":     1700000000,
+		"vct":     "urn:fleming:vc:AgeOver",
+		"_sd":     []string{vectors[0].digest, vectors[1].digest},
+		"_sd_alg": "sha-256",
+	})
+	compact := header + "." + payload + ".~" + vectors[0].encoded + "~" + vectors[1].encoded + "~"
+
+	decoded, err := DecodeSDJWT(compact)
+	if err != nil {
+		t.Fatalf("DecodeSDJWT() error = %v", err)
+	}
+	if decoded.Issuer != "0x1111111111111111111111111111111111111111" || decoded.ClaimType != ClaimAgeOver {
+		t.Errorf("DecodeSDJWT() issuer/type = %s/%s", decoded.Issuer, decoded.ClaimType)
+	}
+	if decoded.ID != "0b5c8f3a-1d2e-4f60-8a7b-9c0d1e2f3a4b" || decoded.Proof != nil {
+		t.Errorf("DecodeSDJWT() id = %s, proof = %v", decoded.ID, decoded.Proof)
+	}
+	for i, v := range vectors {
+		if got := ComputeDisclosureDigest(decoded.Disclosures[i].Encoded); got != v.digest {
+			t.Errorf("digest[%d] = %s, want %s", i, got, v.digest)
+		}
+		if decoded.Claims[v.key] != v.value {
+			t.Errorf("claim %s = %v, want %v", v.key, decoded.Claims[v.key], v.value)
+		}
+	}
+	if err := verifyDisclosures(decoded); err != nil {
+		t.Errorf("verifyDisclosures() error = %v", err)
+	}
+	if err := VerifyPresentation(decoded, decoded.Issuer); !errors.Is(err, ErrMissingProof) {
+		t.Errorf("VerifyPresentation() on unsecured SD-JWT error = %v, want %v", err, ErrMissingProof)
+	}
+}
+
+func TestDecodeSDJWT_Errors(t *testing.T) {
+	cred, _ := vectorCredential(t)
+	compact, _ := EncodeSDJWT(cred)
+	jwt := compact[:strings.Index(compact, "~")]
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"no separator", jwt, ErrMalformedSDJWT},
+		{"key binding", compact + "eyJhbGciOiJFUzI1NiJ9.e30.sig", ErrMalformedSDJWT},
+		{"two segments", "eyJhbGciOiJub25lIn0.e30~", ErrMalformedSDJWT},
+		{"unsupported alg", "eyJhbGciOiJFUzI1NiIsInR5cCI6ImRjK3NkLWp3dCJ9" + jwt[strings.Index(jwt, "."):] + "~", ErrUnsupportedProof},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeSDJWT(tt.input); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodeSDJWT() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncodeSDJWT_RequiresSealedCredential(t *testing.T) {
+	cred := &Credential{Claims: map[string]any{"over": 18}}
+	if _, err := EncodeSDJWT(cred); !errors.Is(err, ErrUnsealed) {
+		t.Errorf("EncodeSDJWT() error = %v, want %v", err, ErrUnsealed)
+	}
+}
+
+func encodeTestSegment(t *testing.T, v any) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// sdJWTVector pins the encoding of vectorCredential. Changing it breaks
+// tokens already handed to verifiers.
+const sdJWTVector = "eyJhbGciOiJFSVAxOTEiLCJ0eXAiOiJkYytzZC1qd3QiLCJraWQiOiJkaWQ6cGtoOmVpcDE1NToxOjB4MmM3NTM2ZTM2MDVkOWMxNmE3YTNkN2IxODk4ZTUyOTM5NmE2NWMyMyNibG9ja2NoYWluQWNjb3VudElkIiwiY3JlYXRlZCI6IjIwMjUtMDEtMDJUMDM6MDQ6MDVaIn0.eyJpc3MiOiJkaWQ6cGtoOmVpcDE1NToxOjB4MmM3NTM2ZTM2MDVkOWMxNmE3YTNkN2IxODk4ZTUyOTM5NmE2NWMyMyIsInN1YiI6ImRpZDpwa2g6ZWlwMTU1OjE6MHgyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyMjIyIiwianRpIjoidXJuOnV1aWQ6NmYxYzJkM2UtNGI1YS00YzZkLThlN2YtOWEwYjFjMmQzZTRmIiwiaWF0IjoxNzM1Nzg3MDQ1LCJleHAiOjQwNzEwMDYyNDUsInZjdCI6InVybjpmbGVtaW5nOnZjOkJsb29kd29ya1JhbmdlIiwiX3NkIjpbImhsU0dFSEJRUzRDMDVtRUZPWGx3QlNsTFNhTURWWlNCcW0tblBhNlczUVEiLCJrQWlhQ0pXUkg0ZUl1ZzVjMmJLSExNUWhfSUFDcm5wRk5WT2FfOTNZZjJFIiwibEZ3anhwYkdEaHV0cnBNUlZwNHEycjN5LXFFSTFGVDFiblBHMFVDdjkwYyJdLCJfc2RfYWxnIjoic2hhLTI1NiIsInN0YXR1cyI6eyJzdGF0dXNfbGlzdCI6eyJpZHgiOjQyfX0sInNjaGVtYV92ZXJzaW9uIjoidmMudjEiLCJzb3VyY2VfZXZlbnRfaWRzIjpbImV2ZW50LTEiXX0.0YfvsQoRt2_ylrzud6845hyrOD1XE44pL4PH8M6GpM8pEt2RbVZlcM2D-MCPIhp0zsv9hUS-alylE0pkknxCtBs~WyJjMkZzZEMxaGJHeEpibEpoYm1kbCIsImFsbEluUmFuZ2UiLHRydWVd~WyJjMkZzZEMxdFlYSnJaWEkiLCJtYXJrZXIiLCI3MTgtNyJd~WyJjMkZzZEMxMllXeDFaUSIsInZhbHVlIiwxNS41XQ~"
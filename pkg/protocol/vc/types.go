@@ -147,6 +147,10 @@ type Credential struct {
 	// Only populated when presenting with disclosures
 	Disclosures []Disclosure `json:"disclosures,omitempty"`
 
+	// Digests are the SD-JWT digests of every sealed disclosure, sorted.
+	// The issuer's proof commits to these rather than to the claim values.
+	Digests []string `json:"digests,omitempty"`
+
 	// SourceEventIDs are the timeline event IDs that back this credential
 	SourceEventIDs []types.ID `json:"sourceEventIds,omitempty"`
 
@@ -164,6 +168,9 @@ type Credential struct {
 
 	// SchemaVersion is the protocol schema version
 	SchemaVersion string `json:"schemaVersion"`
+
+	// Proof is the issuer's signature over the credential (see SigningMessage)
+	Proof *Proof `json:"proof,omitempty"`
 }
 
 // Validate validates the credential structure.
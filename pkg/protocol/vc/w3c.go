@@ -0,0 +1,296 @@
+package vc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/itspablomontes/fleming/pkg/protocol/types"
+)
+
+// W3C VC 2.0 vocabulary used by the JSON-LD encoding.
+const (
+	ContextW3CV2             = "https://www.w3.org/ns/credentials/v2"
+	TypeVerifiableCredential = "VerifiableCredential"
+
+	// didPKHPrefix is the did:pkh prefix for Ethereum mainnet accounts (CAIP-10).
+	// Fleming addresses are chain-agnostic, so mainnet is used as the canonical chain.
+	didPKHPrefix = "did:pkh:eip155:1:"
+
+	// verificationMethodFragment names the account key inside a did:pkh document.
+	verificationMethodFragment = "#blockchainAccountId"
+
+	evidenceIDPrefix = "urn:fleming:event:"
+	sdAlgSHA256      = "sha-256"
+)
+
+var (
+	ErrMalformedDocument = errors.New("malformed W3C credential document")
+	ErrInvalidDID        = errors.New("invalid did:pkh identifier")
+	ErrReservedClaim     = errors.New("claim name is reserved")
+)
+
+// w3cDocument is the W3C VC 2.0 JSON-LD shape of a Credential. Terms that the base
+// context does not define (schemaVersion, selectiveDisclosure) resolve through its
+// issuer-dependent @vocab.
+type w3cDocument struct {
+	Context             []string                `json:"@context"`
+	ID                  string                  `json:"id"`
+	Type                []string                `json:"type"`
+	Issuer              string                  `json:"issuer"`
+	ValidFrom           string                  `json:"validFrom"`
+	ValidUntil          string                  `json:"validUntil,omitempty"`
+	CredentialSubject   map[string]any          `json:"credentialSubject"`
+	CredentialStatus    *w3cStatus              `json:"credentialStatus,omitempty"`
+	Evidence            []w3cEvidence           `json:"evidence,omitempty"`
+	SchemaVersion       string                  `json:"schemaVersion,omitempty"`
+	SelectiveDisclosure *w3cSelectiveDisclosure `json:"selectiveDisclosure,omitempty"`
+	Proof               *w3cProof               `json:"proof,omitempty"`
+}
+
+// w3cStatus is a Bitstring Status List entry. The list itself is published by the
+// issuer's Fleming node, so statusListCredential is resolved out of band.
+type w3cStatus struct {
+	Type            string `json:"type"`
+	StatusPurpose   string `json:"statusPurpose"`
+	StatusListIndex string `json:"statusListIndex"`
+}
+
+type w3cEvidence struct {
+	ID   string   `json:"id"`
+	Type []string `json:"type"`
+}
+
+// w3cSelectiveDisclosure carries the SD-JWT digests the proof commits to and the
+// disclosures for the claims present in credentialSubject.
+type w3cSelectiveDisclosure struct {
+	Alg         string   `json:"alg"`
+	Digests     []string `json:"digests"`
+	Disclosures []string `json:"disclosures,omitempty"`
+}
+
+type w3cProof struct {
+	Type               string `json:"type"`
+	Created            string `json:"created"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofPurpose       string `json:"proofPurpose"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// EncodeW3C renders a credential or presentation as a W3C VC 2.0 JSON-LD document.
+// Only the claims present on c (its selected disclosures) appear in credentialSubject.
+func EncodeW3C(c *Credential) ([]byte, error) {
+	subject := make(map[string]any, len(c.Claims)+1)
+	for key, value := range c.Claims {
+		if key == "id" {
+			return nil, fmt.Errorf("%w: %s", ErrReservedClaim, key)
+		}
+		subject[key] = value
+	}
+	subject["id"] = AddressToDID(c.Subject)
+
+	doc := w3cDocument{
+		Context:           []string{ContextW3CV2},
+		ID:                credentialURI(c.ID),
+		Type:              []string{TypeVerifiableCredential, string(c.ClaimType)},
+		Issuer:            AddressToDID(c.Issuer),
+		ValidFrom:         formatTime(c.IssuedAt),
+		CredentialSubject: subject,
+		SchemaVersion:     c.SchemaVersion,
+	}
+	if c.ExpiresAt != nil {
+		doc.ValidUntil = formatTime(*c.ExpiresAt)
+	}
+	if c.RevocationIndex != nil {
+		doc.CredentialStatus = &w3cStatus{
+			Type:            "BitstringStatusListEntry",
+			StatusPurpose:   "revocation",
+			StatusListIndex: strconv.FormatUint(*c.RevocationIndex, 10),
+		}
+	}
+	for _, id := range c.SourceEventIDs {
+		doc.Evidence = append(doc.Evidence, w3cEvidence{ID: evidenceIDPrefix + id.String(), Type: []string{"TimelineEvent"}})
+	}
+	if len(c.Digests) > 0 {
+		encoded, err := encodedDisclosures(c)
+		if err != nil {
+			return nil, err
+		}
+		doc.SelectiveDisclosure = &w3cSelectiveDisclosure{Alg: sdAlgSHA256, Digests: c.Digests, Disclosures: encoded}
+	}
+	if c.Proof != nil {
+		doc.Proof = &w3cProof{
+			Type:               c.Proof.Type,
+			Created:            formatTime(c.Proof.Created),
+			VerificationMethod: AddressToDID(c.Proof.VerificationMethod) + verificationMethodFragment,
+			ProofPurpose:       "assertionMethod",
+			ProofValue:         c.Proof.ProofValue,
+		}
+	}
+
+	return json.Marshal(doc)
+}
+
+// DecodeW3C parses a document produced by EncodeW3C back into a Credential.
+// The result is not verified; pass it to VerifyPresentation for that.
+func DecodeW3C(data []byte) (*Credential, error) {
+	var doc w3cDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedDocument, err)
+	}
+	if !slices.Contains(doc.Context, ContextW3CV2) {
+		return nil, fmt.Errorf("%w: missing %s context", ErrMalformedDocument, ContextW3CV2)
+	}
+	if len(doc.Type) != 2 || doc.Type[0] != TypeVerifiableCredential {
+		return nil, fmt.Errorf("%w: type must be [%s, <claim type>]", ErrMalformedDocument, TypeVerifiableCredential)
+	}
+
+	issuer, err := DIDToAddress(doc.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	subjectDID, _ := doc.CredentialSubject["id"].(string)
+	subject, err := DIDToAddress(subjectDID)
+	if err != nil {
+		return nil, err
+	}
+	issuedAt, err := parseTime("validFrom", doc.ValidFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Credential{
+		ID:            credentialIDFromURI(doc.ID),
+		Issuer:        issuer,
+		Subject:       subject,
+		ClaimType:     ClaimType(doc.Type[1]),
+		Claims:        make(map[string]any, len(doc.CredentialSubject)),
+		IssuedAt:      issuedAt,
+		Status:        StatusActive,
+		SchemaVersion: doc.SchemaVersion,
+	}
+	for key, value := range doc.CredentialSubject {
+		if key != "id" {
+			c.Claims[key] = value
+		}
+	}
+	if doc.ValidUntil != "" {
+		expiresAt, err := parseTime("validUntil", doc.ValidUntil)
+		if err != nil {
+			return nil, err
+		}
+		c.ExpiresAt = &expiresAt
+	}
+	if doc.CredentialStatus != nil {
+		index, err := strconv.ParseUint(doc.CredentialStatus.StatusListIndex, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: statusListIndex: %v", ErrMalformedDocument, err)
+		}
+		c.RevocationIndex = &index
+	}
+	for _, ev := range doc.Evidence {
+		c.SourceEventIDs = append(c.SourceEventIDs, types.ID(strings.TrimPrefix(ev.ID, evidenceIDPrefix)))
+	}
+	if sd := doc.SelectiveDisclosure; sd != nil {
+		if sd.Alg != sdAlgSHA256 {
+			return nil, fmt.Errorf("%w: unsupported digest algorithm %q", ErrMalformedDocument, sd.Alg)
+		}
+		c.Digests = sd.Digests
+		if c.Disclosures, err = decodeDisclosures(sd.Disclosures); err != nil {
+			return nil, err
+		}
+	}
+	if doc.Proof != nil {
+		method, err := DIDToAddress(strings.TrimSuffix(doc.Proof.VerificationMethod, verificationMethodFragment))
+		if err != nil {
+			return nil, err
+		}
+		created, err := parseTime("proof.created", doc.Proof.Created)
+		if err != nil {
+			return nil, err
+		}
+		c.Proof = &Proof{Type: doc.Proof.Type, Created: created, VerificationMethod: method, ProofValue: doc.Proof.ProofValue}
+	}
+
+	return c, nil
+}
+
+// AddressToDID returns the did:pkh identifier for a wallet address.
+func AddressToDID(addr types.WalletAddress) string {
+	return didPKHPrefix + addr.String()
+}
+
+// DIDToAddress parses a did:pkh:eip155 identifier (on any chain) or a bare address.
+func DIDToAddress(did string) (types.WalletAddress, error) {
+	account := did
+	if strings.HasPrefix(did, "did:") {
+		parts := strings.Split(did, ":")
+		if len(parts) != 5 || parts[1] != "pkh" || parts[2] != "eip155" {
+			return "", fmt.Errorf("%w: %q", ErrInvalidDID, did)
+		}
+		account = parts[4]
+	}
+	if len(account) != 42 || !strings.HasPrefix(account, "0x") {
+		return "", fmt.Errorf("%w: %q", ErrInvalidDID, did)
+	}
+	return types.NewWalletAddress(account)
+}
+
+// credentialURI renders a credential ID as a URI, as VC 2.0 requires for id.
+func credentialURI(id types.ID) string {
+	if _, err := uuid.Parse(id.String()); err == nil {
+		return "urn:uuid:" + id.String()
+	}
+	return id.String()
+}
+
+func credentialIDFromURI(uri string) types.ID {
+	return types.ID(strings.TrimPrefix(uri, "urn:uuid:"))
+}
+
+// encodedDisclosures returns c's disclosures in claim-name order, which keeps
+// encodings deterministic. Every disclosure must already be sealed.
+func encodedDisclosures(c *Credential) ([]string, error) {
+	disclosures := slices.Clone(c.Disclosures)
+	sort.Slice(disclosures, func(i, j int) bool { return disclosures[i].Key < disclosures[j].Key })
+
+	encoded := make([]string, 0, len(disclosures))
+	for _, d := range disclosures {
+		if d.Encoded == "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnsealed, d.Key)
+		}
+		encoded = append(encoded, d.Encoded)
+	}
+	return encoded, nil
+}
+
+func decodeDisclosures(encoded []string) ([]Disclosure, error) {
+	disclosures := make([]Disclosure, 0, len(encoded))
+	for _, e := range encoded {
+		d, err := DecodeDisclosure(e)
+		if err != nil {
+			return nil, err
+		}
+		disclosures = append(disclosures, *d)
+	}
+	return disclosures, nil
+}
+
+// formatTime uses second precision, matching what the issuer's proof commits to.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseTime(field, value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s: %v", ErrMalformedDocument, field, err)
+	}
+	return t.UTC(), nil
+}
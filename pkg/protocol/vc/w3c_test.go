@@ -0,0 +1,156 @@
+package vc
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestW3C_RoundTrip(t *testing.T) {
+	cred, issuer := vectorCredential(t)
+
+	doc, err := EncodeW3C(cred)
+	if err != nil {
+		t.Fatalf("EncodeW3C() error = %v", err)
+	}
+
+	decoded, err := DecodeW3C(doc)
+	if err != nil {
+		t.Fatalf("DecodeW3C() error = %v", err)
+	}
+	if err := VerifyCredential(decoded, issuer); err != nil {
+		t.Fatalf("VerifyCredential() on decoded document error = %v", err)
+	}
+	if decoded.ID != cred.ID || decoded.Subject != cred.Subject || decoded.ClaimType != cred.ClaimType {
+		t.Errorf("DecodeW3C() envelope = %+v, want %+v", decoded, cred)
+	}
+	if !reflect.DeepEqual(decoded.Claims, cred.Claims) {
+		t.Errorf("DecodeW3C() claims = %v, want %v", decoded.Claims, cred.Claims)
+	}
+	if !reflect.DeepEqual(decoded.SourceEventIDs, cred.SourceEventIDs) {
+		t.Errorf("DecodeW3C() source events = %v, want %v", decoded.SourceEventIDs, cred.SourceEventIDs)
+	}
+	if *decoded.Proof != *cred.Proof {
+		t.Errorf("DecodeW3C() proof = %+v, want %+v", decoded.Proof, cred.Proof)
+	}
+
+	again, err := EncodeW3C(decoded)
+	if err != nil {
+		t.Fatalf("EncodeW3C() of decoded credential error = %v", err)
+	}
+	if string(again) != string(doc) {
+		t.Error("EncodeW3C() should be stable across a decode round trip")
+	}
+}
+
+func TestW3C_Vector(t *testing.T) {
+	cred, _ := vectorCredential(t)
+
+	doc, err := EncodeW3C(cred)
+	if err != nil {
+		t.Fatalf("EncodeW3C() error = %v", err)
+	}
+	if string(doc) != w3cVector {
+		t.Errorf("EncodeW3C() =\n%s\nwant\n%s", doc, w3cVector)
+	}
+}
+
+// TestW3C_DataModel checks the members a generic VC 2.0 processor relies on.
+func TestW3C_DataModel(t *testing.T) {
+	cred, _ := vectorCredential(t)
+	presentation, err := NewPresentationBuilder(cred).DiscloseKey("marker").Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	doc, err := EncodeW3C(presentation)
+	if err != nil {
+		t.Fatalf("EncodeW3C() error = %v", err)
+	}
+	var generic map[string]any
+	if err := json.Unmarshal(doc, &generic); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	if ctx := generic["@context"].([]any); ctx[0] != ContextW3CV2 {
+		t.Errorf("@context[0] = %v, want %s", ctx[0], ContextW3CV2)
+	}
+	if typ := generic["type"].([]any); typ[0] != TypeVerifiableCredential || typ[1] != string(ClaimBloodworkRange) {
+		t.Errorf("type = %v", typ)
+	}
+	if generic["id"] != "urn:uuid:6f1c2d3e-4b5a-4c6d-8e7f-9a0b1c2d3e4f" {
+		t.Errorf("id = %v", generic["id"])
+	}
+	if generic["issuer"] != AddressToDID(cred.Issuer) || generic["validFrom"] != "2025-01-02T03:04:05Z" {
+		t.Errorf("issuer/validFrom = %v/%v", generic["issuer"], generic["validFrom"])
+	}
+
+	subject := generic["credentialSubject"].(map[string]any)
+	want := map[string]any{"id": "did:pkh:eip155:1:0x2222222222222222222222222222222222222222", "marker": "718-7"}
+	if !reflect.DeepEqual(subject, want) {
+		t.Errorf("credentialSubject = %v, want %v", subject, want)
+	}
+	status := generic["credentialStatus"].(map[string]any)
+	if status["type"] != "BitstringStatusListEntry" || status["statusListIndex"] != "42" {
+		t.Errorf("credentialStatus = %v", status)
+	}
+}
+
+func TestEncodeW3C_RejectsReservedClaim(t *testing.T) {
+	cred := &Credential{Claims: map[string]any{"id": "x"}}
+	if _, err := EncodeW3C(cred); !errors.Is(err, ErrReservedClaim) {
+		t.Errorf("EncodeW3C() error = %v, want %v", err, ErrReservedClaim)
+	}
+}
+
+func TestDecodeW3C_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"not json", "{", ErrMalformedDocument},
+		{"missing context", `{"type":["VerifiableCredential","AgeOver"]}`, ErrMalformedDocument},
+		{"missing claim type", `{"@context":["https://www.w3.org/ns/credentials/v2"],"type":["VerifiableCredential"]}`, ErrMalformedDocument},
+		{"non-pkh issuer", `{"@context":["https://www.w3.org/ns/credentials/v2"],"type":["VerifiableCredential","AgeOver"],"issuer":"did:web:example.com"}`, ErrInvalidDID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeW3C([]byte(tt.input)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("DecodeW3C() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDIDToAddress(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"did:pkh:eip155:1:0xAbCdEf0123456789AbCdEf0123456789aBcDeF01", "0xabcdef0123456789abcdef0123456789abcdef01", false},
+		{"did:pkh:eip155:137:0x1111111111111111111111111111111111111111", "0x1111111111111111111111111111111111111111", false},
+		{"0x1111111111111111111111111111111111111111", "0x1111111111111111111111111111111111111111", false},
+		{"did:pkh:solana:1:0x1111111111111111111111111111111111111111", "", true},
+		{"did:pkh:eip155:1:0x1234", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := DIDToAddress(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DIDToAddress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.String() != tt.want {
+				t.Errorf("DIDToAddress() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// w3cVector pins the JSON-LD encoding of vectorCredential.
+const w3cVector = `{"@context":["https://www.w3.org/ns/credentials/v2"],"id":"urn:uuid:6f1c2d3e-4b5a-4c6d-8e7f-9a0b1c2d3e4f","type":["VerifiableCredential","BloodworkRange"],"issuer":"did:pkh:eip155:1:0x2c7536e3605d9c16a7a3d7b1898e529396a65c23","validFrom":"2025-01-02T03:04:05Z","validUntil":"2099-01-02T03:04:05Z","credentialSubject":{"allInRange":true,"id":"did:pkh:eip155:1:0x2222222222222222222222222222222222222222","marker":"718-7","value":15.5},"credentialStatus":{"type":"BitstringStatusListEntry","statusPurpose":"revocation","statusListIndex":"42"},"evidence":[{"id":"urn:fleming:event:event-1","type":["TimelineEvent"]}],"schemaVersion":"vc.v1","selectiveDisclosure":{"alg":"sha-256","digests":["hlSGEHBQS4C05mEFOXlwBSlLSaMDVZSBqm-nPa6W3QQ","kAiaCJWRH4eIug5c2bKHLMQh_IACrnpFNVOa_93Yf2E","lFwjxpbGDhutrpMRVp4q2r3y-qEI1FT1bnPG0UCv90c"],"disclosures":["WyJjMkZzZEMxaGJHeEpibEpoYm1kbCIsImFsbEluUmFuZ2UiLHRydWVd","WyJjMkZzZEMxdFlYSnJaWEkiLCJtYXJrZXIiLCI3MTgtNyJd","WyJjMkZzZEMxMllXeDFaUSIsInZhbHVlIiwxNS41XQ"]},"proof":{"type":"EIP191Signature","created":"2025-01-02T03:04:05Z","verificationMethod":"did:pkh:eip155:1:0x2c7536e3605d9c16a7a3d7b1898e529396a65c23#blockchainAccountId","proofPurpose":"assertionMethod","proofValue":"0xd187efb10a11b76ff296bcee77af38e61cab383d57138e292f83c7f0ce86a4cf2912dd916d566570cd83f8c08f221a74cecbfd8544be6a5ca5134a64927c42b41b"}}`
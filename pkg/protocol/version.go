@@ -39,8 +39,10 @@ func SchemaVersion(component string) string {
 const (
 	SchemaVersionTimeline    = "timeline.v1"
 	SchemaVersionConsent     = "consent.v1"
-	SchemaVersionAudit       = "audit.v1"
+	SchemaVersionAudit       = "audit.v2"
 	SchemaVersionIdentity    = "identity.v1"
 	SchemaVersionVC          = "vc.v1"
 	SchemaVersionAttestation = "attestation.v1"
+	SchemaVersionDelegation  = "delegation.v1"
+	SchemaVersionReceipt     = "receipt.v1"
 )